@@ -38,8 +38,11 @@ import (
 )
 
 type HealthStatus struct {
-	Ok  bool    `json:"ok,required"`
-	Msg *string `json:"msg,omitempty"`
+	Ok                 bool    `json:"ok,required"`
+	Msg                *string `json:"msg,omitempty"`
+	LatencyInMillis    *int64  `json:"latencyInMillis,omitempty"`
+	DegradedReason     *string `json:"degradedReason,omitempty"`
+	RetryAfterInMillis *int64  `json:"retryAfterInMillis,omitempty"`
 }
 
 // ToWire translates a HealthStatus struct into a Thrift-level intermediate
@@ -59,7 +62,7 @@ type HealthStatus struct {
 //	}
 func (v *HealthStatus) ToWire() (wire.Value, error) {
 	var (
-		fields [2]wire.Field
+		fields [5]wire.Field
 		i      int = 0
 		w      wire.Value
 		err    error
@@ -79,6 +82,30 @@ func (v *HealthStatus) ToWire() (wire.Value, error) {
 		fields[i] = wire.Field{ID: 2, Value: w}
 		i++
 	}
+	if v.LatencyInMillis != nil {
+		w, err = wire.NewValueI64(*(v.LatencyInMillis)), error(nil)
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 3, Value: w}
+		i++
+	}
+	if v.DegradedReason != nil {
+		w, err = wire.NewValueString(*(v.DegradedReason)), error(nil)
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 4, Value: w}
+		i++
+	}
+	if v.RetryAfterInMillis != nil {
+		w, err = wire.NewValueI64(*(v.RetryAfterInMillis)), error(nil)
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 5, Value: w}
+		i++
+	}
 
 	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
 }
@@ -124,6 +151,36 @@ func (v *HealthStatus) FromWire(w wire.Value) error {
 					return err
 				}
 
+			}
+		case 3:
+			if field.Value.Type() == wire.TI64 {
+				var x int64
+				x, err = field.Value.GetI64(), error(nil)
+				v.LatencyInMillis = &x
+				if err != nil {
+					return err
+				}
+
+			}
+		case 4:
+			if field.Value.Type() == wire.TBinary {
+				var x string
+				x, err = field.Value.GetString(), error(nil)
+				v.DegradedReason = &x
+				if err != nil {
+					return err
+				}
+
+			}
+		case 5:
+			if field.Value.Type() == wire.TI64 {
+				var x int64
+				x, err = field.Value.GetI64(), error(nil)
+				v.RetryAfterInMillis = &x
+				if err != nil {
+					return err
+				}
+
 			}
 		}
 	}
@@ -166,17 +223,2131 @@ func (v *HealthStatus) Encode(sw stream.Writer) error {
 		}
 	}
 
+	if v.LatencyInMillis != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 3, Type: wire.TI64}); err != nil {
+			return err
+		}
+		if err := sw.WriteInt64(*(v.LatencyInMillis)); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	if v.DegradedReason != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 4, Type: wire.TBinary}); err != nil {
+			return err
+		}
+		if err := sw.WriteString(*(v.DegradedReason)); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	if v.RetryAfterInMillis != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 5, Type: wire.TI64}); err != nil {
+			return err
+		}
+		if err := sw.WriteInt64(*(v.RetryAfterInMillis)); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return sw.WriteStructEnd()
+}
+
+// Decode deserializes a HealthStatus struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a HealthStatus struct could not be generated from the wire
+// representation.
+func (v *HealthStatus) Decode(sr stream.Reader) error {
+
+	okIsSet := false
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		case fh.ID == 1 && fh.Type == wire.TBool:
+			v.Ok, err = sr.ReadBool()
+			if err != nil {
+				return err
+			}
+			okIsSet = true
+		case fh.ID == 2 && fh.Type == wire.TBinary:
+			var x string
+			x, err = sr.ReadString()
+			v.Msg = &x
+			if err != nil {
+				return err
+			}
+
+		case fh.ID == 3 && fh.Type == wire.TI64:
+			var x int64
+			x, err = sr.ReadInt64()
+			v.LatencyInMillis = &x
+			if err != nil {
+				return err
+			}
+
+		case fh.ID == 4 && fh.Type == wire.TBinary:
+			var x string
+			x, err = sr.ReadString()
+			v.DegradedReason = &x
+			if err != nil {
+				return err
+			}
+
+		case fh.ID == 5 && fh.Type == wire.TI64:
+			var x int64
+			x, err = sr.ReadInt64()
+			v.RetryAfterInMillis = &x
+			if err != nil {
+				return err
+			}
+
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	if !okIsSet {
+		return errors.New("field Ok of HealthStatus is required")
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a HealthStatus
+// struct.
+func (v *HealthStatus) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [5]string
+	i := 0
+	fields[i] = fmt.Sprintf("Ok: %v", v.Ok)
+	i++
+	if v.Msg != nil {
+		fields[i] = fmt.Sprintf("Msg: %v", *(v.Msg))
+		i++
+	}
+	if v.LatencyInMillis != nil {
+		fields[i] = fmt.Sprintf("LatencyInMillis: %v", *(v.LatencyInMillis))
+		i++
+	}
+	if v.DegradedReason != nil {
+		fields[i] = fmt.Sprintf("DegradedReason: %v", *(v.DegradedReason))
+		i++
+	}
+	if v.RetryAfterInMillis != nil {
+		fields[i] = fmt.Sprintf("RetryAfterInMillis: %v", *(v.RetryAfterInMillis))
+		i++
+	}
+
+	return fmt.Sprintf("HealthStatus{%v}", strings.Join(fields[:i], ", "))
+}
+
+func _String_EqualsPtr(lhs, rhs *string) bool {
+	if lhs != nil && rhs != nil {
+
+		x := *lhs
+		y := *rhs
+		return (x == y)
+	}
+	return lhs == nil && rhs == nil
+}
+
+func _I64_EqualsPtr(lhs, rhs *int64) bool {
+	if lhs != nil && rhs != nil {
+
+		x := *lhs
+		y := *rhs
+		return (x == y)
+	}
+	return lhs == nil && rhs == nil
+}
+
+// Equals returns true if all the fields of this HealthStatus match the
+// provided HealthStatus.
+//
+// This function performs a deep comparison.
+func (v *HealthStatus) Equals(rhs *HealthStatus) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+	if !(v.Ok == rhs.Ok) {
+		return false
+	}
+	if !_String_EqualsPtr(v.Msg, rhs.Msg) {
+		return false
+	}
+	if !_I64_EqualsPtr(v.LatencyInMillis, rhs.LatencyInMillis) {
+		return false
+	}
+	if !_String_EqualsPtr(v.DegradedReason, rhs.DegradedReason) {
+		return false
+	}
+	if !_I64_EqualsPtr(v.RetryAfterInMillis, rhs.RetryAfterInMillis) {
+		return false
+	}
+
+	return true
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of HealthStatus.
+func (v *HealthStatus) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	enc.AddBool("ok", v.Ok)
+	if v.Msg != nil {
+		enc.AddString("msg", *v.Msg)
+	}
+	if v.LatencyInMillis != nil {
+		enc.AddInt64("latencyInMillis", *v.LatencyInMillis)
+	}
+	if v.DegradedReason != nil {
+		enc.AddString("degradedReason", *v.DegradedReason)
+	}
+	if v.RetryAfterInMillis != nil {
+		enc.AddInt64("retryAfterInMillis", *v.RetryAfterInMillis)
+	}
+	return err
+}
+
+// GetOk returns the value of Ok if it is set or its
+// zero value if it is unset.
+func (v *HealthStatus) GetOk() (o bool) {
+	if v != nil {
+		o = v.Ok
+	}
+	return
+}
+
+// GetMsg returns the value of Msg if it is set or its
+// zero value if it is unset.
+func (v *HealthStatus) GetMsg() (o string) {
+	if v != nil && v.Msg != nil {
+		return *v.Msg
+	}
+
+	return
+}
+
+// IsSetMsg returns true if Msg is not nil.
+func (v *HealthStatus) IsSetMsg() bool {
+	return v != nil && v.Msg != nil
+}
+
+// GetLatencyInMillis returns the value of LatencyInMillis if it is set or its
+// zero value if it is unset.
+func (v *HealthStatus) GetLatencyInMillis() (o int64) {
+	if v != nil && v.LatencyInMillis != nil {
+		return *v.LatencyInMillis
+	}
+
+	return
+}
+
+// IsSetLatencyInMillis returns true if LatencyInMillis is not nil.
+func (v *HealthStatus) IsSetLatencyInMillis() bool {
+	return v != nil && v.LatencyInMillis != nil
+}
+
+// GetDegradedReason returns the value of DegradedReason if it is set or its
+// zero value if it is unset.
+func (v *HealthStatus) GetDegradedReason() (o string) {
+	if v != nil && v.DegradedReason != nil {
+		return *v.DegradedReason
+	}
+
+	return
+}
+
+// IsSetDegradedReason returns true if DegradedReason is not nil.
+func (v *HealthStatus) IsSetDegradedReason() bool {
+	return v != nil && v.DegradedReason != nil
+}
+
+// GetRetryAfterInMillis returns the value of RetryAfterInMillis if it is set or its
+// zero value if it is unset.
+func (v *HealthStatus) GetRetryAfterInMillis() (o int64) {
+	if v != nil && v.RetryAfterInMillis != nil {
+		return *v.RetryAfterInMillis
+	}
+
+	return
+}
+
+// IsSetRetryAfterInMillis returns true if RetryAfterInMillis is not nil.
+func (v *HealthStatus) IsSetRetryAfterInMillis() bool {
+	return v != nil && v.RetryAfterInMillis != nil
+}
+
+type LoadStatus struct {
+	InFlightRequests    int64    `json:"inFlightRequests,required"`
+	MaxInFlightRequests *int64   `json:"maxInFlightRequests,omitempty"`
+	Saturation          *float64 `json:"saturation,omitempty"`
+}
+
+// ToWire translates a LoadStatus struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *LoadStatus) ToWire() (wire.Value, error) {
+	var (
+		fields [3]wire.Field
+		i      int = 0
+		w      wire.Value
+		err    error
+	)
+
+	w, err = wire.NewValueI64(v.InFlightRequests), error(nil)
+	if err != nil {
+		return w, err
+	}
+	fields[i] = wire.Field{ID: 1, Value: w}
+	i++
+	if v.MaxInFlightRequests != nil {
+		w, err = wire.NewValueI64(*(v.MaxInFlightRequests)), error(nil)
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 2, Value: w}
+		i++
+	}
+	if v.Saturation != nil {
+		w, err = wire.NewValueDouble(*(v.Saturation)), error(nil)
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 3, Value: w}
+		i++
+	}
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+// FromWire deserializes a LoadStatus struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a LoadStatus struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v LoadStatus
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *LoadStatus) FromWire(w wire.Value) error {
+	var err error
+
+	inFlightRequestsIsSet := false
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		case 1:
+			if field.Value.Type() == wire.TI64 {
+				v.InFlightRequests, err = field.Value.GetI64(), error(nil)
+				if err != nil {
+					return err
+				}
+				inFlightRequestsIsSet = true
+			}
+		case 2:
+			if field.Value.Type() == wire.TI64 {
+				var x int64
+				x, err = field.Value.GetI64(), error(nil)
+				v.MaxInFlightRequests = &x
+				if err != nil {
+					return err
+				}
+
+			}
+		case 3:
+			if field.Value.Type() == wire.TDouble {
+				var x float64
+				x, err = field.Value.GetDouble(), error(nil)
+				v.Saturation = &x
+				if err != nil {
+					return err
+				}
+
+			}
+		}
+	}
+
+	if !inFlightRequestsIsSet {
+		return errors.New("field InFlightRequests of LoadStatus is required")
+	}
+
+	return nil
+}
+
+// Encode serializes a LoadStatus struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a LoadStatus struct could not be encoded.
+func (v *LoadStatus) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 1, Type: wire.TI64}); err != nil {
+		return err
+	}
+	if err := sw.WriteInt64(v.InFlightRequests); err != nil {
+		return err
+	}
+	if err := sw.WriteFieldEnd(); err != nil {
+		return err
+	}
+
+	if v.MaxInFlightRequests != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 2, Type: wire.TI64}); err != nil {
+			return err
+		}
+		if err := sw.WriteInt64(*(v.MaxInFlightRequests)); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	if v.Saturation != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 3, Type: wire.TDouble}); err != nil {
+			return err
+		}
+		if err := sw.WriteDouble(*(v.Saturation)); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return sw.WriteStructEnd()
+}
+
+// Decode deserializes a LoadStatus struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a LoadStatus struct could not be generated from the wire
+// representation.
+func (v *LoadStatus) Decode(sr stream.Reader) error {
+
+	inFlightRequestsIsSet := false
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		case fh.ID == 1 && fh.Type == wire.TI64:
+			v.InFlightRequests, err = sr.ReadInt64()
+			if err != nil {
+				return err
+			}
+			inFlightRequestsIsSet = true
+		case fh.ID == 2 && fh.Type == wire.TI64:
+			var x int64
+			x, err = sr.ReadInt64()
+			v.MaxInFlightRequests = &x
+			if err != nil {
+				return err
+			}
+
+		case fh.ID == 3 && fh.Type == wire.TDouble:
+			var x float64
+			x, err = sr.ReadDouble()
+			v.Saturation = &x
+			if err != nil {
+				return err
+			}
+
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	if !inFlightRequestsIsSet {
+		return errors.New("field InFlightRequests of LoadStatus is required")
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a LoadStatus
+// struct.
+func (v *LoadStatus) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [3]string
+	i := 0
+	fields[i] = fmt.Sprintf("InFlightRequests: %v", v.InFlightRequests)
+	i++
+	if v.MaxInFlightRequests != nil {
+		fields[i] = fmt.Sprintf("MaxInFlightRequests: %v", *(v.MaxInFlightRequests))
+		i++
+	}
+	if v.Saturation != nil {
+		fields[i] = fmt.Sprintf("Saturation: %v", *(v.Saturation))
+		i++
+	}
+
+	return fmt.Sprintf("LoadStatus{%v}", strings.Join(fields[:i], ", "))
+}
+
+func _Double_EqualsPtr(lhs, rhs *float64) bool {
+	if lhs != nil && rhs != nil {
+
+		x := *lhs
+		y := *rhs
+		return (x == y)
+	}
+	return lhs == nil && rhs == nil
+}
+
+// Equals returns true if all the fields of this LoadStatus match the
+// provided LoadStatus.
+//
+// This function performs a deep comparison.
+func (v *LoadStatus) Equals(rhs *LoadStatus) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+	if !(v.InFlightRequests == rhs.InFlightRequests) {
+		return false
+	}
+	if !_I64_EqualsPtr(v.MaxInFlightRequests, rhs.MaxInFlightRequests) {
+		return false
+	}
+	if !_Double_EqualsPtr(v.Saturation, rhs.Saturation) {
+		return false
+	}
+
+	return true
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of LoadStatus.
+func (v *LoadStatus) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	enc.AddInt64("inFlightRequests", v.InFlightRequests)
+	if v.MaxInFlightRequests != nil {
+		enc.AddInt64("maxInFlightRequests", *v.MaxInFlightRequests)
+	}
+	if v.Saturation != nil {
+		enc.AddFloat64("saturation", *v.Saturation)
+	}
+	return err
+}
+
+// GetInFlightRequests returns the value of InFlightRequests if it is set or its
+// zero value if it is unset.
+func (v *LoadStatus) GetInFlightRequests() (o int64) {
+	if v != nil {
+		o = v.InFlightRequests
+	}
+	return
+}
+
+// GetMaxInFlightRequests returns the value of MaxInFlightRequests if it is set or its
+// zero value if it is unset.
+func (v *LoadStatus) GetMaxInFlightRequests() (o int64) {
+	if v != nil && v.MaxInFlightRequests != nil {
+		return *v.MaxInFlightRequests
+	}
+
+	return
+}
+
+// IsSetMaxInFlightRequests returns true if MaxInFlightRequests is not nil.
+func (v *LoadStatus) IsSetMaxInFlightRequests() bool {
+	return v != nil && v.MaxInFlightRequests != nil
+}
+
+// GetSaturation returns the value of Saturation if it is set or its
+// zero value if it is unset.
+func (v *LoadStatus) GetSaturation() (o float64) {
+	if v != nil && v.Saturation != nil {
+		return *v.Saturation
+	}
+
+	return
+}
+
+// IsSetSaturation returns true if Saturation is not nil.
+func (v *LoadStatus) IsSetSaturation() bool {
+	return v != nil && v.Saturation != nil
+}
+
+type ShardOwnership struct {
+	Count    int32   `json:"count,required"`
+	ShardIDs []int32 `json:"shardIDs,omitempty"`
+}
+
+type _List_I32_ValueList []int32
+
+func (v _List_I32_ValueList) ForEach(f func(wire.Value) error) error {
+	for _, x := range v {
+		w, err := wire.NewValueI32(x), error(nil)
+		if err != nil {
+			return err
+		}
+		err = f(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v _List_I32_ValueList) Size() int {
+	return len(v)
+}
+
+func (_List_I32_ValueList) ValueType() wire.Type {
+	return wire.TI32
+}
+
+func (_List_I32_ValueList) Close() {}
+
+// ToWire translates a ShardOwnership struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *ShardOwnership) ToWire() (wire.Value, error) {
+	var (
+		fields [2]wire.Field
+		i      int = 0
+		w      wire.Value
+		err    error
+	)
+
+	w, err = wire.NewValueI32(v.Count), error(nil)
+	if err != nil {
+		return w, err
+	}
+	fields[i] = wire.Field{ID: 1, Value: w}
+	i++
+	if v.ShardIDs != nil {
+		w, err = wire.NewValueList(_List_I32_ValueList(v.ShardIDs)), error(nil)
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 2, Value: w}
+		i++
+	}
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+func _List_I32_Read(l wire.ValueList) ([]int32, error) {
+	if l.ValueType() != wire.TI32 {
+		return nil, nil
+	}
+
+	o := make([]int32, 0, l.Size())
+	err := l.ForEach(func(x wire.Value) error {
+		i, err := x.GetI32(), error(nil)
+		if err != nil {
+			return err
+		}
+		o = append(o, i)
+		return nil
+	})
+	l.Close()
+	return o, err
+}
+
+// FromWire deserializes a ShardOwnership struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a ShardOwnership struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v ShardOwnership
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *ShardOwnership) FromWire(w wire.Value) error {
+	var err error
+
+	countIsSet := false
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		case 1:
+			if field.Value.Type() == wire.TI32 {
+				v.Count, err = field.Value.GetI32(), error(nil)
+				if err != nil {
+					return err
+				}
+				countIsSet = true
+			}
+		case 2:
+			if field.Value.Type() == wire.TList {
+				v.ShardIDs, err = _List_I32_Read(field.Value.GetList())
+				if err != nil {
+					return err
+				}
+
+			}
+		}
+	}
+
+	if !countIsSet {
+		return errors.New("field Count of ShardOwnership is required")
+	}
+
+	return nil
+}
+
+func _List_I32_Encode(val []int32, sw stream.Writer) error {
+
+	lh := stream.ListHeader{
+		Type:   wire.TI32,
+		Length: len(val),
+	}
+	if err := sw.WriteListBegin(lh); err != nil {
+		return err
+	}
+
+	for _, v := range val {
+		if err := sw.WriteInt32(v); err != nil {
+			return err
+		}
+	}
+	return sw.WriteListEnd()
+}
+
+// Encode serializes a ShardOwnership struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a ShardOwnership struct could not be encoded.
+func (v *ShardOwnership) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 1, Type: wire.TI32}); err != nil {
+		return err
+	}
+	if err := sw.WriteInt32(v.Count); err != nil {
+		return err
+	}
+	if err := sw.WriteFieldEnd(); err != nil {
+		return err
+	}
+
+	if v.ShardIDs != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 2, Type: wire.TList}); err != nil {
+			return err
+		}
+		if err := _List_I32_Encode(v.ShardIDs, sw); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return sw.WriteStructEnd()
+}
+
+func _List_I32_Decode(sr stream.Reader) ([]int32, error) {
+	lh, err := sr.ReadListBegin()
+	if err != nil {
+		return nil, err
+	}
+
+	if lh.Type != wire.TI32 {
+		for i := 0; i < lh.Length; i++ {
+			if err := sr.Skip(lh.Type); err != nil {
+				return nil, err
+			}
+		}
+		return nil, sr.ReadListEnd()
+	}
+
+	o := make([]int32, 0, lh.Length)
+	for i := 0; i < lh.Length; i++ {
+		v, err := sr.ReadInt32()
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, v)
+	}
+
+	if err = sr.ReadListEnd(); err != nil {
+		return nil, err
+	}
+	return o, err
+}
+
+// Decode deserializes a ShardOwnership struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a ShardOwnership struct could not be generated from the wire
+// representation.
+func (v *ShardOwnership) Decode(sr stream.Reader) error {
+
+	countIsSet := false
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		case fh.ID == 1 && fh.Type == wire.TI32:
+			v.Count, err = sr.ReadInt32()
+			if err != nil {
+				return err
+			}
+			countIsSet = true
+		case fh.ID == 2 && fh.Type == wire.TList:
+			v.ShardIDs, err = _List_I32_Decode(sr)
+			if err != nil {
+				return err
+			}
+
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	if !countIsSet {
+		return errors.New("field Count of ShardOwnership is required")
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a ShardOwnership
+// struct.
+func (v *ShardOwnership) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [2]string
+	i := 0
+	fields[i] = fmt.Sprintf("Count: %v", v.Count)
+	i++
+	if v.ShardIDs != nil {
+		fields[i] = fmt.Sprintf("ShardIDs: %v", v.ShardIDs)
+		i++
+	}
+
+	return fmt.Sprintf("ShardOwnership{%v}", strings.Join(fields[:i], ", "))
+}
+
+func _List_I32_Equals(lhs, rhs []int32) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+
+	for i, lv := range lhs {
+		rv := rhs[i]
+		if !(lv == rv) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Equals returns true if all the fields of this ShardOwnership match the
+// provided ShardOwnership.
+//
+// This function performs a deep comparison.
+func (v *ShardOwnership) Equals(rhs *ShardOwnership) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+	if !(v.Count == rhs.Count) {
+		return false
+	}
+	if !((v.ShardIDs == nil && rhs.ShardIDs == nil) || (v.ShardIDs != nil && rhs.ShardIDs != nil && _List_I32_Equals(v.ShardIDs, rhs.ShardIDs))) {
+		return false
+	}
+
+	return true
+}
+
+type _List_I32_Zapper []int32
+
+// MarshalLogArray implements zapcore.ArrayMarshaler, enabling
+// fast logging of _List_I32_Zapper.
+func (l _List_I32_Zapper) MarshalLogArray(enc zapcore.ArrayEncoder) (err error) {
+	for _, v := range l {
+		enc.AppendInt32(v)
+	}
+	return err
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of ShardOwnership.
+func (v *ShardOwnership) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	enc.AddInt32("count", v.Count)
+	if v.ShardIDs != nil {
+		err = multierr.Append(err, enc.AddArray("shardIDs", (_List_I32_Zapper)(v.ShardIDs)))
+	}
+	return err
+}
+
+// GetCount returns the value of Count if it is set or its
+// zero value if it is unset.
+func (v *ShardOwnership) GetCount() (o int32) {
+	if v != nil {
+		o = v.Count
+	}
+	return
+}
+
+// GetShardIDs returns the value of ShardIDs if it is set or its
+// zero value if it is unset.
+func (v *ShardOwnership) GetShardIDs() (o []int32) {
+	if v != nil && v.ShardIDs != nil {
+		return v.ShardIDs
+	}
+
+	return
+}
+
+// IsSetShardIDs returns true if ShardIDs is not nil.
+func (v *ShardOwnership) IsSetShardIDs() bool {
+	return v != nil && v.ShardIDs != nil
+}
+
+type ShardOwnershipNotApplicableError struct {
+	Message string `json:"message,required"`
+}
+
+// ToWire translates a ShardOwnershipNotApplicableError struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *ShardOwnershipNotApplicableError) ToWire() (wire.Value, error) {
+	var (
+		fields [1]wire.Field
+		i      int = 0
+		w      wire.Value
+		err    error
+	)
+
+	w, err = wire.NewValueString(v.Message), error(nil)
+	if err != nil {
+		return w, err
+	}
+	fields[i] = wire.Field{ID: 1, Value: w}
+	i++
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+// FromWire deserializes a ShardOwnershipNotApplicableError struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a ShardOwnershipNotApplicableError struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v ShardOwnershipNotApplicableError
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *ShardOwnershipNotApplicableError) FromWire(w wire.Value) error {
+	var err error
+
+	messageIsSet := false
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		case 1:
+			if field.Value.Type() == wire.TBinary {
+				v.Message, err = field.Value.GetString(), error(nil)
+				if err != nil {
+					return err
+				}
+				messageIsSet = true
+			}
+		}
+	}
+
+	if !messageIsSet {
+		return errors.New("field Message of ShardOwnershipNotApplicableError is required")
+	}
+
+	return nil
+}
+
+// Encode serializes a ShardOwnershipNotApplicableError struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a ShardOwnershipNotApplicableError struct could not be encoded.
+func (v *ShardOwnershipNotApplicableError) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 1, Type: wire.TBinary}); err != nil {
+		return err
+	}
+	if err := sw.WriteString(v.Message); err != nil {
+		return err
+	}
+	if err := sw.WriteFieldEnd(); err != nil {
+		return err
+	}
+
+	return sw.WriteStructEnd()
+}
+
+// Decode deserializes a ShardOwnershipNotApplicableError struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a ShardOwnershipNotApplicableError struct could not be generated from the wire
+// representation.
+func (v *ShardOwnershipNotApplicableError) Decode(sr stream.Reader) error {
+
+	messageIsSet := false
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		case fh.ID == 1 && fh.Type == wire.TBinary:
+			v.Message, err = sr.ReadString()
+			if err != nil {
+				return err
+			}
+			messageIsSet = true
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	if !messageIsSet {
+		return errors.New("field Message of ShardOwnershipNotApplicableError is required")
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a ShardOwnershipNotApplicableError
+// struct.
+func (v *ShardOwnershipNotApplicableError) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [1]string
+	i := 0
+	fields[i] = fmt.Sprintf("Message: %v", v.Message)
+	i++
+
+	return fmt.Sprintf("ShardOwnershipNotApplicableError{%v}", strings.Join(fields[:i], ", "))
+}
+
+// ErrorName is the name of this type as defined in the Thrift
+// file.
+func (*ShardOwnershipNotApplicableError) ErrorName() string {
+	return "ShardOwnershipNotApplicableError"
+}
+
+// Equals returns true if all the fields of this ShardOwnershipNotApplicableError match the
+// provided ShardOwnershipNotApplicableError.
+//
+// This function performs a deep comparison.
+func (v *ShardOwnershipNotApplicableError) Equals(rhs *ShardOwnershipNotApplicableError) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+	if !(v.Message == rhs.Message) {
+		return false
+	}
+
+	return true
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of ShardOwnershipNotApplicableError.
+func (v *ShardOwnershipNotApplicableError) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	enc.AddString("message", v.Message)
+	return err
+}
+
+// GetMessage returns the value of Message if it is set or its
+// zero value if it is unset.
+func (v *ShardOwnershipNotApplicableError) GetMessage() (o string) {
+	if v != nil {
+		o = v.Message
+	}
+	return
+}
+
+func (v *ShardOwnershipNotApplicableError) Error() string {
+	return v.String()
+}
+
+// ThriftModule represents the IDL file used to generate this package.
+var ThriftModule = &thriftreflect.ThriftModule{
+	Name:     "health",
+	Package:  "github.com/uber/cadence/.gen/go/health",
+	FilePath: "health.thrift",
+	SHA1:     "cc1bbf66767bce6d99e767a3c87951ccec9e32c9",
+	Raw:      rawIDL,
+}
+
+const rawIDL = "// Copyright (c) 2017 Uber Technologies, Inc.\n//\n// Permission is hereby granted, free of charge, to any person obtaining a copy\n// of this software and associated documentation files (the \"Software\"), to deal\n// in the Software without restriction, including without limitation the rights\n// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell\n// copies of the Software, and to permit persons to whom the Software is\n// furnished to do so, subject to the following conditions:\n//\n// The above copyright notice and this permission notice shall be included in\n// all copies or substantial portions of the Software.\n//\n// THE SOFTWARE IS PROVIDED \"AS IS\", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR\n// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,\n// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE\n// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER\n// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,\n// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN\n// THE SOFTWARE.\n\nnamespace java com.uber.cadence\n\n/* ==================== Health Check ==================== */\n\nstruct HealthStatus {\n    1: required bool ok\n    2: optional string msg\n    3: optional i64 latencyInMillis\n    4: optional string degradedReason\n    5: optional i64 retryAfterInMillis\n}\n\nstruct ShardOwnership {\n    1: required i32 count\n    2: optional list<i32> shardIDs\n}\n\n// ShardOwnershipNotApplicableError is thrown by shardOwnership on hosts which do not own history shards\nexception ShardOwnershipNotApplicableError {\n    1: required string message\n}\n\nstruct LoadStatus {\n    1: required i64 inFlightRequests\n    2: optional i64 maxInFlightRequests\n    3: optional double saturation\n}\n\nservice Meta {\n    HealthStatus health()\n    ShardOwnership shardOwnership() throws (1: ShardOwnershipNotApplicableError notApplicableError)\n    LoadStatus loadStatus()\n}\n\n"
+
+// Meta_Health_Args represents the arguments for the Meta.health function.
+//
+// The arguments for health are sent and received over the wire as this struct.
+type Meta_Health_Args struct {
+}
+
+// ToWire translates a Meta_Health_Args struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *Meta_Health_Args) ToWire() (wire.Value, error) {
+	var (
+		fields [0]wire.Field
+		i      int = 0
+	)
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+// FromWire deserializes a Meta_Health_Args struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a Meta_Health_Args struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v Meta_Health_Args
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *Meta_Health_Args) FromWire(w wire.Value) error {
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		}
+	}
+
+	return nil
+}
+
+// Encode serializes a Meta_Health_Args struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a Meta_Health_Args struct could not be encoded.
+func (v *Meta_Health_Args) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	return sw.WriteStructEnd()
+}
+
+// Decode deserializes a Meta_Health_Args struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a Meta_Health_Args struct could not be generated from the wire
+// representation.
+func (v *Meta_Health_Args) Decode(sr stream.Reader) error {
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a Meta_Health_Args
+// struct.
+func (v *Meta_Health_Args) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [0]string
+	i := 0
+
+	return fmt.Sprintf("Meta_Health_Args{%v}", strings.Join(fields[:i], ", "))
+}
+
+// Equals returns true if all the fields of this Meta_Health_Args match the
+// provided Meta_Health_Args.
+//
+// This function performs a deep comparison.
+func (v *Meta_Health_Args) Equals(rhs *Meta_Health_Args) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+
+	return true
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of Meta_Health_Args.
+func (v *Meta_Health_Args) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	return err
+}
+
+// MethodName returns the name of the Thrift function as specified in
+// the IDL, for which this struct represent the arguments.
+//
+// This will always be "health" for this struct.
+func (v *Meta_Health_Args) MethodName() string {
+	return "health"
+}
+
+// EnvelopeType returns the kind of value inside this struct.
+//
+// This will always be Call for this struct.
+func (v *Meta_Health_Args) EnvelopeType() wire.EnvelopeType {
+	return wire.Call
+}
+
+// Meta_Health_Helper provides functions that aid in handling the
+// parameters and return values of the Meta.health
+// function.
+var Meta_Health_Helper = struct {
+	// Args accepts the parameters of health in-order and returns
+	// the arguments struct for the function.
+	Args func() *Meta_Health_Args
+
+	// IsException returns true if the given error can be thrown
+	// by health.
+	//
+	// An error can be thrown by health only if the
+	// corresponding exception type was mentioned in the 'throws'
+	// section for it in the Thrift file.
+	IsException func(error) bool
+
+	// WrapResponse returns the result struct for health
+	// given its return value and error.
+	//
+	// This allows mapping values and errors returned by
+	// health into a serializable result struct.
+	// WrapResponse returns a non-nil error if the provided
+	// error cannot be thrown by health
+	//
+	//   value, err := health(args)
+	//   result, err := Meta_Health_Helper.WrapResponse(value, err)
+	//   if err != nil {
+	//     return fmt.Errorf("unexpected error from health: %v", err)
+	//   }
+	//   serialize(result)
+	WrapResponse func(*HealthStatus, error) (*Meta_Health_Result, error)
+
+	// UnwrapResponse takes the result struct for health
+	// and returns the value or error returned by it.
+	//
+	// The error is non-nil only if health threw an
+	// exception.
+	//
+	//   result := deserialize(bytes)
+	//   value, err := Meta_Health_Helper.UnwrapResponse(result)
+	UnwrapResponse func(*Meta_Health_Result) (*HealthStatus, error)
+}{}
+
+func init() {
+	Meta_Health_Helper.Args = func() *Meta_Health_Args {
+		return &Meta_Health_Args{}
+	}
+
+	Meta_Health_Helper.IsException = func(err error) bool {
+		switch err.(type) {
+		default:
+			return false
+		}
+	}
+
+	Meta_Health_Helper.WrapResponse = func(success *HealthStatus, err error) (*Meta_Health_Result, error) {
+		if err == nil {
+			return &Meta_Health_Result{Success: success}, nil
+		}
+
+		return nil, err
+	}
+	Meta_Health_Helper.UnwrapResponse = func(result *Meta_Health_Result) (success *HealthStatus, err error) {
+
+		if result.Success != nil {
+			success = result.Success
+			return
+		}
+
+		err = errors.New("expected a non-void result")
+		return
+	}
+
+}
+
+// Meta_Health_Result represents the result of a Meta.health function call.
+//
+// The result of a health execution is sent and received over the wire as this struct.
+//
+// Success is set only if the function did not throw an exception.
+type Meta_Health_Result struct {
+	// Value returned by health after a successful execution.
+	Success *HealthStatus `json:"success,omitempty"`
+}
+
+// ToWire translates a Meta_Health_Result struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *Meta_Health_Result) ToWire() (wire.Value, error) {
+	var (
+		fields [1]wire.Field
+		i      int = 0
+		w      wire.Value
+		err    error
+	)
+
+	if v.Success != nil {
+		w, err = v.Success.ToWire()
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 0, Value: w}
+		i++
+	}
+
+	if i != 1 {
+		return wire.Value{}, fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", i)
+	}
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+func _HealthStatus_Read(w wire.Value) (*HealthStatus, error) {
+	var v HealthStatus
+	err := v.FromWire(w)
+	return &v, err
+}
+
+// FromWire deserializes a Meta_Health_Result struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a Meta_Health_Result struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v Meta_Health_Result
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *Meta_Health_Result) FromWire(w wire.Value) error {
+	var err error
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		case 0:
+			if field.Value.Type() == wire.TStruct {
+				v.Success, err = _HealthStatus_Read(field.Value)
+				if err != nil {
+					return err
+				}
+
+			}
+		}
+	}
+
+	count := 0
+	if v.Success != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", count)
+	}
+
+	return nil
+}
+
+// Encode serializes a Meta_Health_Result struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a Meta_Health_Result struct could not be encoded.
+func (v *Meta_Health_Result) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	if v.Success != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 0, Type: wire.TStruct}); err != nil {
+			return err
+		}
+		if err := v.Success.Encode(sw); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	count := 0
+	if v.Success != nil {
+		count++
+	}
+
+	if count != 1 {
+		return fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", count)
+	}
+
+	return sw.WriteStructEnd()
+}
+
+func _HealthStatus_Decode(sr stream.Reader) (*HealthStatus, error) {
+	var v HealthStatus
+	err := v.Decode(sr)
+	return &v, err
+}
+
+// Decode deserializes a Meta_Health_Result struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a Meta_Health_Result struct could not be generated from the wire
+// representation.
+func (v *Meta_Health_Result) Decode(sr stream.Reader) error {
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		case fh.ID == 0 && fh.Type == wire.TStruct:
+			v.Success, err = _HealthStatus_Decode(sr)
+			if err != nil {
+				return err
+			}
+
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	count := 0
+	if v.Success != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", count)
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a Meta_Health_Result
+// struct.
+func (v *Meta_Health_Result) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [1]string
+	i := 0
+	if v.Success != nil {
+		fields[i] = fmt.Sprintf("Success: %v", v.Success)
+		i++
+	}
+
+	return fmt.Sprintf("Meta_Health_Result{%v}", strings.Join(fields[:i], ", "))
+}
+
+// Equals returns true if all the fields of this Meta_Health_Result match the
+// provided Meta_Health_Result.
+//
+// This function performs a deep comparison.
+func (v *Meta_Health_Result) Equals(rhs *Meta_Health_Result) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+	if !((v.Success == nil && rhs.Success == nil) || (v.Success != nil && rhs.Success != nil && v.Success.Equals(rhs.Success))) {
+		return false
+	}
+
+	return true
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of Meta_Health_Result.
+func (v *Meta_Health_Result) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	if v.Success != nil {
+		err = multierr.Append(err, enc.AddObject("success", v.Success))
+	}
+	return err
+}
+
+// GetSuccess returns the value of Success if it is set or its
+// zero value if it is unset.
+func (v *Meta_Health_Result) GetSuccess() (o *HealthStatus) {
+	if v != nil && v.Success != nil {
+		return v.Success
+	}
+
+	return
+}
+
+// IsSetSuccess returns true if Success is not nil.
+func (v *Meta_Health_Result) IsSetSuccess() bool {
+	return v != nil && v.Success != nil
+}
+
+// MethodName returns the name of the Thrift function as specified in
+// the IDL, for which this struct represent the result.
+//
+// This will always be "health" for this struct.
+func (v *Meta_Health_Result) MethodName() string {
+	return "health"
+}
+
+// EnvelopeType returns the kind of value inside this struct.
+//
+// This will always be Reply for this struct.
+func (v *Meta_Health_Result) EnvelopeType() wire.EnvelopeType {
+	return wire.Reply
+}
+
+// Meta_LoadStatus_Args represents the arguments for the Meta.loadStatus function.
+//
+// The arguments for loadStatus are sent and received over the wire as this struct.
+type Meta_LoadStatus_Args struct {
+}
+
+// ToWire translates a Meta_LoadStatus_Args struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *Meta_LoadStatus_Args) ToWire() (wire.Value, error) {
+	var (
+		fields [0]wire.Field
+		i      int = 0
+	)
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+// FromWire deserializes a Meta_LoadStatus_Args struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a Meta_LoadStatus_Args struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v Meta_LoadStatus_Args
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *Meta_LoadStatus_Args) FromWire(w wire.Value) error {
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		}
+	}
+
+	return nil
+}
+
+// Encode serializes a Meta_LoadStatus_Args struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a Meta_LoadStatus_Args struct could not be encoded.
+func (v *Meta_LoadStatus_Args) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	return sw.WriteStructEnd()
+}
+
+// Decode deserializes a Meta_LoadStatus_Args struct directly from its Thrift-level
+// representation, without going through an intemediary type.
+//
+// An error is returned if a Meta_LoadStatus_Args struct could not be generated from the wire
+// representation.
+func (v *Meta_LoadStatus_Args) Decode(sr stream.Reader) error {
+
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	fh, ok, err := sr.ReadFieldBegin()
+	if err != nil {
+		return err
+	}
+
+	for ok {
+		switch {
+		default:
+			if err := sr.Skip(fh.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := sr.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if fh, ok, err = sr.ReadFieldBegin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sr.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// String returns a readable string representation of a Meta_LoadStatus_Args
+// struct.
+func (v *Meta_LoadStatus_Args) String() string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	var fields [0]string
+	i := 0
+
+	return fmt.Sprintf("Meta_LoadStatus_Args{%v}", strings.Join(fields[:i], ", "))
+}
+
+// Equals returns true if all the fields of this Meta_LoadStatus_Args match the
+// provided Meta_LoadStatus_Args.
+//
+// This function performs a deep comparison.
+func (v *Meta_LoadStatus_Args) Equals(rhs *Meta_LoadStatus_Args) bool {
+	if v == nil {
+		return rhs == nil
+	} else if rhs == nil {
+		return false
+	}
+
+	return true
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, enabling
+// fast logging of Meta_LoadStatus_Args.
+func (v *Meta_LoadStatus_Args) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	if v == nil {
+		return nil
+	}
+	return err
+}
+
+// MethodName returns the name of the Thrift function as specified in
+// the IDL, for which this struct represent the arguments.
+//
+// This will always be "loadStatus" for this struct.
+func (v *Meta_LoadStatus_Args) MethodName() string {
+	return "loadStatus"
+}
+
+// EnvelopeType returns the kind of value inside this struct.
+//
+// This will always be Call for this struct.
+func (v *Meta_LoadStatus_Args) EnvelopeType() wire.EnvelopeType {
+	return wire.Call
+}
+
+// Meta_LoadStatus_Helper provides functions that aid in handling the
+// parameters and return values of the Meta.loadStatus
+// function.
+var Meta_LoadStatus_Helper = struct {
+	// Args accepts the parameters of loadStatus in-order and returns
+	// the arguments struct for the function.
+	Args func() *Meta_LoadStatus_Args
+
+	// IsException returns true if the given error can be thrown
+	// by loadStatus.
+	//
+	// An error can be thrown by loadStatus only if the
+	// corresponding exception type was mentioned in the 'throws'
+	// section for it in the Thrift file.
+	IsException func(error) bool
+
+	// WrapResponse returns the result struct for loadStatus
+	// given its return value and error.
+	//
+	// This allows mapping values and errors returned by
+	// loadStatus into a serializable result struct.
+	// WrapResponse returns a non-nil error if the provided
+	// error cannot be thrown by loadStatus
+	//
+	//   value, err := loadStatus(args)
+	//   result, err := Meta_LoadStatus_Helper.WrapResponse(value, err)
+	//   if err != nil {
+	//     return fmt.Errorf("unexpected error from loadStatus: %v", err)
+	//   }
+	//   serialize(result)
+	WrapResponse func(*LoadStatus, error) (*Meta_LoadStatus_Result, error)
+
+	// UnwrapResponse takes the result struct for loadStatus
+	// and returns the value or error returned by it.
+	//
+	// The error is non-nil only if loadStatus threw an
+	// exception.
+	//
+	//   result := deserialize(bytes)
+	//   value, err := Meta_LoadStatus_Helper.UnwrapResponse(result)
+	UnwrapResponse func(*Meta_LoadStatus_Result) (*LoadStatus, error)
+}{}
+
+func init() {
+	Meta_LoadStatus_Helper.Args = func() *Meta_LoadStatus_Args {
+		return &Meta_LoadStatus_Args{}
+	}
+
+	Meta_LoadStatus_Helper.IsException = func(err error) bool {
+		switch err.(type) {
+		default:
+			return false
+		}
+	}
+
+	Meta_LoadStatus_Helper.WrapResponse = func(success *LoadStatus, err error) (*Meta_LoadStatus_Result, error) {
+		if err == nil {
+			return &Meta_LoadStatus_Result{Success: success}, nil
+		}
+
+		return nil, err
+	}
+	Meta_LoadStatus_Helper.UnwrapResponse = func(result *Meta_LoadStatus_Result) (success *LoadStatus, err error) {
+
+		if result.Success != nil {
+			success = result.Success
+			return
+		}
+
+		err = errors.New("expected a non-void result")
+		return
+	}
+
+}
+
+// Meta_LoadStatus_Result represents the result of a Meta.loadStatus function call.
+//
+// The result of a loadStatus execution is sent and received over the wire as this struct.
+//
+// Success is set only if the function did not throw an exception.
+type Meta_LoadStatus_Result struct {
+	// Value returned by loadStatus after a successful execution.
+	Success *LoadStatus `json:"success,omitempty"`
+}
+
+// ToWire translates a Meta_LoadStatus_Result struct into a Thrift-level intermediate
+// representation. This intermediate representation may be serialized
+// into bytes using a ThriftRW protocol implementation.
+//
+// An error is returned if the struct or any of its fields failed to
+// validate.
+//
+//	x, err := v.ToWire()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	if err := binaryProtocol.Encode(x, writer); err != nil {
+//	  return err
+//	}
+func (v *Meta_LoadStatus_Result) ToWire() (wire.Value, error) {
+	var (
+		fields [1]wire.Field
+		i      int = 0
+		w      wire.Value
+		err    error
+	)
+
+	if v.Success != nil {
+		w, err = v.Success.ToWire()
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 0, Value: w}
+		i++
+	}
+
+	if i != 1 {
+		return wire.Value{}, fmt.Errorf("Meta_LoadStatus_Result should have exactly one field: got %v fields", i)
+	}
+
+	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
+}
+
+func _LoadStatus_Read(w wire.Value) (*LoadStatus, error) {
+	var v LoadStatus
+	err := v.FromWire(w)
+	return &v, err
+}
+
+// FromWire deserializes a Meta_LoadStatus_Result struct from its Thrift-level
+// representation. The Thrift-level representation may be obtained
+// from a ThriftRW protocol implementation.
+//
+// An error is returned if we were unable to build a Meta_LoadStatus_Result struct
+// from the provided intermediate representation.
+//
+//	x, err := binaryProtocol.Decode(reader, wire.TStruct)
+//	if err != nil {
+//	  return nil, err
+//	}
+//
+//	var v Meta_LoadStatus_Result
+//	if err := v.FromWire(x); err != nil {
+//	  return nil, err
+//	}
+//	return &v, nil
+func (v *Meta_LoadStatus_Result) FromWire(w wire.Value) error {
+	var err error
+
+	for _, field := range w.GetStruct().Fields {
+		switch field.ID {
+		case 0:
+			if field.Value.Type() == wire.TStruct {
+				v.Success, err = _LoadStatus_Read(field.Value)
+				if err != nil {
+					return err
+				}
+
+			}
+		}
+	}
+
+	count := 0
+	if v.Success != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("Meta_LoadStatus_Result should have exactly one field: got %v fields", count)
+	}
+
+	return nil
+}
+
+// Encode serializes a Meta_LoadStatus_Result struct directly into bytes, without going
+// through an intermediary type.
+//
+// An error is returned if a Meta_LoadStatus_Result struct could not be encoded.
+func (v *Meta_LoadStatus_Result) Encode(sw stream.Writer) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+
+	if v.Success != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 0, Type: wire.TStruct}); err != nil {
+			return err
+		}
+		if err := v.Success.Encode(sw); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	count := 0
+	if v.Success != nil {
+		count++
+	}
+
+	if count != 1 {
+		return fmt.Errorf("Meta_LoadStatus_Result should have exactly one field: got %v fields", count)
+	}
+
 	return sw.WriteStructEnd()
 }
 
-// Decode deserializes a HealthStatus struct directly from its Thrift-level
+func _LoadStatus_Decode(sr stream.Reader) (*LoadStatus, error) {
+	var v LoadStatus
+	err := v.Decode(sr)
+	return &v, err
+}
+
+// Decode deserializes a Meta_LoadStatus_Result struct directly from its Thrift-level
 // representation, without going through an intemediary type.
 //
-// An error is returned if a HealthStatus struct could not be generated from the wire
+// An error is returned if a Meta_LoadStatus_Result struct could not be generated from the wire
 // representation.
-func (v *HealthStatus) Decode(sr stream.Reader) error {
-
-	okIsSet := false
+func (v *Meta_LoadStatus_Result) Decode(sr stream.Reader) error {
 
 	if err := sr.ReadStructBegin(); err != nil {
 		return err
@@ -189,16 +2360,8 @@ func (v *HealthStatus) Decode(sr stream.Reader) error {
 
 	for ok {
 		switch {
-		case fh.ID == 1 && fh.Type == wire.TBool:
-			v.Ok, err = sr.ReadBool()
-			if err != nil {
-				return err
-			}
-			okIsSet = true
-		case fh.ID == 2 && fh.Type == wire.TBinary:
-			var x string
-			x, err = sr.ReadString()
-			v.Msg = &x
+		case fh.ID == 0 && fh.Type == wire.TStruct:
+			v.Success, err = _LoadStatus_Decode(sr)
 			if err != nil {
 				return err
 			}
@@ -222,56 +2385,45 @@ func (v *HealthStatus) Decode(sr stream.Reader) error {
 		return err
 	}
 
-	if !okIsSet {
-		return errors.New("field Ok of HealthStatus is required")
+	count := 0
+	if v.Success != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("Meta_LoadStatus_Result should have exactly one field: got %v fields", count)
 	}
 
 	return nil
 }
 
-// String returns a readable string representation of a HealthStatus
+// String returns a readable string representation of a Meta_LoadStatus_Result
 // struct.
-func (v *HealthStatus) String() string {
+func (v *Meta_LoadStatus_Result) String() string {
 	if v == nil {
 		return "<nil>"
 	}
 
-	var fields [2]string
+	var fields [1]string
 	i := 0
-	fields[i] = fmt.Sprintf("Ok: %v", v.Ok)
-	i++
-	if v.Msg != nil {
-		fields[i] = fmt.Sprintf("Msg: %v", *(v.Msg))
+	if v.Success != nil {
+		fields[i] = fmt.Sprintf("Success: %v", v.Success)
 		i++
 	}
 
-	return fmt.Sprintf("HealthStatus{%v}", strings.Join(fields[:i], ", "))
-}
-
-func _String_EqualsPtr(lhs, rhs *string) bool {
-	if lhs != nil && rhs != nil {
-
-		x := *lhs
-		y := *rhs
-		return (x == y)
-	}
-	return lhs == nil && rhs == nil
+	return fmt.Sprintf("Meta_LoadStatus_Result{%v}", strings.Join(fields[:i], ", "))
 }
 
-// Equals returns true if all the fields of this HealthStatus match the
-// provided HealthStatus.
+// Equals returns true if all the fields of this Meta_LoadStatus_Result match the
+// provided Meta_LoadStatus_Result.
 //
 // This function performs a deep comparison.
-func (v *HealthStatus) Equals(rhs *HealthStatus) bool {
+func (v *Meta_LoadStatus_Result) Equals(rhs *Meta_LoadStatus_Result) bool {
 	if v == nil {
 		return rhs == nil
 	} else if rhs == nil {
 		return false
 	}
-	if !(v.Ok == rhs.Ok) {
-		return false
-	}
-	if !_String_EqualsPtr(v.Msg, rhs.Msg) {
+	if !((v.Success == nil && rhs.Success == nil) || (v.Success != nil && rhs.Success != nil && v.Success.Equals(rhs.Success))) {
 		return false
 	}
 
@@ -279,60 +2431,54 @@ func (v *HealthStatus) Equals(rhs *HealthStatus) bool {
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaler, enabling
-// fast logging of HealthStatus.
-func (v *HealthStatus) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+// fast logging of Meta_LoadStatus_Result.
+func (v *Meta_LoadStatus_Result) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
 	if v == nil {
 		return nil
 	}
-	enc.AddBool("ok", v.Ok)
-	if v.Msg != nil {
-		enc.AddString("msg", *v.Msg)
+	if v.Success != nil {
+		err = multierr.Append(err, enc.AddObject("success", v.Success))
 	}
 	return err
 }
 
-// GetOk returns the value of Ok if it is set or its
-// zero value if it is unset.
-func (v *HealthStatus) GetOk() (o bool) {
-	if v != nil {
-		o = v.Ok
-	}
-	return
-}
-
-// GetMsg returns the value of Msg if it is set or its
+// GetSuccess returns the value of Success if it is set or its
 // zero value if it is unset.
-func (v *HealthStatus) GetMsg() (o string) {
-	if v != nil && v.Msg != nil {
-		return *v.Msg
+func (v *Meta_LoadStatus_Result) GetSuccess() (o *LoadStatus) {
+	if v != nil && v.Success != nil {
+		return v.Success
 	}
 
 	return
 }
 
-// IsSetMsg returns true if Msg is not nil.
-func (v *HealthStatus) IsSetMsg() bool {
-	return v != nil && v.Msg != nil
+// IsSetSuccess returns true if Success is not nil.
+func (v *Meta_LoadStatus_Result) IsSetSuccess() bool {
+	return v != nil && v.Success != nil
 }
 
-// ThriftModule represents the IDL file used to generate this package.
-var ThriftModule = &thriftreflect.ThriftModule{
-	Name:     "health",
-	Package:  "github.com/uber/cadence/.gen/go/health",
-	FilePath: "health.thrift",
-	SHA1:     "8d52f05c157e47bef27c86d2133e1cdb475f8024",
-	Raw:      rawIDL,
+// MethodName returns the name of the Thrift function as specified in
+// the IDL, for which this struct represent the result.
+//
+// This will always be "loadStatus" for this struct.
+func (v *Meta_LoadStatus_Result) MethodName() string {
+	return "loadStatus"
 }
 
-const rawIDL = "// Copyright (c) 2017 Uber Technologies, Inc.\n//\n// Permission is hereby granted, free of charge, to any person obtaining a copy\n// of this software and associated documentation files (the \"Software\"), to deal\n// in the Software without restriction, including without limitation the rights\n// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell\n// copies of the Software, and to permit persons to whom the Software is\n// furnished to do so, subject to the following conditions:\n//\n// The above copyright notice and this permission notice shall be included in\n// all copies or substantial portions of the Software.\n//\n// THE SOFTWARE IS PROVIDED \"AS IS\", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR\n// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,\n// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE\n// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER\n// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,\n// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN\n// THE SOFTWARE.\n\nnamespace java com.uber.cadence\n\n/* ==================== Health Check ==================== */\n\nstruct HealthStatus {\n    1: required bool ok\n    2: optional string msg\n}\n\nservice Meta {\n    HealthStatus health()\n}\n\n"
+// EnvelopeType returns the kind of value inside this struct.
+//
+// This will always be Reply for this struct.
+func (v *Meta_LoadStatus_Result) EnvelopeType() wire.EnvelopeType {
+	return wire.Reply
+}
 
-// Meta_Health_Args represents the arguments for the Meta.health function.
+// Meta_ShardOwnership_Args represents the arguments for the Meta.shardOwnership function.
 //
-// The arguments for health are sent and received over the wire as this struct.
-type Meta_Health_Args struct {
+// The arguments for shardOwnership are sent and received over the wire as this struct.
+type Meta_ShardOwnership_Args struct {
 }
 
-// ToWire translates a Meta_Health_Args struct into a Thrift-level intermediate
+// ToWire translates a Meta_ShardOwnership_Args struct into a Thrift-level intermediate
 // representation. This intermediate representation may be serialized
 // into bytes using a ThriftRW protocol implementation.
 //
@@ -347,7 +2493,7 @@ type Meta_Health_Args struct {
 //	if err := binaryProtocol.Encode(x, writer); err != nil {
 //	  return err
 //	}
-func (v *Meta_Health_Args) ToWire() (wire.Value, error) {
+func (v *Meta_ShardOwnership_Args) ToWire() (wire.Value, error) {
 	var (
 		fields [0]wire.Field
 		i      int = 0
@@ -356,11 +2502,11 @@ func (v *Meta_Health_Args) ToWire() (wire.Value, error) {
 	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
 }
 
-// FromWire deserializes a Meta_Health_Args struct from its Thrift-level
+// FromWire deserializes a Meta_ShardOwnership_Args struct from its Thrift-level
 // representation. The Thrift-level representation may be obtained
 // from a ThriftRW protocol implementation.
 //
-// An error is returned if we were unable to build a Meta_Health_Args struct
+// An error is returned if we were unable to build a Meta_ShardOwnership_Args struct
 // from the provided intermediate representation.
 //
 //	x, err := binaryProtocol.Decode(reader, wire.TStruct)
@@ -368,12 +2514,12 @@ func (v *Meta_Health_Args) ToWire() (wire.Value, error) {
 //	  return nil, err
 //	}
 //
-//	var v Meta_Health_Args
+//	var v Meta_ShardOwnership_Args
 //	if err := v.FromWire(x); err != nil {
 //	  return nil, err
 //	}
 //	return &v, nil
-func (v *Meta_Health_Args) FromWire(w wire.Value) error {
+func (v *Meta_ShardOwnership_Args) FromWire(w wire.Value) error {
 
 	for _, field := range w.GetStruct().Fields {
 		switch field.ID {
@@ -383,11 +2529,11 @@ func (v *Meta_Health_Args) FromWire(w wire.Value) error {
 	return nil
 }
 
-// Encode serializes a Meta_Health_Args struct directly into bytes, without going
+// Encode serializes a Meta_ShardOwnership_Args struct directly into bytes, without going
 // through an intermediary type.
 //
-// An error is returned if a Meta_Health_Args struct could not be encoded.
-func (v *Meta_Health_Args) Encode(sw stream.Writer) error {
+// An error is returned if a Meta_ShardOwnership_Args struct could not be encoded.
+func (v *Meta_ShardOwnership_Args) Encode(sw stream.Writer) error {
 	if err := sw.WriteStructBegin(); err != nil {
 		return err
 	}
@@ -395,12 +2541,12 @@ func (v *Meta_Health_Args) Encode(sw stream.Writer) error {
 	return sw.WriteStructEnd()
 }
 
-// Decode deserializes a Meta_Health_Args struct directly from its Thrift-level
+// Decode deserializes a Meta_ShardOwnership_Args struct directly from its Thrift-level
 // representation, without going through an intemediary type.
 //
-// An error is returned if a Meta_Health_Args struct could not be generated from the wire
+// An error is returned if a Meta_ShardOwnership_Args struct could not be generated from the wire
 // representation.
-func (v *Meta_Health_Args) Decode(sr stream.Reader) error {
+func (v *Meta_ShardOwnership_Args) Decode(sr stream.Reader) error {
 
 	if err := sr.ReadStructBegin(); err != nil {
 		return err
@@ -435,9 +2581,9 @@ func (v *Meta_Health_Args) Decode(sr stream.Reader) error {
 	return nil
 }
 
-// String returns a readable string representation of a Meta_Health_Args
+// String returns a readable string representation of a Meta_ShardOwnership_Args
 // struct.
-func (v *Meta_Health_Args) String() string {
+func (v *Meta_ShardOwnership_Args) String() string {
 	if v == nil {
 		return "<nil>"
 	}
@@ -445,14 +2591,14 @@ func (v *Meta_Health_Args) String() string {
 	var fields [0]string
 	i := 0
 
-	return fmt.Sprintf("Meta_Health_Args{%v}", strings.Join(fields[:i], ", "))
+	return fmt.Sprintf("Meta_ShardOwnership_Args{%v}", strings.Join(fields[:i], ", "))
 }
 
-// Equals returns true if all the fields of this Meta_Health_Args match the
-// provided Meta_Health_Args.
+// Equals returns true if all the fields of this Meta_ShardOwnership_Args match the
+// provided Meta_ShardOwnership_Args.
 //
 // This function performs a deep comparison.
-func (v *Meta_Health_Args) Equals(rhs *Meta_Health_Args) bool {
+func (v *Meta_ShardOwnership_Args) Equals(rhs *Meta_ShardOwnership_Args) bool {
 	if v == nil {
 		return rhs == nil
 	} else if rhs == nil {
@@ -463,8 +2609,8 @@ func (v *Meta_Health_Args) Equals(rhs *Meta_Health_Args) bool {
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaler, enabling
-// fast logging of Meta_Health_Args.
-func (v *Meta_Health_Args) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+// fast logging of Meta_ShardOwnership_Args.
+func (v *Meta_ShardOwnership_Args) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
 	if v == nil {
 		return nil
 	}
@@ -474,81 +2620,95 @@ func (v *Meta_Health_Args) MarshalLogObject(enc zapcore.ObjectEncoder) (err erro
 // MethodName returns the name of the Thrift function as specified in
 // the IDL, for which this struct represent the arguments.
 //
-// This will always be "health" for this struct.
-func (v *Meta_Health_Args) MethodName() string {
-	return "health"
+// This will always be "shardOwnership" for this struct.
+func (v *Meta_ShardOwnership_Args) MethodName() string {
+	return "shardOwnership"
 }
 
 // EnvelopeType returns the kind of value inside this struct.
 //
 // This will always be Call for this struct.
-func (v *Meta_Health_Args) EnvelopeType() wire.EnvelopeType {
+func (v *Meta_ShardOwnership_Args) EnvelopeType() wire.EnvelopeType {
 	return wire.Call
 }
 
-// Meta_Health_Helper provides functions that aid in handling the
-// parameters and return values of the Meta.health
+// Meta_ShardOwnership_Helper provides functions that aid in handling the
+// parameters and return values of the Meta.shardOwnership
 // function.
-var Meta_Health_Helper = struct {
-	// Args accepts the parameters of health in-order and returns
+var Meta_ShardOwnership_Helper = struct {
+	// Args accepts the parameters of shardOwnership in-order and returns
 	// the arguments struct for the function.
-	Args func() *Meta_Health_Args
+	Args func() *Meta_ShardOwnership_Args
 
 	// IsException returns true if the given error can be thrown
-	// by health.
+	// by shardOwnership.
 	//
-	// An error can be thrown by health only if the
+	// An error can be thrown by shardOwnership only if the
 	// corresponding exception type was mentioned in the 'throws'
 	// section for it in the Thrift file.
 	IsException func(error) bool
 
-	// WrapResponse returns the result struct for health
+	// WrapResponse returns the result struct for shardOwnership
 	// given its return value and error.
 	//
 	// This allows mapping values and errors returned by
-	// health into a serializable result struct.
+	// shardOwnership into a serializable result struct.
 	// WrapResponse returns a non-nil error if the provided
-	// error cannot be thrown by health
+	// error cannot be thrown by shardOwnership
 	//
-	//   value, err := health(args)
-	//   result, err := Meta_Health_Helper.WrapResponse(value, err)
+	//   value, err := shardOwnership(args)
+	//   result, err := Meta_ShardOwnership_Helper.WrapResponse(value, err)
 	//   if err != nil {
-	//     return fmt.Errorf("unexpected error from health: %v", err)
+	//     return fmt.Errorf("unexpected error from shardOwnership: %v", err)
 	//   }
 	//   serialize(result)
-	WrapResponse func(*HealthStatus, error) (*Meta_Health_Result, error)
+	WrapResponse func(*ShardOwnership, error) (*Meta_ShardOwnership_Result, error)
 
-	// UnwrapResponse takes the result struct for health
+	// UnwrapResponse takes the result struct for shardOwnership
 	// and returns the value or error returned by it.
 	//
-	// The error is non-nil only if health threw an
+	// The error is non-nil only if shardOwnership threw an
 	// exception.
 	//
 	//   result := deserialize(bytes)
-	//   value, err := Meta_Health_Helper.UnwrapResponse(result)
-	UnwrapResponse func(*Meta_Health_Result) (*HealthStatus, error)
+	//   value, err := Meta_ShardOwnership_Helper.UnwrapResponse(result)
+	UnwrapResponse func(*Meta_ShardOwnership_Result) (*ShardOwnership, error)
 }{}
 
 func init() {
-	Meta_Health_Helper.Args = func() *Meta_Health_Args {
-		return &Meta_Health_Args{}
+	Meta_ShardOwnership_Helper.Args = func() *Meta_ShardOwnership_Args {
+		return &Meta_ShardOwnership_Args{}
 	}
 
-	Meta_Health_Helper.IsException = func(err error) bool {
+	Meta_ShardOwnership_Helper.IsException = func(err error) bool {
 		switch err.(type) {
+		case *ShardOwnershipNotApplicableError:
+			return true
 		default:
 			return false
 		}
 	}
 
-	Meta_Health_Helper.WrapResponse = func(success *HealthStatus, err error) (*Meta_Health_Result, error) {
+	Meta_ShardOwnership_Helper.WrapResponse = func(success *ShardOwnership, err error) (*Meta_ShardOwnership_Result, error) {
 		if err == nil {
-			return &Meta_Health_Result{Success: success}, nil
+			return &Meta_ShardOwnership_Result{Success: success}, nil
+		}
+
+		switch e := err.(type) {
+		case *ShardOwnershipNotApplicableError:
+			if e == nil {
+				return nil, errors.New("WrapResponse received non-nil error type with nil value for Meta_ShardOwnership_Result.NotApplicableError")
+			}
+			return &Meta_ShardOwnership_Result{NotApplicableError: e}, nil
 		}
 
 		return nil, err
 	}
-	Meta_Health_Helper.UnwrapResponse = func(result *Meta_Health_Result) (success *HealthStatus, err error) {
+	Meta_ShardOwnership_Helper.UnwrapResponse = func(result *Meta_ShardOwnership_Result) (success *ShardOwnership, err error) {
+		if result.NotApplicableError != nil {
+			err = result.NotApplicableError
+			return
+		}
 
 		if result.Success != nil {
 			success = result.Success
@@ -561,17 +2721,18 @@ func init() {
 
 }
 
-// Meta_Health_Result represents the result of a Meta.health function call.
+// Meta_ShardOwnership_Result represents the result of a Meta.shardOwnership function call.
 //
-// The result of a health execution is sent and received over the wire as this struct.
+// The result of a shardOwnership execution is sent and received over the wire as this struct.
 //
 // Success is set only if the function did not throw an exception.
-type Meta_Health_Result struct {
-	// Value returned by health after a successful execution.
-	Success *HealthStatus `json:"success,omitempty"`
+type Meta_ShardOwnership_Result struct {
+	// Value returned by shardOwnership after a successful execution.
+	Success            *ShardOwnership                   `json:"success,omitempty"`
+	NotApplicableError *ShardOwnershipNotApplicableError `json:"notApplicableError,omitempty"`
 }
 
-// ToWire translates a Meta_Health_Result struct into a Thrift-level intermediate
+// ToWire translates a Meta_ShardOwnership_Result struct into a Thrift-level intermediate
 // representation. This intermediate representation may be serialized
 // into bytes using a ThriftRW protocol implementation.
 //
@@ -586,9 +2747,9 @@ type Meta_Health_Result struct {
 //	if err := binaryProtocol.Encode(x, writer); err != nil {
 //	  return err
 //	}
-func (v *Meta_Health_Result) ToWire() (wire.Value, error) {
+func (v *Meta_ShardOwnership_Result) ToWire() (wire.Value, error) {
 	var (
-		fields [1]wire.Field
+		fields [2]wire.Field
 		i      int = 0
 		w      wire.Value
 		err    error
@@ -602,25 +2763,39 @@ func (v *Meta_Health_Result) ToWire() (wire.Value, error) {
 		fields[i] = wire.Field{ID: 0, Value: w}
 		i++
 	}
+	if v.NotApplicableError != nil {
+		w, err = v.NotApplicableError.ToWire()
+		if err != nil {
+			return w, err
+		}
+		fields[i] = wire.Field{ID: 1, Value: w}
+		i++
+	}
 
 	if i != 1 {
-		return wire.Value{}, fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", i)
+		return wire.Value{}, fmt.Errorf("Meta_ShardOwnership_Result should have exactly one field: got %v fields", i)
 	}
 
 	return wire.NewValueStruct(wire.Struct{Fields: fields[:i]}), nil
 }
 
-func _HealthStatus_Read(w wire.Value) (*HealthStatus, error) {
-	var v HealthStatus
+func _ShardOwnership_Read(w wire.Value) (*ShardOwnership, error) {
+	var v ShardOwnership
 	err := v.FromWire(w)
 	return &v, err
 }
 
-// FromWire deserializes a Meta_Health_Result struct from its Thrift-level
+func _ShardOwnershipNotApplicableError_Read(w wire.Value) (*ShardOwnershipNotApplicableError, error) {
+	var v ShardOwnershipNotApplicableError
+	err := v.FromWire(w)
+	return &v, err
+}
+
+// FromWire deserializes a Meta_ShardOwnership_Result struct from its Thrift-level
 // representation. The Thrift-level representation may be obtained
 // from a ThriftRW protocol implementation.
 //
-// An error is returned if we were unable to build a Meta_Health_Result struct
+// An error is returned if we were unable to build a Meta_ShardOwnership_Result struct
 // from the provided intermediate representation.
 //
 //	x, err := binaryProtocol.Decode(reader, wire.TStruct)
@@ -628,19 +2803,27 @@ func _HealthStatus_Read(w wire.Value) (*HealthStatus, error) {
 //	  return nil, err
 //	}
 //
-//	var v Meta_Health_Result
+//	var v Meta_ShardOwnership_Result
 //	if err := v.FromWire(x); err != nil {
 //	  return nil, err
 //	}
 //	return &v, nil
-func (v *Meta_Health_Result) FromWire(w wire.Value) error {
+func (v *Meta_ShardOwnership_Result) FromWire(w wire.Value) error {
 	var err error
 
 	for _, field := range w.GetStruct().Fields {
 		switch field.ID {
 		case 0:
 			if field.Value.Type() == wire.TStruct {
-				v.Success, err = _HealthStatus_Read(field.Value)
+				v.Success, err = _ShardOwnership_Read(field.Value)
+				if err != nil {
+					return err
+				}
+
+			}
+		case 1:
+			if field.Value.Type() == wire.TStruct {
+				v.NotApplicableError, err = _ShardOwnershipNotApplicableError_Read(field.Value)
 				if err != nil {
 					return err
 				}
@@ -653,18 +2836,21 @@ func (v *Meta_Health_Result) FromWire(w wire.Value) error {
 	if v.Success != nil {
 		count++
 	}
+	if v.NotApplicableError != nil {
+		count++
+	}
 	if count != 1 {
-		return fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", count)
+		return fmt.Errorf("Meta_ShardOwnership_Result should have exactly one field: got %v fields", count)
 	}
 
 	return nil
 }
 
-// Encode serializes a Meta_Health_Result struct directly into bytes, without going
+// Encode serializes a Meta_ShardOwnership_Result struct directly into bytes, without going
 // through an intermediary type.
 //
-// An error is returned if a Meta_Health_Result struct could not be encoded.
-func (v *Meta_Health_Result) Encode(sw stream.Writer) error {
+// An error is returned if a Meta_ShardOwnership_Result struct could not be encoded.
+func (v *Meta_ShardOwnership_Result) Encode(sw stream.Writer) error {
 	if err := sw.WriteStructBegin(); err != nil {
 		return err
 	}
@@ -681,30 +2867,51 @@ func (v *Meta_Health_Result) Encode(sw stream.Writer) error {
 		}
 	}
 
+	if v.NotApplicableError != nil {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: 1, Type: wire.TStruct}); err != nil {
+			return err
+		}
+		if err := v.NotApplicableError.Encode(sw); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
 	count := 0
 	if v.Success != nil {
 		count++
 	}
+	if v.NotApplicableError != nil {
+		count++
+	}
 
 	if count != 1 {
-		return fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", count)
+		return fmt.Errorf("Meta_ShardOwnership_Result should have exactly one field: got %v fields", count)
 	}
 
 	return sw.WriteStructEnd()
 }
 
-func _HealthStatus_Decode(sr stream.Reader) (*HealthStatus, error) {
-	var v HealthStatus
+func _ShardOwnership_Decode(sr stream.Reader) (*ShardOwnership, error) {
+	var v ShardOwnership
 	err := v.Decode(sr)
 	return &v, err
 }
 
-// Decode deserializes a Meta_Health_Result struct directly from its Thrift-level
+func _ShardOwnershipNotApplicableError_Decode(sr stream.Reader) (*ShardOwnershipNotApplicableError, error) {
+	var v ShardOwnershipNotApplicableError
+	err := v.Decode(sr)
+	return &v, err
+}
+
+// Decode deserializes a Meta_ShardOwnership_Result struct directly from its Thrift-level
 // representation, without going through an intemediary type.
 //
-// An error is returned if a Meta_Health_Result struct could not be generated from the wire
+// An error is returned if a Meta_ShardOwnership_Result struct could not be generated from the wire
 // representation.
-func (v *Meta_Health_Result) Decode(sr stream.Reader) error {
+func (v *Meta_ShardOwnership_Result) Decode(sr stream.Reader) error {
 
 	if err := sr.ReadStructBegin(); err != nil {
 		return err
@@ -718,7 +2925,13 @@ func (v *Meta_Health_Result) Decode(sr stream.Reader) error {
 	for ok {
 		switch {
 		case fh.ID == 0 && fh.Type == wire.TStruct:
-			v.Success, err = _HealthStatus_Decode(sr)
+			v.Success, err = _ShardOwnership_Decode(sr)
+			if err != nil {
+				return err
+			}
+
+		case fh.ID == 1 && fh.Type == wire.TStruct:
+			v.NotApplicableError, err = _ShardOwnershipNotApplicableError_Decode(sr)
 			if err != nil {
 				return err
 			}
@@ -746,35 +2959,42 @@ func (v *Meta_Health_Result) Decode(sr stream.Reader) error {
 	if v.Success != nil {
 		count++
 	}
+	if v.NotApplicableError != nil {
+		count++
+	}
 	if count != 1 {
-		return fmt.Errorf("Meta_Health_Result should have exactly one field: got %v fields", count)
+		return fmt.Errorf("Meta_ShardOwnership_Result should have exactly one field: got %v fields", count)
 	}
 
 	return nil
 }
 
-// String returns a readable string representation of a Meta_Health_Result
+// String returns a readable string representation of a Meta_ShardOwnership_Result
 // struct.
-func (v *Meta_Health_Result) String() string {
+func (v *Meta_ShardOwnership_Result) String() string {
 	if v == nil {
 		return "<nil>"
 	}
 
-	var fields [1]string
+	var fields [2]string
 	i := 0
 	if v.Success != nil {
 		fields[i] = fmt.Sprintf("Success: %v", v.Success)
 		i++
 	}
+	if v.NotApplicableError != nil {
+		fields[i] = fmt.Sprintf("NotApplicableError: %v", v.NotApplicableError)
+		i++
+	}
 
-	return fmt.Sprintf("Meta_Health_Result{%v}", strings.Join(fields[:i], ", "))
+	return fmt.Sprintf("Meta_ShardOwnership_Result{%v}", strings.Join(fields[:i], ", "))
 }
 
-// Equals returns true if all the fields of this Meta_Health_Result match the
-// provided Meta_Health_Result.
+// Equals returns true if all the fields of this Meta_ShardOwnership_Result match the
+// provided Meta_ShardOwnership_Result.
 //
 // This function performs a deep comparison.
-func (v *Meta_Health_Result) Equals(rhs *Meta_Health_Result) bool {
+func (v *Meta_ShardOwnership_Result) Equals(rhs *Meta_ShardOwnership_Result) bool {
 	if v == nil {
 		return rhs == nil
 	} else if rhs == nil {
@@ -783,25 +3003,31 @@ func (v *Meta_Health_Result) Equals(rhs *Meta_Health_Result) bool {
 	if !((v.Success == nil && rhs.Success == nil) || (v.Success != nil && rhs.Success != nil && v.Success.Equals(rhs.Success))) {
 		return false
 	}
+	if !((v.NotApplicableError == nil && rhs.NotApplicableError == nil) || (v.NotApplicableError != nil && rhs.NotApplicableError != nil && v.NotApplicableError.Equals(rhs.NotApplicableError))) {
+		return false
+	}
 
 	return true
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaler, enabling
-// fast logging of Meta_Health_Result.
-func (v *Meta_Health_Result) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+// fast logging of Meta_ShardOwnership_Result.
+func (v *Meta_ShardOwnership_Result) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
 	if v == nil {
 		return nil
 	}
 	if v.Success != nil {
 		err = multierr.Append(err, enc.AddObject("success", v.Success))
 	}
+	if v.NotApplicableError != nil {
+		err = multierr.Append(err, enc.AddObject("notApplicableError", v.NotApplicableError))
+	}
 	return err
 }
 
 // GetSuccess returns the value of Success if it is set or its
 // zero value if it is unset.
-func (v *Meta_Health_Result) GetSuccess() (o *HealthStatus) {
+func (v *Meta_ShardOwnership_Result) GetSuccess() (o *ShardOwnership) {
 	if v != nil && v.Success != nil {
 		return v.Success
 	}
@@ -810,21 +3036,36 @@ func (v *Meta_Health_Result) GetSuccess() (o *HealthStatus) {
 }
 
 // IsSetSuccess returns true if Success is not nil.
-func (v *Meta_Health_Result) IsSetSuccess() bool {
+func (v *Meta_ShardOwnership_Result) IsSetSuccess() bool {
 	return v != nil && v.Success != nil
 }
 
+// GetNotApplicableError returns the value of NotApplicableError if it is set or its
+// zero value if it is unset.
+func (v *Meta_ShardOwnership_Result) GetNotApplicableError() (o *ShardOwnershipNotApplicableError) {
+	if v != nil && v.NotApplicableError != nil {
+		return v.NotApplicableError
+	}
+
+	return
+}
+
+// IsSetNotApplicableError returns true if NotApplicableError is not nil.
+func (v *Meta_ShardOwnership_Result) IsSetNotApplicableError() bool {
+	return v != nil && v.NotApplicableError != nil
+}
+
 // MethodName returns the name of the Thrift function as specified in
 // the IDL, for which this struct represent the result.
 //
-// This will always be "health" for this struct.
-func (v *Meta_Health_Result) MethodName() string {
-	return "health"
+// This will always be "shardOwnership" for this struct.
+func (v *Meta_ShardOwnership_Result) MethodName() string {
+	return "shardOwnership"
 }
 
 // EnvelopeType returns the kind of value inside this struct.
 //
 // This will always be Reply for this struct.
-func (v *Meta_Health_Result) EnvelopeType() wire.EnvelopeType {
+func (v *Meta_ShardOwnership_Result) EnvelopeType() wire.EnvelopeType {
 	return wire.Reply
 }
@@ -43,6 +43,16 @@ type Interface interface {
 		ctx context.Context,
 		opts ...yarpc.CallOption,
 	) (*health.HealthStatus, error)
+
+	LoadStatus(
+		ctx context.Context,
+		opts ...yarpc.CallOption,
+	) (*health.LoadStatus, error)
+
+	ShardOwnership(
+		ctx context.Context,
+		opts ...yarpc.CallOption,
+	) (*health.ShardOwnership, error)
 }
 
 // New builds a new client for the Meta service.
@@ -100,3 +110,57 @@ func (c client) Health(
 	success, err = health.Meta_Health_Helper.UnwrapResponse(&result)
 	return
 }
+
+func (c client) LoadStatus(
+	ctx context.Context,
+	opts ...yarpc.CallOption,
+) (success *health.LoadStatus, err error) {
+
+	var result health.Meta_LoadStatus_Result
+	args := health.Meta_LoadStatus_Helper.Args()
+
+	if c.nwc != nil && c.nwc.Enabled() {
+		if err = c.nwc.Call(ctx, args, &result, opts...); err != nil {
+			return
+		}
+	} else {
+		var body wire.Value
+		if body, err = c.c.Call(ctx, args, opts...); err != nil {
+			return
+		}
+
+		if err = result.FromWire(body); err != nil {
+			return
+		}
+	}
+
+	success, err = health.Meta_LoadStatus_Helper.UnwrapResponse(&result)
+	return
+}
+
+func (c client) ShardOwnership(
+	ctx context.Context,
+	opts ...yarpc.CallOption,
+) (success *health.ShardOwnership, err error) {
+
+	var result health.Meta_ShardOwnership_Result
+	args := health.Meta_ShardOwnership_Helper.Args()
+
+	if c.nwc != nil && c.nwc.Enabled() {
+		if err = c.nwc.Call(ctx, args, &result, opts...); err != nil {
+			return
+		}
+	} else {
+		var body wire.Value
+		if body, err = c.c.Call(ctx, args, opts...); err != nil {
+			return
+		}
+
+		if err = result.FromWire(body); err != nil {
+			return
+		}
+	}
+
+	success, err = health.Meta_ShardOwnership_Helper.UnwrapResponse(&result)
+	return
+}
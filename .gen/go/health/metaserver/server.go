@@ -42,6 +42,14 @@ type Interface interface {
 	Health(
 		ctx context.Context,
 	) (*health.HealthStatus, error)
+
+	LoadStatus(
+		ctx context.Context,
+	) (*health.LoadStatus, error)
+
+	ShardOwnership(
+		ctx context.Context,
+	) (*health.ShardOwnership, error)
 }
 
 // New prepares an implementation of the Meta service for
@@ -66,10 +74,34 @@ func New(impl Interface, opts ...thrift.RegisterOption) []transport.Procedure {
 				Signature:    "Health() (*health.HealthStatus)",
 				ThriftModule: health.ThriftModule,
 			},
+
+			thrift.Method{
+				Name: "loadStatus",
+				HandlerSpec: thrift.HandlerSpec{
+
+					Type:   transport.Unary,
+					Unary:  thrift.UnaryHandler(h.LoadStatus),
+					NoWire: loadstatus_NoWireHandler{impl},
+				},
+				Signature:    "LoadStatus() (*health.LoadStatus)",
+				ThriftModule: health.ThriftModule,
+			},
+
+			thrift.Method{
+				Name: "shardOwnership",
+				HandlerSpec: thrift.HandlerSpec{
+
+					Type:   transport.Unary,
+					Unary:  thrift.UnaryHandler(h.ShardOwnership),
+					NoWire: shardownership_NoWireHandler{impl},
+				},
+				Signature:    "ShardOwnership() (*health.ShardOwnership)",
+				ThriftModule: health.ThriftModule,
+			},
 		},
 	}
 
-	procedures := make([]transport.Procedure, 0, 1)
+	procedures := make([]transport.Procedure, 0, 3)
 	procedures = append(procedures, thrift.BuildProcedures(service, opts...)...)
 	return procedures
 }
@@ -110,6 +142,66 @@ func (h handler) Health(ctx context.Context, body wire.Value) (thrift.Response,
 	return response, err
 }
 
+func (h handler) LoadStatus(ctx context.Context, body wire.Value) (thrift.Response, error) {
+	var args health.Meta_LoadStatus_Args
+	if err := args.FromWire(body); err != nil {
+		return thrift.Response{}, yarpcerrors.InvalidArgumentErrorf(
+			"could not decode Thrift request for service 'Meta' procedure 'LoadStatus': %w", err)
+	}
+
+	success, appErr := h.impl.LoadStatus(ctx)
+
+	hadError := appErr != nil
+	result, err := health.Meta_LoadStatus_Helper.WrapResponse(success, appErr)
+
+	var response thrift.Response
+	if err == nil {
+		response.IsApplicationError = hadError
+		response.Body = result
+		if namer, ok := appErr.(yarpcErrorNamer); ok {
+			response.ApplicationErrorName = namer.YARPCErrorName()
+		}
+		if extractor, ok := appErr.(yarpcErrorCoder); ok {
+			response.ApplicationErrorCode = extractor.YARPCErrorCode()
+		}
+		if appErr != nil {
+			response.ApplicationErrorDetails = appErr.Error()
+		}
+	}
+
+	return response, err
+}
+
+func (h handler) ShardOwnership(ctx context.Context, body wire.Value) (thrift.Response, error) {
+	var args health.Meta_ShardOwnership_Args
+	if err := args.FromWire(body); err != nil {
+		return thrift.Response{}, yarpcerrors.InvalidArgumentErrorf(
+			"could not decode Thrift request for service 'Meta' procedure 'ShardOwnership': %w", err)
+	}
+
+	success, appErr := h.impl.ShardOwnership(ctx)
+
+	hadError := appErr != nil
+	result, err := health.Meta_ShardOwnership_Helper.WrapResponse(success, appErr)
+
+	var response thrift.Response
+	if err == nil {
+		response.IsApplicationError = hadError
+		response.Body = result
+		if namer, ok := appErr.(yarpcErrorNamer); ok {
+			response.ApplicationErrorName = namer.YARPCErrorName()
+		}
+		if extractor, ok := appErr.(yarpcErrorCoder); ok {
+			response.ApplicationErrorCode = extractor.YARPCErrorCode()
+		}
+		if appErr != nil {
+			response.ApplicationErrorDetails = appErr.Error()
+		}
+	}
+
+	return response, err
+}
+
 type health_NoWireHandler struct{ impl Interface }
 
 func (h health_NoWireHandler) HandleNoWire(ctx context.Context, nwc *thrift.NoWireCall) (thrift.NoWireResponse, error) {
@@ -146,3 +238,77 @@ func (h health_NoWireHandler) HandleNoWire(ctx context.Context, nwc *thrift.NoWi
 	return response, err
 
 }
+
+type loadstatus_NoWireHandler struct{ impl Interface }
+
+func (h loadstatus_NoWireHandler) HandleNoWire(ctx context.Context, nwc *thrift.NoWireCall) (thrift.NoWireResponse, error) {
+	var (
+		args health.Meta_LoadStatus_Args
+		rw   stream.ResponseWriter
+		err  error
+	)
+
+	rw, err = nwc.RequestReader.ReadRequest(ctx, nwc.EnvelopeType, nwc.Reader, &args)
+	if err != nil {
+		return thrift.NoWireResponse{}, yarpcerrors.InvalidArgumentErrorf(
+			"could not decode (via no wire) Thrift request for service 'Meta' procedure 'LoadStatus': %w", err)
+	}
+
+	success, appErr := h.impl.LoadStatus(ctx)
+
+	hadError := appErr != nil
+	result, err := health.Meta_LoadStatus_Helper.WrapResponse(success, appErr)
+	response := thrift.NoWireResponse{ResponseWriter: rw}
+	if err == nil {
+		response.IsApplicationError = hadError
+		response.Body = result
+		if namer, ok := appErr.(yarpcErrorNamer); ok {
+			response.ApplicationErrorName = namer.YARPCErrorName()
+		}
+		if extractor, ok := appErr.(yarpcErrorCoder); ok {
+			response.ApplicationErrorCode = extractor.YARPCErrorCode()
+		}
+		if appErr != nil {
+			response.ApplicationErrorDetails = appErr.Error()
+		}
+	}
+	return response, err
+
+}
+
+type shardownership_NoWireHandler struct{ impl Interface }
+
+func (h shardownership_NoWireHandler) HandleNoWire(ctx context.Context, nwc *thrift.NoWireCall) (thrift.NoWireResponse, error) {
+	var (
+		args health.Meta_ShardOwnership_Args
+		rw   stream.ResponseWriter
+		err  error
+	)
+
+	rw, err = nwc.RequestReader.ReadRequest(ctx, nwc.EnvelopeType, nwc.Reader, &args)
+	if err != nil {
+		return thrift.NoWireResponse{}, yarpcerrors.InvalidArgumentErrorf(
+			"could not decode (via no wire) Thrift request for service 'Meta' procedure 'ShardOwnership': %w", err)
+	}
+
+	success, appErr := h.impl.ShardOwnership(ctx)
+
+	hadError := appErr != nil
+	result, err := health.Meta_ShardOwnership_Helper.WrapResponse(success, appErr)
+	response := thrift.NoWireResponse{ResponseWriter: rw}
+	if err == nil {
+		response.IsApplicationError = hadError
+		response.Body = result
+		if namer, ok := appErr.(yarpcErrorNamer); ok {
+			response.ApplicationErrorName = namer.YARPCErrorName()
+		}
+		if extractor, ok := appErr.(yarpcErrorCoder); ok {
+			response.ApplicationErrorCode = extractor.YARPCErrorCode()
+		}
+		if appErr != nil {
+			response.ApplicationErrorDetails = appErr.Error()
+		}
+	}
+	return response, err
+
+}
@@ -96,3 +96,65 @@ func (mr *_MockClientRecorder) Health(
 	args := append([]interface{}{ctx}, opts...)
 	return mr.mock.ctrl.RecordCall(mr.mock, "Health", args...)
 }
+
+// LoadStatus responds to a LoadStatus call based on the mock expectations. This
+// call will fail if the mock does not expect this call. Use EXPECT to expect
+// a call to this function.
+//
+//	client.EXPECT().LoadStatus(gomock.Any(), ...).Return(...)
+//	... := client.LoadStatus(...)
+func (m *MockClient) LoadStatus(
+	ctx context.Context,
+	opts ...yarpc.CallOption,
+) (success *health.LoadStatus, err error) {
+
+	args := []interface{}{ctx}
+	for _, o := range opts {
+		args = append(args, o)
+	}
+	i := 0
+	ret := m.ctrl.Call(m, "LoadStatus", args...)
+	success, _ = ret[i].(*health.LoadStatus)
+	i++
+	err, _ = ret[i].(error)
+	return
+}
+
+func (mr *_MockClientRecorder) LoadStatus(
+	ctx interface{},
+	opts ...interface{},
+) *gomock.Call {
+	args := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCall(mr.mock, "LoadStatus", args...)
+}
+
+// ShardOwnership responds to a ShardOwnership call based on the mock expectations. This
+// call will fail if the mock does not expect this call. Use EXPECT to expect
+// a call to this function.
+//
+//	client.EXPECT().ShardOwnership(gomock.Any(), ...).Return(...)
+//	... := client.ShardOwnership(...)
+func (m *MockClient) ShardOwnership(
+	ctx context.Context,
+	opts ...yarpc.CallOption,
+) (success *health.ShardOwnership, err error) {
+
+	args := []interface{}{ctx}
+	for _, o := range opts {
+		args = append(args, o)
+	}
+	i := 0
+	ret := m.ctrl.Call(m, "ShardOwnership", args...)
+	success, _ = ret[i].(*health.ShardOwnership)
+	i++
+	err, _ = ret[i].(error)
+	return
+}
+
+func (mr *_MockClientRecorder) ShardOwnership(
+	ctx interface{},
+	opts ...interface{},
+) *gomock.Call {
+	args := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCall(mr.mock, "ShardOwnership", args...)
+}
@@ -24,3 +24,18 @@
 // @generated
 
 package health
+
+import yarpcerrors "go.uber.org/yarpc/yarpcerrors"
+
+// YARPCErrorCode returns nil for ShardOwnershipNotApplicableError.
+//
+// This is derived from the rpc.code annotation on the Thrift exception.
+func (e *ShardOwnershipNotApplicableError) YARPCErrorCode() *yarpcerrors.Code {
+
+	return nil
+}
+
+// Name is the error name for ShardOwnershipNotApplicableError.
+func (e *ShardOwnershipNotApplicableError) YARPCErrorName() string {
+	return "ShardOwnershipNotApplicableError"
+}
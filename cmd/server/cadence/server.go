@@ -28,6 +28,7 @@ import (
 
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/compatibility"
+	"go.uber.org/yarpc"
 
 	apiv1 "github.com/uber/cadence-idl/go/proto/api/v1"
 
@@ -115,6 +116,7 @@ func (s *server) startService() common.Daemon {
 	params.Logger = loggerimpl.NewLogger(zapLogger).WithTags(tag.Service(params.Name))
 
 	params.PersistenceConfig = s.cfg.Persistence
+	params.HealthDegradation = common.NewHealthDegradation()
 
 	err = nil
 	if s.cfg.DynamicConfig.Client == "" {
@@ -161,6 +163,9 @@ func (s *server) startService() common.Daemon {
 		rpcParams.OutboundsBuilder,
 		rpc.NewCrossDCOutbounds(clusterGroupMetadata.ClusterGroup, rpc.NewDNSPeerChooserFactory(s.cfg.PublicClient.RefreshInterval, params.Logger)),
 	)
+	maxInFlightRequests := dc.GetIntProperty(dynamicconfig.MaxInFlightRequests)
+	params.RequestLoad = common.NewRequestLoad(func() int { return maxInFlightRequests() })
+	rpcParams.InboundMiddleware.Unary = yarpc.UnaryInboundMiddleware(&rpc.InFlightRequestsMiddleware{Load: params.RequestLoad}, rpcParams.InboundMiddleware.Unary)
 	rpcFactory := rpc.NewFactory(params.Logger, rpcParams)
 	params.RPCFactory = rpcFactory
 
@@ -339,6 +339,180 @@ type (
 		// Required when UseMultipleDatabases is true
 		// the length of the list should be exactly the same as NumShards
 		MultipleDatabasesConfig []MultipleDatabasesConfigEntry `yaml:"multipleDatabasesConfig"`
+		// StreamFetchSize is the number of rows the streaming Select methods fetch per round trip, currently only used
+		// by postgres. Larger values take fewer round trips for a large result set but hold more rows in memory at once.
+		// Default is 0, which keeps the fetch size of each method.
+		StreamFetchSize int `yaml:"streamFetchSize"`
+		// BatchInsertMode selects how writes of multiple rows into the same table are sent, currently only used by postgres.
+		// "multiRow" (default) sends a single INSERT with one VALUES tuple per row: fewest round trips, but the statement
+		// text and number of bind parameters grow with the batch.
+		// "singleRow" sends one INSERT per row on the same connection: more round trips, but small statements of a fixed
+		// shape, which some driver versions and connection poolers execute with noticeably lower latency.
+		BatchInsertMode string `yaml:"batchInsertMode"`
+		// SingleRowBindMode selects how a write of a single execution map row is bound, currently only used by postgres.
+		// "named" (default) binds the row by name like the rows of a batch. "positional" binds the row to a positional
+		// statement before it is sent and writes it with a plain Exec, the statement is the same.
+		SingleRowBindMode string `yaml:"singleRowBindMode"`
+		// TablePrefix is prepended to the names of the execution map tables, currently only used by postgres.
+		// It allows several clusters to share one database, each with its own set of tables created with the prefix.
+		// Defaults to empty, i.e. the table names of the schema.
+		TablePrefix string `yaml:"tablePrefix"`
+		// ActivityInfoMapsCacheSize is the number of executions whose activity_info_maps rows are cached in memory,
+		// currently only used by postgres. Default is 0, which disables the cache.
+		// The cache is only invalidated by writes through this process, so it relies on executions being written by
+		// the host owning their shard, and ActivityInfoMapsCacheTTL bounds how long an entry can survive a shard movement.
+		ActivityInfoMapsCacheSize int `yaml:"activityInfoMapsCacheSize"`
+		// ActivityInfoMapsCacheTTL is the time to live of an entry of the activity_info_maps cache. Default is 10s.
+		ActivityInfoMapsCacheTTL time.Duration `yaml:"activityInfoMapsCacheTTL"`
+		// MapTransactionIsolation is the isolation level of the transactions the plugin starts on its own for execution
+		// map operations, like merging a signals requested set or WithExecutionTransaction, currently only used by postgres.
+		// "" (default) uses the default of the server, usually read committed. "readCommitted", "repeatableRead" and
+		// "serializable" select the level. At repeatableRead and serializable Postgres aborts a transaction which conflicts
+		// with a concurrent one with a serialization failure, the transaction is then run again up to a few times.
+		MapTransactionIsolation string `yaml:"mapTransactionIsolation"`
+		// MapDeleteAuditLogPath is the file an audit record is appended to before rows of the execution map tables are deleted,
+		// currently only used by postgres. Each record is a JSON line holding the execution, the deleted keys, the actor set
+		// on the context with sqlplugin.WithAuditActor and a timestamp. The DBs of a process configured with the same path
+		// append to the same open file. Default is empty, which disables the audit log.
+		MapDeleteAuditLogPath string `yaml:"mapDeleteAuditLogPath"`
+		// MaxExecutionMapRows caps the rows a single execution can have in each execution map table, currently only used
+		// by postgres. A write which would push an execution above the cap is rejected with a LimitExceededError.
+		// The count includes rows which the same update deletes afterwards. Default is 0, which disables the cap.
+		MaxExecutionMapRows int `yaml:"maxExecutionMapRows"`
+		// StatementTimeouts bound each statement of the transactions the plugin starts on its own, currently only used by
+		// postgres, which issues SET LOCAL statement_timeout at the start of those transactions. The server then aborts
+		// a statement exceeding the bound and releases its locks, even if the client never cancels it.
+		// Every timeout defaults to 0, which leaves the statement_timeout of the server in effect.
+		StatementTimeouts SQLStatementTimeouts `yaml:"statementTimeouts"`
+		// AsyncMapWriteQueueSize is the number of activity_info_maps rows queued for a background write by
+		// EnqueueReplaceActivityInfoMaps before callers block, currently only used by postgres. The queue is drained
+		// when the DB is closed. The rows are written in the order they were enqueued while their shard is locked, the
+		// rows enqueued under an older range than the current one of their shard are dropped.
+		// Default is 0, which writes enqueued rows synchronously.
+		AsyncMapWriteQueueSize int `yaml:"asyncMapWriteQueueSize"`
+		// AsyncMapWriteBatchSize is the max number of queued rows written in one batch. Default is 100.
+		AsyncMapWriteBatchSize int `yaml:"asyncMapWriteBatchSize"`
+		// AsyncMapWriteFlushInterval is the longest queued rows wait for their batch to fill up before they are written.
+		// Default is 100ms.
+		AsyncMapWriteFlushInterval time.Duration `yaml:"asyncMapWriteFlushInterval"`
+		// TimerInfoMapsMaxStaleness is how far the fire time of a timer written with ReplaceIntoTimerInfoMapsWithReferenceTime
+		// can lie before the reference time, currently only used by postgres. A timer firing earlier was most likely
+		// corrupted and would fire right away. Such a write is logged and counted, and rejected in strict mode.
+		// Default is 0, which disables the validation.
+		TimerInfoMapsMaxStaleness time.Duration `yaml:"timerInfoMapsMaxStaleness"`
+		// TimerInfoMapsStrictMode rejects the writes of stale timers with a BadRequestError instead of only logging them
+		TimerInfoMapsStrictMode bool `yaml:"timerInfoMapsStrictMode"`
+		// SignalsRequestedSetsStrictMode fails an insert into signals_requested_sets with a BadRequestError when one of its
+		// signal IDs is already in the set of the execution, instead of silently keeping the existing one, currently only
+		// used by postgres. The rows only hold the signal ID, so a collision of distinct signals can not be told apart from
+		// a repeated insert of the same one and strict mode is meant to catch the callers which reuse signal IDs. The IDs
+		// which did not collide are still inserted unless the insert runs in a transaction. Default is false.
+		SignalsRequestedSetsStrictMode bool `yaml:"signalsRequestedSetsStrictMode"`
+		// MapDataFallbackEncoding is the encoding the execution map rows whose data_encoding is not in DecodingTypes are
+		// decoded with, currently only used by postgres. It keeps the reads working after a partial downgrade to a binary
+		// which does not know the encoding of some rows: a row whose data decodes with the fallback encoding is returned
+		// with it, any other row is returned as it is and fails to decode. Default is empty, which keeps decoding strict.
+		MapDataFallbackEncoding string `yaml:"mapDataFallbackEncoding"`
+		// EnableDBShardOverride lets a context set with sqlplugin.WithDBShardOverride pin the dbShardID the operations on
+		// the execution map tables are routed to, currently only used by postgres. It is meant for tests and must not be
+		// enabled in production, the pinned rows are invisible to every other operation. Default is false.
+		EnableDBShardOverride bool `yaml:"enableDBShardOverride"`
+		// AsyncMapWriteWALPath is the file the queued rows are appended to, and synced, before they are queued. The rows left
+		// in it by a crash are written when the DB is created again, which can write a row again if the crash happened
+		// right after its batch was written. The rows are stored in full, including their activity data, as plaintext JSON
+		// on the local disk, protected only by the permissions of the file. The DBs of a process configured with the same
+		// path share the log and its queue, the admin DB never opens it. Requires AsyncMapWriteQueueSize.
+		// Default is empty, which disables the log.
+		AsyncMapWriteWALPath string `yaml:"asyncMapWriteWALPath"`
+		// AsyncMapWriteWALMaxSize is the max size in bytes of the log. The log is rewritten with the rows which are not
+		// written yet when it would grow larger, and rows which still do not fit are rejected with a LimitExceededError.
+		// It is truncated whenever all of its rows are written. Default is 64MiB.
+		AsyncMapWriteWALMaxSize int64 `yaml:"asyncMapWriteWALMaxSize"`
+		// MaxInFlightMapOperations bounds the statements of the execution map operations in flight per DB shard, currently
+		// only used by postgres. Further statements wait for a slot until their context is done, so a hot shard throttles
+		// itself instead of exhausting a connection pool shared with other shards. Statements within a transaction are not
+		// bounded. Default is 0, which does not bound them.
+		MaxInFlightMapOperations int `yaml:"maxInFlightMapOperations"`
+		// MapRetryBudgetRPS is the rate at which the retries of the execution map operations are allowed per DB shard,
+		// currently only used by postgres. The operations of a DB shard share a token bucket which each retry takes a
+		// token from. Once a statement sent to a DB shard failed, every statement sent to it until one succeeds is a
+		// retry, and fails fast with a ServiceBusyError when it finds the bucket empty. A transaction retried for a
+		// serialization failure fails with its last error instead. It bounds the retries sent to a failing DB shard
+		// however many operations are in flight. Default is 0, which does not bound them.
+		MapRetryBudgetRPS float64 `yaml:"mapRetryBudgetRPS"`
+		// MapRetryBudgetBurst is the number of tokens the bucket of MapRetryBudgetRPS holds. Default is
+		// MapRetryBudgetRPS rounded up.
+		MapRetryBudgetBurst int `yaml:"mapRetryBudgetBurst"`
+		// MapWriteQPSPerDomain limits the rate of the execution map writes of a domain, keyed by domain ID, currently only
+		// used by postgres. A write over the limit waits for its context unless RejectMapWritesOverDomainQPS is set,
+		// including a write within a transaction, which holds its connection while waiting. Default is empty, which
+		// does not limit any domain.
+		MapWriteQPSPerDomain map[string]int `yaml:"mapWriteQPSPerDomain"`
+		// RejectMapWritesOverDomainQPS fails the execution map writes over the limit of MapWriteQPSPerDomain with a
+		// ServiceBusyError instead of waiting, currently only used by postgres. Default is false.
+		RejectMapWritesOverDomainQPS bool `yaml:"rejectMapWritesOverDomainQPS"`
+		// DBShardPingTimeout bounds how long each DB shard is probed by a health check, currently only used by postgres.
+		// The DB shards are probed concurrently and the check returns as soon as one of them exceeds the bound, so a
+		// single slow DB shard does not slow down the check. Default is 0, which only bounds the check by its context.
+		DBShardPingTimeout time.Duration `yaml:"dbShardPingTimeout"`
+		// LogFailedMapQueries logs the query of an execution map statement which fails at error level, with a summary of
+		// its parameters in which the blobs are redacted to their length, currently only used by postgres. Nothing is
+		// logged for the statements which succeed. Default is false.
+		LogFailedMapQueries bool `yaml:"logFailedMapQueries"`
+		// DeferredMapDeletion allows the rows of activity_info_maps to be flagged as deleted and removed by a background
+		// sweep later, currently only used by postgres. The selects skip the flagged rows. It requires the deleted column
+		// of schema version 0.7. Default is false.
+		DeferredMapDeletion bool `yaml:"deferredMapDeletion"`
+		// MapRowCounterEnabled keeps the number of rows of each execution in activity_info_maps, timer_info_maps,
+		// child_execution_info_maps, request_cancel_info_maps and signal_info_maps in the map_row_count of its executions
+		// row, currently only used by postgres. The replaces and deletes of the rows update the count in their transaction,
+		// or in a transaction of their own, so the count can be compared with the rows to detect their silent loss. It
+		// requires the map_row_count column of schema version 0.11, whose upgrade backfills the count of the existing
+		// executions, and can not be combined with DeferredMapDeletion or PreviousNumShards. The writes made while it is
+		// disabled do not maintain the count, so it should be enabled along with the upgrade. Default is false.
+		MapRowCounterEnabled bool `yaml:"mapRowCounterEnabled"`
+		// MapDeletionConcurrency is the number of execution map tables the rows of an execution are deleted from
+		// concurrently when all the maps of the execution are deleted, currently only used by postgres. The concurrent
+		// deletes run on separate connections and commit independently, so they are not atomic: when one of them fails
+		// the rows of the other tables may already be deleted. The deletes are idempotent and the failed execution is
+		// deleted again by a retry. Within a transaction the tables are always deleted from one after the other.
+		// Default is 0, which deletes from them one after the other.
+		MapDeletionConcurrency int `yaml:"mapDeletionConcurrency"`
+		// PreviousNumShards is the NumShards the execution map rows were routed with before NumShards was increased,
+		// currently only used by postgres. It is a temporary setting for the migration window: the selects of the maps of
+		// an execution which find no rows on the DB shard of NumShards read the DB shard of PreviousNumShards, and the
+		// deletes remove the rows from both. It must be removed once the rows are migrated, since every miss costs a
+		// second read. It must be smaller than NumShards. While it is set, a recorded number of DB shards equal to it is
+		// replaced by NumShards, so the change is not reported as a mismatch. Default is 0, which disables the fallback.
+		PreviousNumShards int `yaml:"previousNShards"`
+		// MapRowCountReportEnabled counts the rows of every execution map table per shard in the background and records
+		// the counts as gauges tagged by table and shard, currently only used by postgres. A count scans the whole table
+		// on every DB shard. Default is false.
+		MapRowCountReportEnabled bool `yaml:"mapRowCountReportEnabled"`
+		// MapRowCountReportInterval is how often the rows are counted when MapRowCountReportEnabled is set, a count taking
+		// longer is cancelled. Default is 5m.
+		MapRowCountReportInterval time.Duration `yaml:"mapRowCountReportInterval"`
+		// ApplicationName is the application_name of the connections, currently only used by postgres. It names the
+		// component which owns the connections in pg_stat_activity, so it should be set per service, like
+		// cadence-history. It must not be set in ConnectAttributes as well. Default is empty, which keeps the default
+		// of the driver.
+		ApplicationName string `yaml:"applicationName"`
+		// LabelTransactionsWithOperation appends the class of the persistence operation to the application_name of the
+		// transactions started for it, like "cadence-history: UpdateWorkflowExecution", currently only used by postgres.
+		// It costs a statement per transaction, the statements outside of a transaction keep ApplicationName, which it
+		// requires. Default is false.
+		LabelTransactionsWithOperation bool `yaml:"labelTransactionsWithOperation"`
+	}
+
+	// SQLStatementTimeouts are the statement timeouts of the classes of transactions a SQL plugin starts on its own
+	SQLStatementTimeouts struct {
+		// BatchWrite applies to the transaction a batch of execution map rows is written in when BatchInsertMode is "singleRow",
+		// and to the transaction an execution map write runs in with the map row count when MapRowCounterEnabled is set
+		BatchWrite time.Duration `yaml:"batchWrite"`
+		// LockedUpdate applies to the transactions which lock rows for a read-modify-write, like merging a signals requested set
+		LockedUpdate time.Duration `yaml:"lockedUpdate"`
+		// ExecutionWrite applies to the transactions an execution row and its execution map rows are written in together
+		ExecutionWrite time.Duration `yaml:"executionWrite"`
 	}
 
 	// MultipleDatabasesConfigEntry is an entry for MultipleDatabasesConfig to connect to a single SQL database
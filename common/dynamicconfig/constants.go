@@ -1240,6 +1240,27 @@ const (
 	// Default value: 5
 	// Allowed filters: N/A
 	ScannerPersistenceMaxQPS
+	// ScannerPersistenceMaxQPSPerDomain is the maximum rate of persistence calls from worker.Scanner spent on a single domain,
+	// it is applied under ScannerPersistenceMaxQPS and 0 means the domain is only limited by ScannerPersistenceMaxQPS
+	// KeyName: worker.scannerPersistenceMaxQPSPerDomain
+	// Value type: Int
+	// Default value: 0
+	// Allowed filters: DomainName
+	ScannerPersistenceMaxQPSPerDomain
+	// ScannerHistoryScavengerConcurrency is the number of history branches the history scavenger processes in parallel,
+	// 0 derives it from ScannerPersistenceMaxQPS and values above 1000 are capped
+	// KeyName: worker.scannerHistoryScavengerConcurrency
+	// Value type: Int
+	// Default value: 0
+	// Allowed filters: N/A
+	ScannerHistoryScavengerConcurrency
+	// ScannerHistoryScavengerMaxHeartbeatDetailsSize is the maximum size in bytes of the heartbeat details recorded by
+	// the history scavenger, larger details are compacted and 0 disables the cap
+	// KeyName: worker.scannerHistoryScavengerMaxHeartbeatDetailsSize
+	// Value type: Int
+	// Default value: 262144
+	// Allowed filters: N/A
+	ScannerHistoryScavengerMaxHeartbeatDetailsSize
 	// ScannerGetOrphanTasksPageSize is the maximum number of orphans to delete in one batch
 	// KeyName: worker.scannerGetOrphanTasksPageSize
 	// Value type: Int
@@ -1258,6 +1279,18 @@ const (
 	// Default value: 256
 	// Allowed filters: N/A
 	ScannerMaxTasksProcessedPerTasklistJob
+	// ScannerDedicatedWorkerMaxConcurrentActivityExecutionSize is the number of activities each worker of the dedicated scanner worker pool runs concurrently
+	// KeyName: worker.scannerDedicatedWorkerMaxConcurrentActivityExecutionSize
+	// Value type: Int
+	// Default value: 10
+	// Allowed filters: N/A
+	ScannerDedicatedWorkerMaxConcurrentActivityExecutionSize
+	// ScannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize is the number of decision tasks each worker of the dedicated scanner worker pool runs concurrently
+	// KeyName: worker.scannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize
+	// Value type: Int
+	// Default value: 10
+	// Allowed filters: N/A
+	ScannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize
 	// ConcreteExecutionsScannerConcurrency is indicates the concurrency of concrete execution scanner
 	// KeyName: worker.executionsScannerConcurrency
 	// Value type: Int
@@ -1282,6 +1315,41 @@ const (
 	// Default value: 1000
 	// Allowed filters: N/A
 	ConcreteExecutionsScannerPersistencePageSize
+	// ConcreteExecutionsScannerMapDataDecodeRPS is the number of executions per second whose execution map data blobs
+	// are decoded by each scan activity of the concrete executions scanner, when map data decoding is enabled
+	// KeyName: worker.executionsScannerMapDataDecodeRPS
+	// Value type: Int
+	// Default value: 10
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapDataDecodeRPS
+	// ConcreteExecutionsScannerMapHistoryCheckRPS is the number of executions per second whose execution map rows are
+	// reconciled with their history by each scan activity of the concrete executions scanner, when the check is enabled
+	// KeyName: worker.executionsScannerMapHistoryCheckRPS
+	// Value type: Int
+	// Default value: 5
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapHistoryCheckRPS
+	// ConcreteExecutionsScannerChildExecutionsCheckRPS is the number of executions per second whose started children
+	// are looked up by each scan activity of the concrete executions scanner, when the check is enabled
+	// KeyName: worker.executionsScannerChildExecutionsCheckRPS
+	// Value type: Int
+	// Default value: 5
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerChildExecutionsCheckRPS
+	// ConcreteExecutionsScannerMapRowCountCheckRPS is the number of executions per second whose map row count is compared
+	// with their execution map rows by each scan activity of the concrete executions scanner, when the check is enabled
+	// KeyName: worker.executionsScannerMapRowCountCheckRPS
+	// Value type: Int
+	// Default value: 5
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapRowCountCheckRPS
+	// ConcreteExecutionsScannerMapDataReencodeRPS is the number of executions per second whose execution map rows are
+	// checked, and re-encoded by the fixer, when the re-encode pass of the concrete executions scanner is enabled
+	// KeyName: worker.executionsScannerMapDataReencodeRPS
+	// Value type: Int
+	// Default value: 5
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapDataReencodeRPS
 	// CurrentExecutionsScannerConcurrency is indicates the concurrency of current executions scanner
 	// KeyName: worker.currentExecutionsConcurrency
 	// Value type: Int
@@ -1404,6 +1472,20 @@ const (
 	// Value type: int
 	// Default value: 2
 	IsolationGroupStateUpdateRetryAttempts
+	// HealthCheckRetryCount is the number of times the Meta health check of a host is retried while it fails
+	// or reports unhealthy, before the host is reported as not serving. Counts above 3 are treated as 3
+	// KeyName: system.healthCheckRetryCount
+	// Value type: Int
+	// Default value: 0
+	// Allowed filters: N/A
+	HealthCheckRetryCount
+	// MaxInFlightRequests is the number of inbound requests in flight at which a host reports itself as saturated
+	// in the LoadStatus of the Meta service, 0 reports no saturation
+	// KeyName: system.maxInFlightRequests
+	// Value type: Int
+	// Default value: 0
+	// Allowed filters: N/A
+	MaxInFlightRequests
 
 	LargeShardHistoryBlobMetricThreshold
 	// LastIntKey must be the last one in this const group
@@ -1507,6 +1589,13 @@ const (
 	// Default value: false
 	// Allowed filters: N/A
 	EnableSQLAsyncTransaction
+	// SQLMapWriteMaintenance makes the SQL stores which support it reject the writes of the execution map tables with a
+	// retryable maintenance error while reads continue, it freezes the maps during a maintenance of the database
+	// KeyName: system.sqlMapWriteMaintenance
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	SQLMapWriteMaintenance
 
 	// key for frontend
 
@@ -1698,6 +1787,27 @@ const (
 	// Default value: false
 	// Allowed filters: N/A
 	HistoryScannerEnabled
+	// ScannerDedicatedWorkerEnabled indicates if the task list, history, executions and timers scanners run on workers
+	// with the concurrency limits of the dedicated scanner worker pool instead of the limits shared by all scanner workers
+	// KeyName: worker.scannerDedicatedWorkerEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ScannerDedicatedWorkerEnabled
+	// ScannerFindingsTableEnabled indicates if the shard scanners record the corrupted and failed executions they find in
+	// the scanner_findings table of the default store in addition to the blobstore, it requires a SQL default store
+	// KeyName: worker.scannerFindingsTableEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ScannerFindingsTableEnabled
+	// ScannerShardScanTimestampsEnabled indicates if the shard scanners record when they last scanned each shard in
+	// the scanner_shard_scans table of the default store, it requires a SQL default store
+	// KeyName: worker.scannerShardScanTimestampsEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ScannerShardScanTimestampsEnabled
 	// ConcreteExecutionsScannerEnabled is indicates if executions scanner should be started as part of worker.Scanner
 	// KeyName: worker.executionsScannerEnabled
 	// Value type: Bool
@@ -1716,6 +1826,34 @@ const (
 	// Default value: true
 	// Allowed filters: N/A
 	ConcreteExecutionsScannerInvariantCollectionHistory
+	// ConcreteExecutionsScannerMapDataDecodeEnabled indicates if the concrete executions scanner decodes the data blob of every
+	// execution map row with its data_encoding and reports the rows which fail to decode. It only works with a SQL default store
+	// KeyName: worker.executionsScannerMapDataDecodeEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapDataDecodeEnabled
+	// ConcreteExecutionsScannerMapHistoryCheckEnabled indicates if the concrete executions scanner reconciles the execution
+	// map rows with the history events and reports the rows without the event which created them. It only works with a SQL default store
+	// KeyName: worker.executionsScannerMapHistoryCheckEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapHistoryCheckEnabled
+	// ConcreteExecutionsScannerChildExecutionsCheckEnabled indicates if the concrete executions scanner looks up the current
+	// run of every started child of open executions and reports the children without one. It only works with a SQL default store
+	// KeyName: worker.executionsScannerChildExecutionsCheckEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerChildExecutionsCheckEnabled
+	// ConcreteExecutionsScannerMapRowCountCheckEnabled indicates if the concrete executions scanner compares the map row count
+	// kept in the executions rows with their execution map rows. It only works with a SQL default store whose map row count is enabled
+	// KeyName: worker.executionsScannerMapRowCountCheckEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	ConcreteExecutionsScannerMapRowCountCheckEnabled
 	// CurrentExecutionsScannerEnabled is indicates if current executions scanner should be started as part of worker.Scanner
 	// KeyName: worker.currentExecutionsScannerEnabled
 	// Value type: Bool
@@ -2140,6 +2278,24 @@ const (
 	// Value type: string ["test-domain","test-domain2"]
 	// Default value: ""
 	ESAnalyzerWorkflowTypeMetricDomains
+	// ConcreteExecutionsScannerExecutionStateFilter restricts the concrete executions scanner to open or closed executions
+	// KeyName: worker.executionsScannerExecutionStateFilter
+	// Value type: string ["all", "open", "closed"]
+	// Default value: "all"
+	ConcreteExecutionsScannerExecutionStateFilter
+	// ConcreteExecutionsScannerShardOrder is the order in which the concrete executions scanner scans the shards,
+	// least_recently_scanned requires worker.scannerShardScanTimestampsEnabled
+	// KeyName: worker.executionsScannerShardOrder
+	// Value type: string ["sequential", "random", "least_recently_scanned"]
+	// Default value: "sequential"
+	ConcreteExecutionsScannerShardOrder
+	// ConcreteExecutionsScannerMapDataReencodeEncoding is the encoding the execution map rows are migrated to by the
+	// concrete executions scanner and fixer. The scanner reports the executions with rows in another encoding and the
+	// fixer re-encodes them. It only works with a SQL default store
+	// KeyName: worker.executionsScannerMapDataReencodeEncoding
+	// Value type: string ["thriftrw"]
+	// Default value: "" (the re-encode pass is disabled)
+	ConcreteExecutionsScannerMapDataReencodeEncoding
 
 	// LastStringKey must be the last one in this const group
 	LastStringKey
@@ -2565,6 +2721,20 @@ const (
 	// Value type: Duration
 	// Default value: 30 minutes
 	ESAnalyzerBufferWaitTime
+	// HistoryScannerStallAlertThreshold is how long the history scavenger can heartbeat without making progress before
+	// the history scanner workflow emits the scavenger_stalled metric, 0 disables the alert
+	// KeyName: worker.historyScannerStallAlertThreshold
+	// Value type: Duration
+	// Default value: 1 hour
+	// Allowed filters: N/A
+	HistoryScannerStallAlertThreshold
+	// ScannerFindingsRetention is how long the findings of the shard scanners are kept in the scanner_findings table,
+	// the shard scanner workflows purge the older findings once their scan completes, 0 disables the purge
+	// KeyName: worker.scannerFindingsRetention
+	// Value type: Duration
+	// Default value: 30 days
+	// Allowed filters: N/A
+	ScannerFindingsRetention
 	// IsolationGroupStateRefreshInterval
 	// KeyName: system.isolationGroupStateRefreshInterval
 	// Value type: Duration
@@ -3485,6 +3655,22 @@ var IntKeys = map[IntKey]DynamicInt{
 		Description:  "ScannerPersistenceMaxQPS is the maximum rate of persistence calls from worker.Scanner",
 		DefaultValue: 5,
 	},
+	ScannerPersistenceMaxQPSPerDomain: DynamicInt{
+		KeyName:      "worker.scannerPersistenceMaxQPSPerDomain",
+		Filters:      []Filter{DomainName},
+		Description:  "ScannerPersistenceMaxQPSPerDomain is the maximum rate of persistence calls from worker.Scanner spent on a single domain, it is applied under ScannerPersistenceMaxQPS and 0 means the domain is only limited by ScannerPersistenceMaxQPS",
+		DefaultValue: 0,
+	},
+	ScannerHistoryScavengerConcurrency: DynamicInt{
+		KeyName:      "worker.scannerHistoryScavengerConcurrency",
+		Description:  "ScannerHistoryScavengerConcurrency is the number of history branches the history scavenger processes in parallel, 0 derives it from ScannerPersistenceMaxQPS and values above 1000 are capped",
+		DefaultValue: 0,
+	},
+	ScannerHistoryScavengerMaxHeartbeatDetailsSize: DynamicInt{
+		KeyName:      "worker.scannerHistoryScavengerMaxHeartbeatDetailsSize",
+		Description:  "ScannerHistoryScavengerMaxHeartbeatDetailsSize is the maximum size in bytes of the heartbeat details recorded by the history scavenger, larger details are compacted and 0 disables the cap",
+		DefaultValue: 256 * 1024,
+	},
 	ScannerGetOrphanTasksPageSize: DynamicInt{
 		KeyName:      "worker.scannerGetOrphanTasksPageSize",
 		Description:  "ScannerGetOrphanTasksPageSize is the maximum number of orphans to delete in one batch",
@@ -3500,6 +3686,16 @@ var IntKeys = map[IntKey]DynamicInt{
 		Description:  "ScannerMaxTasksProcessedPerTasklistJob is the number of tasks to process for a tasklist in each workflow run",
 		DefaultValue: 256,
 	},
+	ScannerDedicatedWorkerMaxConcurrentActivityExecutionSize: DynamicInt{
+		KeyName:      "worker.scannerDedicatedWorkerMaxConcurrentActivityExecutionSize",
+		Description:  "ScannerDedicatedWorkerMaxConcurrentActivityExecutionSize is the number of activities each worker of the dedicated scanner worker pool runs concurrently",
+		DefaultValue: 10,
+	},
+	ScannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize: DynamicInt{
+		KeyName:      "worker.scannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize",
+		Description:  "ScannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize is the number of decision tasks each worker of the dedicated scanner worker pool runs concurrently",
+		DefaultValue: 10,
+	},
 	ConcreteExecutionsScannerConcurrency: DynamicInt{
 		KeyName:      "worker.executionsScannerConcurrency",
 		Description:  "ConcreteExecutionsScannerConcurrency is indicates the concurrency of concrete execution scanner",
@@ -3520,6 +3716,31 @@ var IntKeys = map[IntKey]DynamicInt{
 		Description:  "ConcreteExecutionsScannerPersistencePageSize is indicates the page size of execution persistence fetches in concrete execution scanner",
 		DefaultValue: 1000,
 	},
+	ConcreteExecutionsScannerMapDataDecodeRPS: DynamicInt{
+		KeyName:      "worker.executionsScannerMapDataDecodeRPS",
+		Description:  "ConcreteExecutionsScannerMapDataDecodeRPS is the number of executions per second whose execution map data blobs are decoded by each scan activity of the concrete executions scanner, when map data decoding is enabled",
+		DefaultValue: 10,
+	},
+	ConcreteExecutionsScannerMapHistoryCheckRPS: DynamicInt{
+		KeyName:      "worker.executionsScannerMapHistoryCheckRPS",
+		Description:  "ConcreteExecutionsScannerMapHistoryCheckRPS is the number of executions per second whose execution map rows are reconciled with their history by each scan activity of the concrete executions scanner, when the check is enabled",
+		DefaultValue: 5,
+	},
+	ConcreteExecutionsScannerChildExecutionsCheckRPS: DynamicInt{
+		KeyName:      "worker.executionsScannerChildExecutionsCheckRPS",
+		Description:  "ConcreteExecutionsScannerChildExecutionsCheckRPS is the number of executions per second whose started children are looked up by each scan activity of the concrete executions scanner, when the check is enabled",
+		DefaultValue: 5,
+	},
+	ConcreteExecutionsScannerMapRowCountCheckRPS: DynamicInt{
+		KeyName:      "worker.executionsScannerMapRowCountCheckRPS",
+		Description:  "ConcreteExecutionsScannerMapRowCountCheckRPS is the number of executions per second whose map row count is compared with their execution map rows by each scan activity of the concrete executions scanner, when the check is enabled",
+		DefaultValue: 5,
+	},
+	ConcreteExecutionsScannerMapDataReencodeRPS: DynamicInt{
+		KeyName:      "worker.executionsScannerMapDataReencodeRPS",
+		Description:  "ConcreteExecutionsScannerMapDataReencodeRPS is the number of executions per second whose execution map rows are checked, and re-encoded by the fixer, when the re-encode pass of the concrete executions scanner is enabled",
+		DefaultValue: 5,
+	},
 	CurrentExecutionsScannerConcurrency: DynamicInt{
 		KeyName:      "worker.currentExecutionsConcurrency",
 		Description:  "CurrentExecutionsScannerConcurrency is indicates the concurrency of current executions scanner",
@@ -3630,6 +3851,16 @@ var IntKeys = map[IntKey]DynamicInt{
 		Description:  "The number of attempts to push Isolation group configuration to the config store",
 		DefaultValue: 2,
 	},
+	HealthCheckRetryCount: DynamicInt{
+		KeyName:      "system.healthCheckRetryCount",
+		Description:  "HealthCheckRetryCount is the number of times the Meta health check of a host is retried while it fails or reports unhealthy, before the host is reported as not serving. Counts above 3 are treated as 3",
+		DefaultValue: 0,
+	},
+	MaxInFlightRequests: DynamicInt{
+		KeyName:      "system.maxInFlightRequests",
+		Description:  "MaxInFlightRequests is the number of inbound requests in flight at which a host reports itself as saturated in the LoadStatus of the Meta service, 0 reports no saturation",
+		DefaultValue: 0,
+	},
 	TTLBufferDays: DynamicInt{
 		KeyName:      "system.TTLBufferDays",
 		Description:  "The number of buffer day in the TTL value",
@@ -3728,6 +3959,11 @@ var BoolKeys = map[BoolKey]DynamicBool{
 		Description:  "EnableSQLAsyncTransaction is the key for enabling async transaction",
 		DefaultValue: false,
 	},
+	SQLMapWriteMaintenance: DynamicBool{
+		KeyName:      "system.sqlMapWriteMaintenance",
+		Description:  "SQLMapWriteMaintenance makes the SQL stores which support it reject the writes of the execution map tables with a retryable maintenance error while reads continue",
+		DefaultValue: false,
+	},
 	EnableClientVersionCheck: DynamicBool{
 		KeyName:      "frontend.enableClientVersionCheck",
 		Description:  "EnableClientVersionCheck is enables client version check for frontend",
@@ -3888,6 +4124,21 @@ var BoolKeys = map[BoolKey]DynamicBool{
 		Description:  "HistoryScannerEnabled is indicates if history scanner should be started as part of worker.Scanner",
 		DefaultValue: false,
 	},
+	ScannerDedicatedWorkerEnabled: DynamicBool{
+		KeyName:      "worker.scannerDedicatedWorkerEnabled",
+		Description:  "ScannerDedicatedWorkerEnabled indicates if the task list, history, executions and timers scanners run on workers with the concurrency limits of the dedicated scanner worker pool instead of the limits shared by all scanner workers",
+		DefaultValue: false,
+	},
+	ScannerFindingsTableEnabled: DynamicBool{
+		KeyName:      "worker.scannerFindingsTableEnabled",
+		Description:  "ScannerFindingsTableEnabled indicates if the shard scanners record the corrupted and failed executions they find in the scanner_findings table of the default store in addition to the blobstore, it requires a SQL default store",
+		DefaultValue: false,
+	},
+	ScannerShardScanTimestampsEnabled: DynamicBool{
+		KeyName:      "worker.scannerShardScanTimestampsEnabled",
+		Description:  "ScannerShardScanTimestampsEnabled indicates if the shard scanners record when they last scanned each shard in the scanner_shard_scans table of the default store, it requires a SQL default store",
+		DefaultValue: false,
+	},
 	ConcreteExecutionsScannerEnabled: DynamicBool{
 		KeyName:      "worker.executionsScannerEnabled",
 		Description:  "ConcreteExecutionsScannerEnabled is indicates if executions scanner should be started as part of worker.Scanner",
@@ -3903,6 +4154,26 @@ var BoolKeys = map[BoolKey]DynamicBool{
 		Description:  "ConcreteExecutionsScannerInvariantCollectionHistory is indicates if history invariant checks should be run",
 		DefaultValue: true,
 	},
+	ConcreteExecutionsScannerMapDataDecodeEnabled: DynamicBool{
+		KeyName:      "worker.executionsScannerMapDataDecodeEnabled",
+		Description:  "ConcreteExecutionsScannerMapDataDecodeEnabled indicates if the concrete executions scanner decodes the data blob of every execution map row with its data_encoding and reports the rows which fail to decode",
+		DefaultValue: false,
+	},
+	ConcreteExecutionsScannerMapHistoryCheckEnabled: DynamicBool{
+		KeyName:      "worker.executionsScannerMapHistoryCheckEnabled",
+		Description:  "ConcreteExecutionsScannerMapHistoryCheckEnabled indicates if the concrete executions scanner reconciles the execution map rows with the history events and reports the rows without the event which created them",
+		DefaultValue: false,
+	},
+	ConcreteExecutionsScannerChildExecutionsCheckEnabled: DynamicBool{
+		KeyName:      "worker.executionsScannerChildExecutionsCheckEnabled",
+		Description:  "ConcreteExecutionsScannerChildExecutionsCheckEnabled indicates if the concrete executions scanner looks up the current run of every started child of open executions and reports the children without one",
+		DefaultValue: false,
+	},
+	ConcreteExecutionsScannerMapRowCountCheckEnabled: DynamicBool{
+		KeyName:      "worker.executionsScannerMapRowCountCheckEnabled",
+		Description:  "ConcreteExecutionsScannerMapRowCountCheckEnabled indicates if the concrete executions scanner compares the map row count kept in the executions rows with their execution map rows and reports the executions whose count diverges",
+		DefaultValue: false,
+	},
 	CurrentExecutionsScannerEnabled: DynamicBool{
 		KeyName:      "worker.currentExecutionsScannerEnabled",
 		Description:  "CurrentExecutionsScannerEnabled is indicates if current executions scanner should be started as part of worker.Scanner",
@@ -4262,6 +4533,21 @@ var StringKeys = map[StringKey]DynamicString{
 		Description:  "ESAnalyzerWorkflowDurationWarnThresholds defines the domains we want to emit wf version metrics on",
 		DefaultValue: "",
 	},
+	ConcreteExecutionsScannerExecutionStateFilter: DynamicString{
+		KeyName:      "worker.executionsScannerExecutionStateFilter",
+		Description:  "ConcreteExecutionsScannerExecutionStateFilter restricts the concrete executions scanner to open or closed executions",
+		DefaultValue: "all",
+	},
+	ConcreteExecutionsScannerShardOrder: DynamicString{
+		KeyName:      "worker.executionsScannerShardOrder",
+		Description:  "ConcreteExecutionsScannerShardOrder is the order in which the concrete executions scanner scans the shards, least_recently_scanned requires worker.scannerShardScanTimestampsEnabled",
+		DefaultValue: "sequential",
+	},
+	ConcreteExecutionsScannerMapDataReencodeEncoding: DynamicString{
+		KeyName:      "worker.executionsScannerMapDataReencodeEncoding",
+		Description:  "ConcreteExecutionsScannerMapDataReencodeEncoding is the encoding the execution map rows are migrated to by the concrete executions scanner and fixer, empty disables the re-encode pass",
+		DefaultValue: "",
+	},
 }
 
 var DurationKeys = map[DurationKey]DynamicDuration{
@@ -4651,6 +4937,16 @@ var DurationKeys = map[DurationKey]DynamicDuration{
 		Description:  "ESAnalyzerBufferWaitTime controls min time required to consider a worklow stuck",
 		DefaultValue: time.Minute * 30,
 	},
+	HistoryScannerStallAlertThreshold: DynamicDuration{
+		KeyName:      "worker.historyScannerStallAlertThreshold",
+		Description:  "HistoryScannerStallAlertThreshold is how long the history scavenger can heartbeat without making progress before the history scanner workflow emits the scavenger_stalled metric, 0 disables the alert",
+		DefaultValue: time.Hour,
+	},
+	ScannerFindingsRetention: DynamicDuration{
+		KeyName:      "worker.scannerFindingsRetention",
+		Description:  "ScannerFindingsRetention is how long the findings of the shard scanners are kept in the scanner_findings table, the shard scanner workflows purge the older findings once their scan completes, 0 disables the purge",
+		DefaultValue: time.Hour * 24 * 30,
+	},
 	AsyncTaskDispatchTimeout: DynamicDuration{
 		KeyName:      "matching.asyncTaskDispatchTimeout",
 		Filters:      []Filter{DomainName, TaskListName, TaskType},
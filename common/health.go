@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/types"
+)
+
+// DegradedReasonReadOnly is reported while a host serves reads but not writes, like during a schema migration
+const DegradedReasonReadOnly = "read-only"
+
+type (
+	// HealthDegradationDetector returns why the host is degraded, or an empty string if it is not
+	HealthDegradationDetector func() string
+
+	// HealthDegradation holds why a host is degraded, it is reported as the DegradedReason of the Meta health check.
+	// A degraded host is still healthy, e.g. a read-only host keeps serving reads, so load balancers and clients
+	// can route the requests it cannot serve elsewhere instead of taking it out of rotation.
+	// The reason is either set explicitly or returned by one of the detectors, the explicit reason takes precedence
+	HealthDegradation struct {
+		sync.RWMutex
+		reason    string
+		detectors []HealthDegradationDetector
+	}
+)
+
+// NewHealthDegradation returns a HealthDegradation which reports no degradation
+func NewHealthDegradation() *HealthDegradation {
+	return &HealthDegradation{}
+}
+
+// SetReason sets why the host is degraded, an empty reason clears it
+func (d *HealthDegradation) SetReason(reason string) {
+	d.Lock()
+	defer d.Unlock()
+	d.reason = reason
+}
+
+// AddDetector adds a detector which is called by every health check unless a reason is set explicitly
+func (d *HealthDegradation) AddDetector(detector HealthDegradationDetector) {
+	d.Lock()
+	defer d.Unlock()
+	d.detectors = append(d.detectors, detector)
+}
+
+// Reason returns the explicit reason or else the first reason returned by a detector,
+// an empty string means the host is not degraded. It is safe to call on nil
+func (d *HealthDegradation) Reason() string {
+	if d == nil {
+		return ""
+	}
+	d.RLock()
+	defer d.RUnlock()
+	if d.reason != "" {
+		return d.reason
+	}
+	for _, detector := range d.detectors {
+		if reason := detector(); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// CheckHealth calls check and completes the status it returns with how long the check took and why the host is
+// degraded, the handlers of every transport report their health through it so the status is measured the same way
+func CheckHealth(
+	ctx context.Context,
+	timeSource clock.TimeSource,
+	degradation *HealthDegradation,
+	check func(context.Context) (*types.HealthStatus, error),
+) (*types.HealthStatus, error) {
+	start := timeSource.Now()
+	status, err := check(ctx)
+	if status != nil {
+		status.LatencyInMillis = timeSource.Now().Sub(start).Milliseconds()
+		status.DegradedReason = degradation.Reason()
+	}
+	return status, err
+}
+
+// RequestLoad counts the requests a host is serving, it is reported as the LoadStatus of the Meta service
+type RequestLoad struct {
+	inFlight    int64
+	maxInFlight func() int
+}
+
+// NewRequestLoad creates a RequestLoad, maxInFlight is the number of requests in flight at which the host
+// is saturated, a non-positive value reports no saturation
+func NewRequestLoad(maxInFlight func() int) *RequestLoad {
+	return &RequestLoad{maxInFlight: maxInFlight}
+}
+
+// Start counts a request as in flight until the returned function is called
+func (l *RequestLoad) Start() (done func()) {
+	atomic.AddInt64(&l.inFlight, 1)
+	return func() { atomic.AddInt64(&l.inFlight, -1) }
+}
+
+// Status returns the current load, a nil RequestLoad reports no load
+func (l *RequestLoad) Status() *types.LoadStatus {
+	if l == nil {
+		return &types.LoadStatus{}
+	}
+	status := &types.LoadStatus{InFlightRequests: atomic.LoadInt64(&l.inFlight)}
+	if maxInFlight := l.maxInFlight(); maxInFlight > 0 {
+		status.MaxInFlightRequests = int64(maxInFlight)
+		status.Saturation = float64(status.InFlightRequests) / float64(maxInFlight)
+	}
+	return status
+}
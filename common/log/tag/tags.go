@@ -533,6 +533,31 @@ func ShardRangeID(id int64) Tag {
 	return newInt64("shard-range-id", id)
 }
 
+// NumDBShards returns tag for the number of DB shards of a SQL database
+func NumDBShards(numDBShards int) Tag {
+	return newInt("num-db-shards", numDBShards)
+}
+
+// RecordedNumDBShards returns tag for the number of DB shards recorded in a SQL database
+func RecordedNumDBShards(numDBShards int) Tag {
+	return newInt("recorded-num-db-shards", numDBShards)
+}
+
+// DBShardID returns tag for the DB shard of a SQL database
+func DBShardID(dbShardID int) Tag {
+	return newInt("db-shard-id", dbShardID)
+}
+
+// SQLQuery returns tag for the query of a SQL statement
+func SQLQuery(query string) Tag {
+	return newStringTag("sql-query", query)
+}
+
+// SQLQueryArgs returns tag for the summary of the parameters of a SQL statement
+func SQLQueryArgs(args []string) Tag {
+	return newObjectTag("sql-query-args", args)
+}
+
 // ReadLevel returns tag for ReadLevel
 func ReadLevel(lv int64) Tag {
 	return newInt64("read-level", lv)
@@ -745,6 +745,12 @@ const (
 	ClusterMetadataScope
 	// GetAvailableIsolationGroupsScope is the metric for the default partitioner's getIsolationGroups operation
 	GetAvailableIsolationGroupsScope
+	// PersistenceExecutionMapWriteScope tracks the rows written to the execution map tables by SQL plugins
+	PersistenceExecutionMapWriteScope
+	// PersistenceExecutionMapOperationScope tracks the operations on the execution map tables by SQL plugins
+	PersistenceExecutionMapOperationScope
+	// PersistenceDBShardsScope tracks the checks of the number of DB shards of SQL plugins
+	PersistenceDBShardsScope
 
 	NumCommonScopes
 )
@@ -1255,6 +1261,8 @@ const (
 	CheckDataCorruptionWorkflowScope
 	// ESAnalyzerScope is scope used by ElasticSearch Analyzer (esanalyzer) workflow
 	ESAnalyzerScope
+	// LargestExecutionMapsScope is scope used by the largest execution maps scanner activity
+	LargestExecutionMapsScope
 
 	NumWorkerScopes
 )
@@ -1565,7 +1573,10 @@ var ScopeDefs = map[ServiceIdx]map[int]scopeDefinition{
 		BlobstoreClientDeleteScope:          {operation: "BlobstoreClientDelete", tags: map[string]string{CadenceRoleTagName: BlobstoreRoleTagValue}},
 		BlobstoreClientDirectoryExistsScope: {operation: "BlobstoreClientDirectoryExists", tags: map[string]string{CadenceRoleTagName: BlobstoreRoleTagValue}},
 
-		GetAvailableIsolationGroupsScope: {operation: "GetAvailableIsolationGroups"},
+		GetAvailableIsolationGroupsScope:      {operation: "GetAvailableIsolationGroups"},
+		PersistenceExecutionMapWriteScope:     {operation: "ExecutionMapWrite"},
+		PersistenceExecutionMapOperationScope: {operation: "ExecutionMapOperation"},
+		PersistenceDBShardsScope:              {operation: "DBShards"},
 
 		DomainFailoverScope:         {operation: "DomainFailover"},
 		DomainReplicationQueueScope: {operation: "DomainReplicationQueue"},
@@ -1824,6 +1835,7 @@ var ScopeDefs = map[ServiceIdx]map[int]scopeDefinition{
 		BatcherScope:                           {operation: "batcher"},
 		ParentClosePolicyProcessorScope:        {operation: "ParentClosePolicyProcessor"},
 		ESAnalyzerScope:                        {operation: "ESAnalyzer"},
+		LargestExecutionMapsScope:              {operation: "LargestExecutionMaps"},
 	},
 }
 
@@ -1899,6 +1911,16 @@ const (
 	PersistenceSampledCounterPerDomain
 	PersistenceEmptyResponseCounterPerDomain
 
+	PersistenceExecutionMapRowsWritten
+	PersistenceExecutionMapLatency
+	PersistenceExecutionMapFailures
+	PersistenceExecutionMapRowCount
+	PersistenceExecutionMapInFlight
+	PersistenceExecutionMapRows
+	PersistenceNumDBShardsMismatch
+	PersistenceStaleTimerInfoMapsRows
+	PersistenceMapRetryBudgetExhausted
+
 	CadenceClientRequests
 	CadenceClientFailures
 	CadenceClientLatency
@@ -2392,6 +2414,7 @@ const (
 	HistoryScavengerSuccessCount
 	HistoryScavengerErrorCount
 	HistoryScavengerSkipCount
+	HistoryScavengerStalledCount
 	DomainReplicationEnqueueDLQCount
 	ScannerExecutionsGauge
 	ScannerCorruptedGauge
@@ -2415,6 +2438,8 @@ const (
 	ESAnalyzerNumStuckWorkflowsRefreshed
 	ESAnalyzerNumStuckWorkflowsFailedToRefresh
 	ESAnalyzerNumLongRunningWorkflows
+	LargestExecutionMapsDataSizeGauge
+	LargestExecutionMapsRowCountGauge
 
 	NumWorkerMetrics
 )
@@ -2490,6 +2515,15 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		PersistenceErrDBUnavailableCounterPerDomain:                  {metricName: "persistence_errors_db_unavailable_per_domain", metricRollupName: "persistence_errors_db_unavailable", metricType: Counter},
 		PersistenceSampledCounterPerDomain:                           {metricName: "persistence_sampled_per_domain", metricRollupName: "persistence_sampled", metricType: Counter},
 		PersistenceEmptyResponseCounterPerDomain:                     {metricName: "persistence_empty_response_per_domain", metricRollupName: "persistence_empty_response", metricType: Counter},
+		PersistenceExecutionMapRowsWritten:                           {metricName: "persistence_execution_map_rows_written", metricType: Counter},
+		PersistenceExecutionMapLatency:                               {metricName: "persistence_execution_map_latency", metricType: Timer},
+		PersistenceExecutionMapFailures:                              {metricName: "persistence_execution_map_failures", metricType: Counter},
+		PersistenceExecutionMapRowCount:                              {metricName: "persistence_execution_map_row_count", metricType: Timer},
+		PersistenceExecutionMapInFlight:                              {metricName: "persistence_execution_map_in_flight", metricType: Gauge},
+		PersistenceExecutionMapRows:                                  {metricName: "persistence_execution_map_rows", metricType: Gauge},
+		PersistenceNumDBShardsMismatch:                               {metricName: "persistence_num_db_shards_mismatch", metricType: Counter},
+		PersistenceStaleTimerInfoMapsRows:                            {metricName: "persistence_stale_timer_info_maps_rows", metricType: Counter},
+		PersistenceMapRetryBudgetExhausted:                           {metricName: "persistence_map_retry_budget_exhausted", metricType: Counter},
 		CadenceClientRequests:                                        {metricName: "cadence_client_requests", metricType: Counter},
 		CadenceClientFailures:                                        {metricName: "cadence_client_errors", metricType: Counter},
 		CadenceClientLatency:                                         {metricName: "cadence_client_latency", metricType: Timer},
@@ -2990,6 +3024,7 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		HistoryScavengerSuccessCount:                  {metricName: "scavenger_success", metricType: Counter},
 		HistoryScavengerErrorCount:                    {metricName: "scavenger_errors", metricType: Counter},
 		HistoryScavengerSkipCount:                     {metricName: "scavenger_skips", metricType: Counter},
+		HistoryScavengerStalledCount:                  {metricName: "scavenger_stalled", metricType: Counter},
 		DomainReplicationEnqueueDLQCount:              {metricName: "domain_replication_dlq_enqueue_requests", metricType: Counter},
 		ScannerExecutionsGauge:                        {metricName: "scanner_executions", metricType: Gauge},
 		ScannerCorruptedGauge:                         {metricName: "scanner_corrupted", metricType: Gauge},
@@ -3013,6 +3048,8 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		ESAnalyzerNumStuckWorkflowsRefreshed:          {metricName: "es_analyzer_num_stuck_workflows_refreshed", metricType: Counter},
 		ESAnalyzerNumStuckWorkflowsFailedToRefresh:    {metricName: "es_analyzer_num_stuck_workflows_failed_to_refresh", metricType: Counter},
 		ESAnalyzerNumLongRunningWorkflows:             {metricName: "es_analyzer_num_long_running_workflows", metricType: Counter},
+		LargestExecutionMapsDataSizeGauge:             {metricName: "largest_execution_maps_data_size", metricType: Gauge},
+		LargestExecutionMapsRowCountGauge:             {metricName: "largest_execution_maps_row_count", metricType: Gauge},
 	},
 }
 
@@ -56,6 +56,10 @@ const (
 	shardID                = "shard_id"
 	matchingHost           = "matching_host"
 	pollerIsolationGroup   = "poller_isolation_group"
+	tableName              = "table"
+	writeType              = "write_type"
+	writeCause             = "write_cause"
+	dbOperation            = "db_operation"
 
 	allValue     = "all"
 	unknownValue = "_unknown_"
@@ -224,6 +228,26 @@ func PollerIsolationGroupTag(value string) Tag {
 	return metricWithUnknown(pollerIsolationGroup, value)
 }
 
+// TableTag returns a new database table tag
+func TableTag(value string) Tag {
+	return metricWithUnknown(tableName, value)
+}
+
+// WriteTypeTag returns a new tag for the kind of a database write, e.g. insert or update
+func WriteTypeTag(value string) Tag {
+	return metricWithUnknown(writeType, value)
+}
+
+// WriteCauseTag returns a new tag for the cause of a database write, e.g. heartbeat
+func WriteCauseTag(value string) Tag {
+	return metricWithUnknown(writeCause, value)
+}
+
+// DBOperationTag returns a new tag for the database operation of a plugin, e.g. ReplaceIntoTimerInfoMaps
+func DBOperationTag(value string) Tag {
+	return metricWithUnknown(dbOperation, value)
+}
+
 // PartitionConfigTags returns a list of partition config tags
 func PartitionConfigTags(partitionConfig map[string]string) []Tag {
 	tags := make([]Tag, 0, len(partitionConfig))
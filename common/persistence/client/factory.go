@@ -433,7 +433,8 @@ func (f *factoryImpl) init(clusterName string, limiters map[string]quotas.Limite
 			clusterName,
 			f.logger,
 			getSQLParser(f.logger, common.EncodingType(defaultCfg.SQL.EncodingType), decodingTypes...),
-			f.dc)
+			f.dc,
+			f.metricsClient)
 	default:
 		f.logger.Fatal("invalid config: one of nosql or sql params must be specified for defaultDataStore")
 	}
@@ -469,7 +470,8 @@ func (f *factoryImpl) init(clusterName string, limiters map[string]quotas.Limite
 			clusterName,
 			f.logger,
 			getSQLParser(f.logger, common.EncodingType(visibilityCfg.SQL.EncodingType), decodingTypes...),
-			f.dc)
+			f.dc,
+			f.metricsClient)
 	default:
 		f.logger.Fatal("invalid config: one of nosql or sql params must be specified for visibilityStore")
 	}
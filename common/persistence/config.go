@@ -32,6 +32,7 @@ type (
 		PersistenceSampleLoggingRate             dynamicconfig.IntPropertyFn
 		EnableShardIDMetrics                     dynamicconfig.BoolPropertyFn
 		EnableExecutionTTL                       dynamicconfig.BoolPropertyFnWithDomainIDFilter
+		SQLMapWriteMaintenance                   dynamicconfig.BoolPropertyFn
 	}
 )
 
@@ -43,5 +44,6 @@ func NewDynamicConfiguration(dc *dynamicconfig.Collection) *DynamicConfiguration
 		PersistenceSampleLoggingRate:             dc.GetIntProperty(dynamicconfig.SampleLoggingRate),
 		EnableShardIDMetrics:                     dc.GetBoolProperty(dynamicconfig.EnableShardIDMetrics),
 		EnableExecutionTTL:                       dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.EnableExecutionTTL),
+		SQLMapWriteMaintenance:                   dc.GetBoolProperty(dynamicconfig.SQLMapWriteMaintenance),
 	}
 }
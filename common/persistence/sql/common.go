@@ -59,7 +59,7 @@ func (m *sqlStore) useAsyncTransaction() bool {
 }
 
 func (m *sqlStore) txExecute(ctx context.Context, dbShardID int, operation string, f func(tx sqlplugin.Tx) error) error {
-	tx, err := m.db.BeginTx(ctx, dbShardID)
+	tx, err := m.db.BeginTx(sqlplugin.WithOperationClass(ctx, operation), dbShardID)
 	if err != nil {
 		return convertCommonErrors(m.db, operation, "Failed to start transaction.", err)
 	}
@@ -129,6 +129,7 @@ func convertCommonErrors(
 		*types.DomainAlreadyExistsError,
 		*types.EntityNotExistsError,
 		*types.ServiceBusyError,
+		*types.LimitExceededError,
 		*types.InternalServiceError:
 		return err
 	}
@@ -21,17 +21,24 @@
 package sql
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/uber/cadence/common/persistence/serialization"
 
 	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
 	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 )
 
+// numDBShardsCheckTimeout bounds the check of the number of DB shards when the connection is created
+const numDBShardsCheckTimeout = 10 * time.Second
+
 type (
 	// Factory vends store objects backed by MySQL
 	Factory struct {
@@ -49,8 +56,12 @@ type (
 	dbConn struct {
 		sync.Mutex
 		sqlplugin.DB
-		refCnt int
-		cfg    *config.SQL
+		refCnt        int
+		cfg           *config.SQL
+		metricsClient metrics.Client
+		logger        log.Logger
+		// mapWriteMaintenance is nil unless the factory was given a dynamic configuration
+		mapWriteMaintenance func() bool
 	}
 )
 
@@ -62,12 +73,13 @@ func NewFactory(
 	logger log.Logger,
 	parser serialization.Parser,
 	dc *p.DynamicConfiguration,
+	metricsClient metrics.Client,
 ) *Factory {
 	return &Factory{
 		cfg:         cfg,
 		clusterName: clusterName,
 		logger:      logger,
-		dbConn:      newRefCountedDBConn(&cfg),
+		dbConn:      newRefCountedDBConn(&cfg, metricsClient, logger, dc),
 		parser:      parser,
 		dc:          dc,
 	}
@@ -151,9 +163,15 @@ func (f *Factory) Close() {
 // newRefCountedDBConn returns a  logical mysql connection that
 // uses reference counting to decide when to close the
 // underlying connection object. The reference count gets incremented
-// everytime get() is called and decremented everytime Close() is called
-func newRefCountedDBConn(cfg *config.SQL) dbConn {
-	return dbConn{cfg: cfg}
+// everytime get() is called and decremented everytime Close() is called.
+// metricsClient and logger are handed to plugins which emit metrics or log of their own, they can be nil.
+// The map write maintenance switch of dc is handed to plugins which can pause map writes, dc can be nil
+func newRefCountedDBConn(cfg *config.SQL, metricsClient metrics.Client, logger log.Logger, dc *p.DynamicConfiguration) dbConn {
+	var mapWriteMaintenance func() bool
+	if dc != nil && dc.SQLMapWriteMaintenance != nil {
+		mapWriteMaintenance = func() bool { return dc.SQLMapWriteMaintenance() }
+	}
+	return dbConn{cfg: cfg, metricsClient: metricsClient, logger: logger, mapWriteMaintenance: mapWriteMaintenance}
 }
 
 // get returns a mysql db connection and increments a reference count
@@ -167,12 +185,50 @@ func (c *dbConn) get() (sqlplugin.DB, error) {
 		if err != nil {
 			return nil, err
 		}
+		if emitter, ok := conn.(sqlplugin.MetricsEmitter); ok && c.metricsClient != nil {
+			emitter.SetMetricsClient(c.metricsClient)
+		}
+		if emitter, ok := conn.(sqlplugin.LogEmitter); ok && c.logger != nil {
+			emitter.SetLogger(c.logger)
+		}
+		if controller, ok := conn.(sqlplugin.MapWriteMaintenanceController); ok && c.mapWriteMaintenance != nil {
+			controller.SetMapWriteMaintenance(c.mapWriteMaintenance)
+		}
+		if recorder, ok := conn.(sqlplugin.NumDBShardsRecorder); ok {
+			c.checkNumDBShards(recorder, conn.GetTotalNumDBShards())
+		}
 		c.DB = conn
 	}
 	c.refCnt++
 	return c, nil
 }
 
+// checkNumDBShards compares the number of DB shards in the config with the number recorded in the database.
+// Executions are routed to a DB shard by the number of DB shards, so after a change the rows written before are read
+// from the wrong DB shard. A mismatch is only reported, the data cannot be moved here
+func (c *dbConn) checkNumDBShards(recorder sqlplugin.NumDBShardsRecorder, numDBShards int) {
+	ctx, cancel := context.WithTimeout(context.Background(), numDBShardsCheckTimeout)
+	defer cancel()
+	recorded, err := recorder.RecordNumDBShards(ctx)
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Warn("Failed to check the number of DB shards recorded in the database", tag.Error(err))
+		}
+		return
+	}
+	if recorded == numDBShards {
+		return
+	}
+	if c.metricsClient != nil {
+		c.metricsClient.IncCounter(metrics.PersistenceDBShardsScope, metrics.PersistenceNumDBShardsMismatch)
+	}
+	if c.logger != nil {
+		c.logger.Warn("The number of DB shards differs from the number recorded in the database, "+
+			"executions written before the change are routed to other DB shards",
+			tag.NumDBShards(numDBShards), tag.RecordedNumDBShards(recorded))
+	}
+}
+
 // forceClose ignores reference counts and shutsdown the underlying connection pool
 func (c *dbConn) forceClose() {
 	c.Lock()
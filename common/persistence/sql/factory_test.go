@@ -0,0 +1,69 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/metrics"
+)
+
+type fakeNumDBShardsRecorder struct {
+	recorded int
+	err      error
+}
+
+func (r *fakeNumDBShardsRecorder) RecordNumDBShards(ctx context.Context) (int, error) {
+	return r.recorded, r.err
+}
+
+func TestCheckNumDBShards(t *testing.T) {
+	tests := map[string]struct {
+		recorder   *fakeNumDBShardsRecorder
+		mismatches int64
+	}{
+		"same number":           {recorder: &fakeNumDBShardsRecorder{recorded: 4}},
+		"number changed":        {recorder: &fakeNumDBShardsRecorder{recorded: 2}, mismatches: 1},
+		"number cannot be read": {recorder: &fakeNumDBShardsRecorder{err: errors.New("relation does not exist")}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			scope := tally.NewTestScope("", nil)
+			c := &dbConn{metricsClient: metrics.NewClient(scope, metrics.History), logger: log.NewNoop()}
+			c.checkNumDBShards(test.recorder, 4)
+			var mismatches int64
+			for _, counter := range scope.Snapshot().Counters() {
+				if counter.Name() == "persistence_num_db_shards_mismatch" {
+					mismatches += counter.Value()
+				}
+			}
+			assert.Equal(t, test.mismatches, mismatches)
+		})
+	}
+}
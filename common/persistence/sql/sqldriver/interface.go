@@ -18,6 +18,8 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+//go:generate mockgen -package $GOPACKAGE -source $GOFILE -destination interface_mock.go -self_package github.com/uber/cadence/common/persistence/sql/sqldriver
+
 package sqldriver
 
 import (
@@ -44,6 +46,8 @@ type (
 		Rollback() error
 		// Close closes this driver(and underlying connections)
 		Close() error
+		// PingContext verifies the connection to the shard of dbShardID is still alive
+		PingContext(ctx context.Context, dbShardID int) error
 
 		// ExecDDL executes a DDL query
 		ExecDDL(ctx context.Context, dbShardID int, query string, args ...interface{}) (sql.Result, error)
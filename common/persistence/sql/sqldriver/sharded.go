@@ -138,6 +138,13 @@ func (s *sharded) BeginTxx(ctx context.Context, dbShardID int, opts *sql.TxOptio
 	return s.dbs[dbShardID].BeginTxx(ctx, opts)
 }
 
+func (s *sharded) PingContext(ctx context.Context, dbShardID int) error {
+	if dbShardID == sqlplugin.DbShardUndefined || dbShardID == sqlplugin.DbAllShards {
+		return fmt.Errorf("invalid dbShardID %v shouldn't be used to PingContext, there must be a bug", dbShardID)
+	}
+	return s.dbs[dbShardID].PingContext(ctx)
+}
+
 func (s *sharded) Close() error {
 	var errs []error
 	for _, db := range s.dbs {
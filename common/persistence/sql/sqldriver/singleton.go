@@ -98,6 +98,10 @@ func (s *singleton) BeginTxx(ctx context.Context, _ int, opts *sql.TxOptions) (*
 	return s.db.BeginTxx(ctx, opts)
 }
 
+func (s *singleton) PingContext(ctx context.Context, _ int) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *singleton) Close() error {
 	return s.db.Close()
 }
@@ -21,6 +21,8 @@
 package sqlplugin
 
 import (
+	"context"
+
 	"github.com/dgryski/go-farm"
 
 	"github.com/uber/cadence/common/persistence/serialization"
@@ -36,6 +38,21 @@ const (
 	DbAllShards = -2
 )
 
+type dbShardOverrideContextKey struct{}
+
+// WithDBShardOverride returns a context carrying a dbShardID the plugins route the operations on the execution map
+// tables to, instead of the dbShardID of the history shard. It is meant for tests which pin the routing,
+// the plugins ignore it unless the override is enabled in config
+func WithDBShardOverride(ctx context.Context, dbShardID int) context.Context {
+	return context.WithValue(ctx, dbShardOverrideContextKey{}, dbShardID)
+}
+
+// DBShardOverrideFromContext returns the dbShardID set with WithDBShardOverride
+func DBShardOverrideFromContext(ctx context.Context) (int, bool) {
+	dbShardID, ok := ctx.Value(dbShardOverrideContextKey{}).(int)
+	return dbShardID, ok
+}
+
 // GetDBShardIDFromHistoryShardID maps  historyShardID to a DBShardID
 func GetDBShardIDFromHistoryShardID(historyShardID int, numDBShards int) int {
 	return historyShardID % numDBShards
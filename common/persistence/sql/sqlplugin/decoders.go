@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+)
+
+// ErrDecoderNotFound is returned by DecoderRegistry when no decoder is registered for a table and data_encoding
+var ErrDecoderNotFound = errors.New("decoder not found")
+
+type (
+	// DecodeFunc decodes the data column of a row of an execution map table
+	DecodeFunc func(data []byte) (interface{}, error)
+
+	// DecoderRegistry maps the table and data_encoding of the rows of the execution map tables to the function
+	// decoding their data column. It allows tools to show the content of the rows without knowing the encodings
+	DecoderRegistry struct {
+		sync.RWMutex
+		decoders map[decoderKey]DecodeFunc
+	}
+
+	decoderKey struct {
+		table    string
+		encoding string
+	}
+
+	// DecodedActivityInfoRow is an ActivityInfoMapsRow with its data column decoded
+	DecodedActivityInfoRow struct {
+		ActivityInfoMapsRow
+		// Info is an *serialization.ActivityInfo for the built-in encodings,
+		// or whatever the decoder registered for the encoding of the row returns
+		Info interface{}
+	}
+)
+
+// NewDecoderRegistry returns a registry with decoders for the thriftrw encoding of activity_info_maps, timer_info_maps, child_execution_info_maps, request_cancel_info_maps and signal_info_maps
+func NewDecoderRegistry() (*DecoderRegistry, error) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	if err != nil {
+		return nil, err
+	}
+	r := &DecoderRegistry{decoders: make(map[decoderKey]DecodeFunc)}
+	encoding := string(common.EncodingTypeThriftRW)
+	r.Register("activity_info_maps", encoding, func(data []byte) (interface{}, error) {
+		return parser.ActivityInfoFromBlob(data, encoding)
+	})
+	r.Register("timer_info_maps", encoding, func(data []byte) (interface{}, error) {
+		return parser.TimerInfoFromBlob(data, encoding)
+	})
+	r.Register("child_execution_info_maps", encoding, func(data []byte) (interface{}, error) {
+		return parser.ChildExecutionInfoFromBlob(data, encoding)
+	})
+	r.Register("request_cancel_info_maps", encoding, func(data []byte) (interface{}, error) {
+		return parser.RequestCancelInfoFromBlob(data, encoding)
+	})
+	r.Register("signal_info_maps", encoding, func(data []byte) (interface{}, error) {
+		return parser.SignalInfoFromBlob(data, encoding)
+	})
+	return r, nil
+}
+
+// Register sets the decoder of the data column of table for encoding, replacing the decoder registered before
+func (r *DecoderRegistry) Register(table string, encoding string, decode DecodeFunc) {
+	r.Lock()
+	defer r.Unlock()
+	r.decoders[decoderKey{table: table, encoding: encoding}] = decode
+}
+
+// Decode decodes the data column of a row of table, it returns ErrDecoderNotFound if encoding is not registered for table
+func (r *DecoderRegistry) Decode(table string, encoding string, data []byte) (interface{}, error) {
+	r.RLock()
+	decode, ok := r.decoders[decoderKey{table: table, encoding: encoding}]
+	r.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w for %v with encoding %q", ErrDecoderNotFound, table, encoding)
+	}
+	return decode(data)
+}
+
+// DecodeActivityInfoRow decodes the data column of a row of activity_info_maps
+func (r *DecoderRegistry) DecodeActivityInfoRow(row ActivityInfoMapsRow) (*DecodedActivityInfoRow, error) {
+	info, err := r.Decode("activity_info_maps", row.DataEncoding, row.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedActivityInfoRow{ActivityInfoMapsRow: row, Info: info}, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+)
+
+func TestDecoderRegistry(t *testing.T) {
+	registry, err := NewDecoderRegistry()
+	require.NoError(t, err)
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	blob, err := parser.ActivityInfoToBlob(&serialization.ActivityInfo{Version: 7})
+	require.NoError(t, err)
+
+	decoded, err := registry.DecodeActivityInfoRow(ActivityInfoMapsRow{ScheduleID: 5, Data: blob.Data, DataEncoding: string(blob.Encoding)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), decoded.ScheduleID)
+	assert.Equal(t, int64(7), decoded.Info.(*serialization.ActivityInfo).GetVersion())
+
+	_, err = registry.Decode("timer_info_maps", "json", []byte("{}"))
+	assert.True(t, errors.Is(err, ErrDecoderNotFound), err)
+
+	registry.Register("timer_info_maps", "json", func(data []byte) (interface{}, error) {
+		return string(data), nil
+	})
+	info, err := registry.Decode("timer_info_maps", "json", []byte("{}"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}", info)
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"context"
+)
+
+type relaxedDurabilityContextKey struct{}
+
+// WithRelaxedDurability returns a context marking the writes done with it as durability-relaxed. It trades durability
+// for speed and is only meant for bulk writes which can be redone, like re-encoding migrations: the plugins which
+// support it, like postgres with synchronous_commit, acknowledge the commits of the transactions started with the
+// context before they are flushed to disk, and run the marked map writes done outside of a transaction within one of
+// their own. A crash of the database can lose the writes which were acknowledged last. The database stays consistent, the lost transactions are lost as a whole. Writes done without
+// the mark keep full durability
+func WithRelaxedDurability(ctx context.Context) context.Context {
+	return context.WithValue(ctx, relaxedDurabilityContextKey{}, true)
+}
+
+// RelaxedDurabilityFromContext returns true if ctx was marked with WithRelaxedDurability
+func RelaxedDurabilityFromContext(ctx context.Context) bool {
+	relaxed, _ := ctx.Value(relaxedDurabilityContextKey{}).(bool)
+	return relaxed
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+)
+
+type (
+	// ExecutionMapsDumpOptions are the options of DumpExecutionMaps
+	ExecutionMapsDumpOptions struct {
+		// RedactPayloads leaves out the payloads the users of the workflow control, like the events of the activities and
+		// child executions, the inputs of the signals and the heartbeat details. The info decoded by a decoder registered
+		// for another encoding is left out as a whole, as are the blobs which could not be decoded
+		RedactPayloads bool
+	}
+
+	// ExecutionMapsDump is the content of the execution map tables of an execution, it is meant to be marshaled to JSON
+	ExecutionMapsDump struct {
+		ShardID             int            `json:"shardID"`
+		DomainID            string         `json:"domainID"`
+		WorkflowID          string         `json:"workflowID"`
+		RunID               string         `json:"runID"`
+		ActivityInfos       []DumpedMapRow `json:"activityInfos"`
+		TimerInfos          []DumpedMapRow `json:"timerInfos"`
+		ChildExecutionInfos []DumpedMapRow `json:"childExecutionInfos"`
+		RequestCancelInfos  []DumpedMapRow `json:"requestCancelInfos"`
+		SignalInfos         []DumpedMapRow `json:"signalInfos"`
+		SignalsRequested    []string       `json:"signalsRequested"`
+	}
+
+	// DumpedMapRow is a row of an execution map table with its data column decoded by a DecoderRegistry
+	DumpedMapRow struct {
+		// Key is the schedule ID, timer ID or initiated ID of the row
+		Key          string `json:"key"`
+		DataEncoding string `json:"dataEncoding"`
+		// Info is the decoded data column, it is nil if the data could not be decoded or is redacted
+		Info interface{} `json:"info,omitempty"`
+		// DecodeError is the reason the data could not be decoded, Data then holds the blob unless it is redacted
+		DecodeError string `json:"decodeError,omitempty"`
+		Data        []byte `json:"data,omitempty"`
+		// LastHeartbeatDetails and LastHeartbeatUpdatedTime are only set for the rows of activity_info_maps
+		LastHeartbeatDetails     []byte     `json:"lastHeartbeatDetails,omitempty"`
+		LastHeartbeatUpdatedTime *time.Time `json:"lastHeartbeatUpdatedTime,omitempty"`
+		// Redacted is true if some of the content of the row was left out by ExecutionMapsDumpOptions.RedactPayloads
+		Redacted bool `json:"redacted,omitempty"`
+	}
+)
+
+// DumpExecutionMaps reads the rows of the execution in key from every execution map table and signals_requested_sets,
+// and decodes their data columns with registry. It only reads, a row which can not be decoded is dumped with the
+// reason instead of failing the dump. key.Size is ignored
+func DumpExecutionMaps(ctx context.Context, db DB, registry *DecoderRegistry, key *ExecutionsFilter, opts ExecutionMapsDumpOptions) (*ExecutionMapsDump, error) {
+	shardID := int64(key.ShardID)
+	dump := &ExecutionMapsDump{
+		ShardID:    key.ShardID,
+		DomainID:   key.DomainID.String(),
+		WorkflowID: key.WorkflowID,
+		RunID:      key.RunID.String(),
+	}
+	d := &mapRowDumper{registry: registry, opts: opts}
+
+	activityRows, err := db.SelectFromActivityInfoMaps(ctx, &ActivityInfoMapsFilter{ShardID: shardID, DomainID: key.DomainID, WorkflowID: key.WorkflowID, RunID: key.RunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read activity_info_maps: %w", err)
+	}
+	dump.ActivityInfos = make([]DumpedMapRow, 0, len(activityRows))
+	for _, row := range activityRows {
+		dumped := d.dump("activity_info_maps", strconv.FormatInt(row.ScheduleID, 10), row.DataEncoding, row.Data)
+		if !row.LastHeartbeatUpdatedTime.IsZero() {
+			updated := row.LastHeartbeatUpdatedTime
+			dumped.LastHeartbeatUpdatedTime = &updated
+		}
+		if len(row.LastHeartbeatDetails) > 0 {
+			if opts.RedactPayloads {
+				dumped.Redacted = true
+			} else {
+				dumped.LastHeartbeatDetails = row.LastHeartbeatDetails
+			}
+		}
+		dump.ActivityInfos = append(dump.ActivityInfos, dumped)
+	}
+
+	timerRows, err := db.SelectFromTimerInfoMaps(ctx, &TimerInfoMapsFilter{ShardID: shardID, DomainID: key.DomainID, WorkflowID: key.WorkflowID, RunID: key.RunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timer_info_maps: %w", err)
+	}
+	dump.TimerInfos = make([]DumpedMapRow, 0, len(timerRows))
+	for _, row := range timerRows {
+		dump.TimerInfos = append(dump.TimerInfos, d.dump("timer_info_maps", row.TimerID, row.DataEncoding, row.Data))
+	}
+
+	childRows, err := db.SelectFromChildExecutionInfoMaps(ctx, &ChildExecutionInfoMapsFilter{ShardID: shardID, DomainID: key.DomainID, WorkflowID: key.WorkflowID, RunID: key.RunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read child_execution_info_maps: %w", err)
+	}
+	dump.ChildExecutionInfos = make([]DumpedMapRow, 0, len(childRows))
+	for _, row := range childRows {
+		dump.ChildExecutionInfos = append(dump.ChildExecutionInfos, d.dump("child_execution_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, row.Data))
+	}
+
+	cancelRows, err := db.SelectFromRequestCancelInfoMaps(ctx, &RequestCancelInfoMapsFilter{ShardID: shardID, DomainID: key.DomainID, WorkflowID: key.WorkflowID, RunID: key.RunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request_cancel_info_maps: %w", err)
+	}
+	dump.RequestCancelInfos = make([]DumpedMapRow, 0, len(cancelRows))
+	for _, row := range cancelRows {
+		dump.RequestCancelInfos = append(dump.RequestCancelInfos, d.dump("request_cancel_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, row.Data))
+	}
+
+	signalRows, err := db.SelectFromSignalInfoMaps(ctx, &SignalInfoMapsFilter{ShardID: shardID, DomainID: key.DomainID, WorkflowID: key.WorkflowID, RunID: key.RunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signal_info_maps: %w", err)
+	}
+	dump.SignalInfos = make([]DumpedMapRow, 0, len(signalRows))
+	for _, row := range signalRows {
+		dump.SignalInfos = append(dump.SignalInfos, d.dump("signal_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, row.Data))
+	}
+
+	signalsRequestedRows, err := db.SelectFromSignalsRequestedSets(ctx, &SignalsRequestedSetsFilter{ShardID: shardID, DomainID: key.DomainID, WorkflowID: key.WorkflowID, RunID: key.RunID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signals_requested_sets: %w", err)
+	}
+	dump.SignalsRequested = make([]string, 0, len(signalsRequestedRows))
+	for _, row := range signalsRequestedRows {
+		dump.SignalsRequested = append(dump.SignalsRequested, row.SignalID)
+	}
+	return dump, nil
+}
+
+type mapRowDumper struct {
+	registry *DecoderRegistry
+	opts     ExecutionMapsDumpOptions
+}
+
+func (d *mapRowDumper) dump(table string, key string, encoding string, data []byte) DumpedMapRow {
+	row := DumpedMapRow{Key: key, DataEncoding: encoding}
+	info, err := d.registry.Decode(table, encoding, data)
+	if err != nil {
+		row.DecodeError = err.Error()
+		if d.opts.RedactPayloads {
+			row.Redacted = true
+		} else {
+			row.Data = data
+		}
+		return row
+	}
+	if d.opts.RedactPayloads {
+		info, row.Redacted = redactPayloads(info)
+	}
+	row.Info = info
+	return row
+}
+
+// redactPayloads returns a copy of the decoded info of a row without the payloads of the users, and whether anything
+// was left out. Only the types of the built-in decoders are known, any other info is left out as a whole
+func redactPayloads(info interface{}) (interface{}, bool) {
+	switch info := info.(type) {
+	case *serialization.ActivityInfo:
+		redacted := *info
+		redacted.ScheduledEvent = nil
+		redacted.StartedEvent = nil
+		redacted.RetryLastFailureDetails = nil
+		return &redacted, len(info.ScheduledEvent)+len(info.StartedEvent)+len(info.RetryLastFailureDetails) > 0
+	case *serialization.ChildExecutionInfo:
+		redacted := *info
+		redacted.InitiatedEvent = nil
+		redacted.StartedEvent = nil
+		return &redacted, len(info.InitiatedEvent)+len(info.StartedEvent) > 0
+	case *serialization.SignalInfo:
+		redacted := *info
+		redacted.Input = nil
+		redacted.Control = nil
+		return &redacted, len(info.Input)+len(info.Control) > 0
+	case *serialization.TimerInfo, *serialization.RequestCancelInfo:
+		return info, false
+	default:
+		return nil, true
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+)
+
+// dumpDB answers the selects of DumpExecutionMaps with fixed rows, every other method panics
+type dumpDB struct {
+	DB
+	activities      []ActivityInfoMapsRow
+	signals         []SignalInfoMapsRow
+	signalRequested []SignalsRequestedSetsRow
+}
+
+func (d *dumpDB) SelectFromActivityInfoMaps(ctx context.Context, filter *ActivityInfoMapsFilter) ([]ActivityInfoMapsRow, error) {
+	return d.activities, nil
+}
+
+func (d *dumpDB) SelectFromTimerInfoMaps(ctx context.Context, filter *TimerInfoMapsFilter) ([]TimerInfoMapsRow, error) {
+	return nil, nil
+}
+
+func (d *dumpDB) SelectFromChildExecutionInfoMaps(ctx context.Context, filter *ChildExecutionInfoMapsFilter) ([]ChildExecutionInfoMapsRow, error) {
+	return nil, nil
+}
+
+func (d *dumpDB) SelectFromRequestCancelInfoMaps(ctx context.Context, filter *RequestCancelInfoMapsFilter) ([]RequestCancelInfoMapsRow, error) {
+	return nil, nil
+}
+
+func (d *dumpDB) SelectFromSignalInfoMaps(ctx context.Context, filter *SignalInfoMapsFilter) ([]SignalInfoMapsRow, error) {
+	return d.signals, nil
+}
+
+func (d *dumpDB) SelectFromSignalsRequestedSets(ctx context.Context, filter *SignalsRequestedSetsFilter) ([]SignalsRequestedSetsRow, error) {
+	return d.signalRequested, nil
+}
+
+func TestDumpExecutionMaps(t *testing.T) {
+	registry, err := NewDecoderRegistry()
+	require.NoError(t, err)
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	activity, err := parser.ActivityInfoToBlob(&serialization.ActivityInfo{Version: 3, ScheduledEvent: []byte("scheduled")})
+	require.NoError(t, err)
+	signal, err := parser.SignalInfoToBlob(&serialization.SignalInfo{Name: "signal", Input: []byte("input")})
+	require.NoError(t, err)
+	heartbeat := time.Unix(1700000000, 0).UTC()
+	db := &dumpDB{
+		activities: []ActivityInfoMapsRow{
+			{ScheduleID: 5, Data: activity.Data, DataEncoding: string(activity.Encoding), LastHeartbeatDetails: []byte("details"), LastHeartbeatUpdatedTime: heartbeat},
+		},
+		signals: []SignalInfoMapsRow{
+			{InitiatedID: 7, Data: signal.Data, DataEncoding: string(signal.Encoding)},
+			{InitiatedID: 8, Data: []byte("corrupted"), DataEncoding: "unknown"},
+		},
+		signalRequested: []SignalsRequestedSetsRow{{SignalID: "requested"}},
+	}
+	key := &ExecutionsFilter{ShardID: 2, DomainID: serialization.MustParseUUID("c2e7b4d2-8c15-4d5b-92e3-6f5c1b0e7a11"), WorkflowID: "wid", RunID: serialization.MustParseUUID("0d9b6a3e-2f4c-4f5a-8e1d-7c3b2a1f0e99")}
+
+	dump, err := DumpExecutionMaps(context.Background(), db, registry, key, ExecutionMapsDumpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "c2e7b4d2-8c15-4d5b-92e3-6f5c1b0e7a11", dump.DomainID)
+	require.Len(t, dump.ActivityInfos, 1)
+	assert.Equal(t, "5", dump.ActivityInfos[0].Key)
+	assert.Equal(t, []byte("scheduled"), dump.ActivityInfos[0].Info.(*serialization.ActivityInfo).ScheduledEvent)
+	assert.Equal(t, []byte("details"), dump.ActivityInfos[0].LastHeartbeatDetails)
+	assert.Equal(t, heartbeat, *dump.ActivityInfos[0].LastHeartbeatUpdatedTime)
+	require.Len(t, dump.SignalInfos, 2)
+	assert.Equal(t, []byte("input"), dump.SignalInfos[0].Info.(*serialization.SignalInfo).Input)
+	assert.Nil(t, dump.SignalInfos[1].Info)
+	assert.Contains(t, dump.SignalInfos[1].DecodeError, ErrDecoderNotFound.Error())
+	assert.Equal(t, []byte("corrupted"), dump.SignalInfos[1].Data)
+	assert.Empty(t, dump.TimerInfos)
+	assert.Equal(t, []string{"requested"}, dump.SignalsRequested)
+	_, err = json.Marshal(dump)
+	assert.NoError(t, err)
+
+	dump, err = DumpExecutionMaps(context.Background(), db, registry, key, ExecutionMapsDumpOptions{RedactPayloads: true})
+	require.NoError(t, err)
+	assert.True(t, dump.ActivityInfos[0].Redacted)
+	assert.Nil(t, dump.ActivityInfos[0].LastHeartbeatDetails)
+	assert.Nil(t, dump.ActivityInfos[0].Info.(*serialization.ActivityInfo).ScheduledEvent)
+	assert.Equal(t, int64(3), dump.ActivityInfos[0].Info.(*serialization.ActivityInfo).Version)
+	assert.Equal(t, "signal", dump.SignalInfos[0].Info.(*serialization.SignalInfo).Name)
+	assert.Nil(t, dump.SignalInfos[0].Info.(*serialization.SignalInfo).Input)
+	assert.True(t, dump.SignalInfos[1].Redacted)
+	assert.Nil(t, dump.SignalInfos[1].Data)
+}
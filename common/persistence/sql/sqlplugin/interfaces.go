@@ -24,9 +24,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/serialization"
 )
@@ -36,6 +39,30 @@ var (
 	ErrTTLNotSupported = errors.New("plugin implementation does not support ttl")
 )
 
+// PersistenceError is returned by the Select methods of the execution maps when the query itself failed.
+// It is never used to report that no rows matched, an execution without rows in a map returns an empty slice and a nil error.
+type PersistenceError struct {
+	Operation string
+	Err       error
+}
+
+func (e *PersistenceError) Error() string {
+	return fmt.Sprintf("%v failed: %v", e.Operation, e.Err)
+}
+
+// Unwrap returns the error of the failed query
+func (e *PersistenceError) Unwrap() error {
+	return e.Err
+}
+
+// MaintenanceError is returned by the writes of the execution map tables while map writes are paused for a
+// maintenance of the database. It is retryable, the writes succeed again once the maintenance is over
+type MaintenanceError struct{}
+
+func (e *MaintenanceError) Error() string {
+	return "execution map writes are paused for maintenance"
+}
+
 type (
 	// Plugin defines the interface for any SQL database that needs to implement
 	Plugin interface {
@@ -410,6 +437,17 @@ type (
 		LastHeartbeatUpdatedTime time.Time
 	}
 
+	// ActivityInfoMetadataRow is an ActivityInfoMapsRow without the data columns, it holds the heartbeat of an activity
+	ActivityInfoMetadataRow struct {
+		ShardID                  int64
+		DomainID                 serialization.UUID
+		WorkflowID               string
+		RunID                    serialization.UUID
+		ScheduleID               int64
+		LastHeartbeatDetails     []byte
+		LastHeartbeatUpdatedTime time.Time
+	}
+
 	// ActivityInfoMapsFilter contains the column names within activity_info_maps table that
 	// can be used to filter results through a WHERE clause
 	ActivityInfoMapsFilter struct {
@@ -504,6 +542,67 @@ type (
 		InitiatedIDs []int64
 	}
 
+	// ExecutionMapsSizeRow is the number of rows and the size in bytes of the serialized blobs
+	// an execution uses across the execution map tables
+	ExecutionMapsSizeRow struct {
+		ShardID    int64
+		DomainID   serialization.UUID
+		WorkflowID string
+		RunID      serialization.UUID
+		RowCount   int64
+		DataSize   int64
+	}
+
+	// MutableStateMaps holds the rows of every execution map table of an execution, keyed by the map key of the table
+	MutableStateMaps struct {
+		Activities       map[int64]ActivityInfoMapsRow
+		Timers           map[string]TimerInfoMapsRow
+		ChildExecutions  map[int64]ChildExecutionInfoMapsRow
+		RequestCancels   map[int64]RequestCancelInfoMapsRow
+		Signals          map[int64]SignalInfoMapsRow
+		SignalsRequested map[string]SignalsRequestedSetsRow
+	}
+
+	// ScannerFindingsRow represents a row in scanner_findings table, it is a corrupted or failed execution found by a scan
+	ScannerFindingsRow struct {
+		FindingID     serialization.UUID
+		ScanID        string
+		ShardID       int64
+		DomainID      serialization.UUID
+		WorkflowID    string
+		RunID         serialization.UUID
+		ResultType    string
+		InvariantName string
+		Info          string
+		InfoDetails   string
+		CreatedTime   time.Time
+	}
+
+	// ScannerFindingsFilter selects the rows of scanner_findings created in [MinCreatedTime, MaxCreatedTime),
+	// at most PageSize of them
+	ScannerFindingsFilter struct {
+		DomainID       serialization.UUID
+		ShardID        int64
+		MinCreatedTime time.Time
+		MaxCreatedTime time.Time
+		PageSize       int
+	}
+
+	// ShardScanTimestampsRow represents a row in scanner_shard_scans table, it is when a scanner last scanned a shard
+	ShardScanTimestampsRow struct {
+		ScannerName     string
+		ShardID         int
+		LastScannedTime time.Time
+	}
+
+	// ExecutionKeyRow identifies an execution which has rows in an execution map table
+	ExecutionKeyRow struct {
+		ShardID    int64
+		DomainID   serialization.UUID
+		WorkflowID string
+		RunID      serialization.UUID
+	}
+
 	// SignalsRequestedSetsRow represents a row in signals_requested_sets table
 	SignalsRequestedSetsRow struct {
 		ShardID    int64
@@ -513,6 +612,16 @@ type (
 		SignalID   string
 	}
 
+	// SignalsRequestedSetKey is the primary key of a signals_requested_sets row, unlike SignalsRequestedSetsRow it
+	// can be used as a map key. DomainID and RunID hold the bytes of the UUIDs
+	SignalsRequestedSetKey struct {
+		ShardID    int64
+		DomainID   string
+		WorkflowID string
+		RunID      string
+		SignalID   string
+	}
+
 	// SignalsRequestedSetsFilter contains the column names within signals_requested_sets table that
 	// can be used to filter results through a WHERE clause
 	SignalsRequestedSetsFilter struct {
@@ -523,6 +632,13 @@ type (
 		SignalIDs  []string
 	}
 
+	// MapRowCountRow is the number of execution map rows kept in an executions row with the number of rows of the
+	// execution in the execution map tables
+	MapRowCountRow struct {
+		MapRowCount    int64
+		ActualRowCount int64
+	}
+
 	// VisibilityRow represents a row in executions_visibility table
 	VisibilityRow struct {
 		DomainID         string
@@ -715,6 +831,7 @@ type (
 		ReplaceIntoActivityInfoMaps(ctx context.Context, rows []ActivityInfoMapsRow) (sql.Result, error)
 		// SelectFromActivityInfoMaps returns one or more rows from activity_info_maps
 		// Required filter params - {shardID, domainID, workflowID, runID}
+		// Returns an empty slice when there are no rows, query failures are returned as *PersistenceError
 		SelectFromActivityInfoMaps(ctx context.Context, filter *ActivityInfoMapsFilter) ([]ActivityInfoMapsRow, error)
 		// DeleteFromActivityInfoMaps deletes a row from activity_info_maps table
 		// Required filter params
@@ -725,6 +842,7 @@ type (
 		ReplaceIntoTimerInfoMaps(ctx context.Context, rows []TimerInfoMapsRow) (sql.Result, error)
 		// SelectFromTimerInfoMaps returns one or more rows form timer_info_maps table
 		// Required filter params - {shardID, domainID, workflowID, runID}
+		// Returns an empty slice when there are no rows, query failures are returned as *PersistenceError
 		SelectFromTimerInfoMaps(ctx context.Context, filter *TimerInfoMapsFilter) ([]TimerInfoMapsRow, error)
 		// DeleteFromTimerInfoMaps deletes one or more rows from timer_info_maps
 		// Required filter params
@@ -735,6 +853,7 @@ type (
 		ReplaceIntoChildExecutionInfoMaps(ctx context.Context, rows []ChildExecutionInfoMapsRow) (sql.Result, error)
 		// SelectFromChildExecutionInfoMaps returns one or more rows form child_execution_info_maps table
 		// Required filter params - {shardID, domainID, workflowID, runID}
+		// Returns an empty slice when there are no rows, query failures are returned as *PersistenceError
 		SelectFromChildExecutionInfoMaps(ctx context.Context, filter *ChildExecutionInfoMapsFilter) ([]ChildExecutionInfoMapsRow, error)
 		// DeleteFromChildExecutionInfoMaps deletes one or more rows from child_execution_info_maps
 		// Required filter params
@@ -745,6 +864,7 @@ type (
 		ReplaceIntoRequestCancelInfoMaps(ctx context.Context, rows []RequestCancelInfoMapsRow) (sql.Result, error)
 		// SelectFromRequestCancelInfoMaps returns one or more rows form request_cancel_info_maps table
 		// Required filter params - {shardID, domainID, workflowID, runID}
+		// Returns an empty slice when there are no rows, query failures are returned as *PersistenceError
 		SelectFromRequestCancelInfoMaps(ctx context.Context, filter *RequestCancelInfoMapsFilter) ([]RequestCancelInfoMapsRow, error)
 		// DeleteFromRequestCancelInfoMaps deletes one or more rows from request_cancel_info_maps
 		// Required filter params
@@ -755,6 +875,7 @@ type (
 		ReplaceIntoSignalInfoMaps(ctx context.Context, rows []SignalInfoMapsRow) (sql.Result, error)
 		// SelectFromSignalInfoMaps returns one or more rows form signal_info_maps table
 		// Required filter params - {shardID, domainID, workflowID, runID}
+		// Returns an empty slice when there are no rows, query failures are returned as *PersistenceError
 		SelectFromSignalInfoMaps(ctx context.Context, filter *SignalInfoMapsFilter) ([]SignalInfoMapsRow, error)
 		// DeleteFromSignalInfoMaps deletes one or more rows from signal_info_maps table
 		// Required filter params
@@ -765,6 +886,7 @@ type (
 		InsertIntoSignalsRequestedSets(ctx context.Context, rows []SignalsRequestedSetsRow) (sql.Result, error)
 		// SelectFromSignalInfoMaps returns one or more rows form singals_requested_sets table
 		// Required filter params - {shardID, domainID, workflowID, runID}
+		// Returns an empty slice when there are no rows, query failures are returned as *PersistenceError
 		SelectFromSignalsRequestedSets(ctx context.Context, filter *SignalsRequestedSetsFilter) ([]SignalsRequestedSetsRow, error)
 		// DeleteFromSignalsRequestedSets deletes one or more rows from signals_requested_sets
 		// Required filter params
@@ -848,6 +970,95 @@ type (
 		Close() error
 	}
 
+	// MetricsEmitter is implemented by the DB of plugins which emit metrics of their own, like whether the rows
+	// written to the execution map tables were inserted or updated existing rows
+	MetricsEmitter interface {
+		// SetMetricsClient sets the client the metrics are emitted with, it has to be called before the DB is used
+		SetMetricsClient(metricsClient metrics.Client)
+	}
+
+	// MapWriteMaintenanceController is implemented by the DB of plugins which can pause the writes of the execution map
+	// tables, the writes fail with a *MaintenanceError while the reads continue
+	MapWriteMaintenanceController interface {
+		// SetMapWriteMaintenance sets the function the writes check whether map writes are paused with, it is called by
+		// every write and has to be cheap. It has to be called before the DB is used
+		SetMapWriteMaintenance(maintenance func() bool)
+	}
+
+	// LogEmitter is implemented by the DB of plugins which log warnings of their own, like a caller passing
+	// duplicated map keys in one batch
+	LogEmitter interface {
+		// SetLogger sets the logger the warnings are logged with, it has to be called before the DB is used
+		SetLogger(logger log.Logger)
+	}
+
+	// ScannerFindingsStore is implemented by the DB of plugins which can store the findings of the scanners in a table,
+	// it makes the corrupted and failed executions found by the scans queryable for reports and investigations
+	ScannerFindingsStore interface {
+		// InsertScannerFinding inserts a row into scanner_findings
+		InsertScannerFinding(ctx context.Context, row *ScannerFindingsRow) error
+		// SelectScannerFindingsByDomain returns the findings of filter.DomainID, oldest first. filter.ShardID is ignored
+		SelectScannerFindingsByDomain(ctx context.Context, filter *ScannerFindingsFilter) ([]ScannerFindingsRow, error)
+		// SelectScannerFindingsByShard returns the findings of filter.ShardID, oldest first. filter.DomainID is ignored
+		SelectScannerFindingsByShard(ctx context.Context, filter *ScannerFindingsFilter) ([]ScannerFindingsRow, error)
+		// DeleteScannerFindingsBefore deletes at most batchSize findings created before the given time and returns
+		// the number of findings deleted, fewer than batchSize once no older finding is left
+		DeleteScannerFindingsBefore(ctx context.Context, before time.Time, batchSize int) (int64, error)
+	}
+
+	// ShardScanTimestampsStore is implemented by the DB of plugins which can store when each shard was last scanned
+	// by a scanner, it allows a scanner to start with the shards it scanned least recently
+	ShardScanTimestampsStore interface {
+		// ReplaceIntoShardScanTimestamps records the scan of row.ShardID by row.ScannerName, replacing its previous scan
+		ReplaceIntoShardScanTimestamps(ctx context.Context, row *ShardScanTimestampsRow) error
+		// SelectShardScanTimestamps returns the last scan of each shard scanned by scannerName,
+		// the shards it never scanned have no row
+		SelectShardScanTimestamps(ctx context.Context, scannerName string) ([]ShardScanTimestampsRow, error)
+	}
+
+	// NumDBShardsRecorder is implemented by the DB of plugins which store the number of DB shards in the database.
+	// The DB shard of an execution is derived from it, so a change of the number makes the rows of existing
+	// executions unreachable, the stored number allows such a change to be detected
+	NumDBShardsRecorder interface {
+		// RecordNumDBShards stores GetTotalNumDBShards unless a number was stored before and returns the stored number.
+		// A plugin which migrates the number of DB shards replaces the stored previous number during the migration
+		RecordNumDBShards(ctx context.Context) (int, error)
+	}
+
+	// ChildExecutionInfoMapsStatusReader is implemented by the DB of plugins which can read the child executions of an
+	// execution together with the current run of each child, it saves auditing tools a query per child
+	ChildExecutionInfoMapsStatusReader interface {
+		// SelectChildExecutionInfoWithStatus returns the rows of child_execution_info_maps selected by filter, each with
+		// the current_executions row of its child. numHistoryShards is the number of history shards of the cluster,
+		// which locates the shard of each child
+		SelectChildExecutionInfoWithStatus(ctx context.Context, filter *ChildExecutionInfoMapsFilter, numHistoryShards int) ([]ChildExecutionInfoWithStatus, error)
+	}
+
+	// ChildExecutionInfoWithStatus is a row of child_execution_info_maps with the current run of its child
+	ChildExecutionInfoWithStatus struct {
+		ChildExecutionInfoMapsRow
+		// Current is nil if the child has no current run, e.g. it was deleted, or the row does not name its domain
+		Current *CurrentExecutionsRow
+	}
+
+	// MapRowCountReader is implemented by the DB of plugins which can keep the number of execution map rows of each
+	// execution in its executions row
+	MapRowCountReader interface {
+		// SelectMapRowCount returns the number of execution map rows kept in the executions row of filter along with
+		// the number of rows the execution has in the execution map tables, both read from the same snapshot.
+		// It returns sql.ErrNoRows if there is no executions row. filter.Size is ignored
+		SelectMapRowCount(ctx context.Context, filter *ExecutionsFilter) (*MapRowCountRow, error)
+	}
+
+	// ExecutionMapsSizeReader is implemented by the DB of plugins which can report the storage used by the
+	// execution maps of each execution. It is meant for diagnostics, the queries scan a whole shard
+	ExecutionMapsSizeReader interface {
+		// SelectLargestExecutionMaps returns the limit executions of a shard which use the most bytes across
+		// activity_info_maps, timer_info_maps, child_execution_info_maps, request_cancel_info_maps and signal_info_maps,
+		// largest first
+		SelectLargestExecutionMaps(ctx context.Context, shardID int, limit int) ([]ExecutionMapsSizeRow, error)
+	}
+
 	ErrorChecker interface {
 		IsDupEntryError(err error) bool
 		IsNotFoundError(err error) bool
@@ -855,3 +1066,14 @@ type (
 		IsThrottlingError(err error) bool
 	}
 )
+
+// Key returns the primary key of the row
+func (r SignalsRequestedSetsRow) Key() SignalsRequestedSetKey {
+	return SignalsRequestedSetKey{
+		ShardID:    r.ShardID,
+		DomainID:   string(r.DomainID),
+		WorkflowID: r.WorkflowID,
+		RunID:      string(r.RunID),
+		SignalID:   r.SignalID,
+	}
+}
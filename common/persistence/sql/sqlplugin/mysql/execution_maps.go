@@ -137,8 +137,11 @@ func (mdb *db) ReplaceIntoActivityInfoMaps(ctx context.Context, rows []sqlplugin
 // SelectFromActivityInfoMaps reads one or more rows from activity_info_maps table
 func (mdb *db) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) ([]sqlplugin.ActivityInfoMapsRow, error) {
 	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), mdb.GetTotalNumDBShards())
-	var rows []sqlplugin.ActivityInfoMapsRow
+	rows := []sqlplugin.ActivityInfoMapsRow{}
 	err := mdb.driver.SelectContext(ctx, dbShardID, &rows, getActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromActivityInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
@@ -146,7 +149,7 @@ func (mdb *db) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin
 		rows[i].RunID = filter.RunID
 		rows[i].LastHeartbeatUpdatedTime = mdb.converter.FromMySQLDateTime(rows[i].LastHeartbeatUpdatedTime)
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromActivityInfoMaps deletes one or more rows from activity_info_maps table
@@ -187,16 +190,19 @@ func (mdb *db) ReplaceIntoTimerInfoMaps(ctx context.Context, rows []sqlplugin.Ti
 
 // SelectFromTimerInfoMaps reads one or more rows from timer_info_maps table
 func (mdb *db) SelectFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) ([]sqlplugin.TimerInfoMapsRow, error) {
-	var rows []sqlplugin.TimerInfoMapsRow
+	rows := []sqlplugin.TimerInfoMapsRow{}
 	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), mdb.GetTotalNumDBShards())
 	err := mdb.driver.SelectContext(ctx, dbShardID, &rows, getTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromTimerInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromTimerInfoMaps deletes one or more rows from timer_info_maps table
@@ -237,16 +243,19 @@ func (mdb *db) ReplaceIntoChildExecutionInfoMaps(ctx context.Context, rows []sql
 
 // SelectFromChildExecutionInfoMaps reads one or more rows from child_execution_info_maps table
 func (mdb *db) SelectFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) ([]sqlplugin.ChildExecutionInfoMapsRow, error) {
-	var rows []sqlplugin.ChildExecutionInfoMapsRow
+	rows := []sqlplugin.ChildExecutionInfoMapsRow{}
 	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), mdb.GetTotalNumDBShards())
 	err := mdb.driver.SelectContext(ctx, dbShardID, &rows, getChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromChildExecutionInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromChildExecutionInfoMaps deletes one or more rows from child_execution_info_maps table
@@ -287,16 +296,19 @@ func (mdb *db) ReplaceIntoRequestCancelInfoMaps(ctx context.Context, rows []sqlp
 
 // SelectFromRequestCancelInfoMaps reads one or more rows from request_cancel_info_maps table
 func (mdb *db) SelectFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) ([]sqlplugin.RequestCancelInfoMapsRow, error) {
-	var rows []sqlplugin.RequestCancelInfoMapsRow
+	rows := []sqlplugin.RequestCancelInfoMapsRow{}
 	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), mdb.GetTotalNumDBShards())
 	err := mdb.driver.SelectContext(ctx, dbShardID, &rows, getRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromRequestCancelInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromRequestCancelInfoMaps deletes one or more rows from request_cancel_info_maps table
@@ -338,15 +350,18 @@ func (mdb *db) ReplaceIntoSignalInfoMaps(ctx context.Context, rows []sqlplugin.S
 // SelectFromSignalInfoMaps reads one or more rows from signal_info_maps table
 func (mdb *db) SelectFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) ([]sqlplugin.SignalInfoMapsRow, error) {
 	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), mdb.GetTotalNumDBShards())
-	var rows []sqlplugin.SignalInfoMapsRow
+	rows := []sqlplugin.SignalInfoMapsRow{}
 	err := mdb.driver.SelectContext(ctx, dbShardID, &rows, getSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromSignalInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromSignalInfoMaps deletes one or more rows from signal_info_maps table
@@ -401,16 +416,19 @@ func (mdb *db) InsertIntoSignalsRequestedSets(ctx context.Context, rows []sqlplu
 
 // SelectFromSignalsRequestedSets reads one or more rows from signals_requested_sets table
 func (mdb *db) SelectFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) ([]sqlplugin.SignalsRequestedSetsRow, error) {
-	var rows []sqlplugin.SignalsRequestedSetsRow
+	rows := []sqlplugin.SignalsRequestedSetsRow{}
 	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), mdb.GetTotalNumDBShards())
 	err := mdb.driver.SelectContext(ctx, dbShardID, &rows, getSignalsRequestedSetQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromSignalsRequestedSets", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromSignalsRequestedSets deletes one or more rows from signals_requested_sets table
@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"context"
+)
+
+type operationClassContextKey struct{}
+
+// WithOperationClass returns a context carrying the class of the persistence operation done with it, like
+// UpdateWorkflowExecution. Plugins which label their connections, like postgres with application_name, include it
+// in the label of the transactions started with the context
+func WithOperationClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, operationClassContextKey{}, class)
+}
+
+// OperationClassFromContext returns the class set with WithOperationClass, or an empty string
+func OperationClassFromContext(ctx context.Context) string {
+	class, _ := ctx.Value(operationClassContextKey{}).(string)
+	return class
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	defaultActivityInfoMapsCacheTTL = 10 * time.Second
+	// maxActivityInfoMapsCacheInvalidations bounds the invalidations remembered for reads in flight,
+	// once exceeded they are forgotten and all reads in flight skip populating the cache instead
+	maxActivityInfoMapsCacheInvalidations = 10000
+)
+
+type (
+	activityInfoMapsCacheKey struct {
+		shardID    int64
+		domainID   string
+		workflowID string
+		runID      string
+	}
+
+	// activityInfoMapsCache is a LRU cache of the activity_info_maps rows of an execution.
+	// A read can only populate the cache if no write invalidated the execution since the read started,
+	// which keeps a slow read from putting back rows a concurrent write already replaced.
+	activityInfoMapsCache struct {
+		entries cache.Cache
+
+		sync.Mutex
+		seq         uint64
+		floor       uint64
+		invalidated map[activityInfoMapsCacheKey]uint64
+	}
+)
+
+func newActivityInfoMapsCache(size int, ttl time.Duration) *activityInfoMapsCache {
+	if ttl <= 0 {
+		ttl = defaultActivityInfoMapsCacheTTL
+	}
+	return &activityInfoMapsCache{
+		entries: cache.New(&cache.Options{
+			TTL:      ttl,
+			MaxCount: size,
+		}),
+		invalidated: make(map[activityInfoMapsCacheKey]uint64),
+	}
+}
+
+func newActivityInfoMapsCacheKey(shardID int64, domainID string, workflowID string, runID string) activityInfoMapsCacheKey {
+	return activityInfoMapsCacheKey{
+		shardID:    shardID,
+		domainID:   domainID,
+		workflowID: workflowID,
+		runID:      runID,
+	}
+}
+
+// get returns a copy of the cached rows of an execution
+func (c *activityInfoMapsCache) get(key activityInfoMapsCacheKey) ([]sqlplugin.ActivityInfoMapsRow, bool) {
+	rows, ok := c.entries.Get(key).([]sqlplugin.ActivityInfoMapsRow)
+	if !ok {
+		return nil, false
+	}
+	return append([]sqlplugin.ActivityInfoMapsRow{}, rows...), true
+}
+
+// startRead returns the token to pass to put once the rows of an execution were read from the database
+func (c *activityInfoMapsCache) startRead() uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.seq
+}
+
+// put caches a copy of the rows of an execution, unless the execution was invalidated after the read started
+func (c *activityInfoMapsCache) put(key activityInfoMapsCacheKey, rows []sqlplugin.ActivityInfoMapsRow, readSeq uint64) {
+	c.Lock()
+	defer c.Unlock()
+	if readSeq < c.floor || c.invalidated[key] > readSeq {
+		return
+	}
+	c.entries.Put(key, append([]sqlplugin.ActivityInfoMapsRow{}, rows...))
+}
+
+// invalidate drops the cached rows of an execution and prevents reads in flight from caching them again
+func (c *activityInfoMapsCache) invalidate(key activityInfoMapsCacheKey) {
+	c.Lock()
+	defer c.Unlock()
+	c.seq++
+	if len(c.invalidated) >= maxActivityInfoMapsCacheInvalidations {
+		c.invalidated = make(map[activityInfoMapsCacheKey]uint64)
+		c.floor = c.seq
+	}
+	c.invalidated[key] = c.seq
+	c.entries.Delete(key)
+}
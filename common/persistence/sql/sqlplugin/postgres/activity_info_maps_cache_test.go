@@ -0,0 +1,98 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestActivityInfoMapsCache(t *testing.T) {
+	c := newActivityInfoMapsCache(10, time.Minute)
+	key := newActivityInfoMapsCacheKey(1, "domain", "workflow", "run")
+	rows := []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}}
+
+	_, ok := c.get(key)
+	assert.False(t, ok)
+
+	c.put(key, rows, c.startRead())
+	cached, ok := c.get(key)
+	assert.True(t, ok)
+	assert.Equal(t, rows, cached)
+	cached[0].ScheduleID = 6
+	cached, _ = c.get(key)
+	assert.Equal(t, int64(5), cached[0].ScheduleID, "callers must not be able to modify cached rows")
+
+	c.invalidate(key)
+	_, ok = c.get(key)
+	assert.False(t, ok)
+
+	// a read which started before a write of the same execution must not populate the cache
+	readSeq := c.startRead()
+	c.invalidate(key)
+	c.put(key, rows, readSeq)
+	_, ok = c.get(key)
+	assert.False(t, ok)
+
+	// writes of other executions do not prevent populating the cache
+	readSeq = c.startRead()
+	c.invalidate(newActivityInfoMapsCacheKey(1, "domain", "workflow", "other-run"))
+	c.put(key, rows, readSeq)
+	_, ok = c.get(key)
+	assert.True(t, ok)
+}
+
+func TestActivityInfoMapsCacheForgetsInvalidations(t *testing.T) {
+	c := newActivityInfoMapsCache(10, time.Minute)
+	key := newActivityInfoMapsCacheKey(1, "domain", "workflow", "run")
+	readSeq := c.startRead()
+	for i := 0; i < maxActivityInfoMapsCacheInvalidations+1; i++ {
+		c.invalidate(newActivityInfoMapsCacheKey(int64(i), "domain", "workflow", "run"))
+	}
+	assert.Len(t, c.invalidated, 1)
+	c.put(key, nil, readSeq)
+	_, ok := c.get(key)
+	assert.False(t, ok, "reads in flight when invalidations were forgotten must not populate the cache")
+}
+
+func TestActivityInfoMapsWrittenInTx(t *testing.T) {
+	c := newActivityInfoMapsCache(10, time.Minute)
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	key := newActivityInfoMapsCacheKey(1, string(domainID), "workflow", string(runID))
+	c.put(key, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1}}, c.startRead())
+
+	tx := &db{isTx: true, opts: dbOptions{activityInfoMapsCache: c}}
+	tx.activityInfoMapsWritten(1, domainID, "workflow", runID)
+	_, ok := c.get(key)
+	assert.True(t, ok, "uncommitted writes must not invalidate the cache")
+	assert.Equal(t, []activityInfoMapsCacheKey{key}, tx.txActivityInfoMapsWrites)
+
+	pdb := &db{opts: dbOptions{activityInfoMapsCache: c}}
+	pdb.activityInfoMapsWritten(1, domainID, "workflow", runID)
+	_, ok = c.get(key)
+	assert.False(t, ok)
+}
@@ -0,0 +1,477 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+const (
+	defaultAsyncMapWriteBatchSize     = 100
+	defaultAsyncMapWriteFlushInterval = 100 * time.Millisecond
+	asyncMapWriteTimeout              = 10 * time.Second
+)
+
+var errAsyncMapWriterClosed = errors.New("async map writer is closed")
+
+// asyncMapWriter writes the activity_info_maps rows queued by EnqueueReplaceActivityInfoMaps in the background,
+// in batches of up to batchSize rows. The queue is bounded, enqueueing blocks until the queue has room for all the
+// rows of the call, and it is drained before the db is closed. Queued rows hold their slots until they are written,
+// so the callers are held back while the writes fail.
+//
+// The rows are written with the first of the DBs the writer was started with which is not closed yet. A writer with a
+// write-ahead log is shared by the DBs of the process configured with the same log path, so the log has a single
+// writer, and it is closed with the last of them.
+type asyncMapWriter struct {
+	// queue holds the rows of each enqueue call together, slots bounds the rows in it
+	queue         chan []walRow
+	queueSize     int
+	slots         *semaphore.Weighted
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	// wal is nil unless the write-ahead log is enabled in config, replay holds the rows found in the log on startup,
+	// they are written before the queued rows
+	wal    *mapWriteWAL
+	replay []walRow
+
+	// mu guards started and closed, enqueueing holds it for reading so the queue is never closed while a row is sent
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+
+	// hostMu guards hosts, the DBs the writer was started with in the order they were started. A write holds it for
+	// reading, so a DB is only removed once the writes with it are done
+	hostMu sync.RWMutex
+	hosts  []*db
+	// refs counts the DBs holding the writer, it is guarded by asyncMapWriters
+	refs int
+}
+
+// asyncMapWriters holds the writers with a write-ahead log by the path of the log
+var asyncMapWriters = struct {
+	sync.Mutex
+	byPath map[string]*asyncMapWriter
+}{byPath: make(map[string]*asyncMapWriter)}
+
+// openAsyncMapWriter returns the writer of the async map write queue enabled in cfg, nil if it is not enabled.
+// The writer of a write-ahead log is shared with the other DBs of the process configured with the same log path,
+// the queue settings of the first of them are used. The writer is held until it is released with unref
+func openAsyncMapWriter(cfg *config.SQL) (*asyncMapWriter, error) {
+	if cfg.AsyncMapWriteQueueSize == 0 {
+		return nil, nil
+	}
+	if cfg.AsyncMapWriteWALPath == "" {
+		writer := newAsyncMapWriter(cfg.AsyncMapWriteQueueSize, cfg.AsyncMapWriteBatchSize, cfg.AsyncMapWriteFlushInterval)
+		writer.refs = 1
+		return writer, nil
+	}
+	path := processFilePath(cfg.AsyncMapWriteWALPath)
+	asyncMapWriters.Lock()
+	defer asyncMapWriters.Unlock()
+	if writer, ok := asyncMapWriters.byPath[path]; ok {
+		writer.refs++
+		return writer, nil
+	}
+	wal, replay, err := openMapWriteWAL(path, cfg.AsyncMapWriteWALMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	writer := newAsyncMapWriter(cfg.AsyncMapWriteQueueSize, cfg.AsyncMapWriteBatchSize, cfg.AsyncMapWriteFlushInterval)
+	writer.wal = wal
+	writer.replay = replay
+	writer.refs = 1
+	asyncMapWriters.byPath[path] = writer
+	return writer, nil
+}
+
+func newAsyncMapWriter(queueSize int, batchSize int, flushInterval time.Duration) *asyncMapWriter {
+	if batchSize == 0 {
+		batchSize = defaultAsyncMapWriteBatchSize
+	}
+	if flushInterval == 0 {
+		flushInterval = defaultAsyncMapWriteFlushInterval
+	}
+	return &asyncMapWriter{
+		// each queued call holds at least one slot, so the channel never fills up before the slots run out
+		queue:         make(chan []walRow, queueSize),
+		queueSize:     queueSize,
+		slots:         semaphore.NewWeighted(int64(queueSize)),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+}
+
+// EnqueueReplaceActivityInfoMaps queues rows to be written to activity_info_maps in the background,
+// rows are written synchronously when the async map write queue is not enabled in config
+func (pdb *db) EnqueueReplaceActivityInfoMaps(ctx context.Context, rangeID int64, rows []sqlplugin.ActivityInfoMapsRow) error {
+	if pdb.opts.asyncWriter == nil {
+		_, err := pdb.ReplaceIntoActivityInfoMaps(ctx, rows)
+		return err
+	}
+	return pdb.opts.asyncWriter.enqueue(ctx, rangeID, rows)
+}
+
+// start adds pdb, which must not be a transaction, to the DBs the queued rows are written with. The writer starts
+// writing with the first one
+func (w *asyncMapWriter) start(pdb *db) {
+	w.hostMu.Lock()
+	w.hosts = append(w.hosts, pdb)
+	w.hostMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started || w.closed {
+		return
+	}
+	w.started = true
+	go w.run()
+}
+
+// unref releases the writer held by pdb, or by the options of a DB which failed to be created if pdb is nil.
+// The writer is closed once it is released by the last DB holding it, the queued rows are written with pdb before
+// it is removed from the DBs the rows are written with
+func (w *asyncMapWriter) unref(pdb *db) error {
+	asyncMapWriters.Lock()
+	w.refs--
+	last := w.refs <= 0
+	if last && w.wal != nil && asyncMapWriters.byPath[w.wal.path] == w {
+		delete(asyncMapWriters.byPath, w.wal.path)
+	}
+	asyncMapWriters.Unlock()
+	var err error
+	if last {
+		err = w.close()
+	}
+	if pdb != nil {
+		w.hostMu.Lock()
+		for i, host := range w.hosts {
+			if host == pdb {
+				w.hosts = append(w.hosts[:i:i], w.hosts[i+1:]...)
+				break
+			}
+		}
+		w.hostMu.Unlock()
+	}
+	return err
+}
+
+// withHost calls fn with the DB the rows are written with, it is not removed until fn returns.
+// fn is not called while the writer has no DB, the rows wait for the next DB to start
+func (w *asyncMapWriter) withHost(fn func(pdb *db)) {
+	w.hostMu.RLock()
+	defer w.hostMu.RUnlock()
+	if len(w.hosts) > 0 {
+		fn(w.hosts[0])
+	}
+}
+
+// enqueue queues all of rows or, if ctx is done before the queue has room for them, none of them
+func (w *asyncMapWriter) enqueue(ctx context.Context, rangeID int64, rows []sqlplugin.ActivityInfoMapsRow) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return errAsyncMapWriterClosed
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	slots := w.slotsOf(len(rows))
+	if err := w.slots.Acquire(ctx, slots); err != nil {
+		return err
+	}
+	queued := make([]walRow, len(rows))
+	for i, row := range rows {
+		queued[i] = walRow{rangeID: rangeID, row: row}
+	}
+	if w.wal != nil {
+		var err error
+		if queued, err = w.wal.append(rangeID, rows); err != nil {
+			w.slots.Release(slots)
+			return err
+		}
+	}
+	// the slots are held by the rows until they are written
+	for i := int64(0); i < slots; i++ {
+		queued[i].slots = 1
+	}
+	w.queue <- queued
+	return nil
+}
+
+// slotsOf returns the queue slots taken by an enqueue of n rows, more rows than the queue holds take all of it
+func (w *asyncMapWriter) slotsOf(n int) int64 {
+	if n > w.queueSize {
+		n = w.queueSize
+	}
+	return int64(n)
+}
+
+// close stops accepting rows and waits until the queued rows are written, the rows of a writer which was never
+// started are left in the write-ahead log
+func (w *asyncMapWriter) close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	started := w.started
+	w.mu.Unlock()
+	if started {
+		<-w.done
+	}
+	if w.wal != nil {
+		return w.wal.close()
+	}
+	return nil
+}
+
+// run writes the replayed rows and then the queued ones. Rows which fail to be written are kept in front of the
+// batch and retried every flush interval, new rows do not trigger a write until the retry succeeds
+func (w *asyncMapWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	batch, failing := w.replay, true
+	w.withHost(func(pdb *db) {
+		batch, failing = w.write(pdb, w.fence(pdb, w.replay), false)
+	})
+	w.replay = nil
+	for {
+		select {
+		case rows, ok := <-w.queue:
+			if !ok {
+				w.withHost(func(pdb *db) {
+					batch, _ = w.write(pdb, batch, true)
+					if len(batch) > 0 && pdb.opts.logger != nil {
+						if w.wal != nil {
+							pdb.opts.logger.Warn("Failed to write queued activity_info_maps rows before closing, they are left in the WAL",
+								tag.Counter(len(batch)))
+						} else {
+							pdb.opts.logger.Error("Failed to write queued activity_info_maps rows before closing, they are dropped",
+								tag.Counter(len(batch)))
+						}
+					}
+				})
+				return
+			}
+			batch = append(batch, rows...)
+			if !failing {
+				w.withHost(func(pdb *db) {
+					batch, failing = w.write(pdb, batch, false)
+				})
+			}
+		case <-ticker.C:
+			w.withHost(func(pdb *db) {
+				batch, failing = w.write(pdb, batch, true)
+			})
+		}
+	}
+}
+
+// write writes rows in batches of batchSize rows, the last partial batch only if all is set. It stops at the first
+// batch which fails and returns the rows which are not written, failed is true if a batch failed
+func (w *asyncMapWriter) write(pdb *db, rows []walRow, all bool) (left []walRow, failed bool) {
+	for len(rows) >= w.batchSize || (all && len(rows) > 0) {
+		n := w.batchSize
+		if n > len(rows) {
+			n = len(rows)
+		}
+		if retry := w.flush(pdb, rows[:n]); len(retry) > 0 {
+			return append(retry, rows[n:]...), true
+		}
+		rows = rows[n:]
+	}
+	return append([]walRow(nil), rows...), false
+}
+
+// flush writes a batch with one transaction per shard, as rows of different shards can not be written together.
+// The transaction locks the shard row and drops the rows queued under an older range than its current one, the shard
+// was acquired again since, possibly by another host which may have written newer state of the activities. The other
+// rows are written in the order they were enqueued, of the rows of the same activity the last one enqueued is written.
+// The rows of a shard which failed to be written are returned to be retried, unless the write was rejected as
+// invalid, which a retry would not change. The other rows are released from the write-ahead log and the queue.
+func (w *asyncMapWriter) flush(pdb *db, batch []walRow) (retry []walRow) {
+	if len(batch) == 0 {
+		return nil
+	}
+	var shardIDs []int64
+	byShard := make(map[int64][]walRow)
+	for _, queued := range batch {
+		shardID := queued.row.ShardID
+		if _, ok := byShard[shardID]; !ok {
+			shardIDs = append(shardIDs, shardID)
+		}
+		byShard[shardID] = append(byShard[shardID], queued)
+	}
+	var done []walRow
+	for _, shardID := range shardIDs {
+		queued := byShard[shardID]
+		ctx, cancel := context.WithTimeout(context.Background(), asyncMapWriteTimeout)
+		stale, err := w.flushShard(ctx, pdb, shardID, queued)
+		cancel()
+		switch {
+		case err == nil:
+			if stale > 0 && pdb.opts.logger != nil {
+				pdb.opts.logger.Warn("Dropped queued activity_info_maps rows queued under an older shard range",
+					tag.ShardID(int(shardID)), tag.Counter(stale))
+			}
+			done = append(done, queued...)
+		case isInvalidMapWrite(err):
+			if pdb.opts.logger != nil {
+				pdb.opts.logger.Error("Queued activity_info_maps rows were rejected, they are dropped",
+					tag.ShardID(int(shardID)), tag.Counter(len(queued)), tag.Error(err))
+			}
+			done = append(done, queued...)
+		default:
+			if pdb.opts.logger != nil {
+				pdb.opts.logger.Warn("Failed to write queued activity_info_maps rows, they are retried",
+					tag.ShardID(int(shardID)), tag.Counter(len(queued)), tag.Error(err))
+			}
+			retry = append(retry, queued...)
+		}
+	}
+	w.release(pdb, done)
+	return retry
+}
+
+// flushShard writes the queued rows of shardID in a transaction which holds the shard row locked for reading, so
+// the range of the shard can not change until the rows are written. It returns the number of stale rows dropped
+func (w *asyncMapWriter) flushShard(ctx context.Context, pdb *db, shardID int64, queued []walRow) (stale int, err error) {
+	write := func(tx *db) error {
+		rangeID, err := tx.ReadLockShards(ctx, &sqlplugin.ShardsFilter{ShardID: shardID})
+		if err != nil {
+			return err
+		}
+		rows := make([]sqlplugin.ActivityInfoMapsRow, 0, len(queued))
+		for _, queued := range queued {
+			if queued.rangeID < int64(rangeID) {
+				continue
+			}
+			rows = append(rows, queued.row)
+		}
+		stale = len(queued) - len(rows)
+		if len(rows) == 0 {
+			return nil
+		}
+		_, err = tx.ReplaceIntoActivityInfoMaps(ctx, lastEnqueuedRows(rows))
+		return err
+	}
+	if pdb.isTx {
+		return stale, write(pdb)
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(shardID))
+	return stale, pdb.txExecute(ctx, dbShardID, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.LockedUpdate, write)
+}
+
+// lastEnqueuedRows keeps the last enqueued row of each activity, the rows are kept in the order they were enqueued
+func lastEnqueuedRows(rows []sqlplugin.ActivityInfoMapsRow) []sqlplugin.ActivityInfoMapsRow {
+	last := make(map[activityInfoMapsKey]int, len(rows))
+	for i, row := range rows {
+		last[activityInfoMapsKeyOf(row)] = i
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+	kept := make([]sqlplugin.ActivityInfoMapsRow, 0, len(last))
+	for i, row := range rows {
+		if last[activityInfoMapsKeyOf(row)] == i {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// release frees the queue slots of rows and removes them from the write-ahead log
+func (w *asyncMapWriter) release(pdb *db, rows []walRow) {
+	if len(rows) == 0 {
+		return
+	}
+	var slots int64
+	for _, row := range rows {
+		slots += row.slots
+	}
+	if slots > 0 {
+		w.slots.Release(slots)
+	}
+	if w.wal == nil {
+		return
+	}
+	if err := w.wal.release(rows); err != nil && pdb.opts.logger != nil {
+		pdb.opts.logger.Error("Failed to release written activity_info_maps rows from the WAL", tag.Error(err))
+	}
+}
+
+// fence drops the replayed rows which were queued under an older range of their shard than its current one, the
+// shard was acquired again since, possibly by another host which may have written newer state of the activities.
+// The rows of a shard whose range cannot be read are skipped but left in the write-ahead log for the next start
+func (w *asyncMapWriter) fence(pdb *db, replay []walRow) []walRow {
+	ranges := make(map[int64]int64)
+	var kept, stale []walRow
+	for _, queued := range replay {
+		shardID := queued.row.ShardID
+		rangeID, ok := ranges[shardID]
+		if !ok {
+			ctx, cancel := context.WithTimeout(context.Background(), asyncMapWriteTimeout)
+			shard, err := pdb.SelectFromShards(ctx, &sqlplugin.ShardsFilter{ShardID: shardID})
+			cancel()
+			rangeID = -1
+			if err == nil {
+				rangeID = shard.RangeID
+			} else if pdb.opts.logger != nil {
+				pdb.opts.logger.Warn("Failed to read the range of a shard with activity_info_maps rows in the WAL, they are not replayed",
+					tag.ShardID(int(shardID)), tag.Error(err))
+			}
+			ranges[shardID] = rangeID
+		}
+		switch {
+		case rangeID < 0:
+		case queued.rangeID < rangeID:
+			stale = append(stale, queued)
+		default:
+			kept = append(kept, queued)
+		}
+	}
+	if len(stale) > 0 && pdb.opts.logger != nil {
+		pdb.opts.logger.Warn("Dropped activity_info_maps rows of the WAL queued under an older shard range", tag.Counter(len(stale)))
+	}
+	w.release(pdb, stale)
+	return kept
+}
+
+// isInvalidMapWrite tells whether err rejects the rows of a map write, it fails again however often it is retried
+func isInvalidMapWrite(err error) bool {
+	var badRequest *types.BadRequestError
+	var limitExceeded *types.LimitExceededError
+	return errors.As(err, &badRequest) || errors.As(err, &limitExceeded)
+}
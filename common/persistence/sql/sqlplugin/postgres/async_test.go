@@ -0,0 +1,235 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestEnqueueReplaceActivityInfoMaps(t *testing.T) {
+	// without a queue the rows are written right away
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(batchResult(1), nil)
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}}))
+
+	// a queued batch is written once full and the rest when the queue is drained, one transaction per shard
+	connector := &stagingConnector{rangeID: 1}
+	writer := newAsyncMapWriter(2, 3, time.Hour)
+	pdb = newAsyncStagingDB(t, connector, writer)
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{
+		{ShardID: 1, ScheduleID: 5},
+		{ShardID: 2, ScheduleID: 5},
+		{ShardID: 1, ScheduleID: 6},
+		{ShardID: 1, ScheduleID: 7},
+	}))
+	require.NoError(t, writer.close())
+	var written []int64
+	for _, rows := range writtenActivities(connector) {
+		for _, row := range rows {
+			assert.Equal(t, rows[0]/10, row/10)
+			written = append(written, row)
+		}
+	}
+	assert.ElementsMatch(t, []int64{15, 25, 16, 17}, written)
+	assert.Len(t, connector.isolations, 3)
+	assert.Equal(t, errAsyncMapWriterClosed, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1}}))
+}
+
+// newAsyncStagingDB returns a db on connector which writes the rows queued in writer
+func newAsyncStagingDB(t *testing.T, connector *stagingConnector, writer *asyncMapWriter) *db {
+	xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{queries: newExecutionMapQueries(""), asyncWriter: writer})
+	require.NoError(t, err)
+	return pdb
+}
+
+// writtenActivities returns the activities of each upsert written to connector as shardID*10+scheduleID
+func writtenActivities(connector *stagingConnector) [][]int64 {
+	// an activity_info_maps row is bound to shard_id, domain_id, workflow_id, run_id, schedule_id and 4 data columns
+	const columns = 9
+	connector.Lock()
+	defer connector.Unlock()
+	var written [][]int64
+	for _, args := range connector.writtenArgs {
+		var rows []int64
+		for i := 0; i+columns <= len(args); i += columns {
+			rows = append(rows, args[i].Value.(int64)*10+args[i+4].Value.(int64))
+		}
+		written = append(written, rows)
+	}
+	return written
+}
+
+func TestEnqueueReplaceActivityInfoMapsBlocksWhileQueueIsFull(t *testing.T) {
+	writer := newAsyncMapWriter(3, 0, 0)
+	pdb := &db{opts: dbOptions{asyncWriter: writer}}
+	// the writer is not started, so the queue is not drained
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 1, ScheduleID: 6}}))
+
+	// there is room for one more row only, so none of the two rows is queued when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pdb.EnqueueReplaceActivityInfoMaps(ctx, 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 7}, {ShardID: 1, ScheduleID: 8}})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	require.Len(t, writer.queue, 1)
+	assert.Len(t, <-writer.queue, 2)
+	writer.slots.Release(2)
+
+	// more rows than the queue holds are queued together once the queue is empty
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 1, ScheduleID: 6}, {ShardID: 1, ScheduleID: 7}, {ShardID: 1, ScheduleID: 8}}))
+	require.Len(t, writer.queue, 1)
+	assert.Len(t, <-writer.queue, 4)
+	writer.close()
+}
+
+func TestAsyncMapWriterRetriesFailedWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	writer := newAsyncMapWriter(2, 2, time.Millisecond)
+	wal, _, err := openMapWriteWAL(path, 0)
+	require.NoError(t, err)
+	writer.wal = wal
+	var paused atomic.Bool
+	paused.Store(true)
+	connector := &stagingConnector{rangeID: 1}
+	pdb := newAsyncStagingDB(t, connector, writer)
+	pdb.SetMapWriteMaintenance(paused.Load)
+
+	// the writes fail while paused for maintenance, the rows stay in the log and keep their queue slots
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 1, ScheduleID: 6}}))
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, writtenActivities(connector))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, pdb.EnqueueReplaceActivityInfoMaps(ctx, 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 7}}))
+
+	// the rows are written once the pause ends and released from the log
+	paused.Store(false)
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 7}}))
+	require.NoError(t, writer.close())
+	var written []int64
+	for _, rows := range writtenActivities(connector) {
+		written = append(written, rows...)
+	}
+	assert.Equal(t, []int64{15, 16, 17}, written)
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestAsyncMapWriterDropsRejectedWrites(t *testing.T) {
+	writer := newAsyncMapWriter(2, 0, time.Hour)
+	pdb := &db{opts: dbOptions{asyncWriter: writer}}
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 1, ScheduleID: 6}}))
+	queued := <-writer.queue
+	rejecting, driver := newMockDB(t)
+	rejecting.isTx = true
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), readLockShardQry, int64(1)).DoAndReturn((&sentStatements{}).rows(1))
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(nil, &types.BadRequestError{Message: "invalid row"})
+	assert.Empty(t, writer.flush(rejecting, queued))
+	// the slots of the dropped rows are released
+	assert.True(t, writer.slots.TryAcquire(2))
+	writer.close()
+}
+
+func TestAsyncMapWriterFlushFencesAndOrdersRows(t *testing.T) {
+	writer := newAsyncMapWriter(5, 0, time.Hour)
+	pdb := &db{opts: dbOptions{asyncWriter: writer}}
+	// the shard was acquired again under range 3 after the first row was queued
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 2, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 9}}))
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 3, []sqlplugin.ActivityInfoMapsRow{
+		{ShardID: 1, ScheduleID: 6, Data: []byte("first")},
+		{ShardID: 1, ScheduleID: 5, LastHeartbeatUpdatedTime: time.Unix(2, 0)},
+	}))
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 3, []sqlplugin.ActivityInfoMapsRow{
+		{ShardID: 1, ScheduleID: 6, Data: []byte("second")},
+		{ShardID: 1, ScheduleID: 5, LastHeartbeatUpdatedTime: time.Unix(1, 0)},
+	}))
+	var queued []walRow
+	for len(writer.queue) > 0 {
+		queued = append(queued, <-writer.queue...)
+	}
+
+	tx, driver := newMockDB(t)
+	tx.isTx = true
+	sent := &sentStatements{}
+	gomock.InOrder(
+		driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), readLockShardQry, int64(1)).DoAndReturn(sent.rows(3)),
+		driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.namedResult()),
+	)
+	assert.Empty(t, writer.flush(tx, queued))
+	// the stale row is dropped, of each activity the row enqueued last is written even with an older heartbeat
+	written := sent.lastArgs()[0].([]sqlplugin.ActivityInfoMapsRow)
+	require.Len(t, written, 2)
+	assert.Equal(t, int64(6), written[0].ScheduleID)
+	assert.Equal(t, []byte("second"), written[0].Data)
+	assert.Equal(t, int64(5), written[1].ScheduleID)
+	assert.Equal(t, time.Unix(1, 0).UnixNano(), written[1].LastHeartbeatUpdatedTime.UnixNano())
+	// the slots of the written and the dropped rows are released
+	assert.True(t, writer.slots.TryAcquire(5))
+	writer.close()
+}
+
+func TestAsyncMapWriterIsSharedPerWALPath(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.SQL{AsyncMapWriteQueueSize: 4, AsyncMapWriteBatchSize: 1, AsyncMapWriteWALPath: filepath.Join(dir, "wal")}
+	first, err := openAsyncMapWriter(cfg)
+	require.NoError(t, err)
+	// the same log spelled differently has the same writer
+	second, err := openAsyncMapWriter(&config.SQL{AsyncMapWriteQueueSize: 2, AsyncMapWriteWALPath: filepath.Join(dir, ".", "wal")})
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// the rows are written with the other DB once the first one is closed
+	connector := &stagingConnector{rangeID: 1}
+	firstDB := newAsyncStagingDB(t, connector, first)
+	secondDB := newAsyncStagingDB(t, connector, second)
+	require.NoError(t, firstDB.Close())
+	require.NoError(t, secondDB.EnqueueReplaceActivityInfoMaps(context.Background(), 1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}}))
+	require.NoError(t, secondDB.Close())
+	assert.Equal(t, [][]int64{{15}}, writtenActivities(connector))
+
+	// the writer is closed with the last DB holding it, the next DB opens the log again
+	third, err := openAsyncMapWriter(cfg)
+	require.NoError(t, err)
+	assert.NotSame(t, first, third)
+	require.NoError(t, third.unref(nil))
+	assert.Empty(t, asyncMapWriters.byPath)
+}
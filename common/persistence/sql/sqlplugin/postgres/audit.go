@@ -0,0 +1,159 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+type (
+	// mapDeleteAuditRecord is written to the audit sink before rows of an execution map table are deleted
+	mapDeleteAuditRecord struct {
+		Timestamp  time.Time `json:"timestamp"`
+		Actor      string    `json:"actor"`
+		Table      string    `json:"table"`
+		ShardID    int64     `json:"shardID"`
+		DomainID   string    `json:"domainID"`
+		WorkflowID string    `json:"workflowID"`
+		RunID      string    `json:"runID"`
+		// Keys are the map keys deleted, empty when every row of the execution is deleted
+		Keys []string `json:"keys,omitempty"`
+	}
+
+	// auditSink persists audit records, a failed write prevents the audited delete
+	auditSink interface {
+		write(record mapDeleteAuditRecord) error
+		close() error
+	}
+
+	// fileAuditSink appends audit records as JSON lines to a file
+	fileAuditSink struct {
+		sync.Mutex
+		file *os.File
+		path string
+		// refs counts the DBs holding the sink, it is guarded by fileAuditSinks
+		refs int
+	}
+)
+
+// fileAuditSinks holds the open audit logs by path. The DBs of a process configured with the same path share the
+// sink of the path, so a single file is opened and the records of the DBs are never interleaved
+var fileAuditSinks = struct {
+	sync.Mutex
+	byPath map[string]*fileAuditSink
+}{byPath: make(map[string]*fileAuditSink)}
+
+// newFileAuditSink returns the sink of path, it is opened unless another DB of the process holds it already.
+// The file is closed once every DB holding the sink closed it
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	path = processFilePath(path)
+	fileAuditSinks.Lock()
+	defer fileAuditSinks.Unlock()
+	if sink, ok := fileAuditSinks.byPath[path]; ok {
+		sink.refs++
+		return sink, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open map delete audit log: %v", err)
+	}
+	sink := &fileAuditSink{file: file, path: path, refs: 1}
+	fileAuditSinks.byPath[path] = sink
+	return sink, nil
+}
+
+// processFilePath returns the absolute path of a file shared by the DBs of the process, so that different spellings
+// of the same path share it too
+func processFilePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func (s *fileAuditSink) write(record mapDeleteAuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *fileAuditSink) close() error {
+	fileAuditSinks.Lock()
+	defer fileAuditSinks.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(fileAuditSinks.byPath, s.path)
+	return s.file.Close()
+}
+
+// auditMapDelete writes the audit record of a delete from table, it is a no-op unless the audit log is enabled in config
+func (pdb *db) auditMapDelete(
+	ctx context.Context,
+	table string,
+	shardID int64,
+	domainID serialization.UUID,
+	workflowID string,
+	runID serialization.UUID,
+	keys []string,
+) error {
+	if pdb.opts.auditSink == nil {
+		return nil
+	}
+	err := pdb.opts.auditSink.write(mapDeleteAuditRecord{
+		Timestamp:  time.Now().UTC(),
+		Actor:      sqlplugin.AuditActorFromContext(ctx),
+		Table:      table,
+		ShardID:    shardID,
+		DomainID:   domainID.String(),
+		WorkflowID: workflowID,
+		RunID:      runID.String(),
+		Keys:       keys,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit delete from %v: %v", table, err)
+	}
+	return nil
+}
+
+func int64Keys(ids []int64) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.FormatInt(id, 10)
+	}
+	return keys
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+type recordingAuditSink struct {
+	records []mapDeleteAuditRecord
+	err     error
+}
+
+func (s *recordingAuditSink) write(record mapDeleteAuditRecord) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *recordingAuditSink) close() error {
+	return nil
+}
+
+func TestAuditMapDelete(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	sink := &recordingAuditSink{}
+	pdb, driver := newMockDB(t)
+	pdb.opts.auditSink = sink
+	sent := &sentStatements{}
+	driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.result(0)).Times(7)
+	ctx := sqlplugin.WithAuditActor(context.Background(), "cleanup-tool")
+
+	_, err := pdb.DeleteFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+		ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, InitiatedIDs: []int64{5, 7},
+	})
+	require.NoError(t, err)
+	require.Len(t, sink.records, 1)
+	record := sink.records[0]
+	assert.Equal(t, "cleanup-tool", record.Actor)
+	assert.Equal(t, childExecutionInfoTableName, record.Table)
+	assert.Equal(t, int64(3), record.ShardID)
+	assert.Equal(t, domainID.String(), record.DomainID)
+	assert.Equal(t, "wid", record.WorkflowID)
+	assert.Equal(t, runID.String(), record.RunID)
+	assert.Equal(t, []string{"5", "7"}, record.Keys)
+	assert.False(t, record.Timestamp.IsZero())
+	assert.Len(t, sent.queries, 1)
+
+	errs := pdb.DeleteMapsForExecutions(ctx, []sqlplugin.ExecutionsFilter{{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID}}, 1, 0)
+	require.NoError(t, errs[0])
+	assert.Len(t, sink.records, 7)
+	assert.Empty(t, sink.records[6].Keys)
+
+	// the delete is not performed when it cannot be audited
+	sink.err = errors.New("disk full")
+	_, err = pdb.DeleteFromSignalsRequestedSets(ctx, &sqlplugin.SignalsRequestedSetsFilter{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID})
+	assert.EqualError(t, err, "failed to audit delete from signals_requested_sets: disk full")
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := newFileAuditSink(path)
+	require.NoError(t, err)
+	// the DBs of the process share the sink of a path, it stays open until the last of them closes it
+	shared, err := newFileAuditSink(path)
+	require.NoError(t, err)
+	assert.Same(t, sink, shared)
+	require.NoError(t, sink.write(mapDeleteAuditRecord{Actor: "a", Table: timerInfoTableName, Keys: []string{"t1"}}))
+	require.NoError(t, sink.close())
+	require.NoError(t, shared.write(mapDeleteAuditRecord{Actor: "b", Table: signalInfoTableName}))
+	require.NoError(t, shared.close())
+	assert.Empty(t, fileAuditSinks.byPath)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var record mapDeleteAuditRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "a", record.Actor)
+	assert.Equal(t, []string{"t1"}, record.Keys)
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "b", record.Actor)
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	// batchInsertModeMultiRow sends all rows of a batch in a single INSERT with a multi-row VALUES list
+	batchInsertModeMultiRow = "multiRow"
+	// batchInsertModeSingleRow sends one INSERT per row of a batch, one after another on the same connection
+	batchInsertModeSingleRow = "singleRow"
+
+	// returningInserted makes an INSERT ... ON CONFLICT DO UPDATE query return whether each row was inserted,
+	// xmax is only set on the row versions written by the DO UPDATE branch
+	returningInserted = "\nRETURNING (xmax = 0) AS inserted"
+	// returningChangedRow is returningInserted with the primary key of each row, %v is the map key column.
+	// The key is cast with CAST since sqlx reads :: as an escaped colon
+	returningChangedRow = returningInserted + ", shard_id, domain_id, workflow_id, run_id, CAST(%v AS text) AS map_key"
+)
+
+type batchResult int64
+
+var _ sql.Result = batchResult(0)
+
+func (r batchResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported by this driver")
+}
+
+func (r batchResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// upsertResult is the result of namedUpsertBatch
+type upsertResult struct {
+	inserted int64
+	updated  int64
+}
+
+var _ sql.Result = upsertResult{}
+
+func (r upsertResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported by this driver")
+}
+
+func (r upsertResult) RowsAffected() (int64, error) {
+	return r.inserted + r.updated, nil
+}
+
+// atomicBatch runs write, which sends the n rows of a batch, so that a failure midway leaves none of the rows written.
+// A batch sent as a single statement or within a transaction is already atomic, otherwise write runs within
+// a transaction of its own, which is rolled back if write fails. A batch marked by sqlplugin.WithRelaxedDurability
+// always runs within a transaction outside of one, its commit is where the durability is relaxed.
+func (pdb *db) atomicBatch(ctx context.Context, dbShardID int, n int, write func(pdb *db) error) error {
+	if pdb.isTx {
+		return write(pdb)
+	}
+	if (n <= 1 || pdb.opts.batchInsertMode != batchInsertModeSingleRow) && !sqlplugin.RelaxedDurabilityFromContext(ctx) {
+		return write(pdb)
+	}
+	tx, err := pdb.beginTxWithStatementTimeout(ctx, dbShardID, sql.LevelDefault, pdb.opts.statementTimeouts.BatchWrite)
+	if err != nil {
+		return err
+	}
+	if err := write(tx); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return err
+	}
+	return tx.Commit()
+}
+
+// namedExecBatch writes rows, which must be a slice of row structs, with the given named INSERT query
+// using the batch insert mode of this db. In singleRow mode the batch is only atomic when executed inside a transaction,
+// see atomicBatch.
+func (pdb *db) namedExecBatch(ctx context.Context, dbShardID int, query string, rows interface{}) (sql.Result, error) {
+	if pdb.opts.batchInsertMode != batchInsertModeSingleRow {
+		return pdb.namedExec(ctx, dbShardID, query, rows)
+	}
+	v := reflect.ValueOf(rows)
+	var rowsAffected int64
+	for i := 0; i < v.Len(); i++ {
+		result, err := pdb.namedExec(ctx, dbShardID, query, v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		rowsAffected += n
+	}
+	return batchResult(rowsAffected), nil
+}
+
+// namedExec runs a named write query of an execution map table, a single row is bound positionally if the single
+// row bind mode is positional
+func (pdb *db) namedExec(ctx context.Context, dbShardID int, query string, rows interface{}) (sql.Result, error) {
+	positional, args, ok, err := pdb.positionalMapRow(query, rows)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return pdb.mapDriver().ExecContext(ctx, dbShardID, positional, args...)
+	}
+	return pdb.mapDriver().NamedExecContext(ctx, dbShardID, query, rows)
+}
+
+// bindNamed is BindNamed for the write queries of the execution map tables, a single row is bound like in namedExec
+func (pdb *db) bindNamed(dbShardID int, query string, rows interface{}) (string, []interface{}, error) {
+	if positional, args, ok, err := pdb.positionalMapRow(query, rows); err != nil || ok {
+		return positional, args, err
+	}
+	return pdb.originalDBs[dbShardID].BindNamed(query, rows)
+}
+
+// namedUpsertBatch is namedExecBatch for named INSERT ... ON CONFLICT DO UPDATE queries on table, it tells apart
+// the rows which were inserted from the existing rows which were updated. The change event of each row is returned
+// as well if a change sink is set or the map row count is enabled
+func (pdb *db) namedUpsertBatch(ctx context.Context, dbShardID int, table string, query string, rows interface{}) (upsertResult, []MapChangeEvent, error) {
+	withChanges := pdb.opts.mapChangeSink != nil || pdb.opts.mapRowCounter
+	if withChanges {
+		query += fmt.Sprintf(returningChangedRow, mapKeyColumns[table])
+	} else {
+		query += returningInserted
+	}
+	args := []interface{}{rows}
+	if pdb.opts.batchInsertMode == batchInsertModeSingleRow {
+		v := reflect.ValueOf(rows)
+		args = make([]interface{}, v.Len())
+		for i := range args {
+			args[i] = v.Index(i).Interface()
+		}
+	}
+	var result upsertResult
+	var events []MapChangeEvent
+	for _, arg := range args {
+		boundQuery, boundArgs, err := pdb.bindNamed(dbShardID, query, arg)
+		if err != nil {
+			return upsertResult{}, nil, err
+		}
+		var inserted []bool
+		if withChanges {
+			var changed []changedMapRow
+			if err := pdb.mapDriver().SelectContext(ctx, dbShardID, &changed, boundQuery, boundArgs...); err != nil {
+				return upsertResult{}, nil, err
+			}
+			for _, row := range changed {
+				inserted = append(inserted, row.Inserted)
+				events = append(events, row.toEvent(table))
+			}
+		} else if err := pdb.mapDriver().SelectContext(ctx, dbShardID, &inserted, boundQuery, boundArgs...); err != nil {
+			return upsertResult{}, nil, err
+		}
+		for _, ok := range inserted {
+			if ok {
+				result.inserted++
+			} else {
+				result.updated++
+			}
+		}
+	}
+	return result, events, nil
+}
@@ -0,0 +1,446 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/metrics"
+	pt "github.com/uber/cadence/common/persistence/persistence-tests"
+	"github.com/uber/cadence/common/persistence/serialization"
+	sqlpersistence "github.com/uber/cadence/common/persistence/sql"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/testflags"
+)
+
+// BenchmarkReplaceIntoActivityInfoMaps compares the batch insert modes against a live postgres, run it with
+//
+//	POSTGRES=1 go test -run=^$ -bench=BenchmarkReplaceIntoActivityInfoMaps ./common/persistence/sql/sqlplugin/postgres/
+//
+// multiRow pays one round trip per batch and wins whenever network latency dominates, singleRow pays one round trip
+// per row but keeps the statement shape fixed, which is what makes it competitive with drivers or poolers that cannot
+// reuse plans for statements whose text changes with the batch size.
+func BenchmarkReplaceIntoActivityInfoMaps(b *testing.B) {
+	testflags.RequirePostgres(b)
+	options := GetTestClusterOption()
+	testCluster := sqlpersistence.NewTestCluster(PluginName, "test_"+pt.GenerateRandomDBName(10), options.DBUsername, options.DBPassword, options.DBHost, options.DBPort, options.SchemaDir)
+	testCluster.SetupTestDatabase()
+	defer testCluster.TearDownTestDatabase()
+
+	for _, mode := range []string{batchInsertModeMultiRow, batchInsertModeSingleRow} {
+		for _, batchSize := range []int{1, 10, 100} {
+			b.Run(fmt.Sprintf("%v/%v", mode, batchSize), func(b *testing.B) {
+				cfg := *testCluster.Config().DataStores["test"].SQL
+				cfg.BatchInsertMode = mode
+				pdb, err := (&plugin{}).CreateDB(&cfg)
+				require.NoError(b, err)
+				defer pdb.Close()
+
+				domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+				runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+				rows := make([]sqlplugin.ActivityInfoMapsRow, batchSize)
+				for i := range rows {
+					rows[i] = sqlplugin.ActivityInfoMapsRow{
+						ShardID:                  1,
+						DomainID:                 domainID,
+						WorkflowID:               fmt.Sprintf("%v-%v", mode, batchSize),
+						RunID:                    runID,
+						ScheduleID:               int64(i),
+						Data:                     []byte("data"),
+						DataEncoding:             "thriftrw",
+						LastHeartbeatUpdatedTime: time.Now(),
+					}
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, err := pdb.ReplaceIntoActivityInfoMaps(context.Background(), rows)
+					require.NoError(b, err)
+				}
+			})
+		}
+	}
+}
+
+func TestUpsertMapRowsCountsInsertsAndUpdates(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, driver := newMockDB(t)
+	pdb.originalDBs = []*sqlx.DB{xdb}
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]bool{true, false, true}))
+	pdb.SetMetricsClient(metrics.NewClient(scope, metrics.History))
+
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}, {ShardID: 1, TimerID: "b"}, {ShardID: 1, TimerID: "c"}}
+	result, err := pdb.ReplaceIntoTimerInfoMaps(sqlplugin.WithMapWriteCause(context.Background(), sqlplugin.MapWriteCauseSchedule), rows)
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.True(t, strings.HasSuffix(sent.query(), "RETURNING (xmax = 0) AS inserted"))
+
+	counters := make(map[string]int64)
+	for _, c := range scope.Snapshot().Counters() {
+		assert.Equal(t, "timer_info_maps", c.Tags()["table"])
+		assert.Equal(t, "schedule", c.Tags()["write_cause"])
+		counters[c.Tags()["write_type"]] = c.Value()
+	}
+	assert.Equal(t, map[string]int64{"insert": 2, "update": 1}, counters)
+}
+
+// stagingConnector is a database/sql connector whose statements each write a row, rows written within a
+// transaction are staged until the transaction commits. The failAt-th statement fails with failWith, or a
+// connection reset if it is nil. The isolation level of each transaction begun is recorded.
+type stagingConnector struct {
+	sync.Mutex
+	failAt     int
+	failWith   error
+	execs      int
+	written    int
+	queries    []string
+	isolations []driver.IsolationLevel
+	// rangeID is returned by every query unless row is set, row returns the columns and values of the single row of
+	// a query, no row if values is nil. writtenArgs are the args of the written statements
+	rangeID     int64
+	row         func(query string) (columns []string, values []driver.Value)
+	writtenArgs [][]driver.NamedValue
+}
+
+func (c *stagingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &stagingConn{connector: c}, nil
+}
+
+func (c *stagingConnector) Driver() driver.Driver {
+	panic("not implemented")
+}
+
+type stagingConn struct {
+	connector  *stagingConnector
+	inTx       bool
+	staged     int
+	stagedArgs [][]driver.NamedValue
+}
+
+func (c *stagingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *stagingConn) Close() error {
+	return nil
+}
+
+func (c *stagingConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+func (c *stagingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.connector.Lock()
+	c.connector.isolations = append(c.connector.isolations, opts.Isolation)
+	c.connector.Unlock()
+	return c.Begin()
+}
+
+func (c *stagingConn) Commit() error {
+	c.connector.Lock()
+	defer c.connector.Unlock()
+	c.connector.written += c.staged
+	c.connector.writtenArgs = append(c.connector.writtenArgs, c.stagedArgs...)
+	c.inTx, c.staged, c.stagedArgs = false, 0, nil
+	return nil
+}
+
+func (c *stagingConn) Rollback() error {
+	c.inTx, c.staged, c.stagedArgs = false, 0, nil
+	return nil
+}
+
+func (c *stagingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.Lock()
+	defer c.connector.Unlock()
+	c.connector.queries = append(c.connector.queries, query)
+	if c.connector.row != nil {
+		columns, values := c.connector.row(query)
+		return &stagingRows{columns: columns, values: values}, nil
+	}
+	return &stagingRows{columns: []string{"range_id"}, values: []driver.Value{c.connector.rangeID}}, nil
+}
+
+// stagingRows is a single row, or none if values is nil
+type stagingRows struct {
+	columns []string
+	values  []driver.Value
+}
+
+func (r *stagingRows) Columns() []string {
+	return r.columns
+}
+
+func (r *stagingRows) Close() error {
+	return nil
+}
+
+func (r *stagingRows) Next(dest []driver.Value) error {
+	if r.values == nil {
+		return io.EOF
+	}
+	copy(dest, r.values)
+	r.values = nil
+	return nil
+}
+
+func (c *stagingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.Lock()
+	defer c.connector.Unlock()
+	c.connector.execs++
+	c.connector.queries = append(c.connector.queries, query)
+	if c.connector.execs == c.connector.failAt {
+		if c.connector.failWith != nil {
+			return nil, c.connector.failWith
+		}
+		return nil, errors.New("connection reset")
+	}
+	if c.inTx {
+		c.staged++
+		c.stagedArgs = append(c.stagedArgs, args)
+	} else {
+		c.connector.written++
+		c.connector.writtenArgs = append(c.connector.writtenArgs, args)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func TestReplaceIsAtomicInSingleRowMode(t *testing.T) {
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}, {ShardID: 1, TimerID: "b"}, {ShardID: 1, TimerID: "c"}}
+	newStagingDB := func(connector *stagingConnector) *db {
+		xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+		xdb.MapperFunc(strcase.ToSnake)
+		pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{
+			batchInsertMode: batchInsertModeSingleRow,
+			queries:         newExecutionMapQueries(""),
+		})
+		require.NoError(t, err)
+		return pdb
+	}
+
+	// the second row fails, the first one must not be written
+	connector := &stagingConnector{failAt: 2}
+	_, err := newStagingDB(connector).ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 2, connector.execs)
+	assert.Equal(t, 0, connector.written)
+
+	connector = &stagingConnector{}
+	result, err := newStagingDB(connector).ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, 3, connector.written)
+}
+
+func TestMergeSignalsRequestedSetIsAtomic(t *testing.T) {
+	filter := &sqlplugin.SignalsRequestedSetsFilter{ShardID: 1, WorkflowID: "wid"}
+	newStagingDB := func(connector *stagingConnector) *db {
+		xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+		xdb.MapperFunc(strcase.ToSnake)
+		pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{queries: newExecutionMapQueries("")})
+		require.NoError(t, err)
+		return pdb
+	}
+
+	// the lock, the delete and the insert are written together
+	connector := &stagingConnector{}
+	require.NoError(t, newStagingDB(connector).MergeSignalsRequestedSet(context.Background(), filter, []string{"a", "b"}, []string{"c"}))
+	assert.Equal(t, 3, connector.execs)
+	assert.Equal(t, 3, connector.written)
+
+	// the insert fails, the delete must not be written
+	connector = &stagingConnector{failAt: 3}
+	err := newStagingDB(connector).MergeSignalsRequestedSet(context.Background(), filter, []string{"a", "b"}, []string{"c"})
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 0, connector.written)
+
+	// without removes nothing is deleted
+	connector = &stagingConnector{}
+	require.NoError(t, newStagingDB(connector).MergeSignalsRequestedSet(context.Background(), filter, []string{"a"}, nil))
+	assert.Equal(t, 2, connector.execs)
+}
+
+func TestWithExecutionTransaction(t *testing.T) {
+	key := &sqlplugin.ExecutionsFilter{ShardID: 1, WorkflowID: "wid"}
+	newStagingDB := func(connector *stagingConnector) *db {
+		xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+		xdb.MapperFunc(strcase.ToSnake)
+		pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{
+			queries:           newExecutionMapQueries(""),
+			statementTimeouts: config.SQLStatementTimeouts{ExecutionWrite: time.Second},
+		})
+		require.NoError(t, err)
+		return pdb
+	}
+	write := func(tx sqlplugin.Tx) error {
+		if _, err := tx.UpdateExecutions(context.Background(), &sqlplugin.ExecutionsRow{ShardID: 1, WorkflowID: "wid"}); err != nil {
+			return err
+		}
+		_, err := tx.DeleteFromTimerInfoMaps(context.Background(), &sqlplugin.TimerInfoMapsFilter{ShardID: 1, WorkflowID: "wid"})
+		return err
+	}
+
+	// the execution row and the map are written together
+	connector := &stagingConnector{}
+	require.NoError(t, newStagingDB(connector).WithExecutionTransaction(context.Background(), key, write))
+	require.Len(t, connector.queries, 3)
+	assert.Equal(t, "SET LOCAL statement_timeout = 1000", connector.queries[0])
+	assert.Equal(t, 3, connector.written)
+
+	// the map write fails, the execution row must not be written
+	connector = &stagingConnector{failAt: 3}
+	err := newStagingDB(connector).WithExecutionTransaction(context.Background(), key, write)
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 0, connector.written)
+
+	// an error of fn rolls the transaction back as well
+	connector = &stagingConnector{}
+	err = newStagingDB(connector).WithExecutionTransaction(context.Background(), key, func(tx sqlplugin.Tx) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		return errors.New("conflict")
+	})
+	assert.EqualError(t, err, "conflict")
+	assert.Equal(t, 0, connector.written)
+}
+
+func TestTransactionHelpersSetStatementTimeout(t *testing.T) {
+	newStagingDB := func(connector *stagingConnector) *db {
+		xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+		xdb.MapperFunc(strcase.ToSnake)
+		pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{
+			batchInsertMode: batchInsertModeSingleRow,
+			queries:         newExecutionMapQueries(""),
+			statementTimeouts: config.SQLStatementTimeouts{
+				BatchWrite:   1500 * time.Millisecond,
+				LockedUpdate: 100 * time.Microsecond,
+			},
+		})
+		require.NoError(t, err)
+		return pdb
+	}
+
+	connector := &stagingConnector{}
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}, {ShardID: 1, TimerID: "b"}}
+	_, err := newStagingDB(connector).ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	require.Len(t, connector.queries, 3)
+	assert.Equal(t, "SET LOCAL statement_timeout = 1500", connector.queries[0])
+
+	// a timeout below a millisecond is rounded up instead of disabling the timeout
+	connector = &stagingConnector{}
+	filter := &sqlplugin.SignalsRequestedSetsFilter{ShardID: 1, WorkflowID: "wid"}
+	require.NoError(t, newStagingDB(connector).MergeSignalsRequestedSet(context.Background(), filter, []string{"a"}, nil))
+	require.Len(t, connector.queries, 3)
+	assert.Equal(t, "SET LOCAL statement_timeout = 1", connector.queries[0])
+
+	// the transaction is not used when the timeout can not be set
+	connector = &stagingConnector{failAt: 1}
+	_, err = newStagingDB(connector).ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 1, connector.execs)
+	assert.Equal(t, 0, connector.written)
+
+	// without a timeout no statement is added
+	connector = &stagingConnector{}
+	pdb := newStagingDB(connector)
+	pdb.opts.statementTimeouts = config.SQLStatementTimeouts{}
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Len(t, connector.queries, 2)
+}
+
+func TestTransactionHelpersRelaxDurability(t *testing.T) {
+	connector := &stagingConnector{}
+	xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{
+		queries:           newExecutionMapQueries(""),
+		statementTimeouts: config.SQLStatementTimeouts{LockedUpdate: time.Second},
+	})
+	require.NoError(t, err)
+	filter := &sqlplugin.SignalsRequestedSetsFilter{ShardID: 1, WorkflowID: "wid"}
+
+	// writes keep full durability unless they are marked
+	require.NoError(t, pdb.MergeSignalsRequestedSet(context.Background(), filter, []string{"a"}, nil))
+	assert.NotContains(t, connector.queries, setLocalSynchronousCommitOffQuery)
+
+	connector.queries = nil
+	require.NoError(t, pdb.MergeSignalsRequestedSet(sqlplugin.WithRelaxedDurability(context.Background()), filter, []string{"a"}, nil))
+	require.Len(t, connector.queries, 4)
+	assert.Equal(t, setLocalSynchronousCommitOffQuery, connector.queries[0])
+	assert.Equal(t, "SET LOCAL statement_timeout = 1000", connector.queries[1])
+}
+
+func TestRelaxedDurabilityOfMultiRowBatches(t *testing.T) {
+	connector := &stagingConnector{}
+	xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{
+		batchInsertMode:   batchInsertModeMultiRow,
+		singleRowBindMode: singleRowBindModePositional,
+		queries:           newExecutionMapQueries(""),
+	})
+	require.NoError(t, err)
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}, {ShardID: 1, TimerID: "b"}}
+
+	// a multi row batch is a single statement which is not run within a transaction unless it is marked
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Empty(t, connector.isolations)
+	assert.Len(t, connector.queries, 1)
+
+	// the marked batches run within a transaction whose commit does not wait for the WAL flush, single rows as well
+	for _, batch := range [][]sqlplugin.TimerInfoMapsRow{rows, rows[:1]} {
+		connector.queries, connector.isolations = nil, nil
+		_, err = pdb.ReplaceIntoTimerInfoMaps(sqlplugin.WithRelaxedDurability(context.Background()), batch)
+		require.NoError(t, err)
+		assert.Len(t, connector.isolations, 1)
+		require.Len(t, connector.queries, 2)
+		assert.Equal(t, setLocalSynchronousCommitOffQuery, connector.queries[0])
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// %v is the list of (shard_id, domain_id, workflow_id) tuples, filled in per query
+const getCurrentExecutionsOfChildrenQueryTemplate = `SELECT shard_id, domain_id, workflow_id, run_id, create_request_id, state, close_status, start_version, last_write_version
+FROM current_executions
+WHERE (shard_id, domain_id, workflow_id) IN (%v)`
+
+var _ sqlplugin.ChildExecutionInfoMapsStatusReader = (*db)(nil)
+
+// SelectChildExecutionInfoWithStatus reads the child rows with SelectFromChildExecutionInfoMaps and then looks up the
+// current runs of all children with one query per DB shard the children are on. The domain and workflow ID of a child
+// are only stored in its data blob and its current run is in the history shard of its workflow ID, so the lookup can
+// not be joined into the query of the child rows
+func (pdb *db) SelectChildExecutionInfoWithStatus(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter, numHistoryShards int) ([]sqlplugin.ChildExecutionInfoWithStatus, error) {
+	if numHistoryShards < 1 {
+		return nil, fmt.Errorf("invalid numHistoryShards %v, it must be positive", numHistoryShards)
+	}
+	rows, err := pdb.SelectFromChildExecutionInfoMaps(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]sqlplugin.ChildExecutionInfoWithStatus, len(rows))
+	// the indexes of the rows in result by the DB shard of their child and by the key of their child
+	type childKey struct {
+		shardID    int
+		domainID   string
+		workflowID string
+	}
+	indexes := make(map[int]map[childKey][]int)
+	for i, row := range rows {
+		result[i].ChildExecutionInfoMapsRow = row
+		info, err := pdb.opts.executionParser.ChildExecutionInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			return nil, err
+		}
+		// rows written before the child domain ID was recorded only carry the domain name
+		if info.DomainID == "" || info.StartedWorkflowID == "" {
+			continue
+		}
+		domainID, err := uuid.Parse(info.DomainID)
+		if err != nil {
+			return nil, err
+		}
+		shardID := common.WorkflowIDToHistoryShard(info.StartedWorkflowID, numHistoryShards)
+		dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(shardID, pdb.GetTotalNumDBShards())
+		if indexes[dbShardID] == nil {
+			indexes[dbShardID] = make(map[childKey][]int)
+		}
+		key := childKey{shardID: shardID, domainID: string(domainID[:]), workflowID: info.StartedWorkflowID}
+		indexes[dbShardID][key] = append(indexes[dbShardID][key], i)
+	}
+	for dbShardID, children := range indexes {
+		tuples := make([]string, 0, len(children))
+		args := make([]interface{}, 0, 3*len(children))
+		for key := range children {
+			tuples = append(tuples, fmt.Sprintf("($%v, $%v, $%v)", len(args)+1, len(args)+2, len(args)+3))
+			args = append(args, key.shardID, serialization.UUID(key.domainID), key.workflowID)
+		}
+		var current []sqlplugin.CurrentExecutionsRow
+		query := fmt.Sprintf(getCurrentExecutionsOfChildrenQueryTemplate, strings.Join(tuples, ", "))
+		if err := pdb.driver.SelectContext(ctx, dbShardID, &current, query, args...); err != nil {
+			return nil, &sqlplugin.PersistenceError{Operation: "SelectChildExecutionInfoWithStatus", Err: err}
+		}
+		for j := range current {
+			key := childKey{shardID: int(current[j].ShardID), domainID: string(current[j].DomainID), workflowID: current[j].WorkflowID}
+			for _, i := range children[key] {
+				result[i].Current = &current[j]
+			}
+		}
+	}
+	return result, nil
+}
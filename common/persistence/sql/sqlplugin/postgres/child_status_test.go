@@ -0,0 +1,86 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestSelectChildExecutionInfoWithStatus(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	childDomainID := "6ddd4ba2-2cb9-4c0b-a4fb-bbe9a2c4c09c"
+	child := func(initiatedID int64, info *serialization.ChildExecutionInfo) sqlplugin.ChildExecutionInfoMapsRow {
+		blob, err := parser.ChildExecutionInfoToBlob(info)
+		require.NoError(t, err)
+		return sqlplugin.ChildExecutionInfoMapsRow{InitiatedID: initiatedID, Data: blob.Data, DataEncoding: string(blob.Encoding)}
+	}
+	const numHistoryShards = 16
+	running := sqlplugin.CurrentExecutionsRow{
+		ShardID:    int64(common.WorkflowIDToHistoryShard("running-child", numHistoryShards)),
+		DomainID:   serialization.MustParseUUID(childDomainID),
+		WorkflowID: "running-child",
+		RunID:      serialization.MustParseUUID("4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3"),
+		State:      persistence.WorkflowStateRunning,
+	}
+	pdb, driver := newMockDB(t)
+	pdb.opts.executionParser = parser
+	gomock.InOrder(
+		driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn((&sentStatements{}).rows([]sqlplugin.ChildExecutionInfoMapsRow{
+			child(5, &serialization.ChildExecutionInfo{DomainID: childDomainID, StartedWorkflowID: "running-child"}),
+			child(6, &serialization.ChildExecutionInfo{DomainID: childDomainID, StartedWorkflowID: "deleted-child"}),
+			child(7, &serialization.ChildExecutionInfo{DomainNameDEPRECATED: "domain", StartedWorkflowID: "running-child"}),
+		})),
+		// the children of one DB shard are looked up in one query, which finds the current run of the running child only
+		driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ int, dest interface{}, _ string, args ...interface{}) error {
+				for i := 0; i < len(args); i += 3 {
+					if int64(args[i].(int)) == running.ShardID && string(args[i+1].(serialization.UUID)) == string(running.DomainID) && args[i+2] == running.WorkflowID {
+						*dest.(*[]sqlplugin.CurrentExecutionsRow) = append(*dest.(*[]sqlplugin.CurrentExecutionsRow), running)
+					}
+				}
+				return nil
+			}),
+	)
+
+	result, err := pdb.SelectChildExecutionInfoWithStatus(context.Background(), &sqlplugin.ChildExecutionInfoMapsFilter{ShardID: 1}, numHistoryShards)
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+	assert.Equal(t, int64(5), result[0].InitiatedID)
+	assert.Equal(t, &running, result[0].Current)
+	assert.Equal(t, int64(6), result[1].InitiatedID)
+	assert.Nil(t, result[1].Current)
+	assert.Equal(t, int64(7), result[2].InitiatedID)
+	assert.Nil(t, result[2].Current, "a row without the domain ID of its child is not looked up")
+
+	_, err = pdb.SelectChildExecutionInfoWithStatus(context.Background(), &sqlplugin.ChildExecutionInfoMapsFilter{ShardID: 1}, 0)
+	assert.Error(t, err)
+}
@@ -23,13 +23,21 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence/serialization"
 	"github.com/uber/cadence/common/persistence/sql/sqldriver"
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
 )
 
 type (
@@ -38,6 +46,91 @@ type (
 		driver      sqldriver.Driver
 		originalDBs []*sqlx.DB
 		numDBShards int
+		opts        dbOptions
+		isTx        bool
+		// txDBShardID is the dbShardID the transaction was started on
+		txDBShardID int
+		// executions whose activity_info_maps rows were written in this transaction,
+		// they are invalidated in the activity info maps cache once the transaction commits
+		txActivityInfoMapsWrites []activityInfoMapsCacheKey
+		// change events of the execution map rows written in this transaction,
+		// they are sent to the change sink once the transaction commits
+		txMapChanges []MapChangeEvent
+	}
+
+	// dbOptions holds the settings derived from config.SQL, they are shared by a db and all of its transactions
+	dbOptions struct {
+		// streamFetchSize is the number of rows the streaming Select methods fetch at a time, 0 keeps their default
+		streamFetchSize int
+		batchInsertMode string
+		// singleRowBindMode is how a write of a single execution map row is bound, named unless configured
+		singleRowBindMode string
+		queries           *executionMapQueries
+		// activityInfoMapsCache is nil unless the cache is enabled in config
+		activityInfoMapsCache *activityInfoMapsCache
+		// mapMetrics is nil unless set through SetMetricsClient or SetExecutionMapMetrics
+		mapMetrics ExecutionMapMetrics
+		// logger is nil unless set through SetLogger
+		logger log.Logger
+		// auditSink is nil unless the map delete audit log is enabled in config
+		auditSink auditSink
+		// maxExecutionMapRows is the number of rows an execution can have in each execution map table, 0 is unlimited
+		maxExecutionMapRows int
+		// mapTxIsolation is the isolation level of the transactions started by txExecute
+		mapTxIsolation sql.IsolationLevel
+		// statementTimeouts are set with SET LOCAL at the start of the transactions started by the transaction helpers
+		statementTimeouts config.SQLStatementTimeouts
+		// asyncWriter is nil unless the async map write queue is enabled in config
+		asyncWriter *asyncMapWriter
+		// rowCountReporter is nil unless the background count of the execution map rows is enabled in config
+		rowCountReporter *mapRowCountReporter
+		// applicationName is empty unless the transactions are labeled with the class of their operation in config,
+		// the label is applicationName followed by the class
+		applicationName string
+		// timerParser decodes the timers validated by ReplaceIntoTimerInfoMapsWithReferenceTime, it is nil unless
+		// timerMaxStaleness is set in config
+		timerParser       serialization.Parser
+		timerMaxStaleness time.Duration
+		timerStrictMode   bool
+		// signalsStrictMode fails the inserts into signals_requested_sets of signal IDs which are already in the set
+		signalsStrictMode bool
+		// executionParser decodes the executions checked by DeleteFromActivityInfoMapsIfClosed
+		executionParser serialization.Parser
+		// decodeFallback is nil unless a fallback encoding of the execution map rows is configured
+		decodeFallback *decodeFallback
+		// metricsClient is nil unless set through SetMetricsClient
+		metricsClient metrics.Client
+		// dbShardOverrideEnabled allows the context to override the dbShardID of the execution map tables, for tests only
+		dbShardOverrideEnabled bool
+		// mapOpLimiter is nil unless the execution map statements in flight per dbShardID are bounded in config
+		mapOpLimiter *mapOpLimiter
+		// retryBudget is nil unless the retries of the execution map operations per dbShardID are bounded in config
+		retryBudget *mapRetryBudget
+		// domainWriteLimiter is nil unless QPS limits of the execution map writes of some domains are configured
+		domainWriteLimiter *domainWriteLimiter
+		// mapWriteMaintenance is nil unless set through SetMapWriteMaintenance, the execution map writes fail while it
+		// returns true
+		mapWriteMaintenance func() bool
+		// mapChangeSink is nil unless set through SetMapChangeSink
+		mapChangeSink MapChangeSink
+		// pingTimeout bounds the probe of each DB shard by Ping, 0 is unbounded
+		pingTimeout time.Duration
+		// logFailedMapQueries logs the query and a redacted summary of the parameters of the execution map statements
+		// which fail, it requires a logger set through SetLogger
+		logFailedMapQueries bool
+		// deferredMapDeletion allows the rows of activity_info_maps to be flagged as deleted and swept later, the table
+		// must have the deleted column then
+		deferredMapDeletion bool
+		// mapDeletionConcurrency is the number of execution map tables deleteMapsForExecution deletes from at a time
+		// outside of a transaction, the tables are deleted from one after the other if it is 1 or less
+		mapDeletionConcurrency int
+		// mapRowCounter maintains the map_row_count of the executions rows along with the rows of the execution map tables
+		mapRowCounter bool
+		// mapHooks are the hooks registered through RegisterExecutionMapHooks keyed by table
+		mapHooks map[string]ExecutionMapHooks
+		// previousNumDBShards is the number of DB shards before a migration, the execution map rows missing from their
+		// DB shard are read from the DB shard of this number. 0 unless a migration is in progress
+		previousNumDBShards int
 	}
 )
 
@@ -45,44 +138,100 @@ func (pdb *db) GetTotalNumDBShards() int {
 	return pdb.numDBShards
 }
 
+// mapDBShardID returns the dbShardID the rows of the execution map tables of a history shard are routed to.
+// The override of ctx is used instead if it is enabled in config and in range. A transaction is bound to
+// the dbShardID it was started on, so the override is ignored within a transaction. While the number of DB shards is
+// migrated, a transaction started on the DB shard the rows were routed to before keeps routing them there
+func (pdb *db) mapDBShardID(ctx context.Context, historyShardID int) int {
+	if pdb.opts.dbShardOverrideEnabled && !pdb.isTx {
+		if dbShardID, ok := sqlplugin.DBShardOverrideFromContext(ctx); ok && dbShardID >= 0 && dbShardID < pdb.GetTotalNumDBShards() {
+			return dbShardID
+		}
+	}
+	if pdb.isTx && pdb.opts.previousNumDBShards > 0 &&
+		pdb.txDBShardID == sqlplugin.GetDBShardIDFromHistoryShardID(historyShardID, pdb.opts.previousNumDBShards) {
+		return pdb.txDBShardID
+	}
+	return sqlplugin.GetDBShardIDFromHistoryShardID(historyShardID, pdb.GetTotalNumDBShards())
+}
+
+// ResolveDBShardForExecution returns the dbShardID the execution map rows of historyShardID are routed to by the map
+// operations of ctx. While the number of DB shards is migrated, migrating is true and previousDBShardID is the DB shard
+// of the previous number the rows are still read from and deleted on
+func (pdb *db) ResolveDBShardForExecution(ctx context.Context, historyShardID int) (dbShardID int, previousDBShardID int, migrating bool) {
+	dbShardID = pdb.mapDBShardID(ctx, historyShardID)
+	previousDBShardID, migrating = pdb.previousDBShardID(historyShardID, dbShardID)
+	return dbShardID, previousDBShardID, migrating
+}
+
 var _ sqlplugin.DB = (*db)(nil)
 var _ sqlplugin.Tx = (*db)(nil)
+var _ sqlplugin.MetricsEmitter = (*db)(nil)
+var _ sqlplugin.LogEmitter = (*db)(nil)
+var _ sqlplugin.MapWriteMaintenanceController = (*db)(nil)
+var _ sqlplugin.NumDBShardsRecorder = (*db)(nil)
+var _ sqlplugin.MapRowCountReader = (*db)(nil)
 
 // ErrDupEntry indicates a duplicate primary key i.e. the row already exists,
 // check http://www.postgresql.org/docs/9.3/static/errcodes-appendix.html
 const ErrDupEntry = "23505"
 
+// ErrQueryCanceled indicates a statement was canceled, like when it ran longer than the statement_timeout
+const ErrQueryCanceled = "57014"
+
 const ErrInsufficientResources = "53000"
 const ErrTooManyConnections = "53300"
 
+// ErrSerializationFailure indicates a transaction was aborted because it could not be
+// serialized with a concurrent transaction, the whole transaction can be retried
+const ErrSerializationFailure = "40001"
+
 func (pdb *db) IsDupEntryError(err error) bool {
-	sqlErr, ok := err.(*pq.Error)
+	var sqlErr *pq.Error
+	ok := errors.As(err, &sqlErr)
 	return ok && sqlErr.Code == ErrDupEntry
 }
 
 func (pdb *db) IsNotFoundError(err error) bool {
-	return err == sql.ErrNoRows
+	return errors.Is(err, sql.ErrNoRows)
 }
 
 func (pdb *db) IsTimeoutError(err error) bool {
-	return err == context.DeadlineExceeded
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var sqlErr *pq.Error
+	ok := errors.As(err, &sqlErr)
+	return ok && sqlErr.Code == ErrQueryCanceled
+}
+
+// IsSerializationFailureError returns true if the transaction was aborted by a serialization failure
+func (pdb *db) IsSerializationFailureError(err error) bool {
+	var sqlErr *pq.Error
+	ok := errors.As(err, &sqlErr)
+	return ok && sqlErr.Code == ErrSerializationFailure
 }
 
 func (pdb *db) IsThrottlingError(err error) bool {
-	sqlErr, ok := err.(*pq.Error)
+	var sqlErr *pq.Error
+	ok := errors.As(err, &sqlErr)
 	if ok {
 		if sqlErr.Code == ErrTooManyConnections ||
 			sqlErr.Code == ErrInsufficientResources {
 			return true
 		}
 	}
-	return false
+	// writes paused for maintenance are retried with backoff like the writes the database is too busy for, and so are
+	// the operations over their domain limit or over the retry budget of their DB shard, wrapped or not
+	var maintenanceErr *sqlplugin.MaintenanceError
+	var busyErr *types.ServiceBusyError
+	return errors.As(err, &maintenanceErr) || errors.As(err, &busyErr)
 }
 
 // newDB returns an instance of DB, which is a logical
 // connection to the underlying postgres database
 // dbShardID is needed when tx is not nil
-func newDB(xdbs []*sqlx.DB, tx *sqlx.Tx, dbShardID int, numDBShards int) (*db, error) {
+func newDB(xdbs []*sqlx.DB, tx *sqlx.Tx, dbShardID int, numDBShards int, opts dbOptions) (*db, error) {
 	driver, err := sqldriver.NewDriver(xdbs, tx, dbShardID)
 	if err != nil {
 		return nil, err
@@ -93,32 +242,288 @@ func newDB(xdbs []*sqlx.DB, tx *sqlx.Tx, dbShardID int, numDBShards int) (*db, e
 		originalDBs: xdbs, // this is kept because newDB will be called again when starting a transaction
 		driver:      driver,
 		numDBShards: numDBShards,
+		opts:        opts,
+		isTx:        tx != nil,
+		txDBShardID: dbShardID,
+	}
+	if opts.asyncWriter != nil && !db.isTx {
+		opts.asyncWriter.start(db)
+	}
+	if opts.rowCountReporter != nil && !db.isTx {
+		opts.rowCountReporter.start(db)
 	}
 	return db, nil
 }
 
 // BeginTx starts a new transaction and returns a reference to the Tx object
 func (pdb *db) BeginTx(ctx context.Context, dbShardID int) (sqlplugin.Tx, error) {
-	xtx, err := pdb.driver.BeginTxx(ctx, dbShardID, nil)
+	return pdb.BeginTxWithIsolation(ctx, dbShardID, sql.LevelDefault)
+}
+
+// BeginTxWithIsolation starts a new transaction with the given isolation level
+// and returns a reference to the Tx object. sql.LevelDefault uses the default of the connection pool
+func (pdb *db) BeginTxWithIsolation(ctx context.Context, dbShardID int, level sql.IsolationLevel) (sqlplugin.Tx, error) {
+	return pdb.beginTx(ctx, dbShardID, level)
+}
+
+func (pdb *db) beginTx(ctx context.Context, dbShardID int, level sql.IsolationLevel) (*db, error) {
+	var opts *sql.TxOptions
+	if level != sql.LevelDefault {
+		opts = &sql.TxOptions{Isolation: level}
+	}
+	xtx, err := pdb.driver.BeginTxx(ctx, dbShardID, opts)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := newDB(pdb.originalDBs, xtx, dbShardID, pdb.numDBShards, pdb.opts)
 	if err != nil {
 		return nil, err
 	}
-	return newDB(pdb.originalDBs, xtx, dbShardID, pdb.numDBShards)
+	if err := tx.labelTx(ctx, dbShardID); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	if err := tx.relaxTxDurability(ctx, dbShardID); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	return tx, nil
+}
+
+// relaxTxDurability turns synchronous_commit off for the transaction if ctx was marked by
+// sqlplugin.WithRelaxedDurability. Its commit then does not wait for its WAL to be flushed, which can lose the
+// transaction if the server crashes right after the commit returned
+func (pdb *db) relaxTxDurability(ctx context.Context, dbShardID int) error {
+	if !sqlplugin.RelaxedDurabilityFromContext(ctx) {
+		return nil
+	}
+	_, err := pdb.driver.ExecContext(ctx, dbShardID, setLocalSynchronousCommitOffQuery)
+	return err
+}
+
+// labelTx sets the application_name of the transaction to the configured application name followed by the class of
+// the operation of ctx, it is reset once the transaction ends. Nothing is set when either of them is missing
+func (pdb *db) labelTx(ctx context.Context, dbShardID int) error {
+	class := sqlplugin.OperationClassFromContext(ctx)
+	if pdb.opts.applicationName == "" || class == "" {
+		return nil
+	}
+	_, err := pdb.driver.ExecContext(ctx, dbShardID, setLocalApplicationNameQuery, pdb.opts.applicationName+": "+class)
+	return err
 }
 
 // Commit commits a previously started transaction
 func (pdb *db) Commit() error {
-	return pdb.driver.Commit()
+	err := pdb.driver.Commit()
+	for _, key := range pdb.txActivityInfoMapsWrites {
+		pdb.opts.activityInfoMapsCache.invalidate(key)
+	}
+	pdb.txActivityInfoMapsWrites = nil
+	if err == nil && len(pdb.txMapChanges) > 0 {
+		pdb.opts.mapChangeSink.MapRowsChanged(pdb.txMapChanges)
+	}
+	pdb.txMapChanges = nil
+	return err
 }
 
 // Rollback triggers rollback of a previously started transaction
 func (pdb *db) Rollback() error {
+	pdb.txMapChanges = nil
 	return pdb.driver.Rollback()
 }
 
+// SetMetricsClient enables the metrics of this db, transactions started afterwards share the client
+func (pdb *db) SetMetricsClient(metricsClient metrics.Client) {
+	pdb.opts.metricsClient = metricsClient
+	if metricsClient == nil {
+		pdb.opts.mapMetrics = nil
+		return
+	}
+	pdb.opts.mapMetrics = &clientMapMetrics{client: metricsClient}
+}
+
+// SetExecutionMapMetrics replaces the metrics client for the metrics of the execution map tables,
+// transactions started afterwards share them
+func (pdb *db) SetExecutionMapMetrics(mapMetrics ExecutionMapMetrics) {
+	pdb.opts.mapMetrics = mapMetrics
+}
+
+// SetLogger enables the warnings logged by this db, transactions started afterwards share the logger.
+// The fingerprints of the execution map queries are logged once at debug level
+func (pdb *db) SetLogger(logger log.Logger) {
+	pdb.opts.logger = logger
+	if logger != nil {
+		pdb.logQueryFingerprints()
+	}
+}
+
+// SetMapWriteMaintenance pauses the execution map writes of this db and its transactions while maintenance returns true,
+// the writes fail with a *sqlplugin.MaintenanceError and the reads are not affected
+func (pdb *db) SetMapWriteMaintenance(maintenance func() bool) {
+	pdb.opts.mapWriteMaintenance = maintenance
+}
+
+func (pdb *db) mapWritesPaused() bool {
+	return pdb.opts.mapWriteMaintenance != nil && pdb.opts.mapWriteMaintenance()
+}
+
+// DBShardTimeoutError is reported by Ping and ProbeWrites for a DB shard which did not answer in time
+type DBShardTimeoutError struct {
+	DBShardID int
+}
+
+func (e *DBShardTimeoutError) Error() string {
+	return fmt.Sprintf("dbShardID %v did not answer in time", e.DBShardID)
+}
+
+// SlowDBShards returns the sorted dbShardIDs of the result of Ping or ProbeWrites which did not answer in time
+func SlowDBShards(reachability map[int]error) []int {
+	var slow []int
+	for dbShardID, err := range reachability {
+		if _, ok := err.(*DBShardTimeoutError); ok {
+			slow = append(slow, dbShardID)
+		}
+	}
+	sort.Ints(slow)
+	return slow
+}
+
+// Ping checks the connection pool of every DB shard concurrently and returns the reachability keyed by dbShardID.
+// It returns as soon as every DB shard answered, or one of them did not answer in time, in which case every DB shard
+// which did not answer yet is reported with a *DBShardTimeoutError
+func (pdb *db) Ping(ctx context.Context) map[int]error {
+	return pdb.probeDBShards(ctx, func(ctx context.Context, dbShardID int) error {
+		return pdb.driver.PingContext(ctx, dbShardID)
+	})
+}
+
+// ProbeWrites upserts and deletes a sentinel row of db_shard_health on every DB shard concurrently and returns whether
+// the writes succeeded keyed by dbShardID, like Ping. Every probe writes the same row, so concurrent probes only
+// contend on it briefly, and a row left behind by a failed delete is replaced by the next probe
+func (pdb *db) ProbeWrites(ctx context.Context) map[int]error {
+	return pdb.probeDBShards(ctx, func(ctx context.Context, dbShardID int) error {
+		if _, err := pdb.driver.ExecContext(ctx, dbShardID, upsertDBShardHealthQuery, dbShardHealthID, time.Now().UTC()); err != nil {
+			return err
+		}
+		_, err := pdb.driver.ExecContext(ctx, dbShardID, deleteDBShardHealthQuery, dbShardHealthID)
+		return err
+	})
+}
+
+// probeDBShards runs probe on every DB shard concurrently, see Ping
+func (pdb *db) probeDBShards(ctx context.Context, probe func(ctx context.Context, dbShardID int) error) map[int]error {
+	type probeResult struct {
+		dbShardID int
+		err       error
+	}
+	numDBShards := pdb.GetTotalNumDBShards()
+	// buffered, so the probes still running when probeDBShards returns do not leak
+	results := make(chan probeResult, numDBShards)
+	for dbShardID := 0; dbShardID < numDBShards; dbShardID++ {
+		go func(dbShardID int) {
+			results <- probeResult{dbShardID: dbShardID, err: pdb.probeDBShard(ctx, dbShardID, probe)}
+		}(dbShardID)
+	}
+	result := make(map[int]error, numDBShards)
+	for len(result) < numDBShards {
+		var timedOut bool
+		select {
+		case r := <-results:
+			result[r.dbShardID] = r.err
+			_, timedOut = r.err.(*DBShardTimeoutError)
+		case <-ctx.Done():
+			timedOut = true
+		}
+		if timedOut {
+			for dbShardID := 0; dbShardID < numDBShards; dbShardID++ {
+				if _, ok := result[dbShardID]; !ok {
+					result[dbShardID] = &DBShardTimeoutError{DBShardID: dbShardID}
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (pdb *db) probeDBShard(ctx context.Context, dbShardID int, probe func(ctx context.Context, dbShardID int) error) error {
+	if pdb.opts.pingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pdb.opts.pingTimeout)
+		defer cancel()
+	}
+	err := probe(ctx, dbShardID)
+	if err != nil && ctx.Err() != nil {
+		return &DBShardTimeoutError{DBShardID: dbShardID}
+	}
+	return err
+}
+
+const (
+	// the label is bound as a parameter, which SET LOCAL does not accept, is_local true makes set_config behave the same
+	setLocalApplicationNameQuery = `SELECT set_config('application_name', $1, true)`
+
+	// db_shard_health holds the sentinel row written by ProbeWrites, its id is always dbShardHealthID
+	dbShardHealthID          = 1
+	upsertDBShardHealthQuery = `INSERT INTO db_shard_health (id, probed_time) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET probed_time = excluded.probed_time`
+	deleteDBShardHealthQuery = `DELETE FROM db_shard_health WHERE id = $1`
+)
+
+const (
+	// db_shard_metadata has a single row, its id is always dbShardMetadataID
+	dbShardMetadataID           = 1
+	insertNumDBShardsQuery      = `INSERT INTO db_shard_metadata (id, num_db_shards) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`
+	migrateNumDBShardsQuery     = `UPDATE db_shard_metadata SET num_db_shards = $2 WHERE id = $1 AND num_db_shards = $3`
+	getRecordedNumDBShardsQuery = `SELECT num_db_shards FROM db_shard_metadata WHERE id = $1`
+)
+
+// RecordNumDBShards stores the number of DB shards in the default DB shard the first time it is called against
+// a database and returns the number stored, which differs from GetTotalNumDBShards if the config was changed since.
+// While the number of DB shards is migrated, a stored previous number is replaced by GetTotalNumDBShards, since the
+// change was intended, so the number stays in sync once the migration completes
+func (pdb *db) RecordNumDBShards(ctx context.Context) (int, error) {
+	if _, err := pdb.driver.ExecContext(ctx, sqlplugin.DbDefaultShard, insertNumDBShardsQuery, dbShardMetadataID, pdb.GetTotalNumDBShards()); err != nil {
+		return 0, err
+	}
+	if pdb.opts.previousNumDBShards > 0 {
+		_, err := pdb.driver.ExecContext(ctx, sqlplugin.DbDefaultShard, migrateNumDBShardsQuery, dbShardMetadataID, pdb.GetTotalNumDBShards(), pdb.opts.previousNumDBShards)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var recorded int
+	err := pdb.driver.GetContext(ctx, sqlplugin.DbDefaultShard, &recorded, getRecordedNumDBShardsQuery, dbShardMetadataID)
+	return recorded, err
+}
+
 // Close closes the connection to the mysql db
 func (pdb *db) Close() error {
-	return pdb.driver.Close()
+	// the queued writes need the connections, so they are drained first
+	var err error
+	if !pdb.isTx {
+		err = pdb.opts.close(pdb)
+	}
+	if closeErr := pdb.driver.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// close releases the resources held by the options, like the audit log file. pdb is the DB closing them, or nil if
+// the DB failed to be created
+func (opts dbOptions) close(pdb *db) error {
+	var err error
+	if opts.rowCountReporter != nil {
+		opts.rowCountReporter.close()
+	}
+	if opts.asyncWriter != nil {
+		err = opts.asyncWriter.unref(pdb)
+	}
+	if opts.auditSink != nil {
+		if closeErr := opts.auditSink.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // PluginName returns the name of the mysql plugin
@@ -0,0 +1,190 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestRecordNumDBShards(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	gomock.InOrder(
+		driver.EXPECT().ExecContext(gomock.Any(), 0, insertNumDBShardsQuery, dbShardMetadataID, 4).Return(batchResult(0), nil),
+		driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), getRecordedNumDBShardsQuery, gomock.Any()).DoAndReturn((&sentStatements{}).rows(2)),
+	)
+	recorded, err := pdb.RecordNumDBShards(context.Background())
+	require.NoError(t, err)
+	// the number recorded by an earlier process is returned, the insert does not overwrite it
+	assert.Equal(t, 2, recorded)
+}
+
+func TestRecordNumDBShardsDuringMigration(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	pdb.opts.previousNumDBShards = 2
+	gomock.InOrder(
+		driver.EXPECT().ExecContext(gomock.Any(), 0, insertNumDBShardsQuery, dbShardMetadataID, 4).Return(batchResult(0), nil),
+		driver.EXPECT().ExecContext(gomock.Any(), 0, migrateNumDBShardsQuery, dbShardMetadataID, 4, 2).Return(batchResult(1), nil),
+		driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), getRecordedNumDBShardsQuery, gomock.Any()).DoAndReturn((&sentStatements{}).rows(4)),
+	)
+	recorded, err := pdb.RecordNumDBShards(context.Background())
+	require.NoError(t, err)
+	// the previous number is replaced, so the number recorded matches the config once the migration completes
+	assert.Equal(t, 4, recorded)
+
+	failure := errors.New("connection reset")
+	gomock.InOrder(
+		driver.EXPECT().ExecContext(gomock.Any(), 0, insertNumDBShardsQuery, dbShardMetadataID, 4).Return(batchResult(0), nil),
+		driver.EXPECT().ExecContext(gomock.Any(), 0, migrateNumDBShardsQuery, dbShardMetadataID, 4, 2).Return(nil, failure),
+	)
+	_, err = pdb.RecordNumDBShards(context.Background())
+	assert.Equal(t, failure, err)
+}
+
+func TestMapDBShardID(t *testing.T) {
+	pinned := sqlplugin.WithDBShardOverride(context.Background(), 3)
+	outOfRange := sqlplugin.WithDBShardOverride(context.Background(), 4)
+	tests := map[string]struct {
+		ctx      context.Context
+		enabled  bool
+		isTx     bool
+		expected int
+	}{
+		"no override":                 {ctx: context.Background(), enabled: true, expected: 1},
+		"override disabled":           {ctx: pinned, expected: 1},
+		"override":                    {ctx: pinned, enabled: true, expected: 3},
+		"override out of range":       {ctx: outOfRange, enabled: true, expected: 1},
+		"override within transaction": {ctx: pinned, enabled: true, isTx: true, expected: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pdb := &db{numDBShards: 4, isTx: test.isTx, opts: dbOptions{dbShardOverrideEnabled: test.enabled}}
+			assert.Equal(t, test.expected, pdb.mapDBShardID(test.ctx, 5))
+		})
+	}
+}
+
+func TestResolveDBShardForExecution(t *testing.T) {
+	pinned := sqlplugin.WithDBShardOverride(context.Background(), 3)
+	tests := map[string]struct {
+		ctx                 context.Context
+		previousNumDBShards int
+		shardID             int
+		expected            int
+		expectedPrevious    int
+		expectedMigrating   bool
+	}{
+		"no migration":                {ctx: context.Background(), shardID: 5, expected: 1},
+		"override":                    {ctx: pinned, shardID: 5, expected: 3},
+		"migration":                   {ctx: context.Background(), previousNumDBShards: 2, shardID: 6, expected: 2, expectedPrevious: 0, expectedMigrating: true},
+		"migration to the same shard": {ctx: context.Background(), previousNumDBShards: 2, shardID: 5, expected: 1},
+		"override during migration":   {ctx: pinned, previousNumDBShards: 2, shardID: 6, expected: 3},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pdb := &db{numDBShards: 4, opts: dbOptions{dbShardOverrideEnabled: true, previousNumDBShards: test.previousNumDBShards}}
+			dbShardID, previous, migrating := pdb.ResolveDBShardForExecution(test.ctx, test.shardID)
+			assert.Equal(t, test.expected, dbShardID)
+			assert.Equal(t, test.expectedMigrating, migrating)
+			if migrating {
+				assert.Equal(t, test.expectedPrevious, previous)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	unreachable := errors.New("connection refused")
+	var hang map[int]bool
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	pdb.opts.pingTimeout = 50 * time.Millisecond
+	// the DB shards in hang only answer once ctx is done
+	driver.EXPECT().PingContext(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, dbShardID int) error {
+		if hang[dbShardID] {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if dbShardID == 2 {
+			return unreachable
+		}
+		return nil
+	}).AnyTimes()
+	assert.Equal(t, map[int]error{0: nil, 1: nil, 2: unreachable, 3: nil}, pdb.Ping(context.Background()))
+
+	// the hanging DB shards do not delay the result beyond the timeout
+	hang = map[int]bool{1: true, 3: true}
+	start := time.Now()
+	result := pdb.Ping(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, unreachable, result[2])
+	assert.Equal(t, []int{1, 3}, SlowDBShards(result))
+
+	// without a timeout the deadline of the context bounds the check
+	pdb.opts.pingTimeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result = pdb.Ping(ctx)
+	assert.Len(t, result, 4)
+	assert.Equal(t, []int{1, 3}, SlowDBShards(result))
+}
+
+func TestProbeWrites(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 3
+	// the writes to DB shard 1 fail, the sentinel row is deleted after it is written to the others
+	for _, dbShardID := range []int{0, 2} {
+		gomock.InOrder(
+			driver.EXPECT().ExecContext(gomock.Any(), dbShardID, upsertDBShardHealthQuery, gomock.Any()).Return(batchResult(1), nil),
+			driver.EXPECT().ExecContext(gomock.Any(), dbShardID, deleteDBShardHealthQuery, gomock.Any()).Return(batchResult(1), nil),
+		)
+	}
+	driver.EXPECT().ExecContext(gomock.Any(), 1, upsertDBShardHealthQuery, gomock.Any()).Return(nil, errors.New("cannot execute INSERT in a read-only transaction"))
+	result := pdb.ProbeWrites(context.Background())
+	assert.Len(t, result, 3)
+	assert.NoError(t, result[0])
+	assert.Error(t, result[1])
+	assert.NoError(t, result[2])
+}
+
+func TestLabelTx(t *testing.T) {
+	tx, driver := newMockDB(t)
+	tx.isTx = true
+	tx.opts.applicationName = "cadence-history"
+	ctx := sqlplugin.WithOperationClass(context.Background(), "UpdateWorkflowExecution")
+	driver.EXPECT().ExecContext(gomock.Any(), 0, setLocalApplicationNameQuery, "cadence-history: UpdateWorkflowExecution").Return(batchResult(0), nil)
+	require.NoError(t, tx.labelTx(ctx, 0))
+
+	// nothing is set without the class of the operation or without labeling enabled
+	require.NoError(t, tx.labelTx(context.Background(), 0))
+	tx.opts.applicationName = ""
+	require.NoError(t, tx.labelTx(ctx, 0))
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// previousDBShardID returns the dbShardID the execution map rows of historyShardID were routed to before the number of
+// DB shards was increased. ok is false unless a migration is in progress and the rows were routed to another DB shard
+// than dbShardID. A transaction is bound to the DB shard it was started on, so it never falls back, and neither does
+// an operation pinned to a DB shard with sqlplugin.WithDBShardOverride
+func (pdb *db) previousDBShardID(historyShardID int, dbShardID int) (previous int, ok bool) {
+	if pdb.opts.previousNumDBShards == 0 || pdb.isTx {
+		return 0, false
+	}
+	if dbShardID != sqlplugin.GetDBShardIDFromHistoryShardID(historyShardID, pdb.GetTotalNumDBShards()) {
+		return 0, false
+	}
+	previous = sqlplugin.GetDBShardIDFromHistoryShardID(historyShardID, pdb.opts.previousNumDBShards)
+	return previous, previous != dbShardID
+}
+
+// selectMapRows selects the execution map rows of an execution into dest, a pointer to a slice. While the number of DB
+// shards is migrated, the rows are read from the DB shard of the previous number when none are found on dbShardID
+func (pdb *db) selectMapRows(ctx context.Context, historyShardID int, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	err := pdb.mapDriver().SelectContext(ctx, dbShardID, dest, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	previous, ok := pdb.previousDBShardID(historyShardID, dbShardID)
+	if !ok || reflect.ValueOf(dest).Elem().Len() > 0 {
+		return err
+	}
+	return pdb.mapDriver().SelectContext(ctx, previous, dest, query, args...)
+}
+
+// execMapDelete deletes execution map rows of an execution. While the number of DB shards is migrated, the rows are
+// deleted from the DB shard of the previous number as well, so that no deleted row can be read through the fallback
+// of selectMapRows. The result counts the rows deleted from both DB shards
+func (pdb *db) execMapDelete(ctx context.Context, historyShardID int, dbShardID int, query string, args ...interface{}) (sql.Result, error) {
+	result, err := pdb.mapDriver().ExecContext(ctx, dbShardID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	previous, ok := pdb.previousDBShardID(historyShardID, dbShardID)
+	if !ok {
+		return result, nil
+	}
+	previousResult, err := pdb.mapDriver().ExecContext(ctx, previous, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return batchResult(rowsAffected(result) + rowsAffected(previousResult)), nil
+}
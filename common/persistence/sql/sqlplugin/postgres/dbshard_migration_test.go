@@ -0,0 +1,168 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestPreviousDBShardFallback(t *testing.T) {
+	// history shard 5 moved from DB shard 1 of 2 to DB shard 1 of 4, history shard 6 from DB shard 0 to DB shard 2
+	filter := func(shardID int64) *sqlplugin.TimerInfoMapsFilter {
+		return &sqlplugin.TimerInfoMapsFilter{ShardID: shardID, WorkflowID: "wid"}
+	}
+	tests := map[string]struct {
+		previousNumDBShards int
+		isTx                bool
+		txDBShardID         int
+		shardID             int64
+		rows                map[int][]sqlplugin.TimerInfoMapsRow
+		expectedTimers      []string
+		expectedSelected    []int
+		expectedDeletedOn   []int
+	}{
+		"rows not migrated yet": {
+			previousNumDBShards: 2, shardID: 6,
+			rows:           map[int][]sqlplugin.TimerInfoMapsRow{0: {{TimerID: "old"}}},
+			expectedTimers: []string{"old"}, expectedSelected: []int{2, 0}, expectedDeletedOn: []int{2, 0},
+		},
+		"rows migrated": {
+			previousNumDBShards: 2, shardID: 6,
+			rows:           map[int][]sqlplugin.TimerInfoMapsRow{0: {{TimerID: "old"}}, 2: {{TimerID: "new"}}},
+			expectedTimers: []string{"new"}, expectedSelected: []int{2}, expectedDeletedOn: []int{2, 0},
+		},
+		"same DB shard": {
+			previousNumDBShards: 2, shardID: 5,
+			expectedSelected: []int{1}, expectedDeletedOn: []int{1},
+		},
+		"no migration": {
+			shardID:          6,
+			rows:             map[int][]sqlplugin.TimerInfoMapsRow{0: {{TimerID: "old"}}},
+			expectedSelected: []int{2}, expectedDeletedOn: []int{2},
+		},
+		"transaction": {
+			previousNumDBShards: 2, isTx: true, txDBShardID: 2, shardID: 6,
+			rows:             map[int][]sqlplugin.TimerInfoMapsRow{0: {{TimerID: "old"}}},
+			expectedSelected: []int{2}, expectedDeletedOn: []int{2},
+		},
+		"transaction on the previous DB shard": {
+			previousNumDBShards: 2, isTx: true, txDBShardID: 0, shardID: 6,
+			rows:           map[int][]sqlplugin.TimerInfoMapsRow{0: {{TimerID: "old"}}},
+			expectedTimers: []string{"old"}, expectedSelected: []int{0}, expectedDeletedOn: []int{0},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pdb, driver := newMockDB(t)
+			pdb.numDBShards = 4
+			pdb.isTx = test.isTx
+			pdb.txDBShardID = test.txDBShardID
+			pdb.opts.previousNumDBShards = test.previousNumDBShards
+			// each DB shard answers with the rows stored on it, the DB shards each statement is run on are recorded
+			var selected, deletedOn []int
+			driver.EXPECT().SelectContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, dbShardID int, dest interface{}, _ string, _ ...interface{}) error {
+					selected = append(selected, dbShardID)
+					*dest.(*[]sqlplugin.TimerInfoMapsRow) = append([]sqlplugin.TimerInfoMapsRow{}, test.rows[dbShardID]...)
+					return nil
+				}).Times(len(test.expectedSelected))
+			driver.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, dbShardID int, _ string, _ ...interface{}) (sql.Result, error) {
+					deletedOn = append(deletedOn, dbShardID)
+					return batchResult(len(test.rows[dbShardID])), nil
+				}).Times(len(test.expectedDeletedOn))
+
+			rows, err := pdb.SelectFromTimerInfoMaps(context.Background(), filter(test.shardID))
+			require.NoError(t, err)
+			var timers []string
+			for _, row := range rows {
+				timers = append(timers, row.TimerID)
+			}
+			assert.Equal(t, test.expectedTimers, timers)
+			assert.Equal(t, test.expectedSelected, selected)
+
+			result, err := pdb.DeleteFromTimerInfoMaps(context.Background(), filter(test.shardID))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedDeletedOn, deletedOn)
+			var expectedDeleted int
+			for _, dbShardID := range test.expectedDeletedOn {
+				expectedDeleted += len(test.rows[dbShardID])
+			}
+			assert.Equal(t, expectedDeleted, rowsAffected(result))
+		})
+	}
+}
+
+func TestPreviousDBShardFallbackOfKeyedSelects(t *testing.T) {
+	// history shard 6 moved from DB shard 0 of 2 to DB shard 2 of 4 and its rows are only stored on DB shard 0 yet
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	pdb.opts.previousNumDBShards = 2
+	var selected []int
+	driver.EXPECT().SelectContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, dbShardID int, dest interface{}, _ string, _ ...interface{}) error {
+			selected = append(selected, dbShardID)
+			if dbShardID != 0 {
+				return nil
+			}
+			switch dest := dest.(type) {
+			case *[]sqlplugin.ActivityInfoMetadataRow:
+				*dest = []sqlplugin.ActivityInfoMetadataRow{{ScheduleID: 7}}
+			case *[]sqlplugin.ActivityInfoMapsRow:
+				*dest = []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 7}}
+			case *[]sqlplugin.TimerInfoMapsRow:
+				*dest = []sqlplugin.TimerInfoMapsRow{{TimerID: "old"}}
+			}
+			return nil
+		}).AnyTimes()
+	ctx := context.Background()
+
+	metadata, err := pdb.SelectActivityInfoMetadata(ctx, &sqlplugin.ActivityInfoMapsFilter{ShardID: 6})
+	require.NoError(t, err)
+	require.Len(t, metadata, 1)
+	assert.Equal(t, int64(7), metadata[0].ScheduleID)
+	assert.Equal(t, []int{2, 0}, selected)
+
+	selected = nil
+	timers, err := pdb.SelectTimerInfoByTimerIDs(ctx, &sqlplugin.TimerInfoMapsFilter{ShardID: 6}, []string{"old"})
+	require.NoError(t, err)
+	require.Len(t, timers, 1)
+	assert.Equal(t, "old", timers[0].TimerID)
+	assert.Equal(t, []int{2, 0}, selected)
+
+	// a transaction started on the previous DB shard locks the rows stored there
+	selected = nil
+	pdb.isTx = true
+	pdb.txDBShardID = 0
+	activities, err := pdb.SelectFromActivityInfoMapsForUpdate(ctx, &sqlplugin.ActivityInfoMapsFilter{ShardID: 6, ScheduleIDs: []int64{7}})
+	require.NoError(t, err)
+	require.Len(t, activities, 1)
+	assert.Equal(t, int64(7), activities[0].ScheduleID)
+	assert.Equal(t, []int{0}, selected)
+}
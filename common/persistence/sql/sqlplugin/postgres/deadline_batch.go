@@ -0,0 +1,117 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	// deadlineChunkFirstRows is the size of the first chunk of a batch, before the latency of a row is known
+	deadlineChunkFirstRows = 10
+	deadlineChunkMaxRows   = 1000
+	// deadlineChunkBudget is the share of the remaining time the next chunk is sized to take, the rest is left for
+	// chunks slower than the ones observed so far
+	deadlineChunkBudget = 0.5
+)
+
+// ReplaceIntoActivityInfoMapsWithinDeadline replaces rows into activity_info_maps in chunks sized to the remaining
+// time of ctx, and returns how many of the first rows were written
+func (pdb *db) ReplaceIntoActivityInfoMapsWithinDeadline(ctx context.Context, rows []sqlplugin.ActivityInfoMapsRow) (int, error) {
+	return writeWithinDeadline(ctx, len(rows), func(ctx context.Context, from int, to int) error {
+		_, err := pdb.ReplaceIntoActivityInfoMaps(ctx, rows[from:to])
+		return err
+	})
+}
+
+// ReplaceIntoTimerInfoMapsWithinDeadline replaces rows into timer_info_maps in chunks sized to the remaining
+// time of ctx, and returns how many of the first rows were written
+func (pdb *db) ReplaceIntoTimerInfoMapsWithinDeadline(ctx context.Context, rows []sqlplugin.TimerInfoMapsRow) (int, error) {
+	return writeWithinDeadline(ctx, len(rows), func(ctx context.Context, from int, to int) error {
+		_, err := pdb.ReplaceIntoTimerInfoMaps(ctx, rows[from:to])
+		return err
+	})
+}
+
+// writeWithinDeadline writes n rows with write, one chunk [from, to) at a time, and returns the number of rows written.
+// The chunks are sized from the latency per row observed by the previous chunks, so that the next chunk is expected
+// to take deadlineChunkBudget of the remaining time. Without a deadline the rows are written in a single chunk.
+// A chunk which would not fit into the remaining time is not started and context.DeadlineExceeded is returned
+func writeWithinDeadline(ctx context.Context, n int, write func(ctx context.Context, from int, to int) error) (int, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		if err := write(ctx, 0, n); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	written := 0
+	var perRow time.Duration
+	for written < n {
+		size := deadlineChunkSize(time.Until(deadline), perRow, n-written)
+		if size == 0 {
+			return written, context.DeadlineExceeded
+		}
+		start := time.Now()
+		if err := write(ctx, written, written+size); err != nil {
+			return written, err
+		}
+		written += size
+		observed := time.Since(start) / time.Duration(size)
+		if observed <= 0 {
+			// a 0 latency would read as none observed yet
+			observed = time.Nanosecond
+		}
+		if perRow == 0 {
+			perRow = observed
+		} else {
+			perRow = (perRow + observed) / 2
+		}
+	}
+	return written, nil
+}
+
+// deadlineChunkSize returns the number of rows of the next chunk given the remaining time, the latency per row
+// observed so far, 0 when none was observed yet, and the number of rows left. It returns 0 once not even a single row
+// is expected to fit into the remaining time
+func deadlineChunkSize(remaining time.Duration, perRow time.Duration, left int) int {
+	if remaining <= 0 {
+		return 0
+	}
+	size := deadlineChunkFirstRows
+	if perRow > 0 {
+		size = int(float64(remaining) * deadlineChunkBudget / float64(perRow))
+		if size == 0 && perRow < remaining {
+			// a single row is still expected to fit
+			size = 1
+		}
+		if size > deadlineChunkMaxRows {
+			size = deadlineChunkMaxRows
+		}
+	}
+	if size > left {
+		size = left
+	}
+	return size
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestReplaceIntoActivityInfoMapsWithinDeadline(t *testing.T) {
+	rows := make([]sqlplugin.ActivityInfoMapsRow, 25)
+	for i := range rows {
+		rows[i] = sqlplugin.ActivityInfoMapsRow{ShardID: 1, ScheduleID: int64(i)}
+	}
+	// chunks records the number of rows of every write
+	var chunks []int
+	recordChunk := func(_ context.Context, _ int, _ string, arg interface{}) (sql.Result, error) {
+		n := reflect.ValueOf(arg).Len()
+		chunks = append(chunks, n)
+		return batchResult(n), nil
+	}
+
+	// without a deadline the rows are written at once
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(recordChunk)
+	written, err := pdb.ReplaceIntoActivityInfoMapsWithinDeadline(context.Background(), rows)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, written)
+	assert.Equal(t, []int{25}, chunks)
+
+	// the first chunk is small, the rest is sized from its latency
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	chunks = nil
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(recordChunk).Times(2)
+	written, err = pdb.ReplaceIntoActivityInfoMapsWithinDeadline(ctx, rows)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, written)
+	assert.Equal(t, []int{deadlineChunkFirstRows, 25 - deadlineChunkFirstRows}, chunks)
+
+	// a chunk which times out returns the rows written before it
+	gomock.InOrder(
+		driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(recordChunk),
+		driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(nil, context.DeadlineExceeded),
+	)
+	written, err = pdb.ReplaceIntoActivityInfoMapsWithinDeadline(ctx, rows)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, deadlineChunkFirstRows, written)
+}
+
+func TestDeadlineChunkSize(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		remaining time.Duration
+		perRow    time.Duration
+		left      int
+		want      int
+	}{
+		{name: "first chunk", remaining: time.Second, left: 100, want: deadlineChunkFirstRows},
+		{name: "first chunk of few rows", remaining: time.Second, left: 3, want: 3},
+		{name: "half of the remaining time", remaining: time.Second, perRow: 10 * time.Millisecond, left: 100, want: 50},
+		{name: "bounded by the rows left", remaining: time.Second, perRow: 10 * time.Millisecond, left: 20, want: 20},
+		{name: "bounded by the max chunk", remaining: time.Hour, perRow: time.Millisecond, left: 1e6, want: deadlineChunkMaxRows},
+		{name: "a single row still fits", remaining: 15 * time.Millisecond, perRow: 10 * time.Millisecond, left: 100, want: 1},
+		{name: "no row fits", remaining: 5 * time.Millisecond, perRow: 10 * time.Millisecond, left: 100, want: 0},
+		{name: "deadline passed", remaining: -time.Second, left: 100, want: 0},
+	} {
+		assert.Equal(t, tc.want, deadlineChunkSize(tc.remaining, tc.perRow, tc.left), tc.name)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// decodeFallback relabels the execution map rows read with a data_encoding the store has no decoder for, e.g. after a
+// downgrade, with the fallback encoding if their data decodes with it
+type decodeFallback struct {
+	// decodable are the encodings the persistence layer of the store decodes
+	decodable map[string]struct{}
+	encoding  string
+	parser    serialization.Parser
+}
+
+func newDecodeFallback(cfg *config.SQL) (*decodeFallback, error) {
+	parser, err := serialization.NewParser(common.EncodingType(cfg.MapDataFallbackEncoding), common.EncodingType(cfg.MapDataFallbackEncoding))
+	if err != nil {
+		return nil, fmt.Errorf("invalid mapDataFallbackEncoding %q: %v", cfg.MapDataFallbackEncoding, err)
+	}
+	decodable := map[string]struct{}{cfg.MapDataFallbackEncoding: {}}
+	for _, encoding := range cfg.DecodingTypes {
+		decodable[encoding] = struct{}{}
+	}
+	return &decodeFallback{decodable: decodable, encoding: cfg.MapDataFallbackEncoding, parser: parser}, nil
+}
+
+// relabel sets the data_encoding of the rows which can not be decoded with their own but can with the fallback encoding
+// to the fallback encoding and returns how many it relabeled. The other rows are left as they are, so a row which does
+// not decode with either still fails to decode in the persistence layer
+func (f *decodeFallback) relabel(rows interface{}) int {
+	relabeled := 0
+	try := func(encoding *string, decode func(encoding string) error) {
+		if _, ok := f.decodable[*encoding]; ok {
+			return
+		}
+		if decode(f.encoding) == nil {
+			*encoding = f.encoding
+			relabeled++
+		}
+	}
+	switch rows := rows.(type) {
+	case []sqlplugin.ActivityInfoMapsRow:
+		for i := range rows {
+			try(&rows[i].DataEncoding, func(encoding string) error {
+				_, err := f.parser.ActivityInfoFromBlob(rows[i].Data, encoding)
+				return err
+			})
+		}
+	case []sqlplugin.TimerInfoMapsRow:
+		for i := range rows {
+			try(&rows[i].DataEncoding, func(encoding string) error {
+				_, err := f.parser.TimerInfoFromBlob(rows[i].Data, encoding)
+				return err
+			})
+		}
+	case []sqlplugin.ChildExecutionInfoMapsRow:
+		for i := range rows {
+			try(&rows[i].DataEncoding, func(encoding string) error {
+				_, err := f.parser.ChildExecutionInfoFromBlob(rows[i].Data, encoding)
+				return err
+			})
+		}
+	case []sqlplugin.RequestCancelInfoMapsRow:
+		for i := range rows {
+			try(&rows[i].DataEncoding, func(encoding string) error {
+				_, err := f.parser.RequestCancelInfoFromBlob(rows[i].Data, encoding)
+				return err
+			})
+		}
+	case []sqlplugin.SignalInfoMapsRow:
+		for i := range rows {
+			try(&rows[i].DataEncoding, func(encoding string) error {
+				_, err := f.parser.SignalInfoFromBlob(rows[i].Data, encoding)
+				return err
+			})
+		}
+	}
+	return relabeled
+}
+
+// applyDecodeFallback relabels the rows read from table which only decode with the fallback encoding, if one is configured
+func (pdb *db) applyDecodeFallback(table string, rows interface{}) {
+	if pdb.opts.decodeFallback == nil {
+		return
+	}
+	if relabeled := pdb.opts.decodeFallback.relabel(rows); relabeled > 0 && pdb.opts.logger != nil {
+		pdb.opts.logger.Warn("Execution map rows with an unsupported data encoding were decoded with the fallback encoding",
+			tag.Name(table), tag.Counter(relabeled))
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestDecodeFallback(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	blob, err := parser.ActivityInfoToBlob(&serialization.ActivityInfo{Version: 1})
+	require.NoError(t, err)
+	rows := []sqlplugin.ActivityInfoMapsRow{
+		{ScheduleID: 1, Data: blob.Data, DataEncoding: "thriftrw"},
+		{ScheduleID: 2, Data: blob.Data, DataEncoding: "thriftrw-next"},
+		{ScheduleID: 3, Data: []byte{1, 2, 3}, DataEncoding: "thriftrw-next"},
+	}
+
+	opts, err := newDBOptions(&config.SQL{DecodingTypes: []string{"thriftrw"}, MapDataFallbackEncoding: "thriftrw"})
+	require.NoError(t, err)
+	opts.queries = newExecutionMapQueries("")
+	pdb, driver := newMockDB(t)
+	pdb.opts = opts
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn((&sentStatements{}).rows(rows))
+	result, err := pdb.SelectFromActivityInfoMaps(context.Background(), &sqlplugin.ActivityInfoMapsFilter{ShardID: 1})
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+	assert.Equal(t, "thriftrw", result[0].DataEncoding)
+	assert.Equal(t, "thriftrw", result[1].DataEncoding, "a row decoding with the fallback encoding is relabeled")
+	assert.Equal(t, "thriftrw-next", result[2].DataEncoding, "a row not decoding with the fallback encoding is left as it is")
+}
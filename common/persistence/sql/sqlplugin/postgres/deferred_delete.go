@@ -0,0 +1,170 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+)
+
+const (
+	// %[1]v is the name of the table
+	markDeletedMapQueryTemplate = `UPDATE %[1]v SET deleted = TRUE
+WHERE
+shard_id = $1 AND
+domain_id = $2 AND
+workflow_id = $3 AND
+run_id = $4 AND
+NOT deleted`
+
+	// %[1]v is the name of the table
+	// %[2]v is the name of the key
+	markDeletedKeysInMapQueryTemplate = `UPDATE %[1]v SET deleted = TRUE
+WHERE
+shard_id = ? AND
+domain_id = ? AND
+workflow_id = ? AND
+run_id = ? AND
+%[2]v IN ( ? ) AND
+NOT deleted`
+
+	// %[1]v is the name of the table
+	// %[2]v is the name of the key
+	sweepDeletedMapQueryTemplate = `DELETE FROM %[1]v
+WHERE (shard_id, domain_id, workflow_id, run_id, %[2]v) IN (
+SELECT shard_id, domain_id, workflow_id, run_id, %[2]v FROM %[1]v
+WHERE
+shard_id = $1 AND
+deleted
+LIMIT $2)`
+)
+
+// withoutDeletedRows returns query with the rows flagged as deleted filtered out, query must have a WHERE clause
+// starting on its own line like the templates of the execution map queries
+func withoutDeletedRows(query string) string {
+	return strings.Replace(query, "WHERE\n", "WHERE\nNOT deleted AND\n", 1)
+}
+
+// enableDeferredActivityDeletion rewrites the queries on activity_info_maps for a table with the deleted column:
+// the selects skip the flagged rows and an upsert clears the flag of the row it replaces
+func (q *executionMapQueries) enableDeferredActivityDeletion(tablePrefix string) {
+	activityInfoTable := tablePrefix + activityInfoTableName
+	q.setKeyInActivityInfoMapQry = makeSetKeyInMapQry(activityInfoTable, activityInfoColumns, []string{activityInfoKey}) + ",\n\tdeleted = FALSE"
+	for _, query := range []*string{
+		&q.getActivityInfoMapQry,
+		&q.getActivityInfoMetadataQry,
+		&q.getActivityInfoMapRowQry,
+		&q.getActivityInfoMapForUpdateQry,
+		&q.getKeysInActivityInfoMapForUpdateQry,
+		&q.getActivityInfoMapsShardFirstPageQry,
+		&q.getActivityInfoMapsShardNextPageQry,
+		&q.getActivityInfoMapPageQry,
+		&q.listExecutionsInActivityInfoMapQrys.firstPage,
+		&q.listExecutionsInActivityInfoMapQrys.nextPage,
+		&q.getExecutionsInActivityInfoMapQry,
+		&q.countOtherKeysInActivityInfoMapQry,
+	} {
+		*query = withoutDeletedRows(*query)
+	}
+	q.markDeletedActivityInfoMapQry = fmt.Sprintf(markDeletedMapQueryTemplate, activityInfoTable)
+	q.markDeletedKeysInActivityInfoMapQry = fmt.Sprintf(markDeletedKeysInMapQueryTemplate, activityInfoTable, activityInfoKey)
+	q.sweepDeletedActivityInfoMapQry = fmt.Sprintf(sweepDeletedMapQueryTemplate, activityInfoTable, activityInfoKey)
+	// a flagged row is swapped like a missing one
+	q.swapActivityInfoMapQry = withoutDeletedRows(makeSwapKeyInMapQry(activityInfoTable, activityInfoColumns, activityInfoKey, q.setKeyInActivityInfoMapQry))
+	q.fingerprints = makeQueryFingerprints(q)
+}
+
+// ErrDeferredMapDeletionDisabled is returned by MarkForDeletionActivityInfoMaps and SweepDeletedActivityInfoMaps unless
+// deferred map deletion is enabled in config, the tables are only expected to have the deleted column then
+var ErrDeferredMapDeletionDisabled = errors.New("deferred map deletion is not enabled")
+
+// MarkForDeletionActivityInfoMaps flags the rows of activity_info_maps selected by filter as deleted
+func (pdb *db) MarkForDeletionActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (result sql.Result, err error) {
+	if !pdb.opts.deferredMapDeletion {
+		return nil, ErrDeferredMapDeletionDisabled
+	}
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "MarkForDeletionActivityInfoMaps", activityInfoTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	defer pdb.activityInfoMapsWritten(filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, activityInfoTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, int64Keys(filter.ScheduleIDs)); err != nil {
+		return nil, err
+	}
+	if len(filter.ScheduleIDs) > 0 {
+		query, args, err := sqlx.In(pdb.opts.queries.markDeletedKeysInActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.ScheduleIDs)
+		if err != nil {
+			return nil, err
+		}
+		return pdb.mapDriver().ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+	}
+	return pdb.mapDriver().ExecContext(ctx, dbShardID, pdb.opts.queries.markDeletedActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+}
+
+// SweepDeletedActivityInfoMaps removes the flagged rows of activity_info_maps of a shard. Each batch is a statement
+// of its own, so a sweep never holds the locks of more than batchSize rows and can be stopped through ctx between batches
+func (pdb *db) SweepDeletedActivityInfoMaps(ctx context.Context, shardID int, batchSize int, qps int) (swept int64, err error) {
+	if !pdb.opts.deferredMapDeletion {
+		return 0, ErrDeferredMapDeletionDisabled
+	}
+	if batchSize < 1 {
+		return 0, fmt.Errorf("invalid batchSize %v, it must be positive", batchSize)
+	}
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SweepDeletedActivityInfoMaps", activityInfoTableName)
+	defer func() { span.finish(int(swept), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	span.setDBShardID(dbShardID)
+	var limiter *quotas.RateLimiter
+	if qps > 0 {
+		limiter = quotas.NewSimpleRateLimiter(qps)
+	}
+	for {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return swept, err
+			}
+		}
+		result, err := pdb.mapDriver().ExecContext(ctx, dbShardID, pdb.opts.queries.sweepDeletedActivityInfoMapQry, shardID, batchSize)
+		if err != nil {
+			return swept, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return swept, err
+		}
+		swept += n
+		if n < int64(batchSize) {
+			return swept, nil
+		}
+	}
+}
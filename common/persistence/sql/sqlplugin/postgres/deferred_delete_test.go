@@ -0,0 +1,114 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestEnableDeferredActivityDeletion(t *testing.T) {
+	plain := newExecutionMapQueries("")
+	queries := newExecutionMapQueries("")
+	queries.enableDeferredActivityDeletion("")
+
+	assert.Equal(t, plain.getTimerInfoMapSQLQuery, queries.getTimerInfoMapSQLQuery)
+	assert.Equal(t, plain.deleteActivityInfoMapQry, queries.deleteActivityInfoMapQry)
+	for _, query := range []string{
+		queries.getActivityInfoMapQry,
+		queries.getActivityInfoMetadataQry,
+		queries.getActivityInfoMapForUpdateQry,
+		queries.getKeysInActivityInfoMapForUpdateQry,
+		queries.getActivityInfoMapsShardFirstPageQry,
+		queries.getActivityInfoMapsShardNextPageQry,
+		queries.listExecutionsInActivityInfoMapQrys.firstPage,
+		queries.listExecutionsInActivityInfoMapQrys.nextPage,
+		queries.getExecutionsInActivityInfoMapQry,
+		queries.countOtherKeysInActivityInfoMapQry,
+	} {
+		assert.Contains(t, query, "WHERE\nNOT deleted AND\nshard_id = ")
+	}
+	assert.Equal(t, plain.setKeyInActivityInfoMapQry+",\n\tdeleted = FALSE", queries.setKeyInActivityInfoMapQry)
+	assert.Len(t, queries.fingerprints["MarkForDeletionActivityInfoMaps"], 2)
+	assert.Len(t, queries.fingerprints["SweepDeletedActivityInfoMaps"], 1)
+	assert.Empty(t, plain.fingerprints["SweepDeletedActivityInfoMaps"])
+}
+
+func TestMarkForDeletionActivityInfoMaps(t *testing.T) {
+	ctx := context.Background()
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: 1, WorkflowID: "wid"}
+
+	pdb, driver := newMockDB(t)
+	_, err := pdb.MarkForDeletionActivityInfoMaps(ctx, filter)
+	assert.Equal(t, ErrDeferredMapDeletionDisabled, err)
+
+	queries := newExecutionMapQueries("")
+	queries.enableDeferredActivityDeletion("")
+	pdb.opts.queries = queries
+	pdb.opts.deferredMapDeletion = true
+	sent := &sentStatements{}
+	driver.EXPECT().ExecContext(gomock.Any(), 0, queries.markDeletedActivityInfoMapQry, gomock.Any()).DoAndReturn(sent.result(3))
+	result, err := pdb.MarkForDeletionActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rowsAffected(result))
+
+	filter.ScheduleIDs = []int64{5}
+	driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.result(1))
+	result, err = pdb.MarkForDeletionActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rowsAffected(result))
+	assert.Contains(t, sent.query(), "schedule_id IN ( $5 ) AND\nNOT deleted")
+}
+
+func TestSweepDeletedActivityInfoMaps(t *testing.T) {
+	ctx := context.Background()
+	pdb, driver := newMockDB(t)
+	_, err := pdb.SweepDeletedActivityInfoMaps(ctx, 1, 2, 0)
+	assert.Equal(t, ErrDeferredMapDeletionDisabled, err)
+
+	queries := newExecutionMapQueries("")
+	queries.enableDeferredActivityDeletion("")
+	pdb.opts.queries = queries
+	pdb.opts.deferredMapDeletion = true
+	_, err = pdb.SweepDeletedActivityInfoMaps(ctx, 1, 0, 0)
+	assert.Error(t, err)
+
+	// the sweep stops at the first batch smaller than the batch size
+	gomock.InOrder(
+		driver.EXPECT().ExecContext(gomock.Any(), 0, queries.sweepDeletedActivityInfoMapQry, gomock.Any()).Return(batchResult(2), nil).Times(2),
+		driver.EXPECT().ExecContext(gomock.Any(), 0, queries.sweepDeletedActivityInfoMapQry, gomock.Any()).Return(batchResult(1), nil),
+	)
+	swept, err := pdb.SweepDeletedActivityInfoMaps(ctx, 1, 2, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), swept)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	swept, err = pdb.SweepDeletedActivityInfoMaps(cancelled, 1, 2, 1)
+	assert.Error(t, err)
+	assert.Zero(t, swept)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/types"
+)
+
+// domainWriteLimiter bounds the rate of the execution map writes of each domain with a configured QPS, so the writes
+// of a noisy domain cannot take the capacity of the database shared with the other domains
+type domainWriteLimiter struct {
+	// reject makes a write over budget fail with a ServiceBusyError instead of waiting for a token
+	reject bool
+	// limiters are keyed by the canonical string of the domain ID, a domain without a limiter is not limited
+	limiters map[string]quotas.Limiter
+}
+
+func newDomainWriteLimiter(qps map[string]int, reject bool) (*domainWriteLimiter, error) {
+	l := &domainWriteLimiter{reject: reject, limiters: make(map[string]quotas.Limiter, len(qps))}
+	for domainID, limit := range qps {
+		id, err := uuid.Parse(domainID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain ID %q in mapWriteQPSPerDomain: %v", domainID, err)
+		}
+		if limit <= 0 {
+			return nil, fmt.Errorf("invalid mapWriteQPSPerDomain %v of domain %v, it must be positive", limit, domainID)
+		}
+		l.limiters[id.String()] = quotas.NewSimpleRateLimiter(limit)
+	}
+	return l, nil
+}
+
+// allow takes a token of each limited domain among the n domain IDs, waiting for it unless writes over budget
+// are rejected. Each domain is charged once however many of the IDs it has
+func (l *domainWriteLimiter) allow(ctx context.Context, n int, domainID func(i int) serialization.UUID) error {
+	charged := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		id := domainID(i).String()
+		limiter, ok := l.limiters[id]
+		if !ok || charged[id] {
+			continue
+		}
+		charged[id] = true
+		if l.reject {
+			if !limiter.Allow() {
+				return &types.ServiceBusyError{Message: fmt.Sprintf("execution map writes of domain %v are over their QPS limit", id)}
+			}
+			continue
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowMapWrites fails the execution map writes while they are paused for maintenance, otherwise it is
+// domainWriteLimiter.allow and lets every write through unless per domain QPS limits are configured
+func (pdb *db) allowMapWrites(ctx context.Context, n int, domainID func(i int) serialization.UUID) error {
+	if pdb.mapWritesPaused() {
+		return &sqlplugin.MaintenanceError{}
+	}
+	if pdb.opts.domainWriteLimiter == nil {
+		return nil
+	}
+	return pdb.opts.domainWriteLimiter.allow(ctx, n, domainID)
+}
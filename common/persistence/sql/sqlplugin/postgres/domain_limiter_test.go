@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestDomainWriteLimiterRejects(t *testing.T) {
+	limited := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	other := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	limiter, err := newDomainWriteLimiter(map[string]int{limited.String(): 1}, true)
+	require.NoError(t, err)
+	pdb, driver := newMockDB(t)
+	pdb.opts.domainWriteLimiter = limiter
+	sent := &sentStatements{}
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.namedResult()).Times(11)
+	rows := func(domainIDs ...serialization.UUID) []sqlplugin.TimerInfoMapsRow {
+		var rows []sqlplugin.TimerInfoMapsRow
+		for i, domainID := range domainIDs {
+			rows = append(rows, sqlplugin.TimerInfoMapsRow{ShardID: 1, DomainID: domainID, TimerID: string(rune('a' + i))})
+		}
+		return rows
+	}
+
+	// the rows of a batch take a single token of their domain
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows(limited, limited))
+	require.NoError(t, err)
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows(other, limited))
+	assert.IsType(t, &types.ServiceBusyError{}, err)
+	_, err = pdb.DeleteFromTimerInfoMaps(context.Background(), &sqlplugin.TimerInfoMapsFilter{ShardID: 1, DomainID: limited})
+	assert.IsType(t, &types.ServiceBusyError{}, err)
+	assert.Len(t, sent.queries, 1, "a rejected write must not reach the database")
+
+	// a domain without a limit is not throttled
+	for i := 0; i < 10; i++ {
+		_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows(other))
+		require.NoError(t, err)
+	}
+}
+
+func TestDomainWriteLimiterWaits(t *testing.T) {
+	limited := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	limiter, err := newDomainWriteLimiter(map[string]int{limited.String(): 1}, false)
+	require.NoError(t, err)
+	domainID := func(int) serialization.UUID { return limited }
+
+	require.NoError(t, limiter.allow(context.Background(), 1, domainID))
+	// the next token is a second away, more than the context allows
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, limiter.allow(ctx, 1, domainID))
+}
+
+func TestMapWriteMaintenance(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	maintenance := true
+	pdb.SetMapWriteMaintenance(func() bool { return maintenance })
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}}
+
+	_, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	assert.IsType(t, &sqlplugin.MaintenanceError{}, err)
+	assert.True(t, pdb.IsThrottlingError(err), "a write paused for maintenance must be retried")
+	_, err = pdb.DeleteFromTimerInfoMaps(context.Background(), &sqlplugin.TimerInfoMapsFilter{ShardID: 1})
+	assert.IsType(t, &sqlplugin.MaintenanceError{}, err)
+	errs := pdb.DeleteMapsForExecutions(context.Background(), []sqlplugin.ExecutionsFilter{{ShardID: 1}}, 1, 0)
+	require.Len(t, errs, 1)
+	assert.IsType(t, &sqlplugin.MaintenanceError{}, errs[0])
+
+	// only the write after the pause reaches the database
+	maintenance = false
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(batchResult(1), nil)
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+}
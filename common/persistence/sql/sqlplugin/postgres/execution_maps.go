@@ -23,12 +23,24 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/serialization"
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/types"
 )
 
 const (
@@ -45,15 +57,16 @@ run_id = $4`
 	// %[5]v should be %[2]v with "excluded." prepended.
 	// i.e. %[5]v = ",".join("excluded." + s for s in %[2]v)
 	// So that this query can be used with BindNamed
-	// %[4]v should be the name of the key associated with the map
+	// %[4]v should be the columns of the key associated with the map, comma separated
 	// e.g. for ActivityInfo it is "schedule_id"
+	// %[6]v and %[7]v are %[4]v with colons and "excluded." prepended, like %[3]v and %[5]v
 	setKeyInMapQueryTemplate = `INSERT INTO %[1]v
 (shard_id, domain_id, workflow_id, run_id, %[4]v, %[2]v)
 VALUES
-(:shard_id, :domain_id, :workflow_id, :run_id, :%[4]v, %[3]v)
+(:shard_id, :domain_id, :workflow_id, :run_id, %[6]v, %[3]v)
 ON CONFLICT (shard_id, domain_id, workflow_id, run_id, %[4]v) DO UPDATE
 	SET (shard_id, domain_id, workflow_id, run_id, %[4]v, %[2]v)
-  	  = (excluded.shard_id, excluded.domain_id, excluded.workflow_id, excluded.run_id, excluded.%[4]v, %[5]v)`
+  	  = (excluded.shard_id, excluded.domain_id, excluded.workflow_id, excluded.run_id, %[7]v, %[5]v)`
 
 	// %[2]v is the name of the key
 	deleteKeyInMapQueryTemplate = `DELETE FROM %[1]v
@@ -73,10 +86,46 @@ shard_id = $1 AND
 domain_id = $2 AND
 workflow_id = $3 AND
 run_id = $4`
+
+	// %[1]v is the name of the table
+	// %[2]v is the name of the key
+	countOtherKeysInMapQueryTemplate = `SELECT COUNT(*) FROM %[1]v
+WHERE
+shard_id = ? AND
+domain_id = ? AND
+workflow_id = ? AND
+run_id = ? AND
+%[2]v NOT IN ( ? )`
+
+	// %[1]v is the name of the table
+	// %[2]v is the name of the key
+	// %[3]v is the value columns, separated by commas
+	getKeysInMapQueryTemplate = `SELECT %[2]v, %[3]v FROM %[1]v
+WHERE
+shard_id = ? AND
+domain_id = ? AND
+workflow_id = ? AND
+run_id = ? AND
+%[2]v IN ( ? )`
+
+	// %[1]v is the name of the table
+	// %[2]v is the name of the key
+	// %[3]v is the value columns, separated by commas
+	// rows are locked in key order so that concurrent transactions cannot deadlock on them
+	getKeysInMapForUpdateQueryTemplate = `SELECT %[2]v, %[3]v FROM %[1]v
+WHERE
+shard_id = ? AND
+domain_id = ? AND
+workflow_id = ? AND
+run_id = ? AND
+%[2]v IN ( ? )
+ORDER BY %[2]v
+FOR UPDATE`
 )
 
 const (
-	deleteAllSignalsRequestedSetQuery = `DELETE FROM signals_requested_sets
+	// %[1]v is the name of the table
+	deleteAllSignalsRequestedSetQueryTemplate = `DELETE FROM %[1]v
 WHERE
 shard_id = $1 AND
 domain_id = $2 AND
@@ -84,12 +133,15 @@ workflow_id = $3 AND
 run_id = $4
 `
 
-	createSignalsRequestedSetQuery = `INSERT INTO signals_requested_sets
+	createSignalsRequestedSetQueryTemplate = `INSERT INTO %[1]v
 (shard_id, domain_id, workflow_id, run_id, signal_id) VALUES
 (:shard_id, :domain_id, :workflow_id, :run_id, :signal_id)
 ON CONFLICT (shard_id, domain_id, workflow_id, run_id, signal_id) DO NOTHING`
 
-	deleteSignalsRequestedSetQuery = `DELETE FROM signals_requested_sets
+	createSignalsRequestedSetReturningQueryTemplate = createSignalsRequestedSetQueryTemplate + `
+RETURNING shard_id, domain_id, workflow_id, run_id, signal_id`
+
+	deleteSignalsRequestedSetQueryTemplate = `DELETE FROM %[1]v
 WHERE
 shard_id = ? AND
 domain_id = ? AND
@@ -97,11 +149,16 @@ workflow_id = ? AND
 run_id = ? AND
 signal_id IN ( ? )`
 
-	getSignalsRequestedSetQuery = `SELECT signal_id FROM signals_requested_sets WHERE
+	getSignalsRequestedSetQueryTemplate = `SELECT signal_id FROM %[1]v WHERE
 shard_id = $1 AND
 domain_id = $2 AND
 workflow_id = $3 AND
 run_id = $4`
+
+	// %%v is the list of (domain_id, workflow_id, run_id) tuples, filled in per query
+	getSignalsRequestedSetsForExecutionsQueryTemplate = `SELECT domain_id, workflow_id, run_id, signal_id FROM %[1]v WHERE
+shard_id = $1 AND
+(domain_id, workflow_id, run_id) IN (%%v)`
 )
 
 func stringMap(a []string, f func(string) string) []string {
@@ -112,20 +169,56 @@ func stringMap(a []string, f func(string) string) []string {
 	return b
 }
 
+// mustValidateMapColumns panics unless the value columns of an execution map table are a non empty list without
+// duplicates which does not contain any of its key columns. The columns are constants of this package, so a bad list is
+// a programming error which would otherwise only show as a confusing error of the SQL statement at execution time
+func mustValidateMapColumns(tableName string, nonPrimaryKeyColumns []string, mapKeyColumns ...string) {
+	if len(nonPrimaryKeyColumns) == 0 {
+		panic(fmt.Sprintf("postgres: no value columns for the queries of %v", tableName))
+	}
+	seen := make(map[string]struct{}, len(nonPrimaryKeyColumns))
+	for _, column := range nonPrimaryKeyColumns {
+		if _, ok := seen[column]; ok {
+			panic(fmt.Sprintf("postgres: value column %v of %v is listed twice", column, tableName))
+		}
+		seen[column] = struct{}{}
+	}
+	for _, key := range mapKeyColumns {
+		if key == "" {
+			panic(fmt.Sprintf("postgres: empty map key column for the queries of %v", tableName))
+		}
+		if _, ok := seen[key]; ok {
+			panic(fmt.Sprintf("postgres: map key column %v of %v is listed as a value column", key, tableName))
+		}
+	}
+}
+
+// the queries are built once at init, so a bad column list fails at startup rather than once the query is executed
+func init() {
+	newExecutionMapQueries("")
+}
+
 func makeDeleteMapQry(tableName string) string {
 	return fmt.Sprintf(deleteMapQueryTemplate, tableName)
 }
 
-func makeSetKeyInMapQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+func makeSetKeyInMapQry(tableName string, nonPrimaryKeyColumns []string, mapKeyColumns []string) string {
+	mustValidateMapColumns(tableName, nonPrimaryKeyColumns, mapKeyColumns...)
 	return fmt.Sprintf(setKeyInMapQueryTemplate,
 		tableName,
 		strings.Join(nonPrimaryKeyColumns, ","),
 		strings.Join(stringMap(nonPrimaryKeyColumns, func(x string) string {
 			return ":" + x
 		}), ","),
-		mapKeyName,
+		strings.Join(mapKeyColumns, ","),
 		strings.Join(stringMap(nonPrimaryKeyColumns, func(x string) string {
 			return "excluded." + x
+		}), ","),
+		strings.Join(stringMap(mapKeyColumns, func(x string) string {
+			return ":" + x
+		}), ","),
+		strings.Join(stringMap(mapKeyColumns, func(x string) string {
+			return "excluded." + x
 		}), ","))
 }
 
@@ -135,13 +228,177 @@ func makeDeleteKeyInMapQry(tableName string, mapKeyName string) string {
 		mapKeyName)
 }
 
+func makeGetMapRowQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+	return makeGetMapQryTemplate(tableName, nonPrimaryKeyColumns, mapKeyName) + fmt.Sprintf(" AND\n%v = $5", mapKeyName)
+}
+
+func makeGetMapForUpdateQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+	return makeGetMapQryTemplate(tableName, nonPrimaryKeyColumns, mapKeyName) + fmt.Sprintf("\nORDER BY %v\nFOR UPDATE", mapKeyName)
+}
+
+func makeGetKeysInMapForUpdateQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+	mustValidateMapColumns(tableName, nonPrimaryKeyColumns, mapKeyName)
+	return fmt.Sprintf(getKeysInMapForUpdateQueryTemplate,
+		tableName,
+		mapKeyName,
+		strings.Join(nonPrimaryKeyColumns, ","))
+}
+
+func makeGetKeysInMapQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+	mustValidateMapColumns(tableName, nonPrimaryKeyColumns, mapKeyName)
+	return fmt.Sprintf(getKeysInMapQueryTemplate,
+		tableName,
+		mapKeyName,
+		strings.Join(nonPrimaryKeyColumns, ","))
+}
+
 func makeGetMapQryTemplate(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+	mustValidateMapColumns(tableName, nonPrimaryKeyColumns, mapKeyName)
 	return fmt.Sprintf(getMapQueryTemplate,
 		tableName,
 		mapKeyName,
 		strings.Join(nonPrimaryKeyColumns, ","))
 }
 
+// executionMapQueries holds the queries on the execution map tables. They are built per db
+// because the table names carry the table prefix configured for the db.
+type executionMapQueries struct {
+	deleteActivityInfoMapQry                  string
+	setKeyInActivityInfoMapQry                string
+	deleteKeyInActivityInfoMapQry             string
+	getActivityInfoMapQry                     string
+	getActivityInfoMetadataQry                string
+	getActivityInfoMapRowQry                  string
+	getActivityInfoMapForUpdateQry            string
+	getKeysInActivityInfoMapForUpdateQry      string
+	getActivityInfoMapsShardFirstPageQry      string
+	getActivityInfoMapsShardNextPageQry       string
+	deleteTimerInfoMapSQLQuery                string
+	setKeyInTimerInfoMapSQLQuery              string
+	deleteKeyInTimerInfoMapSQLQuery           string
+	getTimerInfoMapSQLQuery                   string
+	getKeysInTimerInfoMapQry                  string
+	deleteChildExecutionInfoMapQry            string
+	setKeyInChildExecutionInfoMapQry          string
+	deleteKeyInChildExecutionInfoMapQry       string
+	getChildExecutionInfoMapQry               string
+	deleteRequestCancelInfoMapQry             string
+	setKeyInRequestCancelInfoMapQry           string
+	deleteKeyInRequestCancelInfoMapQry        string
+	getRequestCancelInfoMapQry                string
+	deleteSignalInfoMapQry                    string
+	setKeyInSignalInfoMapQry                  string
+	deleteKeyInSignalInfoMapQry               string
+	getSignalInfoMapQry                       string
+	deleteAllSignalsRequestedSetQuery         string
+	createSignalsRequestedSetQuery            string
+	createSignalsRequestedSetReturningQuery   string
+	deleteSignalsRequestedSetQuery            string
+	getSignalsRequestedSetQuery               string
+	getSignalsRequestedSetsForExecutionsQry   string
+	getLargestExecutionMapsQuery              string
+	selectMapRowCountQry                      string
+	listExecutionsInActivityInfoMapQrys       listExecutionsInMapQueries
+	listExecutionsInTimerInfoMapQrys          listExecutionsInMapQueries
+	listExecutionsInChildExecutionInfoMapQrys listExecutionsInMapQueries
+	listExecutionsInRequestCancelInfoMapQrys  listExecutionsInMapQueries
+	listExecutionsInSignalInfoMapQrys         listExecutionsInMapQueries
+	getExecutionsInActivityInfoMapQry         string
+	countOtherKeysInActivityInfoMapQry        string
+	countOtherKeysInTimerInfoMapQry           string
+	countOtherKeysInChildExecutionInfoMapQry  string
+	countOtherKeysInRequestCancelInfoMapQry   string
+	countOtherKeysInSignalInfoMapQry          string
+	countOtherKeysInSignalsRequestedSetMapQry string
+	// countMapRowsByShardQrys count the rows of each table per shard, keyed by the name of the table without prefix
+	countMapRowsByShardQrys map[string]string
+	// the mark and sweep queries are empty unless deferred deletion is enabled, see enableDeferredActivityDeletion
+	markDeletedActivityInfoMapQry       string
+	markDeletedKeysInActivityInfoMapQry string
+	sweepDeletedActivityInfoMapQry      string
+	swapActivityInfoMapQry              string
+	getActivityInfoMapPageQry           string
+	// fingerprints are computed from the queries above once they are built
+	fingerprints map[string][]string
+}
+
+func newExecutionMapQueries(tablePrefix string) *executionMapQueries {
+	activityInfoTable := tablePrefix + activityInfoTableName
+	timerInfoTable := tablePrefix + timerInfoTableName
+	childExecutionInfoTable := tablePrefix + childExecutionInfoTableName
+	requestCancelInfoTable := tablePrefix + requestCancelInfoTableName
+	signalInfoTable := tablePrefix + signalInfoTableName
+	signalsRequestedSetsTable := tablePrefix + signalsRequestedSetsTableName
+	q := &executionMapQueries{
+		deleteActivityInfoMapQry:             makeDeleteMapQry(activityInfoTable),
+		setKeyInActivityInfoMapQry:           makeSetKeyInMapQry(activityInfoTable, activityInfoColumns, []string{activityInfoKey}),
+		deleteKeyInActivityInfoMapQry:        makeDeleteKeyInMapQry(activityInfoTable, activityInfoKey),
+		getActivityInfoMapQry:                makeGetMapQryTemplate(activityInfoTable, activityInfoColumns, activityInfoKey),
+		getActivityInfoMetadataQry:           makeGetMapQryTemplate(activityInfoTable, activityInfoMetadataColumns, activityInfoKey),
+		getActivityInfoMapRowQry:             makeGetMapRowQry(activityInfoTable, activityInfoColumns, activityInfoKey),
+		getActivityInfoMapForUpdateQry:       makeGetMapForUpdateQry(activityInfoTable, activityInfoColumns, activityInfoKey),
+		getKeysInActivityInfoMapForUpdateQry: makeGetKeysInMapForUpdateQry(activityInfoTable, activityInfoColumns, activityInfoKey),
+		getActivityInfoMapsShardFirstPageQry: fmt.Sprintf(getActivityInfoMapsShardFirstPageQryTemplate, activityInfoTable),
+		getActivityInfoMapsShardNextPageQry:  fmt.Sprintf(getActivityInfoMapsShardNextPageQryTemplate, activityInfoTable),
+
+		deleteTimerInfoMapSQLQuery:      makeDeleteMapQry(timerInfoTable),
+		setKeyInTimerInfoMapSQLQuery:    makeSetKeyInMapQry(timerInfoTable, timerInfoColumns, []string{timerInfoKey}),
+		deleteKeyInTimerInfoMapSQLQuery: makeDeleteKeyInMapQry(timerInfoTable, timerInfoKey),
+		getTimerInfoMapSQLQuery:         makeGetMapQryTemplate(timerInfoTable, timerInfoColumns, timerInfoKey),
+		getKeysInTimerInfoMapQry:        makeGetKeysInMapQry(timerInfoTable, timerInfoColumns, timerInfoKey),
+
+		deleteChildExecutionInfoMapQry:      makeDeleteMapQry(childExecutionInfoTable),
+		setKeyInChildExecutionInfoMapQry:    makeSetKeyInMapQry(childExecutionInfoTable, childExecutionInfoColumns, []string{childExecutionInfoKey}),
+		deleteKeyInChildExecutionInfoMapQry: makeDeleteKeyInMapQry(childExecutionInfoTable, childExecutionInfoKey),
+		getChildExecutionInfoMapQry:         makeGetMapQryTemplate(childExecutionInfoTable, childExecutionInfoColumns, childExecutionInfoKey),
+
+		deleteRequestCancelInfoMapQry:      makeDeleteMapQry(requestCancelInfoTable),
+		setKeyInRequestCancelInfoMapQry:    makeSetKeyInMapQry(requestCancelInfoTable, requestCancelInfoColumns, []string{requestCancelInfoKey}),
+		deleteKeyInRequestCancelInfoMapQry: makeDeleteKeyInMapQry(requestCancelInfoTable, requestCancelInfoKey),
+		getRequestCancelInfoMapQry:         makeGetMapQryTemplate(requestCancelInfoTable, requestCancelInfoColumns, requestCancelInfoKey),
+
+		deleteSignalInfoMapQry:      makeDeleteMapQry(signalInfoTable),
+		setKeyInSignalInfoMapQry:    makeSetKeyInMapQry(signalInfoTable, signalInfoColumns, []string{signalInfoKey}),
+		deleteKeyInSignalInfoMapQry: makeDeleteKeyInMapQry(signalInfoTable, signalInfoKey),
+		getSignalInfoMapQry:         makeGetMapQryTemplate(signalInfoTable, signalInfoColumns, signalInfoKey),
+
+		deleteAllSignalsRequestedSetQuery:       fmt.Sprintf(deleteAllSignalsRequestedSetQueryTemplate, signalsRequestedSetsTable),
+		createSignalsRequestedSetQuery:          fmt.Sprintf(createSignalsRequestedSetQueryTemplate, signalsRequestedSetsTable),
+		createSignalsRequestedSetReturningQuery: fmt.Sprintf(createSignalsRequestedSetReturningQueryTemplate, signalsRequestedSetsTable),
+		deleteSignalsRequestedSetQuery:          fmt.Sprintf(deleteSignalsRequestedSetQueryTemplate, signalsRequestedSetsTable),
+		getSignalsRequestedSetQuery:             fmt.Sprintf(getSignalsRequestedSetQueryTemplate, signalsRequestedSetsTable),
+		getSignalsRequestedSetsForExecutionsQry: fmt.Sprintf(getSignalsRequestedSetsForExecutionsQueryTemplate, signalsRequestedSetsTable),
+
+		getLargestExecutionMapsQuery: fmt.Sprintf(getLargestExecutionMapsQueryTemplate,
+			activityInfoTable, timerInfoTable, childExecutionInfoTable, requestCancelInfoTable, signalInfoTable),
+		selectMapRowCountQry: fmt.Sprintf(selectMapRowCountQueryTemplate,
+			activityInfoTable, timerInfoTable, childExecutionInfoTable, requestCancelInfoTable, signalInfoTable),
+
+		listExecutionsInActivityInfoMapQrys:       makeListExecutionsInMapQueries(activityInfoTable),
+		listExecutionsInTimerInfoMapQrys:          makeListExecutionsInMapQueries(timerInfoTable),
+		listExecutionsInChildExecutionInfoMapQrys: makeListExecutionsInMapQueries(childExecutionInfoTable),
+		listExecutionsInRequestCancelInfoMapQrys:  makeListExecutionsInMapQueries(requestCancelInfoTable),
+		listExecutionsInSignalInfoMapQrys:         makeListExecutionsInMapQueries(signalInfoTable),
+		getExecutionsInActivityInfoMapQry:         fmt.Sprintf(getExecutionsInMapQryTemplate, activityInfoTable),
+
+		countOtherKeysInActivityInfoMapQry:        fmt.Sprintf(countOtherKeysInMapQueryTemplate, activityInfoTable, activityInfoKey),
+		countOtherKeysInTimerInfoMapQry:           fmt.Sprintf(countOtherKeysInMapQueryTemplate, timerInfoTable, timerInfoKey),
+		countOtherKeysInChildExecutionInfoMapQry:  fmt.Sprintf(countOtherKeysInMapQueryTemplate, childExecutionInfoTable, childExecutionInfoKey),
+		countOtherKeysInRequestCancelInfoMapQry:   fmt.Sprintf(countOtherKeysInMapQueryTemplate, requestCancelInfoTable, requestCancelInfoKey),
+		countOtherKeysInSignalInfoMapQry:          fmt.Sprintf(countOtherKeysInMapQueryTemplate, signalInfoTable, signalInfoKey),
+		countOtherKeysInSignalsRequestedSetMapQry: fmt.Sprintf(countOtherKeysInMapQueryTemplate, signalsRequestedSetsTable, "signal_id"),
+
+		countMapRowsByShardQrys: make(map[string]string, len(mapRowCountTables)),
+	}
+	for _, table := range mapRowCountTables {
+		q.countMapRowsByShardQrys[table] = fmt.Sprintf(countMapRowsByShardQueryTemplate, tablePrefix+table)
+	}
+	q.swapActivityInfoMapQry = makeSwapKeyInMapQry(activityInfoTable, activityInfoColumns, activityInfoKey, q.setKeyInActivityInfoMapQry)
+	q.getActivityInfoMapPageQry = makeGetMapPageQry(activityInfoTable, activityInfoColumns, activityInfoKey)
+	q.fingerprints = makeQueryFingerprints(q)
+	return q
+}
+
 var (
 	// Omit shard_id, run_id, domain_id, workflow_id, schedule_id since they're in the primary key
 	activityInfoColumns = []string{
@@ -150,32 +407,375 @@ var (
 		"last_heartbeat_details",
 		"last_heartbeat_updated_time",
 	}
+	// activityInfoMetadataColumns are the activityInfoColumns without the data columns
+	activityInfoMetadataColumns = []string{
+		"last_heartbeat_details",
+		"last_heartbeat_updated_time",
+	}
 	activityInfoTableName = "activity_info_maps"
 	activityInfoKey       = "schedule_id"
+)
+
+const (
+	getActivityInfoMapsShardFirstPageQryTemplate = `SELECT domain_id, workflow_id, run_id, schedule_id, data, data_encoding, last_heartbeat_details, last_heartbeat_updated_time
+FROM %v
+WHERE
+shard_id = $1
+ORDER BY domain_id, workflow_id, run_id, schedule_id
+LIMIT $2`
 
-	deleteActivityInfoMapQry      = makeDeleteMapQry(activityInfoTableName)
-	setKeyInActivityInfoMapQry    = makeSetKeyInMapQry(activityInfoTableName, activityInfoColumns, activityInfoKey)
-	deleteKeyInActivityInfoMapQry = makeDeleteKeyInMapQry(activityInfoTableName, activityInfoKey)
-	getActivityInfoMapQry         = makeGetMapQryTemplate(activityInfoTableName, activityInfoColumns, activityInfoKey)
+	getActivityInfoMapsShardNextPageQryTemplate = `SELECT domain_id, workflow_id, run_id, schedule_id, data, data_encoding, last_heartbeat_details, last_heartbeat_updated_time
+FROM %v
+WHERE
+shard_id = $1 AND
+(domain_id, workflow_id, run_id, schedule_id) > ($2, $3, $4, $5)
+ORDER BY domain_id, workflow_id, run_id, schedule_id
+LIMIT $6`
 )
 
+type activityInfoMapsShardCursor struct {
+	DomainID   serialization.UUID
+	WorkflowID string
+	RunID      serialization.UUID
+	ScheduleID int64
+}
+
+func (c *activityInfoMapsShardCursor) serialize() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func (c *activityInfoMapsShardCursor) deserialize(payload []byte) error {
+	return json.Unmarshal(payload, c)
+}
+
+// checkRowsShardID returns an error unless all n rows of a batch for the given table have the shard ID of the first row,
+// the batch is routed by the first row only, so a mixed batch would silently write rows into the DB shard of another shard
+func checkRowsShardID(table string, n int, shardID func(i int) int64) error {
+	for i := 1; i < n; i++ {
+		if shardID(i) != shardID(0) {
+			return fmt.Errorf("batch for %v mixes shards: row %v has shard ID %v but row 0 has shard ID %v", table, i, shardID(i), shardID(0))
+		}
+	}
+	return nil
+}
+
+// mapRowKey identifies a row of an execution map table, key is the map key of the row
+type mapRowKey struct {
+	domainID   serialization.UUID
+	workflowID string
+	runID      serialization.UUID
+	key        interface{}
+}
+
+// dedupMapRows returns rows, a slice of row structs, without the rows whose map key is repeated by a later row of the batch.
+// Postgres rejects an INSERT ... ON CONFLICT DO UPDATE which affects the same row twice, the last occurrence is kept
+// as it is the one a sequence of single row writes would leave behind. Duplicated keys are a bug of the caller,
+// so a warning is logged whenever rows are removed.
+func (pdb *db) dedupMapRows(table string, shardID int64, rows interface{}, key func(i int) mapRowKey) interface{} {
+	type rowKey struct {
+		execution string
+		key       interface{}
+	}
+	v := reflect.ValueOf(rows)
+	keys := make([]rowKey, v.Len())
+	last := make(map[rowKey]int, v.Len())
+	for i := range keys {
+		k := key(i)
+		keys[i] = rowKey{execution: executionKey(k.domainID, k.workflowID, k.runID), key: k.key}
+		last[keys[i]] = i
+	}
+	if len(last) == v.Len() {
+		return rows
+	}
+	deduped := reflect.MakeSlice(v.Type(), 0, len(last))
+	for i := range keys {
+		if last[keys[i]] == i {
+			deduped = reflect.Append(deduped, v.Index(i))
+		}
+	}
+	if pdb.opts.logger != nil {
+		pdb.opts.logger.Warn("Removed execution map rows with duplicated keys from a batch",
+			tag.Name(table), tag.ShardID(int(shardID)), tag.Counter(v.Len()-len(last)))
+	}
+	return deduped.Interface()
+}
+
+// checkMapRowsLimit returns a LimitExceededError if writing the n rows of a batch into table would leave an execution
+// with more rows than allowed by config. Rows replacing an existing key of the execution do not add to the count.
+// countQuery counts the rows of an execution whose key is not one of the given keys.
+func (pdb *db) checkMapRowsLimit(
+	ctx context.Context,
+	dbShardID int,
+	table string,
+	countQuery string,
+	shardID int64,
+	n int,
+	rowKey func(i int) mapRowKey,
+) error {
+	limit := pdb.opts.maxExecutionMapRows
+	if limit <= 0 {
+		return nil
+	}
+	type executionKeys struct {
+		mapRowKey
+		keys []interface{}
+		seen map[interface{}]bool
+	}
+	var executions []*executionKeys
+	byExecution := make(map[string]*executionKeys)
+	for i := 0; i < n; i++ {
+		k := rowKey(i)
+		id := executionKey(k.domainID, k.workflowID, k.runID)
+		e, ok := byExecution[id]
+		if !ok {
+			e = &executionKeys{mapRowKey: k, seen: make(map[interface{}]bool)}
+			byExecution[id] = e
+			executions = append(executions, e)
+		}
+		if !e.seen[k.key] {
+			e.seen[k.key] = true
+			e.keys = append(e.keys, k.key)
+		}
+	}
+	for _, e := range executions {
+		count := 0
+		if len(e.keys) <= limit {
+			query, args, err := sqlx.In(countQuery, shardID, e.domainID, e.workflowID, e.runID, e.keys)
+			if err != nil {
+				return err
+			}
+			if err := pdb.mapDriver().GetContext(ctx, dbShardID, &count, sqlx.Rebind(sqlx.BindType(PluginName), query), args...); err != nil {
+				return err
+			}
+		}
+		if count+len(e.keys) > limit {
+			return &types.LimitExceededError{Message: fmt.Sprintf(
+				"writing %v rows into %v would leave workflow %v run %v with %v rows, the limit is %v",
+				len(e.keys), table, e.workflowID, e.runID, count+len(e.keys), limit)}
+		}
+	}
+	return nil
+}
+
+// upsertMapRows writes rows with the upsert query of an execution map table, either all rows are written or none.
+// If metrics are enabled the written rows are counted as inserts or updates, which needs the query to return a row
+// per written row. Without metrics the rows are written with namedExecBatch. The counters are emitted once the
+// statement succeeds, within a transaction they include writes which are rolled back later.
+// The inserted rows are added to the map_row_count of their executions in the same transaction when it is enabled.
+func (pdb *db) upsertMapRows(ctx context.Context, dbShardID int, table string, query string, rows interface{}) (result sql.Result, err error) {
+	rows, err = pdb.beforeExecMapRows(ctx, table, rows)
+	if err != nil {
+		return nil, err
+	}
+	err = pdb.countedMapWrite(ctx, dbShardID, reflect.ValueOf(rows).Len(), func(pdb *db) error {
+		result, err = pdb.writeMapRows(ctx, dbShardID, table, query, rows)
+		return err
+	})
+	return result, err
+}
+
+func (pdb *db) writeMapRows(ctx context.Context, dbShardID int, table string, query string, rows interface{}) (sql.Result, error) {
+	if pdb.opts.mapMetrics == nil && pdb.opts.mapChangeSink == nil && !pdb.opts.mapRowCounter {
+		return pdb.namedExecBatch(ctx, dbShardID, query, rows)
+	}
+	result, events, err := pdb.namedUpsertBatch(ctx, dbShardID, table, query, rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := pdb.recordMapWrites(ctx, dbShardID, table, result, events); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// recordMapWrites accounts for the rows written by an upsert on table: the inserted rows are added to the map_row_count
+// of their executions, the rows are counted as inserts or updates and their events are reported to the change sink,
+// each only if it is enabled
+func (pdb *db) recordMapWrites(ctx context.Context, dbShardID int, table string, result upsertResult, events []MapChangeEvent) error {
+	if pdb.opts.mapRowCounter {
+		if err := pdb.addInsertedMapRowCounts(ctx, dbShardID, events); err != nil {
+			return err
+		}
+	}
+	if pdb.opts.mapMetrics != nil {
+		cause := sqlplugin.MapWriteCauseFromContext(ctx)
+		pdb.opts.mapMetrics.RecordCount(table, writeTypeInsert, cause, result.inserted)
+		pdb.opts.mapMetrics.RecordCount(table, writeTypeUpdate, cause, result.updated)
+	}
+	if pdb.opts.mapChangeSink != nil {
+		pdb.reportMapChanges(events)
+	}
+	return nil
+}
+
 // ReplaceIntoActivityInfoMaps replaces one or more rows in activity_info_maps table
-func (pdb *db) ReplaceIntoActivityInfoMaps(ctx context.Context, rows []sqlplugin.ActivityInfoMapsRow) (sql.Result, error) {
+func (pdb *db) ReplaceIntoActivityInfoMaps(ctx context.Context, rows []sqlplugin.ActivityInfoMapsRow) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "ReplaceIntoActivityInfoMaps", activityInfoTableName)
+	defer func() { span.finish(len(rows), err) }()
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(rows[0].ShardID), pdb.GetTotalNumDBShards())
+	if err := checkRowsShardID("activity_info_maps", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	rows = pdb.dedupMapRows(activityInfoTableName, rows[0].ShardID, rows, func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].ScheduleID}
+	}).([]sqlplugin.ActivityInfoMapsRow)
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, activityInfoTableName, pdb.opts.queries.countOtherKeysInActivityInfoMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].ScheduleID}
+	}); err != nil {
+		return nil, err
+	}
 	for i := range rows {
 		rows[i].LastHeartbeatUpdatedTime = pdb.converter.ToPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
 	}
-	return pdb.driver.NamedExecContext(ctx, dbShardID, setKeyInActivityInfoMapQry, rows)
+	result, err = pdb.upsertMapRows(ctx, dbShardID, activityInfoTableName, pdb.opts.queries.setKeyInActivityInfoMapQry, rows)
+	for _, row := range rows {
+		pdb.activityInfoMapsWritten(row.ShardID, row.DomainID, row.WorkflowID, row.RunID)
+	}
+	return result, err
 }
 
 // SelectFromActivityInfoMaps reads one or more rows from activity_info_maps table
-func (pdb *db) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) ([]sqlplugin.ActivityInfoMapsRow, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
-	var rows []sqlplugin.ActivityInfoMapsRow
-	err := pdb.driver.SelectContext(ctx, dbShardID, &rows, getActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+func (pdb *db) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (result []sqlplugin.ActivityInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromActivityInfoMaps", activityInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	// reads inside a transaction bypass the cache, they may observe writes which are not committed yet
+	activityCache := pdb.opts.activityInfoMapsCache
+	if pdb.isTx {
+		activityCache = nil
+	}
+	key := newActivityInfoMapsCacheKey(filter.ShardID, string(filter.DomainID), filter.WorkflowID, string(filter.RunID))
+	var readSeq uint64
+	if activityCache != nil {
+		if rows, ok := activityCache.get(key); ok {
+			return rows, nil
+		}
+		readSeq = activityCache.startRead()
+	}
+
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.ActivityInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromActivityInfoMaps", Err: err}
+	}
+	for i := 0; i < len(rows); i++ {
+		rows[i].ShardID = int64(filter.ShardID)
+		rows[i].DomainID = filter.DomainID
+		rows[i].WorkflowID = filter.WorkflowID
+		rows[i].RunID = filter.RunID
+		rows[i].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
+	}
+	if err := pdb.afterScanMapRows(ctx, activityInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	if activityCache != nil {
+		activityCache.put(key, rows, readSeq)
+	}
+	return rows, nil
+}
+
+// SelectActivityInfoMetadata reads the schedule_id and the heartbeat columns of the activity_info_maps rows of an
+// execution, it skips the data column which is the bulk of a row. The rows are not cached and no hook is called
+// since there is no data to process
+func (pdb *db) SelectActivityInfoMetadata(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (result []sqlplugin.ActivityInfoMetadataRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectActivityInfoMetadata", activityInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.ActivityInfoMetadataRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getActivityInfoMetadataQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectActivityInfoMetadata", Err: err}
+	}
+	for i := 0; i < len(rows); i++ {
+		rows[i].ShardID = int64(filter.ShardID)
+		rows[i].DomainID = filter.DomainID
+		rows[i].WorkflowID = filter.WorkflowID
+		rows[i].RunID = filter.RunID
+		rows[i].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
+	}
+	return rows, nil
+}
+
+// GetActivityInfoMapRow reads the activity_info_maps row of a single activity. An execution whose activities are
+// cached is served from the cache, otherwise only the row of scheduleID is read and the cache is left as it is
+func (pdb *db) GetActivityInfoMapRow(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter, scheduleID int64) (result sqlplugin.ActivityInfoMapsRow, found bool, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "GetActivityInfoMapRow", activityInfoTableName)
+	defer func() {
+		count := 0
+		if found {
+			count = 1
+		}
+		span.finish(count, err)
+	}()
+	if pdb.opts.activityInfoMapsCache != nil && !pdb.isTx {
+		key := newActivityInfoMapsCacheKey(filter.ShardID, string(filter.DomainID), filter.WorkflowID, string(filter.RunID))
+		if rows, ok := pdb.opts.activityInfoMapsCache.get(key); ok {
+			for _, row := range rows {
+				if row.ScheduleID == scheduleID {
+					return row, true, nil
+				}
+			}
+			return sqlplugin.ActivityInfoMapsRow{}, false, nil
+		}
+	}
+
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.ActivityInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getActivityInfoMapRowQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, scheduleID)
+	if err != nil && err != sql.ErrNoRows {
+		return sqlplugin.ActivityInfoMapsRow{}, false, &sqlplugin.PersistenceError{Operation: "GetActivityInfoMapRow", Err: err}
+	}
+	if len(rows) == 0 {
+		return sqlplugin.ActivityInfoMapsRow{}, false, nil
+	}
+	rows[0].ShardID = int64(filter.ShardID)
+	rows[0].DomainID = filter.DomainID
+	rows[0].WorkflowID = filter.WorkflowID
+	rows[0].RunID = filter.RunID
+	rows[0].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[0].LastHeartbeatUpdatedTime)
+	if err := pdb.afterScanMapRows(ctx, activityInfoTableName, rows[:1]); err != nil {
+		return sqlplugin.ActivityInfoMapsRow{}, false, err
+	}
+	return rows[0], true, nil
+}
+
+var errSelectForUpdateOutsideTx = errors.New("rows can only be selected for update within a transaction")
+
+// SelectFromActivityInfoMapsForUpdate reads the rows of activity_info_maps selected by filter and locks them until
+// the transaction ends, so a read-modify-write of the rows cannot lose a concurrent update. If filter.ScheduleIDs is
+// empty every row of the execution is locked. It bypasses the cache and fails unless called on a transaction.
+func (pdb *db) SelectFromActivityInfoMapsForUpdate(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (result []sqlplugin.ActivityInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromActivityInfoMapsForUpdate", activityInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	if !pdb.isTx {
+		return nil, errSelectForUpdateOutsideTx
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	query := pdb.opts.queries.getActivityInfoMapForUpdateQry
+	args := []interface{}{filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID}
+	if len(filter.ScheduleIDs) > 0 {
+		query, args, err = sqlx.In(pdb.opts.queries.getKeysInActivityInfoMapForUpdateQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.ScheduleIDs)
+		if err != nil {
+			return nil, err
+		}
+		query = sqlx.Rebind(sqlx.BindType(PluginName), query)
+	}
+	rows := []sqlplugin.ActivityInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromActivityInfoMapsForUpdate", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
@@ -183,20 +783,248 @@ func (pdb *db) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin
 		rows[i].RunID = filter.RunID
 		rows[i].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
 	}
-	return rows, err
+	if err := pdb.afterScanMapRows(ctx, activityInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SelectActivityInfoMapsShardCursor pages through the activity_info_maps rows of all executions in a shard,
+// ordered by (domain_id, workflow_id, run_id, schedule_id). The returned cursor is opaque and resumes right after
+// the last returned row instead of at an offset, so rows inserted concurrently never shift or repeat a page.
+// An empty cursor starts from the beginning, a nil cursor is returned once the shard is exhausted.
+func (pdb *db) SelectActivityInfoMapsShardCursor(ctx context.Context, shardID int, cursor []byte, pageSize int) ([]sqlplugin.ActivityInfoMapsRow, []byte, error) {
+	// a page of no rows would end the paging with a nil cursor although the shard is not exhausted
+	if pageSize < 1 {
+		return nil, nil, fmt.Errorf("invalid pageSize %v, it must be positive", pageSize)
+	}
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	var rows []sqlplugin.ActivityInfoMapsRow
+	var err error
+	if len(cursor) == 0 {
+		err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, pdb.opts.queries.getActivityInfoMapsShardFirstPageQry, shardID, pageSize)
+	} else {
+		var last activityInfoMapsShardCursor
+		if err := last.deserialize(cursor); err != nil {
+			return nil, nil, err
+		}
+		err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, pdb.opts.queries.getActivityInfoMapsShardNextPageQry,
+			shardID, last.DomainID, last.WorkflowID, last.RunID, last.ScheduleID, pageSize)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range rows {
+		rows[i].ShardID = int64(shardID)
+		rows[i].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
+	}
+	if err := pdb.afterScanMapRows(ctx, activityInfoTableName, rows); err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 || len(rows) < pageSize {
+		return rows, nil, nil
+	}
+	lastRow := rows[len(rows)-1]
+	next := &activityInfoMapsShardCursor{
+		DomainID:   lastRow.DomainID,
+		WorkflowID: lastRow.WorkflowID,
+		RunID:      lastRow.RunID,
+		ScheduleID: lastRow.ScheduleID,
+	}
+	nextCursor, err := next.serialize()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, nextCursor, nil
 }
 
 // DeleteFromActivityInfoMaps deletes one or more rows from activity_info_maps table
-func (pdb *db) DeleteFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (sql.Result, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
+func (pdb *db) DeleteFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "DeleteFromActivityInfoMaps", activityInfoTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	defer pdb.activityInfoMapsWritten(filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, activityInfoTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, int64Keys(filter.ScheduleIDs)); err != nil {
+		return nil, err
+	}
 	if len(filter.ScheduleIDs) > 0 {
-		query, args, err := sqlx.In(deleteKeyInActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.ScheduleIDs)
+		query, args, err := sqlx.In(pdb.opts.queries.deleteKeyInActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.ScheduleIDs)
 		if err != nil {
 			return nil, err
 		}
-		return pdb.driver.ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+		return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+	}
+	return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, pdb.opts.queries.deleteActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+}
+
+// lockExecutionStateQuery reads the state of an execution and keeps the row from changing until the transaction ends
+const lockExecutionStateQuery = `SELECT data, data_encoding FROM executions
+WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4
+FOR SHARE`
+
+// ExecutionNotClosedError is returned by DeleteFromActivityInfoMapsIfClosed when the executions table shows
+// the execution as still open, nothing is deleted then
+type ExecutionNotClosedError struct {
+	ShardID    int64
+	DomainID   serialization.UUID
+	WorkflowID string
+	RunID      serialization.UUID
+	State      int
+}
+
+func (e *ExecutionNotClosedError) Error() string {
+	return fmt.Sprintf("execution %v/%v/%v/%v is not closed, its state is %v", e.ShardID, e.DomainID, e.WorkflowID, e.RunID, e.State)
+}
+
+// ExecutionNotFoundError is returned by DeleteFromActivityInfoMapsIfClosed when the executions table has no row of
+// the execution, its state is unknown and nothing is deleted then
+type ExecutionNotFoundError struct {
+	ShardID    int64
+	DomainID   serialization.UUID
+	WorkflowID string
+	RunID      serialization.UUID
+}
+
+func (e *ExecutionNotFoundError) Error() string {
+	return fmt.Sprintf("execution %v/%v/%v/%v is not found, its state is unknown", e.ShardID, e.DomainID, e.WorkflowID, e.RunID)
+}
+
+// DeleteFromActivityInfoMapsIfClosed deletes the rows in the same transaction the executions row is locked in,
+// so the execution can not be reopened, e.g. by a reset, between the check and the delete. While the number of DB
+// shards is migrated, the executions row is looked up on the DB shard of the previous number when it is not found on
+// the current one, and the rows are deleted from both DB shards like the other deletes do
+func (pdb *db) DeleteFromActivityInfoMapsIfClosed(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (result sql.Result, err error) {
+	guardedDelete := func(tx *db) error {
+		if err := tx.lockClosedExecution(ctx, filter); err != nil {
+			return err
+		}
+		result, err = tx.DeleteFromActivityInfoMaps(ctx, filter)
+		return err
+	}
+	if pdb.isTx {
+		if err := guardedDelete(pdb); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	previous, migrating := pdb.previousDBShardID(int(filter.ShardID), dbShardID)
+	other := previous
+	err = pdb.txExecute(ctx, dbShardID, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.LockedUpdate, guardedDelete)
+	var notFound *ExecutionNotFoundError
+	if migrating && errors.As(err, &notFound) {
+		other = dbShardID
+		err = pdb.txExecute(ctx, previous, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.LockedUpdate, guardedDelete)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !migrating {
+		return result, nil
+	}
+	// the rows left on the other DB shard would be read through the fallback of selectMapRows otherwise
+	guarded := result
+	err = pdb.txExecute(ctx, other, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.LockedUpdate, func(tx *db) error {
+		result, err = tx.DeleteFromActivityInfoMaps(ctx, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchResult(rowsAffected(guarded) + rowsAffected(result)), nil
+}
+
+// lockClosedExecution locks the executions row of the execution of filter and fails unless it shows the execution
+// as closed, pdb must be a transaction for the lock to last until its deletes are done
+func (pdb *db) lockClosedExecution(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) error {
+	var row struct {
+		Data         []byte
+		DataEncoding string
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	err := pdb.mapDriver().GetContext(ctx, dbShardID, &row, lockExecutionStateQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err == sql.ErrNoRows {
+		return &ExecutionNotFoundError{ShardID: filter.ShardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID}
+	}
+	if err != nil {
+		return err
+	}
+	info, err := pdb.opts.executionParser.WorkflowExecutionInfoFromBlob(row.Data, row.DataEncoding)
+	if err != nil {
+		return err
+	}
+	if info.State != persistence.WorkflowStateCompleted {
+		return &ExecutionNotClosedError{
+			ShardID:    filter.ShardID,
+			DomainID:   filter.DomainID,
+			WorkflowID: filter.WorkflowID,
+			RunID:      filter.RunID,
+			State:      int(info.State),
+		}
 	}
-	return pdb.driver.ExecContext(ctx, dbShardID, deleteActivityInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	return nil
+}
+
+// activityInfoMapsWritten invalidates the cached activity_info_maps rows of an execution after they were written.
+// Within a transaction the invalidation is deferred to the commit, until then other readers still see the old rows.
+func (pdb *db) activityInfoMapsWritten(shardID int64, domainID serialization.UUID, workflowID string, runID serialization.UUID) {
+	if pdb.opts.activityInfoMapsCache == nil {
+		return
+	}
+	key := newActivityInfoMapsCacheKey(shardID, string(domainID), workflowID, string(runID))
+	if pdb.isTx {
+		pdb.txActivityInfoMapsWrites = append(pdb.txActivityInfoMapsWrites, key)
+		return
+	}
+	pdb.opts.activityInfoMapsCache.invalidate(key)
+}
+
+type activityInfoMapsKey struct {
+	shardID    int64
+	domainID   string
+	workflowID string
+	runID      string
+	scheduleID int64
+}
+
+func activityInfoMapsKeyOf(row sqlplugin.ActivityInfoMapsRow) activityInfoMapsKey {
+	return activityInfoMapsKey{
+		shardID:    row.ShardID,
+		domainID:   string(row.DomainID),
+		workflowID: row.WorkflowID,
+		runID:      string(row.RunID),
+		scheduleID: row.ScheduleID,
+	}
+}
+
+// FlushHeartbeats coalesces buffered heartbeat updates into one batched upsert into activity_info_maps table.
+// When the same activity shows up more than once, only the row with the latest LastHeartbeatUpdatedTime is written.
+func (pdb *db) FlushHeartbeats(ctx context.Context, rows []sqlplugin.ActivityInfoMapsRow) (sql.Result, error) {
+	return pdb.ReplaceIntoActivityInfoMaps(ctx, coalesceHeartbeats(rows))
+}
+
+// coalesceHeartbeats keeps the most recent heartbeat per activity, preserving the order in which
+// each activity was first seen
+func coalesceHeartbeats(rows []sqlplugin.ActivityInfoMapsRow) []sqlplugin.ActivityInfoMapsRow {
+	latest := make(map[activityInfoMapsKey]int, len(rows))
+	result := make([]sqlplugin.ActivityInfoMapsRow, 0, len(rows))
+	for _, row := range rows {
+		key := activityInfoMapsKeyOf(row)
+		idx, ok := latest[key]
+		if !ok {
+			latest[key] = len(result)
+			result = append(result, row)
+			continue
+		}
+		if !row.LastHeartbeatUpdatedTime.Before(result[idx].LastHeartbeatUpdatedTime) {
+			result[idx] = row
+		}
+	}
+	return result
 }
 
 var (
@@ -206,47 +1034,156 @@ var (
 	}
 	timerInfoTableName = "timer_info_maps"
 	timerInfoKey       = "timer_id"
-
-	deleteTimerInfoMapSQLQuery      = makeDeleteMapQry(timerInfoTableName)
-	setKeyInTimerInfoMapSQLQuery    = makeSetKeyInMapQry(timerInfoTableName, timerInfoColumns, timerInfoKey)
-	deleteKeyInTimerInfoMapSQLQuery = makeDeleteKeyInMapQry(timerInfoTableName, timerInfoKey)
-	getTimerInfoMapSQLQuery         = makeGetMapQryTemplate(timerInfoTableName, timerInfoColumns, timerInfoKey)
 )
 
 // ReplaceIntoTimerInfoMaps replaces one or more rows in timer_info_maps table
-func (pdb *db) ReplaceIntoTimerInfoMaps(ctx context.Context, rows []sqlplugin.TimerInfoMapsRow) (sql.Result, error) {
+func (pdb *db) ReplaceIntoTimerInfoMaps(ctx context.Context, rows []sqlplugin.TimerInfoMapsRow) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "ReplaceIntoTimerInfoMaps", timerInfoTableName)
+	defer func() { span.finish(len(rows), err) }()
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(rows[0].ShardID), pdb.GetTotalNumDBShards())
-	return pdb.driver.NamedExecContext(ctx, dbShardID, setKeyInTimerInfoMapSQLQuery, rows)
+	if err := checkRowsShardID("timer_info_maps", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	rows = pdb.dedupMapRows(timerInfoTableName, rows[0].ShardID, rows, func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].TimerID}
+	}).([]sqlplugin.TimerInfoMapsRow)
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, timerInfoTableName, pdb.opts.queries.countOtherKeysInTimerInfoMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].TimerID}
+	}); err != nil {
+		return nil, err
+	}
+	return pdb.upsertMapRows(ctx, dbShardID, timerInfoTableName, pdb.opts.queries.setKeyInTimerInfoMapSQLQuery, rows)
+}
+
+// ReplaceIntoTimerInfoMapsWithReferenceTime replaces rows in timer_info_maps table after checking that the fire time
+// of each timer is no earlier than the max staleness of the config before referenceTime. A stale or undecodable timer
+// is logged and counted, in strict mode the write is rejected with a BadRequestError. Without a max staleness in
+// config it is ReplaceIntoTimerInfoMaps
+func (pdb *db) ReplaceIntoTimerInfoMapsWithReferenceTime(ctx context.Context, rows []sqlplugin.TimerInfoMapsRow, referenceTime time.Time) (sql.Result, error) {
+	if pdb.opts.timerParser != nil {
+		if err := pdb.validateTimerFireTimes(rows, referenceTime); err != nil {
+			return nil, err
+		}
+	}
+	return pdb.ReplaceIntoTimerInfoMaps(ctx, rows)
+}
+
+func (pdb *db) validateTimerFireTimes(rows []sqlplugin.TimerInfoMapsRow, referenceTime time.Time) error {
+	oldest := referenceTime.Add(-pdb.opts.timerMaxStaleness)
+	stale := 0
+	var firstErr error
+	for _, row := range rows {
+		var reason string
+		timer, err := pdb.opts.timerParser.TimerInfoFromBlob(row.Data, row.DataEncoding)
+		switch {
+		case err != nil:
+			reason = fmt.Sprintf("cannot be decoded: %v", err)
+		case timer.ExpiryTimestamp.Before(oldest):
+			reason = fmt.Sprintf("fires at %v, more than %v before %v", timer.ExpiryTimestamp, pdb.opts.timerMaxStaleness, referenceTime)
+		default:
+			continue
+		}
+		stale++
+		if firstErr == nil {
+			firstErr = &types.BadRequestError{Message: fmt.Sprintf(
+				"timer %v of workflow %v run %v %v", row.TimerID, row.WorkflowID, row.RunID, reason)}
+		}
+		if pdb.opts.logger != nil {
+			pdb.opts.logger.Warn("Stale timer written to timer_info_maps",
+				tag.ShardID(int(row.ShardID)), tag.WorkflowDomainID(row.DomainID.String()), tag.WorkflowID(row.WorkflowID),
+				tag.WorkflowRunID(row.RunID.String()), tag.WorkflowTimerID(row.TimerID), tag.Value(reason))
+		}
+	}
+	if stale > 0 && pdb.opts.metricsClient != nil {
+		pdb.opts.metricsClient.Scope(metrics.PersistenceExecutionMapWriteScope, metrics.TableTag(timerInfoTableName)).
+			AddCounter(metrics.PersistenceStaleTimerInfoMapsRows, int64(stale))
+	}
+	if pdb.opts.timerStrictMode {
+		return firstErr
+	}
+	return nil
 }
 
 // SelectFromTimerInfoMaps reads one or more rows from timer_info_maps table
-func (pdb *db) SelectFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) ([]sqlplugin.TimerInfoMapsRow, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
-	var rows []sqlplugin.TimerInfoMapsRow
-	err := pdb.driver.SelectContext(ctx, dbShardID, &rows, getTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+func (pdb *db) SelectFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) (result []sqlplugin.TimerInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromTimerInfoMaps", timerInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.TimerInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromTimerInfoMaps", Err: err}
+	}
+	for i := 0; i < len(rows); i++ {
+		rows[i].ShardID = int64(filter.ShardID)
+		rows[i].DomainID = filter.DomainID
+		rows[i].WorkflowID = filter.WorkflowID
+		rows[i].RunID = filter.RunID
+	}
+	if err := pdb.afterScanMapRows(ctx, timerInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SelectTimerInfoByTimerIDs reads the rows of the given timers from timer_info_maps table
+func (pdb *db) SelectTimerInfoByTimerIDs(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter, timerIDs []string) (result []sqlplugin.TimerInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectTimerInfoByTimerIDs", timerInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	rows := []sqlplugin.TimerInfoMapsRow{}
+	if len(timerIDs) == 0 {
+		return rows, nil
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	query, args, err := sqlx.In(pdb.opts.queries.getKeysInTimerInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, timerIDs)
+	if err != nil {
+		return nil, err
+	}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectTimerInfoByTimerIDs", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	if err := pdb.afterScanMapRows(ctx, timerInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
 }
 
 // DeleteFromTimerInfoMaps deletes one or more rows from timer_info_maps table
-func (pdb *db) DeleteFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) (sql.Result, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
+func (pdb *db) DeleteFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "DeleteFromTimerInfoMaps", timerInfoTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, timerInfoTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.TimerIDs); err != nil {
+		return nil, err
+	}
 	if len(filter.TimerIDs) > 0 {
-		query, args, err := sqlx.In(deleteKeyInTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.TimerIDs)
+		query, args, err := sqlx.In(pdb.opts.queries.deleteKeyInTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.TimerIDs)
 		if err != nil {
 			return nil, err
 		}
-		return pdb.driver.ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+		return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
 	}
-	return pdb.driver.ExecContext(ctx, dbShardID, deleteTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, pdb.opts.queries.deleteTimerInfoMapSQLQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
 }
 
 var (
@@ -256,47 +1193,77 @@ var (
 	}
 	childExecutionInfoTableName = "child_execution_info_maps"
 	childExecutionInfoKey       = "initiated_id"
-
-	deleteChildExecutionInfoMapQry      = makeDeleteMapQry(childExecutionInfoTableName)
-	setKeyInChildExecutionInfoMapQry    = makeSetKeyInMapQry(childExecutionInfoTableName, childExecutionInfoColumns, childExecutionInfoKey)
-	deleteKeyInChildExecutionInfoMapQry = makeDeleteKeyInMapQry(childExecutionInfoTableName, childExecutionInfoKey)
-	getChildExecutionInfoMapQry         = makeGetMapQryTemplate(childExecutionInfoTableName, childExecutionInfoColumns, childExecutionInfoKey)
 )
 
 // ReplaceIntoChildExecutionInfoMaps replaces one or more rows in child_execution_info_maps table
-func (pdb *db) ReplaceIntoChildExecutionInfoMaps(ctx context.Context, rows []sqlplugin.ChildExecutionInfoMapsRow) (sql.Result, error) {
+func (pdb *db) ReplaceIntoChildExecutionInfoMaps(ctx context.Context, rows []sqlplugin.ChildExecutionInfoMapsRow) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "ReplaceIntoChildExecutionInfoMaps", childExecutionInfoTableName)
+	defer func() { span.finish(len(rows), err) }()
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(rows[0].ShardID), pdb.GetTotalNumDBShards())
-	return pdb.driver.NamedExecContext(ctx, dbShardID, setKeyInChildExecutionInfoMapQry, rows)
+	if err := checkRowsShardID("child_execution_info_maps", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	rows = pdb.dedupMapRows(childExecutionInfoTableName, rows[0].ShardID, rows, func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].InitiatedID}
+	}).([]sqlplugin.ChildExecutionInfoMapsRow)
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, childExecutionInfoTableName, pdb.opts.queries.countOtherKeysInChildExecutionInfoMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].InitiatedID}
+	}); err != nil {
+		return nil, err
+	}
+	return pdb.upsertMapRows(ctx, dbShardID, childExecutionInfoTableName, pdb.opts.queries.setKeyInChildExecutionInfoMapQry, rows)
 }
 
 // SelectFromChildExecutionInfoMaps reads one or more rows from child_execution_info_maps table
-func (pdb *db) SelectFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) ([]sqlplugin.ChildExecutionInfoMapsRow, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
-	var rows []sqlplugin.ChildExecutionInfoMapsRow
-	err := pdb.driver.SelectContext(ctx, dbShardID, &rows, getChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+func (pdb *db) SelectFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) (result []sqlplugin.ChildExecutionInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromChildExecutionInfoMaps", childExecutionInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.ChildExecutionInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromChildExecutionInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	if err := pdb.afterScanMapRows(ctx, childExecutionInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
 }
 
 // DeleteFromChildExecutionInfoMaps deletes one or more rows from child_execution_info_maps table
-func (pdb *db) DeleteFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) (sql.Result, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
+func (pdb *db) DeleteFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "DeleteFromChildExecutionInfoMaps", childExecutionInfoTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, childExecutionInfoTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, int64Keys(filter.InitiatedIDs)); err != nil {
+		return nil, err
+	}
 	if len(filter.InitiatedIDs) > 0 {
-		query, args, err := sqlx.In(deleteKeyInChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.InitiatedIDs)
+		query, args, err := sqlx.In(pdb.opts.queries.deleteKeyInChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.InitiatedIDs)
 		if err != nil {
 			return nil, err
 		}
-		return pdb.driver.ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+		return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
 	}
-	return pdb.driver.ExecContext(ctx, dbShardID, deleteChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, pdb.opts.queries.deleteChildExecutionInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
 }
 
 var (
@@ -306,47 +1273,77 @@ var (
 	}
 	requestCancelInfoTableName = "request_cancel_info_maps"
 	requestCancelInfoKey       = "initiated_id"
-
-	deleteRequestCancelInfoMapQry      = makeDeleteMapQry(requestCancelInfoTableName)
-	setKeyInRequestCancelInfoMapQry    = makeSetKeyInMapQry(requestCancelInfoTableName, requestCancelInfoColumns, requestCancelInfoKey)
-	deleteKeyInRequestCancelInfoMapQry = makeDeleteKeyInMapQry(requestCancelInfoTableName, requestCancelInfoKey)
-	getRequestCancelInfoMapQry         = makeGetMapQryTemplate(requestCancelInfoTableName, requestCancelInfoColumns, requestCancelInfoKey)
 )
 
 // ReplaceIntoRequestCancelInfoMaps replaces one or more rows in request_cancel_info_maps table
-func (pdb *db) ReplaceIntoRequestCancelInfoMaps(ctx context.Context, rows []sqlplugin.RequestCancelInfoMapsRow) (sql.Result, error) {
+func (pdb *db) ReplaceIntoRequestCancelInfoMaps(ctx context.Context, rows []sqlplugin.RequestCancelInfoMapsRow) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "ReplaceIntoRequestCancelInfoMaps", requestCancelInfoTableName)
+	defer func() { span.finish(len(rows), err) }()
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(rows[0].ShardID), pdb.GetTotalNumDBShards())
-	return pdb.driver.NamedExecContext(ctx, dbShardID, setKeyInRequestCancelInfoMapQry, rows)
+	if err := checkRowsShardID("request_cancel_info_maps", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	rows = pdb.dedupMapRows(requestCancelInfoTableName, rows[0].ShardID, rows, func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].InitiatedID}
+	}).([]sqlplugin.RequestCancelInfoMapsRow)
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, requestCancelInfoTableName, pdb.opts.queries.countOtherKeysInRequestCancelInfoMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].InitiatedID}
+	}); err != nil {
+		return nil, err
+	}
+	return pdb.upsertMapRows(ctx, dbShardID, requestCancelInfoTableName, pdb.opts.queries.setKeyInRequestCancelInfoMapQry, rows)
 }
 
 // SelectFromRequestCancelInfoMaps reads one or more rows from request_cancel_info_maps table
-func (pdb *db) SelectFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) ([]sqlplugin.RequestCancelInfoMapsRow, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
-	var rows []sqlplugin.RequestCancelInfoMapsRow
-	err := pdb.driver.SelectContext(ctx, dbShardID, &rows, getRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+func (pdb *db) SelectFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) (result []sqlplugin.RequestCancelInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromRequestCancelInfoMaps", requestCancelInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.RequestCancelInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromRequestCancelInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	if err := pdb.afterScanMapRows(ctx, requestCancelInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
 }
 
 // DeleteFromRequestCancelInfoMaps deletes one or more rows from request_cancel_info_maps table
-func (pdb *db) DeleteFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) (sql.Result, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
+func (pdb *db) DeleteFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "DeleteFromRequestCancelInfoMaps", requestCancelInfoTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, requestCancelInfoTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, int64Keys(filter.InitiatedIDs)); err != nil {
+		return nil, err
+	}
 	if len(filter.InitiatedIDs) > 0 {
-		query, args, err := sqlx.In(deleteKeyInRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.InitiatedIDs)
+		query, args, err := sqlx.In(pdb.opts.queries.deleteKeyInRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.InitiatedIDs)
 		if err != nil {
 			return nil, err
 		}
-		return pdb.driver.ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+		return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
 	}
-	return pdb.driver.ExecContext(ctx, dbShardID, deleteRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, pdb.opts.queries.deleteRequestCancelInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
 }
 
 var (
@@ -357,80 +1354,751 @@ var (
 	signalInfoTableName = "signal_info_maps"
 	signalInfoKey       = "initiated_id"
 
-	deleteSignalInfoMapQry      = makeDeleteMapQry(signalInfoTableName)
-	setKeyInSignalInfoMapQry    = makeSetKeyInMapQry(signalInfoTableName, signalInfoColumns, signalInfoKey)
-	deleteKeyInSignalInfoMapQry = makeDeleteKeyInMapQry(signalInfoTableName, signalInfoKey)
-	getSignalInfoMapQry         = makeGetMapQryTemplate(signalInfoTableName, signalInfoColumns, signalInfoKey)
+	signalsRequestedSetsTableName = "signals_requested_sets"
 )
 
 // ReplaceIntoSignalInfoMaps replaces one or more rows in signal_info_maps table
-func (pdb *db) ReplaceIntoSignalInfoMaps(ctx context.Context, rows []sqlplugin.SignalInfoMapsRow) (sql.Result, error) {
+func (pdb *db) ReplaceIntoSignalInfoMaps(ctx context.Context, rows []sqlplugin.SignalInfoMapsRow) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "ReplaceIntoSignalInfoMaps", signalInfoTableName)
+	defer func() { span.finish(len(rows), err) }()
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(rows[0].ShardID), pdb.GetTotalNumDBShards())
-	return pdb.driver.NamedExecContext(ctx, dbShardID, setKeyInSignalInfoMapQry, rows)
+	if err := checkRowsShardID("signal_info_maps", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	rows = pdb.dedupMapRows(signalInfoTableName, rows[0].ShardID, rows, func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].InitiatedID}
+	}).([]sqlplugin.SignalInfoMapsRow)
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, signalInfoTableName, pdb.opts.queries.countOtherKeysInSignalInfoMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].InitiatedID}
+	}); err != nil {
+		return nil, err
+	}
+	return pdb.upsertMapRows(ctx, dbShardID, signalInfoTableName, pdb.opts.queries.setKeyInSignalInfoMapQry, rows)
 }
 
 // SelectFromSignalInfoMaps reads one or more rows from signal_info_maps table
-func (pdb *db) SelectFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) ([]sqlplugin.SignalInfoMapsRow, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
-	var rows []sqlplugin.SignalInfoMapsRow
-	err := pdb.driver.SelectContext(ctx, dbShardID, &rows, getSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+func (pdb *db) SelectFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) (result []sqlplugin.SignalInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromSignalInfoMaps", signalInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.SignalInfoMapsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromSignalInfoMaps", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	if err := pdb.afterScanMapRows(ctx, signalInfoTableName, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
 }
 
 // DeleteFromSignalInfoMaps deletes one or more rows from signal_info_maps table
-func (pdb *db) DeleteFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) (sql.Result, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
+func (pdb *db) DeleteFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "DeleteFromSignalInfoMaps", signalInfoTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, signalInfoTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, int64Keys(filter.InitiatedIDs)); err != nil {
+		return nil, err
+	}
 	if len(filter.InitiatedIDs) > 0 {
-		query, args, err := sqlx.In(deleteKeyInSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.InitiatedIDs)
+		query, args, err := sqlx.In(pdb.opts.queries.deleteKeyInSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.InitiatedIDs)
 		if err != nil {
 			return nil, err
 		}
-		return pdb.driver.ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+		return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
 	}
-	return pdb.driver.ExecContext(ctx, dbShardID, deleteSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	return pdb.execCountedMapDelete(ctx, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, dbShardID, pdb.opts.queries.deleteSignalInfoMapQry, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
 }
 
-// InsertIntoSignalsRequestedSets inserts one or more rows into signals_requested_sets table
-func (pdb *db) InsertIntoSignalsRequestedSets(ctx context.Context, rows []sqlplugin.SignalsRequestedSetsRow) (sql.Result, error) {
+// InsertIntoSignalsRequestedSets inserts one or more rows into signals_requested_sets table. A signal ID which is already
+// in the set is kept as is, in strict mode it fails the insert with a BadRequestError
+func (pdb *db) InsertIntoSignalsRequestedSets(ctx context.Context, rows []sqlplugin.SignalsRequestedSetsRow) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "InsertIntoSignalsRequestedSets", signalsRequestedSetsTableName)
+	defer func() { span.finish(len(rows), err) }()
 	if len(rows) == 0 {
 		return nil, nil
 	}
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(rows[0].ShardID), pdb.GetTotalNumDBShards())
-	return pdb.driver.NamedExecContext(ctx, dbShardID, createSignalsRequestedSetQuery, rows)
+	if err := checkRowsShardID("signals_requested_sets", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, signalsRequestedSetsTableName, pdb.opts.queries.countOtherKeysInSignalsRequestedSetMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].SignalID}
+	}); err != nil {
+		return nil, err
+	}
+	if pdb.opts.signalsStrictMode {
+		return pdb.insertSignalsRequestedSetsStrict(ctx, dbShardID, rows)
+	}
+	err = pdb.atomicBatch(ctx, dbShardID, len(rows), func(pdb *db) error {
+		result, err = pdb.namedExecBatch(ctx, dbShardID, pdb.opts.queries.createSignalsRequestedSetQuery, rows)
+		return err
+	})
+	return result, err
+}
+
+// insertSignalsRequestedSetsStrict inserts the rows and fails with a BadRequestError naming the signal IDs which were
+// not inserted because they are already in the set, or repeated within rows
+func (pdb *db) insertSignalsRequestedSetsStrict(ctx context.Context, dbShardID int, rows []sqlplugin.SignalsRequestedSetsRow) (sql.Result, error) {
+	insertedRows, err := pdb.insertSignalsRequestedSetsReturning(ctx, dbShardID, rows)
+	if err != nil {
+		return nil, err
+	}
+	inserted := make(map[sqlplugin.SignalsRequestedSetKey]struct{}, len(insertedRows))
+	for _, row := range insertedRows {
+		inserted[row.Key()] = struct{}{}
+	}
+	var collisions []string
+	for _, row := range rows {
+		key := row.Key()
+		if _, ok := inserted[key]; ok {
+			// a row repeated within rows is only inserted once
+			delete(inserted, key)
+			continue
+		}
+		collisions = append(collisions, row.SignalID)
+	}
+	if len(collisions) > 0 {
+		return nil, &types.BadRequestError{Message: fmt.Sprintf(
+			"signal IDs %v of workflow %v run %v are already requested", collisions, rows[0].WorkflowID, rows[0].RunID)}
+	}
+	return batchResult(len(rows)), nil
+}
+
+// InsertSignalsRequestedSetsReporting inserts one or more rows into signals_requested_sets table
+// and returns the keys of the rows which were newly added, rows which already existed are not part of the result
+func (pdb *db) InsertSignalsRequestedSetsReporting(ctx context.Context, rows []sqlplugin.SignalsRequestedSetsRow) (map[sqlplugin.SignalsRequestedSetKey]struct{}, error) {
+	inserted := make(map[sqlplugin.SignalsRequestedSetKey]struct{})
+	if len(rows) == 0 {
+		return inserted, nil
+	}
+	if err := checkRowsShardID("signals_requested_sets", len(rows), func(i int) int64 { return rows[i].ShardID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.allowMapWrites(ctx, len(rows), func(i int) serialization.UUID { return rows[i].DomainID }); err != nil {
+		return nil, err
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(rows[0].ShardID))
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, signalsRequestedSetsTableName, pdb.opts.queries.countOtherKeysInSignalsRequestedSetMapQry, rows[0].ShardID, len(rows), func(i int) mapRowKey {
+		return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].SignalID}
+	}); err != nil {
+		return nil, err
+	}
+	insertedRows, err := pdb.insertSignalsRequestedSetsReturning(ctx, dbShardID, rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range insertedRows {
+		inserted[row.Key()] = struct{}{}
+	}
+	return inserted, nil
+}
+
+// insertSignalsRequestedSetsReturning inserts the rows in one statement and returns the rows which were inserted
+func (pdb *db) insertSignalsRequestedSetsReturning(ctx context.Context, dbShardID int, rows []sqlplugin.SignalsRequestedSetsRow) ([]sqlplugin.SignalsRequestedSetsRow, error) {
+	query, args, err := pdb.bindNamed(dbShardID, pdb.opts.queries.createSignalsRequestedSetReturningQuery, rows)
+	if err != nil {
+		return nil, err
+	}
+	var insertedRows []sqlplugin.SignalsRequestedSetsRow
+	if err := pdb.mapDriver().SelectContext(ctx, dbShardID, &insertedRows, query, args...); err != nil {
+		return nil, err
+	}
+	return insertedRows, nil
+}
+
+// lockSignalsRequestedSetQuery takes a transaction level advisory lock on the signals requested set of an execution,
+// it is released when the transaction commits or rolls back
+const lockSignalsRequestedSetQuery = `SELECT pg_advisory_xact_lock(hashtext($1))`
+
+// MergeSignalsRequestedSet removes remove from and then adds add to the signals requested set of an execution.
+// Both are applied in one transaction which holds an advisory lock on the set, so concurrent merges of the same set
+// run one after another and the final set does not depend on how they interleave. Inserts and deletes which do not
+// go through MergeSignalsRequestedSet do not take the lock. Within a transaction the merge runs in that transaction.
+func (pdb *db) MergeSignalsRequestedSet(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter, add []string, remove []string) (err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "MergeSignalsRequestedSet", signalsRequestedSetsTableName)
+	defer func() { span.finish(len(add)+len(remove), err) }()
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return err
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	merge := func(tx *db) error {
+		lockKey := fmt.Sprintf("%v/%v/%v/%v/%v", signalsRequestedSetsTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+		if _, err := tx.driver.ExecContext(ctx, dbShardID, lockSignalsRequestedSetQuery, lockKey); err != nil {
+			return err
+		}
+		if len(remove) > 0 {
+			if _, err := tx.DeleteFromSignalsRequestedSets(ctx, &sqlplugin.SignalsRequestedSetsFilter{
+				ShardID:    filter.ShardID,
+				DomainID:   filter.DomainID,
+				WorkflowID: filter.WorkflowID,
+				RunID:      filter.RunID,
+				SignalIDs:  remove,
+			}); err != nil {
+				return err
+			}
+		}
+		if len(add) > 0 {
+			rows := make([]sqlplugin.SignalsRequestedSetsRow, len(add))
+			for i, signalID := range add {
+				rows[i] = sqlplugin.SignalsRequestedSetsRow{
+					ShardID:    filter.ShardID,
+					DomainID:   filter.DomainID,
+					WorkflowID: filter.WorkflowID,
+					RunID:      filter.RunID,
+					SignalID:   signalID,
+				}
+			}
+			if _, err := tx.InsertIntoSignalsRequestedSets(ctx, rows); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if pdb.isTx {
+		return merge(pdb)
+	}
+	return pdb.txExecute(ctx, dbShardID, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.LockedUpdate, merge)
 }
 
 // SelectFromSignalsRequestedSets reads one or more rows from signals_requested_sets table
-func (pdb *db) SelectFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) ([]sqlplugin.SignalsRequestedSetsRow, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
-	var rows []sqlplugin.SignalsRequestedSetsRow
-	err := pdb.driver.SelectContext(ctx, dbShardID, &rows, getSignalsRequestedSetQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+func (pdb *db) SelectFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) (result []sqlplugin.SignalsRequestedSetsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectFromSignalsRequestedSets", signalsRequestedSetsTableName)
+	defer func() { span.finish(len(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	rows := []sqlplugin.SignalsRequestedSetsRow{}
+	err = pdb.selectMapRows(ctx, int(filter.ShardID), dbShardID, &rows, pdb.opts.queries.getSignalsRequestedSetQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectFromSignalsRequestedSets", Err: err}
+	}
 	for i := 0; i < len(rows); i++ {
 		rows[i].ShardID = int64(filter.ShardID)
 		rows[i].DomainID = filter.DomainID
 		rows[i].WorkflowID = filter.WorkflowID
 		rows[i].RunID = filter.RunID
 	}
-	return rows, err
+	return rows, nil
 }
 
 // DeleteFromSignalsRequestedSets deletes one or more rows from signals_requested_sets table
-func (pdb *db) DeleteFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) (sql.Result, error) {
-	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(int(filter.ShardID), pdb.GetTotalNumDBShards())
+func (pdb *db) DeleteFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) (result sql.Result, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "DeleteFromSignalsRequestedSets", signalsRequestedSetsTableName)
+	defer func() { span.finish(rowsAffected(result), err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return filter.DomainID }); err != nil {
+		return nil, err
+	}
+	if err := pdb.auditMapDelete(ctx, signalsRequestedSetsTableName, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.SignalIDs); err != nil {
+		return nil, err
+	}
 	if len(filter.SignalIDs) > 0 {
-		query, args, err := sqlx.In(deleteSignalsRequestedSetQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.SignalIDs)
+		query, args, err := sqlx.In(pdb.opts.queries.deleteSignalsRequestedSetQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, filter.SignalIDs)
 		if err != nil {
 			return nil, err
 		}
-		return pdb.driver.ExecContext(ctx, dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+		return pdb.execMapDelete(ctx, int(filter.ShardID), dbShardID, sqlx.Rebind(sqlx.BindType(PluginName), query), args...)
+	}
+	return pdb.execMapDelete(ctx, int(filter.ShardID), dbShardID, pdb.opts.queries.deleteAllSignalsRequestedSetQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+}
+
+// SelectSignalsRequestedSetsForExecutions reads the signals_requested_sets rows of many executions of a shard
+// with a single query. All keys must belong to shardID. The returned slice has the same length as keys,
+// entry i holds the rows of keys[i].
+func (pdb *db) SelectSignalsRequestedSetsForExecutions(ctx context.Context, shardID int, keys []sqlplugin.ExecutionsFilter) (result [][]sqlplugin.SignalsRequestedSetsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectSignalsRequestedSetsForExecutions", signalsRequestedSetsTableName)
+	defer func() {
+		count := 0
+		for _, rows := range result {
+			count += len(rows)
+		}
+		span.finish(count, err)
+	}()
+	for i, key := range keys {
+		if key.ShardID != shardID {
+			return nil, fmt.Errorf("key %v for signals_requested_sets has shard ID %v but the batch is for shard ID %v", i, key.ShardID, shardID)
+		}
+	}
+	result = make([][]sqlplugin.SignalsRequestedSetsRow, len(keys))
+	for i := range result {
+		result[i] = []sqlplugin.SignalsRequestedSetsRow{}
+	}
+	if len(keys) == 0 {
+		return result, nil
+	}
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	span.setDBShardID(dbShardID)
+
+	indexes := make(map[string][]int, len(keys))
+	tuples := make([]string, len(keys))
+	args := make([]interface{}, 0, 1+3*len(keys))
+	args = append(args, shardID)
+	for i, key := range keys {
+		k := executionKey(key.DomainID, key.WorkflowID, key.RunID)
+		indexes[k] = append(indexes[k], i)
+		tuples[i] = fmt.Sprintf("($%v, $%v, $%v)", len(args)+1, len(args)+2, len(args)+3)
+		args = append(args, key.DomainID, key.WorkflowID, key.RunID)
+	}
+	query := fmt.Sprintf(pdb.opts.queries.getSignalsRequestedSetsForExecutionsQry, strings.Join(tuples, ", "))
+	rows := []sqlplugin.SignalsRequestedSetsRow{}
+	err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "SelectSignalsRequestedSetsForExecutions", Err: err}
+	}
+	for _, row := range rows {
+		row.ShardID = int64(shardID)
+		for _, i := range indexes[executionKey(row.DomainID, row.WorkflowID, row.RunID)] {
+			result[i] = append(result[i], row)
+		}
+	}
+	return result, nil
+}
+
+// SelectAllMapsForExecution reads the six map tables of an execution concurrently through their Select methods,
+// so the rows go through the activity info maps cache and the AfterScan hooks like any other read
+func (pdb *db) SelectAllMapsForExecution(ctx context.Context, filter *sqlplugin.ExecutionsFilter) (*sqlplugin.MutableStateMaps, error) {
+	shardID := int64(filter.ShardID)
+	var (
+		activities       []sqlplugin.ActivityInfoMapsRow
+		timers           []sqlplugin.TimerInfoMapsRow
+		childExecutions  []sqlplugin.ChildExecutionInfoMapsRow
+		requestCancels   []sqlplugin.RequestCancelInfoMapsRow
+		signals          []sqlplugin.SignalInfoMapsRow
+		signalsRequested []sqlplugin.SignalsRequestedSetsRow
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		activities, err = pdb.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{
+			ShardID: shardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID,
+		})
+		return err
+	})
+	g.Go(func() (err error) {
+		timers, err = pdb.SelectFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{
+			ShardID: shardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID,
+		})
+		return err
+	})
+	g.Go(func() (err error) {
+		childExecutions, err = pdb.SelectFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+			ShardID: shardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID,
+		})
+		return err
+	})
+	g.Go(func() (err error) {
+		requestCancels, err = pdb.SelectFromRequestCancelInfoMaps(ctx, &sqlplugin.RequestCancelInfoMapsFilter{
+			ShardID: shardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID,
+		})
+		return err
+	})
+	g.Go(func() (err error) {
+		signals, err = pdb.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+			ShardID: shardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID,
+		})
+		return err
+	})
+	g.Go(func() (err error) {
+		signalsRequested, err = pdb.SelectFromSignalsRequestedSets(ctx, &sqlplugin.SignalsRequestedSetsFilter{
+			ShardID: shardID, DomainID: filter.DomainID, WorkflowID: filter.WorkflowID, RunID: filter.RunID,
+		})
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return newMutableStateMaps(activities, timers, childExecutions, requestCancels, signals, signalsRequested), nil
+}
+
+func newMutableStateMaps(
+	activities []sqlplugin.ActivityInfoMapsRow,
+	timers []sqlplugin.TimerInfoMapsRow,
+	childExecutions []sqlplugin.ChildExecutionInfoMapsRow,
+	requestCancels []sqlplugin.RequestCancelInfoMapsRow,
+	signals []sqlplugin.SignalInfoMapsRow,
+	signalsRequested []sqlplugin.SignalsRequestedSetsRow,
+) *sqlplugin.MutableStateMaps {
+	maps := &sqlplugin.MutableStateMaps{
+		Activities:       make(map[int64]sqlplugin.ActivityInfoMapsRow, len(activities)),
+		Timers:           make(map[string]sqlplugin.TimerInfoMapsRow, len(timers)),
+		ChildExecutions:  make(map[int64]sqlplugin.ChildExecutionInfoMapsRow, len(childExecutions)),
+		RequestCancels:   make(map[int64]sqlplugin.RequestCancelInfoMapsRow, len(requestCancels)),
+		Signals:          make(map[int64]sqlplugin.SignalInfoMapsRow, len(signals)),
+		SignalsRequested: make(map[string]sqlplugin.SignalsRequestedSetsRow, len(signalsRequested)),
+	}
+	for _, row := range activities {
+		maps.Activities[row.ScheduleID] = row
+	}
+	for _, row := range timers {
+		maps.Timers[row.TimerID] = row
+	}
+	for _, row := range childExecutions {
+		maps.ChildExecutions[row.InitiatedID] = row
+	}
+	for _, row := range requestCancels {
+		maps.RequestCancels[row.InitiatedID] = row
+	}
+	for _, row := range signals {
+		maps.Signals[row.InitiatedID] = row
+	}
+	for _, row := range signalsRequested {
+		maps.SignalsRequested[row.SignalID] = row
+	}
+	return maps
+}
+
+// executionKey identifies an execution within a shard, domain and run IDs have a fixed length
+func executionKey(domainID serialization.UUID, workflowID string, runID serialization.UUID) string {
+	return string(domainID) + string(runID) + workflowID
+}
+
+// DeleteMapsForExecutions deletes every row of activity_info_maps, timer_info_maps, child_execution_info_maps,
+// request_cancel_info_maps, signal_info_maps and signals_requested_sets for each of the given executions.
+// It is meant for offline cleanup tools: deletes are fanned out to at most concurrency workers and throttled
+// to qps executions per second (qps <= 0 disables throttling). Keys are grouped by dbShardID so that
+// consecutive deletes reuse the same connection pool.
+// The returned slice has the same length as keys, a nil entry means all maps of that execution were deleted.
+func (pdb *db) DeleteMapsForExecutions(ctx context.Context, keys []sqlplugin.ExecutionsFilter, concurrency int, qps int) []error {
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return errs
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var limiter *quotas.RateLimiter
+	if qps > 0 {
+		limiter = quotas.NewSimpleRateLimiter(qps)
+	}
+
+	work := make(chan int, len(keys))
+	for _, indexes := range groupKeysByDBShard(keys, pdb.GetTotalNumDBShards()) {
+		for _, i := range indexes {
+			work <- i
+		}
+	}
+	close(work)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if limiter != nil {
+					if err := limiter.Wait(ctx); err != nil {
+						errs[i] = err
+						continue
+					}
+				}
+				errs[i] = pdb.deleteMapsForExecution(ctx, keys[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// deleteMapsForExecution deletes the rows of key from every execution map table. The tables are deleted from one
+// after the other, or mapDeletionConcurrency at a time outside of a transaction. Each concurrent delete commits on its
+// own connection, so a failed delete leaves the rows of the other tables deleted, the deletes are idempotent and the
+// caller retries the whole execution. The first error is returned.
+func (pdb *db) deleteMapsForExecution(ctx context.Context, key sqlplugin.ExecutionsFilter) error {
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return key.DomainID }); err != nil {
+		return err
+	}
+	defer pdb.activityInfoMapsWritten(int64(key.ShardID), key.DomainID, key.WorkflowID, key.RunID)
+	dbShardID := pdb.mapDBShardID(ctx, key.ShardID)
+	tables := []executionMapTableDelete{
+		{activityInfoTableName, pdb.opts.queries.deleteActivityInfoMapQry},
+		{timerInfoTableName, pdb.opts.queries.deleteTimerInfoMapSQLQuery},
+		{childExecutionInfoTableName, pdb.opts.queries.deleteChildExecutionInfoMapQry},
+		{requestCancelInfoTableName, pdb.opts.queries.deleteRequestCancelInfoMapQry},
+		{signalInfoTableName, pdb.opts.queries.deleteSignalInfoMapQry},
+		{signalsRequestedSetsTableName, pdb.opts.queries.deleteAllSignalsRequestedSetQuery},
+	}
+	concurrency := pdb.opts.mapDeletionConcurrency
+	// the statements of a transaction run on its single connection
+	if pdb.isTx || concurrency <= 1 {
+		for _, table := range tables {
+			if err := pdb.deleteExecutionMapTable(ctx, key, dbShardID, table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, len(tables))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(len(tables))
+	for i := range tables {
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = pdb.deleteExecutionMapTable(ctx, key, dbShardID, tables[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executionMapTableDelete is the delete query of all the rows of an execution in an execution map table
+type executionMapTableDelete struct {
+	name  string
+	query string
+}
+
+func (pdb *db) deleteExecutionMapTable(ctx context.Context, key sqlplugin.ExecutionsFilter, dbShardID int, table executionMapTableDelete) error {
+	if err := pdb.auditMapDelete(ctx, table.name, int64(key.ShardID), key.DomainID, key.WorkflowID, key.RunID, nil); err != nil {
+		return err
+	}
+	var err error
+	if _, counted := mapKeyColumns[table.name]; counted {
+		_, err = pdb.execCountedMapDelete(ctx, int64(key.ShardID), key.DomainID, key.WorkflowID, key.RunID, dbShardID, table.query, key.ShardID, key.DomainID, key.WorkflowID, key.RunID)
+	} else {
+		_, err = pdb.execMapDelete(ctx, key.ShardID, dbShardID, table.query, key.ShardID, key.DomainID, key.WorkflowID, key.RunID)
+	}
+	return err
+}
+
+// groupKeysByDBShard returns the indexes of keys grouped by the dbShardID the key is routed to
+func groupKeysByDBShard(keys []sqlplugin.ExecutionsFilter, numDBShards int) map[int][]int {
+	groups := make(map[int][]int)
+	for i, key := range keys {
+		dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(key.ShardID, numDBShards)
+		groups[dbShardID] = append(groups[dbShardID], i)
+	}
+	return groups
+}
+
+const (
+	// %[1]v to %[5]v are the names of activity_info_maps, timer_info_maps, child_execution_info_maps,
+	// request_cancel_info_maps and signal_info_maps
+	getLargestExecutionMapsQueryTemplate = `SELECT domain_id, workflow_id, run_id, SUM(row_count) AS row_count, SUM(data_size) AS data_size FROM (
+SELECT domain_id, workflow_id, run_id, COUNT(*) AS row_count,
+SUM(octet_length(data) + COALESCE(octet_length(last_heartbeat_details), 0)) AS data_size
+FROM %[1]v WHERE shard_id = $1 GROUP BY domain_id, workflow_id, run_id
+UNION ALL
+SELECT domain_id, workflow_id, run_id, COUNT(*), SUM(octet_length(data)) FROM %[2]v WHERE shard_id = $1 GROUP BY domain_id, workflow_id, run_id
+UNION ALL
+SELECT domain_id, workflow_id, run_id, COUNT(*), SUM(octet_length(data)) FROM %[3]v WHERE shard_id = $1 GROUP BY domain_id, workflow_id, run_id
+UNION ALL
+SELECT domain_id, workflow_id, run_id, COUNT(*), SUM(octet_length(data)) FROM %[4]v WHERE shard_id = $1 GROUP BY domain_id, workflow_id, run_id
+UNION ALL
+SELECT domain_id, workflow_id, run_id, COUNT(*), SUM(octet_length(data)) FROM %[5]v WHERE shard_id = $1 GROUP BY domain_id, workflow_id, run_id
+) AS sizes
+GROUP BY domain_id, workflow_id, run_id
+ORDER BY data_size DESC, domain_id, workflow_id, run_id
+LIMIT $2`
+)
+
+var _ sqlplugin.ExecutionMapsSizeReader = (*db)(nil)
+
+// SelectLargestExecutionMaps returns the limit executions of a shard which use the most bytes across
+// the execution map tables, largest first
+func (pdb *db) SelectLargestExecutionMaps(ctx context.Context, shardID int, limit int) ([]sqlplugin.ExecutionMapsSizeRow, error) {
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	var rows []sqlplugin.ExecutionMapsSizeRow
+	if err := pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, pdb.opts.queries.getLargestExecutionMapsQuery, shardID, limit); err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].ShardID = int64(shardID)
+	}
+	return rows, nil
+}
+
+const (
+	listExecutionsInMapFirstPageQryTemplate = `SELECT DISTINCT domain_id, workflow_id, run_id
+FROM %v
+WHERE
+shard_id = $1
+ORDER BY domain_id, workflow_id, run_id
+LIMIT $2`
+
+	listExecutionsInMapNextPageQryTemplate = `SELECT DISTINCT domain_id, workflow_id, run_id
+FROM %v
+WHERE
+shard_id = $1 AND
+(domain_id, workflow_id, run_id) > ($2, $3, $4)
+ORDER BY domain_id, workflow_id, run_id
+LIMIT $5`
+
+	// %%v is the list of (domain_id, workflow_id, run_id) tuples, filled in per query
+	getExecutionsInMapQryTemplate = `SELECT DISTINCT domain_id, workflow_id, run_id
+FROM %[1]v
+WHERE
+shard_id = $1 AND
+(domain_id, workflow_id, run_id) IN (%%v)`
+)
+
+// listExecutionsInMapQueries are the queries which page through the distinct executions of a shard in a map table
+type listExecutionsInMapQueries struct {
+	firstPage string
+	nextPage  string
+}
+
+func makeListExecutionsInMapQueries(table string) listExecutionsInMapQueries {
+	return listExecutionsInMapQueries{
+		firstPage: fmt.Sprintf(listExecutionsInMapFirstPageQryTemplate, table),
+		nextPage:  fmt.Sprintf(listExecutionsInMapNextPageQryTemplate, table),
+	}
+}
+
+type executionKeyCursor struct {
+	DomainID   serialization.UUID
+	WorkflowID string
+	RunID      serialization.UUID
+}
+
+func (c *executionKeyCursor) serialize() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func (c *executionKeyCursor) deserialize(payload []byte) error {
+	return json.Unmarshal(payload, c)
+}
+
+// ListExecutionsInActivityInfoMaps pages through the distinct executions of a shard in activity_info_maps
+func (pdb *db) ListExecutionsInActivityInfoMaps(ctx context.Context, shardID int, cursor []byte, pageSize int) ([]sqlplugin.ExecutionKeyRow, []byte, error) {
+	return pdb.listExecutionsInMap(ctx, pdb.opts.queries.listExecutionsInActivityInfoMapQrys, shardID, cursor, pageSize)
+}
+
+// ListExecutionsInTimerInfoMaps pages through the distinct executions of a shard in timer_info_maps
+func (pdb *db) ListExecutionsInTimerInfoMaps(ctx context.Context, shardID int, cursor []byte, pageSize int) ([]sqlplugin.ExecutionKeyRow, []byte, error) {
+	return pdb.listExecutionsInMap(ctx, pdb.opts.queries.listExecutionsInTimerInfoMapQrys, shardID, cursor, pageSize)
+}
+
+// ListExecutionsInChildExecutionInfoMaps pages through the distinct executions of a shard in child_execution_info_maps
+func (pdb *db) ListExecutionsInChildExecutionInfoMaps(ctx context.Context, shardID int, cursor []byte, pageSize int) ([]sqlplugin.ExecutionKeyRow, []byte, error) {
+	return pdb.listExecutionsInMap(ctx, pdb.opts.queries.listExecutionsInChildExecutionInfoMapQrys, shardID, cursor, pageSize)
+}
+
+// ListExecutionsInRequestCancelInfoMaps pages through the distinct executions of a shard in request_cancel_info_maps
+func (pdb *db) ListExecutionsInRequestCancelInfoMaps(ctx context.Context, shardID int, cursor []byte, pageSize int) ([]sqlplugin.ExecutionKeyRow, []byte, error) {
+	return pdb.listExecutionsInMap(ctx, pdb.opts.queries.listExecutionsInRequestCancelInfoMapQrys, shardID, cursor, pageSize)
+}
+
+// ListExecutionsInSignalInfoMaps pages through the distinct executions of a shard in signal_info_maps
+func (pdb *db) ListExecutionsInSignalInfoMaps(ctx context.Context, shardID int, cursor []byte, pageSize int) ([]sqlplugin.ExecutionKeyRow, []byte, error) {
+	return pdb.listExecutionsInMap(ctx, pdb.opts.queries.listExecutionsInSignalInfoMapQrys, shardID, cursor, pageSize)
+}
+
+// listExecutionsInMap resumes right after the execution in the cursor like SelectActivityInfoMapsShardCursor,
+// so executions inserted concurrently never shift or repeat a page
+func (pdb *db) listExecutionsInMap(
+	ctx context.Context,
+	queries listExecutionsInMapQueries,
+	shardID int,
+	cursor []byte,
+	pageSize int,
+) ([]sqlplugin.ExecutionKeyRow, []byte, error) {
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	var rows []sqlplugin.ExecutionKeyRow
+	var err error
+	if len(cursor) == 0 {
+		err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, queries.firstPage, shardID, pageSize)
+	} else {
+		var last executionKeyCursor
+		if err := last.deserialize(cursor); err != nil {
+			return nil, nil, err
+		}
+		err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, queries.nextPage,
+			shardID, last.DomainID, last.WorkflowID, last.RunID, pageSize)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range rows {
+		rows[i].ShardID = int64(shardID)
+	}
+	if len(rows) == 0 || len(rows) < pageSize {
+		return rows, nil, nil
+	}
+	lastRow := rows[len(rows)-1]
+	next := &executionKeyCursor{
+		DomainID:   lastRow.DomainID,
+		WorkflowID: lastRow.WorkflowID,
+		RunID:      lastRow.RunID,
+	}
+	nextCursor, err := next.serialize()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, nextCursor, nil
+}
+
+// ExecutionsWithActivityInfoMaps checks all keys with a single query, the rows are never read
+func (pdb *db) ExecutionsWithActivityInfoMaps(ctx context.Context, shardID int, keys []sqlplugin.ExecutionKeyRow) (result []sqlplugin.ExecutionKeyRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "ExecutionsWithActivityInfoMaps", activityInfoTableName)
+	defer func() { span.finish(len(result), err) }()
+	for i, key := range keys {
+		if key.ShardID != int64(shardID) {
+			return nil, fmt.Errorf("key %v for activity_info_maps has shard ID %v but the batch is for shard ID %v", i, key.ShardID, shardID)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	span.setDBShardID(dbShardID)
+
+	tuples := make([]string, len(keys))
+	args := make([]interface{}, 0, 1+3*len(keys))
+	args = append(args, shardID)
+	for i, key := range keys {
+		tuples[i] = fmt.Sprintf("($%v, $%v, $%v)", len(args)+1, len(args)+2, len(args)+3)
+		args = append(args, key.DomainID, key.WorkflowID, key.RunID)
+	}
+	query := fmt.Sprintf(pdb.opts.queries.getExecutionsInActivityInfoMapQry, strings.Join(tuples, ", "))
+	var rows []sqlplugin.ExecutionKeyRow
+	err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &sqlplugin.PersistenceError{Operation: "ExecutionsWithActivityInfoMaps", Err: err}
+	}
+	existing := make(map[string]struct{}, len(rows))
+	for _, row := range rows {
+		existing[executionKey(row.DomainID, row.WorkflowID, row.RunID)] = struct{}{}
+	}
+	for _, key := range keys {
+		if _, ok := existing[executionKey(key.DomainID, key.WorkflowID, key.RunID)]; ok {
+			result = append(result, key)
+		}
 	}
-	return pdb.driver.ExecContext(ctx, dbShardID, deleteAllSignalsRequestedSetQuery, filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID)
+	return result, nil
 }
@@ -0,0 +1,306 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/config"
+	pt "github.com/uber/cadence/common/persistence/persistence-tests"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/testflags"
+)
+
+// newMapsTestDB returns a db on a fresh database of a real Postgres with the cadence schema applied, so the execution map
+// methods can be exercised end to end instead of against a mocked driver. Postgres is started with docker/dev/postgres.yml
+// and the tests using it only run with POSTGRES=1. configure, if not nil, adjusts the config the db is created with.
+// The database is dropped once the test completes
+func newMapsTestDB(t *testing.T, configure func(cfg *config.SQL)) *db {
+	testflags.RequirePostgres(t)
+	testBase := pt.NewTestBaseWithSQL(GetTestClusterOption())
+	testBase.Setup()
+	t.Cleanup(testBase.TearDownWorkflowStore)
+	persistenceCfg := testBase.Config()
+	cfg := *persistenceCfg.DataStores[persistenceCfg.DefaultStore].SQL
+	if configure != nil {
+		configure(&cfg)
+	}
+	sqlDB, err := (&plugin{}).CreateDB(&cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB.(*db)
+}
+
+// mapsTestExecution returns the key of a new execution of shard 1
+func mapsTestExecution() (shardID int64, domainID serialization.UUID, workflowID string, runID serialization.UUID) {
+	return 1, serialization.MustParseUUID(uuid.New()), "maps-test-" + uuid.New(), serialization.MustParseUUID(uuid.New())
+}
+
+func TestPostgresSQLActivityInfoMapsRoundTrip(t *testing.T) {
+	pdb := newMapsTestDB(t, nil)
+	ctx := context.Background()
+	shardID, domainID, workflowID, runID := mapsTestExecution()
+	// a zone other than UTC checks that the heartbeat time is converted on the way in and out
+	heartbeat := time.Date(2020, 5, 17, 10, 30, 0, 123456000, time.FixedZone("UTC+5", 5*60*60))
+	row := func(scheduleID int64, data string) sqlplugin.ActivityInfoMapsRow {
+		return sqlplugin.ActivityInfoMapsRow{
+			ShardID:                  shardID,
+			DomainID:                 domainID,
+			WorkflowID:               workflowID,
+			RunID:                    runID,
+			ScheduleID:               scheduleID,
+			Data:                     []byte(data),
+			DataEncoding:             "thriftrw",
+			LastHeartbeatDetails:     []byte("details-" + data),
+			LastHeartbeatUpdatedTime: heartbeat,
+		}
+	}
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID}
+
+	_, err := pdb.ReplaceIntoActivityInfoMaps(ctx, []sqlplugin.ActivityInfoMapsRow{row(1, "a"), row(2, "b"), row(3, "c")})
+	require.NoError(t, err)
+	// replacing existing rows goes through ON CONFLICT DO UPDATE
+	_, err = pdb.ReplaceIntoActivityInfoMaps(ctx, []sqlplugin.ActivityInfoMapsRow{row(2, "b2"), row(4, "d")})
+	require.NoError(t, err)
+
+	rows, err := pdb.SelectFromActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	data := make(map[int64]string)
+	for _, r := range rows {
+		data[r.ScheduleID] = string(r.Data)
+		assert.Equal(t, shardID, r.ShardID)
+		assert.Equal(t, domainID, r.DomainID)
+		assert.Equal(t, "details-"+string(r.Data), string(r.LastHeartbeatDetails))
+		assert.True(t, heartbeat.Equal(r.LastHeartbeatUpdatedTime), "heartbeat %v read back as %v", heartbeat, r.LastHeartbeatUpdatedTime)
+	}
+	assert.Equal(t, map[int64]string{1: "a", 2: "b2", 3: "c", 4: "d"}, data)
+
+	single, found, err := pdb.GetActivityInfoMapRow(ctx, filter, 2)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "b2", string(single.Data))
+	assert.True(t, heartbeat.Equal(single.LastHeartbeatUpdatedTime))
+	_, found, err = pdb.GetActivityInfoMapRow(ctx, filter, 5)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// deleting some keys expands the IN list with sqlx.In
+	keysFilter := *filter
+	keysFilter.ScheduleIDs = []int64{1, 3}
+	result, err := pdb.DeleteFromActivityInfoMaps(ctx, &keysFilter)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rowsAffected(result))
+	// the rows can only be selected for update within a transaction
+	tx, err := pdb.BeginTx(ctx, sqlplugin.GetDBShardIDFromHistoryShardID(int(shardID), pdb.GetTotalNumDBShards()))
+	require.NoError(t, err)
+	forUpdate, err := tx.(*db).SelectFromActivityInfoMapsForUpdate(ctx, &sqlplugin.ActivityInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID, ScheduleIDs: []int64{1, 2, 3, 4},
+	})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	require.Len(t, forUpdate, 2)
+	assert.Equal(t, int64(2), forUpdate[0].ScheduleID)
+	assert.Equal(t, int64(4), forUpdate[1].ScheduleID)
+
+	result, err = pdb.DeleteFromActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rowsAffected(result))
+	rows, err = pdb.SelectFromActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestPostgresSQLSwapActivityInfoMapRow(t *testing.T) {
+	pdb := newMapsTestDB(t, nil)
+	ctx := context.Background()
+	shardID, domainID, workflowID, runID := mapsTestExecution()
+	heartbeat := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	row := func(data string) *sqlplugin.ActivityInfoMapsRow {
+		return &sqlplugin.ActivityInfoMapsRow{
+			ShardID:                  shardID,
+			DomainID:                 domainID,
+			WorkflowID:               workflowID,
+			RunID:                    runID,
+			ScheduleID:               1,
+			Data:                     []byte(data),
+			DataEncoding:             "thriftrw",
+			LastHeartbeatUpdatedTime: heartbeat,
+		}
+	}
+
+	previous, err := pdb.SwapActivityInfoMapRow(ctx, row("a"))
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+
+	previous, err = pdb.SwapActivityInfoMapRow(ctx, row("b"))
+	require.NoError(t, err)
+	require.NotNil(t, previous)
+	assert.Equal(t, "a", string(previous.Data))
+	assert.Equal(t, int64(1), previous.ScheduleID)
+	assert.True(t, heartbeat.Equal(previous.LastHeartbeatUpdatedTime), "heartbeat %v read back as %v", heartbeat, previous.LastHeartbeatUpdatedTime)
+
+	rows, err := pdb.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "b", string(rows[0].Data))
+}
+
+func TestPostgresSQLTimerInfoMapsRoundTrip(t *testing.T) {
+	pdb := newMapsTestDB(t, nil)
+	ctx := context.Background()
+	shardID, domainID, workflowID, runID := mapsTestExecution()
+	row := func(timerID string, data string) sqlplugin.TimerInfoMapsRow {
+		return sqlplugin.TimerInfoMapsRow{
+			ShardID:      shardID,
+			DomainID:     domainID,
+			WorkflowID:   workflowID,
+			RunID:        runID,
+			TimerID:      timerID,
+			Data:         []byte(data),
+			DataEncoding: "thriftrw",
+		}
+	}
+	filter := &sqlplugin.TimerInfoMapsFilter{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID}
+
+	_, err := pdb.ReplaceIntoTimerInfoMaps(ctx, []sqlplugin.TimerInfoMapsRow{row("t1", "a"), row("t2", "b")})
+	require.NoError(t, err)
+	_, err = pdb.ReplaceIntoTimerInfoMaps(ctx, []sqlplugin.TimerInfoMapsRow{row("t1", "a2")})
+	require.NoError(t, err)
+
+	rows, err := pdb.SelectTimerInfoByTimerIDs(ctx, filter, []string{"t1", "missing"})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "t1", rows[0].TimerID)
+	assert.Equal(t, "a2", string(rows[0].Data))
+
+	keysFilter := *filter
+	keysFilter.TimerIDs = []string{"t2"}
+	_, err = pdb.DeleteFromTimerInfoMaps(ctx, &keysFilter)
+	require.NoError(t, err)
+	rows, err = pdb.SelectFromTimerInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "t1", rows[0].TimerID)
+}
+
+func TestPostgresSQLMapChangeEvents(t *testing.T) {
+	pdb := newMapsTestDB(t, nil)
+	sink := &recordingMapChangeSink{}
+	pdb.SetMapChangeSink(sink)
+	ctx := context.Background()
+	shardID, domainID, workflowID, runID := mapsTestExecution()
+	row := func(timerID string) sqlplugin.TimerInfoMapsRow {
+		return sqlplugin.TimerInfoMapsRow{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID, TimerID: timerID, Data: []byte("data"), DataEncoding: "thriftrw"}
+	}
+
+	_, err := pdb.ReplaceIntoTimerInfoMaps(ctx, []sqlplugin.TimerInfoMapsRow{row("t1")})
+	require.NoError(t, err)
+	_, err = pdb.ReplaceIntoTimerInfoMaps(ctx, []sqlplugin.TimerInfoMapsRow{row("t1"), row("t2")})
+	require.NoError(t, err)
+
+	types := make(map[string][]MapChangeType)
+	for _, event := range sink.events {
+		assert.Equal(t, timerInfoTableName, event.Table)
+		assert.Equal(t, domainID, event.DomainID)
+		assert.Equal(t, runID, event.RunID)
+		types[event.Key] = append(types[event.Key], event.Type)
+	}
+	assert.Equal(t, map[string][]MapChangeType{
+		"t1": {MapChangeInsert, MapChangeUpdate},
+		"t2": {MapChangeInsert},
+	}, types)
+}
+
+func TestPostgresSQLSignalsRequestedSetsRoundTrip(t *testing.T) {
+	pdb := newMapsTestDB(t, nil)
+	ctx := context.Background()
+	shardID, domainID, workflowID, runID := mapsTestExecution()
+	row := func(signalID string) sqlplugin.SignalsRequestedSetsRow {
+		return sqlplugin.SignalsRequestedSetsRow{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID, SignalID: signalID}
+	}
+	filter := &sqlplugin.SignalsRequestedSetsFilter{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID}
+
+	// inserting a signal ID which is already in the set is a no-op through ON CONFLICT DO NOTHING
+	_, err := pdb.InsertIntoSignalsRequestedSets(ctx, []sqlplugin.SignalsRequestedSetsRow{row("s1"), row("s2")})
+	require.NoError(t, err)
+	_, err = pdb.InsertIntoSignalsRequestedSets(ctx, []sqlplugin.SignalsRequestedSetsRow{row("s2"), row("s3")})
+	require.NoError(t, err)
+
+	keysFilter := *filter
+	keysFilter.SignalIDs = []string{"s1", "s3"}
+	_, err = pdb.DeleteFromSignalsRequestedSets(ctx, &keysFilter)
+	require.NoError(t, err)
+	rows, err := pdb.SelectFromSignalsRequestedSets(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "s2", rows[0].SignalID)
+}
+
+func TestPostgresSQLDeferredActivityInfoMapsDeletion(t *testing.T) {
+	pdb := newMapsTestDB(t, func(cfg *config.SQL) { cfg.DeferredMapDeletion = true })
+	ctx := context.Background()
+	shardID, domainID, workflowID, runID := mapsTestExecution()
+	row := func(scheduleID int64) sqlplugin.ActivityInfoMapsRow {
+		return sqlplugin.ActivityInfoMapsRow{
+			ShardID:                  shardID,
+			DomainID:                 domainID,
+			WorkflowID:               workflowID,
+			RunID:                    runID,
+			ScheduleID:               scheduleID,
+			Data:                     []byte("data"),
+			DataEncoding:             "thriftrw",
+			LastHeartbeatUpdatedTime: time.Now(),
+		}
+	}
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID}
+
+	_, err := pdb.ReplaceIntoActivityInfoMaps(ctx, []sqlplugin.ActivityInfoMapsRow{row(1), row(2), row(3)})
+	require.NoError(t, err)
+	result, err := pdb.MarkForDeletionActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rowsAffected(result))
+	rows, err := pdb.SelectFromActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	// replacing a flagged row brings it back
+	_, err = pdb.ReplaceIntoActivityInfoMaps(ctx, []sqlplugin.ActivityInfoMapsRow{row(2)})
+	require.NoError(t, err)
+	rows, err = pdb.SelectFromActivityInfoMaps(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, int64(2), rows[0].ScheduleID)
+
+	swept, err := pdb.SweepDeletedActivityInfoMaps(ctx, int(shardID), 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), swept)
+	swept, err = pdb.SweepDeletedActivityInfoMaps(ctx, int(shardID), 1, 0)
+	require.NoError(t, err)
+	assert.Zero(t, swept)
+}
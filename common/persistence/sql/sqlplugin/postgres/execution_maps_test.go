@@ -0,0 +1,710 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestCoalesceHeartbeats(t *testing.T) {
+	now := time.Now()
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	row := func(scheduleID int64, hbTime time.Time, details string) sqlplugin.ActivityInfoMapsRow {
+		return sqlplugin.ActivityInfoMapsRow{
+			ShardID:                  1,
+			DomainID:                 domainID,
+			WorkflowID:               "wid",
+			RunID:                    runID,
+			ScheduleID:               scheduleID,
+			LastHeartbeatDetails:     []byte(details),
+			LastHeartbeatUpdatedTime: hbTime,
+		}
+	}
+
+	rows := []sqlplugin.ActivityInfoMapsRow{
+		row(5, now, "a"),
+		row(6, now, "b"),
+		row(5, now.Add(time.Second), "c"),
+		row(5, now.Add(-time.Second), "stale"),
+		row(6, now.Add(2*time.Second), "d"),
+	}
+	result := coalesceHeartbeats(rows)
+	assert.Len(t, result, 2)
+	assert.Equal(t, int64(5), result[0].ScheduleID)
+	assert.Equal(t, []byte("c"), result[0].LastHeartbeatDetails)
+	assert.Equal(t, int64(6), result[1].ScheduleID)
+	assert.Equal(t, []byte("d"), result[1].LastHeartbeatDetails)
+
+	assert.Empty(t, coalesceHeartbeats(nil))
+}
+
+func TestGroupKeysByDBShard(t *testing.T) {
+	keys := []sqlplugin.ExecutionsFilter{
+		{ShardID: 0, WorkflowID: "a"},
+		{ShardID: 1, WorkflowID: "b"},
+		{ShardID: 2, WorkflowID: "c"},
+		{ShardID: 3, WorkflowID: "d"},
+		{ShardID: 4, WorkflowID: "e"},
+	}
+	assert.Equal(t, map[int][]int{
+		0: {0, 2, 4},
+		1: {1, 3},
+	}, groupKeysByDBShard(keys, 2))
+	assert.Equal(t, map[int][]int{0: {0, 1, 2, 3, 4}}, groupKeysByDBShard(keys, 1))
+	assert.Empty(t, groupKeysByDBShard(nil, 2))
+}
+
+func TestErrorCheckersSeeThroughPersistenceError(t *testing.T) {
+	pdb := &db{}
+	wrap := func(err error) error {
+		return &sqlplugin.PersistenceError{Operation: "SelectFromActivityInfoMaps", Err: err}
+	}
+	assert.True(t, pdb.IsTimeoutError(wrap(context.DeadlineExceeded)))
+	assert.True(t, pdb.IsTimeoutError(wrap(&pq.Error{Code: ErrQueryCanceled})))
+	assert.True(t, pdb.IsThrottlingError(wrap(&pq.Error{Code: ErrTooManyConnections})))
+	assert.True(t, pdb.IsSerializationFailureError(wrap(&pq.Error{Code: ErrSerializationFailure})))
+	assert.False(t, pdb.IsNotFoundError(wrap(errors.New("connection reset"))))
+	assert.Equal(t, "SelectFromActivityInfoMaps failed: connection reset", wrap(errors.New("connection reset")).Error())
+}
+
+func TestReplaceRejectsMixedShards(t *testing.T) {
+	pdb := &db{numDBShards: 1}
+	_, err := pdb.ReplaceIntoActivityInfoMaps(context.Background(), []sqlplugin.ActivityInfoMapsRow{{ShardID: 1}, {ShardID: 1}, {ShardID: 2}})
+	assert.EqualError(t, err, "batch for activity_info_maps mixes shards: row 2 has shard ID 2 but row 0 has shard ID 1")
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), []sqlplugin.TimerInfoMapsRow{{ShardID: 3}, {ShardID: 4}})
+	assert.EqualError(t, err, "batch for timer_info_maps mixes shards: row 1 has shard ID 4 but row 0 has shard ID 3")
+	_, err = pdb.InsertSignalsRequestedSetsReporting(context.Background(), []sqlplugin.SignalsRequestedSetsRow{{ShardID: 3}, {ShardID: 4}})
+	assert.Error(t, err)
+	assert.NoError(t, checkRowsShardID("signal_info_maps", 3, func(i int) int64 { return 5 }))
+}
+
+func TestReplaceDedupsRowsByKey(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.namedResult()).Times(2)
+	logger := &log.MockLogger{}
+	logger.On("Debug", "Execution map query fingerprints", mock.Anything).Times(len(pdb.opts.queries.fingerprints))
+	pdb.SetLogger(logger)
+
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}, {ShardID: 1, TimerID: "b"}}
+	_, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{rows}, sent.lastArgs())
+
+	logger.On("Warn", "Removed execution map rows with duplicated keys from a batch", mock.Anything).Once()
+	rows = []sqlplugin.TimerInfoMapsRow{
+		{ShardID: 1, TimerID: "a", Data: []byte("first")},
+		{ShardID: 1, TimerID: "b"},
+		{ShardID: 1, TimerID: "a", Data: []byte("last")},
+	}
+	result, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, []interface{}{[]sqlplugin.TimerInfoMapsRow{
+		{ShardID: 1, TimerID: "b"},
+		{ShardID: 1, TimerID: "a", Data: []byte("last")},
+	}}, sent.lastArgs())
+	logger.AssertExpectations(t)
+}
+
+func TestSelectSignalsRequestedSetsForExecutions(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]sqlplugin.SignalsRequestedSetsRow{
+		{DomainID: domainID, WorkflowID: "b", RunID: runID, SignalID: "s1"},
+		{DomainID: domainID, WorkflowID: "a", RunID: runID, SignalID: "s2"},
+		{DomainID: domainID, WorkflowID: "b", RunID: runID, SignalID: "s3"},
+	}))
+	keys := []sqlplugin.ExecutionsFilter{
+		{ShardID: 3, DomainID: domainID, WorkflowID: "a", RunID: runID},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "b", RunID: runID},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "c", RunID: runID},
+	}
+
+	result, err := pdb.SelectSignalsRequestedSetsForExecutions(context.Background(), 3, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT domain_id, workflow_id, run_id, signal_id FROM signals_requested_sets WHERE
+shard_id = $1 AND
+(domain_id, workflow_id, run_id) IN (($2, $3, $4), ($5, $6, $7), ($8, $9, $10))`, sent.query())
+	assert.Len(t, sent.lastArgs(), 10)
+	assert.Equal(t, [][]sqlplugin.SignalsRequestedSetsRow{
+		{{ShardID: 3, DomainID: domainID, WorkflowID: "a", RunID: runID, SignalID: "s2"}},
+		{
+			{ShardID: 3, DomainID: domainID, WorkflowID: "b", RunID: runID, SignalID: "s1"},
+			{ShardID: 3, DomainID: domainID, WorkflowID: "b", RunID: runID, SignalID: "s3"},
+		},
+		{},
+	}, result)
+
+	keys[2].ShardID = 4
+	_, err = pdb.SelectSignalsRequestedSetsForExecutions(context.Background(), 3, keys)
+	assert.EqualError(t, err, "key 2 for signals_requested_sets has shard ID 4 but the batch is for shard ID 3")
+}
+
+func TestInsertIntoSignalsRequestedSetsStrictMode(t *testing.T) {
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, driver := newMockDB(t)
+	pdb.originalDBs = []*sqlx.DB{xdb}
+	pdb.opts.signalsStrictMode = true
+	rows := []sqlplugin.SignalsRequestedSetsRow{
+		{ShardID: 3, WorkflowID: "a", SignalID: "s1"},
+		{ShardID: 3, WorkflowID: "a", SignalID: "s2"},
+		{ShardID: 3, WorkflowID: "b", SignalID: "s1"},
+	}
+	sent := &sentStatements{}
+
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(rows))
+	result, err := pdb.InsertIntoSignalsRequestedSets(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rowsAffected(result))
+	assert.Contains(t, sent.query(), "RETURNING shard_id, domain_id, workflow_id, run_id, signal_id")
+
+	// s1 of workflow b was already requested, s1 of workflow a does not hide it
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(rows[:2]))
+	_, err = pdb.InsertIntoSignalsRequestedSets(context.Background(), rows)
+	var badRequest *types.BadRequestError
+	require.ErrorAs(t, err, &badRequest)
+	assert.Contains(t, badRequest.Message, "signal IDs [s1]")
+
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(rows[1:]))
+	_, err = pdb.InsertIntoSignalsRequestedSets(context.Background(), rows)
+	require.ErrorAs(t, err, &badRequest)
+	assert.Contains(t, badRequest.Message, "signal IDs [s1]")
+}
+
+func TestInsertSignalsRequestedSetsReporting(t *testing.T) {
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, driver := newMockDB(t)
+	pdb.originalDBs = []*sqlx.DB{xdb}
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runA := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	runB := serialization.MustParseUUID("6c0d2e4f-1a3b-4c5d-8e9f-0a1b2c3d4e5f")
+	rows := []sqlplugin.SignalsRequestedSetsRow{
+		{ShardID: 3, DomainID: domainID, WorkflowID: "a", RunID: runA, SignalID: "s1"},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "a", RunID: runB, SignalID: "s1"},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "b", RunID: runA, SignalID: "s1"},
+	}
+	sent := &sentStatements{}
+
+	// only the signal of run B was new, the same signal ID of the other executions must not be reported
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(rows[1:2]))
+	inserted, err := pdb.InsertSignalsRequestedSetsReporting(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Equal(t, map[sqlplugin.SignalsRequestedSetKey]struct{}{rows[1].Key(): {}}, inserted)
+	_, ok := inserted[rows[0].Key()]
+	assert.False(t, ok)
+
+	// bindNamed writes a single row positionally when configured, like the other map writes
+	pdb.opts.singleRowBindMode = singleRowBindModePositional
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]sqlplugin.SignalsRequestedSetsRow(nil)))
+	inserted, err = pdb.InsertSignalsRequestedSetsReporting(context.Background(), rows[:1])
+	require.NoError(t, err)
+	assert.Empty(t, inserted)
+	assert.Contains(t, sent.query(), "($1, $2, $3, $4, $5)")
+}
+
+func TestMakeSetKeyInMapQry(t *testing.T) {
+	assert.Equal(t, `INSERT INTO timer_info_maps
+(shard_id, domain_id, workflow_id, run_id, timer_id, data,data_encoding)
+VALUES
+(:shard_id, :domain_id, :workflow_id, :run_id, :timer_id, :data,:data_encoding)
+ON CONFLICT (shard_id, domain_id, workflow_id, run_id, timer_id) DO UPDATE
+	SET (shard_id, domain_id, workflow_id, run_id, timer_id, data,data_encoding)
+  	  = (excluded.shard_id, excluded.domain_id, excluded.workflow_id, excluded.run_id, excluded.timer_id, excluded.data,excluded.data_encoding)`,
+		makeSetKeyInMapQry("timer_info_maps", []string{"data", "data_encoding"}, []string{"timer_id"}))
+
+	assert.Equal(t, `INSERT INTO composite_maps
+(shard_id, domain_id, workflow_id, run_id, a,b, data)
+VALUES
+(:shard_id, :domain_id, :workflow_id, :run_id, :a,:b, :data)
+ON CONFLICT (shard_id, domain_id, workflow_id, run_id, a,b) DO UPDATE
+	SET (shard_id, domain_id, workflow_id, run_id, a,b, data)
+  	  = (excluded.shard_id, excluded.domain_id, excluded.workflow_id, excluded.run_id, excluded.a,excluded.b, excluded.data)`,
+		makeSetKeyInMapQry("composite_maps", []string{"data"}, []string{"a", "b"}))
+}
+
+func TestMakeMapQueriesValidateColumns(t *testing.T) {
+	assert.PanicsWithValue(t, "postgres: no value columns for the queries of timer_info_maps", func() {
+		makeSetKeyInMapQry("timer_info_maps", nil, []string{"timer_id"})
+	})
+	assert.PanicsWithValue(t, "postgres: map key column timer_id of timer_info_maps is listed as a value column", func() {
+		makeGetMapQryTemplate("timer_info_maps", []string{"timer_id", "data"}, "timer_id")
+	})
+	assert.PanicsWithValue(t, "postgres: value column data of timer_info_maps is listed twice", func() {
+		makeGetKeysInMapQry("timer_info_maps", []string{"data", "data"}, "timer_id")
+	})
+	assert.PanicsWithValue(t, "postgres: empty map key column for the queries of timer_info_maps", func() {
+		makeGetMapPageQry("timer_info_maps", []string{"data"}, "")
+	})
+	assert.NotPanics(t, func() { newExecutionMapQueries("") })
+}
+
+func TestSelectFromActivityInfoMapsForUpdate(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID}
+
+	// nothing is read outside of a transaction
+	_, err := pdb.SelectFromActivityInfoMapsForUpdate(context.Background(), filter)
+	assert.Equal(t, errSelectForUpdateOutsideTx, err)
+
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(sent.rows([]sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}})).Times(2)
+	pdb.isTx = true
+	rows, err := pdb.SelectFromActivityInfoMapsForUpdate(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, []sqlplugin.ActivityInfoMapsRow{{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 5}}, rows)
+	assert.True(t, strings.HasSuffix(sent.query(), "run_id = $4\nORDER BY schedule_id\nFOR UPDATE"), sent.query())
+	assert.Len(t, sent.lastArgs(), 4)
+
+	filter.ScheduleIDs = []int64{5, 6}
+	_, err = pdb.SelectFromActivityInfoMapsForUpdate(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(sent.query(), "schedule_id IN ( $5, $6 )\nORDER BY schedule_id\nFOR UPDATE"), sent.query())
+	assert.Len(t, sent.lastArgs(), 6)
+}
+
+func TestSelectActivityInfoMetadata(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(sent.rows([]sqlplugin.ActivityInfoMetadataRow{{ScheduleID: 5, LastHeartbeatDetails: []byte("details")}}))
+
+	rows, err := pdb.SelectActivityInfoMetadata(context.Background(), &sqlplugin.ActivityInfoMapsFilter{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID})
+	require.NoError(t, err)
+	assert.Equal(t, []sqlplugin.ActivityInfoMetadataRow{{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 5, LastHeartbeatDetails: []byte("details")}}, rows)
+	assert.True(t, strings.HasPrefix(sent.query(), "SELECT schedule_id, last_heartbeat_details,last_heartbeat_updated_time FROM activity_info_maps"), sent.query())
+	assert.NotContains(t, sent.query(), "data")
+}
+
+func TestSelectTimerInfoByTimerIDs(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]sqlplugin.TimerInfoMapsRow{{TimerID: "a"}}))
+	filter := &sqlplugin.TimerInfoMapsFilter{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID}
+
+	rows, err := pdb.SelectTimerInfoByTimerIDs(context.Background(), filter, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []sqlplugin.TimerInfoMapsRow{{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "a"}}, rows)
+	assert.True(t, strings.HasSuffix(sent.query(), "run_id = $4 AND\ntimer_id IN ( $5, $6 )"), sent.query())
+	assert.Len(t, sent.lastArgs(), 6)
+
+	// no timers are read without a query
+	rows, err = pdb.SelectTimerInfoByTimerIDs(context.Background(), filter, nil)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	assert.NotNil(t, rows)
+}
+
+func TestReplaceIntoTimerInfoMapsWithReferenceTime(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	now := time.Now()
+	row := func(timerID string, fireTime time.Time) sqlplugin.TimerInfoMapsRow {
+		blob, err := parser.TimerInfoToBlob(&serialization.TimerInfo{ExpiryTimestamp: fireTime})
+		require.NoError(t, err)
+		return sqlplugin.TimerInfoMapsRow{ShardID: 1, WorkflowID: "wid", TimerID: timerID, Data: blob.Data, DataEncoding: string(blob.Encoding)}
+	}
+	fresh := row("fresh", now.Add(-time.Minute))
+	stale := row("stale", now.Add(-2*time.Hour))
+	undecodable := sqlplugin.TimerInfoMapsRow{ShardID: 1, TimerID: "undecodable", Data: []byte("corrupted"), DataEncoding: string(common.EncodingTypeThriftRW)}
+
+	scope := tally.NewTestScope("", nil)
+	pdb := &db{opts: dbOptions{timerParser: parser, timerMaxStaleness: time.Hour, logger: log.NewNoop()}}
+	pdb.SetMetricsClient(metrics.NewClient(scope, metrics.History))
+	assert.NoError(t, pdb.validateTimerFireTimes([]sqlplugin.TimerInfoMapsRow{fresh, stale, undecodable}, now))
+	var counted int64
+	for _, c := range scope.Snapshot().Counters() {
+		if c.Name() == "persistence_stale_timer_info_maps_rows" {
+			counted += c.Value()
+		}
+	}
+	assert.Equal(t, int64(2), counted)
+
+	// in strict mode the write is rejected before anything is written
+	pdb.opts.timerStrictMode = true
+	assert.NoError(t, pdb.validateTimerFireTimes([]sqlplugin.TimerInfoMapsRow{fresh}, now))
+	_, err = pdb.ReplaceIntoTimerInfoMapsWithReferenceTime(context.Background(), []sqlplugin.TimerInfoMapsRow{fresh, stale}, now)
+	var badRequest *types.BadRequestError
+	require.ErrorAs(t, err, &badRequest)
+	assert.Contains(t, badRequest.Message, "timer stale of workflow wid")
+}
+
+func TestCheckMapRowsLimit(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	rows := []sqlplugin.TimerInfoMapsRow{
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "a"},
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "b"},
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "a"},
+	}
+	check := func() error {
+		return pdb.checkMapRowsLimit(context.Background(), 0, timerInfoTableName, pdb.opts.queries.countOtherKeysInTimerInfoMapQry, 1, len(rows), func(i int) mapRowKey {
+			return mapRowKey{domainID: rows[i].DomainID, workflowID: rows[i].WorkflowID, runID: rows[i].RunID, key: rows[i].TimerID}
+		})
+	}
+
+	// no limit configured
+	assert.NoError(t, check())
+
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(2)).Times(2)
+	pdb.opts.maxExecutionMapRows = 4
+	assert.NoError(t, check())
+	assert.True(t, strings.HasSuffix(sent.query(), "timer_id NOT IN ( $5, $6 )"), sent.query())
+
+	pdb.opts.maxExecutionMapRows = 3
+	_, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	var limitErr *types.LimitExceededError
+	require.True(t, errors.As(err, &limitErr), "unexpected error %v", err)
+	assert.Equal(t, "writing 2 rows into timer_info_maps would leave workflow wid run "+runID.String()+" with 4 rows, the limit is 3", limitErr.Message)
+
+	// a batch larger than the limit is rejected without counting
+	pdb.opts.maxExecutionMapRows = 1
+	assert.Error(t, check())
+}
+
+func TestListExecutionsInActivityInfoMaps(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	page := []sqlplugin.ExecutionKeyRow{
+		{DomainID: domainID, WorkflowID: "wid-1", RunID: runID},
+		{DomainID: domainID, WorkflowID: "wid-2", RunID: runID},
+	}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(page))
+
+	rows, cursor, err := pdb.ListExecutionsInActivityInfoMaps(context.Background(), 3, nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []sqlplugin.ExecutionKeyRow{
+		{ShardID: 3, DomainID: domainID, WorkflowID: "wid-1", RunID: runID},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "wid-2", RunID: runID},
+	}, rows)
+	assert.True(t, strings.HasPrefix(sent.query(), "SELECT DISTINCT domain_id, workflow_id, run_id\nFROM activity_info_maps"), sent.query())
+	assert.Equal(t, []interface{}{3, 2}, sent.lastArgs())
+	require.NotNil(t, cursor)
+
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows(page[:1]))
+	rows, cursor, err = pdb.ListExecutionsInActivityInfoMaps(context.Background(), 3, cursor, 2)
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Nil(t, cursor)
+	assert.Equal(t, []interface{}{3, domainID, "wid-2", runID, 2}, sent.lastArgs())
+}
+
+func TestExecutionsWithActivityInfoMaps(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]sqlplugin.ExecutionKeyRow{
+		{DomainID: domainID, WorkflowID: "c", RunID: runID},
+		{DomainID: domainID, WorkflowID: "a", RunID: runID},
+	}))
+	keys := []sqlplugin.ExecutionKeyRow{
+		{ShardID: 3, DomainID: domainID, WorkflowID: "a", RunID: runID},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "b", RunID: runID},
+		{ShardID: 3, DomainID: domainID, WorkflowID: "c", RunID: runID},
+	}
+
+	result, err := pdb.ExecutionsWithActivityInfoMaps(context.Background(), 3, keys)
+	require.NoError(t, err)
+	assert.Equal(t, []sqlplugin.ExecutionKeyRow{keys[0], keys[2]}, result)
+	assert.Equal(t, `SELECT DISTINCT domain_id, workflow_id, run_id
+FROM activity_info_maps
+WHERE
+shard_id = $1 AND
+(domain_id, workflow_id, run_id) IN (($2, $3, $4), ($5, $6, $7), ($8, $9, $10))`, sent.query())
+	assert.Len(t, sent.lastArgs(), 10)
+
+	keys[1].ShardID = 4
+	_, err = pdb.ExecutionsWithActivityInfoMaps(context.Background(), 3, keys)
+	assert.EqualError(t, err, "key 1 for activity_info_maps has shard ID 4 but the batch is for shard ID 3")
+}
+
+func TestNewMutableStateMaps(t *testing.T) {
+	maps := newMutableStateMaps(
+		[]sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}, {ScheduleID: 7}},
+		[]sqlplugin.TimerInfoMapsRow{{TimerID: "t"}},
+		[]sqlplugin.ChildExecutionInfoMapsRow{{InitiatedID: 3}},
+		nil,
+		[]sqlplugin.SignalInfoMapsRow{{InitiatedID: 9}},
+		[]sqlplugin.SignalsRequestedSetsRow{{SignalID: "s1"}, {SignalID: "s2"}},
+	)
+	assert.Equal(t, map[int64]sqlplugin.ActivityInfoMapsRow{5: {ScheduleID: 5}, 7: {ScheduleID: 7}}, maps.Activities)
+	assert.Equal(t, map[string]sqlplugin.TimerInfoMapsRow{"t": {TimerID: "t"}}, maps.Timers)
+	assert.Equal(t, map[int64]sqlplugin.ChildExecutionInfoMapsRow{3: {InitiatedID: 3}}, maps.ChildExecutions)
+	assert.NotNil(t, maps.RequestCancels)
+	assert.Empty(t, maps.RequestCancels)
+	assert.Equal(t, map[int64]sqlplugin.SignalInfoMapsRow{9: {InitiatedID: 9}}, maps.Signals)
+	assert.Equal(t, map[string]sqlplugin.SignalsRequestedSetsRow{"s1": {SignalID: "s1"}, "s2": {SignalID: "s2"}}, maps.SignalsRequested)
+}
+
+func TestQueryFingerprints(t *testing.T) {
+	queries := newExecutionMapQueries("")
+	assert.Equal(t, []string{queryFingerprint(queries.getTimerInfoMapSQLQuery)}, queries.fingerprints["SelectFromTimerInfoMaps"])
+	assert.Len(t, queries.fingerprints["DeleteFromActivityInfoMaps"], 2)
+	// the fingerprint is the md5 of the query text, which postgres can compute from pg_stat_statements
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", queryFingerprint(""))
+
+	prefixed := newExecutionMapQueries("prefix_")
+	assert.NotEqual(t, queries.fingerprints["SelectFromTimerInfoMaps"], prefixed.fingerprints["SelectFromTimerInfoMaps"])
+	for operation, fingerprints := range queries.fingerprints {
+		for _, fingerprint := range fingerprints {
+			assert.Len(t, fingerprint, 32, operation)
+		}
+	}
+}
+
+func TestDeleteFromActivityInfoMapsIfClosed(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: 1, WorkflowID: "wid"}
+	newTxDB := func() (*db, *sqldriver.MockDriver) {
+		pdb, driver := newMockDB(t)
+		pdb.isTx = true
+		pdb.opts.executionParser = parser
+		return pdb, driver
+	}
+	// executionState answers the read of the executions row with state
+	executionState := func(state int32) func(context.Context, int, interface{}, string, ...interface{}) error {
+		return func(_ context.Context, _ int, dest interface{}, _ string, _ ...interface{}) error {
+			blob, err := parser.WorkflowExecutionInfoToBlob(&serialization.WorkflowExecutionInfo{State: state})
+			if err != nil {
+				return err
+			}
+			reflect.ValueOf(dest).Elem().FieldByName("Data").SetBytes(blob.Data)
+			reflect.ValueOf(dest).Elem().FieldByName("DataEncoding").SetString(string(blob.Encoding))
+			return nil
+		}
+	}
+
+	// an open execution is refused and nothing is deleted
+	pdb, driver := newTxDB()
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(executionState(persistence.WorkflowStateRunning))
+	_, err = pdb.DeleteFromActivityInfoMapsIfClosed(context.Background(), filter)
+	var notClosed *ExecutionNotClosedError
+	require.ErrorAs(t, err, &notClosed)
+	assert.Equal(t, persistence.WorkflowStateRunning, notClosed.State)
+	assert.Equal(t, "wid", notClosed.WorkflowID)
+
+	// a closed execution is deleted like DeleteFromActivityInfoMaps does
+	pdb, driver = newTxDB()
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(executionState(persistence.WorkflowStateCompleted))
+	driver.EXPECT().ExecContext(gomock.Any(), 0, newExecutionMapQueries("").deleteActivityInfoMapQry, gomock.Any()).Return(batchResult(2), nil)
+	result, err := pdb.DeleteFromActivityInfoMapsIfClosed(context.Background(), filter)
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// nothing is deleted when the state of the execution is unknown
+	pdb, driver = newTxDB()
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).Return(sql.ErrNoRows)
+	_, err = pdb.DeleteFromActivityInfoMapsIfClosed(context.Background(), filter)
+	var notFound *ExecutionNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "wid", notFound.WorkflowID)
+}
+
+func TestDeleteFromActivityInfoMapsIfClosedDuringDBShardMigration(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	blob, err := parser.WorkflowExecutionInfoToBlob(&serialization.WorkflowExecutionInfo{State: persistence.WorkflowStateCompleted})
+	require.NoError(t, err)
+	// closedExecution answers the read of the executions row with a closed execution
+	closedExecution := func(string) ([]string, []driver.Value) {
+		return []string{"data", "data_encoding"}, []driver.Value{blob.Data, string(blob.Encoding)}
+	}
+	noExecution := func(string) ([]string, []driver.Value) {
+		return []string{"data", "data_encoding"}, nil
+	}
+	// history shard 3 is routed to DB shard 1 of 2, it was routed to the only DB shard before
+	newMigratingDB := func(previous, current *stagingConnector) *db {
+		var xdbs []*sqlx.DB
+		for _, connector := range []*stagingConnector{previous, current} {
+			xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+			xdb.MapperFunc(strcase.ToSnake)
+			xdbs = append(xdbs, xdb)
+		}
+		pdb, err := newDB(xdbs, nil, sqlplugin.DbShardUndefined, 2, dbOptions{queries: newExecutionMapQueries(""), executionParser: parser, previousNumDBShards: 1})
+		require.NoError(t, err)
+		return pdb
+	}
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: 3, WorkflowID: "wid"}
+
+	// the execution is only found on the previous DB shard, the rows are deleted from both
+	previous, current := &stagingConnector{row: closedExecution}, &stagingConnector{row: noExecution}
+	result, err := newMigratingDB(previous, current).DeleteFromActivityInfoMapsIfClosed(context.Background(), filter)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rowsAffected(result))
+	assert.Equal(t, 1, previous.written)
+	assert.Equal(t, 1, current.written)
+
+	// nothing is deleted when the execution is found on neither DB shard
+	previous, current = &stagingConnector{row: noExecution}, &stagingConnector{row: noExecution}
+	_, err = newMigratingDB(previous, current).DeleteFromActivityInfoMapsIfClosed(context.Background(), filter)
+	var notFound *ExecutionNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Zero(t, previous.written)
+	assert.Zero(t, current.written)
+}
+
+func TestGetActivityInfoMapRow(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	pdb, driver := newMockDB(t)
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(sent.rows([]sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5, Data: []byte("data")}}))
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID}
+
+	row, found, err := pdb.GetActivityInfoMapRow(context.Background(), filter, 5)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, sqlplugin.ActivityInfoMapsRow{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 5, Data: []byte("data")}, row)
+	assert.True(t, strings.HasSuffix(sent.query(), "run_id = $4 AND\nschedule_id = $5"), sent.query())
+	assert.Equal(t, []interface{}{int64(3), domainID, "wid", runID, int64(5)}, sent.lastArgs())
+
+	// a missing row is not an error
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]sqlplugin.ActivityInfoMapsRow(nil)))
+	row, found, err = pdb.GetActivityInfoMapRow(context.Background(), filter, 6)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, sqlplugin.ActivityInfoMapsRow{}, row)
+}
+
+// deletesInFlight is an ExecContext action which holds each delete until release is closed and records the most
+// deletes in flight, the delete of failing fails
+type deletesInFlight struct {
+	sentStatements
+	inFlight int
+	maxIn    int
+	failing  string
+	release  chan struct{}
+}
+
+func (d *deletesInFlight) exec(ctx context.Context, dbShardID int, query string, args ...interface{}) (sql.Result, error) {
+	d.record(query, args)
+	d.mu.Lock()
+	d.inFlight++
+	if d.inFlight > d.maxIn {
+		d.maxIn = d.inFlight
+	}
+	d.mu.Unlock()
+	if d.release != nil {
+		<-d.release
+	}
+	d.mu.Lock()
+	d.inFlight--
+	d.mu.Unlock()
+	if query == d.failing {
+		return nil, errors.New("delete failed")
+	}
+	return batchResult(1), nil
+}
+
+func TestDeleteMapsForExecutionConcurrency(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	key := sqlplugin.ExecutionsFilter{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID}
+
+	// the tables are deleted from one after the other by default
+	pdb, driver := newMockDB(t)
+	deletes := &deletesInFlight{}
+	driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(deletes.exec).Times(6)
+	require.NoError(t, pdb.deleteMapsForExecution(context.Background(), key))
+	assert.Equal(t, 1, deletes.maxIn)
+
+	// three tables are deleted from at a time, the deletes of the other tables still run when one fails
+	pdb, driver = newMockDB(t)
+	queries := pdb.opts.queries
+	pdb.opts.mapDeletionConcurrency = 3
+	deletes = &deletesInFlight{release: make(chan struct{}), failing: queries.deleteTimerInfoMapSQLQuery}
+	driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(deletes.exec).Times(6)
+	done := make(chan error)
+	go func() { done <- pdb.deleteMapsForExecution(context.Background(), key) }()
+	require.Eventually(t, func() bool {
+		deletes.mu.Lock()
+		defer deletes.mu.Unlock()
+		return deletes.inFlight == 3
+	}, time.Second, time.Millisecond)
+	close(deletes.release)
+	assert.EqualError(t, <-done, "delete failed")
+	assert.Equal(t, 3, deletes.maxIn)
+	assert.ElementsMatch(t, []string{
+		queries.deleteActivityInfoMapQry,
+		queries.deleteTimerInfoMapSQLQuery,
+		queries.deleteChildExecutionInfoMapQry,
+		queries.deleteRequestCancelInfoMapQry,
+		queries.deleteSignalInfoMapQry,
+		queries.deleteAllSignalsRequestedSetQuery,
+	}, deletes.queries)
+
+	// the statements of a transaction are not run concurrently
+	tx, driver := newMockDB(t)
+	tx.isTx = true
+	tx.opts.mapDeletionConcurrency = 3
+	deletes = &deletesInFlight{}
+	driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(deletes.exec).Times(6)
+	require.NoError(t, tx.deleteMapsForExecution(context.Background(), key))
+	assert.Equal(t, 1, deletes.maxIn)
+}
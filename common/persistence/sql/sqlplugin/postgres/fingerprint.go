@@ -0,0 +1,125 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+
+	"github.com/uber/cadence/common/log/tag"
+)
+
+// queryFingerprint returns the hex md5 of query. The execution map queries only take bind parameters, so
+// pg_stat_statements keeps their text verbatim and a query can be looked up with WHERE md5(query) = fingerprint.
+// Queries which expand a batch into a VALUES or IN list have one text per batch size, only the text of a
+// single row batch matches the fingerprint of the template
+func queryFingerprint(query string) string {
+	sum := md5.Sum([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// makeQueryFingerprints returns the fingerprints of the queries each execution map operation runs,
+// keyed by the operation name used by the metrics and traces of the operation
+func makeQueryFingerprints(q *executionMapQueries) map[string][]string {
+	operations := map[string][]string{
+		"ReplaceIntoActivityInfoMaps":         {q.setKeyInActivityInfoMapQry, q.countOtherKeysInActivityInfoMapQry},
+		"SelectFromActivityInfoMaps":          {q.getActivityInfoMapQry},
+		"SelectActivityInfoMetadata":          {q.getActivityInfoMetadataQry},
+		"GetActivityInfoMapRow":               {q.getActivityInfoMapRowQry},
+		"SelectFromActivityInfoMapsForUpdate": {q.getActivityInfoMapForUpdateQry, q.getKeysInActivityInfoMapForUpdateQry},
+		"SelectActivityInfoMapsShardCursor":   {q.getActivityInfoMapsShardFirstPageQry, q.getActivityInfoMapsShardNextPageQry},
+		"DeleteFromActivityInfoMaps":          {q.deleteActivityInfoMapQry, q.deleteKeyInActivityInfoMapQry},
+		"MarkForDeletionActivityInfoMaps":     {q.markDeletedActivityInfoMapQry, q.markDeletedKeysInActivityInfoMapQry},
+		"SweepDeletedActivityInfoMaps":        {q.sweepDeletedActivityInfoMapQry},
+		"SwapActivityInfoMapRow":              {q.swapActivityInfoMapQry, q.countOtherKeysInActivityInfoMapQry},
+		"SelectActivityInfoMapsChan":          {q.getActivityInfoMapPageQry},
+		"SelectAllActivityInfoMapsForShard":   {q.getActivityInfoMapsShardFirstPageQry, q.getActivityInfoMapsShardNextPageQry},
+
+		"ReplaceIntoTimerInfoMaps":  {q.setKeyInTimerInfoMapSQLQuery, q.countOtherKeysInTimerInfoMapQry},
+		"SelectFromTimerInfoMaps":   {q.getTimerInfoMapSQLQuery},
+		"SelectTimerInfoByTimerIDs": {q.getKeysInTimerInfoMapQry},
+		"DeleteFromTimerInfoMaps":   {q.deleteTimerInfoMapSQLQuery, q.deleteKeyInTimerInfoMapSQLQuery},
+
+		"ReplaceIntoChildExecutionInfoMaps": {q.setKeyInChildExecutionInfoMapQry, q.countOtherKeysInChildExecutionInfoMapQry},
+		"SelectFromChildExecutionInfoMaps":  {q.getChildExecutionInfoMapQry},
+		"DeleteFromChildExecutionInfoMaps":  {q.deleteChildExecutionInfoMapQry, q.deleteKeyInChildExecutionInfoMapQry},
+
+		"ReplaceIntoRequestCancelInfoMaps": {q.setKeyInRequestCancelInfoMapQry, q.countOtherKeysInRequestCancelInfoMapQry},
+		"SelectFromRequestCancelInfoMaps":  {q.getRequestCancelInfoMapQry},
+		"DeleteFromRequestCancelInfoMaps":  {q.deleteRequestCancelInfoMapQry, q.deleteKeyInRequestCancelInfoMapQry},
+
+		"ReplaceIntoSignalInfoMaps": {q.setKeyInSignalInfoMapQry, q.countOtherKeysInSignalInfoMapQry},
+		"SelectFromSignalInfoMaps":  {q.getSignalInfoMapQry},
+		"DeleteFromSignalInfoMaps":  {q.deleteSignalInfoMapQry, q.deleteKeyInSignalInfoMapQry},
+
+		"InsertIntoSignalsRequestedSets":          {q.createSignalsRequestedSetQuery, q.countOtherKeysInSignalsRequestedSetMapQry},
+		"InsertSignalsRequestedSetsReporting":     {q.createSignalsRequestedSetReturningQuery, q.countOtherKeysInSignalsRequestedSetMapQry},
+		"SelectFromSignalsRequestedSets":          {q.getSignalsRequestedSetQuery},
+		"SelectSignalsRequestedSetsForExecutions": {q.getSignalsRequestedSetsForExecutionsQry},
+		"DeleteFromSignalsRequestedSets":          {q.deleteAllSignalsRequestedSetQuery, q.deleteSignalsRequestedSetQuery},
+		"MergeSignalsRequestedSet":                {lockSignalsRequestedSetQuery},
+
+		"SelectLargestExecutionMaps": {q.getLargestExecutionMapsQuery},
+		"SelectMapRowCount":          {q.selectMapRowCountQry},
+
+		"ListExecutionsInActivityInfoMaps":       {q.listExecutionsInActivityInfoMapQrys.firstPage, q.listExecutionsInActivityInfoMapQrys.nextPage},
+		"ListExecutionsInTimerInfoMaps":          {q.listExecutionsInTimerInfoMapQrys.firstPage, q.listExecutionsInTimerInfoMapQrys.nextPage},
+		"ListExecutionsInChildExecutionInfoMaps": {q.listExecutionsInChildExecutionInfoMapQrys.firstPage, q.listExecutionsInChildExecutionInfoMapQrys.nextPage},
+		"ListExecutionsInRequestCancelInfoMaps":  {q.listExecutionsInRequestCancelInfoMapQrys.firstPage, q.listExecutionsInRequestCancelInfoMapQrys.nextPage},
+		"ListExecutionsInSignalInfoMaps":         {q.listExecutionsInSignalInfoMapQrys.firstPage, q.listExecutionsInSignalInfoMapQrys.nextPage},
+		"ExecutionsWithActivityInfoMaps":         {q.getExecutionsInActivityInfoMapQry},
+	}
+	for _, table := range mapRowCountTables {
+		operations["CountMapRowsByShard"] = append(operations["CountMapRowsByShard"], q.countMapRowsByShardQrys[table])
+	}
+	fingerprints := make(map[string][]string, len(operations))
+	for operation, queries := range operations {
+		for _, query := range queries {
+			// the queries of the features which are not enabled are not built
+			if query == "" {
+				continue
+			}
+			fingerprints[operation] = append(fingerprints[operation], queryFingerprint(query))
+		}
+	}
+	return fingerprints
+}
+
+// QueryFingerprints returns the fingerprints of the queries of the execution map operations keyed by operation name,
+// an operation running several queries has one fingerprint per query. The caller must not modify the result
+func (pdb *db) QueryFingerprints() map[string][]string {
+	return pdb.opts.queries.fingerprints
+}
+
+// logQueryFingerprints logs the fingerprints of every execution map operation at debug level
+func (pdb *db) logQueryFingerprints() {
+	operations := make([]string, 0, len(pdb.opts.queries.fingerprints))
+	for operation := range pdb.opts.queries.fingerprints {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		pdb.opts.logger.Debug("Execution map query fingerprints",
+			tag.OperationName(operation),
+			tag.Value(pdb.opts.queries.fingerprints[operation]))
+	}
+}
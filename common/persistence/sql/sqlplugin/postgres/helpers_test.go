@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+)
+
+// newMockDB returns a db of a single DB shard whose statements are sent to the returned mock driver, the test fails
+// on any statement which was not expected
+func newMockDB(t testing.TB) (*db, *sqldriver.MockDriver) {
+	driver := sqldriver.NewMockDriver(gomock.NewController(t))
+	return &db{driver: driver, converter: &converter{}, numDBShards: 1, opts: dbOptions{queries: newExecutionMapQueries("")}}, driver
+}
+
+// sentStatements records the statements answered by the actions it returns for a mock driver
+type sentStatements struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]interface{}
+}
+
+func (s *sentStatements) record(query string, args []interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query)
+	s.args = append(s.args, args)
+}
+
+// query returns the query of the last statement, or "" if none was sent
+func (s *sentStatements) query() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queries) == 0 {
+		return ""
+	}
+	return s.queries[len(s.queries)-1]
+}
+
+// lastArgs returns the arguments of the last statement
+func (s *sentStatements) lastArgs() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.args) == 0 {
+		return nil
+	}
+	return s.args[len(s.args)-1]
+}
+
+// rows returns a SelectContext or GetContext action which sets what dest points to to rows
+func (s *sentStatements) rows(rows interface{}) func(context.Context, int, interface{}, string, ...interface{}) error {
+	return func(_ context.Context, _ int, dest interface{}, query string, args ...interface{}) error {
+		s.record(query, args)
+		reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(rows))
+		return nil
+	}
+}
+
+// result returns an ExecContext action which affects n rows
+func (s *sentStatements) result(n int64) func(context.Context, int, string, ...interface{}) (sql.Result, error) {
+	return func(_ context.Context, _ int, query string, args ...interface{}) (sql.Result, error) {
+		s.record(query, args)
+		return batchResult(n), nil
+	}
+}
+
+// namedResult returns a NamedExecContext action which records the row argument as the only argument of the
+// statement and affects a row per row of it
+func (s *sentStatements) namedResult() func(context.Context, int, string, interface{}) (sql.Result, error) {
+	return func(_ context.Context, _ int, query string, arg interface{}) (sql.Result, error) {
+		s.record(query, []interface{}{arg})
+		if v := reflect.ValueOf(arg); v.Kind() == reflect.Slice {
+			return batchResult(v.Len()), nil
+		}
+		return batchResult(1), nil
+	}
+}
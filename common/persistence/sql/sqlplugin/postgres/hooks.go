@@ -0,0 +1,85 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// ExecutionMapHooks are called with the rows of an execution map table. rows is a slice of the row type of the
+// table, e.g. []sqlplugin.ActivityInfoMapsRow, and its elements can be modified in place. A nil hook is a no-op
+type ExecutionMapHooks struct {
+	// BeforeExec is called by the Replace method of the table with the rows which are about to be written.
+	// The rows are a copy of the rows of the caller, a hook replacing their Data does not change the rows of the caller
+	BeforeExec func(ctx context.Context, rows interface{}) error
+	// AfterScan is called by the Select methods of the table with the rows which were read, before they are returned
+	AfterScan func(ctx context.Context, rows interface{}) error
+}
+
+// hookedMapTables are the execution map tables with a data column, hooks can be registered for them
+var hookedMapTables = map[string]bool{
+	activityInfoTableName:       true,
+	timerInfoTableName:          true,
+	childExecutionInfoTableName: true,
+	requestCancelInfoTableName:  true,
+	signalInfoTableName:         true,
+}
+
+// RegisterExecutionMapHooks sets the hooks of an execution map table, transactions share the hooks of their db
+func (pdb *db) RegisterExecutionMapHooks(table string, hooks ExecutionMapHooks) error {
+	if !hookedMapTables[table] {
+		return fmt.Errorf("hooks can not be registered for table %q", table)
+	}
+	if pdb.opts.mapHooks == nil {
+		pdb.opts.mapHooks = make(map[string]ExecutionMapHooks)
+	}
+	pdb.opts.mapHooks[table] = hooks
+	return nil
+}
+
+// beforeExecMapRows calls the BeforeExec hook of table with a copy of rows and returns the copy,
+// rows is returned unchanged if no hook is registered
+func (pdb *db) beforeExecMapRows(ctx context.Context, table string, rows interface{}) (interface{}, error) {
+	hook := pdb.opts.mapHooks[table].BeforeExec
+	if hook == nil {
+		return rows, nil
+	}
+	v := reflect.ValueOf(rows)
+	copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(copied, v)
+	if err := hook(ctx, copied.Interface()); err != nil {
+		return nil, err
+	}
+	return copied.Interface(), nil
+}
+
+// afterScanMapRows calls the AfterScan hook of table with the rows read from it, once the rows which only decode with
+// the fallback encoding are relabeled
+func (pdb *db) afterScanMapRows(ctx context.Context, table string, rows interface{}) error {
+	pdb.applyDecodeFallback(table, rows)
+	hook := pdb.opts.mapHooks[table].AfterScan
+	if hook == nil {
+		return nil
+	}
+	return hook(ctx, rows)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// reverseData stands in for an encryption hook, it replaces the data of every row with its bytes reversed
+func reverseData(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return reversed
+}
+
+func TestExecutionMapHooks(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	assert.Error(t, pdb.RegisterExecutionMapHooks("signals_requested_sets", ExecutionMapHooks{}))
+	require.NoError(t, pdb.RegisterExecutionMapHooks(timerInfoTableName, ExecutionMapHooks{
+		BeforeExec: func(ctx context.Context, rows interface{}) error {
+			for i, row := range rows.([]sqlplugin.TimerInfoMapsRow) {
+				rows.([]sqlplugin.TimerInfoMapsRow)[i].Data = reverseData(row.Data)
+			}
+			return nil
+		},
+	}))
+	require.NoError(t, pdb.RegisterExecutionMapHooks(activityInfoTableName, ExecutionMapHooks{
+		AfterScan: func(ctx context.Context, rows interface{}) error {
+			for i, row := range rows.([]sqlplugin.ActivityInfoMapsRow) {
+				rows.([]sqlplugin.ActivityInfoMapsRow)[i].Data = reverseData(row.Data)
+			}
+			return nil
+		},
+	}))
+
+	// BeforeExec changes a copy of the rows, the rows of the caller are left as they were
+	sent := &sentStatements{}
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.namedResult())
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a", Data: []byte("abc")}}
+	_, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a", Data: []byte("cba")}}}, sent.lastArgs())
+	assert.Equal(t, []byte("abc"), rows[0].Data)
+
+	// AfterScan changes the rows read before they are returned
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(sent.rows([]sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5, Data: []byte("fed")}}))
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	result, err := pdb.SelectFromActivityInfoMaps(context.Background(), &sqlplugin.ActivityInfoMapsFilter{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, []byte("def"), result[0].Data)
+}
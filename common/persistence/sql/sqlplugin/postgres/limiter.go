@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+)
+
+// mapOpLimiter bounds the statements of the execution map operations in flight per dbShardID, so a hot shard
+// waits for its own statements instead of taking every connection of a pool it shares with other shards
+type mapOpLimiter struct {
+	sync.Mutex
+	maxInFlight int
+	slots       map[int]chan struct{}
+}
+
+func newMapOpLimiter(maxInFlight int) *mapOpLimiter {
+	return &mapOpLimiter{maxInFlight: maxInFlight, slots: make(map[int]chan struct{})}
+}
+
+// acquire blocks until a statement can be sent to dbShardID or ctx is done, release must be called once it completed
+func (l *mapOpLimiter) acquire(ctx context.Context, dbShardID int) (release func(), err error) {
+	l.Lock()
+	slots, ok := l.slots[dbShardID]
+	if !ok {
+		slots = make(chan struct{}, l.maxInFlight)
+		l.slots[dbShardID] = slots
+	}
+	l.Unlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// limitedDriver is a driver whose statements are bounded by a mapOpLimiter
+type limitedDriver struct {
+	sqldriver.Driver
+	limiter *mapOpLimiter
+}
+
+func (d *limitedDriver) ExecContext(ctx context.Context, dbShardID int, query string, args ...interface{}) (sql.Result, error) {
+	release, err := d.limiter.acquire(ctx, dbShardID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return d.Driver.ExecContext(ctx, dbShardID, query, args...)
+}
+
+func (d *limitedDriver) NamedExecContext(ctx context.Context, dbShardID int, query string, arg interface{}) (sql.Result, error) {
+	release, err := d.limiter.acquire(ctx, dbShardID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return d.Driver.NamedExecContext(ctx, dbShardID, query, arg)
+}
+
+func (d *limitedDriver) GetContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	release, err := d.limiter.acquire(ctx, dbShardID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return d.Driver.GetContext(ctx, dbShardID, dest, query, args...)
+}
+
+func (d *limitedDriver) SelectContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	release, err := d.limiter.acquire(ctx, dbShardID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return d.Driver.SelectContext(ctx, dbShardID, dest, query, args...)
+}
+
+// mapDriver returns the driver the execution map operations send their statements with. Within a transaction
+// the statements are not bounded, the transaction already holds its connection and waiting for a slot while
+// holding it could starve the operations holding the slots of connections. Neither do they draw from the retry
+// budget, the transaction is retried as a whole by txExecute
+func (pdb *db) mapDriver() sqldriver.Driver {
+	driver := pdb.driver
+	if pdb.opts.mapOpLimiter != nil && !pdb.isTx {
+		driver = &limitedDriver{Driver: driver, limiter: pdb.opts.mapOpLimiter}
+	}
+	if pdb.opts.logFailedMapQueries && pdb.opts.logger != nil {
+		driver = &failedQueryLoggingDriver{Driver: driver, logger: pdb.opts.logger}
+	}
+	if pdb.opts.retryBudget != nil && !pdb.isTx {
+		driver = &retryBudgetDriver{Driver: driver, pdb: pdb, budget: pdb.opts.retryBudget}
+	}
+	return driver
+}
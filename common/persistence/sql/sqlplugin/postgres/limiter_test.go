@@ -0,0 +1,73 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestMapOperationsAreBoundedPerDBShard(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 2
+	pdb.opts.mapOpLimiter = newMapOpLimiter(1)
+	// the statements are answered once unblock is closed, inFlight receives the DB shard of each statement sent
+	unblock, inFlight := make(chan struct{}), make(chan int, 10)
+	driver.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, dbShardID int, _ string, _ ...interface{}) (sql.Result, error) {
+			inFlight <- dbShardID
+			<-unblock
+			return batchResult(0), nil
+		}).Times(3)
+	deleteTimers := func(ctx context.Context, shardID int64) error {
+		_, err := pdb.DeleteFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{ShardID: shardID, WorkflowID: "wid"})
+		return err
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- deleteTimers(context.Background(), 0) }()
+	assert.Equal(t, 0, <-inFlight)
+
+	// the second statement of dbShardID 0 waits until its context is done, dbShardID 1 is not affected
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, deleteTimers(ctx, 0))
+	go func() { errs <- deleteTimers(context.Background(), 1) }()
+	assert.Equal(t, 1, <-inFlight)
+
+	close(unblock)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	assert.NoError(t, deleteTimers(context.Background(), 0))
+
+	// a transaction already holds its connection and is not bounded
+	tx := &db{driver: driver, isTx: true, numDBShards: 2, opts: pdb.opts}
+	assert.Equal(t, sqldriver.Driver(driver), tx.mapDriver())
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"github.com/uber/cadence/common/persistence/serialization"
+)
+
+// mapKeyColumns are the map key column of each execution map table written with an upsert
+var mapKeyColumns = map[string]string{
+	activityInfoTableName:       activityInfoKey,
+	timerInfoTableName:          timerInfoKey,
+	childExecutionInfoTableName: childExecutionInfoKey,
+	requestCancelInfoTableName:  requestCancelInfoKey,
+	signalInfoTableName:         signalInfoKey,
+}
+
+type (
+	// MapChangeSink receives the events of the rows written to the execution map tables
+	MapChangeSink interface {
+		// MapRowsChanged is called with the events of a write once it is durable, a write within a transaction is only
+		// reported once the transaction commits. It is called by the write itself and has to be cheap
+		MapRowsChanged(events []MapChangeEvent)
+	}
+
+	// MapChangeType is whether a write of an execution map row inserted a new row or updated an existing one
+	MapChangeType string
+
+	// MapChangeEvent describes a row written to an execution map table
+	MapChangeEvent struct {
+		Table      string
+		Type       MapChangeType
+		ShardID    int64
+		DomainID   serialization.UUID
+		WorkflowID string
+		RunID      serialization.UUID
+		// Key is the map key of the row as text, e.g. the schedule_id of an activity or the timer_id of a timer
+		Key string
+	}
+)
+
+const (
+	// MapChangeInsert is the MapChangeType of a write which inserted a new row
+	MapChangeInsert MapChangeType = "insert"
+	// MapChangeUpdate is the MapChangeType of a write which updated an existing row
+	MapChangeUpdate MapChangeType = "update"
+)
+
+// changedMapRow is a row returned by an upsert with returningChangedRow
+type changedMapRow struct {
+	Inserted   bool               `db:"inserted"`
+	ShardID    int64              `db:"shard_id"`
+	DomainID   serialization.UUID `db:"domain_id"`
+	WorkflowID string             `db:"workflow_id"`
+	RunID      serialization.UUID `db:"run_id"`
+	MapKey     string             `db:"map_key"`
+}
+
+func (r changedMapRow) toEvent(table string) MapChangeEvent {
+	changeType := MapChangeUpdate
+	if r.Inserted {
+		changeType = MapChangeInsert
+	}
+	return MapChangeEvent{
+		Table:      table,
+		Type:       changeType,
+		ShardID:    r.ShardID,
+		DomainID:   r.DomainID,
+		WorkflowID: r.WorkflowID,
+		RunID:      r.RunID,
+		Key:        r.MapKey,
+	}
+}
+
+// SetMapChangeSink enables the change events of the rows written to the execution map tables, transactions started
+// afterwards share the sink. The upserts return the key of each row then, which costs a little more than the
+// inserted flag returned for the metrics alone
+func (pdb *db) SetMapChangeSink(sink MapChangeSink) {
+	pdb.opts.mapChangeSink = sink
+}
+
+// reportMapChanges sends events to the change sink, a transaction holds them back until it commits
+func (pdb *db) reportMapChanges(events []MapChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if pdb.isTx {
+		pdb.txMapChanges = append(pdb.txMapChanges, events...)
+		return
+	}
+	pdb.opts.mapChangeSink.MapRowsChanged(events)
+}
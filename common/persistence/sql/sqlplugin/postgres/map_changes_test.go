@@ -0,0 +1,94 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// recordingMapChangeSink keeps the events it receives
+type recordingMapChangeSink struct {
+	events []MapChangeEvent
+}
+
+func (s *recordingMapChangeSink) MapRowsChanged(events []MapChangeEvent) {
+	s.events = append(s.events, events...)
+}
+
+func TestMapChangeEvents(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, driver := newMockDB(t)
+	pdb.originalDBs = []*sqlx.DB{xdb}
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]changedMapRow{
+		{Inserted: true, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, MapKey: "5"},
+		{Inserted: false, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, MapKey: "6"},
+	})).Times(3)
+	sink := &recordingMapChangeSink{}
+	pdb.SetMapChangeSink(sink)
+
+	rows := []sqlplugin.ActivityInfoMapsRow{
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 5},
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 6},
+	}
+	result, err := pdb.ReplaceIntoActivityInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.True(t, strings.HasSuffix(sent.query(), "RETURNING (xmax = 0) AS inserted, shard_id, domain_id, workflow_id, run_id, CAST(schedule_id AS text) AS map_key"), sent.query())
+	assert.Equal(t, []MapChangeEvent{
+		{Table: activityInfoTableName, Type: MapChangeInsert, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, Key: "5"},
+		{Table: activityInfoTableName, Type: MapChangeUpdate, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, Key: "6"},
+	}, sink.events)
+
+	// the events of a transaction are held back until it commits and dropped if it rolls back
+	sink.events = nil
+	tx := &db{driver: driver, originalDBs: pdb.originalDBs, converter: pdb.converter, numDBShards: 1, opts: pdb.opts, isTx: true}
+	_, err = tx.ReplaceIntoActivityInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Empty(t, sink.events)
+	driver.EXPECT().Rollback().Return(nil)
+	require.NoError(t, tx.Rollback())
+	assert.Empty(t, tx.txMapChanges)
+
+	_, err = tx.ReplaceIntoActivityInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+	assert.Empty(t, sink.events)
+	driver.EXPECT().Commit().Return(nil)
+	require.NoError(t, tx.Commit())
+	assert.Len(t, sink.events, 2)
+	assert.Empty(t, tx.txMapChanges)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	// addMapRowCountQuery adds to the map_row_count of an executions row
+	addMapRowCountQuery = `UPDATE executions SET map_row_count = map_row_count + $5
+ WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4`
+
+	// selectMapRowCountQueryTemplate reads the map_row_count of an executions row and counts the rows of the execution
+	// in the execution map tables within a single statement, so both are read from the same snapshot
+	selectMapRowCountQueryTemplate = `SELECT map_row_count,
+(SELECT COUNT(*) FROM %[1]v WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4) +
+(SELECT COUNT(*) FROM %[2]v WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4) +
+(SELECT COUNT(*) FROM %[3]v WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4) +
+(SELECT COUNT(*) FROM %[4]v WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4) +
+(SELECT COUNT(*) FROM %[5]v WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4) AS actual_row_count
+FROM executions
+WHERE shard_id = $1 AND domain_id = $2 AND workflow_id = $3 AND run_id = $4`
+)
+
+// countedMapWrite runs write, which writes the rows of the execution map tables and adds them to the map_row_count of
+// their executions, so that the rows and the count change together: in the transaction of pdb, or in a transaction
+// of its own. Without the count it is atomicBatch of the n rows of write
+func (pdb *db) countedMapWrite(ctx context.Context, dbShardID int, n int, write func(pdb *db) error) error {
+	if !pdb.opts.mapRowCounter || pdb.isTx {
+		return pdb.atomicBatch(ctx, dbShardID, n, write)
+	}
+	return pdb.txExecute(ctx, dbShardID, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.BatchWrite, write)
+}
+
+// addMapRowCount adds delta to the map_row_count of an execution. The executions row is on the dbShardID of its map
+// rows, and is not there yet when the rows are written before it, in which case the rows are not counted
+func (pdb *db) addMapRowCount(ctx context.Context, dbShardID int, shardID int64, domainID serialization.UUID, workflowID string, runID serialization.UUID, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	_, err := pdb.driver.ExecContext(ctx, dbShardID, addMapRowCountQuery, shardID, domainID, workflowID, runID, delta)
+	return err
+}
+
+// addInsertedMapRowCounts adds the rows inserted by an upsert to the map_row_count of their executions,
+// the rows replacing an existing key are not counted again
+func (pdb *db) addInsertedMapRowCounts(ctx context.Context, dbShardID int, events []MapChangeEvent) error {
+	type insertedRows struct {
+		event MapChangeEvent
+		count int64
+	}
+	var executions []*insertedRows
+	byExecution := make(map[string]*insertedRows)
+	for _, event := range events {
+		if event.Type != MapChangeInsert {
+			continue
+		}
+		id := executionKey(event.DomainID, event.WorkflowID, event.RunID)
+		e, ok := byExecution[id]
+		if !ok {
+			e = &insertedRows{event: event}
+			byExecution[id] = e
+			executions = append(executions, e)
+		}
+		e.count++
+	}
+	for _, e := range executions {
+		if err := pdb.addMapRowCount(ctx, dbShardID, e.event.ShardID, e.event.DomainID, e.event.WorkflowID, e.event.RunID, e.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execCountedMapDelete is execMapDelete for the rows of an execution of an execution map table, the deleted rows are
+// subtracted from the map_row_count of the execution along with the delete when the count is enabled
+func (pdb *db) execCountedMapDelete(
+	ctx context.Context,
+	shardID int64,
+	domainID serialization.UUID,
+	workflowID string,
+	runID serialization.UUID,
+	dbShardID int,
+	query string,
+	args ...interface{},
+) (result sql.Result, err error) {
+	if !pdb.opts.mapRowCounter {
+		return pdb.execMapDelete(ctx, int(shardID), dbShardID, query, args...)
+	}
+	err = pdb.countedMapWrite(ctx, dbShardID, 0, func(tx *db) error {
+		var err error
+		if result, err = tx.execMapDelete(ctx, int(shardID), dbShardID, query, args...); err != nil {
+			return err
+		}
+		return tx.addMapRowCount(ctx, dbShardID, shardID, domainID, workflowID, runID, -int64(rowsAffected(result)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SelectMapRowCount returns the map_row_count of the executions row of filter and the number of rows of the execution
+// in the execution map tables
+func (pdb *db) SelectMapRowCount(ctx context.Context, filter *sqlplugin.ExecutionsFilter) (*sqlplugin.MapRowCountRow, error) {
+	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(filter.ShardID, pdb.GetTotalNumDBShards())
+	var row sqlplugin.MapRowCountRow
+	if err := pdb.driver.GetContext(ctx, dbShardID, &row, pdb.opts.queries.selectMapRowCountQry,
+		filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
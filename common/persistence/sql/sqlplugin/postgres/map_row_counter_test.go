@@ -0,0 +1,87 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestMapRowCounter(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	tx, driver := newMockDB(t)
+	tx.originalDBs = []*sqlx.DB{xdb}
+	tx.isTx = true
+	tx.opts.mapRowCounter = true
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn((&sentStatements{}).rows([]changedMapRow{
+		{Inserted: true, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, MapKey: "5"},
+		{Inserted: false, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, MapKey: "6"},
+		{Inserted: true, ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, MapKey: "7"},
+	}))
+	driver.EXPECT().ExecContext(gomock.Any(), 0, addMapRowCountQuery, int64(1), domainID, "wid", runID, int64(2)).Return(batchResult(1), nil)
+
+	// only the inserted rows are added to the count, the rows replacing an existing key are already counted
+	rows := []sqlplugin.TimerInfoMapsRow{
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "5"},
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "6"},
+		{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "7"},
+	}
+	_, err := tx.ReplaceIntoTimerInfoMaps(context.Background(), rows)
+	require.NoError(t, err)
+
+	// the deleted rows are subtracted from the count
+	gomock.InOrder(
+		driver.EXPECT().ExecContext(gomock.Any(), 0, tx.opts.queries.deleteTimerInfoMapSQLQuery, gomock.Any()).Return(batchResult(3), nil),
+		driver.EXPECT().ExecContext(gomock.Any(), 0, addMapRowCountQuery, int64(1), domainID, "wid", runID, int64(-3)).Return(batchResult(1), nil),
+	)
+	result, err := tx.DeleteFromTimerInfoMaps(context.Background(), &sqlplugin.TimerInfoMapsFilter{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID})
+	require.NoError(t, err)
+	assert.Equal(t, 3, rowsAffected(result))
+
+	// nothing is deleted, the count is left alone
+	driver.EXPECT().ExecContext(gomock.Any(), 0, tx.opts.queries.deleteTimerInfoMapSQLQuery, gomock.Any()).Return(batchResult(0), nil)
+	_, err = tx.DeleteFromTimerInfoMaps(context.Background(), &sqlplugin.TimerInfoMapsFilter{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID})
+	require.NoError(t, err)
+}
+
+func TestNewDBOptions_MapRowCounter(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{NumShards: 2, MapRowCounterEnabled: true})
+	require.NoError(t, err)
+	assert.True(t, opts.mapRowCounter)
+
+	_, err = newDBOptions(&config.SQL{NumShards: 2, MapRowCounterEnabled: true, DeferredMapDeletion: true})
+	assert.Error(t, err)
+	_, err = newDBOptions(&config.SQL{NumShards: 2, MapRowCounterEnabled: true, PreviousNumShards: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/cadence/common/metrics"
+)
+
+const (
+	writeTypeInsert = "insert"
+	writeTypeUpdate = "update"
+)
+
+// ExecutionMapMetrics receives the metrics of the operations on the execution map tables
+type ExecutionMapMetrics interface {
+	// RecordLatency records how long an operation on table took, err is the error the operation failed with
+	RecordLatency(operation string, table string, latency time.Duration, err error)
+	// RecordCount counts the rows written to table by the kind of write, either insert or update, and by the cause
+	// of the write taken from the context with MapWriteCauseFromContext
+	RecordCount(table string, writeType string, cause string, count int64)
+	// RecordSize records the number of rows an operation on table read or wrote
+	RecordSize(operation string, table string, rowCount int)
+	// AddInFlight adds delta to the number of operations in flight on table, it is called with 1 when an operation
+	// starts and with -1 once it finished, so the number shows which table the concurrent operations contend on
+	AddInFlight(table string, delta int)
+	// RecordRowCount records the number of rows of shardID in table, it is called periodically when the rows of the
+	// execution map tables are counted in the background
+	RecordRowCount(table string, shardID int64, count int64)
+}
+
+// clientMapMetrics emits the metrics of the execution map tables with the metrics client of the service,
+// it is used unless other metrics are set through SetExecutionMapMetrics
+type clientMapMetrics struct {
+	client metrics.Client
+	// inFlight maps the name of a table to the *int64 number of operations in flight on it
+	inFlight sync.Map
+}
+
+var _ ExecutionMapMetrics = (*clientMapMetrics)(nil)
+
+func (m *clientMapMetrics) operationScope(operation string, table string) metrics.Scope {
+	return m.client.Scope(metrics.PersistenceExecutionMapOperationScope, metrics.TableTag(table), metrics.DBOperationTag(operation))
+}
+
+func (m *clientMapMetrics) RecordLatency(operation string, table string, latency time.Duration, err error) {
+	scope := m.operationScope(operation, table)
+	scope.RecordTimer(metrics.PersistenceExecutionMapLatency, latency)
+	if err != nil {
+		scope.IncCounter(metrics.PersistenceExecutionMapFailures)
+	}
+}
+
+func (m *clientMapMetrics) RecordCount(table string, writeType string, cause string, count int64) {
+	m.client.Scope(metrics.PersistenceExecutionMapWriteScope, metrics.TableTag(table), metrics.WriteTypeTag(writeType), metrics.WriteCauseTag(cause)).
+		AddCounter(metrics.PersistenceExecutionMapRowsWritten, count)
+}
+
+func (m *clientMapMetrics) RecordSize(operation string, table string, rowCount int) {
+	m.operationScope(operation, table).RecordTimer(metrics.PersistenceExecutionMapRowCount, time.Duration(rowCount))
+}
+
+func (m *clientMapMetrics) AddInFlight(table string, delta int) {
+	counter, _ := m.inFlight.LoadOrStore(table, new(int64))
+	inFlight := atomic.AddInt64(counter.(*int64), int64(delta))
+	m.client.Scope(metrics.PersistenceExecutionMapOperationScope, metrics.TableTag(table)).
+		UpdateGauge(metrics.PersistenceExecutionMapInFlight, float64(inFlight))
+}
+
+func (m *clientMapMetrics) RecordRowCount(table string, shardID int64, count int64) {
+	m.client.Scope(metrics.PersistenceExecutionMapOperationScope, metrics.TableTag(table), metrics.ShardIDTag(strconv.FormatInt(shardID, 10))).
+		UpdateGauge(metrics.PersistenceExecutionMapRows, float64(count))
+}
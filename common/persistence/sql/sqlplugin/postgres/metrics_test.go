@@ -0,0 +1,112 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// recordingMapMetrics records the calls of the execution map metrics as strings
+type recordingMapMetrics struct {
+	calls []string
+}
+
+func (m *recordingMapMetrics) RecordLatency(operation string, table string, latency time.Duration, err error) {
+	m.calls = append(m.calls, "latency "+operation+" "+table)
+}
+
+func (m *recordingMapMetrics) RecordCount(table string, writeType string, cause string, count int64) {
+	m.calls = append(m.calls, "count "+table+" "+writeType+" "+cause)
+}
+
+func (m *recordingMapMetrics) RecordSize(operation string, table string, rowCount int) {
+	m.calls = append(m.calls, "size "+operation+" "+table)
+}
+
+func (m *recordingMapMetrics) AddInFlight(table string, delta int) {
+	m.calls = append(m.calls, fmt.Sprintf("in flight %v %+d", table, delta))
+}
+
+func (m *recordingMapMetrics) RecordRowCount(table string, shardID int64, count int64) {
+	m.calls = append(m.calls, fmt.Sprintf("rows %v %v %v", table, shardID, count))
+}
+
+func TestSetExecutionMapMetrics(t *testing.T) {
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, driver := newMockDB(t)
+	pdb.originalDBs = []*sqlx.DB{xdb}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn((&sentStatements{}).rows([]bool{true}))
+	mapMetrics := &recordingMapMetrics{}
+	pdb.SetExecutionMapMetrics(mapMetrics)
+
+	_, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"in flight timer_info_maps +1",
+		"count timer_info_maps insert unknown",
+		"count timer_info_maps update unknown",
+		"in flight timer_info_maps -1",
+		"latency ReplaceIntoTimerInfoMaps timer_info_maps",
+		"size ReplaceIntoTimerInfoMaps timer_info_maps",
+	}, mapMetrics.calls)
+
+	// a nil metrics client disables the metrics
+	pdb.SetMetricsClient(nil)
+	assert.Nil(t, pdb.opts.mapMetrics)
+}
+
+func TestClientMapMetricsInFlight(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	mapMetrics := &clientMapMetrics{client: metrics.NewClient(scope, metrics.History)}
+	inFlight := func(table string) float64 {
+		for _, g := range scope.Snapshot().Gauges() {
+			if g.Name() == "persistence_execution_map_in_flight" && g.Tags()["table"] == table {
+				return g.Value()
+			}
+		}
+		return -1
+	}
+
+	mapMetrics.AddInFlight(activityInfoTableName, 1)
+	mapMetrics.AddInFlight(activityInfoTableName, 1)
+	mapMetrics.AddInFlight(timerInfoTableName, 1)
+	assert.Equal(t, float64(2), inFlight(activityInfoTableName))
+	assert.Equal(t, float64(1), inFlight(timerInfoTableName))
+
+	mapMetrics.AddInFlight(activityInfoTableName, -1)
+	mapMetrics.AddInFlight(activityInfoTableName, -1)
+	assert.Equal(t, float64(0), inFlight(activityInfoTableName))
+	assert.Equal(t, float64(1), inFlight(timerInfoTableName))
+}
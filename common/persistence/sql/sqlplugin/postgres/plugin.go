@@ -21,14 +21,19 @@
 package postgres
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/url"
 	"os"
+	"regexp"
 	"runtime"
 
+	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/config"
 	pt "github.com/uber/cadence/common/persistence/persistence-tests"
+	"github.com/uber/cadence/common/persistence/serialization"
 	"github.com/uber/cadence/common/persistence/sql"
 	"github.com/uber/cadence/common/persistence/sql/sqldriver"
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
@@ -54,24 +59,191 @@ func init() {
 
 // CreateDB initialize the db object
 func (d *plugin) CreateDB(cfg *config.SQL) (sqlplugin.DB, error) {
+	opts, err := newDBOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.asyncWriter, err = openAsyncMapWriter(cfg); err != nil {
+		opts.close(nil)
+		return nil, err
+	}
 	conns, err := sqldriver.CreateDBConnections(cfg, func(cfg *config.SQL) (*sqlx.DB, error) {
 		return d.createSingleDBConn(cfg)
 	})
 	if err != nil {
+		opts.close(nil)
 		return nil, err
 	}
-	return newDB(conns, nil, sqlplugin.DbShardUndefined, cfg.NumShards)
+	return newDB(conns, nil, sqlplugin.DbShardUndefined, cfg.NumShards, opts)
 }
 
-// CreateAdminDB initialize the adminDB object
+// CreateAdminDB initialize the adminDB object, it writes the rows of EnqueueReplaceActivityInfoMaps synchronously
+// and never opens the async map write queue, which is owned by the DBs of CreateDB
 func (d *plugin) CreateAdminDB(cfg *config.SQL) (sqlplugin.AdminDB, error) {
+	opts, err := newDBOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
 	conns, err := sqldriver.CreateDBConnections(cfg, func(cfg *config.SQL) (*sqlx.DB, error) {
 		return d.createSingleDBConn(cfg)
 	})
 	if err != nil {
+		opts.close(nil)
 		return nil, err
 	}
-	return newDB(conns, nil, sqlplugin.DbShardUndefined, cfg.NumShards)
+	return newDB(conns, nil, sqlplugin.DbShardUndefined, cfg.NumShards, opts)
+}
+
+var tablePrefixRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func newDBOptions(cfg *config.SQL) (dbOptions, error) {
+	var opts dbOptions
+	switch cfg.BatchInsertMode {
+	case "", batchInsertModeMultiRow:
+		opts.batchInsertMode = batchInsertModeMultiRow
+	case batchInsertModeSingleRow:
+		opts.batchInsertMode = batchInsertModeSingleRow
+	default:
+		return dbOptions{}, fmt.Errorf("unknown batchInsertMode %q, supported values are %q and %q", cfg.BatchInsertMode, batchInsertModeMultiRow, batchInsertModeSingleRow)
+	}
+	if cfg.StreamFetchSize < 0 {
+		return dbOptions{}, fmt.Errorf("invalid streamFetchSize %v, it must not be negative", cfg.StreamFetchSize)
+	}
+	opts.streamFetchSize = cfg.StreamFetchSize
+	switch cfg.SingleRowBindMode {
+	case "", singleRowBindModeNamed:
+		opts.singleRowBindMode = singleRowBindModeNamed
+	case singleRowBindModePositional:
+		opts.singleRowBindMode = singleRowBindModePositional
+	default:
+		return dbOptions{}, fmt.Errorf("unknown singleRowBindMode %q, supported values are %q and %q", cfg.SingleRowBindMode, singleRowBindModeNamed, singleRowBindModePositional)
+	}
+	level, ok := mapTransactionIsolationLevels[cfg.MapTransactionIsolation]
+	if !ok {
+		return dbOptions{}, fmt.Errorf("unknown mapTransactionIsolation %q, supported values are %q, %q and %q", cfg.MapTransactionIsolation,
+			mapTransactionIsolationReadCommitted, mapTransactionIsolationRepeatableRead, mapTransactionIsolationSerializable)
+	}
+	opts.mapTxIsolation = level
+	// the prefix is pasted into the queries, so only allow characters of an unquoted identifier
+	if cfg.TablePrefix != "" && !tablePrefixRegex.MatchString(cfg.TablePrefix) {
+		return dbOptions{}, fmt.Errorf("invalid tablePrefix %q, it must match %v", cfg.TablePrefix, tablePrefixRegex)
+	}
+	opts.queries = newExecutionMapQueries(cfg.TablePrefix)
+	if cfg.DeferredMapDeletion {
+		opts.queries.enableDeferredActivityDeletion(cfg.TablePrefix)
+		opts.deferredMapDeletion = true
+	}
+	opts.mapHooks = make(map[string]ExecutionMapHooks)
+	if cfg.ActivityInfoMapsCacheSize > 0 {
+		opts.activityInfoMapsCache = newActivityInfoMapsCache(cfg.ActivityInfoMapsCacheSize, cfg.ActivityInfoMapsCacheTTL)
+	}
+	if cfg.MaxExecutionMapRows < 0 {
+		return dbOptions{}, fmt.Errorf("invalid maxExecutionMapRows %v, it must not be negative", cfg.MaxExecutionMapRows)
+	}
+	opts.maxExecutionMapRows = cfg.MaxExecutionMapRows
+	opts.signalsStrictMode = cfg.SignalsRequestedSetsStrictMode
+	opts.dbShardOverrideEnabled = cfg.EnableDBShardOverride
+	executionParser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	if err != nil {
+		return dbOptions{}, err
+	}
+	opts.executionParser = executionParser
+	if cfg.MapDataFallbackEncoding != "" {
+		if opts.decodeFallback, err = newDecodeFallback(cfg); err != nil {
+			return dbOptions{}, err
+		}
+	}
+	if cfg.TimerInfoMapsMaxStaleness < 0 {
+		return dbOptions{}, fmt.Errorf("invalid timerInfoMapsMaxStaleness %v, it must not be negative", cfg.TimerInfoMapsMaxStaleness)
+	}
+	if cfg.TimerInfoMapsMaxStaleness > 0 {
+		parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+		if err != nil {
+			return dbOptions{}, err
+		}
+		opts.timerParser = parser
+		opts.timerMaxStaleness = cfg.TimerInfoMapsMaxStaleness
+		opts.timerStrictMode = cfg.TimerInfoMapsStrictMode
+	}
+	if cfg.StatementTimeouts.BatchWrite < 0 || cfg.StatementTimeouts.LockedUpdate < 0 || cfg.StatementTimeouts.ExecutionWrite < 0 {
+		return dbOptions{}, fmt.Errorf("invalid statementTimeouts %+v, they must not be negative", cfg.StatementTimeouts)
+	}
+	opts.statementTimeouts = cfg.StatementTimeouts
+	if cfg.AsyncMapWriteQueueSize < 0 || cfg.AsyncMapWriteBatchSize < 0 || cfg.AsyncMapWriteFlushInterval < 0 {
+		return dbOptions{}, fmt.Errorf("invalid async map write queue size %v, batch size %v or flush interval %v, they must not be negative",
+			cfg.AsyncMapWriteQueueSize, cfg.AsyncMapWriteBatchSize, cfg.AsyncMapWriteFlushInterval)
+	}
+	if cfg.AsyncMapWriteWALMaxSize < 0 {
+		return dbOptions{}, fmt.Errorf("invalid asyncMapWriteWALMaxSize %v, it must not be negative", cfg.AsyncMapWriteWALMaxSize)
+	}
+	if cfg.MaxInFlightMapOperations < 0 {
+		return dbOptions{}, fmt.Errorf("invalid maxInFlightMapOperations %v, it must not be negative", cfg.MaxInFlightMapOperations)
+	}
+	if cfg.MaxInFlightMapOperations > 0 {
+		opts.mapOpLimiter = newMapOpLimiter(cfg.MaxInFlightMapOperations)
+	}
+	if cfg.MapRetryBudgetRPS < 0 || cfg.MapRetryBudgetBurst < 0 {
+		return dbOptions{}, fmt.Errorf("invalid mapRetryBudgetRPS %v or mapRetryBudgetBurst %v, they must not be negative",
+			cfg.MapRetryBudgetRPS, cfg.MapRetryBudgetBurst)
+	}
+	if cfg.MapRetryBudgetRPS > 0 {
+		burst := cfg.MapRetryBudgetBurst
+		if burst == 0 {
+			burst = int(math.Ceil(cfg.MapRetryBudgetRPS))
+		}
+		opts.retryBudget = newMapRetryBudget(cfg.MapRetryBudgetRPS, burst)
+	}
+	if len(cfg.MapWriteQPSPerDomain) > 0 {
+		limiter, err := newDomainWriteLimiter(cfg.MapWriteQPSPerDomain, cfg.RejectMapWritesOverDomainQPS)
+		if err != nil {
+			return dbOptions{}, err
+		}
+		opts.domainWriteLimiter = limiter
+	}
+	if cfg.DBShardPingTimeout < 0 {
+		return dbOptions{}, fmt.Errorf("invalid dbShardPingTimeout %v, it must not be negative", cfg.DBShardPingTimeout)
+	}
+	opts.pingTimeout = cfg.DBShardPingTimeout
+	if cfg.PreviousNumShards < 0 || (cfg.PreviousNumShards > 0 && cfg.PreviousNumShards >= cfg.NumShards) {
+		return dbOptions{}, fmt.Errorf("invalid previousNShards %v, it must not be negative and be smaller than nShards %v", cfg.PreviousNumShards, cfg.NumShards)
+	}
+	opts.previousNumDBShards = cfg.PreviousNumShards
+	// the count is only maintained by the deletes, the flagged rows and the rows of the previous DB shards are not counted
+	if cfg.MapRowCounterEnabled && (cfg.DeferredMapDeletion || cfg.PreviousNumShards > 0) {
+		return dbOptions{}, errors.New("mapRowCounterEnabled can not be combined with deferredMapDeletion or previousNShards")
+	}
+	opts.mapRowCounter = cfg.MapRowCounterEnabled
+	if cfg.MapDeletionConcurrency < 0 {
+		return dbOptions{}, fmt.Errorf("invalid mapDeletionConcurrency %v, it must not be negative", cfg.MapDeletionConcurrency)
+	}
+	opts.mapDeletionConcurrency = cfg.MapDeletionConcurrency
+	opts.logFailedMapQueries = cfg.LogFailedMapQueries
+	if _, ok := cfg.ConnectAttributes["application_name"]; ok && cfg.ApplicationName != "" {
+		return dbOptions{}, errors.New("applicationName must not be set in connectAttributes as well")
+	}
+	if cfg.LabelTransactionsWithOperation && cfg.ApplicationName == "" {
+		return dbOptions{}, errors.New("labelTransactionsWithOperation requires applicationName to be set")
+	}
+	if cfg.LabelTransactionsWithOperation {
+		opts.applicationName = cfg.ApplicationName
+	}
+	if cfg.MapRowCountReportInterval < 0 {
+		return dbOptions{}, fmt.Errorf("invalid mapRowCountReportInterval %v, it must not be negative", cfg.MapRowCountReportInterval)
+	}
+	if cfg.MapRowCountReportEnabled {
+		opts.rowCountReporter = newMapRowCountReporter(cfg.MapRowCountReportInterval)
+	}
+	if cfg.AsyncMapWriteWALPath != "" && cfg.AsyncMapWriteQueueSize == 0 {
+		return dbOptions{}, errors.New("asyncMapWriteWALPath requires asyncMapWriteQueueSize to be set")
+	}
+	if cfg.MapDeleteAuditLogPath != "" {
+		sink, err := newFileAuditSink(cfg.MapDeleteAuditLogPath)
+		if err != nil {
+			return dbOptions{}, err
+		}
+		opts.auditSink = sink
+	}
+	return opts, nil
 }
 
 // CreateDBConnection creates a returns a reference to a logical connection to the
@@ -83,6 +255,9 @@ func (d *plugin) createSingleDBConn(cfg *config.SQL) (*sqlx.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg.ApplicationName != "" {
+		params.Set("application_name", cfg.ApplicationName)
+	}
 	for k, v := range cfg.ConnectAttributes {
 		params.Set(k, v)
 	}
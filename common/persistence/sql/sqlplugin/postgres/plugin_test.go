@@ -22,7 +22,14 @@
 
 package postgres
 
-import "testing"
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/cadence/common/config"
+)
 
 var testCases = []struct {
 	name     string
@@ -57,3 +64,188 @@ func TestGenerateCredentialString(t *testing.T) {
 		}
 	}
 }
+
+func TestNewDBOptions(t *testing.T) {
+	for mode, want := range map[string]string{
+		"":          batchInsertModeMultiRow,
+		"multiRow":  batchInsertModeMultiRow,
+		"singleRow": batchInsertModeSingleRow,
+	} {
+		opts, err := newDBOptions(&config.SQL{BatchInsertMode: mode})
+		if err != nil || opts.batchInsertMode != want {
+			t.Errorf("%q: got %v, %v, want %v", mode, opts.batchInsertMode, err, want)
+		}
+	}
+	if _, err := newDBOptions(&config.SQL{BatchInsertMode: "pipelined"}); err == nil {
+		t.Errorf("expected error for unknown batch insert mode")
+	}
+}
+
+func TestNewDBOptionsStreamFetchSize(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{StreamFetchSize: 500})
+	if err != nil || opts.streamFetchSize != 500 {
+		t.Errorf("got %v, %v, want 500", opts.streamFetchSize, err)
+	}
+	if _, err := newDBOptions(&config.SQL{StreamFetchSize: -1}); err == nil {
+		t.Errorf("expected error for negative stream fetch size")
+	}
+}
+
+func TestNewDBOptionsSingleRowBindMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"":           singleRowBindModeNamed,
+		"positional": singleRowBindModePositional,
+		"named":      singleRowBindModeNamed,
+	} {
+		opts, err := newDBOptions(&config.SQL{SingleRowBindMode: mode})
+		if err != nil || opts.singleRowBindMode != want {
+			t.Errorf("%q: got %v, %v, want %v", mode, opts.singleRowBindMode, err, want)
+		}
+	}
+	if _, err := newDBOptions(&config.SQL{SingleRowBindMode: "prepared"}); err == nil {
+		t.Errorf("expected error for unknown single row bind mode")
+	}
+}
+
+func TestNewDBOptionsTablePrefix(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{})
+	if err != nil || !strings.Contains(opts.queries.getActivityInfoMapQry, "FROM activity_info_maps\n") {
+		t.Errorf("unexpected default queries: %v, %v", opts.queries.getActivityInfoMapQry, err)
+	}
+	opts, err = newDBOptions(&config.SQL{TablePrefix: "tenantA_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		opts.queries.setKeyInActivityInfoMapQry,
+		opts.queries.getTimerInfoMapSQLQuery,
+		opts.queries.deleteChildExecutionInfoMapQry,
+		opts.queries.deleteKeyInRequestCancelInfoMapQry,
+		opts.queries.getSignalInfoMapQry,
+		opts.queries.createSignalsRequestedSetReturningQuery,
+		opts.queries.getActivityInfoMapsShardNextPageQry,
+		opts.queries.listExecutionsInSignalInfoMapQrys.nextPage,
+	} {
+		if !strings.Contains(query, " tenantA_") {
+			t.Errorf("query does not use the table prefix: %v", query)
+		}
+	}
+	if _, err := newDBOptions(&config.SQL{TablePrefix: "tenant; DROP TABLE executions; --"}); err == nil {
+		t.Errorf("expected error for invalid table prefix")
+	}
+}
+
+func TestNewDBOptionsMaxExecutionMapRows(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{MaxExecutionMapRows: 1000})
+	if err != nil || opts.maxExecutionMapRows != 1000 {
+		t.Errorf("unexpected maxExecutionMapRows: %v, %v", opts.maxExecutionMapRows, err)
+	}
+	if _, err := newDBOptions(&config.SQL{MaxExecutionMapRows: -1}); err == nil {
+		t.Errorf("expected error for negative maxExecutionMapRows")
+	}
+}
+
+func TestNewDBOptionsMapTransactionIsolation(t *testing.T) {
+	for value, level := range map[string]sql.IsolationLevel{
+		"":               sql.LevelDefault,
+		"readCommitted":  sql.LevelReadCommitted,
+		"repeatableRead": sql.LevelRepeatableRead,
+		"serializable":   sql.LevelSerializable,
+	} {
+		opts, err := newDBOptions(&config.SQL{MapTransactionIsolation: value})
+		if err != nil || opts.mapTxIsolation != level {
+			t.Errorf("unexpected mapTxIsolation for %q: %v, %v", value, opts.mapTxIsolation, err)
+		}
+	}
+	if _, err := newDBOptions(&config.SQL{MapTransactionIsolation: "snapshot"}); err == nil {
+		t.Error("expected error for unknown mapTransactionIsolation")
+	}
+}
+
+func TestNewDBOptionsStatementTimeouts(t *testing.T) {
+	timeouts := config.SQLStatementTimeouts{BatchWrite: time.Second, LockedUpdate: 5 * time.Second}
+	opts, err := newDBOptions(&config.SQL{StatementTimeouts: timeouts})
+	if err != nil || opts.statementTimeouts != timeouts {
+		t.Errorf("unexpected statementTimeouts: %+v, %v", opts.statementTimeouts, err)
+	}
+	if _, err := newDBOptions(&config.SQL{StatementTimeouts: config.SQLStatementTimeouts{LockedUpdate: -time.Second}}); err == nil {
+		t.Errorf("expected error for negative statementTimeouts")
+	}
+}
+
+func TestNewDBOptionsMapWriteQPSPerDomain(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{MapWriteQPSPerDomain: map[string]int{"8A4E1C3A-59A7-4A8C-95D4-5D1B0F2A3F10": 10}})
+	if err != nil || opts.domainWriteLimiter == nil || opts.domainWriteLimiter.limiters["8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10"] == nil {
+		t.Errorf("unexpected domainWriteLimiter: %+v, %v", opts.domainWriteLimiter, err)
+	}
+	if _, err := newDBOptions(&config.SQL{MapWriteQPSPerDomain: map[string]int{"test-domain": 10}}); err == nil {
+		t.Errorf("expected error for a domain name instead of a domain ID")
+	}
+	if _, err := newDBOptions(&config.SQL{MapWriteQPSPerDomain: map[string]int{"8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10": 0}}); err == nil {
+		t.Errorf("expected error for a zero QPS")
+	}
+}
+
+func TestNewDBOptionsPreviousNumShards(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{NumShards: 4, PreviousNumShards: 2})
+	if err != nil || opts.previousNumDBShards != 2 {
+		t.Errorf("unexpected previousNumDBShards: %v, %v", opts.previousNumDBShards, err)
+	}
+	for _, previous := range []int{-1, 4, 8} {
+		if _, err := newDBOptions(&config.SQL{NumShards: 4, PreviousNumShards: previous}); err == nil {
+			t.Errorf("expected error for previousNShards %v", previous)
+		}
+	}
+}
+
+func TestNewDBOptionsMapDeletionConcurrency(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{MapDeletionConcurrency: 3})
+	if err != nil || opts.mapDeletionConcurrency != 3 {
+		t.Errorf("unexpected mapDeletionConcurrency: %v, %v", opts.mapDeletionConcurrency, err)
+	}
+	if _, err := newDBOptions(&config.SQL{MapDeletionConcurrency: -1}); err == nil {
+		t.Error("expected error for negative mapDeletionConcurrency")
+	}
+}
+
+func TestNewDBOptionsMapDataFallbackEncoding(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{})
+	if err != nil || opts.decodeFallback != nil {
+		t.Errorf("unexpected default decodeFallback: %+v, %v", opts.decodeFallback, err)
+	}
+	opts, err = newDBOptions(&config.SQL{MapDataFallbackEncoding: "thriftrw"})
+	if err != nil || opts.decodeFallback == nil || opts.decodeFallback.encoding != "thriftrw" {
+		t.Errorf("unexpected decodeFallback: %+v, %v", opts.decodeFallback, err)
+	}
+	if _, err := newDBOptions(&config.SQL{MapDataFallbackEncoding: "protobuf"}); err == nil {
+		t.Errorf("expected error for an unsupported fallback encoding")
+	}
+}
+
+func TestNewDBOptionsMapRowCountReport(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{MapRowCountReportEnabled: true, MapRowCountReportInterval: time.Minute})
+	if err != nil || opts.rowCountReporter == nil || opts.rowCountReporter.interval != time.Minute {
+		t.Errorf("unexpected rowCountReporter: %+v, %v", opts.rowCountReporter, err)
+	}
+	opts.close(nil)
+	if _, err := newDBOptions(&config.SQL{MapRowCountReportEnabled: true, MapRowCountReportInterval: -time.Minute}); err == nil {
+		t.Errorf("expected error for negative mapRowCountReportInterval")
+	}
+}
+
+func TestNewDBOptionsLabelTransactionsWithOperation(t *testing.T) {
+	opts, err := newDBOptions(&config.SQL{ApplicationName: "cadence-history"})
+	if err != nil || opts.applicationName != "" {
+		t.Errorf("unexpected applicationName without labeling: %q, %v", opts.applicationName, err)
+	}
+	opts, err = newDBOptions(&config.SQL{ApplicationName: "cadence-history", LabelTransactionsWithOperation: true})
+	if err != nil || opts.applicationName != "cadence-history" {
+		t.Errorf("unexpected applicationName: %q, %v", opts.applicationName, err)
+	}
+	if _, err := newDBOptions(&config.SQL{LabelTransactionsWithOperation: true}); err == nil {
+		t.Errorf("expected error for labelTransactionsWithOperation without applicationName")
+	}
+	if _, err := newDBOptions(&config.SQL{ApplicationName: "cadence-history", ConnectAttributes: map[string]string{"application_name": "cadence"}}); err == nil {
+		t.Errorf("expected error for applicationName set in connectAttributes as well")
+	}
+}
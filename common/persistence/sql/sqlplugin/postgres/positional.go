@@ -0,0 +1,70 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"reflect"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// singleRowBindModeNamed binds a single execution map row by name like the rows of a batch
+	singleRowBindModeNamed = "named"
+	// singleRowBindModePositional binds a single execution map row to a positional statement before it is sent, so
+	// it is written with a plain Exec instead of a named one
+	singleRowBindModePositional = "positional"
+)
+
+// mapRowBinder binds the named write queries of the execution map tables with the column names the rows are mapped
+// to by the plugin, it is never connected
+var mapRowBinder = newMapRowBinder()
+
+func newMapRowBinder() *sqlx.DB {
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	return xdb
+}
+
+// positionalMapRow returns query bound to the positional arguments of rows if rows is a single execution map row,
+// either a row struct or a slice of one, which is written positionally in this db. ok is false otherwise
+func (pdb *db) positionalMapRow(query string, rows interface{}) (positional string, args []interface{}, ok bool, err error) {
+	if pdb.opts.singleRowBindMode != singleRowBindModePositional {
+		return "", nil, false, nil
+	}
+	v := reflect.ValueOf(rows)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Len() != 1 {
+			return "", nil, false, nil
+		}
+		rows = v.Index(0).Interface()
+	case reflect.Struct:
+	default:
+		return "", nil, false, nil
+	}
+	positional, args, err = mapRowBinder.BindNamed(query, rows)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return positional, args, true, nil
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestPositionalMapRowMatchesBindNamed(t *testing.T) {
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	queries := newExecutionMapQueries("")
+	deferred := newExecutionMapQueries("")
+	deferred.enableDeferredActivityDeletion("")
+
+	activity := sqlplugin.ActivityInfoMapsRow{
+		ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 5, Data: []byte("data"),
+		DataEncoding: "thriftrw", LastHeartbeatDetails: []byte("details"), LastHeartbeatUpdatedTime: time.Unix(1, 0)}
+	// every write query of the execution map tables which is bound by name, with each suffix it is run with
+	tests := map[string]struct {
+		queries []string
+		row     interface{}
+	}{
+		"activity":                        {upsertQueries(queries.setKeyInActivityInfoMapQry), activity},
+		"activity with deferred deletion": {upsertQueries(deferred.setKeyInActivityInfoMapQry), activity},
+		"timer": {upsertQueries(queries.setKeyInTimerInfoMapSQLQuery), sqlplugin.TimerInfoMapsRow{
+			ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, TimerID: "t", Data: []byte("data"), DataEncoding: "thriftrw"}},
+		"child execution": {upsertQueries(queries.setKeyInChildExecutionInfoMapQry), sqlplugin.ChildExecutionInfoMapsRow{
+			ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, InitiatedID: 7, Data: []byte("data"), DataEncoding: "thriftrw"}},
+		"request cancel": {upsertQueries(queries.setKeyInRequestCancelInfoMapQry), sqlplugin.RequestCancelInfoMapsRow{
+			ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, InitiatedID: 8, Data: []byte("data"), DataEncoding: "thriftrw"}},
+		"signal": {upsertQueries(queries.setKeyInSignalInfoMapQry), sqlplugin.SignalInfoMapsRow{
+			ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, InitiatedID: 9, Data: []byte("data"), DataEncoding: "thriftrw"}},
+		"signals requested": {[]string{queries.createSignalsRequestedSetQuery, queries.createSignalsRequestedSetReturningQuery}, sqlplugin.SignalsRequestedSetsRow{
+			ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: runID, SignalID: "sid"}},
+	}
+	pdb := &db{numDBShards: 1, opts: dbOptions{singleRowBindMode: singleRowBindModePositional}}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, query := range test.queries {
+				wantQuery, wantArgs, err := xdb.BindNamed(query, test.row)
+				require.NoError(t, err)
+				positional, args, ok, err := pdb.positionalMapRow(query, test.row)
+				require.NoError(t, err)
+				require.True(t, ok, query)
+				assert.Equal(t, wantQuery, positional)
+				assert.Equal(t, wantArgs, args)
+			}
+		})
+	}
+}
+
+// upsertQueries returns query with each suffix the upserts of an execution map table are run with
+func upsertQueries(query string) []string {
+	return []string{query, query + returningInserted}
+}
+
+func TestPositionalMapRow(t *testing.T) {
+	query := newExecutionMapQueries("").setKeyInTimerInfoMapSQLQuery
+	pdb := &db{numDBShards: 1, opts: dbOptions{singleRowBindMode: singleRowBindModePositional}}
+	_, _, ok, err := pdb.positionalMapRow(query, []sqlplugin.TimerInfoMapsRow{{TimerID: "a"}, {TimerID: "b"}})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	positional, args, ok, err := pdb.positionalMapRow(query, []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotContains(t, positional, ":timer_id")
+	assert.Contains(t, args, "a")
+	// a parameter which is not a column of the row fails like binding it by name does
+	_, _, _, err = pdb.positionalMapRow("UPDATE t SET a = :missing", sqlplugin.TimerInfoMapsRow{TimerID: "a"})
+	assert.Error(t, err)
+	// the named mode leaves every row to be bound by name
+	pdb.opts.singleRowBindMode = singleRowBindModeNamed
+	_, _, ok, err = pdb.positionalMapRow(query, []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSingleRowBindMode(t *testing.T) {
+	rows := []sqlplugin.TimerInfoMapsRow{{ShardID: 1, TimerID: "a"}}
+	for _, test := range []struct {
+		mode       string
+		rows       []sqlplugin.TimerInfoMapsRow
+		execs      int
+		namedExecs int
+	}{
+		{singleRowBindModePositional, rows, 1, 0},
+		{singleRowBindModePositional, append(rows, sqlplugin.TimerInfoMapsRow{ShardID: 1, TimerID: "b"}), 0, 1},
+		{singleRowBindModeNamed, rows, 0, 1},
+	} {
+		pdb, driver := newMockDB(t)
+		pdb.opts.singleRowBindMode = test.mode
+		driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(batchResult(1), nil).Times(test.execs)
+		driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(batchResult(1), nil).Times(test.namedExecs)
+		result, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), test.rows)
+		require.NoError(t, err)
+		n, err := result.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n, test.mode)
+	}
+}
+
+// BenchmarkReplaceIntoActivityInfoMapsSingleRow compares the single row bind modes without a database, so it only
+// measures what the binding costs
+func BenchmarkReplaceIntoActivityInfoMapsSingleRow(b *testing.B) {
+	rows := []sqlplugin.ActivityInfoMapsRow{{
+		ShardID:                  1,
+		DomainID:                 serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10"),
+		WorkflowID:               "wid",
+		RunID:                    serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b"),
+		ScheduleID:               5,
+		Data:                     []byte("data"),
+		DataEncoding:             "thriftrw",
+		LastHeartbeatDetails:     []byte("details"),
+		LastHeartbeatUpdatedTime: time.Unix(1, 0),
+	}}
+	for _, mode := range []string{singleRowBindModeNamed, singleRowBindModePositional} {
+		b.Run(mode, func(b *testing.B) {
+			xdb := sqlx.NewDb(nil, PluginName)
+			xdb.MapperFunc(strcase.ToSnake)
+			pdb, driver := newMockDB(b)
+			pdb.opts.singleRowBindMode = mode
+			// a named write is bound like sqlx does before it would be sent and dropped
+			driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, dbShardID int, query string, arg interface{}) (sql.Result, error) {
+					if _, _, err := xdb.BindNamed(query, arg); err != nil {
+						return nil, err
+					}
+					return batchResult(1), nil
+				}).AnyTimes()
+			driver.EXPECT().ExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).Return(batchResult(1), nil).AnyTimes()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := pdb.ReplaceIntoActivityInfoMaps(context.Background(), rows); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
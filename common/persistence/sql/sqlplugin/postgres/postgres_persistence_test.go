@@ -21,11 +21,19 @@
 package postgres
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	pt "github.com/uber/cadence/common/persistence/persistence-tests"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/testflags"
 )
 
@@ -85,6 +93,58 @@ func TestPostgresSQLVisibilityPersistenceSuite(t *testing.T) {
 	suite.Run(t, s)
 }
 
+func TestPostgresSQLMergeSignalsRequestedSetConcurrently(t *testing.T) {
+	testflags.RequirePostgres(t)
+	testBase := pt.NewTestBaseWithSQL(GetTestClusterOption())
+	testBase.Setup()
+	defer testBase.TearDownWorkflowStore()
+	cfg := testBase.Config()
+	pdb, err := (&plugin{}).CreateDB(cfg.DataStores[cfg.DefaultStore].SQL)
+	require.NoError(t, err)
+	defer pdb.Close()
+	merger := pdb.(*db)
+
+	filter := &sqlplugin.SignalsRequestedSetsFilter{
+		ShardID:    1,
+		DomainID:   serialization.MustParseUUID(uuid.New()),
+		WorkflowID: "merge-signals-requested-set",
+		RunID:      serialization.MustParseUUID(uuid.New()),
+	}
+	const workers = 8
+	const merges = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*(merges+1))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// each worker replaces its own signal over and over while every worker removes and adds the shared one
+			for m := 1; m <= merges; m++ {
+				errs <- merger.MergeSignalsRequestedSet(context.Background(), filter,
+					[]string{fmt.Sprintf("signal-%v-%v", w, m)}, []string{fmt.Sprintf("signal-%v-%v", w, m-1)})
+			}
+			errs <- merger.MergeSignalsRequestedSet(context.Background(), filter, []string{"shared"}, []string{"shared"})
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	rows, err := pdb.SelectFromSignalsRequestedSets(context.Background(), filter)
+	require.NoError(t, err)
+	var signalIDs []string
+	for _, row := range rows {
+		signalIDs = append(signalIDs, row.SignalID)
+	}
+	expected := []string{"shared"}
+	for w := 0; w < workers; w++ {
+		expected = append(expected, fmt.Sprintf("signal-%v-%v", w, merges))
+	}
+	assert.ElementsMatch(t, expected, signalIDs)
+}
+
 // TODO flaky test in buildkite
 // https://github.com/uber/cadence/issues/2877
 /*
@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+)
+
+// failedQueryLoggingDriver logs the statements of the execution map operations which fail, with their query and
+// a summary of their parameters. Nothing is logged for a statement which succeeds
+type failedQueryLoggingDriver struct {
+	sqldriver.Driver
+	logger log.Logger
+}
+
+func (d *failedQueryLoggingDriver) ExecContext(ctx context.Context, dbShardID int, query string, args ...interface{}) (sql.Result, error) {
+	result, err := d.Driver.ExecContext(ctx, dbShardID, query, args...)
+	if err != nil {
+		d.logFailure(err, dbShardID, query, summarizeQueryArgs(args))
+	}
+	return result, err
+}
+
+func (d *failedQueryLoggingDriver) NamedExecContext(ctx context.Context, dbShardID int, query string, arg interface{}) (sql.Result, error) {
+	result, err := d.Driver.NamedExecContext(ctx, dbShardID, query, arg)
+	if err != nil {
+		d.logFailure(err, dbShardID, query, []string{summarizeQueryArg(arg)})
+	}
+	return result, err
+}
+
+func (d *failedQueryLoggingDriver) GetContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	err := d.Driver.GetContext(ctx, dbShardID, dest, query, args...)
+	// no row is an answer, not a failure
+	if err != nil && err != sql.ErrNoRows {
+		d.logFailure(err, dbShardID, query, summarizeQueryArgs(args))
+	}
+	return err
+}
+
+func (d *failedQueryLoggingDriver) SelectContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	err := d.Driver.SelectContext(ctx, dbShardID, dest, query, args...)
+	if err != nil {
+		d.logFailure(err, dbShardID, query, summarizeQueryArgs(args))
+	}
+	return err
+}
+
+func (d *failedQueryLoggingDriver) logFailure(err error, dbShardID int, query string, args []string) {
+	d.logger.Error("Execution map statement failed",
+		tag.Error(err),
+		tag.DBShardID(dbShardID),
+		tag.SQLQuery(query),
+		tag.SQLQueryArgs(args))
+}
+
+func summarizeQueryArgs(args []interface{}) []string {
+	summary := make([]string, len(args))
+	for i, arg := range args {
+		summary[i] = summarizeQueryArg(arg)
+	}
+	return summary
+}
+
+// summarizeQueryArg describes a parameter of a statement without its contents unless it is a key: byte slices other
+// than UUIDs are blobs and only their length is kept, a row keeps its key columns and the length of its blobs, and a
+// batch of rows is described by its first row. Values of any other type are only described by their type
+func summarizeQueryArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case serialization.UUID:
+		return v.String()
+	case []byte:
+		return fmt.Sprintf("[]byte(len=%v)", len(v))
+	case string, bool, int, int32, int64, uint32, uint64:
+		return fmt.Sprintf("%v", v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	value := reflect.ValueOf(arg)
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return "NULL"
+		}
+		return summarizeQueryArg(value.Elem().Interface())
+	case reflect.Slice:
+		if value.Len() == 0 {
+			return fmt.Sprintf("%T(len=0)", arg)
+		}
+		return fmt.Sprintf("%v elements, first %v", value.Len(), summarizeQueryArg(value.Index(0).Interface()))
+	case reflect.Struct:
+		fields := ""
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if fields != "" {
+				fields += " "
+			}
+			fields += value.Type().Field(i).Name + ":" + summarizeQueryArg(value.Field(i).Interface())
+		}
+		return fmt.Sprintf("%v{%v}", value.Type().Name(), fields)
+	}
+	return fmt.Sprintf("%T", arg)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestLogFailedMapQueries(t *testing.T) {
+	logger := &log.MockLogger{}
+	pdb, driver := newMockDB(t)
+	pdb.opts.logFailedMapQueries = true
+	pdb.opts.logger = logger
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	filter := &sqlplugin.TimerInfoMapsFilter{ShardID: 1, DomainID: domainID, WorkflowID: "wid", RunID: domainID}
+
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	_, err := pdb.SelectFromTimerInfoMaps(context.Background(), filter)
+	assert.NoError(t, err)
+
+	reset := errors.New("connection reset")
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).Return(reset).Times(2)
+	logger.On("Error", "Execution map statement failed", []tag.Tag{
+		tag.Error(reset),
+		tag.DBShardID(0),
+		tag.SQLQuery(pdb.opts.queries.getTimerInfoMapSQLQuery),
+		tag.SQLQueryArgs([]string{"1", domainID.String(), "wid", domainID.String()}),
+	}).Once()
+	_, err = pdb.SelectFromTimerInfoMaps(context.Background(), filter)
+	assert.Error(t, err)
+	logger.AssertExpectations(t)
+
+	pdb.opts.logFailedMapQueries = false
+	_, err = pdb.SelectFromTimerInfoMaps(context.Background(), filter)
+	assert.Error(t, err)
+	logger.AssertNumberOfCalls(t, "Error", 1)
+}
+
+func TestSummarizeQueryArg(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	heartbeat := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	row := sqlplugin.ActivityInfoMapsRow{
+		ShardID:                  1,
+		DomainID:                 domainID,
+		WorkflowID:               "wid",
+		RunID:                    domainID,
+		ScheduleID:               5,
+		Data:                     []byte("secret"),
+		DataEncoding:             "thriftrw",
+		LastHeartbeatUpdatedTime: heartbeat,
+	}
+	assert.Equal(t, "ActivityInfoMapsRow{ShardID:1 DomainID:"+domainID.String()+" WorkflowID:wid RunID:"+domainID.String()+" ScheduleID:5 "+
+		"Data:[]byte(len=6) DataEncoding:thriftrw LastHeartbeatDetails:[]byte(len=0) LastHeartbeatUpdatedTime:2020-05-17T10:30:00Z}",
+		summarizeQueryArg(row))
+	assert.Equal(t, "2 elements, first "+summarizeQueryArg(row), summarizeQueryArg([]sqlplugin.ActivityInfoMapsRow{row, row}))
+	assert.Equal(t, "[]byte(len=6)", summarizeQueryArg([]byte("secret")))
+	assert.Equal(t, "NULL", summarizeQueryArg(nil))
+	assert.Equal(t, "float64", summarizeQueryArg(1.5))
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lib/pq"
+	"golang.org/x/time/rate"
+
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+	"github.com/uber/cadence/common/types"
+)
+
+// mapRetryBudget is a token bucket per dbShardID which the retries of the execution map operations draw from, the
+// first attempt of an operation is free. While a shard is failing its concurrent operations share the budget, so the
+// rate of retries sent to the shard is bounded instead of growing with the number of operations.
+//
+// The callers retry the statements of the operations outside of the plugin, so they cannot be told apart from new
+// ones. Once a statement sent to a shard failed, every statement sent to the shard until one succeeds is taken for a
+// retry and draws from the budget, a statement which finds the budget exhausted fails fast without being sent. The
+// serialization failures of transactions are retried by txExecute, each retry draws from the budget as well
+type mapRetryBudget struct {
+	sync.Mutex
+	rps    float64
+	burst  int
+	shards map[int]*shardRetryBudget
+}
+
+// shardRetryBudget is the budget of a dbShardID, failing is set while its last statement failed
+type shardRetryBudget struct {
+	bucket  *rate.Limiter
+	failing atomic.Bool
+}
+
+func newMapRetryBudget(rps float64, burst int) *mapRetryBudget {
+	return &mapRetryBudget{rps: rps, burst: burst, shards: make(map[int]*shardRetryBudget)}
+}
+
+func (b *mapRetryBudget) shard(dbShardID int) *shardRetryBudget {
+	b.Lock()
+	defer b.Unlock()
+	shard, ok := b.shards[dbShardID]
+	if !ok {
+		shard = &shardRetryBudget{bucket: rate.NewLimiter(rate.Limit(b.rps), b.burst)}
+		b.shards[dbShardID] = shard
+	}
+	return shard
+}
+
+// allowRetry takes a token of dbShardID without waiting, it returns false once the budget of dbShardID is exhausted
+func (b *mapRetryBudget) allowRetry(dbShardID int) bool {
+	return b.shard(dbShardID).bucket.Allow()
+}
+
+// isRetryableMapError returns the retryable function of the transactions on dbShardID, a serialization failure is
+// retried while the retry budget of dbShardID has tokens and fails fast otherwise
+func (pdb *db) isRetryableMapError(dbShardID int) func(error) bool {
+	return func(err error) bool {
+		if !pdb.IsSerializationFailureError(err) {
+			return false
+		}
+		if pdb.opts.retryBudget == nil || pdb.opts.retryBudget.allowRetry(dbShardID) {
+			return true
+		}
+		pdb.retryBudgetExhausted()
+		return false
+	}
+}
+
+func (pdb *db) retryBudgetExhausted() {
+	if pdb.opts.metricsClient != nil {
+		pdb.opts.metricsClient.Scope(metrics.PersistenceExecutionMapWriteScope).IncCounter(metrics.PersistenceMapRetryBudgetExhausted)
+	}
+}
+
+// isMapShardFailure tells whether err failed a statement because of the shard, rather than because of the statement
+// itself like a missing row, a constraint violation or an invalid query, which a retry would fail with again
+func isMapShardFailure(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var sqlErr *pq.Error
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.Code.Class() {
+		case "22", "23", "42":
+			return false
+		}
+	}
+	return true
+}
+
+// retryBudgetDriver is a driver whose statements draw from the retry budget of their dbShardID while it is failing
+type retryBudgetDriver struct {
+	sqldriver.Driver
+	pdb    *db
+	budget *mapRetryBudget
+}
+
+// before fails fast if dbShardID is failing and its retry budget is exhausted
+func (d *retryBudgetDriver) before(dbShardID int) error {
+	shard := d.budget.shard(dbShardID)
+	if !shard.failing.Load() || shard.bucket.Allow() {
+		return nil
+	}
+	d.pdb.retryBudgetExhausted()
+	return &types.ServiceBusyError{Message: fmt.Sprintf("the retry budget of DB shard %v is exhausted", dbShardID)}
+}
+
+func (d *retryBudgetDriver) after(dbShardID int, err error) {
+	d.budget.shard(dbShardID).failing.Store(isMapShardFailure(err))
+}
+
+func (d *retryBudgetDriver) ExecContext(ctx context.Context, dbShardID int, query string, args ...interface{}) (sql.Result, error) {
+	if err := d.before(dbShardID); err != nil {
+		return nil, err
+	}
+	result, err := d.Driver.ExecContext(ctx, dbShardID, query, args...)
+	d.after(dbShardID, err)
+	return result, err
+}
+
+func (d *retryBudgetDriver) NamedExecContext(ctx context.Context, dbShardID int, query string, arg interface{}) (sql.Result, error) {
+	if err := d.before(dbShardID); err != nil {
+		return nil, err
+	}
+	result, err := d.Driver.NamedExecContext(ctx, dbShardID, query, arg)
+	d.after(dbShardID, err)
+	return result, err
+}
+
+func (d *retryBudgetDriver) GetContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	if err := d.before(dbShardID); err != nil {
+		return err
+	}
+	err := d.Driver.GetContext(ctx, dbShardID, dest, query, args...)
+	d.after(dbShardID, err)
+	return err
+}
+
+func (d *retryBudgetDriver) SelectContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	if err := d.before(dbShardID); err != nil {
+		return err
+	}
+	err := d.Driver.SelectContext(ctx, dbShardID, dest, query, args...)
+	d.after(dbShardID, err)
+	return err
+}
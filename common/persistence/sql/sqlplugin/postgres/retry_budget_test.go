@@ -0,0 +1,165 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestRetryBudgetIsSharedPerDBShard(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 2
+	// every transaction fails to begin with a serialization failure
+	begins := make(map[int]int)
+	driver.EXPECT().BeginTxx(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, dbShardID int, _ *sql.TxOptions) (*sqlx.Tx, error) {
+		begins[dbShardID]++
+		return nil, &pq.Error{Code: ErrSerializationFailure}
+	}).AnyTimes()
+	noop := func(tx *db) error { return nil }
+	txExecute := func(dbShardID int) error {
+		return pdb.txExecute(context.Background(), dbShardID, sql.LevelDefault, 0, noop)
+	}
+
+	// without a budget every transaction is retried up to the max attempts of the retry policy
+	assert.True(t, pdb.IsSerializationFailureError(txExecute(0)))
+	assert.Equal(t, serializationRetryMaxAttempts+1, begins[0])
+
+	// the budget of 2 retries is drawn by the first transaction, the next one fails fast on its first attempt
+	pdb.opts.retryBudget = newMapRetryBudget(0.001, 2)
+	begins = make(map[int]int)
+	assert.True(t, pdb.IsSerializationFailureError(txExecute(0)))
+	assert.Equal(t, 3, begins[0])
+	assert.True(t, pdb.IsSerializationFailureError(txExecute(0)))
+	assert.Equal(t, 4, begins[0])
+
+	// the other dbShardID has its own budget
+	assert.True(t, pdb.IsSerializationFailureError(txExecute(1)))
+	assert.Equal(t, 3, begins[1])
+}
+
+func TestMapTransactionRetriesSerializationFailure(t *testing.T) {
+	connector := &stagingConnector{failAt: 2, failWith: &pq.Error{Code: ErrSerializationFailure}}
+	xdb := sqlx.NewDb(sql.OpenDB(connector), PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	opts, err := newDBOptions(&config.SQL{MapTransactionIsolation: "serializable"})
+	require.NoError(t, err)
+	pdb, err := newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, opts)
+	require.NoError(t, err)
+	filter := &sqlplugin.SignalsRequestedSetsFilter{ShardID: 1, WorkflowID: "wid"}
+
+	// the delete of the first attempt is aborted, the whole transaction runs again and is written once
+	require.NoError(t, pdb.MergeSignalsRequestedSet(context.Background(), filter, []string{"a", "b"}, []string{"c"}))
+	assert.Equal(t, 5, connector.execs)
+	assert.Equal(t, 3, connector.written)
+	assert.Equal(t, []driver.IsolationLevel{driver.IsolationLevel(sql.LevelSerializable), driver.IsolationLevel(sql.LevelSerializable)}, connector.isolations)
+
+	// other errors are not retried
+	connector = &stagingConnector{failAt: 2}
+	xdb = sqlx.NewDb(sql.OpenDB(connector), PluginName)
+	pdb, err = newDB([]*sqlx.DB{xdb}, nil, sqlplugin.DbShardUndefined, 1, opts)
+	require.NoError(t, err)
+	assert.EqualError(t, pdb.MergeSignalsRequestedSet(context.Background(), filter, []string{"a"}, []string{"c"}), "connection reset")
+	assert.Len(t, connector.isolations, 1)
+}
+
+func TestRetryBudgetBoundsMapOperationRetries(t *testing.T) {
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 2
+	pdb.opts.retryBudget = newMapRetryBudget(0.001, 2)
+	// the statements sent to a DB shard fail with a throttling error while failures of it are left
+	failures, statements := map[int]int{0: 100, 1: 1}, make(map[int]int)
+	statement := func(dbShardID int) error {
+		statements[dbShardID]++
+		if failures[dbShardID] > 0 {
+			failures[dbShardID]--
+			return &pq.Error{Code: ErrTooManyConnections}
+		}
+		return nil
+	}
+	driver.EXPECT().NamedExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, dbShardID int, _ string, _ interface{}) (sql.Result, error) {
+			return batchResult(1), statement(dbShardID)
+		}).AnyTimes()
+	driver.EXPECT().SelectContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, dbShardID int, _ interface{}, _ string, _ ...interface{}) error {
+			return statement(dbShardID)
+		}).AnyTimes()
+	policy := backoff.NewExponentialRetryPolicy(time.Millisecond)
+	policy.SetMaximumAttempts(10)
+	retry := backoff.NewThrottleRetry(
+		backoff.WithRetryPolicy(policy),
+		backoff.WithThrottlePolicy(policy),
+		backoff.WithRetryableError(func(error) bool { return true }),
+	)
+	write := func(shardID int64) error {
+		return retry.Do(context.Background(), func() error {
+			_, err := pdb.ReplaceIntoTimerInfoMaps(context.Background(), []sqlplugin.TimerInfoMapsRow{{ShardID: shardID, TimerID: "a"}})
+			return err
+		})
+	}
+
+	// the first attempt and the 2 retries of the budget reach the failing shard, the next retry fails fast
+	err := write(0)
+	assert.IsType(t, &types.ServiceBusyError{}, err)
+	assert.Equal(t, 3, statements[0])
+	// a read of the shard is a retry as well while the shard is failing
+	_, err = pdb.SelectFromTimerInfoMaps(context.Background(), &sqlplugin.TimerInfoMapsFilter{ShardID: 0})
+	var busy *types.ServiceBusyError
+	assert.True(t, errors.As(err, &busy))
+	assert.True(t, pdb.IsThrottlingError(err))
+	assert.Equal(t, 3, statements[0])
+
+	// the other dbShardID has its own budget, once a statement succeeds the shard is not failing anymore and the
+	// statements are not retries
+	require.NoError(t, write(1))
+	assert.Equal(t, 2, statements[1])
+	for i := 0; i < 3; i++ {
+		require.NoError(t, write(1))
+	}
+	assert.Equal(t, 5, statements[1])
+}
+
+func TestIsMapShardFailure(t *testing.T) {
+	assert.False(t, isMapShardFailure(nil))
+	assert.False(t, isMapShardFailure(sql.ErrNoRows))
+	assert.False(t, isMapShardFailure(context.Canceled))
+	assert.False(t, isMapShardFailure(&pq.Error{Code: ErrDupEntry}))
+	assert.True(t, isMapShardFailure(&pq.Error{Code: ErrTooManyConnections}))
+	assert.True(t, isMapShardFailure(context.DeadlineExceeded))
+	assert.True(t, isMapShardFailure(driver.ErrBadConn))
+}
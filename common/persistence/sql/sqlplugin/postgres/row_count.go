@@ -0,0 +1,187 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common/log/tag"
+)
+
+const (
+	defaultMapRowCountReportInterval = 5 * time.Minute
+
+	// %[1]v is the name of the table
+	countMapRowsByShardQueryTemplate = `SELECT shard_id, COUNT(*) AS row_count FROM %[1]v GROUP BY shard_id`
+)
+
+// mapRowCountTables are the execution map tables whose rows are counted, in the order they are counted
+var mapRowCountTables = []string{
+	activityInfoTableName,
+	timerInfoTableName,
+	childExecutionInfoTableName,
+	requestCancelInfoTableName,
+	signalInfoTableName,
+	signalsRequestedSetsTableName,
+}
+
+type mapRowCount struct {
+	ShardID  int64
+	RowCount int64
+}
+
+// mapRowCountReporter counts the rows of every execution map table per shard in the background, every interval, and
+// records the counts with RecordRowCount of the execution map metrics. A count is a full scan of the table on every
+// DB shard, so the interval should be minutes rather than seconds. Nothing is counted while no metrics are set.
+type mapRowCountReporter struct {
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	// reported are the shards a count was recorded for by table, a shard whose rows are all gone is recorded with 0
+	// once so its gauge does not keep the last count
+	reported map[string]map[int64]struct{}
+
+	// mu guards started and closed
+	mu      sync.Mutex
+	started bool
+	closed  bool
+}
+
+func newMapRowCountReporter(interval time.Duration) *mapRowCountReporter {
+	if interval == 0 {
+		interval = defaultMapRowCountReportInterval
+	}
+	return &mapRowCountReporter{
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		reported: make(map[string]map[int64]struct{}),
+	}
+}
+
+// start starts counting the rows with pdb, which must not be a transaction
+func (r *mapRowCountReporter) start(pdb *db) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started || r.closed {
+		return
+	}
+	r.started = true
+	go r.run(pdb)
+}
+
+// close stops the reporter and waits for a count in progress to return
+func (r *mapRowCountReporter) close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.stop)
+	started := r.started
+	r.mu.Unlock()
+	if started {
+		<-r.done
+	}
+}
+
+func (r *mapRowCountReporter) run(pdb *db) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+			go func() {
+				// a count in progress is abandoned once the reporter is stopped
+				select {
+				case <-r.stop:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			r.report(ctx, pdb)
+			cancel()
+		}
+	}
+}
+
+// report counts the rows of every table and records the counts. The rows of a shard can be spread over two DB shards
+// while the DB shards are migrated, so the counts of all DB shards are added up. A table which fails to be counted on
+// any DB shard is skipped until the next report, as a partial count would show a drop which did not happen
+func (r *mapRowCountReporter) report(ctx context.Context, pdb *db) {
+	mapMetrics := pdb.opts.mapMetrics
+	if mapMetrics == nil {
+		return
+	}
+	for _, table := range mapRowCountTables {
+		counts, err := pdb.countMapRowsByShard(ctx, table)
+		if err != nil {
+			if pdb.opts.logger != nil {
+				pdb.opts.logger.Warn("failed to count the rows of an execution map table", tag.Name(table), tag.Error(err))
+			}
+			continue
+		}
+		for shardID := range r.reported[table] {
+			if _, ok := counts[shardID]; !ok {
+				mapMetrics.RecordRowCount(table, shardID, 0)
+			}
+		}
+		reported := make(map[int64]struct{}, len(counts))
+		shardIDs := make([]int64, 0, len(counts))
+		for shardID := range counts {
+			shardIDs = append(shardIDs, shardID)
+			reported[shardID] = struct{}{}
+		}
+		sort.Slice(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] })
+		for _, shardID := range shardIDs {
+			mapMetrics.RecordRowCount(table, shardID, counts[shardID])
+		}
+		r.reported[table] = reported
+	}
+}
+
+// countMapRowsByShard returns the number of rows of table keyed by shard ID, summed over all DB shards
+func (pdb *db) countMapRowsByShard(ctx context.Context, table string) (map[int64]int64, error) {
+	query, ok := pdb.opts.queries.countMapRowsByShardQrys[table]
+	if !ok {
+		return nil, fmt.Errorf("no row count query for table %v", table)
+	}
+	counts := make(map[int64]int64)
+	for dbShardID := 0; dbShardID < pdb.numDBShards; dbShardID++ {
+		var rows []mapRowCount
+		if err := pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, query); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[row.ShardID] += row.RowCount
+		}
+	}
+	return counts, nil
+}
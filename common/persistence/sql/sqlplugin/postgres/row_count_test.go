@@ -0,0 +1,90 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapRowCountReporter(t *testing.T) {
+	// the row counts are keyed by DB shard and table, counting the rows of timer_info_maps fails
+	rowCounts := map[int]map[string][]mapRowCount{
+		0: {activityInfoTableName: {{ShardID: 1, RowCount: 3}, {ShardID: 2, RowCount: 5}}},
+		1: {activityInfoTableName: {{ShardID: 2, RowCount: 1}}},
+	}
+	mapMetrics := &recordingMapMetrics{}
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 2
+	pdb.opts.mapMetrics = mapMetrics
+	driver.EXPECT().SelectContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, dbShardID int, dest interface{}, query string, _ ...interface{}) error {
+			for _, table := range mapRowCountTables {
+				if strings.Contains(query, "FROM "+table+" ") {
+					if table == timerInfoTableName {
+						return errors.New("statement timeout")
+					}
+					*dest.(*[]mapRowCount) = rowCounts[dbShardID][table]
+					return nil
+				}
+			}
+			return errors.New("unexpected query " + query)
+		}).AnyTimes()
+	reporter := newMapRowCountReporter(0)
+	assert.Equal(t, defaultMapRowCountReportInterval, reporter.interval)
+
+	// the counts of a shard spread over two DB shards are added up, the table which fails to be counted is skipped
+	reporter.report(context.Background(), pdb)
+	assert.Equal(t, []string{
+		"rows activity_info_maps 1 3",
+		"rows activity_info_maps 2 6",
+	}, mapMetrics.calls)
+
+	// a shard without rows left is recorded with 0 once
+	mapMetrics.calls = nil
+	rowCounts = map[int]map[string][]mapRowCount{0: {activityInfoTableName: {{ShardID: 2, RowCount: 4}}}}
+	reporter.report(context.Background(), pdb)
+	reporter.report(context.Background(), pdb)
+	assert.Equal(t, []string{
+		"rows activity_info_maps 1 0",
+		"rows activity_info_maps 2 4",
+		"rows activity_info_maps 2 4",
+	}, mapMetrics.calls)
+}
+
+func TestMapRowCountReporterClose(t *testing.T) {
+	reporter := newMapRowCountReporter(time.Millisecond)
+	// no metrics are set, so nothing is counted
+	pdb, _ := newMockDB(t)
+	reporter.start(pdb)
+	time.Sleep(5 * time.Millisecond)
+	reporter.close()
+	reporter.close()
+
+	// a reporter which was never started closes right away
+	newMapRowCountReporter(time.Millisecond).close()
+}
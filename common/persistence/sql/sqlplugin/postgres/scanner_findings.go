@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	insertScannerFindingQuery = `INSERT INTO scanner_findings (finding_id, scan_id, shard_id, domain_id, workflow_id, run_id, result_type, invariant_name, info, info_details, created_time)
+VALUES (:finding_id, :scan_id, :shard_id, :domain_id, :workflow_id, :run_id, :result_type, :invariant_name, :info, :info_details, :created_time)`
+
+	scannerFindingsColumns = `finding_id, scan_id, shard_id, domain_id, workflow_id, run_id, result_type, invariant_name, info, info_details, created_time`
+
+	getScannerFindingsByDomainQuery = `SELECT ` + scannerFindingsColumns + ` FROM scanner_findings
+WHERE domain_id = $1 AND created_time >= $2 AND created_time < $3
+ORDER BY created_time, finding_id
+LIMIT $4`
+
+	getScannerFindingsByShardQuery = `SELECT ` + scannerFindingsColumns + ` FROM scanner_findings
+WHERE shard_id = $1 AND created_time >= $2 AND created_time < $3
+ORDER BY created_time, finding_id
+LIMIT $4`
+
+	deleteScannerFindingsBeforeQuery = `DELETE FROM scanner_findings
+WHERE finding_id IN (
+SELECT finding_id FROM scanner_findings
+WHERE created_time < $1
+LIMIT $2)`
+)
+
+var _ sqlplugin.ScannerFindingsStore = (*db)(nil)
+
+// InsertScannerFinding inserts a row into scanner_findings table, the findings of all shards are stored on the default DB shard
+// so that they can be queried by domain
+func (pdb *db) InsertScannerFinding(ctx context.Context, row *sqlplugin.ScannerFindingsRow) error {
+	finding := *row
+	finding.CreatedTime = pdb.converter.ToPostgresDateTime(finding.CreatedTime)
+	_, err := pdb.driver.NamedExecContext(ctx, sqlplugin.DbDefaultShard, insertScannerFindingQuery, &finding)
+	return err
+}
+
+// SelectScannerFindingsByDomain reads the rows of a domain from scanner_findings table
+func (pdb *db) SelectScannerFindingsByDomain(ctx context.Context, filter *sqlplugin.ScannerFindingsFilter) ([]sqlplugin.ScannerFindingsRow, error) {
+	return pdb.selectScannerFindings(ctx, getScannerFindingsByDomainQuery, filter.DomainID, filter)
+}
+
+// SelectScannerFindingsByShard reads the rows of a history shard from scanner_findings table
+func (pdb *db) SelectScannerFindingsByShard(ctx context.Context, filter *sqlplugin.ScannerFindingsFilter) ([]sqlplugin.ScannerFindingsRow, error) {
+	return pdb.selectScannerFindings(ctx, getScannerFindingsByShardQuery, filter.ShardID, filter)
+}
+
+func (pdb *db) selectScannerFindings(ctx context.Context, query string, key interface{}, filter *sqlplugin.ScannerFindingsFilter) ([]sqlplugin.ScannerFindingsRow, error) {
+	var rows []sqlplugin.ScannerFindingsRow
+	err := pdb.driver.SelectContext(ctx, sqlplugin.DbDefaultShard, &rows, query,
+		key,
+		pdb.converter.ToPostgresDateTime(filter.MinCreatedTime),
+		pdb.converter.ToPostgresDateTime(filter.MaxCreatedTime),
+		filter.PageSize)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].CreatedTime = pdb.converter.FromPostgresDateTime(rows[i].CreatedTime)
+	}
+	return rows, nil
+}
+
+// DeleteScannerFindingsBefore deletes a batch of the rows of scanner_findings table created before the given time, a batch
+// is a statement of its own so that a purge never holds the locks of more than batchSize rows
+func (pdb *db) DeleteScannerFindingsBefore(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	if batchSize < 1 {
+		return 0, fmt.Errorf("invalid batchSize %v, it must be positive", batchSize)
+	}
+	result, err := pdb.driver.ExecContext(ctx, sqlplugin.DbDefaultShard, deleteScannerFindingsBeforeQuery, pdb.converter.ToPostgresDateTime(before), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestScannerFindings(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := sqlplugin.ScannerFindingsRow{ShardID: 7, DomainID: domainID, WorkflowID: "wid", ResultType: "corrupted", CreatedTime: created}
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	sent := &sentStatements{}
+	// the findings of every history shard are on the default DB shard
+	driver.EXPECT().NamedExecContext(gomock.Any(), sqlplugin.DbDefaultShard, gomock.Any(), gomock.Any()).DoAndReturn(sent.namedResult())
+	driver.EXPECT().SelectContext(gomock.Any(), sqlplugin.DbDefaultShard, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(sent.rows([]sqlplugin.ScannerFindingsRow{row})).Times(2)
+
+	require.NoError(t, pdb.InsertScannerFinding(context.Background(), &row))
+	assert.Equal(t, "wid", sent.lastArgs()[0].(*sqlplugin.ScannerFindingsRow).WorkflowID)
+
+	filter := &sqlplugin.ScannerFindingsFilter{DomainID: domainID, ShardID: 7, MinCreatedTime: created, MaxCreatedTime: created.Add(time.Hour), PageSize: 10}
+	rows, err := pdb.SelectScannerFindingsByDomain(context.Background(), filter)
+	require.NoError(t, err)
+	assert.Equal(t, []sqlplugin.ScannerFindingsRow{row}, rows)
+	assert.True(t, strings.Contains(sent.query(), "WHERE domain_id = $1"), sent.query())
+	assert.Equal(t, []interface{}{domainID, created, created.Add(time.Hour), 10}, sent.lastArgs())
+
+	_, err = pdb.SelectScannerFindingsByShard(context.Background(), filter)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(sent.query(), "WHERE shard_id = $1"), sent.query())
+	assert.Equal(t, int64(7), sent.lastArgs()[0])
+}
+
+func TestDeleteScannerFindingsBefore(t *testing.T) {
+	before := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	driver.EXPECT().ExecContext(gomock.Any(), sqlplugin.DbDefaultShard, deleteScannerFindingsBeforeQuery, before, 100).Return(batchResult(3), nil)
+
+	deleted, err := pdb.DeleteScannerFindingsBefore(context.Background(), before, 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	_, err = pdb.DeleteScannerFindingsBefore(context.Background(), before, 0)
+	assert.Error(t, err)
+}
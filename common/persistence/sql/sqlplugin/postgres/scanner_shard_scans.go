@@ -0,0 +1,61 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	replaceIntoShardScanTimestampsQuery = `INSERT INTO scanner_shard_scans (scanner_name, shard_id, last_scanned_time)
+VALUES (:scanner_name, :shard_id, :last_scanned_time)
+ON CONFLICT (scanner_name, shard_id) DO UPDATE SET last_scanned_time = excluded.last_scanned_time`
+
+	getShardScanTimestampsQuery = `SELECT scanner_name, shard_id, last_scanned_time FROM scanner_shard_scans
+WHERE scanner_name = $1`
+)
+
+var _ sqlplugin.ShardScanTimestampsStore = (*db)(nil)
+
+// ReplaceIntoShardScanTimestamps replaces a row in scanner_shard_scans table, the scans of all shards are stored on the
+// default DB shard so that the shards of a scanner can be read at once
+func (pdb *db) ReplaceIntoShardScanTimestamps(ctx context.Context, row *sqlplugin.ShardScanTimestampsRow) error {
+	scan := *row
+	scan.LastScannedTime = pdb.converter.ToPostgresDateTime(scan.LastScannedTime)
+	_, err := pdb.driver.NamedExecContext(ctx, sqlplugin.DbDefaultShard, replaceIntoShardScanTimestampsQuery, &scan)
+	return err
+}
+
+// SelectShardScanTimestamps reads the rows of a scanner from scanner_shard_scans table
+func (pdb *db) SelectShardScanTimestamps(ctx context.Context, scannerName string) ([]sqlplugin.ShardScanTimestampsRow, error) {
+	var rows []sqlplugin.ShardScanTimestampsRow
+	err := pdb.driver.SelectContext(ctx, sqlplugin.DbDefaultShard, &rows, getShardScanTimestampsQuery, scannerName)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].LastScannedTime = pdb.converter.FromPostgresDateTime(rows[i].LastScannedTime)
+	}
+	return rows, nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestShardScanTimestamps(t *testing.T) {
+	scanned := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := sqlplugin.ShardScanTimestampsRow{ScannerName: "executions-scanner", ShardID: 7, LastScannedTime: scanned}
+	pdb, driver := newMockDB(t)
+	pdb.numDBShards = 4
+	// the timestamps of every history shard are on the default DB shard
+	driver.EXPECT().NamedExecContext(gomock.Any(), sqlplugin.DbDefaultShard, gomock.Any(), &row).Return(batchResult(1), nil)
+	driver.EXPECT().SelectContext(gomock.Any(), sqlplugin.DbDefaultShard, gomock.Any(), gomock.Any(), "executions-scanner").
+		DoAndReturn((&sentStatements{}).rows([]sqlplugin.ShardScanTimestampsRow{row}))
+
+	require.NoError(t, pdb.ReplaceIntoShardScanTimestamps(context.Background(), &row))
+	rows, err := pdb.SelectShardScanTimestamps(context.Background(), "executions-scanner")
+	require.NoError(t, err)
+	assert.Equal(t, []sqlplugin.ShardScanTimestampsRow{row}, rows)
+}
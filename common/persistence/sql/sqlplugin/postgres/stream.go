@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// %[1]v is the name of the table
+// %[2]v is the name of the key
+// %[3]v is the value columns, separated by commas
+const getMapPageQueryTemplate = `SELECT %[2]v, %[3]v FROM %[1]v
+WHERE
+shard_id = $1 AND
+domain_id = $2 AND
+workflow_id = $3 AND
+run_id = $4 AND
+%[2]v > $5
+ORDER BY %[2]v
+LIMIT $6`
+
+// defaultStreamFetchSize is the number of rows SelectActivityInfoMapsChan reads at a time unless configured
+const defaultStreamFetchSize = 1000
+
+// streamFetchSize returns the number of rows the streaming Select methods read at a time
+func (pdb *db) streamFetchSize() int {
+	if pdb.opts.streamFetchSize > 0 {
+		return pdb.opts.streamFetchSize
+	}
+	return defaultStreamFetchSize
+}
+
+func makeGetMapPageQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string) string {
+	mustValidateMapColumns(tableName, nonPrimaryKeyColumns, mapKeyName)
+	return fmt.Sprintf(getMapPageQueryTemplate,
+		tableName,
+		mapKeyName,
+		strings.Join(nonPrimaryKeyColumns, ","))
+}
+
+// SelectActivityInfoMapsChan streams the activity_info_maps rows of the execution in filter in schedule_id order.
+// The rows are read a page at a time and each page is only read once the rows before it were received, so
+// cancelling ctx stops the reads. The row channel is closed once the rows are exhausted or the stream fails,
+// the error channel then receives nil or the error and is closed. filter.ScheduleIDs is ignored
+func (pdb *db) SelectActivityInfoMapsChan(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) (<-chan sqlplugin.ActivityInfoMapsRow, <-chan error) {
+	rowCh := make(chan sqlplugin.ActivityInfoMapsRow)
+	errCh := make(chan error, 1)
+	go func() {
+		err := pdb.streamActivityInfoMaps(ctx, filter, rowCh)
+		close(rowCh)
+		errCh <- err
+		close(errCh)
+	}()
+	return rowCh, errCh
+}
+
+func (pdb *db) streamActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter, rowCh chan<- sqlplugin.ActivityInfoMapsRow) (err error) {
+	streamed := 0
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectActivityInfoMapsChan", activityInfoTableName)
+	defer func() { span.finish(streamed, err) }()
+	dbShardID := pdb.mapDBShardID(ctx, int(filter.ShardID))
+	span.setDBShardID(dbShardID)
+	pageSize := pdb.streamFetchSize()
+	// schedule IDs are positive, so the first page starts after 0
+	var lastScheduleID int64
+	for {
+		// a row may still be received after the cancellation, the next page is not read then
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rows := []sqlplugin.ActivityInfoMapsRow{}
+		err := pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, pdb.opts.queries.getActivityInfoMapPageQry,
+			filter.ShardID, filter.DomainID, filter.WorkflowID, filter.RunID, lastScheduleID, pageSize)
+		if err != nil {
+			return &sqlplugin.PersistenceError{Operation: "SelectActivityInfoMapsChan", Err: err}
+		}
+		for i := range rows {
+			rows[i].ShardID = filter.ShardID
+			rows[i].DomainID = filter.DomainID
+			rows[i].WorkflowID = filter.WorkflowID
+			rows[i].RunID = filter.RunID
+			rows[i].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
+		}
+		if err := pdb.afterScanMapRows(ctx, activityInfoTableName, rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			select {
+			case rowCh <- row:
+				streamed++
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if len(rows) < pageSize {
+			return nil
+		}
+		lastScheduleID = rows[len(rows)-1].ScheduleID
+	}
+}
+
+// SelectAllActivityInfoMapsForShard reads the activity_info_maps rows of a shard a batch at a time with the keyset
+// queries of SelectActivityInfoMapsShardCursor, a batch is only read once fn returned for the batch before it, so at
+// most one batch is held in memory regardless of the size of the shard. Each batch is a new slice fn may keep
+func (pdb *db) SelectAllActivityInfoMapsForShard(ctx context.Context, shardID int, batchSize int, fn func([]sqlplugin.ActivityInfoMapsRow) error) (err error) {
+	if batchSize < 1 {
+		return fmt.Errorf("invalid batchSize %v, it must be positive", batchSize)
+	}
+	read := 0
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SelectAllActivityInfoMapsForShard", activityInfoTableName)
+	defer func() { span.finish(read, err) }()
+	dbShardID := pdb.mapDBShardID(ctx, shardID)
+	span.setDBShardID(dbShardID)
+	var last *activityInfoMapsShardCursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var rows []sqlplugin.ActivityInfoMapsRow
+		var err error
+		if last == nil {
+			err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, pdb.opts.queries.getActivityInfoMapsShardFirstPageQry, shardID, batchSize)
+		} else {
+			err = pdb.mapDriver().SelectContext(ctx, dbShardID, &rows, pdb.opts.queries.getActivityInfoMapsShardNextPageQry,
+				shardID, last.DomainID, last.WorkflowID, last.RunID, last.ScheduleID, batchSize)
+		}
+		if err != nil {
+			return &sqlplugin.PersistenceError{Operation: "SelectAllActivityInfoMapsForShard", Err: err}
+		}
+		for i := range rows {
+			rows[i].ShardID = int64(shardID)
+			rows[i].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(rows[i].LastHeartbeatUpdatedTime)
+		}
+		if err := pdb.afterScanMapRows(ctx, activityInfoTableName, rows); err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			read += len(rows)
+			if err := fn(rows); err != nil {
+				return err
+			}
+		}
+		if len(rows) < batchSize {
+			return nil
+		}
+		lastRow := rows[len(rows)-1]
+		last = &activityInfoMapsShardCursor{
+			DomainID:   lastRow.DomainID,
+			WorkflowID: lastRow.WorkflowID,
+			RunID:      lastRow.RunID,
+			ScheduleID: lastRow.ScheduleID,
+		}
+	}
+}
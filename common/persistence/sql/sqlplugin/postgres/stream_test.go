@@ -0,0 +1,249 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// activityPages answers the page queries of SelectActivityInfoMapsChan from scheduleIDs and counts them
+type activityPages struct {
+	sync.Mutex
+	scheduleIDs []int64
+	pages       int
+}
+
+func (p *activityPages) selectPage(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	p.Lock()
+	defer p.Unlock()
+	p.pages++
+	after, limit := args[4].(int64), args[5].(int)
+	rows := []sqlplugin.ActivityInfoMapsRow{}
+	for _, id := range p.scheduleIDs {
+		if id > after && len(rows) < limit {
+			rows = append(rows, sqlplugin.ActivityInfoMapsRow{ScheduleID: id})
+		}
+	}
+	*dest.(*[]sqlplugin.ActivityInfoMapsRow) = rows
+	return nil
+}
+
+func (p *activityPages) pageCount() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.pages
+}
+
+func TestSelectActivityInfoMapsChan(t *testing.T) {
+	pages := &activityPages{scheduleIDs: []int64{1, 2, 5, 7, 9}}
+	pdb, driver := newMockDB(t)
+	pdb.opts.streamFetchSize = 2
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(pages.selectPage).Times(3)
+	filter := &sqlplugin.ActivityInfoMapsFilter{ShardID: 3, WorkflowID: "wid"}
+
+	rowCh, errCh := pdb.SelectActivityInfoMapsChan(context.Background(), filter)
+	var scheduleIDs []int64
+	for row := range rowCh {
+		assert.Equal(t, int64(3), row.ShardID)
+		assert.Equal(t, "wid", row.WorkflowID)
+		scheduleIDs = append(scheduleIDs, row.ScheduleID)
+	}
+	require.NoError(t, <-errCh)
+	assert.Equal(t, []int64{1, 2, 5, 7, 9}, scheduleIDs)
+	assert.Equal(t, 3, pages.pageCount())
+	_, open := <-errCh
+	assert.False(t, open)
+	// without a configured fetch size the rows are read a default page at a time
+	assert.Equal(t, defaultStreamFetchSize, (&db{}).streamFetchSize())
+}
+
+func TestSelectActivityInfoMapsChanCancel(t *testing.T) {
+	pages := &activityPages{scheduleIDs: []int64{1, 2, 3, 4, 5, 6}}
+	pdb, driver := newMockDB(t)
+	pdb.opts.streamFetchSize = 2
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(pages.selectPage)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rowCh, errCh := pdb.SelectActivityInfoMapsChan(ctx, &sqlplugin.ActivityInfoMapsFilter{ShardID: 3})
+	row := <-rowCh
+	assert.Equal(t, int64(1), row.ScheduleID)
+	cancel()
+	// the rows read before the cancellation may still be received, none after it
+	for range rowCh {
+	}
+	assert.Equal(t, context.Canceled, <-errCh)
+	assert.Equal(t, 1, pages.pageCount(), "no page is read once the context is cancelled")
+}
+
+func TestSelectActivityInfoMapsChanError(t *testing.T) {
+	failure := errors.New("connection reset")
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).Return(failure)
+
+	rowCh, errCh := pdb.SelectActivityInfoMapsChan(context.Background(), &sqlplugin.ActivityInfoMapsFilter{ShardID: 3})
+	_, received := <-rowCh
+	assert.False(t, received)
+	err := <-errCh
+	var persistenceErr *sqlplugin.PersistenceError
+	require.True(t, errors.As(err, &persistenceErr))
+	assert.Equal(t, "SelectActivityInfoMapsChan", persistenceErr.Operation)
+	assert.Equal(t, failure, persistenceErr.Err)
+}
+
+// shardPages answers the keyset queries of a shard from rows, which are in key order, and records the cursors
+type shardPages struct {
+	rows    []sqlplugin.ActivityInfoMapsRow
+	cursors [][]interface{}
+}
+
+func (p *shardPages) selectPage(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
+	start, limit := 0, args[len(args)-1].(int)
+	if len(args) > 2 {
+		p.cursors = append(p.cursors, args[1:5])
+		for i, row := range p.rows {
+			if row.WorkflowID == args[2].(string) && row.ScheduleID == args[4].(int64) {
+				start = i + 1
+			}
+		}
+	}
+	end := start + limit
+	if end > len(p.rows) {
+		end = len(p.rows)
+	}
+	*dest.(*[]sqlplugin.ActivityInfoMapsRow) = append([]sqlplugin.ActivityInfoMapsRow{}, p.rows[start:end]...)
+	return nil
+}
+
+func TestSelectAllActivityInfoMapsForShard(t *testing.T) {
+	pages := &shardPages{rows: []sqlplugin.ActivityInfoMapsRow{
+		{WorkflowID: "a", ScheduleID: 1},
+		{WorkflowID: "a", ScheduleID: 2},
+		{WorkflowID: "b", ScheduleID: 1},
+		{WorkflowID: "c", ScheduleID: 4},
+	}}
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(pages.selectPage).Times(3)
+
+	var batches [][]string
+	err := pdb.SelectAllActivityInfoMapsForShard(context.Background(), 3, 2, func(rows []sqlplugin.ActivityInfoMapsRow) error {
+		var keys []string
+		for _, row := range rows {
+			assert.Equal(t, int64(3), row.ShardID)
+			keys = append(keys, fmt.Sprintf("%v/%v", row.WorkflowID, row.ScheduleID))
+		}
+		batches = append(batches, keys)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a/1", "a/2"}, {"b/1", "c/4"}}, batches)
+	// the batch after a full one is read from the last row, the empty last batch is not passed to fn
+	require.Len(t, pages.cursors, 2)
+	assert.Equal(t, "a", pages.cursors[0][1])
+	assert.Equal(t, int64(2), pages.cursors[0][3])
+	assert.Equal(t, "c", pages.cursors[1][1])
+	assert.Equal(t, int64(4), pages.cursors[1][3])
+}
+
+func TestSelectActivityInfoMapsShardCursor(t *testing.T) {
+	shard := &shardPages{rows: []sqlplugin.ActivityInfoMapsRow{
+		{WorkflowID: "a", ScheduleID: 1},
+		{WorkflowID: "a", ScheduleID: 2},
+		{WorkflowID: "b", ScheduleID: 1},
+		{WorkflowID: "c", ScheduleID: 4},
+		{WorkflowID: "c", ScheduleID: 5},
+	}}
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(shard.selectPage).Times(6)
+
+	var pages [][]string
+	cursor := []byte{}
+	for {
+		rows, next, err := pdb.SelectActivityInfoMapsShardCursor(context.Background(), 3, cursor, 2)
+		require.NoError(t, err)
+		var keys []string
+		for _, row := range rows {
+			assert.Equal(t, int64(3), row.ShardID)
+			keys = append(keys, fmt.Sprintf("%v/%v", row.WorkflowID, row.ScheduleID))
+		}
+		pages = append(pages, keys)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, [][]string{{"a/1", "a/2"}, {"b/1", "c/4"}, {"c/5"}}, pages)
+	// each page after the first resumes after the last row of the page before
+	require.Len(t, shard.cursors, 2)
+	assert.Equal(t, []interface{}{"a", int64(2)}, []interface{}{shard.cursors[0][1], shard.cursors[0][3]})
+	assert.Equal(t, []interface{}{"c", int64(4)}, []interface{}{shard.cursors[1][1], shard.cursors[1][3]})
+
+	// a shard which ends on a full page is exhausted by one more empty page
+	shard.rows = shard.rows[:4]
+	_, next, err := pdb.SelectActivityInfoMapsShardCursor(context.Background(), 3, []byte{}, 2)
+	require.NoError(t, err)
+	rows, next, err := pdb.SelectActivityInfoMapsShardCursor(context.Background(), 3, next, 2)
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+	require.NotNil(t, next)
+	rows, next, err = pdb.SelectActivityInfoMapsShardCursor(context.Background(), 3, next, 2)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	assert.Nil(t, next)
+
+	for _, pageSize := range []int{0, -1} {
+		_, _, err = pdb.SelectActivityInfoMapsShardCursor(context.Background(), 3, nil, pageSize)
+		assert.EqualError(t, err, fmt.Sprintf("invalid pageSize %v, it must be positive", pageSize))
+	}
+}
+
+func TestSelectAllActivityInfoMapsForShardStops(t *testing.T) {
+	pages := &shardPages{rows: []sqlplugin.ActivityInfoMapsRow{{WorkflowID: "a", ScheduleID: 1}, {WorkflowID: "b", ScheduleID: 1}}}
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(pages.selectPage)
+
+	failure := errors.New("analysis failed")
+	calls := 0
+	err := pdb.SelectAllActivityInfoMapsForShard(context.Background(), 3, 1, func([]sqlplugin.ActivityInfoMapsRow) error {
+		calls++
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assert.Equal(t, 1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = pdb.SelectAllActivityInfoMapsForShard(ctx, 3, 1, func([]sqlplugin.ActivityInfoMapsRow) error { return nil })
+	assert.Equal(t, context.Canceled, err)
+
+	err = pdb.SelectAllActivityInfoMapsForShard(context.Background(), 3, 0, func([]sqlplugin.ActivityInfoMapsRow) error { return nil })
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// %[1]v is the name of the table
+// %[2]v is the name of the key
+// %[3]v is the value columns, separated by commas
+// %[4]v is the upsert query of the table, see setKeyInMapQueryTemplate
+// %[5]v is the key and the value columns of previous, separated by commas
+// The previous CTE locks the row before the upsert runs, so a concurrent update of the row either lands before
+// the lock and is returned or waits for the statement to commit. All parts of the statement share a snapshot,
+// previous sees the row as it was before the upsert. The upsert tells whether it inserted the row, which can differ
+// from previous being empty when the row is inserted concurrently, the columns of previous are NULL then.
+const swapKeyInMapQueryTemplate = `WITH previous AS (
+SELECT %[2]v, %[3]v FROM %[1]v
+WHERE
+shard_id = :shard_id AND
+domain_id = :domain_id AND
+workflow_id = :workflow_id AND
+run_id = :run_id AND
+%[2]v = :%[2]v
+FOR UPDATE
+), upserted AS (
+%[4]v` + returningInserted + `
+)
+SELECT upserted.inserted, %[5]v FROM upserted LEFT JOIN previous ON TRUE`
+
+// swappedActivityInfoMapRow is a row returned by swapActivityInfoMapQry, the columns of the replaced row are nil
+// if there was none
+type swappedActivityInfoMapRow struct {
+	Inserted                 bool       `db:"inserted"`
+	ScheduleID               *int64     `db:"schedule_id"`
+	Data                     []byte     `db:"data"`
+	DataEncoding             *string    `db:"data_encoding"`
+	LastHeartbeatDetails     []byte     `db:"last_heartbeat_details"`
+	LastHeartbeatUpdatedTime *time.Time `db:"last_heartbeat_updated_time"`
+}
+
+func makeSwapKeyInMapQry(tableName string, nonPrimaryKeyColumns []string, mapKeyName string, upsertQuery string) string {
+	mustValidateMapColumns(tableName, nonPrimaryKeyColumns, mapKeyName)
+	previousColumns := make([]string, 0, len(nonPrimaryKeyColumns)+1)
+	for _, column := range append([]string{mapKeyName}, nonPrimaryKeyColumns...) {
+		previousColumns = append(previousColumns, "previous."+column)
+	}
+	return fmt.Sprintf(swapKeyInMapQueryTemplate,
+		tableName,
+		mapKeyName,
+		strings.Join(nonPrimaryKeyColumns, ","),
+		upsertQuery,
+		strings.Join(previousColumns, ", "))
+}
+
+// SwapActivityInfoMapRow replaces the row of activity_info_maps with the key of row and returns the row it replaced,
+// or nil if row was inserted. The read and the write are a single statement, so no other write of the row can
+// happen between them. The write is counted like the writes of ReplaceIntoActivityInfoMaps.
+func (pdb *db) SwapActivityInfoMapRow(ctx context.Context, row *sqlplugin.ActivityInfoMapsRow) (result *sqlplugin.ActivityInfoMapsRow, err error) {
+	span := startMapSpan(ctx, pdb.opts.mapMetrics, "SwapActivityInfoMapRow", activityInfoTableName)
+	defer func() { span.finish(1, err) }()
+	if err := pdb.allowMapWrites(ctx, 1, func(int) serialization.UUID { return row.DomainID }); err != nil {
+		return nil, err
+	}
+	dbShardID := pdb.mapDBShardID(ctx, int(row.ShardID))
+	span.setDBShardID(dbShardID)
+	if err := pdb.checkMapRowsLimit(ctx, dbShardID, activityInfoTableName, pdb.opts.queries.countOtherKeysInActivityInfoMapQry, row.ShardID, 1, func(int) mapRowKey {
+		return mapRowKey{domainID: row.DomainID, workflowID: row.WorkflowID, runID: row.RunID, key: row.ScheduleID}
+	}); err != nil {
+		return nil, err
+	}
+	written := *row
+	written.LastHeartbeatUpdatedTime = pdb.converter.ToPostgresDateTime(written.LastHeartbeatUpdatedTime)
+	rows, err := pdb.beforeExecMapRows(ctx, activityInfoTableName, []sqlplugin.ActivityInfoMapsRow{written})
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := pdb.originalDBs[dbShardID].BindNamed(pdb.opts.queries.swapActivityInfoMapQry, rows.([]sqlplugin.ActivityInfoMapsRow)[0])
+	if err != nil {
+		return nil, err
+	}
+	var swapped []swappedActivityInfoMapRow
+	err = pdb.countedMapWrite(ctx, dbShardID, 1, func(pdb *db) error {
+		if err := pdb.mapDriver().SelectContext(ctx, dbShardID, &swapped, query, args...); err != nil {
+			return err
+		}
+		if len(swapped) != 1 {
+			return fmt.Errorf("swap of activity_info_maps row returned %v rows", len(swapped))
+		}
+		event := changedMapRow{
+			Inserted:   swapped[0].Inserted,
+			ShardID:    row.ShardID,
+			DomainID:   row.DomainID,
+			WorkflowID: row.WorkflowID,
+			RunID:      row.RunID,
+			MapKey:     strconv.FormatInt(row.ScheduleID, 10),
+		}.toEvent(activityInfoTableName)
+		result := upsertResult{updated: 1}
+		if swapped[0].Inserted {
+			result = upsertResult{inserted: 1}
+		}
+		return pdb.recordMapWrites(ctx, dbShardID, activityInfoTableName, result, []MapChangeEvent{event})
+	})
+	pdb.activityInfoMapsWritten(row.ShardID, row.DomainID, row.WorkflowID, row.RunID)
+	if err != nil {
+		return nil, &sqlplugin.PersistenceError{Operation: "SwapActivityInfoMapRow", Err: err}
+	}
+	if swapped[0].ScheduleID == nil {
+		return nil, nil
+	}
+	previous := []sqlplugin.ActivityInfoMapsRow{{
+		ShardID:              row.ShardID,
+		DomainID:             row.DomainID,
+		WorkflowID:           row.WorkflowID,
+		RunID:                row.RunID,
+		ScheduleID:           *swapped[0].ScheduleID,
+		Data:                 swapped[0].Data,
+		LastHeartbeatDetails: swapped[0].LastHeartbeatDetails,
+	}}
+	if swapped[0].DataEncoding != nil {
+		previous[0].DataEncoding = *swapped[0].DataEncoding
+	}
+	if swapped[0].LastHeartbeatUpdatedTime != nil {
+		previous[0].LastHeartbeatUpdatedTime = pdb.converter.FromPostgresDateTime(*swapped[0].LastHeartbeatUpdatedTime)
+	}
+	if err := pdb.afterScanMapRows(ctx, activityInfoTableName, previous); err != nil {
+		return nil, err
+	}
+	return &previous[0], nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestSwapActivityInfoMapRow(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	pdb, driver := newMockDB(t)
+	pdb.originalDBs = []*sqlx.DB{xdb}
+	sent := &sentStatements{}
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(sent.rows([]swappedActivityInfoMapRow{{Inserted: true}}))
+	row := &sqlplugin.ActivityInfoMapsRow{
+		ShardID:                  3,
+		DomainID:                 domainID,
+		WorkflowID:               "wid",
+		RunID:                    runID,
+		ScheduleID:               5,
+		Data:                     []byte("new"),
+		DataEncoding:             "thriftrw",
+		LastHeartbeatUpdatedTime: time.Unix(100, 0),
+	}
+
+	// there was no row to replace, the row was inserted
+	previous, err := pdb.SwapActivityInfoMapRow(context.Background(), row)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+	assert.True(t, strings.HasPrefix(sent.query(), "WITH previous AS (\nSELECT schedule_id, data,"), sent.query())
+	assert.Contains(t, sent.query(), "schedule_id = $5\nFOR UPDATE\n), upserted AS (\nINSERT INTO activity_info_maps")
+	assert.True(t, strings.HasSuffix(sent.query(), "RETURNING (xmax = 0) AS inserted\n)\n"+
+		"SELECT upserted.inserted, previous.schedule_id, previous.data, previous.data_encoding, previous.last_heartbeat_details, previous.last_heartbeat_updated_time "+
+		"FROM upserted LEFT JOIN previous ON TRUE"), sent.query())
+	assert.Contains(t, sent.lastArgs(), []byte("new"))
+
+	// the replaced row is returned with the key of row
+	scheduleID, encoding := int64(5), "thriftrw"
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(sent.rows([]swappedActivityInfoMapRow{{ScheduleID: &scheduleID, Data: []byte("old"), DataEncoding: &encoding}}))
+	previous, err = pdb.SwapActivityInfoMapRow(context.Background(), row)
+	require.NoError(t, err)
+	assert.Equal(t, &sqlplugin.ActivityInfoMapsRow{
+		ShardID:      3,
+		DomainID:     domainID,
+		WorkflowID:   "wid",
+		RunID:        runID,
+		ScheduleID:   5,
+		Data:         []byte("old"),
+		DataEncoding: "thriftrw",
+	}, previous)
+	assert.Equal(t, []byte("new"), row.Data)
+}
+
+func TestSwapActivityInfoMapRowSkipsDeletedRows(t *testing.T) {
+	q := newExecutionMapQueries("")
+	assert.NotContains(t, q.swapActivityInfoMapQry, "deleted")
+	q.enableDeferredActivityDeletion("")
+	assert.Contains(t, q.swapActivityInfoMapQry, "WHERE\nNOT deleted AND\nshard_id = :shard_id")
+	assert.Contains(t, q.swapActivityInfoMapQry, "deleted = FALSE\nRETURNING (xmax = 0) AS inserted\n)")
+}
+
+func TestSwapActivityInfoMapRowCounted(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	xdb := sqlx.NewDb(nil, PluginName)
+	xdb.MapperFunc(strcase.ToSnake)
+	// the row is inserted although previous is empty only when it is not inserted concurrently
+	tx, driver := newMockDB(t)
+	tx.originalDBs = []*sqlx.DB{xdb}
+	tx.isTx = true
+	tx.opts.mapRowCounter = true
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn((&sentStatements{}).rows([]swappedActivityInfoMapRow{{Inserted: true}}))
+	driver.EXPECT().ExecContext(gomock.Any(), 0, addMapRowCountQuery, int64(3), domainID, "wid", runID, int64(1)).Return(batchResult(1), nil)
+	sink := &recordingMapChangeSink{}
+	tx.SetMapChangeSink(sink)
+	row := &sqlplugin.ActivityInfoMapsRow{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, ScheduleID: 5}
+
+	_, err := tx.SwapActivityInfoMapRow(context.Background(), row)
+	require.NoError(t, err)
+	assert.Equal(t, []MapChangeEvent{
+		{Table: activityInfoTableName, Type: MapChangeInsert, ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID, Key: "5"},
+	}, tx.txMapChanges)
+
+	// a replaced row is not counted again, even if it was inserted concurrently and previous is empty
+	tx.txMapChanges = nil
+	driver.EXPECT().SelectContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn((&sentStatements{}).rows([]swappedActivityInfoMapRow{{Inserted: false}}))
+	previous, err := tx.SwapActivityInfoMapRow(context.Background(), row)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+	assert.Equal(t, MapChangeUpdate, tx.txMapChanges[0].Type)
+}
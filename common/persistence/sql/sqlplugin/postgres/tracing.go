@@ -0,0 +1,100 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// mapSpan traces a single operation on an execution map table and records its metrics, the zero value is a no-op
+type mapSpan struct {
+	span      opentracing.Span
+	metrics   ExecutionMapMetrics
+	operation string
+	table     string
+	start     time.Time
+}
+
+// startMapSpan starts a child span of the span carried by ctx, the span is a no-op when ctx carries no span,
+// which is always the case when tracing is not configured. The metrics are not recorded when mapMetrics is nil,
+// otherwise the operation is counted as in flight until the span is finished
+func startMapSpan(ctx context.Context, mapMetrics ExecutionMapMetrics, operation string, table string) mapSpan {
+	s := mapSpan{metrics: mapMetrics, operation: operation, table: table}
+	if mapMetrics != nil {
+		mapMetrics.AddInFlight(table, 1)
+		s.start = time.Now()
+	}
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return s
+	}
+	span := parent.Tracer().StartSpan(
+		PluginName+"."+operation,
+		opentracing.ChildOf(parent.Context()),
+		ext.SpanKindRPCClient,
+	)
+	ext.DBType.Set(span, "sql")
+	ext.Component.Set(span, PluginName)
+	span.SetTag("db.table", table)
+	s.span = span
+	return s
+}
+
+func (s mapSpan) setDBShardID(dbShardID int) {
+	if s.span != nil {
+		s.span.SetTag("db.shard_id", dbShardID)
+	}
+}
+
+// finish records the number of rows read or written and marks the span as failed if err is not nil
+func (s mapSpan) finish(rowCount int, err error) {
+	if s.metrics != nil {
+		s.metrics.AddInFlight(s.table, -1)
+		s.metrics.RecordLatency(s.operation, s.table, time.Since(s.start), err)
+		s.metrics.RecordSize(s.operation, s.table, rowCount)
+	}
+	if s.span == nil {
+		return
+	}
+	s.span.SetTag("db.row_count", rowCount)
+	if err != nil {
+		ext.Error.Set(s.span, true)
+		s.span.LogFields(log.Error(err))
+	}
+	s.span.Finish()
+}
+
+func rowsAffected(result sql.Result) int {
+	if result == nil {
+		return 0
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestMapSpans(t *testing.T) {
+	tracer := mocktracer.New()
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	activityCache := newActivityInfoMapsCache(10, time.Minute)
+	activityCache.put(
+		newActivityInfoMapsCacheKey(1, string(domainID), "workflow", string(runID)),
+		[]sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}, {ScheduleID: 6}},
+		activityCache.startRead(),
+	)
+	pdb := &db{numDBShards: 1, opts: dbOptions{activityInfoMapsCache: activityCache}}
+
+	_, err := pdb.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{ShardID: 1, DomainID: domainID, WorkflowID: "workflow", RunID: runID})
+	assert.NoError(t, err)
+	_, err = pdb.ReplaceIntoTimerInfoMaps(ctx, []sqlplugin.TimerInfoMapsRow{{ShardID: 1}, {ShardID: 2}})
+	assert.Error(t, err)
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "postgres.SelectFromActivityInfoMaps", spans[0].OperationName)
+	assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+	assert.Equal(t, "activity_info_maps", spans[0].Tag("db.table"))
+	assert.Equal(t, 2, spans[0].Tag("db.row_count"))
+	assert.Nil(t, spans[0].Tag("error"))
+	assert.Equal(t, "postgres.ReplaceIntoTimerInfoMaps", spans[1].OperationName)
+	assert.Equal(t, "timer_info_maps", spans[1].Tag("db.table"))
+	assert.Equal(t, true, spans[1].Tag("error"))
+	assert.Len(t, spans[1].Logs(), 1)
+
+	// without a span in the context nothing is traced
+	_, err = pdb.ReplaceIntoTimerInfoMaps(context.Background(), []sqlplugin.TimerInfoMapsRow{{ShardID: 1}, {ShardID: 2}})
+	assert.Error(t, err)
+	assert.Len(t, tracer.FinishedSpans(), 2)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// setLocalSynchronousCommitOffQuery relaxes the durability of the commit of the transaction, see sqlplugin.WithRelaxedDurability
+const setLocalSynchronousCommitOffQuery = "SET LOCAL synchronous_commit = off"
+
+const (
+	serializationRetryInitialInterval = 20 * time.Millisecond
+	serializationRetryMaxInterval     = time.Second
+	serializationRetryMaxAttempts     = 5
+)
+
+const (
+	mapTransactionIsolationReadCommitted  = "readCommitted"
+	mapTransactionIsolationRepeatableRead = "repeatableRead"
+	mapTransactionIsolationSerializable   = "serializable"
+)
+
+// mapTransactionIsolationLevels are the isolation levels which can be configured for the map transactions
+var mapTransactionIsolationLevels = map[string]sql.IsolationLevel{
+	"":                                    sql.LevelDefault,
+	mapTransactionIsolationReadCommitted:  sql.LevelReadCommitted,
+	mapTransactionIsolationRepeatableRead: sql.LevelRepeatableRead,
+	mapTransactionIsolationSerializable:   sql.LevelSerializable,
+}
+
+// txExecute runs fn inside a transaction on dbShardID, started with the given isolation level.
+// statementTimeout bounds each statement of the transaction, see beginTxWithStatementTimeout.
+//
+// sql.LevelReadCommitted favors throughput and never fails because of concurrent transactions.
+// sql.LevelSerializable gives correctness-sensitive operations a consistent view, but Postgres aborts
+// the transaction with SQLSTATE 40001 (serialization_failure) when it conflicts with a concurrent one.
+// The only valid reaction is to rerun the whole transaction, so txExecute does that for a bounded number
+// of attempts, each retry drawing from the retry budget of dbShardID when one is configured.
+// fn can be invoked more than once and must not have side effects outside of tx.
+func (pdb *db) txExecute(ctx context.Context, dbShardID int, level sql.IsolationLevel, statementTimeout time.Duration, fn func(tx *db) error) error {
+	policy := backoff.NewExponentialRetryPolicy(serializationRetryInitialInterval)
+	policy.SetMaximumInterval(serializationRetryMaxInterval)
+	policy.SetMaximumAttempts(serializationRetryMaxAttempts)
+	throttleRetry := backoff.NewThrottleRetry(
+		backoff.WithRetryPolicy(policy),
+		backoff.WithRetryableError(pdb.isRetryableMapError(dbShardID)),
+	)
+	return throttleRetry.Do(ctx, func() error {
+		tx, err := pdb.beginTxWithStatementTimeout(ctx, dbShardID, level, statementTimeout)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// beginTxWithStatementTimeout starts a transaction whose statements are aborted by Postgres once they run longer than
+// statementTimeout, which releases the locks they hold even if the context of the caller has no deadline.
+// SET LOCAL only lasts until the transaction ends, so the connection goes back to the pool with its own setting.
+// A statementTimeout of 0 leaves the statement_timeout of the server in effect.
+func (pdb *db) beginTxWithStatementTimeout(ctx context.Context, dbShardID int, level sql.IsolationLevel, statementTimeout time.Duration) (*db, error) {
+	tx, err := pdb.beginTx(ctx, dbShardID, level)
+	if err != nil {
+		return nil, err
+	}
+	if statementTimeout > 0 {
+		// statement_timeout is in milliseconds, rounded up so a sub-millisecond timeout does not disable it
+		millis := (statementTimeout + time.Millisecond - 1) / time.Millisecond
+		if _, err := tx.driver.ExecContext(ctx, dbShardID, fmt.Sprintf("SET LOCAL statement_timeout = %d", millis)); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// WithExecutionTransaction runs fn in a transaction on the dbShardID of the execution row of key, which is where
+// the execution map rows of key are routed to as well. Called on a transaction, fn runs in that transaction
+func (pdb *db) WithExecutionTransaction(ctx context.Context, key *sqlplugin.ExecutionsFilter, fn func(tx sqlplugin.Tx) error) error {
+	if pdb.isTx {
+		return fn(pdb)
+	}
+	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(key.ShardID, pdb.GetTotalNumDBShards())
+	return pdb.txExecute(ctx, dbShardID, pdb.opts.mapTxIsolation, pdb.opts.statementTimeouts.ExecutionWrite, func(tx *db) error {
+		return fn(tx)
+	})
+}
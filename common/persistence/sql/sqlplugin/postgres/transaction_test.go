@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// txConnector is a database/sql connector which only begins and ends transactions, it records the isolation
+// level each transaction was begun with and whether it was committed
+type txConnector struct {
+	isolations []driver.IsolationLevel
+	commits    int
+	rollbacks  int
+}
+
+func (c *txConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &txConn{connector: c}, nil
+}
+
+func (c *txConnector) Driver() driver.Driver {
+	return nil
+}
+
+type txConn struct {
+	connector *txConnector
+}
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *txConn) Close() error {
+	return nil
+}
+
+func (c *txConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *txConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.connector.isolations = append(c.connector.isolations, opts.Isolation)
+	return c, nil
+}
+
+func (c *txConn) Commit() error {
+	c.connector.commits++
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.connector.rollbacks++
+	return nil
+}
+
+func newTxTestDB(t *testing.T, connector *txConnector) *db {
+	pdb, err := newDB([]*sqlx.DB{sqlx.NewDb(sql.OpenDB(connector), PluginName)}, nil, sqlplugin.DbShardUndefined, 1, dbOptions{})
+	require.NoError(t, err)
+	return pdb
+}
+
+func TestTxExecuteRetriesSerializationFailures(t *testing.T) {
+	connector := &txConnector{}
+	pdb := newTxTestDB(t, connector)
+
+	// a transaction aborted by a serialization failure is run again from the start, at the same isolation level
+	attempts := 0
+	err := pdb.txExecute(context.Background(), 0, sql.LevelSerializable, 0, func(tx *db) error {
+		if attempts++; attempts < 3 {
+			return &pq.Error{Code: ErrSerializationFailure}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 1, connector.commits)
+	assert.Equal(t, 2, connector.rollbacks)
+	level := driver.IsolationLevel(sql.LevelSerializable)
+	assert.Equal(t, []driver.IsolationLevel{level, level, level}, connector.isolations)
+
+	// the retries are bounded, the policy allows serializationRetryMaxAttempts retries after the first attempt
+	attempts = 0
+	err = pdb.txExecute(context.Background(), 0, sql.LevelSerializable, 0, func(tx *db) error {
+		attempts++
+		return &pq.Error{Code: ErrSerializationFailure}
+	})
+	assert.True(t, pdb.IsSerializationFailureError(err))
+	assert.Equal(t, serializationRetryMaxAttempts+1, attempts)
+}
+
+func TestTxExecuteDoesNotRetryOtherErrors(t *testing.T) {
+	connector := &txConnector{}
+	pdb := newTxTestDB(t, connector)
+
+	attempts := 0
+	err := pdb.txExecute(context.Background(), 0, sql.LevelDefault, 0, func(tx *db) error {
+		attempts++
+		return &pq.Error{Code: ErrDupEntry}
+	})
+	assert.True(t, pdb.IsDupEntryError(err))
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 0, connector.commits)
+	assert.Equal(t, 1, connector.rollbacks)
+	// the default level leaves the isolation to the connection pool
+	assert.Equal(t, []driver.IsolationLevel{driver.IsolationLevel(sql.LevelDefault)}, connector.isolations)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+const defaultAsyncMapWriteWALMaxSize = 64 * 1024 * 1024
+
+// mapWriteWAL is a write-ahead log of the rows queued by the async map writer. A row is appended as a JSON line
+// together with the shard range it was queued under, and synced, before it is queued, and released once its batch was
+// written. The rows left in the log when the process crashed are replayed when it starts again.
+// The rows are stored in full, with their activity data and heartbeat details, as plaintext JSON on the local disk.
+// The log is only protected by the permissions of its file, which only its owner can read and write.
+//
+// The log is truncated whenever every row in it has been released. If an append would grow it above maxSize, it is
+// rewritten with the rows which are not released yet first, and the append is rejected if it still does not fit.
+type mapWriteWAL struct {
+	sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64
+	nextSeq uint64
+	// pending holds the lines of the rows which were not released yet keyed by sequence number, pendingSize their size
+	pending     map[uint64][]byte
+	pendingSize int64
+}
+
+// walRow is a row of the async map writer with its sequence number in the log, the sequence number is 0 without a log.
+// rangeID is the range of the shard the row was queued under, slots the queue slots held by the row until it is written
+type walRow struct {
+	seq     uint64
+	rangeID int64
+	slots   int64
+	row     sqlplugin.ActivityInfoMapsRow
+}
+
+// walEntry is a line of the log
+type walEntry struct {
+	RangeID int64                          `json:"rangeID"`
+	Row     *sqlplugin.ActivityInfoMapsRow `json:"row"`
+}
+
+// openMapWriteWAL opens the log at path and returns the rows it holds, they are pending until released.
+// A line which cannot be decoded is skipped, like the partial last line of a crash in the middle of a write,
+// and so is a line without a row, which a previous version of the log wrote without its shard range
+func openMapWriteWAL(path string, maxSize int64) (*mapWriteWAL, []walRow, error) {
+	if maxSize == 0 {
+		maxSize = defaultAsyncMapWriteWALMaxSize
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open async map write WAL: %v", err)
+	}
+	l := &mapWriteWAL{path: path, file: file, maxSize: maxSize, pending: make(map[uint64][]byte)}
+	var replay []walRow
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to read async map write WAL: %v", err)
+		}
+		l.size += int64(len(line))
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.Row == nil {
+			continue
+		}
+		l.nextSeq++
+		l.pending[l.nextSeq] = line
+		l.pendingSize += int64(len(line))
+		replay = append(replay, walRow{seq: l.nextSeq, rangeID: entry.RangeID, row: *entry.Row})
+	}
+	// a partial last line is cut off, the next append would extend it otherwise
+	if err := file.Truncate(l.size); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to truncate async map write WAL: %v", err)
+	}
+	return l, replay, nil
+}
+
+// append writes rows queued under rangeID to the log and syncs it, the rows are pending until released.
+// It fails with a LimitExceededError if the log would grow above maxSize with the rows
+func (l *mapWriteWAL) append(rangeID int64, rows []sqlplugin.ActivityInfoMapsRow) ([]walRow, error) {
+	var buf []byte
+	lines := make([][]byte, len(rows))
+	for i := range rows {
+		line, err := json.Marshal(walEntry{RangeID: rangeID, Row: &rows[i]})
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = append(line, '\n')
+		buf = append(buf, lines[i]...)
+	}
+	l.Lock()
+	defer l.Unlock()
+	n := int64(len(buf))
+	if l.size+n > l.maxSize && l.pendingSize < l.size {
+		if err := l.compact(); err != nil {
+			return nil, err
+		}
+	}
+	if l.size+n > l.maxSize {
+		return nil, &types.LimitExceededError{Message: fmt.Sprintf(
+			"async map write WAL is full, %v bytes of pending rows and %v bytes of new rows exceed the max size of %v bytes",
+			l.pendingSize, n, l.maxSize)}
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return nil, fmt.Errorf("failed to write async map write WAL: %v", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync async map write WAL: %v", err)
+	}
+	l.size += n
+	l.pendingSize += n
+	result := make([]walRow, len(rows))
+	for i, row := range rows {
+		l.nextSeq++
+		l.pending[l.nextSeq] = lines[i]
+		result[i] = walRow{seq: l.nextSeq, rangeID: rangeID, row: row}
+	}
+	return result, nil
+}
+
+// release removes rows from the log, the log is truncated once no row is pending
+// and compacted when it is larger than maxSize
+func (l *mapWriteWAL) release(rows []walRow) error {
+	l.Lock()
+	defer l.Unlock()
+	for _, row := range rows {
+		if line, ok := l.pending[row.seq]; ok {
+			l.pendingSize -= int64(len(line))
+			delete(l.pending, row.seq)
+		}
+	}
+	if len(l.pending) == 0 {
+		if err := l.file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate async map write WAL: %v", err)
+		}
+		l.size = 0
+		return nil
+	}
+	if l.size <= l.maxSize {
+		return nil
+	}
+	return l.compact()
+}
+
+// compact replaces the log with a log of the pending rows, the new log is renamed over the old one
+// so a crash leaves either of them behind
+func (l *mapWriteWAL) compact() error {
+	seqs := make([]uint64, 0, len(l.pending))
+	for seq := range l.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	var buf []byte
+	for _, seq := range seqs {
+		buf = append(buf, l.pending[seq]...)
+	}
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_APPEND|os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to compact async map write WAL: %v", err)
+	}
+	if _, err = tmp.Write(buf); err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, l.path)
+	}
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact async map write WAL: %v", err)
+	}
+	l.file.Close()
+	l.file = tmp
+	l.size = int64(len(buf))
+	return nil
+}
+
+// close compacts the log before closing it when rows are left pending, so the rows which were released already are
+// not replayed with them on the next start
+func (l *mapWriteWAL) close() error {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.pending) > 0 {
+		if err := l.compact(); err != nil {
+			l.file.Close()
+			return err
+		}
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestMapWriteWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	wal, replay, err := openMapWriteWAL(path, 0)
+	require.NoError(t, err)
+	assert.Empty(t, replay)
+
+	written, err := wal.append(1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 1, ScheduleID: 6}})
+	require.NoError(t, err)
+	require.NoError(t, wal.release(written[:1]))
+	require.NoError(t, wal.close())
+
+	// a crash in the middle of a write leaves a partial line behind, it is skipped
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"ShardID":1,"Sched`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// the rows which were not released are replayed, the released rows were compacted away when the log was closed
+	wal, replay, err = openMapWriteWAL(path, 0)
+	require.NoError(t, err)
+	var scheduleIDs []int64
+	for _, row := range replay {
+		scheduleIDs = append(scheduleIDs, row.row.ScheduleID)
+	}
+	assert.Equal(t, []int64{6}, scheduleIDs)
+
+	require.NoError(t, wal.release(replay))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+	require.NoError(t, wal.close())
+}
+
+func TestMapWriteWALMaxSize(t *testing.T) {
+	// every row below is written as a line of the same size
+	line, err := json.Marshal(walEntry{RangeID: 1, Row: &sqlplugin.ActivityInfoMapsRow{ShardID: 1, ScheduleID: 5}})
+	require.NoError(t, err)
+	lineSize := int64(len(line) + 1)
+	path := filepath.Join(t.TempDir(), "wal")
+	wal, _, err := openMapWriteWAL(path, 3*lineSize)
+	require.NoError(t, err)
+	written, err := wal.append(1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 1, ScheduleID: 6}, {ShardID: 1, ScheduleID: 7}})
+	require.NoError(t, err)
+
+	// the log is full of pending rows, so it does not grow any further
+	_, err = wal.append(1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 8}})
+	var limitExceeded *types.LimitExceededError
+	assert.ErrorAs(t, err, &limitExceeded)
+	assert.Equal(t, 3*lineSize, wal.size)
+
+	// the released rows are compacted away to make room, the compacted log stays the log the writer appends to
+	require.NoError(t, wal.release(written[:2]))
+	_, err = wal.append(1, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 8}})
+	require.NoError(t, err)
+	assert.Equal(t, 2*lineSize, wal.size)
+	require.NoError(t, wal.close())
+
+	_, replay, err := openMapWriteWAL(path, 1)
+	require.NoError(t, err)
+	require.Len(t, replay, 2)
+	assert.Equal(t, int64(7), replay[0].row.ScheduleID)
+	assert.Equal(t, int64(8), replay[1].row.ScheduleID)
+}
+
+func TestAsyncMapWriterReplaysWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	// the rows of a writer which was never started stand in for the rows left behind by a crash
+	writer := newAsyncMapWriter(4, 0, time.Hour)
+	wal, _, err := openMapWriteWAL(path, 0)
+	require.NoError(t, err)
+	writer.wal = wal
+	pdb := &db{opts: dbOptions{asyncWriter: writer}}
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 3, []sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}, {ShardID: 2, ScheduleID: 5}}))
+	require.NoError(t, pdb.EnqueueReplaceActivityInfoMaps(context.Background(), 3, []sqlplugin.ActivityInfoMapsRow{{ShardID: 3, ScheduleID: 5}}))
+	require.NoError(t, writer.close())
+
+	// shard 1 is still held under the same range, shard 2 was acquired again since and the range of shard 3 cannot be read
+	ranges := map[int64]int64{1: 3, 2: 4}
+	writer = newAsyncMapWriter(4, 0, time.Hour)
+	writer.wal, writer.replay, err = openMapWriteWAL(path, 0)
+	require.NoError(t, err)
+	pdb, driver := newMockDB(t)
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ int, dest interface{}, _ string, args ...interface{}) error {
+			rangeID, ok := ranges[args[0].(int64)]
+			if !ok {
+				return errors.New("shard not readable")
+			}
+			*dest.(*sqlplugin.ShardsRow) = sqlplugin.ShardsRow{ShardID: args[0].(int64), RangeID: rangeID}
+			return nil
+		}).Times(3)
+	kept := writer.fence(pdb, writer.replay)
+	require.Len(t, kept, 1)
+
+	// the kept rows are written like the queued ones
+	tx, driver := newMockDB(t)
+	tx.isTx = true
+	sent := &sentStatements{}
+	driver.EXPECT().GetContext(gomock.Any(), 0, gomock.Any(), readLockShardQry, int64(1)).DoAndReturn(sent.rows(3))
+	driver.EXPECT().NamedExecContext(gomock.Any(), 0, gomock.Any(), gomock.Any()).DoAndReturn(sent.namedResult())
+	assert.Empty(t, writer.flush(tx, kept))
+	assert.Equal(t, []interface{}{[]sqlplugin.ActivityInfoMapsRow{{ShardID: 1, ScheduleID: 5}}}, sent.lastArgs())
+	require.NoError(t, writer.close())
+
+	// the rows of shard 3 are left in the log for the next start
+	_, replay, err := openMapWriteWAL(path, 0)
+	require.NoError(t, err)
+	require.Len(t, replay, 1)
+	assert.Equal(t, int64(3), replay[0].row.ShardID)
+	assert.Equal(t, int64(3), replay[0].rangeID)
+}
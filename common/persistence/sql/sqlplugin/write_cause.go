@@ -0,0 +1,52 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"context"
+)
+
+const (
+	// MapWriteCauseUnknown is the cause of the execution map writes whose context carries none
+	MapWriteCauseUnknown = "unknown"
+	// MapWriteCauseSchedule is the cause of the writes which add the state of newly scheduled or started work
+	MapWriteCauseSchedule = "schedule"
+	// MapWriteCauseHeartbeat is the cause of the writes which record an activity heartbeat
+	MapWriteCauseHeartbeat = "heartbeat"
+	// MapWriteCauseComplete is the cause of the writes which update the state of work as it completes
+	MapWriteCauseComplete = "complete"
+)
+
+type mapWriteCauseContextKey struct{}
+
+// WithMapWriteCause returns a context carrying the cause of the execution map writes done with it, the plugins which
+// emit execution map metrics tag the written rows with it so the write volume can be broken down by cause
+func WithMapWriteCause(ctx context.Context, cause string) context.Context {
+	return context.WithValue(ctx, mapWriteCauseContextKey{}, cause)
+}
+
+// MapWriteCauseFromContext returns the cause set with WithMapWriteCause, or MapWriteCauseUnknown
+func MapWriteCauseFromContext(ctx context.Context) string {
+	if cause, _ := ctx.Value(mapWriteCauseContextKey{}).(string); cause != "" {
+		return cause
+	}
+	return MapWriteCauseUnknown
+}
@@ -23,7 +23,6 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/persistence"
@@ -136,7 +135,7 @@ func getActivityInfoMap(
 		WorkflowID: workflowID,
 		RunID:      runID,
 	})
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		return nil, convertCommonErrors(db, "getActivityInfoMap", "", err)
 	}
 
@@ -282,7 +281,7 @@ func getTimerInfoMap(
 		WorkflowID: workflowID,
 		RunID:      runID,
 	})
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		return nil, convertCommonErrors(db, "getTimerInfoMap", "", err)
 	}
 	ret := make(map[string]*persistence.TimerInfo)
@@ -410,7 +409,7 @@ func getChildExecutionInfoMap(
 		WorkflowID: workflowID,
 		RunID:      runID,
 	})
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		return nil, convertCommonErrors(db, "getChildExecutionInfoMap", "", err)
 	}
 
@@ -535,7 +534,7 @@ func getRequestCancelInfoMap(
 		WorkflowID: workflowID,
 		RunID:      runID,
 	})
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		return nil, convertCommonErrors(db, "getRequestCancelInfoMap", "", err)
 	}
 
@@ -649,7 +648,7 @@ func getSignalInfoMap(
 		WorkflowID: workflowID,
 		RunID:      runID,
 	})
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		return nil, convertCommonErrors(db, "getSignalInfoMap", "", err)
 	}
 
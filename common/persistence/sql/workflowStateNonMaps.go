@@ -88,7 +88,7 @@ func getSignalsRequested(
 		WorkflowID: workflowID,
 		RunID:      runID,
 	})
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
 		return nil, convertCommonErrors(db, "getSignalsRequested", "", err)
 	}
 	var ret = make(map[string]struct{})
@@ -39,7 +39,17 @@ func ConcreteExecutionIterator(
 	retryer persistence.Retryer,
 	pageSize int,
 ) pagination.Iterator {
-	return pagination.NewIterator(ctx, nil, getConcreteExecutions(retryer, pageSize, codec.NewThriftRWEncoder()))
+	return pagination.NewIterator(ctx, nil, getConcreteExecutions(retryer, pageSize, codec.NewThriftRWEncoder(), nil))
+}
+
+// FilteredConcreteExecutionIterator is used to retrieve the Concrete executions accepted by filter.
+func FilteredConcreteExecutionIterator(
+	ctx context.Context,
+	retryer persistence.Retryer,
+	pageSize int,
+	filter ConcreteExecutionFilter,
+) pagination.Iterator {
+	return pagination.NewIterator(ctx, nil, getConcreteExecutions(retryer, pageSize, codec.NewThriftRWEncoder(), filter))
 }
 
 // ConcreteExecution returns a single ConcreteExecution from persistence
@@ -85,6 +95,7 @@ func getConcreteExecutions(
 	pr persistence.Retryer,
 	pageSize int,
 	encoder *codec.ThriftRWEncoder,
+	filter ConcreteExecutionFilter,
 ) pagination.FetchFn {
 	return func(ctx context.Context, token pagination.PageToken) (pagination.Page, error) {
 		req := &persistence.ListConcreteExecutionsRequest{
@@ -97,8 +108,8 @@ func getConcreteExecutions(
 		if err != nil {
 			return pagination.Page{}, err
 		}
-		executions := make([]pagination.Entity, len(resp.Executions))
-		for i, e := range resp.Executions {
+		executions := make([]pagination.Entity, 0, len(resp.Executions))
+		for _, e := range resp.Executions {
 			branchToken, branch, err := getBranchToken(e.ExecutionInfo.BranchToken, e.VersionHistories, encoder)
 			if err != nil {
 				return pagination.Page{}, err
@@ -118,7 +129,10 @@ func getConcreteExecutions(
 			if err := concreteExec.Validate(); err != nil {
 				return pagination.Page{}, err
 			}
-			executions[i] = concreteExec
+			if filter != nil && !filter(concreteExec) {
+				continue
+			}
+			executions = append(executions, concreteExec)
 		}
 		var nextToken interface{} = resp.PageToken
 		if len(resp.PageToken) == 0 {
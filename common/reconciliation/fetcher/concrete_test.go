@@ -23,15 +23,19 @@
 package fetcher
 
 import (
+	"context"
 	"testing"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/uber/cadence/.gen/go/shared"
 	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/codec"
+	"github.com/uber/cadence/common/mocks"
 	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/reconciliation/entity"
 )
 
 const (
@@ -148,6 +152,45 @@ func (p *PersistenceSuite) TestGetBranchToken() {
 	}
 }
 
+func (p *PersistenceSuite) TestGetConcreteExecutions_Filter() {
+	encoder := codec.NewThriftRWEncoder()
+	execManager := &mocks.ExecutionManager{}
+	execManager.On("GetShardID").Return(1)
+	execManager.On("ListConcreteExecutions", mock.Anything, mock.Anything).Return(&persistence.ListConcreteExecutionsResponse{
+		Executions: []*persistence.ListConcreteExecutionsEntity{
+			p.listEntity(encoder, "open-1", persistence.WorkflowStateRunning),
+			p.listEntity(encoder, "closed", persistence.WorkflowStateCompleted),
+			p.listEntity(encoder, "open-2", persistence.WorkflowStateCreated),
+		},
+	}, nil)
+	pr := persistence.NewPersistenceRetryer(execManager, nil, common.CreatePersistenceRetryPolicy())
+
+	page, err := getConcreteExecutions(pr, 10, encoder, nil)(context.Background(), nil)
+	p.NoError(err)
+	p.Len(page.Entities, 3)
+	p.Nil(page.NextToken)
+
+	page, err = getConcreteExecutions(pr, 10, encoder, func(e *entity.ConcreteExecution) bool {
+		return e.State != persistence.WorkflowStateCompleted
+	})(context.Background(), nil)
+	p.NoError(err)
+	p.Len(page.Entities, 2)
+	p.Equal("open-1", page.Entities[0].(*entity.ConcreteExecution).WorkflowID)
+	p.Equal("open-2", page.Entities[1].(*entity.ConcreteExecution).WorkflowID)
+}
+
+func (p *PersistenceSuite) listEntity(encoder *codec.ThriftRWEncoder, workflowID string, state int) *persistence.ListConcreteExecutionsEntity {
+	return &persistence.ListConcreteExecutionsEntity{
+		ExecutionInfo: &persistence.WorkflowExecutionInfo{
+			DomainID:    "domain-id",
+			WorkflowID:  workflowID,
+			RunID:       "run-id",
+			State:       state,
+			BranchToken: p.getValidBranchToken(encoder),
+		},
+	}
+}
+
 func (p *PersistenceSuite) getValidBranchToken(encoder *codec.ThriftRWEncoder) []byte {
 	hb := &shared.HistoryBranch{
 		TreeID:   common.StringPtr(testTreeID),
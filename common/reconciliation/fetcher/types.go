@@ -22,6 +22,10 @@
 
 package fetcher
 
+import (
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
 // ExecutionRequest is used to fetch execution from persistence
 type ExecutionRequest struct {
 	DomainID   string
@@ -29,3 +33,6 @@ type ExecutionRequest struct {
 	RunID      string
 	DomainName string
 }
+
+// ConcreteExecutionFilter returns true for the concrete executions an iterator should return
+type ConcreteExecutionFilter func(*entity.ConcreteExecution) bool
@@ -0,0 +1,208 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	// DanglingChildExecution is a child execution map row of a started child which has no current run,
+	// the dangling children of a corrupted execution are the JSON encoded InfoDetails of its check result
+	DanglingChildExecution struct {
+		InitiatedID int64
+		StartedID   int64
+		DomainID    string
+		WorkflowID  string
+		RunID       string
+	}
+
+	childExecutionsExist struct {
+		db               sqlplugin.ChildExecutionInfoMapsStatusReader
+		parser           serialization.Parser
+		numHistoryShards int
+		limiter          quotas.Limiter
+	}
+)
+
+// NewChildExecutionsExist returns an invariant which checks that every started child recorded in the child execution
+// map of an open concrete execution has a current run. The current runs are looked up in the current_executions table
+// of the shard of each child, numHistoryShards locates the shard. Executions are checked at the rate allowed by limiter.
+// The invariant only reads from the store, the rows of dangling children are not fixed.
+func NewChildExecutionsExist(
+	db sqlplugin.ChildExecutionInfoMapsStatusReader,
+	parser serialization.Parser,
+	numHistoryShards int,
+	limiter quotas.Limiter,
+) Invariant {
+	return &childExecutionsExist{
+		db:               db,
+		parser:           parser,
+		numHistoryShards: numHistoryShards,
+		limiter:          limiter,
+	}
+}
+
+func (c *childExecutionsExist) Check(
+	ctx context.Context,
+	execution interface{},
+) CheckResult {
+	if checkResult := validateCheckContext(ctx, c.Name()); checkResult != nil {
+		return *checkResult
+	}
+
+	concreteExecution, ok := execution.(*entity.ConcreteExecution)
+	if !ok {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   c.Name(),
+			Info:            "failed to check: expected concrete execution",
+		}
+	}
+	// the children of a closed execution are abandoned or closed according to their parent close policy
+	// and may be deleted once their retention expired
+	if !Open(concreteExecution.State) {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   c.Name(),
+		}
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   c.Name(),
+			Info:            "failed to check: rate limiter wait failed",
+			InfoDetails:     err.Error(),
+		}
+	}
+	dangling, err := c.danglingChildExecutions(ctx, &concreteExecution.Execution)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   c.Name(),
+			Info:            "failed to read child execution map rows",
+			InfoDetails:     err.Error(),
+		}
+	}
+	if len(dangling) == 0 {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   c.Name(),
+		}
+	}
+	details, err := json.Marshal(dangling)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   c.Name(),
+			Info:            "failed to encode dangling child executions",
+			InfoDetails:     err.Error(),
+		}
+	}
+	return CheckResult{
+		CheckResultType: CheckResultTypeCorrupted,
+		InvariantName:   c.Name(),
+		Info:            "started child executions have no current run",
+		InfoDetails:     string(details),
+	}
+}
+
+// Fix skips the execution, the rows of dangling children are only reported
+func (c *childExecutionsExist) Fix(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, c.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, c, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	return FixResult{
+		FixResultType: FixResultTypeSkipped,
+		InvariantName: c.Name(),
+		CheckResult:   *checkResult,
+		Info:          "dangling child executions are not fixed by this invariant",
+	}
+}
+
+// Diff returns the result of Fix, which never changes an execution
+func (c *childExecutionsExist) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return c.Fix(ctx, execution)
+}
+
+func (c *childExecutionsExist) Name() Name {
+	return ChildExecutionsExist
+}
+
+// danglingChildExecutions returns the started children of an execution which have no current run. Children which are
+// not started yet can not be looked up, nor can the children of rows which do not record the domain ID of the child
+func (c *childExecutionsExist) danglingChildExecutions(
+	ctx context.Context,
+	execution *entity.Execution,
+) ([]DanglingChildExecution, error) {
+
+	shardID, domainID, runID, err := mapRowsKey(execution)
+	if err != nil {
+		return nil, err
+	}
+	children, err := c.db.SelectChildExecutionInfoWithStatus(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	}, c.numHistoryShards)
+	if err != nil {
+		return nil, err
+	}
+	var dangling []DanglingChildExecution
+	for _, child := range children {
+		if child.Current != nil {
+			continue
+		}
+		info, err := c.parser.ChildExecutionInfoFromBlob(child.Data, child.DataEncoding)
+		if err != nil {
+			return nil, err
+		}
+		if info.StartedID == common.EmptyEventID || info.DomainID == "" || info.StartedWorkflowID == "" {
+			continue
+		}
+		dangling = append(dangling, DanglingChildExecution{
+			InitiatedID: child.InitiatedID,
+			StartedID:   info.StartedID,
+			DomainID:    info.DomainID,
+			WorkflowID:  info.StartedWorkflowID,
+			RunID:       info.StartedRunID.String(),
+		})
+	}
+	return dangling, nil
+}
@@ -0,0 +1,137 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	ChildExecutionsExistSuite struct {
+		*require.Assertions
+		suite.Suite
+	}
+
+	fakeChildExecutionStatusReader struct {
+		children         []sqlplugin.ChildExecutionInfoWithStatus
+		err              error
+		numHistoryShards int
+	}
+)
+
+func TestChildExecutionsExistSuite(t *testing.T) {
+	suite.Run(t, new(ChildExecutionsExistSuite))
+}
+
+func (s *ChildExecutionsExistSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *ChildExecutionsExistSuite) TestCheck() {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	s.NoError(err)
+	childDomainID := "9a7c5e3b-1d2f-4e6a-8b9c-0d1e2f3a4b5c"
+	childRunID := serialization.MustParseUUID("1b2c3d4e-5f6a-4b7c-8d9e-0f1a2b3c4d5e")
+	child := func(initiatedID int64, info *serialization.ChildExecutionInfo, current *sqlplugin.CurrentExecutionsRow) sqlplugin.ChildExecutionInfoWithStatus {
+		blob, err := parser.ChildExecutionInfoToBlob(info)
+		s.NoError(err)
+		return sqlplugin.ChildExecutionInfoWithStatus{
+			ChildExecutionInfoMapsRow: sqlplugin.ChildExecutionInfoMapsRow{InitiatedID: initiatedID, Data: blob.Data, DataEncoding: string(blob.Encoding)},
+			Current:                   current,
+		}
+	}
+	started := &serialization.ChildExecutionInfo{StartedID: 7, DomainID: childDomainID, StartedWorkflowID: "child", StartedRunID: childRunID}
+
+	reader := &fakeChildExecutionStatusReader{children: []sqlplugin.ChildExecutionInfoWithStatus{
+		child(5, started, &sqlplugin.CurrentExecutionsRow{WorkflowID: "child"}),
+		// not started yet
+		child(6, &serialization.ChildExecutionInfo{StartedID: common.EmptyEventID, DomainID: childDomainID, StartedWorkflowID: "pending"}, nil),
+		// the domain of the child is not recorded
+		child(8, &serialization.ChildExecutionInfo{StartedID: 9, DomainNameDEPRECATED: "domain", StartedWorkflowID: "old"}, nil),
+	}}
+	execution := &entity.ConcreteExecution{
+		Execution: entity.Execution{
+			ShardID:    1,
+			DomainID:   "6ddd4ba2-2cb9-4c0b-a4fb-bbe9a2c4c09c",
+			WorkflowID: "workflow-id",
+			RunID:      "4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3",
+			State:      persistence.WorkflowStateRunning,
+		},
+	}
+	i := NewChildExecutionsExist(reader, parser, 16, quotas.NewSimpleRateLimiter(100))
+	s.Equal(CheckResult{CheckResultType: CheckResultTypeHealthy, InvariantName: ChildExecutionsExist}, i.Check(context.Background(), execution))
+	s.Equal(16, reader.numHistoryShards)
+
+	reader.children = append(reader.children, child(10, started, nil))
+	result := i.Check(context.Background(), execution)
+	s.Equal(CheckResultTypeCorrupted, result.CheckResultType)
+	s.Equal("started child executions have no current run", result.Info)
+	var dangling []DanglingChildExecution
+	s.NoError(json.Unmarshal([]byte(result.InfoDetails), &dangling))
+	s.Equal([]DanglingChildExecution{{InitiatedID: 10, StartedID: 7, DomainID: childDomainID, WorkflowID: "child", RunID: childRunID.String()}}, dangling)
+
+	fixResult := i.Fix(context.Background(), execution)
+	s.Equal(FixResultTypeSkipped, fixResult.FixResultType)
+	s.Equal(result, fixResult.CheckResult)
+
+	// the children of closed executions are not checked
+	closed := *execution
+	closed.State = persistence.WorkflowStateCompleted
+	s.Equal(CheckResultTypeHealthy, i.Check(context.Background(), &closed).CheckResultType)
+
+	reader.err = errors.New("select failed")
+	s.Equal(CheckResult{
+		CheckResultType: CheckResultTypeFailed,
+		InvariantName:   ChildExecutionsExist,
+		Info:            "failed to read child execution map rows",
+		InfoDetails:     "select failed",
+	}, i.Check(context.Background(), execution))
+}
+
+func (s *ChildExecutionsExistSuite) TestCheck_NotConcreteExecution() {
+	i := NewChildExecutionsExist(&fakeChildExecutionStatusReader{}, nil, 16, quotas.NewSimpleRateLimiter(100))
+	result := i.Check(context.Background(), &entity.CurrentExecution{})
+	s.Equal(CheckResultTypeFailed, result.CheckResultType)
+}
+
+func (r *fakeChildExecutionStatusReader) SelectChildExecutionInfoWithStatus(
+	_ context.Context,
+	_ *sqlplugin.ChildExecutionInfoMapsFilter,
+	numHistoryShards int,
+) ([]sqlplugin.ChildExecutionInfoWithStatus, error) {
+	r.numHistoryShards = numHistoryShards
+	return r.children, r.err
+}
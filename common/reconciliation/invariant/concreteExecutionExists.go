@@ -172,6 +172,36 @@ func (c *concreteExecutionExists) Fix(
 	}
 }
 
+// Diff returns the current execution row Fix would delete
+func (c *concreteExecutionExists) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, c.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	currentExecution, _ := execution.(*entity.CurrentExecution)
+	var runIDCheckResult *CheckResult
+	if len(currentExecution.CurrentRunID) == 0 {
+		currentExecution, runIDCheckResult = c.validateCurrentRunID(ctx, currentExecution)
+		if runIDCheckResult != nil {
+			return FixResult{
+				FixResultType: FixResultTypeSkipped,
+				CheckResult:   *runIDCheckResult,
+				InvariantName: c.Name(),
+			}
+		}
+	}
+	return diffBeforeFix(ctx, c, currentExecution, func() []Change {
+		return []Change{{
+			Operation: ChangeOperationDelete,
+			Table:     "current_executions",
+			Key:       executionChangeKey(currentExecution.DomainID, currentExecution.WorkflowID, currentExecution.CurrentRunID),
+		}}
+	})
+}
+
 func (c *concreteExecutionExists) Name() Name {
 	return ConcreteExecutionExists
 }
@@ -153,6 +153,14 @@ func (h *historyExists) Fix(
 	return *fixResult
 }
 
+// Diff returns the rows Fix would delete
+func (h *historyExists) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return diffBeforeFix(ctx, h, execution, func() []Change { return DiffDeleteExecution(execution) })
+}
+
 func (h *historyExists) Name() Name {
 	return HistoryExists
 }
@@ -112,6 +112,14 @@ func (idc *inactiveDomainExists) Fix(
 	return *fixResult
 }
 
+// Diff returns the rows Fix would delete
+func (idc *inactiveDomainExists) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return diffBeforeFix(ctx, idc, execution, func() []Change { return DiffDeleteExecution(execution) })
+}
+
 func (idc *inactiveDomainExists) Name() Name {
 	return InactiveDomainExists
 }
@@ -82,6 +82,37 @@ func (i *invariantManager) RunFixes(
 	return result
 }
 
+// RunDiffs runs the diffs of all enabled invariants, an invariant which can not describe its fix is skipped
+// rather than fixed.
+func (i *invariantManager) RunDiffs(
+	ctx context.Context,
+	execution interface{}) ManagerFixResult {
+	result := ManagerFixResult{
+		FixResultType:            FixResultTypeSkipped,
+		DeterminingInvariantName: nil,
+		FixResults:               nil,
+	}
+	for _, iv := range i.invariants {
+		var fixResult FixResult
+		if differ, ok := iv.(Differ); ok {
+			fixResult = differ.Diff(ctx, execution)
+		} else {
+			fixResult = FixResult{
+				FixResultType: FixResultTypeSkipped,
+				InvariantName: iv.Name(),
+				Info:          "skipped diff because invariant does not support dry-run",
+			}
+		}
+		result.FixResults = append(result.FixResults, fixResult)
+		fixResultType, updated := i.nextFixResultType(result.FixResultType, fixResult.FixResultType)
+		result.FixResultType = fixResultType
+		if updated {
+			result.DeterminingInvariantName = &fixResult.InvariantName
+		}
+	}
+	return result
+}
+
 func (i *invariantManager) nextFixResultType(
 	currentState FixResultType,
 	event FixResultType,
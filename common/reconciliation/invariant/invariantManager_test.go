@@ -648,3 +648,39 @@ func (s *InvariantManagerSuite) TestRunFixes() {
 		s.Equal(tc.expected, manager.RunFixes(context.Background(), entity.Execution{}))
 	}
 }
+
+func (s *InvariantManagerSuite) TestRunDiffs() {
+	type differInvariant struct {
+		*MockInvariant
+		*MockDiffer
+	}
+	diff := FixResult{
+		FixResultType: FixResultTypeFixed,
+		InvariantName: Name("first"),
+		CheckResult: CheckResult{
+			CheckResultType: CheckResultTypeCorrupted,
+		},
+		Changes: []Change{{Operation: ChangeOperationDelete, Table: "executions", Key: "key"}},
+	}
+	differ := differInvariant{MockInvariant: NewMockInvariant(s.controller), MockDiffer: NewMockDiffer(s.controller)}
+	differ.MockDiffer.EXPECT().Diff(gomock.Any(), gomock.Any()).Return(diff)
+	// Fix is never called, the invariant without a Diff is skipped
+	other := NewMockInvariant(s.controller)
+	other.EXPECT().Name().Return(Name("second"))
+
+	manager := &invariantManager{
+		invariants: []Invariant{differ, other},
+	}
+	s.Equal(ManagerFixResult{
+		FixResultType:            FixResultTypeFixed,
+		DeterminingInvariantName: NamePtr("first"),
+		FixResults: []FixResult{
+			diff,
+			{
+				FixResultType: FixResultTypeSkipped,
+				InvariantName: Name("second"),
+				Info:          "skipped diff because invariant does not support dry-run",
+			},
+		},
+	}, manager.RunDiffs(context.Background(), entity.Execution{}))
+}
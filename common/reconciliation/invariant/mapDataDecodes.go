@@ -0,0 +1,253 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	// MapDataReader reads the rows of the execution map tables of a SQL store, it is implemented by sqlplugin.DB
+	MapDataReader interface {
+		SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) ([]sqlplugin.ActivityInfoMapsRow, error)
+		SelectFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) ([]sqlplugin.TimerInfoMapsRow, error)
+		SelectFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) ([]sqlplugin.ChildExecutionInfoMapsRow, error)
+		SelectFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) ([]sqlplugin.RequestCancelInfoMapsRow, error)
+		SelectFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) ([]sqlplugin.SignalInfoMapsRow, error)
+	}
+
+	// MapDataDecodeFailure is an execution map row whose data blob does not decode with its data_encoding,
+	// the failures of a corrupted execution are the JSON encoded InfoDetails of its check result
+	MapDataDecodeFailure struct {
+		Table        string
+		Key          string
+		DataEncoding string
+		Error        string
+	}
+
+	mapDataDecodes struct {
+		db      MapDataReader
+		parser  serialization.Parser
+		limiter quotas.Limiter
+	}
+)
+
+// NewMapDataDecodes returns an invariant which decodes the data blob of every execution map row of a concrete execution
+// with the codec named in its data_encoding. Decoding is CPU heavy, so executions are checked at the rate allowed by limiter.
+// The invariant only reads from the store, rows which fail to decode are repaired by the map data encoding fixer.
+func NewMapDataDecodes(
+	db MapDataReader,
+	parser serialization.Parser,
+	limiter quotas.Limiter,
+) Invariant {
+	return &mapDataDecodes{
+		db:      db,
+		parser:  parser,
+		limiter: limiter,
+	}
+}
+
+func (m *mapDataDecodes) Check(
+	ctx context.Context,
+	execution interface{},
+) CheckResult {
+	if checkResult := validateCheckContext(ctx, m.Name()); checkResult != nil {
+		return *checkResult
+	}
+
+	concreteExecution, ok := execution.(*entity.ConcreteExecution)
+	if !ok {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: expected concrete execution",
+		}
+	}
+	if err := m.limiter.Wait(ctx); err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: rate limiter wait failed",
+			InfoDetails:     err.Error(),
+		}
+	}
+	failures, err := m.decodeMapData(ctx, &concreteExecution.Execution)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to read execution map rows",
+			InfoDetails:     err.Error(),
+		}
+	}
+	if len(failures) == 0 {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   m.Name(),
+		}
+	}
+	details, err := json.Marshal(failures)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to encode execution map rows which do not decode",
+			InfoDetails:     err.Error(),
+		}
+	}
+	return CheckResult{
+		CheckResultType: CheckResultTypeCorrupted,
+		InvariantName:   m.Name(),
+		Info:            "execution map rows do not decode with their data encoding",
+		InfoDetails:     string(details),
+	}
+}
+
+// Fix skips the execution, the data_encoding of corrupted rows has to be repaired with the map data encoding fixer
+func (m *mapDataDecodes) Fix(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, m.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, m, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	return FixResult{
+		FixResultType: FixResultTypeSkipped,
+		InvariantName: m.Name(),
+		CheckResult:   *checkResult,
+		Info:          "execution map rows which do not decode are not fixed by this invariant",
+	}
+}
+
+// Diff returns the result of Fix, which never changes an execution
+func (m *mapDataDecodes) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return m.Fix(ctx, execution)
+}
+
+func (m *mapDataDecodes) Name() Name {
+	return MapDataDecodes
+}
+
+// decodeMapData decodes the data blob of every execution map row of an execution and returns the rows which fail to decode
+func (m *mapDataDecodes) decodeMapData(
+	ctx context.Context,
+	execution *entity.Execution,
+) ([]MapDataDecodeFailure, error) {
+
+	shardID, domainID, runID, err := mapRowsKey(execution)
+	if err != nil {
+		return nil, err
+	}
+	var failures []MapDataDecodeFailure
+	check := func(table string, key string, encoding string, err error) {
+		if err != nil {
+			failures = append(failures, MapDataDecodeFailure{Table: table, Key: key, DataEncoding: encoding, Error: err.Error()})
+		}
+	}
+
+	activityRows, err := m.db.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range activityRows {
+		_, err := m.parser.ActivityInfoFromBlob(row.Data, row.DataEncoding)
+		check("activity_info_maps", strconv.FormatInt(row.ScheduleID, 10), row.DataEncoding, err)
+	}
+
+	timerRows, err := m.db.SelectFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range timerRows {
+		_, err := m.parser.TimerInfoFromBlob(row.Data, row.DataEncoding)
+		check("timer_info_maps", row.TimerID, row.DataEncoding, err)
+	}
+
+	childExecutionRows, err := m.db.SelectFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range childExecutionRows {
+		_, err := m.parser.ChildExecutionInfoFromBlob(row.Data, row.DataEncoding)
+		check("child_execution_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, err)
+	}
+
+	requestCancelRows, err := m.db.SelectFromRequestCancelInfoMaps(ctx, &sqlplugin.RequestCancelInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range requestCancelRows {
+		_, err := m.parser.RequestCancelInfoFromBlob(row.Data, row.DataEncoding)
+		check("request_cancel_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, err)
+	}
+
+	signalRows, err := m.db.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range signalRows {
+		_, err := m.parser.SignalInfoFromBlob(row.Data, row.DataEncoding)
+		check("signal_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, err)
+	}
+	return failures, nil
+}
+
+// mapRowsKey returns the shard, domain and run ID columns of the execution map rows of an execution
+func mapRowsKey(execution *entity.Execution) (int64, serialization.UUID, serialization.UUID, error) {
+	parsedDomainID, err := uuid.Parse(execution.DomainID)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	parsedRunID, err := uuid.Parse(execution.RunID)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return int64(execution.ShardID), serialization.UUID(parsedDomainID[:]), serialization.UUID(parsedRunID[:]), nil
+}
@@ -0,0 +1,159 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	MapDataDecodesSuite struct {
+		*require.Assertions
+		suite.Suite
+	}
+
+	fakeMapDataReader struct {
+		activityRows []sqlplugin.ActivityInfoMapsRow
+		timerRows    []sqlplugin.TimerInfoMapsRow
+		err          error
+	}
+)
+
+func TestMapDataDecodesSuite(t *testing.T) {
+	suite.Run(t, new(MapDataDecodesSuite))
+}
+
+func (s *MapDataDecodesSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *MapDataDecodesSuite) TestCheck() {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	s.NoError(err)
+	activityBlob, err := parser.ActivityInfoToBlob(&serialization.ActivityInfo{Version: 1})
+	s.NoError(err)
+	timerBlob, err := parser.TimerInfoToBlob(&serialization.TimerInfo{Version: 1})
+	s.NoError(err)
+
+	testCases := []struct {
+		reader         *fakeMapDataReader
+		expectedResult CheckResult
+	}{
+		{
+			reader: &fakeMapDataReader{
+				activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5, Data: activityBlob.Data, DataEncoding: string(activityBlob.Encoding)}},
+				timerRows:    []sqlplugin.TimerInfoMapsRow{{TimerID: "t1", Data: timerBlob.Data, DataEncoding: string(timerBlob.Encoding)}},
+			},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeHealthy,
+				InvariantName:   MapDataDecodes,
+			},
+		},
+		{
+			reader: &fakeMapDataReader{
+				activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5, Data: activityBlob.Data, DataEncoding: "json"}},
+				timerRows:    []sqlplugin.TimerInfoMapsRow{{TimerID: "t1", Data: []byte{1, 2, 3}, DataEncoding: string(common.EncodingTypeThriftRW)}},
+			},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeCorrupted,
+				InvariantName:   MapDataDecodes,
+				Info:            "execution map rows do not decode with their data encoding",
+			},
+		},
+		{
+			reader: &fakeMapDataReader{err: errors.New("select failed")},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeFailed,
+				InvariantName:   MapDataDecodes,
+				Info:            "failed to read execution map rows",
+				InfoDetails:     "select failed",
+			},
+		},
+	}
+
+	execution := &entity.ConcreteExecution{
+		Execution: entity.Execution{
+			ShardID:    1,
+			DomainID:   "6ddd4ba2-2cb9-4c0b-a4fb-bbe9a2c4c09c",
+			WorkflowID: "workflow-id",
+			RunID:      "4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3",
+		},
+	}
+	for _, tc := range testCases {
+		i := NewMapDataDecodes(tc.reader, parser, quotas.NewSimpleRateLimiter(100))
+		result := i.Check(context.Background(), execution)
+		if tc.expectedResult.CheckResultType != CheckResultTypeCorrupted {
+			s.Equal(tc.expectedResult, result)
+			continue
+		}
+		s.Equal(tc.expectedResult.CheckResultType, result.CheckResultType)
+		s.Equal(tc.expectedResult.Info, result.Info)
+		var failures []MapDataDecodeFailure
+		s.NoError(json.Unmarshal([]byte(result.InfoDetails), &failures))
+		s.Len(failures, 2)
+		s.Equal("activity_info_maps", failures[0].Table)
+		s.Equal("5", failures[0].Key)
+		s.Equal("json", failures[0].DataEncoding)
+		s.Equal("timer_info_maps", failures[1].Table)
+		s.Equal("t1", failures[1].Key)
+		s.Equal(string(common.EncodingTypeThriftRW), failures[1].DataEncoding)
+	}
+}
+
+func (s *MapDataDecodesSuite) TestCheck_NotConcreteExecution() {
+	i := NewMapDataDecodes(&fakeMapDataReader{}, nil, quotas.NewSimpleRateLimiter(100))
+	result := i.Check(context.Background(), &entity.CurrentExecution{})
+	s.Equal(CheckResultTypeFailed, result.CheckResultType)
+}
+
+func (r *fakeMapDataReader) SelectFromActivityInfoMaps(_ context.Context, _ *sqlplugin.ActivityInfoMapsFilter) ([]sqlplugin.ActivityInfoMapsRow, error) {
+	return r.activityRows, r.err
+}
+
+func (r *fakeMapDataReader) SelectFromTimerInfoMaps(_ context.Context, _ *sqlplugin.TimerInfoMapsFilter) ([]sqlplugin.TimerInfoMapsRow, error) {
+	return r.timerRows, r.err
+}
+
+func (r *fakeMapDataReader) SelectFromChildExecutionInfoMaps(_ context.Context, _ *sqlplugin.ChildExecutionInfoMapsFilter) ([]sqlplugin.ChildExecutionInfoMapsRow, error) {
+	return nil, r.err
+}
+
+func (r *fakeMapDataReader) SelectFromRequestCancelInfoMaps(_ context.Context, _ *sqlplugin.RequestCancelInfoMapsFilter) ([]sqlplugin.RequestCancelInfoMapsRow, error) {
+	return nil, r.err
+}
+
+func (r *fakeMapDataReader) SelectFromSignalInfoMaps(_ context.Context, _ *sqlplugin.SignalInfoMapsFilter) ([]sqlplugin.SignalInfoMapsRow, error) {
+	return nil, r.err
+}
@@ -0,0 +1,502 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	// MapDataRewriter reads the rows of the execution map tables of a SQL store and rewrites them in transactions,
+	// it is implemented by sqlplugin.DB
+	MapDataRewriter interface {
+		MapDataReader
+		GetTotalNumDBShards() int
+		BeginTx(ctx context.Context, dbShardID int) (sqlplugin.Tx, error)
+	}
+
+	// MapDataStaleEncoding is an execution map row whose data blob is not in the target encoding of a re-encode pass,
+	// the rows of a corrupted execution are the JSON encoded InfoDetails of its check result
+	MapDataStaleEncoding struct {
+		Table        string
+		Key          string
+		DataEncoding string
+	}
+
+	mapDataReencode struct {
+		db      MapDataRewriter
+		parser  serialization.Parser
+		target  string
+		limiter quotas.Limiter
+	}
+
+	// mapDataReencoder re-encodes the rows of an execution within a transaction
+	mapDataReencoder struct {
+		m           *mapDataReencode
+		rewritten   int
+		undecodable []MapDataDecodeFailure
+	}
+)
+
+// NewMapDataReencode returns an invariant which migrates the execution map rows of a concrete execution to the encoding
+// of parser. Check reports the rows in any other encoding, Fix decodes them with their data_encoding, encodes them with
+// parser and replaces them. The rows already in the target encoding are never touched, so the pass can be interrupted
+// and run again. Fix rewrites the rows of an execution in a transaction which locks its executions row, like the
+// history service does when it writes them, so a concurrent update of the execution is never overwritten with stale
+// data. Executions are checked and fixed at the rate allowed by limiter.
+func NewMapDataReencode(
+	db MapDataRewriter,
+	parser serialization.Parser,
+	target string,
+	limiter quotas.Limiter,
+) Invariant {
+	return &mapDataReencode{
+		db:      db,
+		parser:  parser,
+		target:  target,
+		limiter: limiter,
+	}
+}
+
+func (m *mapDataReencode) Check(
+	ctx context.Context,
+	execution interface{},
+) CheckResult {
+	if checkResult := validateCheckContext(ctx, m.Name()); checkResult != nil {
+		return *checkResult
+	}
+
+	concreteExecution, ok := execution.(*entity.ConcreteExecution)
+	if !ok {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: expected concrete execution",
+		}
+	}
+	if err := m.limiter.Wait(ctx); err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: rate limiter wait failed",
+			InfoDetails:     err.Error(),
+		}
+	}
+	stale, err := m.staleRows(ctx, &concreteExecution.Execution)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to read execution map rows",
+			InfoDetails:     err.Error(),
+		}
+	}
+	if len(stale) == 0 {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   m.Name(),
+		}
+	}
+	details, err := json.Marshal(stale)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to encode execution map rows which are not in the target encoding",
+			InfoDetails:     err.Error(),
+		}
+	}
+	return CheckResult{
+		CheckResultType: CheckResultTypeCorrupted,
+		InvariantName:   m.Name(),
+		Info:            fmt.Sprintf("execution map rows are not in the target encoding %v", m.target),
+		InfoDetails:     string(details),
+	}
+}
+
+// Fix re-encodes the rows of the execution which are not in the target encoding, the rows which do not decode with
+// their data_encoding are left as they are and fail the fix
+func (m *mapDataReencode) Fix(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, m.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, m, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	r := &mapDataReencoder{m: m}
+	if err := r.reencode(ctx, &execution.(*entity.ConcreteExecution).Execution); err != nil {
+		return FixResult{
+			FixResultType: FixResultTypeFailed,
+			InvariantName: m.Name(),
+			CheckResult:   *checkResult,
+			Info:          "failed to re-encode execution map rows",
+			InfoDetails:   err.Error(),
+		}
+	}
+	if len(r.undecodable) > 0 {
+		details, _ := json.Marshal(r.undecodable)
+		return FixResult{
+			FixResultType: FixResultTypeFailed,
+			InvariantName: m.Name(),
+			CheckResult:   *checkResult,
+			Info:          fmt.Sprintf("re-encoded %v execution map rows, %v rows do not decode with their data encoding", r.rewritten, len(r.undecodable)),
+			InfoDetails:   string(details),
+		}
+	}
+	return FixResult{
+		FixResultType: FixResultTypeFixed,
+		InvariantName: m.Name(),
+		CheckResult:   *checkResult,
+		Info:          fmt.Sprintf("re-encoded %v execution map rows", r.rewritten),
+	}
+}
+
+// Diff returns the rows Fix would re-encode. The rows are not decoded, so the rows which would fail the fix because
+// they do not decode with their data_encoding are listed as well
+func (m *mapDataReencode) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, m.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, m, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	var stale []MapDataStaleEncoding
+	if err := json.Unmarshal([]byte(checkResult.InfoDetails), &stale); err != nil {
+		return FixResult{
+			FixResultType: FixResultTypeFailed,
+			InvariantName: m.Name(),
+			CheckResult:   *checkResult,
+			Info:          "failed to decode execution map rows which are not in the target encoding",
+			InfoDetails:   err.Error(),
+		}
+	}
+	changes := make([]Change, 0, len(stale))
+	for _, row := range stale {
+		changes = append(changes, Change{
+			Operation: ChangeOperationUpdate,
+			Table:     row.Table,
+			Key:       row.Key,
+			Fields:    []FieldChange{{Field: "data_encoding", Before: row.DataEncoding, After: m.target}},
+		})
+	}
+	return FixResult{
+		FixResultType: FixResultTypeFixed,
+		InvariantName: m.Name(),
+		CheckResult:   *checkResult,
+		Info:          fmt.Sprintf("would re-encode %v execution map rows", len(changes)),
+		Changes:       changes,
+	}
+}
+
+func (m *mapDataReencode) Name() Name {
+	return MapDataReencode
+}
+
+// staleRows returns the execution map rows of an execution which are not in the target encoding
+func (m *mapDataReencode) staleRows(
+	ctx context.Context,
+	execution *entity.Execution,
+) ([]MapDataStaleEncoding, error) {
+
+	shardID, domainID, runID, err := mapRowsKey(execution)
+	if err != nil {
+		return nil, err
+	}
+	var stale []MapDataStaleEncoding
+	check := func(table string, key string, encoding string) {
+		if encoding != m.target {
+			stale = append(stale, MapDataStaleEncoding{Table: table, Key: key, DataEncoding: encoding})
+		}
+	}
+
+	activityRows, err := m.db.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range activityRows {
+		check("activity_info_maps", strconv.FormatInt(row.ScheduleID, 10), row.DataEncoding)
+	}
+
+	timerRows, err := m.db.SelectFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range timerRows {
+		check("timer_info_maps", row.TimerID, row.DataEncoding)
+	}
+
+	childExecutionRows, err := m.db.SelectFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range childExecutionRows {
+		check("child_execution_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding)
+	}
+
+	requestCancelRows, err := m.db.SelectFromRequestCancelInfoMaps(ctx, &sqlplugin.RequestCancelInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range requestCancelRows {
+		check("request_cancel_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding)
+	}
+
+	signalRows, err := m.db.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range signalRows {
+		check("signal_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding)
+	}
+	return stale, nil
+}
+
+// reencode rewrites the rows of an execution which are not in the target encoding in a transaction which locks the
+// executions row first. An execution which was deleted since it was checked has nothing left to re-encode.
+// The transaction is committed with relaxed durability, a re-encode lost to a crash of the database leaves the rows
+// in their previous encoding, which still decodes, and the next pass re-encodes them again
+func (r *mapDataReencoder) reencode(
+	ctx context.Context,
+	execution *entity.Execution,
+) error {
+
+	shardID, domainID, runID, err := mapRowsKey(execution)
+	if err != nil {
+		return err
+	}
+	ctx = sqlplugin.WithRelaxedDurability(ctx)
+	tx, err := r.m.db.BeginTx(ctx, sqlplugin.GetDBShardIDFromHistoryShardID(int(shardID), r.m.db.GetTotalNumDBShards()))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.WriteLockExecutions(ctx, &sqlplugin.ExecutionsFilter{
+		ShardID: int(shardID), DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	}); err != nil {
+		tx.Rollback() //nolint:errcheck
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if err := r.reencodeRows(ctx, tx, shardID, domainID, execution.WorkflowID, runID); err != nil {
+		tx.Rollback() //nolint:errcheck
+		r.rewritten = 0
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *mapDataReencoder) reencodeRows(
+	ctx context.Context,
+	tx sqlplugin.Tx,
+	shardID int64,
+	domainID serialization.UUID,
+	workflowID string,
+	runID serialization.UUID,
+) error {
+
+	m := r.m
+	activityRows, err := tx.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID,
+	})
+	if err != nil {
+		return err
+	}
+	var activities []sqlplugin.ActivityInfoMapsRow
+	for _, row := range activityRows {
+		if row.DataEncoding == m.target {
+			continue
+		}
+		info, err := m.parser.ActivityInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			r.fail("activity_info_maps", strconv.FormatInt(row.ScheduleID, 10), row.DataEncoding, err)
+			continue
+		}
+		blob, err := m.parser.ActivityInfoToBlob(info)
+		if err != nil {
+			return err
+		}
+		row.Data, row.DataEncoding = blob.Data, string(blob.Encoding)
+		activities = append(activities, row)
+	}
+	if len(activities) > 0 {
+		if _, err := tx.ReplaceIntoActivityInfoMaps(ctx, activities); err != nil {
+			return err
+		}
+		r.rewritten += len(activities)
+	}
+
+	timerRows, err := tx.SelectFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID,
+	})
+	if err != nil {
+		return err
+	}
+	var timers []sqlplugin.TimerInfoMapsRow
+	for _, row := range timerRows {
+		if row.DataEncoding == m.target {
+			continue
+		}
+		info, err := m.parser.TimerInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			r.fail("timer_info_maps", row.TimerID, row.DataEncoding, err)
+			continue
+		}
+		blob, err := m.parser.TimerInfoToBlob(info)
+		if err != nil {
+			return err
+		}
+		row.Data, row.DataEncoding = blob.Data, string(blob.Encoding)
+		timers = append(timers, row)
+	}
+	if len(timers) > 0 {
+		if _, err := tx.ReplaceIntoTimerInfoMaps(ctx, timers); err != nil {
+			return err
+		}
+		r.rewritten += len(timers)
+	}
+
+	childExecutionRows, err := tx.SelectFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID,
+	})
+	if err != nil {
+		return err
+	}
+	var childExecutions []sqlplugin.ChildExecutionInfoMapsRow
+	for _, row := range childExecutionRows {
+		if row.DataEncoding == m.target {
+			continue
+		}
+		info, err := m.parser.ChildExecutionInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			r.fail("child_execution_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, err)
+			continue
+		}
+		blob, err := m.parser.ChildExecutionInfoToBlob(info)
+		if err != nil {
+			return err
+		}
+		row.Data, row.DataEncoding = blob.Data, string(blob.Encoding)
+		childExecutions = append(childExecutions, row)
+	}
+	if len(childExecutions) > 0 {
+		if _, err := tx.ReplaceIntoChildExecutionInfoMaps(ctx, childExecutions); err != nil {
+			return err
+		}
+		r.rewritten += len(childExecutions)
+	}
+
+	requestCancelRows, err := tx.SelectFromRequestCancelInfoMaps(ctx, &sqlplugin.RequestCancelInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID,
+	})
+	if err != nil {
+		return err
+	}
+	var requestCancels []sqlplugin.RequestCancelInfoMapsRow
+	for _, row := range requestCancelRows {
+		if row.DataEncoding == m.target {
+			continue
+		}
+		info, err := m.parser.RequestCancelInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			r.fail("request_cancel_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, err)
+			continue
+		}
+		blob, err := m.parser.RequestCancelInfoToBlob(info)
+		if err != nil {
+			return err
+		}
+		row.Data, row.DataEncoding = blob.Data, string(blob.Encoding)
+		requestCancels = append(requestCancels, row)
+	}
+	if len(requestCancels) > 0 {
+		if _, err := tx.ReplaceIntoRequestCancelInfoMaps(ctx, requestCancels); err != nil {
+			return err
+		}
+		r.rewritten += len(requestCancels)
+	}
+
+	signalRows, err := tx.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: workflowID, RunID: runID,
+	})
+	if err != nil {
+		return err
+	}
+	var signals []sqlplugin.SignalInfoMapsRow
+	for _, row := range signalRows {
+		if row.DataEncoding == m.target {
+			continue
+		}
+		info, err := m.parser.SignalInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			r.fail("signal_info_maps", strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding, err)
+			continue
+		}
+		blob, err := m.parser.SignalInfoToBlob(info)
+		if err != nil {
+			return err
+		}
+		row.Data, row.DataEncoding = blob.Data, string(blob.Encoding)
+		signals = append(signals, row)
+	}
+	if len(signals) > 0 {
+		if _, err := tx.ReplaceIntoSignalInfoMaps(ctx, signals); err != nil {
+			return err
+		}
+		r.rewritten += len(signals)
+	}
+	return nil
+}
+
+func (r *mapDataReencoder) fail(table string, key string, encoding string, err error) {
+	r.undecodable = append(r.undecodable, MapDataDecodeFailure{Table: table, Key: key, DataEncoding: encoding, Error: err.Error()})
+}
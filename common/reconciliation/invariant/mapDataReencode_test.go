@@ -0,0 +1,249 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	MapDataReencodeSuite struct {
+		*require.Assertions
+		suite.Suite
+	}
+
+	fakeMapDataRewriter struct {
+		fakeMapDataReader
+		tx *fakeMapDataTx
+	}
+
+	// fakeMapDataTx implements the methods of sqlplugin.Tx used by the re-encode pass, the embedded Tx is nil
+	fakeMapDataTx struct {
+		sqlplugin.Tx
+		reader     *fakeMapDataReader
+		lockErr    error
+		activities []sqlplugin.ActivityInfoMapsRow
+		timers     []sqlplugin.TimerInfoMapsRow
+		committed  bool
+		rolledBack bool
+		// relaxedDurability is set if the transaction was started with a context marked by WithRelaxedDurability
+		relaxedDurability bool
+	}
+
+	// oldEncodingParser decodes the rows labelled "old" as thriftrw, it stands in for the codec being migrated from
+	oldEncodingParser struct {
+		serialization.Parser
+	}
+)
+
+func TestMapDataReencodeSuite(t *testing.T) {
+	suite.Run(t, new(MapDataReencodeSuite))
+}
+
+func (s *MapDataReencodeSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *MapDataReencodeSuite) TestCheck() {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	s.NoError(err)
+	reader := &fakeMapDataReader{
+		activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5, DataEncoding: string(common.EncodingTypeThriftRW)}},
+		timerRows:    []sqlplugin.TimerInfoMapsRow{{TimerID: "t1", DataEncoding: "json"}},
+	}
+
+	i := NewMapDataReencode(&fakeMapDataRewriter{fakeMapDataReader: *reader}, parser, string(common.EncodingTypeThriftRW), quotas.NewSimpleRateLimiter(100))
+	result := i.Check(context.Background(), s.execution())
+	s.Equal(CheckResultTypeCorrupted, result.CheckResultType)
+	s.Equal(MapDataReencode, result.InvariantName)
+	var stale []MapDataStaleEncoding
+	s.NoError(json.Unmarshal([]byte(result.InfoDetails), &stale))
+	s.Equal([]MapDataStaleEncoding{{Table: "timer_info_maps", Key: "t1", DataEncoding: "json"}}, stale)
+
+	reader.timerRows = nil
+	i = NewMapDataReencode(&fakeMapDataRewriter{fakeMapDataReader: *reader}, parser, string(common.EncodingTypeThriftRW), quotas.NewSimpleRateLimiter(100))
+	result = i.Check(context.Background(), s.execution())
+	s.Equal(CheckResult{CheckResultType: CheckResultTypeHealthy, InvariantName: MapDataReencode}, result)
+}
+
+func (s *MapDataReencodeSuite) TestFix() {
+	thriftParser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	s.NoError(err)
+	activityBlob, err := thriftParser.ActivityInfoToBlob(&serialization.ActivityInfo{Version: 1})
+	s.NoError(err)
+	timerBlob, err := thriftParser.TimerInfoToBlob(&serialization.TimerInfo{Version: 1})
+	s.NoError(err)
+
+	reader := fakeMapDataReader{
+		activityRows: []sqlplugin.ActivityInfoMapsRow{
+			{ScheduleID: 5, Data: activityBlob.Data, DataEncoding: string(common.EncodingTypeThriftRW)},
+			{ScheduleID: 6, Data: activityBlob.Data, DataEncoding: "old"},
+		},
+		timerRows: []sqlplugin.TimerInfoMapsRow{
+			{TimerID: "t1", Data: timerBlob.Data, DataEncoding: "old"},
+			{TimerID: "t2", Data: []byte{1, 2, 3}, DataEncoding: "old"},
+		},
+	}
+	tx := &fakeMapDataTx{reader: &reader}
+	i := NewMapDataReencode(&fakeMapDataRewriter{fakeMapDataReader: reader, tx: tx}, &oldEncodingParser{thriftParser}, string(common.EncodingTypeThriftRW), quotas.NewSimpleRateLimiter(100))
+	result := i.Fix(context.Background(), s.execution())
+	s.Equal(FixResultTypeFailed, result.FixResultType)
+	s.Equal("re-encoded 2 execution map rows, 1 rows do not decode with their data encoding", result.Info)
+	s.True(tx.committed)
+	s.True(tx.relaxedDurability)
+	s.Len(tx.activities, 1)
+	s.Equal(int64(6), tx.activities[0].ScheduleID)
+	s.Equal(string(common.EncodingTypeThriftRW), tx.activities[0].DataEncoding)
+	s.Len(tx.timers, 1)
+	s.Equal("t1", tx.timers[0].TimerID)
+	s.Equal(string(common.EncodingTypeThriftRW), tx.timers[0].DataEncoding)
+	var failures []MapDataDecodeFailure
+	s.NoError(json.Unmarshal([]byte(result.InfoDetails), &failures))
+	s.Len(failures, 1)
+	s.Equal("t2", failures[0].Key)
+}
+
+func (s *MapDataReencodeSuite) TestDiff() {
+	reader := fakeMapDataReader{
+		activityRows: []sqlplugin.ActivityInfoMapsRow{
+			{ScheduleID: 5, DataEncoding: string(common.EncodingTypeThriftRW)},
+			{ScheduleID: 6, DataEncoding: "old"},
+		},
+		timerRows: []sqlplugin.TimerInfoMapsRow{{TimerID: "t1", DataEncoding: "old"}},
+	}
+	tx := &fakeMapDataTx{reader: &reader}
+	i := NewMapDataReencode(&fakeMapDataRewriter{fakeMapDataReader: reader, tx: tx}, nil, string(common.EncodingTypeThriftRW), quotas.NewSimpleRateLimiter(100))
+	result := i.(Differ).Diff(context.Background(), s.execution())
+	s.Equal(FixResultTypeFixed, result.FixResultType)
+	s.Equal([]Change{
+		{
+			Operation: ChangeOperationUpdate,
+			Table:     "activity_info_maps",
+			Key:       "6",
+			Fields:    []FieldChange{{Field: "data_encoding", Before: "old", After: string(common.EncodingTypeThriftRW)}},
+		},
+		{
+			Operation: ChangeOperationUpdate,
+			Table:     "timer_info_maps",
+			Key:       "t1",
+			Fields:    []FieldChange{{Field: "data_encoding", Before: "old", After: string(common.EncodingTypeThriftRW)}},
+		},
+	}, result.Changes)
+	s.False(tx.committed)
+	s.False(tx.rolledBack)
+}
+
+func (s *MapDataReencodeSuite) TestFix_ExecutionDeleted() {
+	reader := fakeMapDataReader{timerRows: []sqlplugin.TimerInfoMapsRow{{TimerID: "t1", DataEncoding: "json"}}}
+	tx := &fakeMapDataTx{reader: &reader, lockErr: sql.ErrNoRows}
+	i := NewMapDataReencode(&fakeMapDataRewriter{fakeMapDataReader: reader, tx: tx}, nil, string(common.EncodingTypeThriftRW), quotas.NewSimpleRateLimiter(100))
+	result := i.Fix(context.Background(), s.execution())
+	s.Equal(FixResultTypeFixed, result.FixResultType)
+	s.Equal("re-encoded 0 execution map rows", result.Info)
+	s.True(tx.rolledBack)
+	s.False(tx.committed)
+}
+
+func (s *MapDataReencodeSuite) execution() *entity.ConcreteExecution {
+	return &entity.ConcreteExecution{
+		Execution: entity.Execution{
+			ShardID:    1,
+			DomainID:   "6ddd4ba2-2cb9-4c0b-a4fb-bbe9a2c4c09c",
+			WorkflowID: "workflow-id",
+			RunID:      "4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3",
+		},
+	}
+}
+
+func (r *fakeMapDataRewriter) GetTotalNumDBShards() int {
+	return 1
+}
+
+func (r *fakeMapDataRewriter) BeginTx(ctx context.Context, _ int) (sqlplugin.Tx, error) {
+	r.tx.relaxedDurability = sqlplugin.RelaxedDurabilityFromContext(ctx)
+	return r.tx, nil
+}
+
+func (t *fakeMapDataTx) WriteLockExecutions(_ context.Context, _ *sqlplugin.ExecutionsFilter) (int, error) {
+	return 0, t.lockErr
+}
+
+func (t *fakeMapDataTx) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) ([]sqlplugin.ActivityInfoMapsRow, error) {
+	return t.reader.SelectFromActivityInfoMaps(ctx, filter)
+}
+
+func (t *fakeMapDataTx) SelectFromTimerInfoMaps(ctx context.Context, filter *sqlplugin.TimerInfoMapsFilter) ([]sqlplugin.TimerInfoMapsRow, error) {
+	return t.reader.SelectFromTimerInfoMaps(ctx, filter)
+}
+
+func (t *fakeMapDataTx) SelectFromChildExecutionInfoMaps(ctx context.Context, filter *sqlplugin.ChildExecutionInfoMapsFilter) ([]sqlplugin.ChildExecutionInfoMapsRow, error) {
+	return t.reader.SelectFromChildExecutionInfoMaps(ctx, filter)
+}
+
+func (t *fakeMapDataTx) SelectFromRequestCancelInfoMaps(ctx context.Context, filter *sqlplugin.RequestCancelInfoMapsFilter) ([]sqlplugin.RequestCancelInfoMapsRow, error) {
+	return t.reader.SelectFromRequestCancelInfoMaps(ctx, filter)
+}
+
+func (t *fakeMapDataTx) SelectFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) ([]sqlplugin.SignalInfoMapsRow, error) {
+	return t.reader.SelectFromSignalInfoMaps(ctx, filter)
+}
+
+func (t *fakeMapDataTx) ReplaceIntoActivityInfoMaps(_ context.Context, rows []sqlplugin.ActivityInfoMapsRow) (sql.Result, error) {
+	t.activities = append(t.activities, rows...)
+	return nil, nil
+}
+
+func (t *fakeMapDataTx) ReplaceIntoTimerInfoMaps(_ context.Context, rows []sqlplugin.TimerInfoMapsRow) (sql.Result, error) {
+	t.timers = append(t.timers, rows...)
+	return nil, nil
+}
+
+func (t *fakeMapDataTx) Commit() error {
+	t.committed = true
+	return nil
+}
+
+func (t *fakeMapDataTx) Rollback() error {
+	t.rolledBack = true
+	return nil
+}
+
+func (p *oldEncodingParser) ActivityInfoFromBlob(data []byte, _ string) (*serialization.ActivityInfo, error) {
+	return p.Parser.ActivityInfoFromBlob(data, string(common.EncodingTypeThriftRW))
+}
+
+func (p *oldEncodingParser) TimerInfoFromBlob(data []byte, _ string) (*serialization.TimerInfo, error) {
+	return p.Parser.TimerInfoFromBlob(data, string(common.EncodingTypeThriftRW))
+}
@@ -0,0 +1,170 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	// MapRowCountMismatch is the JSON encoded InfoDetails of the check result of an execution whose map row count
+	// does not match the rows it has in the execution map tables
+	MapRowCountMismatch struct {
+		MapRowCount    int64
+		ActualRowCount int64
+	}
+
+	mapRowCountMatches struct {
+		db      sqlplugin.MapRowCountReader
+		limiter quotas.Limiter
+	}
+)
+
+// NewMapRowCountMatches returns an invariant which checks that the number of execution map rows kept in the executions
+// row of a concrete execution matches the rows the execution has in the execution map tables. A divergence means
+// rows were lost, or written, without going through the plugin.
+// Executions are checked at the rate allowed by limiter. The invariant only reads from the store, a divergence is
+// not fixed.
+func NewMapRowCountMatches(
+	db sqlplugin.MapRowCountReader,
+	limiter quotas.Limiter,
+) Invariant {
+	return &mapRowCountMatches{
+		db:      db,
+		limiter: limiter,
+	}
+}
+
+func (m *mapRowCountMatches) Check(
+	ctx context.Context,
+	execution interface{},
+) CheckResult {
+	if checkResult := validateCheckContext(ctx, m.Name()); checkResult != nil {
+		return *checkResult
+	}
+
+	concreteExecution, ok := execution.(*entity.ConcreteExecution)
+	if !ok {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: expected concrete execution",
+		}
+	}
+	if err := m.limiter.Wait(ctx); err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: rate limiter wait failed",
+			InfoDetails:     err.Error(),
+		}
+	}
+	shardID, domainID, runID, err := mapRowsKey(&concreteExecution.Execution)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to parse execution key",
+			InfoDetails:     err.Error(),
+		}
+	}
+	row, err := m.db.SelectMapRowCount(ctx, &sqlplugin.ExecutionsFilter{
+		ShardID: int(shardID), DomainID: domainID, WorkflowID: concreteExecution.WorkflowID, RunID: runID,
+	})
+	// the execution was deleted since it was scanned
+	if errors.Is(err, sql.ErrNoRows) {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   m.Name(),
+		}
+	}
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to read map row count",
+			InfoDetails:     err.Error(),
+		}
+	}
+	if row.MapRowCount == row.ActualRowCount {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   m.Name(),
+		}
+	}
+	details, err := json.Marshal(MapRowCountMismatch{MapRowCount: row.MapRowCount, ActualRowCount: row.ActualRowCount})
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to encode map row count mismatch",
+			InfoDetails:     err.Error(),
+		}
+	}
+	return CheckResult{
+		CheckResultType: CheckResultTypeCorrupted,
+		InvariantName:   m.Name(),
+		Info:            "map row count does not match the execution map rows",
+		InfoDetails:     string(details),
+	}
+}
+
+// Fix skips the execution, a divergent map row count is only reported
+func (m *mapRowCountMatches) Fix(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, m.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, m, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	return FixResult{
+		FixResultType: FixResultTypeSkipped,
+		InvariantName: m.Name(),
+		CheckResult:   *checkResult,
+		Info:          "map row count mismatches are not fixed by this invariant",
+	}
+}
+
+// Diff returns the result of Fix, which never changes an execution
+func (m *mapRowCountMatches) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return m.Fix(ctx, execution)
+}
+
+func (m *mapRowCountMatches) Name() Name {
+	return MapRowCountMatches
+}
@@ -0,0 +1,116 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	MapRowCountMatchesSuite struct {
+		*require.Assertions
+		suite.Suite
+	}
+
+	fakeMapRowCountReader struct {
+		row    *sqlplugin.MapRowCountRow
+		err    error
+		filter *sqlplugin.ExecutionsFilter
+	}
+)
+
+func TestMapRowCountMatchesSuite(t *testing.T) {
+	suite.Run(t, new(MapRowCountMatchesSuite))
+}
+
+func (s *MapRowCountMatchesSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *MapRowCountMatchesSuite) TestCheck() {
+	execution := &entity.ConcreteExecution{
+		Execution: entity.Execution{
+			ShardID:    3,
+			DomainID:   "6ddd4ba2-2cb9-4c0b-a4fb-bbe9a2c4c09c",
+			WorkflowID: "workflow-id",
+			RunID:      "4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3",
+			State:      persistence.WorkflowStateCompleted,
+		},
+	}
+	reader := &fakeMapRowCountReader{row: &sqlplugin.MapRowCountRow{MapRowCount: 4, ActualRowCount: 4}}
+	i := NewMapRowCountMatches(reader, quotas.NewSimpleRateLimiter(100))
+	s.Equal(CheckResult{CheckResultType: CheckResultTypeHealthy, InvariantName: MapRowCountMatches}, i.Check(context.Background(), execution))
+	s.Equal(3, reader.filter.ShardID)
+	s.Equal("workflow-id", reader.filter.WorkflowID)
+	s.Equal("4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3", reader.filter.RunID.String())
+
+	reader.row = &sqlplugin.MapRowCountRow{MapRowCount: 4, ActualRowCount: 3}
+	result := i.Check(context.Background(), execution)
+	s.Equal(CheckResultTypeCorrupted, result.CheckResultType)
+	s.Equal("map row count does not match the execution map rows", result.Info)
+	var mismatch MapRowCountMismatch
+	s.NoError(json.Unmarshal([]byte(result.InfoDetails), &mismatch))
+	s.Equal(MapRowCountMismatch{MapRowCount: 4, ActualRowCount: 3}, mismatch)
+
+	fixResult := i.Fix(context.Background(), execution)
+	s.Equal(FixResultTypeSkipped, fixResult.FixResultType)
+	s.Equal(result, fixResult.CheckResult)
+
+	// the execution was deleted since it was scanned
+	reader.row, reader.err = nil, sql.ErrNoRows
+	s.Equal(CheckResultTypeHealthy, i.Check(context.Background(), execution).CheckResultType)
+
+	reader.err = errors.New("select failed")
+	s.Equal(CheckResult{
+		CheckResultType: CheckResultTypeFailed,
+		InvariantName:   MapRowCountMatches,
+		Info:            "failed to read map row count",
+		InfoDetails:     "select failed",
+	}, i.Check(context.Background(), execution))
+}
+
+func (s *MapRowCountMatchesSuite) TestCheck_NotConcreteExecution() {
+	i := NewMapRowCountMatches(&fakeMapRowCountReader{}, quotas.NewSimpleRateLimiter(100))
+	result := i.Check(context.Background(), &entity.CurrentExecution{})
+	s.Equal(CheckResultTypeFailed, result.CheckResultType)
+}
+
+func (r *fakeMapRowCountReader) SelectMapRowCount(
+	_ context.Context,
+	filter *sqlplugin.ExecutionsFilter,
+) (*sqlplugin.MapRowCountRow, error) {
+	r.filter = filter
+	return r.row, r.err
+}
@@ -0,0 +1,370 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	c "github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+	"github.com/uber/cadence/common/types"
+)
+
+const (
+	mapsMatchHistoryPageSize = 1000
+)
+
+type (
+	// MapHistoryDiscrepancy is an execution map row without the history event which created it, the discrepancies
+	// of a corrupted execution are the JSON encoded InfoDetails of its check result
+	MapHistoryDiscrepancy struct {
+		Table string
+		Key   string
+		Error string
+	}
+
+	mapsMatchHistory struct {
+		pr      persistence.Retryer
+		dc      cache.DomainCache
+		db      MapDataReader
+		limiter quotas.Limiter
+	}
+
+	// historyMapKeys are the keys of the execution map rows created by the events of a history
+	historyMapKeys struct {
+		scheduledActivities     map[int64]struct{}
+		startedTimers           map[string]struct{}
+		initiatedChildren       map[int64]struct{}
+		initiatedCancelRequests map[int64]struct{}
+		initiatedSignals        map[int64]struct{}
+	}
+)
+
+// NewMapsMatchHistory returns an invariant which reconciles the execution map rows of a concrete execution with its
+// history: every row must have the event which created it, e.g. an activity row its ActivityTaskScheduled event.
+// The rows are read before the history, so a row created while the execution is checked always finds its event.
+// Reading a whole history is expensive, so executions are checked at the rate allowed by limiter. The invariant only
+// reads from the store.
+func NewMapsMatchHistory(
+	pr persistence.Retryer,
+	dc cache.DomainCache,
+	db MapDataReader,
+	limiter quotas.Limiter,
+) Invariant {
+	return &mapsMatchHistory{
+		pr:      pr,
+		dc:      dc,
+		db:      db,
+		limiter: limiter,
+	}
+}
+
+func (m *mapsMatchHistory) Check(
+	ctx context.Context,
+	execution interface{},
+) CheckResult {
+	if checkResult := validateCheckContext(ctx, m.Name()); checkResult != nil {
+		return *checkResult
+	}
+
+	concreteExecution, ok := execution.(*entity.ConcreteExecution)
+	if !ok {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: expected concrete execution",
+		}
+	}
+	domainName, err := m.dc.GetDomainName(concreteExecution.GetDomainID())
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: expected DomainName",
+			InfoDetails:     err.Error(),
+		}
+	}
+	if err := m.limiter.Wait(ctx); err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to check: rate limiter wait failed",
+			InfoDetails:     err.Error(),
+		}
+	}
+	rows, err := readMapKeys(ctx, m.db, &concreteExecution.Execution)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to read execution map rows",
+			InfoDetails:     err.Error(),
+		}
+	}
+	if rows.empty() {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   m.Name(),
+		}
+	}
+	events, err := m.readHistoryMapKeys(ctx, concreteExecution, domainName)
+	if err != nil {
+		if _, ok := err.(*types.EntityNotExistsError); ok {
+			// a missing history is reported by the history exists invariant
+			return CheckResult{
+				CheckResultType: CheckResultTypeHealthy,
+				InvariantName:   m.Name(),
+				Info:            "determined execution was healthy because its history does not exist",
+			}
+		}
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to read history",
+			InfoDetails:     err.Error(),
+		}
+	}
+	discrepancies := rows.without(events)
+	if len(discrepancies) == 0 {
+		return CheckResult{
+			CheckResultType: CheckResultTypeHealthy,
+			InvariantName:   m.Name(),
+		}
+	}
+	details, err := json.Marshal(discrepancies)
+	if err != nil {
+		return CheckResult{
+			CheckResultType: CheckResultTypeFailed,
+			InvariantName:   m.Name(),
+			Info:            "failed to encode execution map rows which do not match history",
+			InfoDetails:     err.Error(),
+		}
+	}
+	return CheckResult{
+		CheckResultType: CheckResultTypeCorrupted,
+		InvariantName:   m.Name(),
+		Info:            "execution map rows do not match history",
+		InfoDetails:     string(details),
+	}
+}
+
+// Fix skips the execution, which rows or events are wrong can only be told by looking into the execution
+func (m *mapsMatchHistory) Fix(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	if fixResult := validateFixContext(ctx, m.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, m, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	return FixResult{
+		FixResultType: FixResultTypeSkipped,
+		InvariantName: m.Name(),
+		CheckResult:   *checkResult,
+		Info:          "execution map rows which do not match history are not fixed by this invariant",
+	}
+}
+
+// Diff returns the result of Fix, which never changes an execution
+func (m *mapsMatchHistory) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return m.Fix(ctx, execution)
+}
+
+func (m *mapsMatchHistory) Name() Name {
+	return MapsMatchHistory
+}
+
+// readHistoryMapKeys reads the whole current branch of an execution and returns the keys of the map rows its events create
+func (m *mapsMatchHistory) readHistoryMapKeys(
+	ctx context.Context,
+	execution *entity.ConcreteExecution,
+	domainName string,
+) (*historyMapKeys, error) {
+
+	keys := newHistoryMapKeys()
+	req := &persistence.ReadHistoryBranchRequest{
+		BranchToken: execution.BranchToken,
+		MinEventID:  c.FirstEventID,
+		MaxEventID:  c.EndEventID,
+		PageSize:    mapsMatchHistoryPageSize,
+		ShardID:     c.IntPtr(execution.ShardID),
+		DomainName:  domainName,
+	}
+	for {
+		resp, err := m.pr.ReadHistoryBranch(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range resp.HistoryEvents {
+			keys.add(event)
+		}
+		if len(resp.NextPageToken) == 0 {
+			return keys, nil
+		}
+		req.NextPageToken = resp.NextPageToken
+	}
+}
+
+func newHistoryMapKeys() *historyMapKeys {
+	return &historyMapKeys{
+		scheduledActivities:     make(map[int64]struct{}),
+		startedTimers:           make(map[string]struct{}),
+		initiatedChildren:       make(map[int64]struct{}),
+		initiatedCancelRequests: make(map[int64]struct{}),
+		initiatedSignals:        make(map[int64]struct{}),
+	}
+}
+
+func (k *historyMapKeys) add(event *types.HistoryEvent) {
+	switch event.GetEventType() {
+	case types.EventTypeActivityTaskScheduled:
+		k.scheduledActivities[event.ID] = struct{}{}
+	case types.EventTypeTimerStarted:
+		k.startedTimers[event.GetTimerStartedEventAttributes().GetTimerID()] = struct{}{}
+	case types.EventTypeStartChildWorkflowExecutionInitiated:
+		k.initiatedChildren[event.ID] = struct{}{}
+	case types.EventTypeRequestCancelExternalWorkflowExecutionInitiated:
+		k.initiatedCancelRequests[event.ID] = struct{}{}
+	case types.EventTypeSignalExternalWorkflowExecutionInitiated:
+		k.initiatedSignals[event.ID] = struct{}{}
+	}
+}
+
+func (k *historyMapKeys) empty() bool {
+	return len(k.scheduledActivities) == 0 && len(k.startedTimers) == 0 && len(k.initiatedChildren) == 0 &&
+		len(k.initiatedCancelRequests) == 0 && len(k.initiatedSignals) == 0
+}
+
+// without returns the keys of k which are missing from events, in table and key order
+func (k *historyMapKeys) without(events *historyMapKeys) []MapHistoryDiscrepancy {
+	var discrepancies []MapHistoryDiscrepancy
+	missing := func(keys map[int64]struct{}, eventKeys map[int64]struct{}, table string, eventType types.EventType) {
+		var missingKeys []int64
+		for key := range keys {
+			if _, ok := eventKeys[key]; !ok {
+				missingKeys = append(missingKeys, key)
+			}
+		}
+		sort.Slice(missingKeys, func(i, j int) bool { return missingKeys[i] < missingKeys[j] })
+		for _, key := range missingKeys {
+			discrepancies = append(discrepancies, MapHistoryDiscrepancy{
+				Table: table,
+				Key:   strconv.FormatInt(key, 10),
+				Error: "no " + eventType.String() + " event in history",
+			})
+		}
+	}
+	missing(k.scheduledActivities, events.scheduledActivities, "activity_info_maps", types.EventTypeActivityTaskScheduled)
+	var missingTimers []string
+	for timerID := range k.startedTimers {
+		if _, ok := events.startedTimers[timerID]; !ok {
+			missingTimers = append(missingTimers, timerID)
+		}
+	}
+	sort.Strings(missingTimers)
+	for _, timerID := range missingTimers {
+		discrepancies = append(discrepancies, MapHistoryDiscrepancy{
+			Table: "timer_info_maps",
+			Key:   timerID,
+			Error: "no " + types.EventTypeTimerStarted.String() + " event in history",
+		})
+	}
+	missing(k.initiatedChildren, events.initiatedChildren, "child_execution_info_maps", types.EventTypeStartChildWorkflowExecutionInitiated)
+	missing(k.initiatedCancelRequests, events.initiatedCancelRequests, "request_cancel_info_maps", types.EventTypeRequestCancelExternalWorkflowExecutionInitiated)
+	missing(k.initiatedSignals, events.initiatedSignals, "signal_info_maps", types.EventTypeSignalExternalWorkflowExecutionInitiated)
+	return discrepancies
+}
+
+// readMapKeys returns the keys of the execution map rows of an execution
+func readMapKeys(
+	ctx context.Context,
+	db MapDataReader,
+	execution *entity.Execution,
+) (*historyMapKeys, error) {
+
+	shardID, domainID, runID, err := mapRowsKey(execution)
+	if err != nil {
+		return nil, err
+	}
+	keys := newHistoryMapKeys()
+	activityRows, err := db.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range activityRows {
+		keys.scheduledActivities[row.ScheduleID] = struct{}{}
+	}
+	timerRows, err := db.SelectFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range timerRows {
+		keys.startedTimers[row.TimerID] = struct{}{}
+	}
+	childExecutionRows, err := db.SelectFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range childExecutionRows {
+		keys.initiatedChildren[row.InitiatedID] = struct{}{}
+	}
+	requestCancelRows, err := db.SelectFromRequestCancelInfoMaps(ctx, &sqlplugin.RequestCancelInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range requestCancelRows {
+		keys.initiatedCancelRequests[row.InitiatedID] = struct{}{}
+	}
+	signalRows, err := db.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID, WorkflowID: execution.WorkflowID, RunID: runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range signalRows {
+		keys.initiatedSignals[row.InitiatedID] = struct{}{}
+	}
+	return keys, nil
+}
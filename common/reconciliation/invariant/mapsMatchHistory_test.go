@@ -0,0 +1,192 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package invariant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	c2 "github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/mocks"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+	"github.com/uber/cadence/common/types"
+)
+
+type MapsMatchHistorySuite struct {
+	*require.Assertions
+	suite.Suite
+}
+
+func TestMapsMatchHistorySuite(t *testing.T) {
+	suite.Run(t, new(MapsMatchHistorySuite))
+}
+
+func (s *MapsMatchHistorySuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *MapsMatchHistorySuite) TestCheck() {
+	events := []*types.HistoryEvent{
+		{ID: 5, EventType: types.EventTypeActivityTaskScheduled.Ptr()},
+		{ID: 6, EventType: types.EventTypeTimerStarted.Ptr(), TimerStartedEventAttributes: &types.TimerStartedEventAttributes{TimerID: "t1"}},
+	}
+	testCases := []struct {
+		reader           *fakeMapDataReader
+		historyPages     [][]*types.HistoryEvent
+		historyErr       error
+		expectedResult   CheckResult
+		expectedMismatch []MapHistoryDiscrepancy
+	}{
+		{
+			reader: &fakeMapDataReader{},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeHealthy,
+				InvariantName:   MapsMatchHistory,
+			},
+		},
+		{
+			reader: &fakeMapDataReader{
+				activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}},
+				timerRows:    []sqlplugin.TimerInfoMapsRow{{TimerID: "t1"}},
+			},
+			historyPages: [][]*types.HistoryEvent{events[:1], events[1:]},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeHealthy,
+				InvariantName:   MapsMatchHistory,
+			},
+		},
+		{
+			reader: &fakeMapDataReader{
+				activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 9}, {ScheduleID: 5}, {ScheduleID: 7}},
+				timerRows:    []sqlplugin.TimerInfoMapsRow{{TimerID: "t1"}, {TimerID: "t2"}},
+			},
+			historyPages: [][]*types.HistoryEvent{events},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeCorrupted,
+				InvariantName:   MapsMatchHistory,
+				Info:            "execution map rows do not match history",
+			},
+			expectedMismatch: []MapHistoryDiscrepancy{
+				{Table: "activity_info_maps", Key: "7", Error: "no ActivityTaskScheduled event in history"},
+				{Table: "activity_info_maps", Key: "9", Error: "no ActivityTaskScheduled event in history"},
+				{Table: "timer_info_maps", Key: "t2", Error: "no TimerStarted event in history"},
+			},
+		},
+		{
+			reader:     &fakeMapDataReader{activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}}},
+			historyErr: &types.EntityNotExistsError{},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeHealthy,
+				InvariantName:   MapsMatchHistory,
+				Info:            "determined execution was healthy because its history does not exist",
+			},
+		},
+		{
+			reader:     &fakeMapDataReader{activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}}},
+			historyErr: errors.New("read failed"),
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeFailed,
+				InvariantName:   MapsMatchHistory,
+				Info:            "failed to read history",
+				InfoDetails:     "read failed",
+			},
+		},
+		{
+			reader: &fakeMapDataReader{err: errors.New("select failed")},
+			expectedResult: CheckResult{
+				CheckResultType: CheckResultTypeFailed,
+				InvariantName:   MapsMatchHistory,
+				Info:            "failed to read execution map rows",
+				InfoDetails:     "select failed",
+			},
+		},
+	}
+
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+	domainCache := cache.NewMockDomainCache(ctrl)
+	domainCache.EXPECT().GetDomainName(gomock.Any()).Return("test-domain-name", nil).AnyTimes()
+	for _, tc := range testCases {
+		historyManager := &mocks.HistoryV2Manager{}
+		if tc.historyErr != nil {
+			historyManager.On("ReadHistoryBranch", mock.Anything, mock.Anything).Return(nil, tc.historyErr)
+		}
+		for i, page := range tc.historyPages {
+			var nextPageToken []byte
+			if i < len(tc.historyPages)-1 {
+				nextPageToken = []byte{byte(i + 1)}
+			}
+			historyManager.On("ReadHistoryBranch", mock.Anything, mock.Anything).Return(&persistence.ReadHistoryBranchResponse{
+				HistoryEvents: page,
+				NextPageToken: nextPageToken,
+			}, nil).Once()
+		}
+		pr := persistence.NewPersistenceRetryer(&mocks.ExecutionManager{}, historyManager, c2.CreatePersistenceRetryPolicy())
+		i := NewMapsMatchHistory(pr, domainCache, tc.reader, quotas.NewSimpleRateLimiter(100))
+		result := i.Check(context.Background(), mapsMatchHistoryExecution())
+		historyManager.AssertExpectations(s.T())
+		if tc.expectedResult.CheckResultType != CheckResultTypeCorrupted {
+			s.Equal(tc.expectedResult, result)
+			continue
+		}
+		s.Equal(tc.expectedResult.CheckResultType, result.CheckResultType)
+		s.Equal(tc.expectedResult.Info, result.Info)
+		var discrepancies []MapHistoryDiscrepancy
+		s.NoError(json.Unmarshal([]byte(result.InfoDetails), &discrepancies))
+		s.Equal(tc.expectedMismatch, discrepancies)
+	}
+}
+
+func (s *MapsMatchHistorySuite) TestFix_Skipped() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+	domainCache := cache.NewMockDomainCache(ctrl)
+	domainCache.EXPECT().GetDomainName(gomock.Any()).Return("test-domain-name", nil).AnyTimes()
+	historyManager := &mocks.HistoryV2Manager{}
+	historyManager.On("ReadHistoryBranch", mock.Anything, mock.Anything).Return(&persistence.ReadHistoryBranchResponse{}, nil)
+	pr := persistence.NewPersistenceRetryer(&mocks.ExecutionManager{}, historyManager, c2.CreatePersistenceRetryPolicy())
+	reader := &fakeMapDataReader{activityRows: []sqlplugin.ActivityInfoMapsRow{{ScheduleID: 5}}}
+	i := NewMapsMatchHistory(pr, domainCache, reader, quotas.NewSimpleRateLimiter(100))
+	result := i.Fix(context.Background(), mapsMatchHistoryExecution())
+	s.Equal(FixResultTypeSkipped, result.FixResultType)
+	s.Equal(CheckResultTypeCorrupted, result.CheckResult.CheckResultType)
+}
+
+func mapsMatchHistoryExecution() *entity.ConcreteExecution {
+	execution := getOpenConcreteExecution()
+	// the execution map rows are keyed by UUIDs
+	execution.DomainID = "6ddd4ba2-2cb9-4c0b-a4fb-bbe9a2c4c09c"
+	execution.RunID = "4d5a4d1b-7ac8-4a8c-a5b2-81e0a0f5a1c3"
+	return execution
+}
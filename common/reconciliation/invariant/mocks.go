@@ -98,6 +98,43 @@ func (mr *MockInvariantMockRecorder) Name() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockInvariant)(nil).Name))
 }
 
+// MockDiffer is a mock of Differ interface.
+type MockDiffer struct {
+	ctrl     *gomock.Controller
+	recorder *MockDifferMockRecorder
+}
+
+// MockDifferMockRecorder is the mock recorder for MockDiffer.
+type MockDifferMockRecorder struct {
+	mock *MockDiffer
+}
+
+// NewMockDiffer creates a new mock instance.
+func NewMockDiffer(ctrl *gomock.Controller) *MockDiffer {
+	mock := &MockDiffer{ctrl: ctrl}
+	mock.recorder = &MockDifferMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDiffer) EXPECT() *MockDifferMockRecorder {
+	return m.recorder
+}
+
+// Diff mocks base method.
+func (m *MockDiffer) Diff(arg0 context.Context, arg1 interface{}) FixResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Diff", arg0, arg1)
+	ret0, _ := ret[0].(FixResult)
+	return ret0
+}
+
+// Diff indicates an expected call of Diff.
+func (mr *MockDifferMockRecorder) Diff(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Diff", reflect.TypeOf((*MockDiffer)(nil).Diff), arg0, arg1)
+}
+
 // MockManager is a mock of Manager interface.
 type MockManager struct {
 	ctrl     *gomock.Controller
@@ -135,6 +172,20 @@ func (mr *MockManagerMockRecorder) RunChecks(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunChecks", reflect.TypeOf((*MockManager)(nil).RunChecks), arg0, arg1)
 }
 
+// RunDiffs mocks base method.
+func (m *MockManager) RunDiffs(arg0 context.Context, arg1 interface{}) ManagerFixResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunDiffs", arg0, arg1)
+	ret0, _ := ret[0].(ManagerFixResult)
+	return ret0
+}
+
+// RunDiffs indicates an expected call of RunDiffs.
+func (mr *MockManagerMockRecorder) RunDiffs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunDiffs", reflect.TypeOf((*MockManager)(nil).RunDiffs), arg0, arg1)
+}
+
 // RunFixes mocks base method.
 func (m *MockManager) RunFixes(arg0 context.Context, arg1 interface{}) ManagerFixResult {
 	m.ctrl.T.Helper()
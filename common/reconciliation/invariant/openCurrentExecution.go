@@ -151,6 +151,14 @@ func (o *openCurrentExecution) Fix(
 	return *fixResult
 }
 
+// Diff returns the rows Fix would delete
+func (o *openCurrentExecution) Diff(
+	ctx context.Context,
+	execution interface{},
+) FixResult {
+	return diffBeforeFix(ctx, o, execution, func() []Change { return DiffDeleteExecution(execution) })
+}
+
 func (o *openCurrentExecution) Name() Name {
 	return OpenCurrentExecution
 }
@@ -24,6 +24,7 @@ package invariant
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/uber/cadence/common/cache"
 	"github.com/uber/cadence/common/persistence"
@@ -160,6 +161,43 @@ func (h *TimerInvalid) Fix(
 	}
 }
 
+// Diff returns the timer task Fix would complete
+func (h *TimerInvalid) Diff(
+	ctx context.Context,
+	e interface{},
+) FixResult {
+
+	if fixResult := validateFixContext(ctx, h.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, h, e)
+	if fixResult != nil {
+		return *fixResult
+	}
+
+	timer, _ := e.(*entity.Timer)
+
+	if timer.TaskType != persistence.TaskTypeUserTimer {
+		return FixResult{
+			FixResultType: FixResultTypeSkipped,
+			InvariantName: h.Name(),
+			Info:          "timer is not a TaskTypeUserTimer",
+		}
+	}
+
+	return FixResult{
+		FixResultType: FixResultTypeFixed,
+		InvariantName: h.Name(),
+		CheckResult:   *checkResult,
+		Changes: []Change{{
+			Operation: ChangeOperationDelete,
+			Table:     "timer_tasks",
+			Key:       fmt.Sprintf("%v/%v", timer.VisibilityTimestamp.UnixNano(), timer.TaskID),
+		}},
+	}
+}
+
 func (h *TimerInvalid) Name() Name {
 	return "TimerInvalid"
 }
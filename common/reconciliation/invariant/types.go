@@ -53,6 +53,29 @@ const (
 	// ConcreteExecutionExists asserts that an open current execution must have a valid concrete execution
 	ConcreteExecutionExists Name = "concrete_execution_exists"
 
+	// MapDataDecodes asserts that the data blob of every execution map row of a concrete execution decodes with its data encoding
+	MapDataDecodes Name = "map_data_decodes"
+
+	// MapsMatchHistory asserts that every execution map row of a concrete execution has the history event which created it
+	MapsMatchHistory Name = "maps_match_history"
+
+	// MapDataReencode asserts that the data blob of every execution map row of a concrete execution is in the target
+	// encoding of a re-encode pass, its fix re-encodes the rows which are not
+	MapDataReencode Name = "map_data_reencode"
+
+	// ChildExecutionsExist asserts that every started child in the child execution map of an open concrete execution
+	// has a current run
+	ChildExecutionsExist Name = "child_executions_exist"
+
+	// MapRowCountMatches asserts that the number of execution map rows kept in the executions row of a concrete
+	// execution matches the rows of the execution in the execution map tables
+	MapRowCountMatches Name = "map_row_count_matches"
+
+	// ChangeOperationDelete indicates that a fix deletes a row
+	ChangeOperationDelete ChangeOperation = "delete"
+	// ChangeOperationUpdate indicates that a fix updates fields of a row
+	ChangeOperationUpdate ChangeOperation = "update"
+
 	// CollectionMutableState is the collection of invariants relating to mutable state
 	CollectionMutableState Collection = 0
 	// CollectionHistory is the collection  of invariants relating to history
@@ -73,6 +96,9 @@ type (
 
 	// FixResultType is the result type of running an invariant fix
 	FixResultType string
+
+	// ChangeOperation is the operation a fix applies to a row
+	ChangeOperation string
 )
 
 // Invariant represents an invariant of a single execution.
@@ -84,11 +110,19 @@ type Invariant interface {
 	Name() Name
 }
 
+// Differ is implemented by the invariants which can describe the changes their fix would make to an execution
+// without making them. Diff returns the FixResult which Fix would return, with the changes Fix would make in Changes.
+type Differ interface {
+	Diff(context.Context, interface{}) FixResult
+}
+
 // Manager represents a manager of several invariants.
 // It can be used to run a group of invariant checks or fixes.
+// RunDiffs runs the fixes in dry-run mode, it never changes an execution.
 type Manager interface {
 	RunChecks(context.Context, interface{}) ManagerCheckResult
 	RunFixes(context.Context, interface{}) ManagerFixResult
+	RunDiffs(context.Context, interface{}) ManagerFixResult
 }
 
 // ManagerCheckResult is the result of running a list of checks
@@ -114,12 +148,30 @@ type CheckResult struct {
 }
 
 // FixResult is the result of running Fix.
+// Changes is only set by Diff, it lists the rows which Fix would change.
 type FixResult struct {
 	FixResultType FixResultType
 	InvariantName Name
 	CheckResult   CheckResult
 	Info          string
 	InfoDetails   string
+	Changes       []Change
+}
+
+// Change is a row of the persistence store which a fix would change.
+// Fields is only set for updates, it holds the values of the updated fields before and after the fix.
+type Change struct {
+	Operation ChangeOperation
+	Table     string
+	Key       string
+	Fields    []FieldChange
+}
+
+// FieldChange is a field of a row which a fix would update
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
 }
 
 // NamePtr returns a pointer to Name
@@ -24,6 +24,7 @@ package invariant
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/uber/cadence/common/cache"
 	"github.com/uber/cadence/common/persistence"
@@ -123,6 +124,52 @@ func DeleteExecution(
 	}
 }
 
+// DiffDeleteExecution returns the changes DeleteExecution would make,
+// the current execution is only deleted if it still points at the run.
+func DiffDeleteExecution(exec interface{}) []Change {
+	execution := getExecution(exec)
+	return []Change{
+		{
+			Operation: ChangeOperationDelete,
+			Table:     "executions",
+			Key:       executionChangeKey(execution.DomainID, execution.WorkflowID, execution.RunID),
+		},
+		{
+			Operation: ChangeOperationDelete,
+			Table:     "current_executions",
+			Key:       executionChangeKey(execution.DomainID, execution.WorkflowID, execution.RunID),
+		},
+	}
+}
+
+// diffBeforeFix returns the result of Diff for an invariant whose fix makes changes, it is the FixResult Fix
+// would return without attempting the changes
+func diffBeforeFix(
+	ctx context.Context,
+	invariant Invariant,
+	execution interface{},
+	changes func() []Change,
+) FixResult {
+	if fixResult := validateFixContext(ctx, invariant.Name()); fixResult != nil {
+		return *fixResult
+	}
+
+	fixResult, checkResult := checkBeforeFix(ctx, invariant, execution)
+	if fixResult != nil {
+		return *fixResult
+	}
+	return FixResult{
+		FixResultType: FixResultTypeFixed,
+		InvariantName: invariant.Name(),
+		CheckResult:   *checkResult,
+		Changes:       changes(),
+	}
+}
+
+func executionChangeKey(domainID string, workflowID string, runID string) string {
+	return fmt.Sprintf("%v/%v/%v", domainID, workflowID, runID)
+}
+
 func validateCheckContext(
 	ctx context.Context,
 	invariantName Name,
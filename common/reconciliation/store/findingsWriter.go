@@ -0,0 +1,102 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+type (
+	findingsWriter struct {
+		ExecutionWriter
+		scanID   string
+		findings sqlplugin.ScannerFindingsStore
+		now      func() time.Time
+	}
+)
+
+// NewFindingsWriter wraps writer so that every ScanOutputEntity added to it is inserted into the scanner_findings table
+// of findings as well, which makes the findings of scanID queryable with SQL. Flush and FlushedKeys are those of writer
+func NewFindingsWriter(
+	writer ExecutionWriter,
+	scanID string,
+	findings sqlplugin.ScannerFindingsStore,
+) ExecutionWriter {
+	return &findingsWriter{
+		ExecutionWriter: writer,
+		scanID:          scanID,
+		findings:        findings,
+		now:             time.Now,
+	}
+}
+
+// Add adds an entity to the wrapped writer and inserts it into scanner_findings if it is a ScanOutputEntity
+func (fw *findingsWriter) Add(e interface{}) error {
+	if err := fw.ExecutionWriter.Add(e); err != nil {
+		return err
+	}
+	output, ok := e.(ScanOutputEntity)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	return fw.findings.InsertScannerFinding(ctx, fw.newFindingsRow(output))
+}
+
+func (fw *findingsWriter) newFindingsRow(output ScanOutputEntity) *sqlplugin.ScannerFindingsRow {
+	row := &sqlplugin.ScannerFindingsRow{
+		FindingID:   serialization.UUID(uuid.NewRandom()),
+		ScanID:      fw.scanID,
+		ResultType:  string(output.Result.CheckResultType),
+		CreatedTime: fw.now(),
+	}
+	var domainID, runID string
+	switch execution := output.Execution.(type) {
+	case *entity.ConcreteExecution:
+		row.ShardID, domainID, row.WorkflowID, runID = int64(execution.ShardID), execution.DomainID, execution.WorkflowID, execution.RunID
+	case *entity.CurrentExecution:
+		row.ShardID, domainID, row.WorkflowID, runID = int64(execution.ShardID), execution.DomainID, execution.WorkflowID, execution.RunID
+	case *entity.Timer:
+		row.ShardID, domainID, row.WorkflowID, runID = int64(execution.ShardID), execution.DomainID, execution.WorkflowID, execution.RunID
+	}
+	row.DomainID = serialization.UUID(uuid.Parse(domainID))
+	row.RunID = serialization.UUID(uuid.Parse(runID))
+	if output.Result.DeterminingInvariantType != nil {
+		row.InvariantName = string(*output.Result.DeterminingInvariantType)
+	}
+	for _, result := range output.Result.CheckResults {
+		if string(result.InvariantName) == row.InvariantName {
+			row.Info, row.InfoDetails = result.Info, result.InfoDetails
+			break
+		}
+	}
+	return row
+}
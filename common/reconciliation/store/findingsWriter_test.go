@@ -0,0 +1,100 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+	"github.com/uber/cadence/common/reconciliation/invariant"
+)
+
+type fakeFindingsStore struct {
+	sqlplugin.ScannerFindingsStore
+	rows []*sqlplugin.ScannerFindingsRow
+}
+
+func (f *fakeFindingsStore) InsertScannerFinding(ctx context.Context, row *sqlplugin.ScannerFindingsRow) error {
+	f.rows = append(f.rows, row)
+	return nil
+}
+
+func TestFindingsWriter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	inner := NewMockExecutionWriter(ctrl)
+	findings := &fakeFindingsStore{}
+	writer := NewFindingsWriter(inner, "scan-id", findings)
+	now := time.Unix(100, 0)
+	writer.(*findingsWriter).now = func() time.Time { return now }
+
+	domainID := "8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10"
+	runID := "2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b"
+	invariantName := invariant.HistoryExists
+	output := ScanOutputEntity{
+		Execution: &entity.ConcreteExecution{Execution: entity.Execution{ShardID: 3, DomainID: domainID, WorkflowID: "wid", RunID: runID}},
+		Result: invariant.ManagerCheckResult{
+			CheckResultType:          invariant.CheckResultTypeCorrupted,
+			DeterminingInvariantType: &invariantName,
+			CheckResults: []invariant.CheckResult{
+				{CheckResultType: invariant.CheckResultTypeHealthy, InvariantName: invariant.OpenCurrentExecution},
+				{CheckResultType: invariant.CheckResultTypeCorrupted, InvariantName: invariantName, Info: "info", InfoDetails: "details"},
+			},
+		},
+	}
+	inner.EXPECT().Add(output).Return(nil)
+	require.NoError(t, writer.Add(output))
+	require.Len(t, findings.rows, 1)
+	row := findings.rows[0]
+	assert.NotEmpty(t, row.FindingID)
+	row.FindingID = nil
+	assert.Equal(t, &sqlplugin.ScannerFindingsRow{
+		ScanID:        "scan-id",
+		ShardID:       3,
+		DomainID:      serialization.MustParseUUID(domainID),
+		WorkflowID:    "wid",
+		RunID:         serialization.MustParseUUID(runID),
+		ResultType:    string(invariant.CheckResultTypeCorrupted),
+		InvariantName: string(invariantName),
+		Info:          "info",
+		InfoDetails:   "details",
+		CreatedTime:   now,
+	}, row)
+
+	// entities which are not scan outputs are only written to the wrapped writer
+	inner.EXPECT().Add("other").Return(nil)
+	require.NoError(t, writer.Add("other"))
+	assert.Len(t, findings.rows, 1)
+
+	inner.EXPECT().FlushedKeys().Return(&Keys{UUID: "keys"})
+	assert.Equal(t, &Keys{UUID: "keys"}, writer.FlushedKeys())
+}
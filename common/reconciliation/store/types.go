@@ -37,8 +37,15 @@ const (
 	FailedExtension Extension = "failed"
 	// FixedExtension is the extension for files which contain fixes
 	FixedExtension Extension = "fixed"
+	// DiffExtension is the extension for files which contain the changes a dry-run fix would make
+	DiffExtension Extension = "diff"
 	// CorruptedExtension is the extension for files which contain corruptions
 	CorruptedExtension Extension = "corrupted"
+	// LargestExecutionMapsExtension is the extension for files which contain the executions with the largest maps
+	LargestExecutionMapsExtension Extension = "largest_maps"
+	// SignalsMismatchExtension is the extension for files which contain signals found in only one of
+	// signals_requested_sets and signal_info_maps
+	SignalsMismatchExtension Extension = "signals_mismatch"
 )
 
 var (
@@ -75,5 +75,7 @@ type (
 		IsolationGroupStore      configstore.Client       // This can be nil, the default config store will be created if so
 		IsolationGroupState      isolationgroup.State     // This can be nil, the default state store will be chosen if so
 		Partitioner              partition.Partitioner
+		HealthDegradation        *common.HealthDegradation // This can be nil, the Meta health check then never reports a degradation
+		RequestLoad              *common.RequestLoad       // This can be nil, the Meta load status then reports no load
 	}
 )
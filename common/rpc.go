@@ -21,7 +21,12 @@
 package common
 
 import (
+	"context"
+	"strconv"
+
 	"go.uber.org/yarpc"
+
+	"github.com/uber/cadence/common/types"
 )
 
 const (
@@ -58,6 +63,16 @@ const (
 
 	// ClientIsolationGroupHeaderName refers to the name of the header that contains the isolation group which the client request is from
 	ClientIsolationGroupHeaderName = "cadence-client-isolation-group"
+
+	// HealthLatencyHeaderName refers to the name of the response header that contains the LatencyInMillis
+	// of a gRPC health check, the proto HealthResponse has no field for it
+	HealthLatencyHeaderName = "cadence-health-latency-ms"
+	// HealthDegradedReasonHeaderName refers to the name of the response header that contains the DegradedReason
+	// of a gRPC health check, it is only written while the host is degraded
+	HealthDegradedReasonHeaderName = "cadence-health-degraded-reason"
+	// HealthRetryAfterHeaderName refers to the name of the response header that contains the RetryAfterInMillis
+	// of a gRPC health check, it is only written while the host expects to become healthy
+	HealthRetryAfterHeaderName = "cadence-health-retry-after-ms"
 )
 
 type (
@@ -67,3 +82,19 @@ type (
 		GetMaxMessageSize() int
 	}
 )
+
+// WriteHealthResponseHeaders writes the fields of status which the proto HealthResponse has no field for
+// as response headers of the yarpc call in ctx, it does nothing outside of a yarpc call
+func WriteHealthResponseHeaders(ctx context.Context, status *types.HealthStatus) {
+	call := yarpc.CallFromContext(ctx)
+	if call == nil || status == nil {
+		return
+	}
+	_ = call.WriteResponseHeader(HealthLatencyHeaderName, strconv.FormatInt(status.LatencyInMillis, 10))
+	if status.DegradedReason != "" {
+		_ = call.WriteResponseHeader(HealthDegradedReasonHeaderName, status.DegradedReason)
+	}
+	if status.RetryAfterInMillis > 0 {
+		_ = call.WriteResponseHeader(HealthRetryAfterHeaderName, strconv.FormatInt(status.RetryAfterInMillis, 10))
+	}
+}
@@ -115,6 +115,16 @@ func (m *InboundMetricsMiddleware) Handle(ctx context.Context, req *transport.Re
 	return h.Handle(ctx, req, resw)
 }
 
+// InFlightRequestsMiddleware counts the inbound requests in flight in Load
+type InFlightRequestsMiddleware struct {
+	Load *common.RequestLoad
+}
+
+func (m *InFlightRequestsMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	defer m.Load.Start()()
+	return h.Handle(ctx, req, resw)
+}
+
 type overrideCallerMiddleware struct {
 	caller string
 }
@@ -87,6 +87,15 @@ func TestInboundMetricsMiddleware(t *testing.T) {
 	})
 }
 
+func TestInFlightRequestsMiddleware(t *testing.T) {
+	load := common.NewRequestLoad(func() int { return 0 })
+	m := InFlightRequestsMiddleware{Load: load}
+	h := &loadRecordingHandler{load: load}
+	assert.NoError(t, m.Handle(context.Background(), &transport.Request{}, nil, h))
+	assert.Equal(t, int64(1), h.inFlight)
+	assert.Equal(t, int64(0), load.Status().InFlightRequests)
+}
+
 func TestOverrideCallerMiddleware(t *testing.T) {
 	m := overrideCallerMiddleware{"x-caller"}
 	_, err := m.Call(context.Background(), &transport.Request{Caller: "service"}, &fakeOutbound{verify: func(r *transport.Request) {
@@ -338,6 +347,17 @@ func (h *fakeHandler) Handle(ctx context.Context, req *transport.Request, resw t
 	return nil
 }
 
+// loadRecordingHandler records the requests in flight while it handles a request
+type loadRecordingHandler struct {
+	load     *common.RequestLoad
+	inFlight int64
+}
+
+func (h *loadRecordingHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.inFlight = h.load.Status().InFlightRequests
+	return nil
+}
+
 type fakeOutbound struct {
 	verify   func(*transport.Request)
 	response *transport.Response
@@ -22,6 +22,26 @@ package types
 
 // HealthStatus is an internal type (TBD...)
 type HealthStatus struct {
-	Ok  bool   `json:"ok,required"`
-	Msg string `json:"msg,omitempty"`
+	Ok              bool   `json:"ok,required"`
+	Msg             string `json:"msg,omitempty"`
+	LatencyInMillis int64  `json:"latencyInMillis,omitempty"`
+	// DegradedReason is set while the host is healthy but cannot serve every request, e.g. "read-only"
+	DegradedReason string `json:"degradedReason,omitempty"`
+	// RetryAfterInMillis is set while the host is not ok but expects to be soon, e.g. while it is warming up,
+	// clients should wait that long before checking again
+	RetryAfterInMillis int64 `json:"retryAfterInMillis,omitempty"`
+}
+
+// LoadStatus is how loaded a host is, load balancers can route to the least loaded hosts
+type LoadStatus struct {
+	InFlightRequests    int64 `json:"inFlightRequests,required"`
+	MaxInFlightRequests int64 `json:"maxInFlightRequests,omitempty"`
+	// Saturation is InFlightRequests relative to MaxInFlightRequests, a host above 1 is overloaded
+	Saturation float64 `json:"saturation,omitempty"`
+}
+
+// ShardOwnership is an internal type (TBD...)
+type ShardOwnership struct {
+	Count    int32   `json:"count,required"`
+	ShardIDs []int32 `json:"shardIDs,omitempty"`
 }
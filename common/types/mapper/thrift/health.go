@@ -31,8 +31,11 @@ func FromHealthStatus(t *types.HealthStatus) *health.HealthStatus {
 		return nil
 	}
 	return &health.HealthStatus{
-		Ok:  t.Ok,
-		Msg: &t.Msg,
+		Ok:                 t.Ok,
+		Msg:                &t.Msg,
+		LatencyInMillis:    &t.LatencyInMillis,
+		DegradedReason:     &t.DegradedReason,
+		RetryAfterInMillis: &t.RetryAfterInMillis,
 	}
 }
 
@@ -42,7 +45,56 @@ func ToHealthStatus(t *health.HealthStatus) *types.HealthStatus {
 		return nil
 	}
 	return &types.HealthStatus{
-		Ok:  t.Ok,
-		Msg: t.GetMsg(),
+		Ok:                 t.Ok,
+		Msg:                t.GetMsg(),
+		LatencyInMillis:    t.GetLatencyInMillis(),
+		DegradedReason:     t.GetDegradedReason(),
+		RetryAfterInMillis: t.GetRetryAfterInMillis(),
+	}
+}
+
+// FromShardOwnership converts internal ShardOwnership type to thrift
+func FromShardOwnership(t *types.ShardOwnership) *health.ShardOwnership {
+	if t == nil {
+		return nil
+	}
+	return &health.ShardOwnership{
+		Count:    t.Count,
+		ShardIDs: t.ShardIDs,
+	}
+}
+
+// ToShardOwnership converts thrift ShardOwnership type to internal
+func ToShardOwnership(t *health.ShardOwnership) *types.ShardOwnership {
+	if t == nil {
+		return nil
+	}
+	return &types.ShardOwnership{
+		Count:    t.Count,
+		ShardIDs: t.ShardIDs,
+	}
+}
+
+// FromLoadStatus converts internal LoadStatus type to thrift
+func FromLoadStatus(t *types.LoadStatus) *health.LoadStatus {
+	if t == nil {
+		return nil
+	}
+	return &health.LoadStatus{
+		InFlightRequests:    t.InFlightRequests,
+		MaxInFlightRequests: &t.MaxInFlightRequests,
+		Saturation:          &t.Saturation,
+	}
+}
+
+// ToLoadStatus converts thrift LoadStatus type to internal
+func ToLoadStatus(t *health.LoadStatus) *types.LoadStatus {
+	if t == nil {
+		return nil
+	}
+	return &types.LoadStatus{
+		InFlightRequests:    t.InFlightRequests,
+		MaxInFlightRequests: t.GetMaxInFlightRequests(),
+		Saturation:          t.GetSaturation(),
 	}
 }
@@ -23,6 +23,7 @@ package common
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -74,6 +75,12 @@ const (
 	retryTaskProcessingMaxInterval     = 100 * time.Millisecond
 	retryTaskProcessingMaxAttempts     = 3
 
+	healthCheckRetryInitialInterval = 100 * time.Millisecond
+	healthCheckRetryMaxInterval     = 500 * time.Millisecond
+	// healthCheckMaxRetryCount caps the configured retry count, so a misconfigured count can not hold a health
+	// check long past the deadline of the load balancer probing the host
+	healthCheckMaxRetryCount = 3
+
 	replicationServiceBusyInitialInterval    = 2 * time.Second
 	replicationServiceBusyMaxInterval        = 10 * time.Second
 	replicationServiceBusyExpirationInterval = 5 * time.Minute
@@ -107,6 +114,9 @@ var (
 	ErrContextTimeoutNotSet = &types.BadRequestError{Message: "Context timeout is not set."}
 	// ErrDecisionResultCountTooLarge error for decision result count exceeds limit
 	ErrDecisionResultCountTooLarge = &types.BadRequestError{Message: "Decision result count exceeds limit."}
+
+	// errHealthCheckNotOk makes a health check which reports not ok retried like a failed one
+	errHealthCheckNotOk = errors.New("health check reported not ok")
 )
 
 // AwaitWaitGroup calls Wait on the given wait
@@ -341,6 +351,47 @@ func PrettyPrintHistory(history *types.History, logger log.Logger) {
 	fmt.Println("******************************************")
 }
 
+// HealthCheckWithRetry calls check and retries it up to retryCount times with a short backoff while it fails
+// or reports not ok, so that a single transient failure does not take the host out of rotation.
+// retryCount is clamped to [0, healthCheckMaxRetryCount]. The result of the last attempt is returned
+func HealthCheckWithRetry(
+	ctx context.Context,
+	retryCount int,
+	check func(context.Context) (*types.HealthStatus, error),
+) (*types.HealthStatus, error) {
+	if retryCount <= 0 {
+		return check(ctx)
+	}
+	if retryCount > healthCheckMaxRetryCount {
+		retryCount = healthCheckMaxRetryCount
+	}
+	var status *types.HealthStatus
+	var err error
+	throttleRetry := backoff.NewThrottleRetry(
+		backoff.WithRetryPolicy(createHealthCheckRetryPolicy(retryCount)),
+		backoff.WithRetryableError(func(error) bool { return true }),
+		backoff.WithThrottleError(func(error) bool { return false }),
+	)
+	// the error of Do is dropped, status and err always hold the result of the last attempt
+	_ = throttleRetry.Do(ctx, func() error {
+		status, err = check(ctx)
+		if err == nil && (status == nil || !status.Ok) {
+			return errHealthCheckNotOk
+		}
+		return err
+	})
+	return status, err
+}
+
+// createHealthCheckRetryPolicy creates a retry policy for the Meta health check which retries at most retryCount times
+func createHealthCheckRetryPolicy(retryCount int) backoff.RetryPolicy {
+	policy := backoff.NewExponentialRetryPolicy(healthCheckRetryInitialInterval)
+	policy.SetMaximumInterval(healthCheckRetryMaxInterval)
+	policy.SetMaximumAttempts(retryCount)
+
+	return policy
+}
+
 // IsValidContext checks that the thrift context is not expired on cancelled.
 // Returns nil if the context is still valid. Otherwise, returns the result of
 // ctx.Err()
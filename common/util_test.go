@@ -35,7 +35,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
 
+	"github.com/uber/cadence/common/clock"
 	"github.com/uber/cadence/common/types"
 )
 
@@ -390,3 +392,137 @@ func TestIntersectionStringSlice(t *testing.T) {
 		assert.ElementsMatch(t, []string{"c", "b"}, c)
 	})
 }
+
+func TestWriteHealthResponseHeaders(t *testing.T) {
+	status := &types.HealthStatus{Ok: true, LatencyInMillis: 12, DegradedReason: DegradedReasonReadOnly}
+	warmingUp := &types.HealthStatus{Msg: "warming up", LatencyInMillis: 3, RetryAfterInMillis: 1500}
+	// outside of a yarpc call there is nothing to write to
+	WriteHealthResponseHeaders(context.Background(), status)
+
+	call := &yarpctest.Call{ResponseHeaders: map[string]string{}}
+	WriteHealthResponseHeaders(yarpctest.ContextWithCall(context.Background(), call), status)
+	assert.Equal(t, map[string]string{
+		HealthLatencyHeaderName:        "12",
+		HealthDegradedReasonHeaderName: DegradedReasonReadOnly,
+	}, call.ResponseHeaders)
+
+	call = &yarpctest.Call{ResponseHeaders: map[string]string{}}
+	WriteHealthResponseHeaders(yarpctest.ContextWithCall(context.Background(), call), warmingUp)
+	assert.Equal(t, map[string]string{
+		HealthLatencyHeaderName:    "3",
+		HealthRetryAfterHeaderName: "1500",
+	}, call.ResponseHeaders)
+}
+
+func TestHealthCheckWithRetry(t *testing.T) {
+	transientErr := &types.InternalServiceError{Message: "persistence unavailable"}
+	checkFailingTimes := func(failures int, calls *int) func(context.Context) (*types.HealthStatus, error) {
+		return func(context.Context) (*types.HealthStatus, error) {
+			*calls++
+			if *calls <= failures {
+				return nil, transientErr
+			}
+			return &types.HealthStatus{Ok: true, Msg: "OK"}, nil
+		}
+	}
+
+	// no retry by default
+	calls := 0
+	status, err := HealthCheckWithRetry(context.Background(), 0, checkFailingTimes(1, &calls))
+	assert.Nil(t, status)
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	status, err = HealthCheckWithRetry(context.Background(), 2, checkFailingTimes(1, &calls))
+	require.NoError(t, err)
+	assert.True(t, status.Ok)
+	assert.Equal(t, 2, calls)
+
+	// an unhealthy status is retried like an error and the last one is returned
+	calls = 0
+	status, err = HealthCheckWithRetry(context.Background(), 1, func(context.Context) (*types.HealthStatus, error) {
+		calls++
+		return &types.HealthStatus{Ok: false, Msg: "shutting down"}, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, status.Ok)
+	assert.Equal(t, 2, calls)
+
+	// the retry count is clamped
+	calls = 0
+	_, err = HealthCheckWithRetry(context.Background(), 100, checkFailingTimes(100, &calls))
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, healthCheckMaxRetryCount+1, calls)
+	calls = 0
+	_, err = HealthCheckWithRetry(context.Background(), -1, checkFailingTimes(1, &calls))
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 1, calls)
+
+	// retries stop once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	_, err = HealthCheckWithRetry(ctx, 5, checkFailingTimes(5, &calls))
+	assert.Equal(t, transientErr, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestHealthDegradation(t *testing.T) {
+	var unset *HealthDegradation
+	assert.Empty(t, unset.Reason())
+
+	d := NewHealthDegradation()
+	assert.Empty(t, d.Reason())
+	migrating := false
+	d.AddDetector(func() string {
+		if migrating {
+			return DegradedReasonReadOnly
+		}
+		return ""
+	})
+	assert.Empty(t, d.Reason())
+	migrating = true
+	assert.Equal(t, DegradedReasonReadOnly, d.Reason())
+
+	// an explicit reason takes precedence over the detectors until it is cleared
+	d.SetReason("draining")
+	assert.Equal(t, "draining", d.Reason())
+	d.SetReason("")
+	assert.Equal(t, DegradedReasonReadOnly, d.Reason())
+}
+
+func TestCheckHealth(t *testing.T) {
+	timeSource := clock.NewEventTimeSource()
+	degradation := NewHealthDegradation()
+	degradation.SetReason(DegradedReasonReadOnly)
+
+	status, err := CheckHealth(context.Background(), timeSource, degradation, func(context.Context) (*types.HealthStatus, error) {
+		timeSource.Update(timeSource.Now().Add(25 * time.Millisecond))
+		return &types.HealthStatus{Ok: true}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.HealthStatus{Ok: true, LatencyInMillis: 25, DegradedReason: DegradedReasonReadOnly}, status)
+
+	checkErr := errors.New("check failed")
+	status, err = CheckHealth(context.Background(), timeSource, degradation, func(context.Context) (*types.HealthStatus, error) {
+		return nil, checkErr
+	})
+	assert.Equal(t, checkErr, err)
+	assert.Nil(t, status)
+}
+
+func TestRequestLoad(t *testing.T) {
+	var unset *RequestLoad
+	assert.Equal(t, &types.LoadStatus{}, unset.Status())
+
+	maxInFlight := 0
+	l := NewRequestLoad(func() int { return maxInFlight })
+	first, second := l.Start(), l.Start()
+	assert.Equal(t, &types.LoadStatus{InFlightRequests: 2}, l.Status())
+	maxInFlight = 4
+	assert.Equal(t, &types.LoadStatus{InFlightRequests: 2, MaxInFlightRequests: 4, Saturation: 0.5}, l.Status())
+	first()
+	second()
+	assert.Equal(t, &types.LoadStatus{MaxInFlightRequests: 4}, l.Status())
+}
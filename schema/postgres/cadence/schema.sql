@@ -53,6 +53,7 @@ CREATE TABLE executions(
   last_write_version BIGINT NOT NULL,
   data BYTEA NOT NULL,
   data_encoding VARCHAR(16) NOT NULL,
+  map_row_count BIGINT NOT NULL DEFAULT 0,
   PRIMARY KEY (shard_id, domain_id, workflow_id, run_id)
 );
 
@@ -263,3 +264,43 @@ CREATE TABLE queue_metadata (
   data BYTEA NOT NULL,
   PRIMARY KEY(queue_type)
 );
+
+CREATE TABLE db_shard_metadata (
+  id INTEGER NOT NULL,
+  num_db_shards INTEGER NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE scanner_findings (
+  finding_id BYTEA NOT NULL,
+  scan_id VARCHAR(255) NOT NULL,
+  shard_id INTEGER NOT NULL,
+  domain_id BYTEA NOT NULL,
+  workflow_id VARCHAR(255) NOT NULL,
+  run_id BYTEA NOT NULL,
+  result_type VARCHAR(16) NOT NULL,
+  invariant_name VARCHAR(255) NOT NULL,
+  info TEXT NOT NULL,
+  info_details TEXT NOT NULL,
+  created_time TIMESTAMP NOT NULL,
+  PRIMARY KEY (finding_id)
+);
+
+CREATE INDEX scanner_findings_by_domain_idx ON scanner_findings (domain_id, created_time);
+CREATE INDEX scanner_findings_by_shard_idx ON scanner_findings (shard_id, created_time);
+CREATE INDEX scanner_findings_by_created_time_idx ON scanner_findings (created_time);
+
+ALTER TABLE activity_info_maps ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE scanner_shard_scans (
+  scanner_name VARCHAR(255) NOT NULL,
+  shard_id INTEGER NOT NULL,
+  last_scanned_time TIMESTAMP NOT NULL,
+  PRIMARY KEY (scanner_name, shard_id)
+);
+
+CREATE TABLE db_shard_health (
+  id INTEGER NOT NULL,
+  probed_time TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
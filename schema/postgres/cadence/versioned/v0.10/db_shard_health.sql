@@ -0,0 +1,5 @@
+CREATE TABLE db_shard_health (
+  id INTEGER NOT NULL,
+  probed_time TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
@@ -0,0 +1,9 @@
+ALTER TABLE executions ADD COLUMN map_row_count BIGINT NOT NULL DEFAULT 0;
+
+-- the count of the existing executions is backfilled from their execution map rows
+UPDATE executions e SET map_row_count =
+  (SELECT COUNT(*) FROM activity_info_maps m WHERE m.shard_id = e.shard_id AND m.domain_id = e.domain_id AND m.workflow_id = e.workflow_id AND m.run_id = e.run_id) +
+  (SELECT COUNT(*) FROM timer_info_maps m WHERE m.shard_id = e.shard_id AND m.domain_id = e.domain_id AND m.workflow_id = e.workflow_id AND m.run_id = e.run_id) +
+  (SELECT COUNT(*) FROM child_execution_info_maps m WHERE m.shard_id = e.shard_id AND m.domain_id = e.domain_id AND m.workflow_id = e.workflow_id AND m.run_id = e.run_id) +
+  (SELECT COUNT(*) FROM request_cancel_info_maps m WHERE m.shard_id = e.shard_id AND m.domain_id = e.domain_id AND m.workflow_id = e.workflow_id AND m.run_id = e.run_id) +
+  (SELECT COUNT(*) FROM signal_info_maps m WHERE m.shard_id = e.shard_id AND m.domain_id = e.domain_id AND m.workflow_id = e.workflow_id AND m.run_id = e.run_id);
@@ -0,0 +1,5 @@
+CREATE TABLE db_shard_metadata (
+  id INTEGER NOT NULL,
+  num_db_shards INTEGER NOT NULL,
+  PRIMARY KEY (id)
+);
@@ -0,0 +1,17 @@
+CREATE TABLE scanner_findings (
+  finding_id BYTEA NOT NULL,
+  scan_id VARCHAR(255) NOT NULL,
+  shard_id INTEGER NOT NULL,
+  domain_id BYTEA NOT NULL,
+  workflow_id VARCHAR(255) NOT NULL,
+  run_id BYTEA NOT NULL,
+  result_type VARCHAR(16) NOT NULL,
+  invariant_name VARCHAR(255) NOT NULL,
+  info TEXT NOT NULL,
+  info_details TEXT NOT NULL,
+  created_time TIMESTAMP NOT NULL,
+  PRIMARY KEY (finding_id)
+);
+
+CREATE INDEX scanner_findings_by_domain_idx ON scanner_findings (domain_id, created_time);
+CREATE INDEX scanner_findings_by_shard_idx ON scanner_findings (shard_id, created_time);
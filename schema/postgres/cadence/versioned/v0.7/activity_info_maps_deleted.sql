@@ -0,0 +1 @@
+ALTER TABLE activity_info_maps ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE;
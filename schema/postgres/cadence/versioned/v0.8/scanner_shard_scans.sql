@@ -0,0 +1,6 @@
+CREATE TABLE scanner_shard_scans (
+  scanner_name VARCHAR(255) NOT NULL,
+  shard_id INTEGER NOT NULL,
+  last_scanned_time TIMESTAMP NOT NULL,
+  PRIMARY KEY (scanner_name, shard_id)
+);
@@ -0,0 +1 @@
+CREATE INDEX scanner_findings_by_created_time_idx ON scanner_findings (created_time);
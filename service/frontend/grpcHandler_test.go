@@ -0,0 +1,97 @@
+// The MIT License (MIT)
+
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package frontend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/yarpctest"
+
+	apiv1 "github.com/uber/cadence-idl/go/proto/api/v1"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/dynamicconfig"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestGRPCHandlerHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := NewMockHandler(ctrl)
+	timeSource := clock.NewEventTimeSource()
+	degradation := common.NewHealthDegradation()
+	g := grpcHandler{h: h, healthCheckRetryCount: dynamicconfig.GetIntPropertyFn(0), healthDegradation: degradation, timeSource: timeSource}
+
+	call := &yarpctest.Call{ResponseHeaders: map[string]string{}}
+	ctx := yarpctest.ContextWithCall(context.Background(), call)
+	h.EXPECT().Health(ctx).DoAndReturn(func(context.Context) (*types.HealthStatus, error) {
+		timeSource.Update(timeSource.Now().Add(5 * time.Millisecond))
+		return &types.HealthStatus{Ok: true, Msg: "OK"}, nil
+	}).Times(1)
+	resp, err := g.Health(ctx, &apiv1.HealthRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &apiv1.HealthResponse{Ok: true, Message: "OK"}, resp)
+	assert.Equal(t, map[string]string{
+		common.HealthLatencyHeaderName: "5",
+	}, call.ResponseHeaders)
+
+	degradation.SetReason(common.DegradedReasonReadOnly)
+	call = &yarpctest.Call{ResponseHeaders: map[string]string{}}
+	ctx = yarpctest.ContextWithCall(context.Background(), call)
+	h.EXPECT().Health(ctx).Return(&types.HealthStatus{Ok: true, Msg: "OK"}, nil).Times(1)
+	resp, err = g.Health(ctx, &apiv1.HealthRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &apiv1.HealthResponse{Ok: true, Message: "OK"}, resp)
+	assert.Equal(t, map[string]string{
+		common.HealthLatencyHeaderName:        "0",
+		common.HealthDegradedReasonHeaderName: common.DegradedReasonReadOnly,
+	}, call.ResponseHeaders)
+
+	degradation.SetReason("")
+	call = &yarpctest.Call{ResponseHeaders: map[string]string{}}
+	ctx = yarpctest.ContextWithCall(context.Background(), call)
+	h.EXPECT().Health(ctx).Return(&types.HealthStatus{Msg: "warming up", RetryAfterInMillis: 1500}, nil).Times(1)
+	resp, err = g.Health(ctx, &apiv1.HealthRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &apiv1.HealthResponse{Message: "warming up"}, resp)
+	assert.Equal(t, map[string]string{
+		common.HealthLatencyHeaderName:    "0",
+		common.HealthRetryAfterHeaderName: "1500",
+	}, call.ResponseHeaders)
+
+	// a failed check is retried as many times as configured
+	g.healthCheckRetryCount = dynamicconfig.GetIntPropertyFn(1)
+	ctx = context.Background()
+	gomock.InOrder(
+		h.EXPECT().Health(ctx).Return(nil, &types.InternalServiceError{Message: "persistence unavailable"}).Times(1),
+		h.EXPECT().Health(ctx).Return(&types.HealthStatus{Ok: true, Msg: "OK"}, nil).Times(1),
+	)
+	resp, err = g.Health(ctx, &apiv1.HealthRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &apiv1.HealthResponse{Ok: true, Message: "OK"}, resp)
+}
@@ -93,6 +93,9 @@ type Config struct {
 
 	ThrottledLogRPS dynamicconfig.IntPropertyFn
 
+	// HealthCheckRetryCount is the number of retries of a failed health check before reporting not serving
+	HealthCheckRetryCount dynamicconfig.IntPropertyFn
+
 	// Domain specific config
 	EnableDomainNotActiveAutoForwarding         dynamicconfig.BoolPropertyFnWithDomainFilter
 	EnableGracefulFailover                      dynamicconfig.BoolPropertyFn
@@ -161,6 +164,7 @@ func NewConfig(dc *dynamicconfig.Collection, numHistoryShards int, isAdvancedVis
 		BlobSizeLimitError:                          dc.GetIntPropertyFilteredByDomain(dynamicconfig.BlobSizeLimitError),
 		BlobSizeLimitWarn:                           dc.GetIntPropertyFilteredByDomain(dynamicconfig.BlobSizeLimitWarn),
 		ThrottledLogRPS:                             dc.GetIntProperty(dynamicconfig.FrontendThrottledLogRPS),
+		HealthCheckRetryCount:                       dc.GetIntProperty(dynamicconfig.HealthCheckRetryCount),
 		ShutdownDrainDuration:                       dc.GetDurationProperty(dynamicconfig.FrontendShutdownDrainDuration),
 		EnableDomainNotActiveAutoForwarding:         dc.GetBoolPropertyFilteredByDomain(dynamicconfig.EnableDomainNotActiveAutoForwarding),
 		EnableGracefulFailover:                      dc.GetBoolProperty(dynamicconfig.EnableGracefulFailover),
@@ -299,10 +303,10 @@ func (s *Service) Start() {
 	handler = NewAccessControlledHandlerImpl(handler, s, s.params.Authorizer, s.params.AuthorizationConfig)
 
 	// Register the latest (most decorated) handler
-	thriftHandler := NewThriftHandler(handler)
+	thriftHandler := NewThriftHandler(handler, s.config.HealthCheckRetryCount, s.params.HealthDegradation, s.params.RequestLoad)
 	thriftHandler.register(s.GetDispatcher())
 
-	grpcHandler := newGrpcHandler(handler)
+	grpcHandler := newGrpcHandler(handler, s.config.HealthCheckRetryCount, s.params.HealthDegradation)
 	grpcHandler.register(s.GetDispatcher())
 
 	s.adminHandler = NewAdminHandler(s, s.params, s.config, dh)
@@ -82,6 +82,8 @@ type (
 	WorkflowHandler struct {
 		resource.Resource
 
+		// warmUpDeadline is the unix nanos the warm up ends at, it is accessed atomically and kept first for alignment
+		warmUpDeadline            int64
 		shuttingDown              int32
 		healthStatus              int32
 		tokenSerializer           common.TaskTokenSerializer
@@ -231,6 +233,7 @@ func (wh *WorkflowHandler) Start() {
 	// TODO: Get warmup duration from config. Even better, run proactive checks such as probing downstream connections.
 	const warmUpDuration = 30 * time.Second
 
+	atomic.StoreInt64(&wh.warmUpDeadline, time.Now().Add(warmUpDuration).UnixNano())
 	warmupTimer := time.NewTimer(warmUpDuration)
 	go func() {
 		<-warmupTimer.C
@@ -269,11 +272,28 @@ func (wh *WorkflowHandler) Health(ctx context.Context) (*types.HealthStatus, err
 	}
 
 	return &types.HealthStatus{
-		Ok:  status == HealthStatusOK,
-		Msg: msg,
+		Ok:                 status == HealthStatusOK,
+		Msg:                msg,
+		RetryAfterInMillis: wh.RetryAfter().Milliseconds(),
 	}, nil
 }
 
+// RetryAfter estimates how long it takes until the handler is healthy, it is 0 unless the handler is warming up.
+// A shutting down handler never becomes healthy again, so there is nothing to wait for
+func (wh *WorkflowHandler) RetryAfter() time.Duration {
+	if HealthStatus(atomic.LoadInt32(&wh.healthStatus)) != HealthStatusWarmingUp {
+		return 0
+	}
+	deadline := atomic.LoadInt64(&wh.warmUpDeadline)
+	if deadline == 0 {
+		return 0
+	}
+	if retryAfter := time.Until(time.Unix(0, deadline)); retryAfter > 0 {
+		return retryAfter
+	}
+	return 0
+}
+
 // RegisterDomain creates a new domain which can be used as a container for all resources.  Domain is a top level
 // entity within Cadence, used as a container for all resources like workflow executions, tasklists, etc.  Domain
 // acts as a sandbox and provides isolation for all resources within the domain.  All resources belongs to exactly one
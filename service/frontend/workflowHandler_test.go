@@ -151,6 +151,25 @@ func (s *workflowHandlerSuite) getWorkflowHandler(config *Config) *WorkflowHandl
 	return NewWorkflowHandler(s.mockResource, config, s.mockVersionChecker, s.domainHandler)
 }
 
+func (s *workflowHandlerSuite) TestHealth_RetryAfter() {
+	wh := s.getWorkflowHandler(s.newConfig(dc.NewInMemoryClient()))
+	wh.warmUpDeadline = time.Now().Add(10 * time.Second).UnixNano()
+
+	status, err := wh.Health(context.Background())
+	s.NoError(err)
+	s.False(status.Ok)
+	s.InDelta(10*time.Second.Milliseconds(), status.RetryAfterInMillis, float64(time.Second.Milliseconds()))
+
+	// neither a healthy nor a shutting down handler expects to change soon
+	wh.UpdateHealthStatus(HealthStatusOK)
+	status, err = wh.Health(context.Background())
+	s.NoError(err)
+	s.True(status.Ok)
+	s.Zero(status.RetryAfterInMillis)
+	wh.UpdateHealthStatus(HealthStatusShuttingDown)
+	s.Zero(wh.RetryAfter())
+}
+
 func (s *workflowHandlerSuite) TestDisableListVisibilityByFilter() {
 	config := s.newConfig(dc.NewInMemoryClient())
 	config.DisableListVisibilityByFilter = dc.GetBoolPropertyFnFilteredByDomain(true)
@@ -27,15 +27,22 @@ import (
 
 	apiv1 "github.com/uber/cadence-idl/go/proto/api/v1"
 	historyv1 "github.com/uber/cadence/.gen/proto/history/v1"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/dynamicconfig"
+	"github.com/uber/cadence/common/types"
 	"github.com/uber/cadence/common/types/mapper/proto"
 )
 
 type grpcHandler struct {
-	h Handler
+	h                     Handler
+	healthCheckRetryCount dynamicconfig.IntPropertyFn
+	healthDegradation     *common.HealthDegradation
+	timeSource            clock.TimeSource
 }
 
-func newGRPCHandler(h Handler) grpcHandler {
-	return grpcHandler{h}
+func newGRPCHandler(h Handler, healthCheckRetryCount dynamicconfig.IntPropertyFn, healthDegradation *common.HealthDegradation) grpcHandler {
+	return grpcHandler{h: h, healthCheckRetryCount: healthCheckRetryCount, healthDegradation: healthDegradation, timeSource: clock.NewRealTimeSource()}
 }
 
 func (g grpcHandler) register(dispatcher *yarpc.Dispatcher) {
@@ -43,8 +50,14 @@ func (g grpcHandler) register(dispatcher *yarpc.Dispatcher) {
 	dispatcher.Register(apiv1.BuildMetaAPIYARPCProcedures(g))
 }
 
+// Health forwards request to the underlying handler and retries a failed check as many times as configured,
+// like the thrift handler. The fields of the health status which the proto response has no field for are reported
+// as response headers
 func (g grpcHandler) Health(ctx context.Context, _ *apiv1.HealthRequest) (*apiv1.HealthResponse, error) {
-	response, err := g.h.Health(ctx)
+	response, err := common.CheckHealth(ctx, g.timeSource, g.healthDegradation, func(ctx context.Context) (*types.HealthStatus, error) {
+		return common.HealthCheckWithRetry(ctx, g.healthCheckRetryCount(), g.h.Health)
+	})
+	common.WriteHealthResponseHeaders(ctx, response)
 	return proto.FromHealthResponse(response), proto.FromError(err)
 }
 
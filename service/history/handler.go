@@ -26,6 +26,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -68,6 +69,7 @@ type (
 
 		PrepareToStop(time.Duration) time.Duration
 		Health(context.Context) (*types.HealthStatus, error)
+		ShardOwnership(context.Context) (*types.ShardOwnership, error)
 		CloseShard(context.Context, *types.CloseShardRequest) error
 		DescribeHistoryHost(context.Context, *types.DescribeHistoryHostRequest) (*types.DescribeHistoryHostResponse, error)
 		DescribeMutableState(context.Context, *types.DescribeMutableStateRequest) (*types.DescribeMutableStateResponse, error)
@@ -294,6 +296,17 @@ func (h *handlerImpl) Health(ctx context.Context) (*types.HealthStatus, error) {
 	return hs, nil
 }
 
+// ShardOwnership returns the number and the IDs of history shards currently owned by this host
+func (h *handlerImpl) ShardOwnership(ctx context.Context) (*types.ShardOwnership, error) {
+	h.startWG.Wait()
+	shardIDs := h.controller.ShardIDs()
+	sort.Slice(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] })
+	return &types.ShardOwnership{
+		Count:    int32(len(shardIDs)),
+		ShardIDs: shardIDs,
+	}, nil
+}
+
 // RecordActivityTaskHeartbeat - Record Activity Task Heart beat.
 func (h *handlerImpl) RecordActivityTaskHeartbeat(
 	ctx context.Context,
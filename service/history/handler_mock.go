@@ -627,6 +627,21 @@ func (mr *MockHandlerMockRecorder) ScheduleDecisionTask(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleDecisionTask", reflect.TypeOf((*MockHandler)(nil).ScheduleDecisionTask), arg0, arg1)
 }
 
+// ShardOwnership mocks base method.
+func (m *MockHandler) ShardOwnership(arg0 context.Context) (*types.ShardOwnership, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShardOwnership", arg0)
+	ret0, _ := ret[0].(*types.ShardOwnership)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShardOwnership indicates an expected call of ShardOwnership.
+func (mr *MockHandlerMockRecorder) ShardOwnership(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShardOwnership", reflect.TypeOf((*MockHandler)(nil).ShardOwnership), arg0)
+}
+
 // SignalWithStartWorkflowExecution mocks base method.
 func (m *MockHandler) SignalWithStartWorkflowExecution(arg0 context.Context, arg1 *types.HistorySignalWithStartWorkflowExecutionRequest) (*types.StartWorkflowExecutionResponse, error) {
 	m.ctrl.T.Helper()
@@ -92,10 +92,10 @@ func (s *Service) Start() {
 
 	s.handler = NewHandler(s.Resource, s.config)
 
-	thriftHandler := NewThriftHandler(s.handler)
+	thriftHandler := NewThriftHandler(s.handler, s.config.HealthCheckRetryCount, s.params.HealthDegradation, s.params.RequestLoad)
 	thriftHandler.register(s.GetDispatcher())
 
-	grpcHandler := newGRPCHandler(s.handler)
+	grpcHandler := newGRPCHandler(s.handler, s.config.HealthCheckRetryCount, s.params.HealthDegradation)
 	grpcHandler.register(s.GetDispatcher())
 
 	// must start resource first
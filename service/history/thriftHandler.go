@@ -31,17 +31,30 @@ import (
 	"github.com/uber/cadence/.gen/go/history/historyserviceserver"
 	"github.com/uber/cadence/.gen/go/replicator"
 	"github.com/uber/cadence/.gen/go/shared"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/dynamicconfig"
+	"github.com/uber/cadence/common/types"
 	"github.com/uber/cadence/common/types/mapper/thrift"
 )
 
 // ThriftHandler wrap underlying handler and handles Thrift related type conversions
 type ThriftHandler struct {
-	h Handler
+	h                     Handler
+	healthCheckRetryCount dynamicconfig.IntPropertyFn
+	healthDegradation     *common.HealthDegradation
+	requestLoad           *common.RequestLoad
+	timeSource            clock.TimeSource
 }
 
 // NewThriftHandler creates Thrift handler on top of underlying handler
-func NewThriftHandler(h Handler) ThriftHandler {
-	return ThriftHandler{h}
+func NewThriftHandler(
+	h Handler,
+	healthCheckRetryCount dynamicconfig.IntPropertyFn,
+	healthDegradation *common.HealthDegradation,
+	requestLoad *common.RequestLoad,
+) ThriftHandler {
+	return ThriftHandler{h: h, healthCheckRetryCount: healthCheckRetryCount, healthDegradation: healthDegradation, requestLoad: requestLoad, timeSource: clock.NewRealTimeSource()}
 }
 
 func (t ThriftHandler) register(dispatcher *yarpc.Dispatcher) {
@@ -49,12 +62,28 @@ func (t ThriftHandler) register(dispatcher *yarpc.Dispatcher) {
 	dispatcher.Register(metaserver.New(&t))
 }
 
-// Health forwards request to the underlying handler
+// Health forwards request to the underlying handler and reports how long the check took,
+// so that a slow but successful check can be treated as degraded by the caller.
+// A failed check is retried as many times as configured before it is reported.
+// A healthy host also reports why it is degraded, if it is
 func (t ThriftHandler) Health(ctx context.Context) (*health.HealthStatus, error) {
-	response, err := t.h.Health(ctx)
+	response, err := common.CheckHealth(ctx, t.timeSource, t.healthDegradation, func(ctx context.Context) (*types.HealthStatus, error) {
+		return common.HealthCheckWithRetry(ctx, t.healthCheckRetryCount(), t.h.Health)
+	})
 	return thrift.FromHealthStatus(response), thrift.FromError(err)
 }
 
+// LoadStatus reports the inbound requests in flight on this host, which are counted by the rpc middleware
+func (t ThriftHandler) LoadStatus(ctx context.Context) (*health.LoadStatus, error) {
+	return thrift.FromLoadStatus(t.requestLoad.Status()), nil
+}
+
+// ShardOwnership forwards request to the underlying handler
+func (t ThriftHandler) ShardOwnership(ctx context.Context) (*health.ShardOwnership, error) {
+	response, err := t.h.ShardOwnership(ctx)
+	return thrift.FromShardOwnership(response), thrift.FromError(err)
+}
+
 // CloseShard forwards request to the underlying handler
 func (t ThriftHandler) CloseShard(ctx context.Context, request *shared.CloseShardRequest) error {
 	err := t.h.CloseShard(ctx, thrift.ToCloseShardRequest(request))
@@ -23,12 +23,15 @@ package history
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/uber/cadence/.gen/go/health"
 	hist "github.com/uber/cadence/.gen/go/history"
 	"github.com/uber/cadence/.gen/go/replicator"
 	"github.com/uber/cadence/.gen/go/shared"
 	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/dynamicconfig"
 	"github.com/uber/cadence/common/types"
 
 	"github.com/golang/mock/gomock"
@@ -40,17 +43,57 @@ func TestThriftHandler(t *testing.T) {
 	defer ctrl.Finish()
 
 	h := NewMockHandler(ctrl)
-	th := NewThriftHandler(h)
+	load := common.NewRequestLoad(func() int { return 4 })
+	th := NewThriftHandler(h, dynamicconfig.GetIntPropertyFn(0), nil, load)
 	ctx := context.Background()
 	internalErr := &types.InternalServiceError{Message: "test"}
 	expectedErr := &shared.InternalServiceError{Message: "test"}
 
 	t.Run("Health", func(t *testing.T) {
-		h.EXPECT().Health(ctx).Return(&types.HealthStatus{}, internalErr).Times(1)
+		timeSource := clock.NewEventTimeSource()
+		th.timeSource = timeSource
+		h.EXPECT().Health(ctx).DoAndReturn(func(context.Context) (*types.HealthStatus, error) {
+			timeSource.Update(timeSource.Now().Add(5 * time.Millisecond))
+			return &types.HealthStatus{}, internalErr
+		}).Times(1)
 		resp, err := th.Health(ctx)
-		assert.Equal(t, health.HealthStatus{Msg: common.StringPtr("")}, *resp)
+		assert.Equal(t, health.HealthStatus{
+			Msg:                common.StringPtr(""),
+			LatencyInMillis:    common.Int64Ptr(5),
+			DegradedReason:     common.StringPtr(""),
+			RetryAfterInMillis: common.Int64Ptr(0),
+		}, *resp)
 		assert.Equal(t, expectedErr, err)
 	})
+	t.Run("HealthDegraded", func(t *testing.T) {
+		degradation := common.NewHealthDegradation()
+		degradation.SetReason(common.DegradedReasonReadOnly)
+		th.healthDegradation = degradation
+		defer func() { th.healthDegradation = nil }()
+		h.EXPECT().Health(ctx).Return(&types.HealthStatus{Ok: true, Msg: "OK"}, nil).Times(1)
+		resp, err := th.Health(ctx)
+		assert.Equal(t, health.HealthStatus{
+			Ok:                 true,
+			Msg:                common.StringPtr("OK"),
+			LatencyInMillis:    common.Int64Ptr(0),
+			DegradedReason:     common.StringPtr(common.DegradedReasonReadOnly),
+			RetryAfterInMillis: common.Int64Ptr(0),
+		}, *resp)
+		assert.NoError(t, err)
+	})
+	t.Run("LoadStatus", func(t *testing.T) {
+		done := load.Start()
+		defer done()
+		resp, err := th.LoadStatus(ctx)
+		assert.Equal(t, health.LoadStatus{InFlightRequests: 1, MaxInFlightRequests: common.Int64Ptr(4), Saturation: common.Float64Ptr(0.25)}, *resp)
+		assert.NoError(t, err)
+	})
+	t.Run("ShardOwnership", func(t *testing.T) {
+		h.EXPECT().ShardOwnership(ctx).Return(&types.ShardOwnership{Count: 2, ShardIDs: []int32{1, 3}}, nil).Times(1)
+		resp, err := th.ShardOwnership(ctx)
+		assert.Equal(t, health.ShardOwnership{Count: 2, ShardIDs: []int32{1, 3}}, *resp)
+		assert.NoError(t, err)
+	})
 	t.Run("CloseShard", func(t *testing.T) {
 		h.EXPECT().CloseShard(ctx, &types.CloseShardRequest{}).Return(internalErr).Times(1)
 		err := th.CloseShard(ctx, &shared.CloseShardRequest{})
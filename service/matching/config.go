@@ -65,6 +65,9 @@ type (
 
 		ThrottledLogRPS dynamicconfig.IntPropertyFn
 
+		// HealthCheckRetryCount is the number of retries of a failed health check before reporting not serving
+		HealthCheckRetryCount dynamicconfig.IntPropertyFn
+
 		// debugging configuration
 		EnableDebugMode             bool // note that this value is initialized once on service start
 		EnableTaskInfoLogByDomainID dynamicconfig.BoolPropertyFnWithDomainIDFilter
@@ -140,6 +143,7 @@ func NewConfig(dc *dynamicconfig.Collection, hostName string) *Config {
 		ForwarderMaxRatePerSecond:       dc.GetIntPropertyFilteredByTaskListInfo(dynamicconfig.MatchingForwarderMaxRatePerSecond),
 		ForwarderMaxChildrenPerNode:     dc.GetIntPropertyFilteredByTaskListInfo(dynamicconfig.MatchingForwarderMaxChildrenPerNode),
 		ShutdownDrainDuration:           dc.GetDurationProperty(dynamicconfig.MatchingShutdownDrainDuration),
+		HealthCheckRetryCount:           dc.GetIntProperty(dynamicconfig.HealthCheckRetryCount),
 		EnableDebugMode:                 dc.GetBoolProperty(dynamicconfig.EnableDebugMode)(),
 		EnableTaskInfoLogByDomainID:     dc.GetBoolPropertyFilteredByDomainID(dynamicconfig.MatchingEnableTaskInfoLogByDomainID),
 		ActivityTaskSyncMatchWaitTime:   dc.GetDurationPropertyFilteredByDomain(dynamicconfig.MatchingActivityTaskSyncMatchWaitTime),
@@ -38,6 +38,9 @@ type Service struct {
 	handler Handler
 	stopC   chan struct{}
 	config  *Config
+
+	healthDegradation *common.HealthDegradation
+	requestLoad       *common.RequestLoad
 }
 
 // NewService builds a new cadence-matching service
@@ -73,6 +76,9 @@ func NewService(
 		status:   common.DaemonStatusInitialized,
 		config:   serviceConfig,
 		stopC:    make(chan struct{}),
+
+		healthDegradation: params.HealthDegradation,
+		requestLoad:       params.RequestLoad,
 	}, nil
 }
 
@@ -100,10 +106,10 @@ func (s *Service) Start() {
 
 	s.handler = NewHandler(engine, s.config, s.GetDomainCache(), s.GetMetricsClient(), s.GetLogger(), s.GetThrottledLogger())
 
-	thriftHandler := NewThriftHandler(s.handler)
+	thriftHandler := NewThriftHandler(s.handler, s.config.HealthCheckRetryCount, s.healthDegradation, s.requestLoad)
 	thriftHandler.register(s.GetDispatcher())
 
-	grpcHandler := newGRPCHandler(s.handler)
+	grpcHandler := newGRPCHandler(s.handler, s.config.HealthCheckRetryCount, s.healthDegradation)
 	grpcHandler.register(s.GetDispatcher())
 
 	// must start base service first
@@ -23,11 +23,14 @@ package matching
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/uber/cadence/.gen/go/health"
 	m "github.com/uber/cadence/.gen/go/matching"
 	s "github.com/uber/cadence/.gen/go/shared"
 	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/clock"
+	"github.com/uber/cadence/common/dynamicconfig"
 	"github.com/uber/cadence/common/types"
 
 	"github.com/golang/mock/gomock"
@@ -39,17 +42,56 @@ func TestThriftHandler(t *testing.T) {
 	defer ctrl.Finish()
 
 	h := NewMockHandler(ctrl)
-	th := NewThriftHandler(h)
+	load := common.NewRequestLoad(func() int { return 4 })
+	th := NewThriftHandler(h, dynamicconfig.GetIntPropertyFn(0), nil, load)
 	ctx := context.Background()
 	internalErr := &types.InternalServiceError{Message: "test"}
 	expectedErr := &s.InternalServiceError{Message: "test"}
 
 	t.Run("Health", func(t *testing.T) {
-		h.EXPECT().Health(ctx).Return(&types.HealthStatus{}, internalErr).Times(1)
+		timeSource := clock.NewEventTimeSource()
+		th.timeSource = timeSource
+		h.EXPECT().Health(ctx).DoAndReturn(func(context.Context) (*types.HealthStatus, error) {
+			timeSource.Update(timeSource.Now().Add(5 * time.Millisecond))
+			return &types.HealthStatus{}, internalErr
+		}).Times(1)
 		resp, err := th.Health(ctx)
-		assert.Equal(t, health.HealthStatus{Msg: common.StringPtr("")}, *resp)
+		assert.Equal(t, health.HealthStatus{
+			Msg:                common.StringPtr(""),
+			LatencyInMillis:    common.Int64Ptr(5),
+			DegradedReason:     common.StringPtr(""),
+			RetryAfterInMillis: common.Int64Ptr(0),
+		}, *resp)
 		assert.Equal(t, expectedErr, err)
 	})
+	t.Run("HealthDegraded", func(t *testing.T) {
+		degradation := common.NewHealthDegradation()
+		degradation.SetReason(common.DegradedReasonReadOnly)
+		th.healthDegradation = degradation
+		defer func() { th.healthDegradation = nil }()
+		h.EXPECT().Health(ctx).Return(&types.HealthStatus{Ok: true, Msg: "OK"}, nil).Times(1)
+		resp, err := th.Health(ctx)
+		assert.Equal(t, health.HealthStatus{
+			Ok:                 true,
+			Msg:                common.StringPtr("OK"),
+			LatencyInMillis:    common.Int64Ptr(0),
+			DegradedReason:     common.StringPtr(common.DegradedReasonReadOnly),
+			RetryAfterInMillis: common.Int64Ptr(0),
+		}, *resp)
+		assert.NoError(t, err)
+	})
+	t.Run("LoadStatus", func(t *testing.T) {
+		done := load.Start()
+		defer done()
+		resp, err := th.LoadStatus(ctx)
+		assert.Equal(t, health.LoadStatus{InFlightRequests: 1, MaxInFlightRequests: common.Int64Ptr(4), Saturation: common.Float64Ptr(0.25)}, *resp)
+		assert.NoError(t, err)
+	})
+	t.Run("ShardOwnership", func(t *testing.T) {
+		resp, err := th.ShardOwnership(ctx)
+		assert.Nil(t, resp)
+		assert.IsType(t, &health.ShardOwnershipNotApplicableError{}, err)
+	})
 	t.Run("AddActivityTask", func(t *testing.T) {
 		h.EXPECT().AddActivityTask(ctx, &types.AddActivityTaskRequest{}).Return(internalErr).Times(1)
 		err := th.AddActivityTask(ctx, &m.AddActivityTaskRequest{})
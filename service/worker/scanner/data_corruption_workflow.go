@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jonboulle/clockwork"
 	"go.uber.org/cadence/activity"
 	"go.uber.org/cadence/worker"
 	"go.uber.org/cadence/workflow"
@@ -49,6 +50,7 @@ func init() {
 	)
 	activity.Register(ExecutionFixerActivity)
 	activity.Register(EmitResultMetricsActivity)
+	activity.Register(VerifyExecutionMapsActivity)
 }
 
 const (
@@ -225,6 +227,7 @@ func NewDataCorruptionWorkflowWorker(
 	return &Scanner{
 		context: scannerContext{
 			resource: resource,
+			clock:    clockwork.NewRealClock(),
 		},
 		tallyScope: params.TallyScope,
 		zapLogger:  zapLogger.Named("data-corruption-workflow"),
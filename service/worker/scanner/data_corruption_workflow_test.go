@@ -141,3 +141,38 @@ func (s *dataCorruptionWorkflowTestSuite) TestExecutionFixerActivity_Success() {
 	_, err := env.ExecuteActivity(ExecutionFixerActivity, fixList)
 	s.NoError(err)
 }
+
+func (s *dataCorruptionWorkflowTestSuite) TestVerifyExecutionMapsActivity_Success() {
+	env := s.NewTestActivityEnvironment()
+	controller := gomock.NewController(s.T())
+	defer controller.Finish()
+	mockResource := resource.NewTest(controller, metrics.Worker)
+	defer mockResource.Finish(s.T())
+	execution := entity.Execution{
+		ShardID:    0,
+		DomainID:   uuid.New(),
+		WorkflowID: uuid.New(),
+		RunID:      uuid.New(),
+	}
+	mockResource.ExecutionMgr.On("GetWorkflowExecution", mock.Anything, mock.Anything).Return(&p.GetWorkflowExecutionResponse{
+		State: &p.WorkflowMutableState{
+			ExecutionInfo: &p.WorkflowExecutionInfo{NextEventID: 10},
+			ActivityInfos: map[int64]*p.ActivityInfo{
+				5: {ScheduleID: 4, ActivityID: "a"},
+			},
+		},
+	}, nil)
+	ctx := context.WithValue(context.Background(), contextKey(testWorkflowName), scannerContext{resource: mockResource})
+	env.SetWorkerOptions(worker.Options{
+		BackgroundActivityContext: ctx,
+	})
+	mockResource.DomainCache.EXPECT().GetDomainName(execution.DomainID).Return("test-domain-name", nil).Times(1)
+	result, err := env.ExecuteActivity(VerifyExecutionMapsActivity, execution)
+	s.NoError(err)
+	var report ExecutionMapsReport
+	s.NoError(result.Get(&report))
+	s.Equal(execution, report.Execution)
+	s.Equal([]ExecutionMapsInconsistency{
+		{Table: activityInfoMapsTable, Key: "5", Reason: "key does not match the event ID 4 of the entry"},
+	}, report.Inconsistencies)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/reconciliation/entity"
+	"github.com/uber/cadence/common/types"
+)
+
+const (
+	activityInfoMapsTable       = "activity_info_maps"
+	timerInfoMapsTable          = "timer_info_maps"
+	childExecutionInfoMapsTable = "child_execution_info_maps"
+	requestCancelInfoMapsTable  = "request_cancel_info_maps"
+	signalInfoMapsTable         = "signal_info_maps"
+	signalsRequestedSetsTable   = "signals_requested_sets"
+)
+
+type (
+	// ExecutionMapsReport is the result of VerifyExecutionMapsActivity
+	ExecutionMapsReport struct {
+		Execution       entity.Execution
+		Inconsistencies []ExecutionMapsInconsistency
+	}
+
+	// ExecutionMapsInconsistency describes a single inconsistent entry of an execution map
+	ExecutionMapsInconsistency struct {
+		Table  string
+		Key    string
+		Reason string
+	}
+)
+
+// VerifyExecutionMapsActivity loads the mutable state of a single execution and checks that its
+// activity, timer, child execution, request cancel, signal and signals requested maps are internally consistent.
+// It only reads from persistence, the returned report lists the inconsistencies found, if any.
+func VerifyExecutionMapsActivity(ctx context.Context, execution entity.Execution) (*ExecutionMapsReport, error) {
+	pr, domainCache, err := getDefaultDAO(ctx, execution.ShardID)
+	if err != nil {
+		return nil, err
+	}
+	domainName, err := domainCache.GetDomainName(execution.DomainID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pr.GetWorkflowExecution(ctx, &persistence.GetWorkflowExecutionRequest{
+		DomainID: execution.DomainID,
+		Execution: types.WorkflowExecution{
+			WorkflowID: execution.WorkflowID,
+			RunID:      execution.RunID,
+		},
+		DomainName: domainName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ExecutionMapsReport{
+		Execution:       execution,
+		Inconsistencies: verifyExecutionMaps(resp.State),
+	}, nil
+}
+
+func verifyExecutionMaps(state *persistence.WorkflowMutableState) []ExecutionMapsInconsistency {
+	var result []ExecutionMapsInconsistency
+	report := func(table string, key string, format string, args ...interface{}) {
+		result = append(result, ExecutionMapsInconsistency{
+			Table:  table,
+			Key:    key,
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+	nextEventID := state.ExecutionInfo.NextEventID
+	checkEventID := func(table string, key int64, eventID int64) {
+		k := strconv.FormatInt(key, 10)
+		if key != eventID {
+			report(table, k, "key does not match the event ID %v of the entry", eventID)
+		}
+		if eventID >= nextEventID {
+			report(table, k, "event ID %v is not before the next event ID %v", eventID, nextEventID)
+		}
+	}
+
+	activityIDs := make(map[string]int64, len(state.ActivityInfos))
+	for scheduleID, info := range state.ActivityInfos {
+		checkEventID(activityInfoMapsTable, scheduleID, info.ScheduleID)
+		if other, ok := activityIDs[info.ActivityID]; ok {
+			first, second := other, scheduleID
+			if first > second {
+				first, second = second, first
+			}
+			report(activityInfoMapsTable, strconv.FormatInt(second, 10), "activity ID %q is also used by schedule ID %v", info.ActivityID, first)
+		}
+		activityIDs[info.ActivityID] = scheduleID
+	}
+	for timerID, info := range state.TimerInfos {
+		if timerID != info.TimerID {
+			report(timerInfoMapsTable, timerID, "key does not match the timer ID %q of the entry", info.TimerID)
+		}
+		if info.StartedID >= nextEventID {
+			report(timerInfoMapsTable, timerID, "started ID %v is not before the next event ID %v", info.StartedID, nextEventID)
+		}
+	}
+	for initiatedID, info := range state.ChildExecutionInfos {
+		checkEventID(childExecutionInfoMapsTable, initiatedID, info.InitiatedID)
+	}
+	for initiatedID, info := range state.RequestCancelInfos {
+		checkEventID(requestCancelInfoMapsTable, initiatedID, info.InitiatedID)
+	}
+	for initiatedID, info := range state.SignalInfos {
+		checkEventID(signalInfoMapsTable, initiatedID, info.InitiatedID)
+	}
+	for signalID := range state.SignalRequestedIDs {
+		if signalID == "" {
+			report(signalsRequestedSetsTable, signalID, "signal ID is empty")
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Table != result[j].Table {
+			return result[i].Table < result[j].Table
+		}
+		if result[i].Key != result[j].Key {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Reason < result[j].Reason
+	})
+	return result
+}
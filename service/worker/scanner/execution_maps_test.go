@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common/persistence"
+)
+
+func TestVerifyExecutionMaps(t *testing.T) {
+	consistent := &persistence.WorkflowMutableState{
+		ExecutionInfo: &persistence.WorkflowExecutionInfo{NextEventID: 10},
+		ActivityInfos: map[int64]*persistence.ActivityInfo{
+			5: {ScheduleID: 5, ActivityID: "a"},
+			6: {ScheduleID: 6, ActivityID: "b"},
+		},
+		TimerInfos:          map[string]*persistence.TimerInfo{"t": {TimerID: "t", StartedID: 7}},
+		ChildExecutionInfos: map[int64]*persistence.ChildExecutionInfo{8: {InitiatedID: 8}},
+		RequestCancelInfos:  map[int64]*persistence.RequestCancelInfo{8: {InitiatedID: 8}},
+		SignalInfos:         map[int64]*persistence.SignalInfo{9: {InitiatedID: 9}},
+		SignalRequestedIDs:  map[string]struct{}{"s": {}},
+	}
+	assert.Empty(t, verifyExecutionMaps(consistent))
+
+	inconsistent := &persistence.WorkflowMutableState{
+		ExecutionInfo: &persistence.WorkflowExecutionInfo{NextEventID: 10},
+		ActivityInfos: map[int64]*persistence.ActivityInfo{
+			5: {ScheduleID: 5, ActivityID: "a"},
+			6: {ScheduleID: 6, ActivityID: "a"},
+		},
+		TimerInfos:          map[string]*persistence.TimerInfo{"t": {TimerID: "u", StartedID: 7}},
+		ChildExecutionInfos: map[int64]*persistence.ChildExecutionInfo{12: {InitiatedID: 12}},
+		RequestCancelInfos:  map[int64]*persistence.RequestCancelInfo{8: {InitiatedID: 7}},
+		SignalInfos:         map[int64]*persistence.SignalInfo{9: {InitiatedID: 9}},
+		SignalRequestedIDs:  map[string]struct{}{"": {}},
+	}
+	assert.Equal(t, []ExecutionMapsInconsistency{
+		{Table: activityInfoMapsTable, Key: "6", Reason: `activity ID "a" is also used by schedule ID 5`},
+		{Table: childExecutionInfoMapsTable, Key: "12", Reason: "event ID 12 is not before the next event ID 10"},
+		{Table: requestCancelInfoMapsTable, Key: "8", Reason: "key does not match the event ID 7 of the entry"},
+		{Table: signalsRequestedSetsTable, Key: "", Reason: "signal ID is empty"},
+		{Table: timerInfoMapsTable, Key: "t", Reason: `key does not match the timer ID "u" of the entry`},
+	}, verifyExecutionMaps(inconsistent))
+}
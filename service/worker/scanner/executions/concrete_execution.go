@@ -32,9 +32,11 @@ import (
 
 	"github.com/uber/cadence/common/blobstore"
 	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/dynamicconfig"
 	"github.com/uber/cadence/common/pagination"
 	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/reconciliation/fetcher"
 	"github.com/uber/cadence/common/reconciliation/invariant"
 	"github.com/uber/cadence/common/reconciliation/store"
 	"github.com/uber/cadence/service/worker/scanner/shardscanner"
@@ -42,8 +44,9 @@ import (
 
 const (
 	// ConcreteExecutionsScannerWFTypeName defines workflow type name for concrete executions scanner
-	ConcreteExecutionsScannerWFTypeName   = "cadence-sys-executions-scanner-workflow"
-	concreteExecutionsScannerWFID         = "cadence-sys-executions-scanner"
+	ConcreteExecutionsScannerWFTypeName = "cadence-sys-executions-scanner-workflow"
+	// ConcreteExecutionsScannerWFID is the workflow ID of the concrete executions scanner
+	ConcreteExecutionsScannerWFID         = "cadence-sys-executions-scanner"
 	concreteExecutionsScannerTaskListName = "cadence-sys-executions-scanner-tasklist-0"
 
 	// ConcreteExecutionsFixerWFTypeName defines workflow type name for concrete executions fixer
@@ -53,6 +56,7 @@ const (
 )
 
 // ConcreteScannerWorkflow starts concrete executions scanner.
+// The scanned executions can be restricted to open or closed ones with the ExecutionStateFilterConfigKey custom config.
 func ConcreteScannerWorkflow(ctx workflow.Context, params shardscanner.ScannerWorkflowParams) error {
 	wf, err := shardscanner.NewScannerWorkflow(ctx, ConcreteExecutionsScannerWFTypeName, params)
 	if err != nil {
@@ -110,6 +114,33 @@ func ScannerManager(
 	for _, fn := range ConcreteExecutionType.ToInvariants(collections) {
 		ivs = append(ivs, fn(pr, domainCache))
 	}
+	if rps := ParseMapDataDecodeRPS(params.ScannerConfig); rps > 0 {
+		if iv := mapDataDecodesInvariant(ctx, rps); iv != nil {
+			ivs = append(ivs, iv)
+		}
+	}
+	if rps := ParseMapHistoryCheckRPS(params.ScannerConfig); rps > 0 {
+		if iv := mapsMatchHistoryInvariant(ctx, pr, domainCache, rps); iv != nil {
+			ivs = append(ivs, iv)
+		}
+	}
+	if rps := ParseChildExecutionsCheckRPS(params.ScannerConfig); rps > 0 {
+		if iv := childExecutionsExistInvariant(ctx, rps); iv != nil {
+			ivs = append(ivs, iv)
+		}
+	}
+	if rps := ParseMapRowCountCheckRPS(params.ScannerConfig); rps > 0 {
+		if iv := mapRowCountMatchesInvariant(ctx, rps); iv != nil {
+			ivs = append(ivs, iv)
+		}
+	}
+	if target, rps := ParseMapDataReencode(params.ScannerConfig); target != "" && rps > 0 {
+		if sc, err := shardscanner.GetScannerContext(ctx); err == nil {
+			if iv := mapDataReencodeInvariant(sc.Config.Persistence, sc.Logger, target, rps); iv != nil {
+				ivs = append(ivs, iv)
+			}
+		}
+	}
 
 	return invariant.NewInvariantManager(ivs)
 }
@@ -120,9 +151,12 @@ func ScannerIterator(
 	pr persistence.Retryer,
 	params shardscanner.ScanShardActivityParams,
 ) pagination.Iterator {
-	it := ConcreteExecutionType.ToIterator()
-	return it(ctx, pr, params.PageSize)
-
+	filter := ParseExecutionStateFilter(params.ScannerConfig).ToConcreteExecutionFilter()
+	if filter == nil {
+		it := ConcreteExecutionType.ToIterator()
+		return it(ctx, pr, params.PageSize)
+	}
+	return fetcher.FilteredConcreteExecutionIterator(ctx, pr, params.PageSize, filter)
 }
 
 // FixerIterator provides iterator for concrete execution fixer.
@@ -131,7 +165,7 @@ func FixerIterator(ctx context.Context, client blobstore.Client, keys store.Keys
 }
 
 // FixerManager provides invariant manager for concrete execution fixer.
-func FixerManager(_ context.Context, pr persistence.Retryer, _ shardscanner.FixShardActivityParams, domainCache cache.DomainCache) invariant.Manager {
+func FixerManager(ctx context.Context, pr persistence.Retryer, _ shardscanner.FixShardActivityParams, domainCache cache.DomainCache) invariant.Manager {
 	var ivs []invariant.Invariant
 	var collections []invariant.Collection
 
@@ -140,6 +174,16 @@ func FixerManager(_ context.Context, pr persistence.Retryer, _ shardscanner.FixS
 	for _, fn := range ConcreteExecutionType.ToInvariants(collections) {
 		ivs = append(ivs, fn(pr, domainCache))
 	}
+	// the re-encode pass is configured when the fixer runs, not taken from the scan which found the executions
+	if fc, err := shardscanner.GetFixerContext(ctx); err == nil && fc.Config != nil && fc.Config.DynamicCollection != nil {
+		target := fc.Config.DynamicCollection.GetStringProperty(dynamicconfig.ConcreteExecutionsScannerMapDataReencodeEncoding)()
+		rps := fc.Config.DynamicCollection.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerMapDataReencodeRPS)()
+		if target != "" && rps > 0 {
+			if iv := mapDataReencodeInvariant(fc.Config.Persistence, fc.Logger, target, rps); iv != nil {
+				ivs = append(ivs, iv)
+			}
+		}
+	}
 	return invariant.NewInvariantManager(ivs)
 }
 
@@ -153,12 +197,35 @@ func ConcreteExecutionConfig(ctx shardscanner.Context) shardscanner.CustomScanne
 	if ctx.Config.DynamicCollection.GetBoolProperty(dynamicconfig.ConcreteExecutionsScannerInvariantCollectionMutableState)() {
 		res[invariant.CollectionMutableState.String()] = strconv.FormatBool(true)
 	}
+	if filter := ctx.Config.DynamicCollection.GetStringProperty(dynamicconfig.ConcreteExecutionsScannerExecutionStateFilter)(); filter != "" {
+		res[ExecutionStateFilterConfigKey] = filter
+	}
+	if ctx.Config.DynamicCollection.GetBoolProperty(dynamicconfig.ConcreteExecutionsScannerMapDataDecodeEnabled)() {
+		rps := ctx.Config.DynamicCollection.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerMapDataDecodeRPS)()
+		res[MapDataDecodeRPSConfigKey] = strconv.Itoa(rps)
+	}
+	if ctx.Config.DynamicCollection.GetBoolProperty(dynamicconfig.ConcreteExecutionsScannerMapHistoryCheckEnabled)() {
+		rps := ctx.Config.DynamicCollection.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerMapHistoryCheckRPS)()
+		res[MapHistoryCheckRPSConfigKey] = strconv.Itoa(rps)
+	}
+	if ctx.Config.DynamicCollection.GetBoolProperty(dynamicconfig.ConcreteExecutionsScannerChildExecutionsCheckEnabled)() {
+		rps := ctx.Config.DynamicCollection.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerChildExecutionsCheckRPS)()
+		res[ChildExecutionsCheckRPSConfigKey] = strconv.Itoa(rps)
+	}
+	if ctx.Config.DynamicCollection.GetBoolProperty(dynamicconfig.ConcreteExecutionsScannerMapRowCountCheckEnabled)() {
+		rps := ctx.Config.DynamicCollection.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerMapRowCountCheckRPS)()
+		res[MapRowCountCheckRPSConfigKey] = strconv.Itoa(rps)
+	}
+	if target := ctx.Config.DynamicCollection.GetStringProperty(dynamicconfig.ConcreteExecutionsScannerMapDataReencodeEncoding)(); target != "" {
+		res[MapDataReencodeEncodingConfigKey] = target
+		res[MapDataReencodeRPSConfigKey] = strconv.Itoa(ctx.Config.DynamicCollection.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerMapDataReencodeRPS)())
+	}
 
 	return res
 }
 
 // ConcreteExecutionScannerConfig configures concrete execution scanner
-func ConcreteExecutionScannerConfig(dc *dynamicconfig.Collection) *shardscanner.ScannerConfig {
+func ConcreteExecutionScannerConfig(dc *dynamicconfig.Collection, persistenceConfig *config.Persistence) *shardscanner.ScannerConfig {
 	return &shardscanner.ScannerConfig{
 		ScannerWFTypeName: ConcreteExecutionsScannerWFTypeName,
 		FixerWFTypeName:   ConcreteExecutionsFixerWFTypeName,
@@ -170,12 +237,14 @@ func ConcreteExecutionScannerConfig(dc *dynamicconfig.Collection) *shardscanner.
 			BlobstoreFlushThreshold: dc.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerBlobstoreFlushThreshold),
 			ActivityBatchSize:       dc.GetIntProperty(dynamicconfig.ConcreteExecutionsScannerActivityBatchSize),
 			AllowDomain:             dc.GetBoolPropertyFilteredByDomain(dynamicconfig.ConcreteExecutionFixerDomainAllow),
+			ShardOrder:              dc.GetStringProperty(dynamicconfig.ConcreteExecutionsScannerShardOrder),
 		},
 		DynamicCollection: dc,
+		Persistence:       persistenceConfig,
 		ScannerHooks:      ConcreteExecutionHooks,
 		FixerHooks:        ConcreteExecutionFixerHooks,
 		StartWorkflowOptions: cclient.StartWorkflowOptions{
-			ID:                           concreteExecutionsScannerWFID,
+			ID:                           ConcreteExecutionsScannerWFID,
 			TaskList:                     concreteExecutionsScannerTaskListName,
 			ExecutionStartToCloseTimeout: 20 * 365 * 24 * time.Hour,
 			WorkflowIDReusePolicy:        cclient.WorkflowIDReusePolicyAllowDuplicate,
@@ -28,6 +28,8 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/reconciliation/entity"
 	"github.com/uber/cadence/common/reconciliation/invariant"
 	"github.com/uber/cadence/common/reconciliation/store"
 
@@ -62,6 +64,7 @@ func (s *concreteExectionsWorkflowsSuite) TestScannerWorkflow_Success() {
 		},
 	}, nil)
 	env.OnActivity(shardscanner.ActivityScannerEmitMetrics, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(shardscanner.ActivityScannerPurgeFindings, mock.Anything).Return(int64(0), nil)
 	shards := shardscanner.Shards{
 		Range: &shardscanner.ShardRange{
 			Min: 0,
@@ -235,3 +238,45 @@ func (s *concreteExectionsWorkflowsSuite) TestScannerWorkflow_Success() {
 	}
 	s.Equal(shardscanner.ShardCorruptKeysResult(expectedCorrupted), shardCorruptKeysResult.Result)
 }
+
+func (s *concreteExectionsWorkflowsSuite) TestParseExecutionStateFilter() {
+	s.Equal(ExecutionStateFilterAll, ParseExecutionStateFilter(nil))
+	s.Equal(ExecutionStateFilterAll, ParseExecutionStateFilter(shardscanner.CustomScannerConfig{ExecutionStateFilterConfigKey: "unknown"}))
+	s.Equal(ExecutionStateFilterOpen, ParseExecutionStateFilter(shardscanner.CustomScannerConfig{ExecutionStateFilterConfigKey: "open"}))
+	s.Equal(ExecutionStateFilterClosed, ParseExecutionStateFilter(shardscanner.CustomScannerConfig{ExecutionStateFilterConfigKey: "closed"}))
+	s.Nil(ExecutionStateFilterAll.ToConcreteExecutionFilter())
+
+	running := &entity.ConcreteExecution{Execution: entity.Execution{State: persistence.WorkflowStateRunning}}
+	completed := &entity.ConcreteExecution{Execution: entity.Execution{State: persistence.WorkflowStateCompleted}}
+	open := ExecutionStateFilterOpen.ToConcreteExecutionFilter()
+	s.True(open(running))
+	s.False(open(completed))
+	closed := ExecutionStateFilterClosed.ToConcreteExecutionFilter()
+	s.False(closed(running))
+	s.True(closed(completed))
+}
+
+func (s *concreteExectionsWorkflowsSuite) TestParseMapDataDecodeRPS() {
+	s.Equal(0, ParseMapDataDecodeRPS(nil))
+	s.Equal(0, ParseMapDataDecodeRPS(shardscanner.CustomScannerConfig{MapDataDecodeRPSConfigKey: "fast"}))
+	s.Equal(0, ParseMapDataDecodeRPS(shardscanner.CustomScannerConfig{MapDataDecodeRPSConfigKey: "-1"}))
+	s.Equal(20, ParseMapDataDecodeRPS(shardscanner.CustomScannerConfig{MapDataDecodeRPSConfigKey: "20"}))
+}
+
+func (s *concreteExectionsWorkflowsSuite) TestParseMapHistoryCheckRPS() {
+	s.Equal(0, ParseMapHistoryCheckRPS(nil))
+	s.Equal(0, ParseMapHistoryCheckRPS(shardscanner.CustomScannerConfig{MapDataDecodeRPSConfigKey: "20"}))
+	s.Equal(5, ParseMapHistoryCheckRPS(shardscanner.CustomScannerConfig{MapHistoryCheckRPSConfigKey: "5"}))
+}
+
+func (s *concreteExectionsWorkflowsSuite) TestParseChildExecutionsCheckRPS() {
+	s.Equal(0, ParseChildExecutionsCheckRPS(nil))
+	s.Equal(0, ParseChildExecutionsCheckRPS(shardscanner.CustomScannerConfig{MapHistoryCheckRPSConfigKey: "5"}))
+	s.Equal(3, ParseChildExecutionsCheckRPS(shardscanner.CustomScannerConfig{ChildExecutionsCheckRPSConfigKey: "3"}))
+}
+
+func (s *concreteExectionsWorkflowsSuite) TestParseMapRowCountCheckRPS() {
+	s.Equal(0, ParseMapRowCountCheckRPS(nil))
+	s.Equal(0, ParseMapRowCountCheckRPS(shardscanner.CustomScannerConfig{ChildExecutionsCheckRPSConfigKey: "3"}))
+	s.Equal(4, ParseMapRowCountCheckRPS(shardscanner.CustomScannerConfig{MapRowCountCheckRPSConfigKey: "4"}))
+}
@@ -41,8 +41,8 @@ import (
 )
 
 const (
-	// currentExecutionsScannerWFID is the current execution scanner workflow ID
-	currentExecutionsScannerWFID = "cadence-sys-current-executions-scanner"
+	// CurrentExecutionsScannerWFID is the current execution scanner workflow ID
+	CurrentExecutionsScannerWFID = "cadence-sys-current-executions-scanner"
 	// CurrentExecutionsScannerWFTypeName is the current execution scanner workflow type
 	CurrentExecutionsScannerWFTypeName = "cadence-sys-current-executions-scanner-workflow"
 	// CurrentExecutionsScannerTaskListName is the current execution scanner workflow tasklist
@@ -147,7 +147,7 @@ func CurrentExecutionScannerConfig(dc *dynamicconfig.Collection) *shardscanner.S
 		ScannerHooks: CurrentExecutionsHooks,
 		FixerHooks:   CurrentExecutionFixerHooks,
 		StartWorkflowOptions: cclient.StartWorkflowOptions{
-			ID:                           currentExecutionsScannerWFID,
+			ID:                           CurrentExecutionsScannerWFID,
 			TaskList:                     CurrentExecutionsScannerTaskListName,
 			ExecutionStartToCloseTimeout: 20 * 365 * 24 * time.Hour,
 			WorkflowIDReusePolicy:        cclient.WorkflowIDReusePolicyAllowDuplicate,
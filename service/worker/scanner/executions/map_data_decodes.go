@@ -0,0 +1,177 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package executions
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/cache"
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/serialization"
+	sqlpersistence "github.com/uber/cadence/common/persistence/sql"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/invariant"
+	"github.com/uber/cadence/service/worker/scanner/shardscanner"
+)
+
+// mapDataDB is the connection to the default SQL store used to read execution map rows,
+// it is opened by the first scan which reads them and shared by all the scans of the worker
+var mapDataDB struct {
+	sync.Mutex
+	db     sqlplugin.DB
+	parser serialization.Parser
+}
+
+// mapDataDecodesInvariant returns the invariant which decodes the execution map rows of the scanned executions,
+// or nil if it can not be built, e.g. because the default store is not a SQL store
+func mapDataDecodesInvariant(ctx context.Context, rps int) invariant.Invariant {
+	sc, err := shardscanner.GetScannerContext(ctx)
+	if err != nil {
+		return nil
+	}
+	db, parser, err := openMapDataDB(sc.Config.Persistence)
+	if err != nil {
+		sc.Logger.Error("Failed to open SQL store, execution map data is not decoded", tag.Error(err))
+		return nil
+	}
+	return invariant.NewMapDataDecodes(db, parser, quotas.NewSimpleRateLimiter(rps))
+}
+
+// mapsMatchHistoryInvariant returns the invariant which reconciles the execution map rows of the scanned executions
+// with their history, or nil if it can not be built, e.g. because the default store is not a SQL store
+func mapsMatchHistoryInvariant(ctx context.Context, pr persistence.Retryer, domainCache cache.DomainCache, rps int) invariant.Invariant {
+	sc, err := shardscanner.GetScannerContext(ctx)
+	if err != nil {
+		return nil
+	}
+	db, _, err := openMapDataDB(sc.Config.Persistence)
+	if err != nil {
+		sc.Logger.Error("Failed to open SQL store, execution map rows are not reconciled with history", tag.Error(err))
+		return nil
+	}
+	return invariant.NewMapsMatchHistory(pr, domainCache, db, quotas.NewSimpleRateLimiter(rps))
+}
+
+// childExecutionsExistInvariant returns the invariant which looks up the current run of the started children of the
+// scanned executions, or nil if it can not be built, e.g. because the default store is not a SQL store or its plugin
+// can not read the children with their current runs
+func childExecutionsExistInvariant(ctx context.Context, rps int) invariant.Invariant {
+	sc, err := shardscanner.GetScannerContext(ctx)
+	if err != nil {
+		return nil
+	}
+	db, parser, err := openMapDataDB(sc.Config.Persistence)
+	if err != nil {
+		sc.Logger.Error("Failed to open SQL store, child executions are not checked", tag.Error(err))
+		return nil
+	}
+	reader, ok := db.(sqlplugin.ChildExecutionInfoMapsStatusReader)
+	if !ok {
+		sc.Logger.Error("SQL plugin can not read child executions with their current runs, child executions are not checked",
+			tag.StoreType(db.PluginName()))
+		return nil
+	}
+	return invariant.NewChildExecutionsExist(reader, parser, sc.Config.Persistence.NumHistoryShards, quotas.NewSimpleRateLimiter(rps))
+}
+
+// mapRowCountMatchesInvariant returns the invariant which compares the map row count of the scanned executions with
+// their execution map rows, or nil if it can not be built, e.g. because the default store is not a SQL store or its
+// plugin does not keep the count
+func mapRowCountMatchesInvariant(ctx context.Context, rps int) invariant.Invariant {
+	sc, err := shardscanner.GetScannerContext(ctx)
+	if err != nil {
+		return nil
+	}
+	db, _, err := openMapDataDB(sc.Config.Persistence)
+	if err != nil {
+		sc.Logger.Error("Failed to open SQL store, map row counts are not checked", tag.Error(err))
+		return nil
+	}
+	reader, ok := db.(sqlplugin.MapRowCountReader)
+	if !ok {
+		sc.Logger.Error("SQL plugin does not keep map row counts, map row counts are not checked", tag.StoreType(db.PluginName()))
+		return nil
+	}
+	return invariant.NewMapRowCountMatches(reader, quotas.NewSimpleRateLimiter(rps))
+}
+
+// mapDataReencodeInvariant returns the invariant which re-encodes the execution map rows of the scanned executions to
+// target, or nil if it can not be built, e.g. because the default store is not a SQL store or target has no encoder.
+// It is built by both the scanner and the fixer, so it takes the persistence config and logger of either context
+func mapDataReencodeInvariant(cfg *config.Persistence, logger log.Logger, target string, rps int) invariant.Invariant {
+	db, _, err := openMapDataDB(cfg)
+	if err != nil {
+		logger.Error("Failed to open SQL store, execution map rows are not re-encoded", tag.Error(err))
+		return nil
+	}
+	// the rows are decoded with the codecs configured for the store and encoded with target
+	parser, err := serialization.NewParser(common.EncodingType(target), sqlDecodingTypes(cfg.DataStores[cfg.DefaultStore].SQL)...)
+	if err != nil {
+		logger.Error("Failed to create the codec of the target encoding, execution map rows are not re-encoded", tag.Error(err))
+		return nil
+	}
+	return invariant.NewMapDataReencode(db, parser, target, quotas.NewSimpleRateLimiter(rps))
+}
+
+func openMapDataDB(cfg *config.Persistence) (sqlplugin.DB, serialization.Parser, error) {
+	mapDataDB.Lock()
+	defer mapDataDB.Unlock()
+
+	if mapDataDB.db != nil {
+		return mapDataDB.db, mapDataDB.parser, nil
+	}
+	if cfg == nil {
+		return nil, nil, errors.New("persistence config is not set")
+	}
+	ds, ok := cfg.DataStores[cfg.DefaultStore]
+	if !ok || ds.SQL == nil {
+		return nil, nil, errors.New("default store is not a SQL store")
+	}
+	// blobs are decoded with the codecs configured for the store, a row whose data_encoding is not one of them is reported
+	parser, err := serialization.NewParser(common.EncodingType(ds.SQL.EncodingType), sqlDecodingTypes(ds.SQL)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sqlpersistence.NewSQLDB(ds.SQL)
+	if err != nil {
+		return nil, nil, err
+	}
+	mapDataDB.db = db
+	mapDataDB.parser = parser
+	return db, parser, nil
+}
+
+func sqlDecodingTypes(cfg *config.SQL) []common.EncodingType {
+	var decodingTypes []common.EncodingType
+	for _, dt := range cfg.DecodingTypes {
+		decodingTypes = append(decodingTypes, common.EncodingType(dt))
+	}
+	return decodingTypes
+}
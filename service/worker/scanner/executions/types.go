@@ -48,6 +48,39 @@ const (
 // ScanType is the enum for representing different entity types to scan
 type ScanType int
 
+const (
+	// ExecutionStateFilterConfigKey is the CustomScannerConfig key of the execution state filter
+	ExecutionStateFilterConfigKey = "ExecutionStateFilter"
+	// MapDataDecodeRPSConfigKey is the CustomScannerConfig key of the number of executions per second whose
+	// execution map data blobs are decoded, it is only set when map data decoding is enabled
+	MapDataDecodeRPSConfigKey = "MapDataDecodeRPS"
+	// MapHistoryCheckRPSConfigKey is the CustomScannerConfig key of the number of executions per second whose execution
+	// map rows are reconciled with their history, it is only set when the check is enabled
+	MapHistoryCheckRPSConfigKey = "MapHistoryCheckRPS"
+	// ChildExecutionsCheckRPSConfigKey is the CustomScannerConfig key of the number of executions per second whose
+	// started children are looked up, it is only set when the check is enabled
+	ChildExecutionsCheckRPSConfigKey = "ChildExecutionsCheckRPS"
+	// MapRowCountCheckRPSConfigKey is the CustomScannerConfig key of the number of executions per second whose map row
+	// count is compared with their execution map rows, it is only set when the check is enabled
+	MapRowCountCheckRPSConfigKey = "MapRowCountCheckRPS"
+	// MapDataReencodeEncodingConfigKey is the CustomScannerConfig key of the encoding the execution map rows are
+	// re-encoded to, it is only set when the re-encode pass is enabled
+	MapDataReencodeEncodingConfigKey = "MapDataReencodeEncoding"
+	// MapDataReencodeRPSConfigKey is the CustomScannerConfig key of the number of executions per second whose execution
+	// map rows are checked for the re-encode pass
+	MapDataReencodeRPSConfigKey = "MapDataReencodeRPS"
+
+	// ExecutionStateFilterAll scans every execution
+	ExecutionStateFilterAll ExecutionStateFilter = "all"
+	// ExecutionStateFilterOpen scans only the executions which are not completed
+	ExecutionStateFilterOpen ExecutionStateFilter = "open"
+	// ExecutionStateFilterClosed scans only the completed executions
+	ExecutionStateFilterClosed ExecutionStateFilter = "closed"
+)
+
+// ExecutionStateFilter selects the executions scanned by their state
+type ExecutionStateFilter string
+
 type (
 	//InvariantFactory represents a function which returns Invariant
 	InvariantFactory func(retryer persistence.Retryer, domainCache cache.DomainCache) invariant.Invariant
@@ -136,3 +169,67 @@ func ParseCollections(params shardscanner.CustomScannerConfig) []invariant.Colle
 	}
 	return collections
 }
+
+// ParseExecutionStateFilter returns the execution state filter of the config, unknown or missing values scan every execution
+func ParseExecutionStateFilter(params shardscanner.CustomScannerConfig) ExecutionStateFilter {
+	switch filter := ExecutionStateFilter(params[ExecutionStateFilterConfigKey]); filter {
+	case ExecutionStateFilterOpen, ExecutionStateFilterClosed:
+		return filter
+	default:
+		return ExecutionStateFilterAll
+	}
+}
+
+// ParseMapDataDecodeRPS returns the number of executions per second whose execution map data blobs are decoded,
+// 0 means map data decoding is disabled
+func ParseMapDataDecodeRPS(params shardscanner.CustomScannerConfig) int {
+	return parseRPS(params, MapDataDecodeRPSConfigKey)
+}
+
+// ParseMapHistoryCheckRPS returns the number of executions per second whose execution map rows are reconciled with
+// their history, 0 means the check is disabled
+func ParseMapHistoryCheckRPS(params shardscanner.CustomScannerConfig) int {
+	return parseRPS(params, MapHistoryCheckRPSConfigKey)
+}
+
+// ParseChildExecutionsCheckRPS returns the number of executions per second whose started children are looked up,
+// 0 means the check is disabled
+func ParseChildExecutionsCheckRPS(params shardscanner.CustomScannerConfig) int {
+	return parseRPS(params, ChildExecutionsCheckRPSConfigKey)
+}
+
+// ParseMapRowCountCheckRPS returns the number of executions per second whose map row count is compared with their
+// execution map rows, 0 means the check is disabled
+func ParseMapRowCountCheckRPS(params shardscanner.CustomScannerConfig) int {
+	return parseRPS(params, MapRowCountCheckRPSConfigKey)
+}
+
+// ParseMapDataReencode returns the encoding the execution map rows are re-encoded to and the number of executions per
+// second which are checked, an empty encoding or 0 means the re-encode pass is disabled
+func ParseMapDataReencode(params shardscanner.CustomScannerConfig) (string, int) {
+	return params[MapDataReencodeEncodingConfigKey], parseRPS(params, MapDataReencodeRPSConfigKey)
+}
+
+func parseRPS(params shardscanner.CustomScannerConfig, key string) int {
+	rps, err := strconv.Atoi(params[key])
+	if err != nil || rps < 0 {
+		return 0
+	}
+	return rps
+}
+
+// ToConcreteExecutionFilter returns the fetcher filter of the execution state filter, nil for ExecutionStateFilterAll
+func (f ExecutionStateFilter) ToConcreteExecutionFilter() fetcher.ConcreteExecutionFilter {
+	switch f {
+	case ExecutionStateFilterOpen:
+		return func(e *entity.ConcreteExecution) bool {
+			return e.State != persistence.WorkflowStateCompleted
+		}
+	case ExecutionStateFilterClosed:
+		return func(e *entity.ConcreteExecution) bool {
+			return e.State == persistence.WorkflowStateCompleted
+		}
+	default:
+		return nil
+	}
+}
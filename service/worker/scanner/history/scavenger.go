@@ -22,6 +22,8 @@ package history
 
 import (
 	"context"
+	"encoding/json"
+	"reflect"
 	"time"
 
 	"go.uber.org/cadence/activity"
@@ -35,12 +37,20 @@ import (
 	"github.com/uber/cadence/common/log/tag"
 	"github.com/uber/cadence/common/metrics"
 	p "github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/quotas"
 	"github.com/uber/cadence/common/types"
 )
 
+// ScavengerHeartbeatDetailsVersion is bumped whenever the fields of ScavengerHeartbeatDetails change
+const ScavengerHeartbeatDetailsVersion = 1
+
 type (
-	// ScavengerHeartbeatDetails is the heartbeat detail for HistoryScavengerActivity
+	// ScavengerHeartbeatDetails is the heartbeat detail for HistoryScavengerActivity.
+	// Fields can be added, removed or change their type across versions, details recorded by another version
+	// are recovered with DecodeScavengerHeartbeatDetails
 	ScavengerHeartbeatDetails struct {
+		// Version is the ScavengerHeartbeatDetailsVersion of the code which recorded the details
+		Version       int
 		NextPageToken []byte
 		CurrentPage   int
 		SkipCount     int
@@ -54,7 +64,11 @@ type (
 		client                     history.Client
 		hbd                        ScavengerHeartbeatDetails
 		rps                        int
+		concurrency                int
+		maxHeartbeatDetailsSize    dynamicconfig.IntPropertyFn
 		limiter                    *rate.Limiter
+		domainRPS                  dynamicconfig.IntPropertyFnWithDomainFilter
+		domainLimiters             *quotas.Collection
 		maxWorkflowRetentionInDays dynamicconfig.IntPropertyFn
 		metrics                    metrics.Client
 		logger                     log.Logger
@@ -78,6 +92,8 @@ const (
 	// used this to decide how many goroutines to process
 	rpsPerConcurrency = 50
 	pageSize          = 1000
+	// maxConcurrency caps the goroutines, more than a page of tasks cannot be in flight
+	maxConcurrency = pageSize
 )
 
 // only clean up history branches that older than this threshold
@@ -89,6 +105,17 @@ func getHistoryCleanupThreshold(maxWorkflowRetentionInDays int) time.Duration {
 	return time.Hour * 24 * time.Duration(maxWorkflowRetentionInDays) * 2
 }
 
+// getConcurrency validates the configured concurrency, falling back to one goroutine per rpsPerConcurrency
+func getConcurrency(rps int, concurrency int) int {
+	if concurrency <= 0 {
+		concurrency = rps/rpsPerConcurrency + 1
+	}
+	if concurrency > maxConcurrency {
+		concurrency = maxConcurrency
+	}
+	return concurrency
+}
+
 // NewScavenger returns an instance of history scavenger daemon
 // The Scavenger can be started by calling the Run() method on the
 // returned object. Calling the Run() method will result in one
@@ -96,9 +123,21 @@ func getHistoryCleanupThreshold(maxWorkflowRetentionInDays int) time.Duration {
 // each branch, the scavenger will attempt
 //   - describe the corresponding workflow execution
 //   - deletion of history itself, if there are no workflow execution
+//
+// domainRPS optionally caps the rate spent on a single domain under the global rps,
+// a nil function or a non-positive value leaves the domain limited by rps only
+//
+// concurrency is the number of branches processed in parallel, a non-positive value
+// derives it from rps and values above maxConcurrency are capped
+//
+// maxHeartbeatDetailsSize optionally caps the size in bytes of the recorded heartbeat details,
+// a nil function or a non-positive value records the details as they are
 func NewScavenger(
 	db p.HistoryManager,
 	rps int,
+	domainRPS dynamicconfig.IntPropertyFnWithDomainFilter,
+	concurrency int,
+	maxHeartbeatDetailsSize dynamicconfig.IntPropertyFn,
 	client history.Client,
 	hbd ScavengerHeartbeatDetails,
 	metricsClient metrics.Client,
@@ -108,13 +147,21 @@ func NewScavenger(
 ) *Scavenger {
 
 	rateLimiter := rate.NewLimiter(rate.Limit(rps), rps)
+	domainLimiters := quotas.NewCollection(quotas.DynamicRateLimiterFactory(func(domain string) float64 {
+		return float64(domainRPS(domain))
+	}))
 
+	hbd.Version = ScavengerHeartbeatDetailsVersion
 	return &Scavenger{
 		db:                         db,
 		client:                     client,
 		hbd:                        hbd,
 		rps:                        rps,
+		concurrency:                getConcurrency(rps, concurrency),
+		maxHeartbeatDetailsSize:    maxHeartbeatDetailsSize,
 		limiter:                    rateLimiter,
+		domainRPS:                  domainRPS,
+		domainLimiters:             domainLimiters,
 		maxWorkflowRetentionInDays: maxWorkflowRetentionInDays,
 		metrics:                    metricsClient,
 		logger:                     logger,
@@ -122,16 +169,39 @@ func NewScavenger(
 	}
 }
 
+// DecodeScavengerHeartbeatDetails decodes heartbeat details recorded by any version of the scavenger as JSON,
+// each field is decoded on its own: unknown fields are ignored, and missing fields or fields which no longer
+// decode into their current type are left at their zero value instead of failing the whole recovery
+func DecodeScavengerHeartbeatDetails(data []byte) (ScavengerHeartbeatDetails, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ScavengerHeartbeatDetails{}, err
+	}
+	var hbd ScavengerHeartbeatDetails
+	value := reflect.ValueOf(&hbd).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		raw, ok := fields[value.Type().Field(i).Name]
+		if !ok {
+			continue
+		}
+		field := reflect.New(value.Field(i).Type())
+		if err := json.Unmarshal(raw, field.Interface()); err == nil {
+			value.Field(i).Set(field.Elem())
+		}
+	}
+	return hbd, nil
+}
+
 // Run runs the scavenger
 func (s *Scavenger) Run(ctx context.Context) (ScavengerHeartbeatDetails, error) {
 	taskCh := make(chan taskDetail, pageSize)
 	respCh := make(chan error, pageSize)
-	concurrency := s.rps/rpsPerConcurrency + 1
-
-	for i := 0; i < concurrency; i++ {
+	for i := 0; i < s.concurrency; i++ {
 		go s.startTaskProcessor(ctx, taskCh, respCh)
 	}
 
+	heartbeatDetails := s.getHeartbeatDetails()
+
 	for {
 		resp, err := s.db.GetAllHistoryTreeBranches(ctx, &p.GetAllHistoryTreeBranchesRequest{
 			PageSize:      pageSize,
@@ -169,7 +239,7 @@ func (s *Scavenger) Run(ctx context.Context) (ScavengerHeartbeatDetails, error)
 				treeID:     br.TreeID,
 				branchID:   br.BranchID,
 
-				hbd: s.hbd,
+				hbd: heartbeatDetails,
 			}
 		}
 
@@ -202,8 +272,9 @@ func (s *Scavenger) Run(ctx context.Context) (ScavengerHeartbeatDetails, error)
 		s.hbd.SuccCount += succCount
 		s.hbd.ErrorCount += errCount + errorsOnSplitting
 		s.hbd.SkipCount += skips
+		heartbeatDetails = s.getHeartbeatDetails()
 		if !s.isInTest {
-			activity.RecordHeartbeat(ctx, s.hbd)
+			activity.RecordHeartbeat(ctx, heartbeatDetails)
 		}
 
 		if len(s.hbd.NextPageToken) == 0 {
@@ -228,10 +299,13 @@ func (s *Scavenger) startTaskProcessor(
 			}
 
 			if !s.isInTest {
-				activity.RecordHeartbeat(ctx, s.hbd)
+				activity.RecordHeartbeat(ctx, task.hbd)
 			}
 
-			err := s.limiter.Wait(ctx)
+			err := s.waitForDomain(ctx, task.domainID)
+			if err == nil {
+				err = s.limiter.Wait(ctx)
+			}
 			if err != nil {
 				respCh <- err
 				s.logger.Error("encounter error when wait for rate limiter",
@@ -299,6 +373,64 @@ func (s *Scavenger) startTaskProcessor(
 	}
 }
 
+// getHeartbeatDetails returns the heartbeat details to record, compacted to fit under maxHeartbeatDetailsSize
+func (s *Scavenger) getHeartbeatDetails() ScavengerHeartbeatDetails {
+	if s.maxHeartbeatDetailsSize == nil || s.maxHeartbeatDetailsSize() <= 0 {
+		return s.hbd
+	}
+	maxSize := s.maxHeartbeatDetailsSize()
+	hbd, size, compacted := compactHeartbeatDetails(s.hbd, maxSize)
+	if compacted {
+		s.logger.Warn("scavenger: compacted heartbeat details which exceeded the size limit",
+			tag.Dynamic("heartbeat-details-size", size),
+			tag.Dynamic("heartbeat-details-max-size", maxSize),
+			tag.Dynamic("heartbeat-details-page-token-dropped", hbd.NextPageToken == nil && s.hbd.NextPageToken != nil))
+	}
+	return hbd
+}
+
+// compactHeartbeatDetails drops the least important fields of the details until their JSON encoding fits under maxSize bytes,
+// the counters are only informational and dropped first, the page token is dropped last since a retried activity
+// then starts over from the first page. It returns the size of the details before compaction and whether they were compacted.
+func compactHeartbeatDetails(hbd ScavengerHeartbeatDetails, maxSize int) (ScavengerHeartbeatDetails, int, bool) {
+	size := heartbeatDetailsSize(hbd)
+	if size <= maxSize {
+		return hbd, size, false
+	}
+	hbd.SkipCount, hbd.ErrorCount, hbd.SuccCount = 0, 0, 0
+	if heartbeatDetailsSize(hbd) <= maxSize {
+		return hbd, size, true
+	}
+	hbd.CurrentPage = 0
+	hbd.NextPageToken = nil
+	return hbd, size, true
+}
+
+func heartbeatDetailsSize(hbd ScavengerHeartbeatDetails) int {
+	data, err := json.Marshal(hbd)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// waitForDomain blocks until the per-domain limiter of the given domain allows one more call,
+// domains without a per-domain limit return right away and are only throttled by the global limiter
+func (s *Scavenger) waitForDomain(ctx context.Context, domainID string) error {
+	if s.domainRPS == nil {
+		return nil
+	}
+	domainName, err := s.domainCache.GetDomainName(domainID)
+	if err != nil {
+		// the domain may be deleted already, fall back to the global limiter
+		return nil
+	}
+	if s.domainRPS(domainName) <= 0 {
+		return nil
+	}
+	return s.domainLimiters.For(domainName).Wait(ctx)
+}
+
 func getTaskLoggingTags(err error, task taskDetail) []tag.Tag {
 	if err != nil {
 		return []tag.Tag{
@@ -22,7 +22,9 @@ package history
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,7 +75,7 @@ func (s *ScavengerTestSuite) createTestScavenger(rps int) (*mocks.HistoryV2Manag
 	controller := gomock.NewController(s.T())
 	workflowClient := history.NewMockClient(controller)
 	maxWorkflowRetentionInDays := dynamicconfig.GetIntPropertyFn(dynamicconfig.MaxRetentionDays.DefaultInt())
-	scvgr := NewScavenger(db, rps, workflowClient, ScavengerHeartbeatDetails{}, s.metric, s.logger, maxWorkflowRetentionInDays, s.mockCache)
+	scvgr := NewScavenger(db, rps, nil, 0, nil, workflowClient, ScavengerHeartbeatDetails{}, s.metric, s.logger, maxWorkflowRetentionInDays, s.mockCache)
 	scvgr.isInTest = true
 	return db, workflowClient, scvgr, controller
 }
@@ -474,3 +476,95 @@ func (s *ScavengerTestSuite) TestMixesTwoPages() {
 	s.Equal(2, hbd.CurrentPage)
 	s.Equal(0, len(hbd.NextPageToken))
 }
+
+func (s *ScavengerTestSuite) TestWaitForDomain() {
+	db, _, scvgr, controller := s.createTestScavenger(100)
+	defer controller.Finish()
+	scvgr = NewScavenger(db, 100, func(domain string) int {
+		if domain == "limited-domain" {
+			return 1
+		}
+		return 0
+	}, 0, nil, nil, ScavengerHeartbeatDetails{}, s.metric, s.logger, nil, s.mockCache)
+	s.mockCache.EXPECT().GetDomainName("domainID1").Return("limited-domain", nil).AnyTimes()
+	s.mockCache.EXPECT().GetDomainName("domainID2").Return("unlimited-domain", nil).AnyTimes()
+	s.mockCache.EXPECT().GetDomainName("domainID3").Return("", fmt.Errorf("domain not found")).AnyTimes()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// the first call consumes the only token of the limited domain, the second one has to wait past the deadline
+	s.NoError(scvgr.waitForDomain(ctx, "domainID1"))
+	s.Error(scvgr.waitForDomain(ctx, "domainID1"))
+	for i := 0; i < 10; i++ {
+		s.NoError(scvgr.waitForDomain(ctx, "domainID2"))
+		s.NoError(scvgr.waitForDomain(ctx, "domainID3"))
+	}
+}
+
+func (s *ScavengerTestSuite) TestGetConcurrency() {
+	s.Equal(1, getConcurrency(5, 0))
+	s.Equal(3, getConcurrency(100, -1))
+	s.Equal(8, getConcurrency(100, 8))
+	s.Equal(maxConcurrency, getConcurrency(100, maxConcurrency+1))
+	s.Equal(maxConcurrency, getConcurrency(rpsPerConcurrency*maxConcurrency, 0))
+}
+
+func (s *ScavengerTestSuite) TestDecodeScavengerHeartbeatDetails() {
+	current, err := json.Marshal(ScavengerHeartbeatDetails{Version: ScavengerHeartbeatDetailsVersion, NextPageToken: []byte("token"), CurrentPage: 3, SuccCount: 5})
+	s.Require().NoError(err)
+	hbd, err := DecodeScavengerHeartbeatDetails(current)
+	s.Require().NoError(err)
+	s.Equal(ScavengerHeartbeatDetails{Version: ScavengerHeartbeatDetailsVersion, NextPageToken: []byte("token"), CurrentPage: 3, SuccCount: 5}, hbd)
+
+	// recorded by an older version without Version, and by a newer one with an unknown field and a changed type
+	hbd, err = DecodeScavengerHeartbeatDetails([]byte(`{"CurrentPage":2,"SkipCount":1}`))
+	s.Require().NoError(err)
+	s.Equal(ScavengerHeartbeatDetails{CurrentPage: 2, SkipCount: 1}, hbd)
+	hbd, err = DecodeScavengerHeartbeatDetails([]byte(`{"Version":2,"CurrentPage":4,"ErrorCount":"many","Shard":7}`))
+	s.Require().NoError(err)
+	s.Equal(ScavengerHeartbeatDetails{Version: 2, CurrentPage: 4}, hbd)
+
+	_, err = DecodeScavengerHeartbeatDetails([]byte("corrupted"))
+	s.Error(err)
+}
+
+func (s *ScavengerTestSuite) TestCompactHeartbeatDetails() {
+	hbd := ScavengerHeartbeatDetails{
+		Version:       ScavengerHeartbeatDetailsVersion,
+		NextPageToken: []byte(strings.Repeat("t", 100)),
+		CurrentPage:   12345,
+		SkipCount:     123456789,
+		ErrorCount:    123456789,
+		SuccCount:     123456789,
+	}
+	size := heartbeatDetailsSize(hbd)
+
+	compacted, originalSize, ok := compactHeartbeatDetails(hbd, size)
+	s.False(ok)
+	s.Equal(size, originalSize)
+	s.Equal(hbd, compacted)
+
+	// the counters are dropped first and the page token survives
+	compacted, _, ok = compactHeartbeatDetails(hbd, size-1)
+	s.True(ok)
+	s.Equal(ScavengerHeartbeatDetails{Version: hbd.Version, NextPageToken: hbd.NextPageToken, CurrentPage: hbd.CurrentPage}, compacted)
+	s.True(heartbeatDetailsSize(compacted) < size)
+
+	// the page token is dropped as a last resort
+	compacted, _, ok = compactHeartbeatDetails(hbd, 100)
+	s.True(ok)
+	s.Equal(ScavengerHeartbeatDetails{Version: hbd.Version}, compacted)
+}
+
+func (s *ScavengerTestSuite) TestGetHeartbeatDetails() {
+	db, _, scvgr, controller := s.createTestScavenger(100)
+	defer controller.Finish()
+	hbd := ScavengerHeartbeatDetails{Version: ScavengerHeartbeatDetailsVersion, NextPageToken: []byte(strings.Repeat("t", 1000)), CurrentPage: 3}
+	scvgr.hbd = hbd
+	s.Equal(hbd, scvgr.getHeartbeatDetails())
+
+	scvgr = NewScavenger(db, 100, nil, 0, dynamicconfig.GetIntPropertyFn(100), nil, hbd, s.metric, s.logger, nil, s.mockCache)
+	s.Equal(ScavengerHeartbeatDetails{Version: ScavengerHeartbeatDetailsVersion}, scvgr.getHeartbeatDetails())
+	// the details kept by the scavenger itself are not compacted
+	s.Equal(hbd, scvgr.hbd)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/workflow"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/service/worker/scanner/history"
+)
+
+const (
+	historyScavengerProgressActivityName = "cadence-sys-history-scanner-progress-activity"
+
+	// historyScavengerProgressChangeID versions the history scanner workflow which checks the progress of the scavenger
+	historyScavengerProgressChangeID = "history-scavenger-progress"
+)
+
+var (
+	historyScavengerProgressCheckInterval = 5 * time.Minute
+
+	historyScavengerProgressActivityOptions = workflow.ActivityOptions{
+		ScheduleToStartTimeout: 5 * time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	}
+)
+
+// historyScavengerProgress is the progress of the history scavenger activity the last time it was checked
+type historyScavengerProgress struct {
+	// CurrentPage and Processed are taken from the heartbeat details of the scavenger,
+	// Processed is the number of history branches it succeeded, failed or skipped
+	CurrentPage int
+	Processed   int
+	// Since is when the progress last moved
+	Since time.Time
+}
+
+// monitorHistoryScavengerProgress checks the progress of the scavenger activity every
+// historyScavengerProgressCheckInterval until it completes, see HistoryScavengerProgressActivity
+func monitorHistoryScavengerProgress(ctx workflow.Context, scavenger workflow.Future) {
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		progressCtx := workflow.WithActivityOptions(ctx, historyScavengerProgressActivityOptions)
+		var progress historyScavengerProgress
+		for {
+			if err := workflow.Sleep(ctx, historyScavengerProgressCheckInterval); err != nil || scavenger.IsReady() {
+				return
+			}
+			// a failed check keeps the last progress, the next check compares against it
+			var checked historyScavengerProgress
+			if err := workflow.ExecuteActivity(progressCtx, historyScavengerProgressActivityName, progress).Get(ctx, &checked); err != nil {
+				workflow.GetLogger(ctx).Warn("failed to check the progress of the history scavenger")
+				continue
+			}
+			progress = checked
+		}
+	})
+}
+
+// HistoryScavengerProgressActivity reads the heartbeat details of the running scavenger activity and returns its
+// progress. If the scavenger is heartbeating but has not moved since last for longer than the configured threshold,
+// it is alive but stuck, and the scavenger_stalled metric is emitted. A scavenger which stopped heartbeating is left
+// to its heartbeat timeout
+func HistoryScavengerProgressActivity(
+	activityCtx context.Context,
+	last historyScavengerProgress,
+) (historyScavengerProgress, error) {
+	ctx, err := getScannerContext(activityCtx)
+	if err != nil {
+		return last, err
+	}
+	res := ctx.resource
+	execution := activity.GetInfo(activityCtx).WorkflowExecution
+	resp, err := res.GetFrontendClient().DescribeWorkflowExecution(activityCtx, &types.DescribeWorkflowExecutionRequest{
+		Domain:    common.SystemLocalDomainName,
+		Execution: &types.WorkflowExecution{WorkflowID: execution.ID, RunID: execution.RunID},
+	})
+	if err != nil {
+		return last, err
+	}
+	now := ctx.clock.Now()
+	var scavenger *types.PendingActivityInfo
+	for _, pending := range resp.PendingActivities {
+		if pending.ActivityType != nil && pending.ActivityType.Name == historyScavengerActivityName {
+			scavenger = pending
+		}
+	}
+	if scavenger == nil || scavenger.LastHeartbeatTimestamp == nil ||
+		now.Sub(time.Unix(0, *scavenger.LastHeartbeatTimestamp)) > activityOptions.HeartbeatTimeout {
+		return last, nil
+	}
+
+	current := historyScavengerProgress{Since: now}
+	if len(scavenger.HeartbeatDetails) > 0 {
+		hbd, err := history.DecodeScavengerHeartbeatDetails(scavenger.HeartbeatDetails)
+		if err != nil {
+			return last, err
+		}
+		current.CurrentPage = hbd.CurrentPage
+		current.Processed = hbd.SuccCount + hbd.ErrorCount + hbd.SkipCount
+	}
+	if last.Since.IsZero() || current.CurrentPage != last.CurrentPage || current.Processed != last.Processed {
+		return current, nil
+	}
+
+	threshold := ctx.cfg.HistoryScannerStallAlertThreshold()
+	if stalled := now.Sub(last.Since); threshold > 0 && stalled >= threshold {
+		res.GetMetricsClient().IncCounter(metrics.HistoryScavengerScope, metrics.HistoryScavengerStalledCount)
+		res.GetLogger().Warn("History scavenger is heartbeating without making progress",
+			tag.Dynamic("stalled-for", stalled),
+			tag.Dynamic("current-page", last.CurrentPage),
+			tag.Counter(last.Processed))
+	}
+	return last, nil
+}
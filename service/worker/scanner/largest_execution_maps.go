@@ -0,0 +1,212 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/workflow"
+
+	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/metrics"
+	sqlpersistence "github.com/uber/cadence/common/persistence/sql"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/store"
+)
+
+const (
+	largestExecutionMapsWFTypeName       = "cadence-sys-largest-execution-maps-workflow"
+	largestExecutionMapsTaskListName     = "cadence-sys-largest-execution-maps-tasklist-0"
+	largestExecutionMapsActivityName     = "cadence-sys-largest-execution-maps-activity"
+	defaultLargestExecutionMapsTopN      = 100
+	largestExecutionMapsBlobstorePageLen = 100
+)
+
+type (
+	// LargestExecutionMapsParams are the parameters of LargestExecutionMapsWorkflow.
+	// The shards in [MinShardID, MaxShardID) are walked, TopN defaults to 100.
+	LargestExecutionMapsParams struct {
+		MinShardID int
+		MaxShardID int
+		TopN       int
+	}
+
+	// ExecutionMapsSize is the number of execution map rows of an execution and the bytes their serialized blobs use
+	ExecutionMapsSize struct {
+		ShardID    int
+		DomainID   string
+		WorkflowID string
+		RunID      string
+		RowCount   int64
+		DataSize   int64
+	}
+
+	// LargestExecutionMapsReport is the result of LargestExecutionMapsWorkflow
+	LargestExecutionMapsReport struct {
+		// Executions are the TopN executions with the largest maps across the walked shards, largest first
+		Executions []ExecutionMapsSize
+		// Keys are the blobstore keys the executions were written to, nil if no blobstore is configured
+		Keys *store.Keys
+	}
+
+	// largestExecutionMapsHeartbeatDetails allows a retried activity to resume after the last completed shard
+	largestExecutionMapsHeartbeatDetails struct {
+		NextShardID int
+		Executions  []ExecutionMapsSize
+	}
+)
+
+func init() {
+	workflow.RegisterWithOptions(LargestExecutionMapsWorkflow, workflow.RegisterOptions{Name: largestExecutionMapsWFTypeName})
+	activity.RegisterWithOptions(LargestExecutionMapsActivity, activity.RegisterOptions{Name: largestExecutionMapsActivityName})
+}
+
+// LargestExecutionMapsWorkflow reports the executions whose activity, timer, child execution, request cancel
+// and signal maps use the most storage. It is not scheduled, operators start it when hunting storage growth.
+func LargestExecutionMapsWorkflow(
+	ctx workflow.Context,
+	params LargestExecutionMapsParams,
+) (*LargestExecutionMapsReport, error) {
+
+	var report LargestExecutionMapsReport
+	future := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, activityOptions), largestExecutionMapsActivityName, params)
+	if err := future.Get(ctx, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// LargestExecutionMapsActivity walks the shards and keeps the TopN executions with the largest maps.
+// The result is written to the blobstore of the scanner, if configured, and the largest execution is emitted as gauges.
+func LargestExecutionMapsActivity(
+	activityCtx context.Context,
+	params LargestExecutionMapsParams,
+) (*LargestExecutionMapsReport, error) {
+
+	ctx, err := getScannerContext(activityCtx)
+	if err != nil {
+		return nil, err
+	}
+	res := ctx.resource
+	topN := params.TopN
+	if topN <= 0 {
+		topN = defaultLargestExecutionMapsTopN
+	}
+
+	db, err := openExecutionMapsSizeReader(ctx.cfg.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	hbd := largestExecutionMapsHeartbeatDetails{NextShardID: params.MinShardID}
+	if activity.HasHeartbeatDetails(activityCtx) {
+		if err := activity.GetHeartbeatDetails(activityCtx, &hbd); err != nil {
+			return nil, err
+		}
+	}
+	for ; hbd.NextShardID < params.MaxShardID; hbd.NextShardID++ {
+		rows, err := db.SelectLargestExecutionMaps(activityCtx, hbd.NextShardID, topN)
+		if err != nil {
+			return nil, err
+		}
+		hbd.Executions = mergeLargestExecutionMaps(hbd.Executions, rows, topN)
+		activity.RecordHeartbeat(activityCtx, largestExecutionMapsHeartbeatDetails{
+			NextShardID: hbd.NextShardID + 1,
+			Executions:  hbd.Executions,
+		})
+	}
+
+	report := &LargestExecutionMapsReport{Executions: hbd.Executions}
+	if client := res.GetBlobstoreClient(); client != nil && len(report.Executions) > 0 {
+		writer := store.NewBlobstoreWriter(uuid.New(), store.LargestExecutionMapsExtension, client, largestExecutionMapsBlobstorePageLen)
+		for _, e := range report.Executions {
+			if err := writer.Add(e); err != nil {
+				return nil, err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return nil, err
+		}
+		report.Keys = writer.FlushedKeys()
+	}
+	if len(report.Executions) > 0 {
+		scope := res.GetMetricsClient().Scope(metrics.LargestExecutionMapsScope)
+		scope.UpdateGauge(metrics.LargestExecutionMapsDataSizeGauge, float64(report.Executions[0].DataSize))
+		scope.UpdateGauge(metrics.LargestExecutionMapsRowCountGauge, float64(report.Executions[0].RowCount))
+	}
+	return report, nil
+}
+
+type executionMapsSizeReadCloser interface {
+	sqlplugin.ExecutionMapsSizeReader
+	Close() error
+}
+
+// openExecutionMapsSizeReader opens a connection to the default store, which has to be a SQL store
+// whose plugin can report the size of execution maps
+func openExecutionMapsSizeReader(cfg *config.Persistence) (executionMapsSizeReadCloser, error) {
+	db, err := openDefaultSQLDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	reader, ok := db.(executionMapsSizeReadCloser)
+	if !ok {
+		db.Close()
+		return nil, fmt.Errorf("SQL plugin %v does not support reporting the size of execution maps", db.PluginName())
+	}
+	return reader, nil
+}
+
+// openDefaultSQLDB opens a connection to the default store, which has to be a SQL store
+func openDefaultSQLDB(cfg *config.Persistence) (sqlplugin.DB, error) {
+	ds, ok := cfg.DataStores[cfg.DefaultStore]
+	if !ok || ds.SQL == nil {
+		return nil, errors.New("default store is not a SQL store")
+	}
+	return sqlpersistence.NewSQLDB(ds.SQL)
+}
+
+// mergeLargestExecutionMaps merges the rows of a shard into the current largest executions and keeps the topN largest
+func mergeLargestExecutionMaps(current []ExecutionMapsSize, rows []sqlplugin.ExecutionMapsSizeRow, topN int) []ExecutionMapsSize {
+	for _, row := range rows {
+		current = append(current, ExecutionMapsSize{
+			ShardID:    int(row.ShardID),
+			DomainID:   row.DomainID.String(),
+			WorkflowID: row.WorkflowID,
+			RunID:      row.RunID.String(),
+			RowCount:   row.RowCount,
+			DataSize:   row.DataSize,
+		})
+	}
+	sort.SliceStable(current, func(i, j int) bool {
+		return current[i].DataSize > current[j].DataSize
+	})
+	if len(current) > topN {
+		current = current[:topN]
+	}
+	return current
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence/testsuite"
+
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+func TestMergeLargestExecutionMaps(t *testing.T) {
+	domainID := serialization.MustParseUUID("8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10")
+	runID := serialization.MustParseUUID("2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b")
+	row := func(shardID int64, workflowID string, dataSize int64) sqlplugin.ExecutionMapsSizeRow {
+		return sqlplugin.ExecutionMapsSizeRow{
+			ShardID:    shardID,
+			DomainID:   domainID,
+			WorkflowID: workflowID,
+			RunID:      runID,
+			RowCount:   dataSize / 10,
+			DataSize:   dataSize,
+		}
+	}
+
+	largest := mergeLargestExecutionMaps(nil, []sqlplugin.ExecutionMapsSizeRow{row(0, "a", 300), row(0, "b", 100)}, 3)
+	largest = mergeLargestExecutionMaps(largest, []sqlplugin.ExecutionMapsSizeRow{row(1, "c", 200), row(1, "d", 50)}, 3)
+	largest = mergeLargestExecutionMaps(largest, nil, 3)
+
+	assert.Equal(t, []ExecutionMapsSize{
+		{ShardID: 0, DomainID: domainID.String(), WorkflowID: "a", RunID: runID.String(), RowCount: 30, DataSize: 300},
+		{ShardID: 1, DomainID: domainID.String(), WorkflowID: "c", RunID: runID.String(), RowCount: 20, DataSize: 200},
+		{ShardID: 0, DomainID: domainID.String(), WorkflowID: "b", RunID: runID.String(), RowCount: 10, DataSize: 100},
+	}, largest)
+}
+
+func TestLargestExecutionMapsWorkflow(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	params := LargestExecutionMapsParams{MinShardID: 0, MaxShardID: 16, TopN: 1}
+	report := &LargestExecutionMapsReport{
+		Executions: []ExecutionMapsSize{{ShardID: 3, WorkflowID: "a", RowCount: 10, DataSize: 1000}},
+	}
+	env.OnActivity(largestExecutionMapsActivityName, mock.Anything, params).Return(report, nil).Once()
+	env.ExecuteWorkflow(largestExecutionMapsWFTypeName, params)
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	var result LargestExecutionMapsReport
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, *report, result)
+	env.AssertExpectations(t)
+}
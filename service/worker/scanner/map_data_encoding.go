@@ -0,0 +1,385 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/workflow"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+const (
+	mapDataEncodingFixerWFTypeName   = "cadence-sys-map-data-encoding-fixer-workflow"
+	mapDataEncodingFixerTaskListName = "cadence-sys-map-data-encoding-fixer-tasklist-0"
+	mapDataEncodingFixerActivityName = "cadence-sys-map-data-encoding-fixer-activity"
+)
+
+var (
+	// mapDataEncodingProbes are the encodings a blob is decoded with to find out its actual encoding,
+	// proto is left out as its decoders are not implemented yet
+	mapDataEncodingProbes = []common.EncodingType{common.EncodingTypeThriftRW}
+
+	// mapDataEncodingTables are the execution map tables whose rows have a data blob
+	mapDataEncodingTables = []string{
+		activityInfoMapsTable,
+		timerInfoMapsTable,
+		childExecutionInfoMapsTable,
+		requestCancelInfoMapsTable,
+		signalInfoMapsTable,
+	}
+
+	errMapRowNotFound = errors.New("row not found")
+)
+
+type (
+	// MapDataEncodingRepairRequest flags the execution map rows of an execution whose data_encoding has to be repaired
+	MapDataEncodingRepairRequest struct {
+		Execution entity.Execution
+		// Rows are the flagged rows, no other row of the execution is touched
+		Rows []ExecutionMapRowKey
+	}
+
+	// ExecutionMapRowKey identifies a row of an execution map table, Key is the map key of the row
+	// i.e. the schedule ID, timer ID or initiated ID
+	ExecutionMapRowKey struct {
+		Table string
+		Key   string
+	}
+
+	// MapDataEncodingRepairReport is the result of repairing the flagged rows of an execution
+	MapDataEncodingRepairReport struct {
+		Execution entity.Execution
+		Repairs   []MapDataEncodingRepair
+	}
+
+	// MapDataEncodingRepair is the outcome for a single flagged row, Error is set if the row was left unchanged
+	MapDataEncodingRepair struct {
+		Table       string
+		Key         string
+		OldEncoding string
+		NewEncoding string
+		Error       string
+	}
+
+	mapDataEncodingRepairer struct {
+		logger  log.Logger
+		flagged map[ExecutionMapRowKey]bool
+		report  *MapDataEncodingRepairReport
+	}
+)
+
+func init() {
+	workflow.RegisterWithOptions(MapDataEncodingFixerWorkflow, workflow.RegisterOptions{Name: mapDataEncodingFixerWFTypeName})
+	activity.RegisterWithOptions(MapDataEncodingFixerActivity, activity.RegisterOptions{Name: mapDataEncodingFixerActivityName})
+}
+
+// MapDataEncodingFixerWorkflow rewrites the data_encoding of the flagged execution map rows whose blob
+// can not be decoded with the encoding recorded for it. It is not scheduled, operators start it with the rows to repair.
+func MapDataEncodingFixerWorkflow(
+	ctx workflow.Context,
+	requests []MapDataEncodingRepairRequest,
+) ([]MapDataEncodingRepairReport, error) {
+
+	var reports []MapDataEncodingRepairReport
+	future := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, activityOptions), mapDataEncodingFixerActivityName, requests)
+	if err := future.Get(ctx, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// MapDataEncodingFixerActivity repairs the flagged rows of every request, one execution after another.
+// For each row the blob is probed with the known decoders and data_encoding is replaced if exactly one of them decodes it.
+// Every change is logged.
+func MapDataEncodingFixerActivity(
+	activityCtx context.Context,
+	requests []MapDataEncodingRepairRequest,
+) ([]MapDataEncodingRepairReport, error) {
+
+	ctx, err := getScannerContext(activityCtx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDefaultSQLDB(ctx.cfg.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, mapDataEncodingProbes...)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []MapDataEncodingRepairReport
+	if activity.HasHeartbeatDetails(activityCtx) {
+		if err := activity.GetHeartbeatDetails(activityCtx, &reports); err != nil {
+			return nil, err
+		}
+	}
+	for len(reports) < len(requests) {
+		report, err := repairMapDataEncoding(activityCtx, db, parser, ctx.resource.GetLogger(), requests[len(reports)])
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+		activity.RecordHeartbeat(activityCtx, reports)
+	}
+	return reports, nil
+}
+
+// repairMapDataEncoding repairs the flagged rows of a single execution
+func repairMapDataEncoding(
+	ctx context.Context,
+	db sqlplugin.DB,
+	parser serialization.Parser,
+	logger log.Logger,
+	request MapDataEncodingRepairRequest,
+) (*MapDataEncodingRepairReport, error) {
+
+	execution := request.Execution
+	domainID, err := uuid.Parse(execution.DomainID)
+	if err != nil {
+		return nil, err
+	}
+	runID, err := uuid.Parse(execution.RunID)
+	if err != nil {
+		return nil, err
+	}
+	shardID := int64(execution.ShardID)
+	r := &mapDataEncodingRepairer{
+		logger: logger.WithTags(
+			tag.ShardID(execution.ShardID),
+			tag.WorkflowDomainID(execution.DomainID),
+			tag.WorkflowID(execution.WorkflowID),
+			tag.WorkflowRunID(execution.RunID),
+		),
+		flagged: make(map[ExecutionMapRowKey]bool, len(request.Rows)),
+		report:  &MapDataEncodingRepairReport{Execution: execution},
+	}
+	tables := make(map[string]bool)
+	for _, row := range request.Rows {
+		r.flagged[row] = true
+		tables[row.Table] = true
+	}
+
+	for _, table := range mapDataEncodingTables {
+		if !tables[table] {
+			continue
+		}
+		switch table {
+		case activityInfoMapsTable:
+			rows, err := db.SelectFromActivityInfoMaps(ctx, &sqlplugin.ActivityInfoMapsFilter{
+				ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				row := row
+				r.repair(table, strconv.FormatInt(row.ScheduleID, 10), row.DataEncoding,
+					func(encoding string) error {
+						_, err := parser.ActivityInfoFromBlob(row.Data, encoding)
+						return err
+					},
+					func(encoding string) error {
+						row.DataEncoding = encoding
+						_, err := db.ReplaceIntoActivityInfoMaps(ctx, []sqlplugin.ActivityInfoMapsRow{row})
+						return err
+					})
+			}
+		case timerInfoMapsTable:
+			rows, err := db.SelectFromTimerInfoMaps(ctx, &sqlplugin.TimerInfoMapsFilter{
+				ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				row := row
+				r.repair(table, row.TimerID, row.DataEncoding,
+					func(encoding string) error {
+						_, err := parser.TimerInfoFromBlob(row.Data, encoding)
+						return err
+					},
+					func(encoding string) error {
+						row.DataEncoding = encoding
+						_, err := db.ReplaceIntoTimerInfoMaps(ctx, []sqlplugin.TimerInfoMapsRow{row})
+						return err
+					})
+			}
+		case childExecutionInfoMapsTable:
+			rows, err := db.SelectFromChildExecutionInfoMaps(ctx, &sqlplugin.ChildExecutionInfoMapsFilter{
+				ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				row := row
+				r.repair(table, strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding,
+					func(encoding string) error {
+						_, err := parser.ChildExecutionInfoFromBlob(row.Data, encoding)
+						return err
+					},
+					func(encoding string) error {
+						row.DataEncoding = encoding
+						_, err := db.ReplaceIntoChildExecutionInfoMaps(ctx, []sqlplugin.ChildExecutionInfoMapsRow{row})
+						return err
+					})
+			}
+		case requestCancelInfoMapsTable:
+			rows, err := db.SelectFromRequestCancelInfoMaps(ctx, &sqlplugin.RequestCancelInfoMapsFilter{
+				ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				row := row
+				r.repair(table, strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding,
+					func(encoding string) error {
+						_, err := parser.RequestCancelInfoFromBlob(row.Data, encoding)
+						return err
+					},
+					func(encoding string) error {
+						row.DataEncoding = encoding
+						_, err := db.ReplaceIntoRequestCancelInfoMaps(ctx, []sqlplugin.RequestCancelInfoMapsRow{row})
+						return err
+					})
+			}
+		case signalInfoMapsTable:
+			rows, err := db.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+				ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				row := row
+				r.repair(table, strconv.FormatInt(row.InitiatedID, 10), row.DataEncoding,
+					func(encoding string) error {
+						_, err := parser.SignalInfoFromBlob(row.Data, encoding)
+						return err
+					},
+					func(encoding string) error {
+						row.DataEncoding = encoding
+						_, err := db.ReplaceIntoSignalInfoMaps(ctx, []sqlplugin.SignalInfoMapsRow{row})
+						return err
+					})
+			}
+		}
+	}
+
+	// flagged rows that are left were not found, or belong to a table without a data blob
+	for _, row := range request.Rows {
+		if !r.flagged[row] {
+			continue
+		}
+		delete(r.flagged, row)
+		err := errMapRowNotFound
+		if !tableHasDataBlob(row.Table) {
+			err = fmt.Errorf("table %v has no data blob", row.Table)
+		}
+		r.report.Repairs = append(r.report.Repairs, MapDataEncodingRepair{Table: row.Table, Key: row.Key, Error: err.Error()})
+	}
+	return r.report, nil
+}
+
+// repair rewrites the data_encoding of a row if it is flagged and its blob is decoded by exactly one known encoding
+func (r *mapDataEncodingRepairer) repair(
+	table string,
+	key string,
+	oldEncoding string,
+	decode func(encoding string) error,
+	replace func(encoding string) error,
+) {
+	rowKey := ExecutionMapRowKey{Table: table, Key: key}
+	if !r.flagged[rowKey] {
+		return
+	}
+	delete(r.flagged, rowKey)
+
+	result := MapDataEncodingRepair{Table: table, Key: key, OldEncoding: oldEncoding}
+	newEncoding, err := detectMapDataEncoding(oldEncoding, mapDataEncodingProbes, decode)
+	if err == nil {
+		err = replace(string(newEncoding))
+	}
+	if err != nil {
+		result.Error = err.Error()
+		r.logger.Warn("Map row data encoding not repaired",
+			tag.Name(table), tag.Key(key), tag.Dynamic("old-encoding", oldEncoding), tag.Error(err))
+	} else {
+		result.NewEncoding = string(newEncoding)
+		r.logger.Info("Map row data encoding repaired",
+			tag.Name(table), tag.Key(key), tag.Dynamic("old-encoding", oldEncoding), tag.Dynamic("new-encoding", result.NewEncoding))
+	}
+	r.report.Repairs = append(r.report.Repairs, result)
+}
+
+// detectMapDataEncoding probes the given encodings with decode and returns the only one that decodes the blob.
+// A blob that still decodes with its current encoding is not corrupt, and a blob that decodes with
+// several encodings is ambiguous, neither is repaired.
+func detectMapDataEncoding(
+	currentEncoding string,
+	probes []common.EncodingType,
+	decode func(encoding string) error,
+) (common.EncodingType, error) {
+
+	var matches []common.EncodingType
+	for _, encoding := range probes {
+		if decode(string(encoding)) != nil {
+			continue
+		}
+		if string(encoding) == currentEncoding {
+			return "", fmt.Errorf("data already decodes with encoding %v", currentEncoding)
+		}
+		matches = append(matches, encoding)
+	}
+	switch len(matches) {
+	case 0:
+		return "", errors.New("data does not decode with any known encoding")
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("data decodes with several encodings %v", matches)
+	}
+}
+
+func tableHasDataBlob(table string) bool {
+	for _, t := range mapDataEncodingTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
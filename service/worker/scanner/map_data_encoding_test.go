@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+// activityInfoMapsDB serves activity_info_maps from memory, every other method panics
+type activityInfoMapsDB struct {
+	sqlplugin.DB
+	rows     []sqlplugin.ActivityInfoMapsRow
+	replaced []sqlplugin.ActivityInfoMapsRow
+}
+
+func (db *activityInfoMapsDB) SelectFromActivityInfoMaps(ctx context.Context, filter *sqlplugin.ActivityInfoMapsFilter) ([]sqlplugin.ActivityInfoMapsRow, error) {
+	return db.rows, nil
+}
+
+func (db *activityInfoMapsDB) ReplaceIntoActivityInfoMaps(ctx context.Context, rows []sqlplugin.ActivityInfoMapsRow) (sql.Result, error) {
+	db.replaced = append(db.replaced, rows...)
+	return nil, nil
+}
+
+func TestDetectMapDataEncoding(t *testing.T) {
+	decodesWith := func(encodings ...common.EncodingType) func(string) error {
+		return func(encoding string) error {
+			for _, e := range encodings {
+				if string(e) == encoding {
+					return nil
+				}
+			}
+			return assert.AnError
+		}
+	}
+
+	probes := []common.EncodingType{common.EncodingTypeThriftRW, common.EncodingTypeProto}
+	encoding, err := detectMapDataEncoding("garbage", probes, decodesWith(common.EncodingTypeProto))
+	assert.NoError(t, err)
+	assert.Equal(t, common.EncodingTypeProto, encoding)
+
+	_, err = detectMapDataEncoding(string(common.EncodingTypeThriftRW), probes, decodesWith(common.EncodingTypeThriftRW))
+	assert.Error(t, err, "a blob that decodes with its encoding is not corrupt")
+	_, err = detectMapDataEncoding("garbage", probes, decodesWith())
+	assert.Error(t, err)
+	_, err = detectMapDataEncoding("garbage", probes, decodesWith(common.EncodingTypeThriftRW, common.EncodingTypeProto))
+	assert.Error(t, err, "an ambiguous blob is not repaired")
+}
+
+func TestRepairMapDataEncoding(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, mapDataEncodingProbes...)
+	require.NoError(t, err)
+	blob, err := parser.ActivityInfoToBlob(&serialization.ActivityInfo{Version: 1, ScheduledEventBatchID: 5})
+	require.NoError(t, err)
+
+	db := &activityInfoMapsDB{rows: []sqlplugin.ActivityInfoMapsRow{
+		{ShardID: 1, ScheduleID: 5, Data: blob.Data, DataEncoding: "garbage"},
+		{ShardID: 1, ScheduleID: 6, Data: blob.Data, DataEncoding: "garbage"},
+	}}
+	execution := entity.Execution{
+		ShardID:    1,
+		DomainID:   "8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10",
+		WorkflowID: "wid",
+		RunID:      "2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b",
+	}
+	report, err := repairMapDataEncoding(context.Background(), db, parser, log.NewNoop(), MapDataEncodingRepairRequest{
+		Execution: execution,
+		Rows: []ExecutionMapRowKey{
+			{Table: activityInfoMapsTable, Key: "5"},
+			{Table: activityInfoMapsTable, Key: "7"},
+			{Table: signalsRequestedSetsTable, Key: "signal"},
+		},
+	})
+	require.NoError(t, err)
+
+	// only the flagged row is rewritten
+	require.Len(t, db.replaced, 1)
+	assert.Equal(t, int64(5), db.replaced[0].ScheduleID)
+	assert.Equal(t, string(common.EncodingTypeThriftRW), db.replaced[0].DataEncoding)
+	assert.Equal(t, &MapDataEncodingRepairReport{
+		Execution: execution,
+		Repairs: []MapDataEncodingRepair{
+			{Table: activityInfoMapsTable, Key: "5", OldEncoding: "garbage", NewEncoding: string(common.EncodingTypeThriftRW)},
+			{Table: activityInfoMapsTable, Key: "7", Error: errMapRowNotFound.Error()},
+			{Table: signalsRequestedSetsTable, Key: "signal", Error: "table signals_requested_sets has no data blob"},
+		},
+	}, report)
+}
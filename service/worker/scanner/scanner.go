@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 
@@ -38,6 +39,7 @@ import (
 	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/dynamicconfig"
 	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/common/resource"
 	"github.com/uber/cadence/service/worker/scanner/shardscanner"
 	"github.com/uber/cadence/service/worker/scanner/tasklist"
@@ -57,7 +59,17 @@ type (
 		// ScannerPersistenceMaxQPS the max rate of calls to persistence
 		// Right now is being used by historyScanner to determine the rate of persistence API calls
 		ScannerPersistenceMaxQPS dynamicconfig.IntPropertyFn
-		// TaskListScannerEnabled indicates if taskList scanner should be started as part of scanner
+		// ScannerPersistenceMaxQPSPerDomain the max rate of calls to persistence for a single domain, applied under ScannerPersistenceMaxQPS
+		// Right now is being used by historyScanner so that a single large domain cannot consume the entire budget
+		ScannerPersistenceMaxQPSPerDomain dynamicconfig.IntPropertyFnWithDomainFilter
+		// ScannerHistoryScavengerConcurrency the number of history branches the history scavenger processes in parallel,
+		// a non-positive value derives it from ScannerPersistenceMaxQPS
+		ScannerHistoryScavengerConcurrency dynamicconfig.IntPropertyFn
+		// ScannerHistoryScavengerMaxHeartbeatDetailsSize the maximum size in bytes of the heartbeat details of the history scavenger,
+		// a non-positive value disables the cap
+		ScannerHistoryScavengerMaxHeartbeatDetailsSize dynamicconfig.IntPropertyFn
+		// TaskListScannerEnabled indicates if taskList scanner should be started as part of scanner,
+		// it is also checked at the start of every run so a started scanner can be turned off
 		TaskListScannerEnabled dynamicconfig.BoolPropertyFn
 		// TaskListScannerOptions contains options for TaskListScanner
 		TaskListScannerOptions tasklist.Options
@@ -65,11 +77,30 @@ type (
 		Persistence *config.Persistence
 		// ClusterMetadata contains the metadata for this cluster
 		ClusterMetadata cluster.Metadata
-		// HistoryScannerEnabled indicates if history scanner should be started as part of scanner
+		// HistoryScannerEnabled indicates if history scanner should be started as part of scanner,
+		// it is also checked at the start of every run so a started scanner can be turned off
 		HistoryScannerEnabled dynamicconfig.BoolPropertyFn
+		// HistoryScannerStallAlertThreshold is how long the history scavenger can go without progress while it is
+		// heartbeating before the history scanner alerts that it is stuck, 0 disables the alert
+		HistoryScannerStallAlertThreshold dynamicconfig.DurationPropertyFn
 		// ShardScanners is a list of shard scanner configs
 		ShardScanners              []*shardscanner.ScannerConfig
 		MaxWorkflowRetentionInDays dynamicconfig.IntPropertyFn
+		// DedicatedWorkerEnabled indicates if the task list, history and shard scanners run on the dedicated worker pool,
+		// whose workers have their own concurrency limits. It is read once when the scanner starts
+		DedicatedWorkerEnabled dynamicconfig.BoolPropertyFn
+		// DedicatedWorkerMaxConcurrentActivityExecutionSize is the activity concurrency of each dedicated worker
+		DedicatedWorkerMaxConcurrentActivityExecutionSize dynamicconfig.IntPropertyFn
+		// DedicatedWorkerMaxConcurrentDecisionTaskExecutionSize is the decision task concurrency of each dedicated worker
+		DedicatedWorkerMaxConcurrentDecisionTaskExecutionSize dynamicconfig.IntPropertyFn
+		// FindingsTableEnabled indicates if the shard scanners record their findings in the scanner_findings table
+		// of the default store as well. It is read once when the scanner starts
+		FindingsTableEnabled dynamicconfig.BoolPropertyFn
+		// ShardScanTimestampsEnabled indicates if the shard scanners record when they last scanned each shard
+		// in the scanner_shard_scans table of the default store. It is read once when the scanner starts
+		ShardScanTimestampsEnabled dynamicconfig.BoolPropertyFn
+		// FindingsRetention is how long the findings are kept in the scanner_findings table
+		FindingsRetention dynamicconfig.DurationPropertyFn
 	}
 
 	// BootstrapParams contains the set of params needed to bootstrap
@@ -86,6 +117,8 @@ type (
 	scannerContext struct {
 		resource resource.Resource
 		cfg      Config
+		// clock is used by the activities to wait between heartbeats, tests replace it with a fake clock
+		clock clockwork.Clock
 	}
 
 	// Scanner is the background sub-system that does full scans
@@ -95,6 +128,10 @@ type (
 		context    scannerContext
 		tallyScope tally.Scope
 		zapLogger  *zap.Logger
+		// findings is nil unless FindingsTableEnabled
+		findings sqlplugin.ScannerFindingsStore
+		// scanTimestamps is nil unless ShardScanTimestampsEnabled
+		scanTimestamps sqlplugin.ShardScanTimestampsStore
 	}
 )
 
@@ -116,6 +153,7 @@ func New(
 		context: scannerContext{
 			resource: resource,
 			cfg:      params.Config,
+			clock:    clockwork.NewRealClock(),
 		},
 		tallyScope: params.TallyScope,
 		zapLogger:  zapLogger.Named("scanner"),
@@ -126,12 +164,29 @@ func New(
 // Start starts the scanner
 func (s *Scanner) Start() error {
 	ctx := context.Background()
+	// scannerTaskListNames are the task lists of the scheduled scanners, they can run on the dedicated worker pool
+	var scannerTaskListNames []string
+	// workerTaskListNames are the task lists of the workflows which are only started on demand
 	var workerTaskListNames []string
 	var wtl []string
 
+	if s.context.cfg.FindingsTableEnabled() {
+		findings, err := openScannerFindingsStore(s.context.cfg.Persistence)
+		if err != nil {
+			return err
+		}
+		s.findings = findings
+	}
+	if s.context.cfg.ShardScanTimestampsEnabled() {
+		scanTimestamps, err := openShardScanTimestampsStore(s.context.cfg.Persistence)
+		if err != nil {
+			return err
+		}
+		s.scanTimestamps = scanTimestamps
+	}
 	for _, sc := range s.context.cfg.ShardScanners {
 		ctx, wtl = s.startShardScanner(ctx, sc)
-		workerTaskListNames = append(workerTaskListNames, wtl...)
+		scannerTaskListNames = append(scannerTaskListNames, wtl...)
 	}
 
 	if s.context.cfg.Persistence.DefaultStoreType() == config.StoreTypeSQL {
@@ -140,15 +195,25 @@ func (s *Scanner) Start() error {
 				ctx,
 				tlScannerWFStartOptions,
 				tlScannerWFTypeName)
-			workerTaskListNames = append(workerTaskListNames, tlScannerTaskListName)
+			scannerTaskListNames = append(scannerTaskListNames, tlScannerTaskListName)
 		}
+		// the largest execution maps, map data encoding fixer, signals consistency and signals requested set rebuild
+		// workflows are started on demand only, so just listen for them
+		ctx = NewScannerContext(ctx, largestExecutionMapsWFTypeName, s.context)
+		workerTaskListNames = append(workerTaskListNames, largestExecutionMapsTaskListName)
+		ctx = NewScannerContext(ctx, mapDataEncodingFixerWFTypeName, s.context)
+		workerTaskListNames = append(workerTaskListNames, mapDataEncodingFixerTaskListName)
+		ctx = NewScannerContext(ctx, signalsConsistencyWFTypeName, s.context)
+		workerTaskListNames = append(workerTaskListNames, signalsConsistencyTaskListName)
+		ctx = NewScannerContext(ctx, signalsRequestedSetRebuildWFTypeName, s.context)
+		workerTaskListNames = append(workerTaskListNames, signalsRequestedSetRebuildTaskListName)
 	}
 	if s.context.cfg.HistoryScannerEnabled() {
 		ctx = s.startScanner(
 			ctx,
 			historyScannerWFStartOptions,
 			historyScannerWFTypeName)
-		workerTaskListNames = append(workerTaskListNames, historyScannerTaskListName)
+		scannerTaskListNames = append(scannerTaskListNames, historyScannerTaskListName)
 	}
 
 	workerOpts := worker.Options{
@@ -158,7 +223,16 @@ func (s *Scanner) Start() error {
 		MaxConcurrentDecisionTaskExecutionSize: maxConcurrentDecisionTaskExecutionSize,
 		BackgroundActivityContext:              ctx,
 	}
+	scannerWorkerOpts := workerOpts
+	if s.context.cfg.DedicatedWorkerEnabled() {
+		scannerWorkerOpts = dedicatedWorkerOptions(workerOpts, s.context.cfg)
+	}
 
+	for _, tl := range scannerTaskListNames {
+		if err := worker.New(s.context.resource.GetSDKClient(), common.SystemLocalDomainName, tl, scannerWorkerOpts).Start(); err != nil {
+			return err
+		}
+	}
 	for _, tl := range workerTaskListNames {
 		if err := worker.New(s.context.resource.GetSDKClient(), common.SystemLocalDomainName, tl, workerOpts).Start(); err != nil {
 			return err
@@ -167,6 +241,19 @@ func (s *Scanner) Start() error {
 	return nil
 }
 
+// dedicatedWorkerOptions returns the options of the dedicated scanner worker pool, limits which are not positive
+// fall back to the ones of the shared options
+func dedicatedWorkerOptions(shared worker.Options, cfg Config) worker.Options {
+	opts := shared
+	if n := cfg.DedicatedWorkerMaxConcurrentActivityExecutionSize(); n > 0 {
+		opts.MaxConcurrentActivityExecutionSize = n
+	}
+	if n := cfg.DedicatedWorkerMaxConcurrentDecisionTaskExecutionSize(); n > 0 {
+		opts.MaxConcurrentDecisionTaskExecutionSize = n
+	}
+	return opts
+}
+
 func (s *Scanner) startScanner(ctx context.Context, options client.StartWorkflowOptions, workflowName string) context.Context {
 	go workercommon.StartWorkflowWithRetry(workflowName, scannerStartUpDelay, s.context.resource, func(client client.Client) error {
 		return s.startWorkflow(client, options, workflowName, nil)
@@ -180,11 +267,13 @@ func (s *Scanner) startShardScanner(
 ) (context.Context, []string) {
 	var workerTaskListNames []string
 	if config.DynamicParams.ScannerEnabled() {
-		ctx = shardscanner.NewScannerContext(
-			ctx,
-			config.ScannerWFTypeName,
-			shardscanner.NewShardScannerContext(s.context.resource, config),
-		)
+		scannerContext := shardscanner.NewShardScannerContext(s.context.resource, config)
+		scannerContext.Findings = s.findings
+		if s.findings != nil {
+			scannerContext.FindingsRetention = s.context.cfg.FindingsRetention
+		}
+		scannerContext.ScanTimestamps = s.scanTimestamps
+		ctx = shardscanner.NewScannerContext(ctx, config.ScannerWFTypeName, scannerContext)
 		go workercommon.StartWorkflowWithRetry(
 			config.ScannerWFTypeName,
 			scannerStartUpDelay,
@@ -270,3 +359,33 @@ func getScannerContext(ctx context.Context) (scannerContext, error) {
 	}
 	return val, nil
 }
+
+// openScannerFindingsStore opens a connection to the default store, which has to be a SQL store
+// whose plugin can store the findings of the scanners. The connection is kept open while the worker runs
+func openScannerFindingsStore(cfg *config.Persistence) (sqlplugin.ScannerFindingsStore, error) {
+	db, err := openDefaultSQLDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	findings, ok := db.(sqlplugin.ScannerFindingsStore)
+	if !ok {
+		db.Close()
+		return nil, fmt.Errorf("SQL plugin %v does not support storing scanner findings", db.PluginName())
+	}
+	return findings, nil
+}
+
+// openShardScanTimestampsStore opens a connection to the default store, which has to be a SQL store
+// whose plugin can record when the scanners last scanned each shard. The connection is kept open while the worker runs
+func openShardScanTimestampsStore(cfg *config.Persistence) (sqlplugin.ShardScanTimestampsStore, error) {
+	db, err := openDefaultSQLDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	scanTimestamps, ok := db.(sqlplugin.ShardScanTimestampsStore)
+	if !ok {
+		db.Close()
+		return nil, fmt.Errorf("SQL plugin %v does not support recording shard scan timestamps", db.PluginName())
+	}
+	return scanTimestamps, nil
+}
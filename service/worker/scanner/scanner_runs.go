@@ -0,0 +1,174 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	cclient "go.uber.org/cadence/client"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/service/worker/scanner/executions"
+	"github.com/uber/cadence/service/worker/scanner/timers"
+)
+
+const (
+	// TaskListScannerType is the scanner which scavenges tasks of tasklists
+	TaskListScannerType ScannerType = "tasklist"
+	// HistoryScannerType is the scanner which scavenges history branches
+	HistoryScannerType ScannerType = "history"
+	// ConcreteExecutionsScannerType is the shard scanner of concrete executions
+	ConcreteExecutionsScannerType ScannerType = "concrete_executions"
+	// CurrentExecutionsScannerType is the shard scanner of current executions
+	CurrentExecutionsScannerType ScannerType = "current_executions"
+	// TimersScannerType is the shard scanner of timers
+	TimersScannerType ScannerType = "timers"
+
+	// ScannerRunOpen is the status of a scanner run which has not closed yet
+	ScannerRunOpen ScannerRunStatus = "open"
+	// ScannerRunClosed is the status of a scanner run which has closed
+	ScannerRunClosed ScannerRunStatus = "closed"
+)
+
+type (
+	// ScannerType identifies one of the system scanner workflows
+	ScannerType string
+
+	// ScannerRunStatus is whether a scanner run is still open
+	ScannerRunStatus string
+
+	// ScannerRun summarizes a single run of a scanner workflow
+	ScannerRun struct {
+		WorkflowID string
+		RunID      string
+		StartTime  time.Time
+		// CloseTime is nil while the run is open
+		CloseTime *time.Time
+		Status    ScannerRunStatus
+		// Outcome is the close status of the run, e.g. COMPLETED or FAILED, empty while the run is open
+		Outcome string
+	}
+)
+
+var scannerWorkflowIDs = map[ScannerType]string{
+	TaskListScannerType:           tlScannerWFID,
+	HistoryScannerType:            historyScannerWFID,
+	ConcreteExecutionsScannerType: executions.ConcreteExecutionsScannerWFID,
+	CurrentExecutionsScannerType:  executions.CurrentExecutionsScannerWFID,
+	TimersScannerType:             timers.ScannerWFID,
+}
+
+// ListScannerRuns returns up to limit most recent runs of a scanner, open runs first and then newest first.
+// The runs are read from the visibility store of the system domain, so the client has to be able to reach it.
+func ListScannerRuns(
+	ctx context.Context,
+	client cclient.Client,
+	scannerType ScannerType,
+	limit int,
+) ([]ScannerRun, error) {
+
+	workflowID, ok := scannerWorkflowIDs[scannerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown scanner type %q", scannerType)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive, got %v", limit)
+	}
+
+	filter := &shared.WorkflowExecutionFilter{WorkflowId: common.StringPtr(workflowID)}
+	startTimeFilter := &shared.StartTimeFilter{
+		EarliestTime: common.Int64Ptr(0),
+		LatestTime:   common.Int64Ptr(time.Now().UnixNano()),
+	}
+
+	var runs []ScannerRun
+	var nextPageToken []byte
+	for {
+		resp, err := client.ListOpenWorkflow(ctx, &shared.ListOpenWorkflowExecutionsRequest{
+			Domain:          common.StringPtr(common.SystemLocalDomainName),
+			MaximumPageSize: common.Int32Ptr(int32(limit)),
+			NextPageToken:   nextPageToken,
+			StartTimeFilter: startTimeFilter,
+			ExecutionFilter: filter,
+		})
+		if err != nil {
+			return nil, err
+		}
+		runs = appendScannerRuns(runs, resp.Executions)
+		nextPageToken = resp.NextPageToken
+		if len(runs) >= limit || len(nextPageToken) == 0 {
+			break
+		}
+	}
+
+	nextPageToken = nil
+	for len(runs) < limit {
+		resp, err := client.ListClosedWorkflow(ctx, &shared.ListClosedWorkflowExecutionsRequest{
+			Domain:          common.StringPtr(common.SystemLocalDomainName),
+			MaximumPageSize: common.Int32Ptr(int32(limit - len(runs))),
+			NextPageToken:   nextPageToken,
+			StartTimeFilter: startTimeFilter,
+			ExecutionFilter: filter,
+		})
+		if err != nil {
+			return nil, err
+		}
+		runs = appendScannerRuns(runs, resp.Executions)
+		nextPageToken = resp.NextPageToken
+		if len(nextPageToken) == 0 {
+			break
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].Status != runs[j].Status {
+			return runs[i].Status == ScannerRunOpen
+		}
+		return runs[i].StartTime.After(runs[j].StartTime)
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func appendScannerRuns(runs []ScannerRun, infos []*shared.WorkflowExecutionInfo) []ScannerRun {
+	for _, info := range infos {
+		run := ScannerRun{
+			WorkflowID: info.GetExecution().GetWorkflowId(),
+			RunID:      info.GetExecution().GetRunId(),
+			StartTime:  time.Unix(0, info.GetStartTime()),
+			Status:     ScannerRunOpen,
+		}
+		if info.CloseStatus != nil {
+			closeTime := time.Unix(0, info.GetCloseTime())
+			run.CloseTime = &closeTime
+			run.Status = ScannerRunClosed
+			run.Outcome = info.GetCloseStatus().String()
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/mocks"
+
+	"github.com/uber/cadence/common"
+)
+
+func TestListScannerRuns(t *testing.T) {
+	now := time.Now()
+	info := func(runID string, start time.Time, closeStatus *shared.WorkflowExecutionCloseStatus) *shared.WorkflowExecutionInfo {
+		info := &shared.WorkflowExecutionInfo{
+			Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr(historyScannerWFID), RunId: common.StringPtr(runID)},
+			StartTime: common.Int64Ptr(start.UnixNano()),
+		}
+		if closeStatus != nil {
+			info.CloseTime = common.Int64Ptr(start.Add(time.Hour).UnixNano())
+			info.CloseStatus = closeStatus
+		}
+		return info
+	}
+	isHistoryScanner := func(filter *shared.WorkflowExecutionFilter) bool {
+		return filter.GetWorkflowId() == historyScannerWFID
+	}
+
+	client := &mocks.Client{}
+	client.On("ListOpenWorkflow", mock.Anything, mock.MatchedBy(func(req *shared.ListOpenWorkflowExecutionsRequest) bool {
+		return req.GetDomain() == common.SystemLocalDomainName && isHistoryScanner(req.ExecutionFilter)
+	})).Return(&shared.ListOpenWorkflowExecutionsResponse{
+		Executions: []*shared.WorkflowExecutionInfo{info("open", now, nil)},
+	}, nil).Once()
+	client.On("ListClosedWorkflow", mock.Anything, mock.MatchedBy(func(req *shared.ListClosedWorkflowExecutionsRequest) bool {
+		return req.NextPageToken == nil && req.GetMaximumPageSize() == 2 && isHistoryScanner(req.ExecutionFilter)
+	})).Return(&shared.ListClosedWorkflowExecutionsResponse{
+		Executions:    []*shared.WorkflowExecutionInfo{info("older", now.Add(-2*time.Hour), shared.WorkflowExecutionCloseStatusFailed.Ptr())},
+		NextPageToken: []byte("next"),
+	}, nil).Once()
+	client.On("ListClosedWorkflow", mock.Anything, mock.MatchedBy(func(req *shared.ListClosedWorkflowExecutionsRequest) bool {
+		return string(req.NextPageToken) == "next" && req.GetMaximumPageSize() == 1
+	})).Return(&shared.ListClosedWorkflowExecutionsResponse{
+		Executions: []*shared.WorkflowExecutionInfo{info("newer", now.Add(-time.Hour), shared.WorkflowExecutionCloseStatusCompleted.Ptr())},
+	}, nil).Once()
+
+	runs, err := ListScannerRuns(context.Background(), client, HistoryScannerType, 3)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	assert.Equal(t, "open", runs[0].RunID)
+	assert.Equal(t, ScannerRunOpen, runs[0].Status)
+	assert.Nil(t, runs[0].CloseTime)
+	assert.Empty(t, runs[0].Outcome)
+	assert.Equal(t, "newer", runs[1].RunID)
+	assert.Equal(t, ScannerRunClosed, runs[1].Status)
+	assert.Equal(t, now.UnixNano(), runs[1].CloseTime.UnixNano())
+	assert.Equal(t, "COMPLETED", runs[1].Outcome)
+	assert.Equal(t, "older", runs[2].RunID)
+	assert.Equal(t, "FAILED", runs[2].Outcome)
+	client.AssertExpectations(t)
+}
+
+func TestListScannerRuns_InvalidArguments(t *testing.T) {
+	client := &mocks.Client{}
+	_, err := ListScannerRuns(context.Background(), client, ScannerType("unknown"), 10)
+	assert.Error(t, err)
+	_, err = ListScannerRuns(context.Background(), client, TimersScannerType, 0)
+	assert.Error(t, err)
+	client.AssertExpectations(t)
+}
@@ -27,6 +27,9 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence/worker"
+
+	"github.com/uber/cadence/common/dynamicconfig"
 )
 
 type scannerTestSuite struct {
@@ -46,3 +49,17 @@ func (s *scannerTestSuite) SetupTest() {
 func (s *scannerTestSuite) TearDownTest() {
 	s.mockCtrl.Finish()
 }
+
+func (s *scannerTestSuite) TestDedicatedWorkerOptions() {
+	shared := worker.Options{
+		MaxConcurrentActivityExecutionSize:     maxConcurrentActivityExecutionSize,
+		MaxConcurrentDecisionTaskExecutionSize: maxConcurrentDecisionTaskExecutionSize,
+	}
+	opts := dedicatedWorkerOptions(shared, Config{
+		DedicatedWorkerMaxConcurrentActivityExecutionSize:     dynamicconfig.GetIntPropertyFn(50),
+		DedicatedWorkerMaxConcurrentDecisionTaskExecutionSize: dynamicconfig.GetIntPropertyFn(0),
+	})
+	s.Equal(50, opts.MaxConcurrentActivityExecutionSize)
+	s.Equal(maxConcurrentDecisionTaskExecutionSize, opts.MaxConcurrentDecisionTaskExecutionSize)
+	s.Equal(maxConcurrentActivityExecutionSize, shared.MaxConcurrentActivityExecutionSize)
+}
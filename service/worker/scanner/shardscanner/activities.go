@@ -50,6 +50,10 @@ const (
 	ActivityFixerCorruptedKeys = "cadence-sys-shardscanner-corruptedkeys-activity"
 	// ActivityFixShard is the activity name for FixShardActivity
 	ActivityFixShard = "cadence-sys-shardscanner-fixshard-activity"
+	// ActivityFixerEmitSummary is the activity name for FixerEmitSummaryActivity
+	ActivityFixerEmitSummary = "cadence-sys-shardscanner-fixer-emit-summary-activity"
+	// ActivityScannerPurgeFindings is the activity name for ScannerPurgeFindingsActivity
+	ActivityScannerPurgeFindings = "cadence-sys-shardscanner-purge-findings-activity"
 	// ShardCorruptKeysQuery is the query name for the query used to get all completed shards with at least one corruption
 	ShardCorruptKeysQuery = "shard_corrupt_keys"
 )
@@ -72,8 +76,12 @@ func ScannerConfigActivity(
 			PageSize:                dc.PageSize(),
 			BlobstoreFlushThreshold: dc.BlobstoreFlushThreshold(),
 			ActivityBatchSize:       dc.ActivityBatchSize(),
+			ShardOrder:              ShardOrderSequential,
 		},
 	}
+	if dc.ShardOrder != nil {
+		result.GenericScannerConfig.ShardOrder = ShardOrder(dc.ShardOrder())
+	}
 
 	if ctx.Hooks != nil && ctx.Hooks.GetScannerConfig != nil {
 		result.CustomScannerConfig = ctx.Hooks.GetScannerConfig(ctx)
@@ -96,11 +104,17 @@ func ScannerConfigActivity(
 	if overwrites.ActivityBatchSize != nil {
 		result.GenericScannerConfig.ActivityBatchSize = *overwrites.ActivityBatchSize
 	}
+	if overwrites.ShardOrder != nil {
+		result.GenericScannerConfig.ShardOrder = *overwrites.ShardOrder
+	}
 
 	if params.Overwrites.CustomScannerConfig != nil {
 		result.CustomScannerConfig = *params.Overwrites.CustomScannerConfig
 	}
 
+	if len(params.Shards) > 0 {
+		result.Shards = orderShards(activityCtx, ctx, params.Shards, result.GenericScannerConfig.ShardOrder)
+	}
 	return result, nil
 }
 
@@ -131,6 +145,9 @@ func ScanShardActivity(
 			ctx.Logger.Error("scanning shard", tag.Error(err))
 			return nil, err
 		}
+		if shardReport.Result.ControlFlowFailure == nil {
+			recordShardScan(activityCtx, ctx, currentShardID)
+		}
 		heartbeatDetails = ScanShardHeartbeatDetails{
 			LastShardIndexHandled: i,
 			Reports:               append(heartbeatDetails.Reports, *shardReport),
@@ -181,6 +198,8 @@ func scanShard(
 		func() { activity.RecordHeartbeat(activityCtx, heartbeatDetails) },
 		scope,
 		resources.GetDomainCache(),
+		ctx.Findings,
+		info.WorkflowExecution.RunID,
 	)
 	report := scanner.Scan(activityCtx)
 	if report.Result.ControlFlowFailure != nil {
@@ -372,6 +391,7 @@ func fixShard(
 		ctx.Hooks.Iterator(activityCtx, resource.GetBlobstoreClient(), corruptedKeys, params),
 		resource.GetBlobstoreClient(),
 		params.ResolvedFixerWorkflowConfig.BlobstoreFlushThreshold,
+		params.ResolvedFixerWorkflowConfig.DryRun,
 		func() { activity.RecordHeartbeat(activityCtx, heartbeatDetails) },
 		resource.GetDomainCache(),
 		ctx.Config.DynamicParams.AllowDomain,
@@ -419,3 +439,32 @@ func ScannerEmitMetricsActivity(
 	scope.UpdateGauge(metrics.ScannerShardSizeTenGauge, float64(shardStats.P10))
 	return nil
 }
+
+// FixerEmitSummaryActivity records the summary of a complete run of ShardFixer.
+// The summary is returned unchanged so that it is durably stored as the activity result in workflow history.
+func FixerEmitSummaryActivity(
+	activityCtx context.Context,
+	params FixerSummary,
+) (FixerSummary, error) {
+	ctx, err := GetFixerContext(activityCtx)
+	if err != nil {
+		return FixerSummary{}, err
+	}
+	info := activity.GetInfo(activityCtx)
+	agg := params.AggregateReportResult
+	ctx.Logger.Info("shard fixer completed",
+		tag.WorkflowType(info.WorkflowType.Name),
+		tag.WorkflowID(info.WorkflowExecution.ID),
+		tag.WorkflowRunID(info.WorkflowExecution.RunID),
+		tag.Value(params),
+	)
+	scope := ctx.Scope.Tagged(
+		metrics.ActivityTypeTag(ActivityFixerEmitSummary),
+		metrics.WorkflowTypeTag(info.WorkflowType.Name),
+		metrics.DomainTag(c.SystemLocalDomainName),
+	)
+	scope.UpdateGauge(metrics.CadenceShardSuccessGauge, float64(params.ShardSuccessCount))
+	scope.UpdateGauge(metrics.CadenceShardFailureGauge, float64(params.ShardControlFlowFailureCount))
+	scope.UpdateGauge(metrics.ScannerExecutionsGauge, float64(agg.EntitiesCount))
+	return params, nil
+}
@@ -44,6 +44,7 @@ import (
 	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/pagination"
 	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/common/reconciliation/entity"
 	"github.com/uber/cadence/common/reconciliation/invariant"
 	"github.com/uber/cadence/common/reconciliation/store"
@@ -69,6 +70,7 @@ func (s *activitiesSuite) SetupSuite() {
 	activity.Register(FixerCorruptedKeysActivity)
 	activity.Register(ScanShardActivity)
 	activity.Register(FixShardActivity)
+	activity.Register(ScannerPurgeFindingsActivity)
 }
 
 func (s *activitiesSuite) SetupTest() {
@@ -284,6 +286,7 @@ func (s *activitiesSuite) TestScannerConfigActivity() {
 					ActivityBatchSize:       10,
 					PageSize:                100,
 					BlobstoreFlushThreshold: 1000,
+					ShardOrder:              ShardOrderSequential,
 				},
 				CustomScannerConfig: CustomScannerConfig{
 					"test-key": "test-value",
@@ -308,6 +311,7 @@ func (s *activitiesSuite) TestScannerConfigActivity() {
 					ActivityBatchSize:       10,
 					PageSize:                100,
 					BlobstoreFlushThreshold: 1000,
+					ShardOrder:              ShardOrderSequential,
 				},
 			},
 		},
@@ -325,11 +329,13 @@ func (s *activitiesSuite) TestScannerConfigActivity() {
 						Enabled:                 common.BoolPtr(false),
 						ActivityBatchSize:       common.IntPtr(1),
 						BlobstoreFlushThreshold: common.IntPtr(100),
+						ShardOrder:              shardOrderPtr(ShardOrderLeastRecentlyScanned),
 					},
 					CustomScannerConfig: &CustomScannerConfig{
 						"test": "test",
 					},
 				},
+				Shards: []int{3, 1, 2},
 			},
 			resolved: ResolvedScannerWorkflowConfig{
 				GenericScannerConfig: GenericScannerConfig{
@@ -338,10 +344,13 @@ func (s *activitiesSuite) TestScannerConfigActivity() {
 					ActivityBatchSize:       1,
 					PageSize:                100,
 					BlobstoreFlushThreshold: 100,
+					ShardOrder:              ShardOrderLeastRecentlyScanned,
 				},
 				CustomScannerConfig: CustomScannerConfig{
 					"test": "test",
 				},
+				// the scan timestamps are not recorded, so the shards keep their order
+				Shards: []int{3, 1, 2},
 			},
 		},
 	}
@@ -501,3 +510,60 @@ func (s *activitiesSuite) getFixerActivityEnvironment() *testsuite.TestActivityE
 	})
 	return env
 }
+
+// fakeFindings deletes batchSize findings for each of the first full batches, then deleted of them
+type fakeFindings struct {
+	sqlplugin.ScannerFindingsStore
+	fullBatches int
+	deleted     int64
+	before      []time.Time
+}
+
+func (f *fakeFindings) DeleteScannerFindingsBefore(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	f.before = append(f.before, before)
+	if len(f.before) <= f.fullBatches {
+		return int64(batchSize), nil
+	}
+	return f.deleted, nil
+}
+
+func (s *activitiesSuite) TestScannerPurgeFindingsActivity() {
+	testCases := map[string]struct {
+		findings  *fakeFindings
+		retention time.Duration
+		purged    int64
+		batches   int
+	}{
+		"several batches":  {findings: &fakeFindings{fullBatches: 2, deleted: 5}, retention: time.Hour, purged: 2*findingsPurgeBatchSize + 5, batches: 3},
+		"nothing expired":  {findings: &fakeFindings{}, retention: time.Hour, batches: 1},
+		"purge disabled":   {findings: &fakeFindings{fullBatches: 2}},
+		"findings not set": {retention: time.Hour},
+	}
+	for name, tc := range testCases {
+		s.Run(name, func() {
+			sc := NewShardScannerContext(s.mockResource, &ScannerConfig{ScannerHooks: func() *ScannerHooks { return &ScannerHooks{} }})
+			if tc.findings != nil {
+				sc.Findings = tc.findings
+			}
+			sc.FindingsRetention = dynamicconfig.GetDurationPropertyFn(tc.retention)
+			env := s.NewTestActivityEnvironment()
+			env.SetWorkerOptions(worker.Options{
+				BackgroundActivityContext: NewScannerContext(context.Background(), testWorkflowName, sc),
+			})
+
+			start := time.Now()
+			value, err := env.ExecuteActivity(ScannerPurgeFindingsActivity)
+			s.NoError(err)
+			var purged int64
+			s.NoError(value.Get(&purged))
+			s.Equal(tc.purged, purged)
+			if tc.findings == nil {
+				return
+			}
+			s.Len(tc.findings.before, tc.batches)
+			for _, before := range tc.findings.before {
+				s.WithinDuration(start.Add(-tc.retention), before, time.Minute)
+			}
+		})
+	}
+}
@@ -169,11 +169,12 @@ type (
 		minShard int
 		maxShard int
 
-		reports       map[int]FixReport
-		domainStats   map[string]*FixStats
-		status        ShardStatusResult
-		statusSummary ShardStatusSummaryResult
-		aggregation   AggregateFixReportResult
+		reports        map[int]FixReport
+		domainStats    map[string]*FixStats
+		invariantStats map[invariant.Name]FixStats
+		status         ShardStatusResult
+		statusSummary  ShardStatusSummaryResult
+		aggregation    AggregateFixReportResult
 	}
 
 	// ShardScanResultAggregator is used to keep aggregated scan metrics
@@ -227,11 +228,12 @@ func NewShardFixResultAggregator(
 		minShard: minShard,
 		maxShard: maxShard,
 
-		reports:       make(map[int]FixReport),
-		domainStats:   make(map[string]*FixStats),
-		status:        status,
-		statusSummary: statusSummary,
-		aggregation:   AggregateFixReportResult{},
+		reports:        make(map[int]FixReport),
+		domainStats:    make(map[string]*FixStats),
+		invariantStats: make(map[invariant.Name]FixStats),
+		status:         status,
+		statusSummary:  statusSummary,
+		aggregation:    AggregateFixReportResult{},
 	}
 }
 
@@ -250,6 +252,11 @@ func (a *ShardFixResultAggregator) GetAggregation() AggregateFixReportResult {
 	return a.aggregation
 }
 
+// GetInvariantStats returns the fix stats aggregated by the invariant which determined the fix result.
+func (a *ShardFixResultAggregator) GetInvariantStats() map[invariant.Name]FixStats {
+	return a.invariantStats
+}
+
 // GetStatusResult returns paginated results for a range of shards
 func (a *ShardFixResultAggregator) GetStatusResult(req PaginatedShardQueryRequest) (*ShardStatusQueryResult, error) {
 	return getStatusResult(a.minShard, a.maxShard, req, a.status)
@@ -300,12 +307,24 @@ func (a *ShardFixResultAggregator) AddReport(report FixReport) {
 	}
 	if report.Result.ShardFixKeys != nil {
 		a.adjustAggregation(report.Stats, func(a, b int64) int64 { return a + b })
+		a.updateInvariantStats(report)
 	}
 	if report.DomainStats != nil {
 		a.updateDomainStats(report)
 	}
 }
 
+func (a *ShardFixResultAggregator) updateInvariantStats(report FixReport) {
+	for name, stats := range report.InvariantStats {
+		aggregateStats := a.invariantStats[name]
+		aggregateStats.EntitiesCount += stats.EntitiesCount
+		aggregateStats.FixedCount += stats.FixedCount
+		aggregateStats.SkippedCount += stats.SkippedCount
+		aggregateStats.FailedCount += stats.FailedCount
+		a.invariantStats[name] = aggregateStats
+	}
+}
+
 func (a *ShardFixResultAggregator) updateDomainStats(report FixReport) {
 	for domainID, domainStats := range report.DomainStats {
 		if _, ok := a.domainStats[domainID]; !ok {
@@ -290,10 +290,11 @@ func (s *aggregatorsSuite) TestShardScanResultAggregator() {
 func (s *aggregatorsSuite) TestShardFixResultAggregator() {
 	agg := NewShardFixResultAggregator([]CorruptedKeysEntry{{ShardID: 1}, {ShardID: 2}, {ShardID: 3}}, 1, 3)
 	expected := &ShardFixResultAggregator{
-		minShard:    1,
-		maxShard:    3,
-		reports:     map[int]FixReport{},
-		domainStats: map[string]*FixStats{},
+		minShard:       1,
+		maxShard:       3,
+		reports:        map[int]FixReport{},
+		domainStats:    map[string]*FixStats{},
+		invariantStats: map[invariant.Name]FixStats{},
 		status: map[int]ShardStatus{
 			1: ShardStatusRunning,
 			2: ShardStatusRunning,
@@ -320,6 +321,13 @@ func (s *aggregatorsSuite) TestShardFixResultAggregator() {
 			FixedCount:    3,
 			FailedCount:   1,
 		},
+		InvariantStats: map[invariant.Name]*FixStats{
+			invariant.HistoryExists: {
+				EntitiesCount: 4,
+				FixedCount:    3,
+				FailedCount:   1,
+			},
+		},
 		Result: FixResult{
 			ShardFixKeys: &FixKeys{
 				Fixed: &store.Keys{
@@ -336,6 +344,11 @@ func (s *aggregatorsSuite) TestShardFixResultAggregator() {
 	expected.aggregation.EntitiesCount = 10
 	expected.aggregation.FixedCount = 3
 	expected.aggregation.FailedCount = 1
+	expected.invariantStats[invariant.HistoryExists] = FixStats{
+		EntitiesCount: 4,
+		FixedCount:    3,
+		FailedCount:   1,
+	}
 	s.Equal(expected, agg)
 	report, err = agg.GetReport(1)
 	s.NoError(err)
@@ -347,6 +360,12 @@ func (s *aggregatorsSuite) TestShardFixResultAggregator() {
 			FixedCount:    3,
 			FailedCount:   1,
 		},
+		InvariantStats: map[invariant.Name]*FixStats{
+			invariant.HistoryExists: {
+				EntitiesCount: 1,
+				FixedCount:    1,
+			},
+		},
 		Result: FixResult{
 			ControlFlowFailure: &ControlFlowFailure{},
 		},
@@ -0,0 +1,69 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package shardscanner
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/cadence/activity"
+
+	"github.com/uber/cadence/common/log/tag"
+)
+
+const (
+	// purgeFindingsChangeID is the version of the scanner workflow which purges the expired findings once the scan completes
+	purgeFindingsChangeID = "purge-findings"
+	// findingsPurgeBatchSize is the number of findings deleted by each statement of a purge
+	findingsPurgeBatchSize = 1000
+)
+
+// ScannerPurgeFindingsActivity deletes the findings older than the findings retention from the scanner_findings table
+// and returns the number of findings deleted. The findings of all scanners share the table, so the purge of one scanner
+// also covers the findings of the others. It does nothing unless the findings are recorded in the table
+func ScannerPurgeFindingsActivity(activityCtx context.Context) (int64, error) {
+	ctx, err := GetScannerContext(activityCtx)
+	if err != nil {
+		return 0, err
+	}
+	if ctx.Findings == nil || ctx.FindingsRetention == nil {
+		return 0, nil
+	}
+	retention := ctx.FindingsRetention()
+	if retention <= 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(-retention)
+	var purged int64
+	for {
+		deleted, err := ctx.Findings.DeleteScannerFindingsBefore(activityCtx, before, findingsPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		purged += deleted
+		activity.RecordHeartbeat(activityCtx, purged)
+		if deleted < findingsPurgeBatchSize {
+			ctx.Logger.Info("Purged the expired scanner findings", tag.Counter(int(purged)), tag.Timestamp(before))
+			return purged, nil
+		}
+	}
+}
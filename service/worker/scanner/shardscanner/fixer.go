@@ -42,6 +42,7 @@ import (
 // 2. Attempting to fix any confirmed corrupted executions.
 // 3. Recording skipped entities, failed to fix entities and successfully fix entities to durable store.
 // 4. Producing a FixReport
+// A dry-run fixer does not attempt fixes, it records the changes the fixes would make instead of successful fixes.
 type Fixer interface {
 	Fix() FixReport
 }
@@ -61,6 +62,7 @@ type (
 		domainCache      cache.DomainCache
 		allowDomain      dynamicconfig.BoolPropertyFnWithDomainFilter
 		scope            metrics.Scope
+		dryRun           bool
 	}
 )
 
@@ -72,12 +74,17 @@ func NewFixer(
 	iterator store.ScanOutputIterator,
 	blobstoreClient blobstore.Client,
 	blobstoreFlushThreshold int,
+	dryRun bool,
 	progressReportFn func(),
 	domainCache cache.DomainCache,
 	allowDomain dynamicconfig.BoolPropertyFnWithDomainFilter,
 	scope metrics.Scope,
 ) *ShardFixer {
 	id := uuid.New()
+	fixedExtension := store.FixedExtension
+	if dryRun {
+		fixedExtension = store.DiffExtension
+	}
 
 	return &ShardFixer{
 		ctx:              ctx,
@@ -85,12 +92,13 @@ func NewFixer(
 		itr:              iterator,
 		skippedWriter:    store.NewBlobstoreWriter(id, store.SkippedExtension, blobstoreClient, blobstoreFlushThreshold),
 		failedWriter:     store.NewBlobstoreWriter(id, store.FailedExtension, blobstoreClient, blobstoreFlushThreshold),
-		fixedWriter:      store.NewBlobstoreWriter(id, store.FixedExtension, blobstoreClient, blobstoreFlushThreshold),
+		fixedWriter:      store.NewBlobstoreWriter(id, fixedExtension, blobstoreClient, blobstoreFlushThreshold),
 		invariantManager: manager,
 		progressReportFn: progressReportFn,
 		domainCache:      domainCache,
 		allowDomain:      allowDomain,
 		scope:            scope,
+		dryRun:           dryRun,
 	}
 }
 
@@ -100,6 +108,7 @@ func (f *ShardFixer) Fix() FixReport {
 	result := FixReport{
 		ShardID:     f.shardID,
 		DomainStats: map[string]*FixStats{},
+		DryRun:      f.dryRun,
 	}
 
 	for f.itr.HasNext() {
@@ -128,12 +137,15 @@ func (f *ShardFixer) Fix() FixReport {
 
 		var fixResult invariant.ManagerFixResult
 
-		if f.allowDomain(domainName) {
-			fixResult = f.invariantManager.RunFixes(f.ctx, soe.Execution)
-		} else {
+		switch {
+		case !f.allowDomain(domainName):
 			fixResult = invariant.ManagerFixResult{
 				FixResultType: invariant.FixResultTypeSkipped,
 			}
+		case f.dryRun:
+			fixResult = f.invariantManager.RunDiffs(f.ctx, soe.Execution)
+		default:
+			fixResult = f.invariantManager.RunFixes(f.ctx, soe.Execution)
 		}
 		result.Stats.EntitiesCount++
 		result.DomainStats[domainID].EntitiesCount++
@@ -148,11 +160,14 @@ func (f *ShardFixer) Fix() FixReport {
 			invariantName = string(*fixResult.DeterminingInvariantName)
 		}
 
-		f.scope.Tagged(
-			metrics.DomainTag(domainName),
-			metrics.InvariantTypeTag(invariantName),
-			metrics.ShardScannerFixResult(string(fixResult.FixResultType)),
-		).IncCounter(metrics.ShardScannerFix)
+		// a dry run fixes nothing, so it is only recorded in the report
+		if !f.dryRun {
+			f.scope.Tagged(
+				metrics.DomainTag(domainName),
+				metrics.InvariantTypeTag(invariantName),
+				metrics.ShardScannerFixResult(string(fixResult.FixResultType)),
+			).IncCounter(metrics.ShardScannerFix)
+		}
 
 		switch fixResult.FixResultType {
 		case invariant.FixResultTypeFixed:
@@ -188,6 +203,9 @@ func (f *ShardFixer) Fix() FixReport {
 		default:
 			panic(fmt.Sprintf("unknown FixResultType: %v", fixResult.FixResultType))
 		}
+		if fixResult.DeterminingInvariantName != nil {
+			countInvariantFix(&result, *fixResult.DeterminingInvariantName, fixResult.FixResultType)
+		}
 	}
 	if err := f.fixedWriter.Flush(); err != nil {
 		result.Result.ControlFlowFailure = &ControlFlowFailure{
@@ -217,3 +235,23 @@ func (f *ShardFixer) Fix() FixReport {
 	}
 	return result
 }
+
+func countInvariantFix(report *FixReport, name invariant.Name, resultType invariant.FixResultType) {
+	if report.InvariantStats == nil {
+		report.InvariantStats = make(map[invariant.Name]*FixStats)
+	}
+	stats, ok := report.InvariantStats[name]
+	if !ok {
+		stats = &FixStats{}
+		report.InvariantStats[name] = stats
+	}
+	stats.EntitiesCount++
+	switch resultType {
+	case invariant.FixResultTypeFixed:
+		stats.FixedCount++
+	case invariant.FixResultTypeSkipped:
+		stats.SkippedCount++
+	case invariant.FixResultTypeFailed:
+		stats.FailedCount++
+	}
+}
@@ -775,3 +775,104 @@ func (s *FixerSuite) TestFix_Success() {
 		},
 	}, result)
 }
+
+func (s *FixerSuite) TestFix_DryRun() {
+	mockItr := store.NewMockScanOutputIterator(s.controller)
+	iteratorCallNumber := 0
+	mockItr.EXPECT().HasNext().DoAndReturn(func() bool {
+		return iteratorCallNumber < 2
+	}).Times(3)
+	mockItr.EXPECT().Next().DoAndReturn(func() (*store.ScanOutputEntity, error) {
+		defer func() {
+			iteratorCallNumber++
+		}()
+		return &store.ScanOutputEntity{
+			Execution: &entity.ConcreteExecution{
+				Execution: entity.Execution{
+					DomainID: "test_domain",
+				},
+			},
+		}, nil
+	}).Times(2)
+	diff := invariant.ManagerFixResult{
+		FixResultType: invariant.FixResultTypeFixed,
+		FixResults: []invariant.FixResult{
+			{
+				FixResultType: invariant.FixResultTypeFixed,
+				InvariantName: invariant.HistoryExists,
+				Changes: []invariant.Change{
+					{Operation: invariant.ChangeOperationDelete, Table: "executions", Key: "key"},
+				},
+			},
+		},
+	}
+	// RunFixes is never called by a dry-run fixer
+	mockInvariantManager := invariant.NewMockManager(s.controller)
+	mockInvariantManager.EXPECT().RunDiffs(gomock.Any(), gomock.Any()).Return(diff).Times(2)
+	fixedWriter := store.NewMockExecutionWriter(s.controller)
+	fixedWriter.EXPECT().Add(gomock.Any()).DoAndReturn(func(e interface{}) error {
+		s.Equal(diff, e.(store.FixOutputEntity).Result)
+		return nil
+	}).Times(2)
+	fixedWriter.EXPECT().Flush().Return(nil)
+	fixedWriter.EXPECT().FlushedKeys().Return(&store.Keys{UUID: "fixed_keys_uuid", Extension: store.DiffExtension})
+	skippedWriter := store.NewMockExecutionWriter(s.controller)
+	skippedWriter.EXPECT().Flush().Return(nil)
+	skippedWriter.EXPECT().FlushedKeys().Return(nil)
+	failedWriter := store.NewMockExecutionWriter(s.controller)
+	failedWriter.EXPECT().Flush().Return(nil)
+	failedWriter.EXPECT().FlushedKeys().Return(nil)
+	domainCache := cache.NewMockDomainCache(s.controller)
+	domainCache.EXPECT().GetDomainName(gomock.Any()).Return("test-domain", nil).Times(2)
+	fixer := &ShardFixer{
+		shardID:          0,
+		itr:              mockItr,
+		invariantManager: mockInvariantManager,
+		skippedWriter:    skippedWriter,
+		failedWriter:     failedWriter,
+		fixedWriter:      fixedWriter,
+		progressReportFn: func() {},
+		domainCache:      domainCache,
+		allowDomain:      dynamicconfig.GetBoolPropertyFnFilteredByDomain(true),
+		scope:            metrics.NoopScope(metrics.Worker),
+		dryRun:           true,
+	}
+	result := fixer.Fix()
+	s.Equal(FixReport{
+		ShardID: 0,
+		Stats: FixStats{
+			EntitiesCount: 2,
+			FixedCount:    2,
+		},
+		Result: FixResult{
+			ShardFixKeys: &FixKeys{
+				Fixed: &store.Keys{UUID: "fixed_keys_uuid", Extension: store.DiffExtension},
+			},
+		},
+		DomainStats: map[string]*FixStats{
+			"test_domain": {
+				EntitiesCount: 2,
+				FixedCount:    2,
+			},
+		},
+		DryRun: true,
+	}, result)
+}
+
+func (s *FixerSuite) TestCountInvariantFix() {
+	report := FixReport{}
+	countInvariantFix(&report, invariant.HistoryExists, invariant.FixResultTypeFixed)
+	countInvariantFix(&report, invariant.HistoryExists, invariant.FixResultTypeFailed)
+	countInvariantFix(&report, invariant.OpenCurrentExecution, invariant.FixResultTypeSkipped)
+	s.Equal(map[invariant.Name]*FixStats{
+		invariant.HistoryExists: {
+			EntitiesCount: 2,
+			FixedCount:    1,
+			FailedCount:   1,
+		},
+		invariant.OpenCurrentExecution: {
+			EntitiesCount: 1,
+			SkippedCount:  1,
+		},
+	}, report.InvariantStats)
+}
@@ -27,6 +27,7 @@ import (
 	"errors"
 
 	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
 
 	"github.com/uber/cadence/common/blobstore"
 	"github.com/uber/cadence/common/cache"
@@ -36,7 +37,13 @@ import (
 )
 
 const (
+	// FixerSummaryQuery is the query name for the query used to get the summary of a completed fixer run
+	FixerSummaryQuery = "fixer_summary"
+
 	fixShardReportChan = "fixShardReportChan"
+
+	// fixerEmitSummaryChangeID is the version of the fixer workflow which records its summary with an activity once the fixes complete
+	fixerEmitSummaryChangeID = "fixer-emit-summary"
 )
 
 var (
@@ -84,6 +91,7 @@ type FixerWorkflow struct {
 	Aggregator *ShardFixResultAggregator
 	Params     FixerWorkflowParams
 	Keys       *FixerCorruptedKeysActivityResult
+	Summary    *FixerSummary
 }
 
 // NewFixerWorkflow returns a new instance of fixer workflow
@@ -117,6 +125,14 @@ func NewFixerWorkflow(
 			return nil, err
 		}
 	}
+	if err := workflow.SetQueryHandler(ctx, FixerSummaryQuery, func() (FixerSummary, error) {
+		if wf.Summary == nil {
+			return FixerSummary{}, errQueryNotReady
+		}
+		return *wf.Summary, nil
+	}); err != nil {
+		return nil, err
+	}
 
 	return &wf, nil
 }
@@ -164,6 +180,22 @@ func (fx *FixerWorkflow) Start(ctx workflow.Context) error {
 			i++
 		}
 	}
+
+	status := fx.Aggregator.GetStatusSummary()
+	summary := FixerSummary{
+		ShardSuccessCount:            status[ShardStatusSuccess],
+		ShardControlFlowFailureCount: status[ShardStatusControlFlowFailure],
+		AggregateReportResult:        fx.Aggregator.GetAggregation(),
+		InvariantStats:               fx.Aggregator.GetInvariantStats(),
+	}
+	fx.Summary = &summary
+	if workflow.GetVersion(ctx, fixerEmitSummaryChangeID, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return nil
+	}
+	// the summary is best-effort, the fixes are already done and must not be reported as failed
+	if err := workflow.ExecuteActivity(getShortActivityContext(ctx), ActivityFixerEmitSummary, summary).Get(ctx, &summary); err != nil {
+		workflow.GetLogger(ctx).Warn("failed to emit fixer summary", zap.Error(err))
+	}
 	return nil
 }
 
@@ -182,6 +214,9 @@ func resolveFixerConfig(overwrites FixerWorkflowConfigOverwrites) ResolvedFixerW
 	if overwrites.ActivityBatchSize != nil {
 		resolvedConfig.ActivityBatchSize = *overwrites.ActivityBatchSize
 	}
+	if overwrites.DryRun != nil {
+		resolvedConfig.DryRun = *overwrites.DryRun
+	}
 	return resolvedConfig
 }
 
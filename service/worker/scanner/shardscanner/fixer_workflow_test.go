@@ -55,6 +55,18 @@ func (s *fixerWorkflowSuite) TestResolveFixerConfig() {
 	}, result)
 }
 
+func (s *fixerWorkflowSuite) TestResolveFixerConfig_DryRun() {
+	result := resolveFixerConfig(FixerWorkflowConfigOverwrites{
+		DryRun: common.BoolPtr(true),
+	})
+	s.Equal(ResolvedFixerWorkflowConfig{
+		Concurrency:             25,
+		BlobstoreFlushThreshold: 1000,
+		ActivityBatchSize:       200,
+		DryRun:                  true,
+	}, result)
+}
+
 func (s *fixerWorkflowSuite) TestGetCorruptedKeysBatches() {
 	var keys []CorruptedKeysEntry
 	for i := 5; i < 50; i += 2 {
@@ -32,6 +32,7 @@ import (
 	"github.com/uber/cadence/common/cache"
 	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/pagination"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/common/reconciliation/entity"
 	"github.com/uber/cadence/common/reconciliation/invariant"
 	"github.com/uber/cadence/common/reconciliation/store"
@@ -60,7 +61,8 @@ type (
 	}
 )
 
-// NewScanner constructs a new ShardScanner
+// NewScanner constructs a new ShardScanner. The corrupted and failed executions are recorded in findings as well,
+// under scanID, unless findings is nil
 func NewScanner(
 	shardID int,
 	iterator pagination.Iterator,
@@ -70,14 +72,22 @@ func NewScanner(
 	progressReportFn func(),
 	scope metrics.Scope,
 	domainCache cache.DomainCache,
+	findings sqlplugin.ScannerFindingsStore,
+	scanID string,
 ) *ShardScanner {
 	id := uuid.New()
 
+	failedWriter := store.NewBlobstoreWriter(id, store.FailedExtension, blobstoreClient, blobstoreFlushThreshold)
+	corruptedWriter := store.NewBlobstoreWriter(id, store.CorruptedExtension, blobstoreClient, blobstoreFlushThreshold)
+	if findings != nil {
+		failedWriter = store.NewFindingsWriter(failedWriter, scanID, findings)
+		corruptedWriter = store.NewFindingsWriter(corruptedWriter, scanID, findings)
+	}
 	return &ShardScanner{
 		shardID:          shardID,
 		itr:              iterator,
-		failedWriter:     store.NewBlobstoreWriter(id, store.FailedExtension, blobstoreClient, blobstoreFlushThreshold),
-		corruptedWriter:  store.NewBlobstoreWriter(id, store.CorruptedExtension, blobstoreClient, blobstoreFlushThreshold),
+		failedWriter:     failedWriter,
+		corruptedWriter:  corruptedWriter,
 		invariantManager: manager,
 		progressReportFn: progressReportFn,
 		scope:            scope,
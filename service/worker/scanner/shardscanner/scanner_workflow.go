@@ -120,6 +120,7 @@ func (wf *ScannerWorkflow) Start(ctx workflow.Context) error {
 	var resolvedConfig ResolvedScannerWorkflowConfig
 	if err := workflow.ExecuteActivity(activityCtx, ActivityScannerConfig, ScannerConfigActivityParams{
 		Overwrites: wf.Params.ScannerWorkflowConfigOverwrites,
+		Shards:     wf.Shards,
 	}).Get(ctx, &resolvedConfig); err != nil {
 		return err
 	}
@@ -128,11 +129,17 @@ func (wf *ScannerWorkflow) Start(ctx workflow.Context) error {
 		return nil
 	}
 
+	// a config resolved before the shard order was introduced does not return the shards
+	shards := wf.Shards
+	if len(resolvedConfig.Shards) > 0 {
+		shards = resolvedConfig.Shards
+	}
+
 	shardReportChan := workflow.GetSignalChannel(ctx, scanShardReportChan)
 	for i := 0; i < resolvedConfig.GenericScannerConfig.Concurrency; i++ {
 		idx := i
 		workflow.Go(ctx, func(ctx workflow.Context) {
-			batches := getShardBatches(resolvedConfig.GenericScannerConfig.ActivityBatchSize, resolvedConfig.GenericScannerConfig.Concurrency, wf.Shards, idx)
+			batches := getShardBatches(resolvedConfig.GenericScannerConfig.ActivityBatchSize, resolvedConfig.GenericScannerConfig.Concurrency, shards, idx)
 			for _, batch := range batches {
 				activityCtx = getLongActivityContext(ctx)
 				var reports []ScanReport
@@ -174,13 +181,19 @@ func (wf *ScannerWorkflow) Start(ctx workflow.Context) error {
 	activityCtx = getShortActivityContext(ctx)
 	summary := wf.Aggregator.GetStatusSummary()
 
-	return workflow.ExecuteActivity(activityCtx, ActivityScannerEmitMetrics, ScannerEmitMetricsActivityParams{
+	if err := workflow.ExecuteActivity(activityCtx, ActivityScannerEmitMetrics, ScannerEmitMetricsActivityParams{
 		ShardSuccessCount:            summary[ShardStatusSuccess],
 		ShardControlFlowFailureCount: summary[ShardStatusControlFlowFailure],
 		AggregateReportResult:        wf.Aggregator.GetAggregateReport(),
 		ShardDistributionStats:       wf.Aggregator.GetShardDistributionStats(),
-	}).Get(ctx, nil)
+	}).Get(ctx, nil); err != nil {
+		return err
+	}
 
+	if workflow.GetVersion(ctx, purgeFindingsChangeID, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return nil
+	}
+	return workflow.ExecuteActivity(getLongActivityContext(ctx), ActivityScannerPurgeFindings).Get(ctx, nil)
 }
 
 func getScanHandlers(aggregator *ShardScanResultAggregator) map[string]interface{} {
@@ -0,0 +1,82 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package shardscanner
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+// orderShards returns a copy of shards in the given order. An order which can not be applied, because it is unknown
+// or because the scan timestamps are not recorded or can not be read, falls back to the sequential order
+func orderShards(activityCtx context.Context, ctx Context, shards []int, order ShardOrder) []int {
+	ordered := append([]int(nil), shards...)
+	switch order {
+	case ShardOrderSequential:
+	case ShardOrderRandom:
+		rand.Shuffle(len(ordered), func(i, j int) { ordered[i], ordered[j] = ordered[j], ordered[i] })
+	case ShardOrderLeastRecentlyScanned:
+		if ctx.ScanTimestamps == nil {
+			ctx.Logger.Warn("Scan timestamps of the shards are not recorded, scanning the shards sequentially",
+				tag.Value(order))
+			break
+		}
+		rows, err := ctx.ScanTimestamps.SelectShardScanTimestamps(activityCtx, ctx.Config.ScannerWFTypeName)
+		if err != nil {
+			ctx.Logger.Warn("Failed to read the scan timestamps of the shards, scanning the shards sequentially",
+				tag.Error(err))
+			break
+		}
+		lastScanned := make(map[int]time.Time, len(rows))
+		for _, row := range rows {
+			lastScanned[row.ShardID] = row.LastScannedTime
+		}
+		// a shard which was never scanned has the zero time, so it comes first
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return lastScanned[ordered[i]].Before(lastScanned[ordered[j]])
+		})
+	default:
+		ctx.Logger.Warn("Unknown shard order, scanning the shards sequentially", tag.Value(order))
+	}
+	return ordered
+}
+
+// recordShardScan records that the shard was just scanned if the scan timestamps are recorded. A failure is only
+// logged, it makes the shard look scanned less recently than it was, which at worst scans it again early
+func recordShardScan(activityCtx context.Context, ctx Context, shardID int) {
+	if ctx.ScanTimestamps == nil {
+		return
+	}
+	err := ctx.ScanTimestamps.ReplaceIntoShardScanTimestamps(activityCtx, &sqlplugin.ShardScanTimestampsRow{
+		ScannerName:     ctx.Config.ScannerWFTypeName,
+		ShardID:         shardID,
+		LastScannedTime: time.Now(),
+	})
+	if err != nil {
+		ctx.Logger.Warn("Failed to record the scan timestamp of a shard", tag.ShardID(shardID), tag.Error(err))
+	}
+}
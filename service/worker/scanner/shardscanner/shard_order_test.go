@@ -0,0 +1,109 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+type fakeScanTimestamps struct {
+	rows     []sqlplugin.ShardScanTimestampsRow
+	err      error
+	recorded []sqlplugin.ShardScanTimestampsRow
+}
+
+func (f *fakeScanTimestamps) ReplaceIntoShardScanTimestamps(ctx context.Context, row *sqlplugin.ShardScanTimestampsRow) error {
+	f.recorded = append(f.recorded, *row)
+	return f.err
+}
+
+func (f *fakeScanTimestamps) SelectShardScanTimestamps(ctx context.Context, scannerName string) ([]sqlplugin.ShardScanTimestampsRow, error) {
+	return f.rows, f.err
+}
+
+func shardOrderPtr(order ShardOrder) *ShardOrder {
+	return &order
+}
+
+func TestOrderShards(t *testing.T) {
+	now := time.Now()
+	timestamps := &fakeScanTimestamps{rows: []sqlplugin.ShardScanTimestampsRow{
+		{ScannerName: testWorkflowName, ShardID: 1, LastScannedTime: now},
+		{ScannerName: testWorkflowName, ShardID: 2, LastScannedTime: now.Add(-time.Hour)},
+		{ScannerName: testWorkflowName, ShardID: 4, LastScannedTime: now.Add(-time.Hour)},
+	}}
+	shards := []int{1, 2, 3, 4, 5}
+	tests := map[string]struct {
+		order          ShardOrder
+		scanTimestamps sqlplugin.ShardScanTimestampsStore
+		expected       []int
+	}{
+		"sequential": {order: ShardOrderSequential, scanTimestamps: timestamps, expected: []int{1, 2, 3, 4, 5}},
+		// the never scanned shards come first, ties keep the sequential order
+		"least recently scanned":     {order: ShardOrderLeastRecentlyScanned, scanTimestamps: timestamps, expected: []int{3, 5, 2, 4, 1}},
+		"timestamps not recorded":    {order: ShardOrderLeastRecentlyScanned, expected: []int{1, 2, 3, 4, 5}},
+		"timestamps can not be read": {order: ShardOrderLeastRecentlyScanned, scanTimestamps: &fakeScanTimestamps{err: errors.New("unavailable")}, expected: []int{1, 2, 3, 4, 5}},
+		"unknown":                    {order: "reverse", scanTimestamps: timestamps, expected: []int{1, 2, 3, 4, 5}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := Context{
+				Logger:         log.NewNoop(),
+				Config:         &ScannerConfig{ScannerWFTypeName: testWorkflowName},
+				ScanTimestamps: test.scanTimestamps,
+			}
+			assert.Equal(t, test.expected, orderShards(context.Background(), ctx, shards, test.order))
+		})
+	}
+
+	ctx := Context{Logger: log.NewNoop(), Config: &ScannerConfig{ScannerWFTypeName: testWorkflowName}}
+	random := orderShards(context.Background(), ctx, shards, ShardOrderRandom)
+	assert.ElementsMatch(t, shards, random)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, shards, "the given shards must not be reordered in place")
+}
+
+func TestRecordShardScan(t *testing.T) {
+	timestamps := &fakeScanTimestamps{err: errors.New("unavailable")}
+	ctx := Context{
+		Logger:         log.NewNoop(),
+		Config:         &ScannerConfig{ScannerWFTypeName: testWorkflowName},
+		ScanTimestamps: timestamps,
+	}
+	// a failure to record is not returned
+	recordShardScan(context.Background(), ctx, 7)
+	if assert.Len(t, timestamps.recorded, 1) {
+		assert.Equal(t, testWorkflowName, timestamps.recorded[0].ScannerName)
+		assert.Equal(t, 7, timestamps.recorded[0].ShardID)
+	}
+
+	ctx.ScanTimestamps = nil
+	recordShardScan(context.Background(), ctx, 7)
+}
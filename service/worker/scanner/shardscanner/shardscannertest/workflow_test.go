@@ -23,6 +23,7 @@
 package shardscannertest
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -33,6 +34,7 @@ import (
 	"go.uber.org/cadence/workflow"
 
 	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/reconciliation/invariant"
 	"github.com/uber/cadence/common/reconciliation/store"
 	"github.com/uber/cadence/service/worker/scanner/shardscanner"
 )
@@ -228,6 +230,17 @@ func (s *workflowsSuite) TestFixerWorkflow_Success() {
 						SkippedCount:  1,
 						FailedCount:   1,
 					},
+					InvariantStats: map[invariant.Name]*shardscanner.FixStats{
+						invariant.HistoryExists: {
+							EntitiesCount: 2,
+							FixedCount:    2,
+						},
+						invariant.OpenCurrentExecution: {
+							EntitiesCount: 2,
+							SkippedCount:  1,
+							FailedCount:   1,
+						},
+					},
 					Result: shardscanner.FixResult{
 						ShardFixKeys: &shardscanner.FixKeys{
 							Skipped: &store.Keys{
@@ -250,6 +263,11 @@ func (s *workflowsSuite) TestFixerWorkflow_Success() {
 		}).Return(reports, nil)
 	}
 
+	env.OnActivity(shardscanner.ActivityFixerEmitSummary, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, summary shardscanner.FixerSummary) (shardscanner.FixerSummary, error) {
+			return summary, nil
+		})
+
 	env.ExecuteWorkflow(NewTestFixerWorkflow, shardscanner.FixerWorkflowParams{
 		ScannerWorkflowWorkflowID:     "test_wid",
 		ScannerWorkflowRunID:          "test_rid",
@@ -258,6 +276,32 @@ func (s *workflowsSuite) TestFixerWorkflow_Success() {
 	s.True(env.IsWorkflowCompleted())
 	s.NoError(env.GetWorkflowError())
 
+	summaryValue, err := env.QueryWorkflow(shardscanner.FixerSummaryQuery)
+	s.NoError(err)
+	var summary shardscanner.FixerSummary
+	s.NoError(summaryValue.Get(&summary))
+	s.Equal(shardscanner.FixerSummary{
+		ShardSuccessCount:            24,
+		ShardControlFlowFailureCount: 6,
+		AggregateReportResult: shardscanner.AggregateFixReportResult{
+			EntitiesCount: 240,
+			FixedCount:    48,
+			FailedCount:   24,
+			SkippedCount:  24,
+		},
+		InvariantStats: map[invariant.Name]shardscanner.FixStats{
+			invariant.HistoryExists: {
+				EntitiesCount: 48,
+				FixedCount:    48,
+			},
+			invariant.OpenCurrentExecution: {
+				EntitiesCount: 48,
+				SkippedCount:  24,
+				FailedCount:   24,
+			},
+		},
+	}, summary)
+
 	aggValue, err := env.QueryWorkflow(shardscanner.AggregateReportQuery)
 	s.NoError(err)
 	var agg shardscanner.AggregateFixReportResult
@@ -295,6 +339,17 @@ func (s *workflowsSuite) TestFixerWorkflow_Success() {
 					FailedCount:   1,
 					SkippedCount:  1,
 				},
+				InvariantStats: map[invariant.Name]*shardscanner.FixStats{
+					invariant.HistoryExists: {
+						EntitiesCount: 2,
+						FixedCount:    2,
+					},
+					invariant.OpenCurrentExecution: {
+						EntitiesCount: 2,
+						SkippedCount:  1,
+						FailedCount:   1,
+					},
+				},
 				Result: shardscanner.FixResult{
 					ShardFixKeys: &shardscanner.FixKeys{
 						Skipped: &store.Keys{
@@ -347,6 +402,51 @@ func (s *workflowsSuite) TestFixerWorkflow_Success() {
 	s.Equal(15, *status.ShardQueryPaginationToken.NextShardID)
 }
 
+func (s *workflowsSuite) TestFixerWorkflow_SummaryFailure() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(shardscanner.ActivityFixerCorruptedKeys, mock.Anything, mock.Anything).Return(&shardscanner.FixerCorruptedKeysActivityResult{
+		CorruptedKeys: []shardscanner.CorruptedKeysEntry{{ShardID: 0}},
+		MinShard:      common.IntPtr(0),
+		MaxShard:      common.IntPtr(0),
+		ShardQueryPaginationToken: shardscanner.ShardQueryPaginationToken{
+			IsDone: true,
+		},
+	}, nil)
+	env.OnActivity(shardscanner.ActivityFixShard, mock.Anything, mock.Anything).Return([]shardscanner.FixReport{
+		{
+			ShardID: 0,
+			Stats: shardscanner.FixStats{
+				EntitiesCount: 1,
+				FixedCount:    1,
+			},
+			Result: shardscanner.FixResult{
+				ShardFixKeys: &shardscanner.FixKeys{},
+			},
+		},
+	}, nil)
+	env.OnActivity(shardscanner.ActivityFixerEmitSummary, mock.Anything, mock.Anything).Return(shardscanner.FixerSummary{}, errors.New("emit failed"))
+
+	env.ExecuteWorkflow(NewTestFixerWorkflow, shardscanner.FixerWorkflowParams{
+		ScannerWorkflowWorkflowID: "test_wid",
+		ScannerWorkflowRunID:      "test_rid",
+	})
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	summaryValue, err := env.QueryWorkflow(shardscanner.FixerSummaryQuery)
+	s.NoError(err)
+	var summary shardscanner.FixerSummary
+	s.NoError(summaryValue.Get(&summary))
+	s.Equal(shardscanner.FixerSummary{
+		ShardSuccessCount: 1,
+		AggregateReportResult: shardscanner.AggregateFixReportResult{
+			EntitiesCount: 1,
+			FixedCount:    1,
+		},
+		InvariantStats: map[invariant.Name]shardscanner.FixStats{},
+	}, summary)
+}
+
 func (s *workflowsSuite) TestGetCorruptedKeys_Success() {
 	env := s.NewTestWorkflowEnvironment()
 	env.OnActivity(shardscanner.ActivityFixerCorruptedKeys, mock.Anything, shardscanner.FixerCorruptedKeysActivityParams{
@@ -33,10 +33,12 @@ import (
 	"go.uber.org/cadence/client"
 	"go.uber.org/cadence/workflow"
 
+	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/dynamicconfig"
 	"github.com/uber/cadence/common/log"
 	"github.com/uber/cadence/common/log/tag"
 	"github.com/uber/cadence/common/metrics"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/common/reconciliation/invariant"
 	"github.com/uber/cadence/common/reconciliation/store"
 	"github.com/uber/cadence/common/resource"
@@ -51,6 +53,20 @@ const (
 	ErrMissingHooks = "hooks are not provided for this scanner"
 )
 
+const (
+	// ShardOrderSequential scans the shards in the order they are given in, numeric order for a range
+	ShardOrderSequential ShardOrder = "sequential"
+	// ShardOrderRandom scans the shards in a random order which differs from one scan to the next
+	ShardOrderRandom ShardOrder = "random"
+	// ShardOrderLeastRecentlyScanned scans the shards which were never scanned first, then the shards
+	// which were scanned least recently. It requires the scan timestamps of the shards to be recorded
+	ShardOrderLeastRecentlyScanned ShardOrder = "least_recently_scanned"
+)
+
+// ShardOrder is the order in which a scanner scans its shards, a scan which does not complete
+// only covers the shards at the front of the order
+type ShardOrder string
+
 type (
 	contextKey string
 
@@ -61,6 +77,12 @@ type (
 		Scope    metrics.Scope
 		Config   *ScannerConfig
 		Logger   log.Logger
+		// Findings is nil unless the findings are recorded in the scanner_findings table in addition to the blobstore
+		Findings sqlplugin.ScannerFindingsStore
+		// ScanTimestamps is nil unless the time each shard is scanned at is recorded in the scanner_shard_scans table
+		ScanTimestamps sqlplugin.ShardScanTimestampsStore
+		// FindingsRetention is how long the findings are kept in the scanner_findings table, it is nil unless Findings is set
+		FindingsRetention dynamicconfig.DurationPropertyFn
 	}
 
 	// FixerContext is the resource that is available to activities under ShardFixer key
@@ -80,6 +102,15 @@ type (
 		ShardDistributionStats       ShardDistributionStats
 	}
 
+	// FixerSummary is the structured outcome of a complete run of ShardFixer.
+	// It is recorded as the result of FixerEmitSummaryActivity and served by FixerSummaryQuery.
+	FixerSummary struct {
+		ShardSuccessCount            int
+		ShardControlFlowFailureCount int
+		AggregateReportResult        AggregateFixReportResult
+		InvariantStats               map[invariant.Name]FixStats
+	}
+
 	// ShardRange identifies a set of shards based on min (inclusive) and max (exclusive)
 	ShardRange struct {
 		Min int
@@ -102,6 +133,8 @@ type (
 	// ScannerConfigActivityParams is the parameter for ScannerConfigActivity
 	ScannerConfigActivityParams struct {
 		Overwrites ScannerWorkflowConfigOverwrites
+		// Shards are the shards of the scan, they are returned in the resolved shard order
+		Shards []int
 	}
 
 	// ScanShardActivityParams is the parameter for ScanShardActivity
@@ -161,12 +194,17 @@ type (
 		Failed  *store.Keys
 	}
 
-	// FixReport is the report of running Fix on a single shard
+	// FixReport is the report of running Fix on a single shard.
+	// The fixed executions of a DryRun report are the executions which would be fixed, nothing was changed.
 	FixReport struct {
 		ShardID     int
 		Stats       FixStats
 		Result      FixResult
 		DomainStats map[string]*FixStats
+		// InvariantStats breaks Stats down by the invariant which determined the fix result,
+		// executions without a determining invariant are only counted in Stats.
+		InvariantStats map[invariant.Name]*FixStats
+		DryRun         bool
 	}
 
 	// FixStats indicates the stats of executions that were handled by shard Fix.
@@ -185,7 +223,7 @@ type (
 	}
 
 	// FixKeys are the keys to the blobs that were uploaded during fix.
-	// Keys can be nil if there were no uploads.
+	// Keys can be nil if there were no uploads. Fixed has the diff extension for a dry-run fix.
 	FixKeys struct {
 		Skipped *store.Keys
 		Failed  *store.Keys
@@ -225,6 +263,7 @@ type (
 		PageSize                int
 		BlobstoreFlushThreshold int
 		ActivityBatchSize       int
+		ShardOrder              ShardOrder
 	}
 
 	// GenericScannerConfigOverwrites allows to override generic params
@@ -234,6 +273,7 @@ type (
 		PageSize                *int
 		BlobstoreFlushThreshold *int
 		ActivityBatchSize       *int
+		ShardOrder              *ShardOrder
 	}
 
 	// ResolvedScannerWorkflowConfig is the resolved config after reading dynamic config
@@ -241,6 +281,9 @@ type (
 	ResolvedScannerWorkflowConfig struct {
 		GenericScannerConfig GenericScannerConfig
 		CustomScannerConfig  CustomScannerConfig
+		// Shards are the shards of ScannerConfigActivityParams in ShardOrder, they are empty if the config was
+		// resolved by a worker which does not order the shards and the shards are scanned as given then
+		Shards []int
 	}
 
 	// ScannerWorkflowConfigOverwrites enables overwriting the values in dynamic config.
@@ -260,16 +303,20 @@ type (
 		BlobstoreFlushThreshold dynamicconfig.IntPropertyFn
 		ActivityBatchSize       dynamicconfig.IntPropertyFn
 		AllowDomain             dynamicconfig.BoolPropertyFnWithDomainFilter
+		// ShardOrder is nil for the scanners which always scan their shards sequentially
+		ShardOrder dynamicconfig.StringPropertyFn
 	}
 
 	// ScannerConfig is the  config for ShardScanner workflow
 	ScannerConfig struct {
-		ScannerWFTypeName    string
-		FixerWFTypeName      string
-		ScannerHooks         func() *ScannerHooks
-		FixerHooks           func() *FixerHooks
-		DynamicParams        DynamicParams
-		DynamicCollection    *dynamicconfig.Collection
+		ScannerWFTypeName string
+		FixerWFTypeName   string
+		ScannerHooks      func() *ScannerHooks
+		FixerHooks        func() *FixerHooks
+		DynamicParams     DynamicParams
+		DynamicCollection *dynamicconfig.Collection
+		// Persistence is the persistence config of the worker, it is only set for scanners which read the SQL store directly
+		Persistence          *config.Persistence
 		StartWorkflowOptions client.StartWorkflowOptions
 		StartFixerOptions    client.StartWorkflowOptions
 	}
@@ -281,13 +328,16 @@ type (
 		Concurrency             *int
 		BlobstoreFlushThreshold *int
 		ActivityBatchSize       *int
+		DryRun                  *bool
 	}
 
 	// ResolvedFixerWorkflowConfig is the resolved config after reading defaults and applying overwrites.
+	// A DryRun fixer changes nothing, it writes the changes each fix would make to blobs with the diff extension.
 	ResolvedFixerWorkflowConfig struct {
 		Concurrency             int
 		BlobstoreFlushThreshold int
 		ActivityBatchSize       int
+		DryRun                  bool
 	}
 )
 
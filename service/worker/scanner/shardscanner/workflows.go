@@ -33,4 +33,6 @@ func init() {
 	activity.RegisterWithOptions(ScannerConfigActivity, activity.RegisterOptions{Name: ActivityScannerConfig})
 	activity.RegisterWithOptions(FixerCorruptedKeysActivity, activity.RegisterOptions{Name: ActivityFixerCorruptedKeys})
 	activity.RegisterWithOptions(FixShardActivity, activity.RegisterOptions{Name: ActivityFixShard})
+	activity.RegisterWithOptions(FixerEmitSummaryActivity, activity.RegisterOptions{Name: ActivityFixerEmitSummary})
+	activity.RegisterWithOptions(ScannerPurgeFindingsActivity, activity.RegisterOptions{Name: ActivityScannerPurgeFindings})
 }
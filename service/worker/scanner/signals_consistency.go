@@ -0,0 +1,224 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/workflow"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/quotas"
+	"github.com/uber/cadence/common/reconciliation/entity"
+	"github.com/uber/cadence/common/reconciliation/store"
+)
+
+const (
+	signalsConsistencyWFTypeName       = "cadence-sys-signals-consistency-workflow"
+	signalsConsistencyTaskListName     = "cadence-sys-signals-consistency-tasklist-0"
+	signalsConsistencyActivityName     = "cadence-sys-signals-consistency-activity"
+	signalsConsistencyBlobstorePageLen = 100
+
+	// SignalsMismatchNotDelivered is the reason of a signal ID in signals_requested_sets without a signal_info_maps record
+	SignalsMismatchNotDelivered = "requested but never delivered"
+	// SignalsMismatchNotRequested is the reason of a signal_info_maps record whose request ID is not in signals_requested_sets
+	SignalsMismatchNotRequested = "delivered but never requested"
+	// SignalsMismatchUndecodable is the reason of a signal_info_maps record whose blob can not be decoded
+	SignalsMismatchUndecodable = "signal info can not be decoded"
+)
+
+type (
+	// SignalsConsistencyParams are the parameters of SignalsConsistencyWorkflow.
+	// QPS is the number of executions checked per second, it defaults to ScannerPersistenceMaxQPS.
+	SignalsConsistencyParams struct {
+		Executions []entity.Execution
+		QPS        int
+	}
+
+	// SignalsMismatch is a signal found on only one side of an execution.
+	// InitiatedID is the key of the signal_info_maps record, 0 for signals only found in signals_requested_sets
+	SignalsMismatch struct {
+		Execution   entity.Execution
+		SignalID    string
+		InitiatedID int64
+		Reason      string
+	}
+
+	// SignalsConsistencyReport is the result of SignalsConsistencyWorkflow
+	SignalsConsistencyReport struct {
+		// Checked is the number of executions checked
+		Checked    int
+		Mismatches []SignalsMismatch
+		// Keys are the blobstore keys the mismatches were written to, nil if no blobstore is configured
+		Keys *store.Keys
+	}
+)
+
+func init() {
+	workflow.RegisterWithOptions(SignalsConsistencyWorkflow, workflow.RegisterOptions{Name: signalsConsistencyWFTypeName})
+	activity.RegisterWithOptions(SignalsConsistencyActivity, activity.RegisterOptions{Name: signalsConsistencyActivityName})
+}
+
+// SignalsConsistencyWorkflow compares the signals_requested_sets entries of executions against the request IDs of
+// their signal_info_maps records. It is not scheduled, operators start it with the executions to check.
+func SignalsConsistencyWorkflow(
+	ctx workflow.Context,
+	params SignalsConsistencyParams,
+) (*SignalsConsistencyReport, error) {
+
+	var report SignalsConsistencyReport
+	future := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, activityOptions), signalsConsistencyActivityName, params)
+	if err := future.Get(ctx, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SignalsConsistencyActivity checks the executions one after another, throttled to params.QPS executions per second.
+// It only reads from persistence, the mismatches are written to the blobstore of the scanner, if configured.
+func SignalsConsistencyActivity(
+	activityCtx context.Context,
+	params SignalsConsistencyParams,
+) (*SignalsConsistencyReport, error) {
+
+	ctx, err := getScannerContext(activityCtx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDefaultSQLDB(ctx.cfg.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	if err != nil {
+		return nil, err
+	}
+	qps := params.QPS
+	if qps <= 0 {
+		qps = ctx.cfg.ScannerPersistenceMaxQPS()
+	}
+	limiter := quotas.NewSimpleRateLimiter(qps)
+
+	report := &SignalsConsistencyReport{}
+	if activity.HasHeartbeatDetails(activityCtx) {
+		if err := activity.GetHeartbeatDetails(activityCtx, report); err != nil {
+			return nil, err
+		}
+	}
+	for report.Checked < len(params.Executions) {
+		if err := limiter.Wait(activityCtx); err != nil {
+			return nil, err
+		}
+		mismatches, err := checkSignalsConsistency(activityCtx, db, parser, params.Executions[report.Checked])
+		if err != nil {
+			return nil, err
+		}
+		report.Mismatches = append(report.Mismatches, mismatches...)
+		report.Checked++
+		activity.RecordHeartbeat(activityCtx, report)
+	}
+
+	if client := ctx.resource.GetBlobstoreClient(); client != nil && len(report.Mismatches) > 0 {
+		writer := store.NewBlobstoreWriter(uuid.New().String(), store.SignalsMismatchExtension, client, signalsConsistencyBlobstorePageLen)
+		for _, m := range report.Mismatches {
+			if err := writer.Add(m); err != nil {
+				return nil, err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return nil, err
+		}
+		report.Keys = writer.FlushedKeys()
+	}
+	return report, nil
+}
+
+// checkSignalsConsistency returns the signals of an execution which are only found in one of
+// signals_requested_sets and signal_info_maps, ordered by signal ID
+func checkSignalsConsistency(
+	ctx context.Context,
+	db sqlplugin.DB,
+	parser serialization.Parser,
+	execution entity.Execution,
+) ([]SignalsMismatch, error) {
+
+	domainID, err := uuid.Parse(execution.DomainID)
+	if err != nil {
+		return nil, err
+	}
+	runID, err := uuid.Parse(execution.RunID)
+	if err != nil {
+		return nil, err
+	}
+	shardID := int64(execution.ShardID)
+	requestedRows, err := db.SelectFromSignalsRequestedSets(ctx, &sqlplugin.SignalsRequestedSetsFilter{
+		ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+	})
+	if err != nil {
+		return nil, err
+	}
+	infoRows, err := db.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []SignalsMismatch
+	// delivered holds a candidate mismatch per decoded signal_info_maps record, it is reported unless requested
+	var delivered []SignalsMismatch
+	deliveredIDs := make(map[string]bool, len(infoRows))
+	for _, row := range infoRows {
+		info, err := parser.SignalInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			result = append(result, SignalsMismatch{Execution: execution, InitiatedID: row.InitiatedID, Reason: SignalsMismatchUndecodable})
+			continue
+		}
+		deliveredIDs[info.RequestID] = true
+		delivered = append(delivered, SignalsMismatch{Execution: execution, SignalID: info.RequestID, InitiatedID: row.InitiatedID, Reason: SignalsMismatchNotRequested})
+	}
+	requested := make(map[string]bool, len(requestedRows))
+	for _, row := range requestedRows {
+		requested[row.SignalID] = true
+		if !deliveredIDs[row.SignalID] {
+			result = append(result, SignalsMismatch{Execution: execution, SignalID: row.SignalID, Reason: SignalsMismatchNotDelivered})
+		}
+	}
+	for _, m := range delivered {
+		if !requested[m.SignalID] {
+			result = append(result, m)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].SignalID != result[j].SignalID {
+			return result[i].SignalID < result[j].SignalID
+		}
+		return result[i].InitiatedID < result[j].InitiatedID
+	})
+	return result, nil
+}
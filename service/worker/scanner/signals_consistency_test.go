@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+// signalsDB serves signal_info_maps and signals_requested_sets from memory, every other method panics
+type signalsDB struct {
+	sqlplugin.DB
+	infos     []sqlplugin.SignalInfoMapsRow
+	requested []sqlplugin.SignalsRequestedSetsRow
+}
+
+func (db *signalsDB) SelectFromSignalInfoMaps(ctx context.Context, filter *sqlplugin.SignalInfoMapsFilter) ([]sqlplugin.SignalInfoMapsRow, error) {
+	return db.infos, nil
+}
+
+func (db *signalsDB) SelectFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) ([]sqlplugin.SignalsRequestedSetsRow, error) {
+	return db.requested, nil
+}
+
+func TestCheckSignalsConsistency(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	infoRow := func(initiatedID int64, requestID string) sqlplugin.SignalInfoMapsRow {
+		blob, err := parser.SignalInfoToBlob(&serialization.SignalInfo{RequestID: requestID})
+		require.NoError(t, err)
+		return sqlplugin.SignalInfoMapsRow{InitiatedID: initiatedID, Data: blob.Data, DataEncoding: string(blob.Encoding)}
+	}
+	db := &signalsDB{
+		infos: []sqlplugin.SignalInfoMapsRow{
+			infoRow(5, "both"),
+			infoRow(6, "delivered-only"),
+			{InitiatedID: 7, Data: []byte("garbage"), DataEncoding: string(common.EncodingTypeThriftRW)},
+		},
+		requested: []sqlplugin.SignalsRequestedSetsRow{{SignalID: "both"}, {SignalID: "requested-only"}},
+	}
+	execution := entity.Execution{
+		ShardID:    1,
+		DomainID:   "8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10",
+		WorkflowID: "wid",
+		RunID:      "2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b",
+	}
+
+	mismatches, err := checkSignalsConsistency(context.Background(), db, parser, execution)
+	require.NoError(t, err)
+	assert.Equal(t, []SignalsMismatch{
+		{Execution: execution, InitiatedID: 7, Reason: SignalsMismatchUndecodable},
+		{Execution: execution, SignalID: "delivered-only", InitiatedID: 6, Reason: SignalsMismatchNotRequested},
+		{Execution: execution, SignalID: "requested-only", Reason: SignalsMismatchNotDelivered},
+	}, mismatches)
+
+	db.infos = db.infos[:1]
+	db.requested = db.requested[:1]
+	mismatches, err = checkSignalsConsistency(context.Background(), db, parser, execution)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	execution.DomainID = "invalid"
+	_, err = checkSignalsConsistency(context.Background(), db, parser, execution)
+	assert.Error(t, err)
+}
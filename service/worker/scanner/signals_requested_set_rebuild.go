@@ -0,0 +1,205 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/workflow"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+const (
+	signalsRequestedSetRebuildWFTypeName   = "cadence-sys-signals-requested-set-rebuild-workflow"
+	signalsRequestedSetRebuildTaskListName = "cadence-sys-signals-requested-set-rebuild-tasklist-0"
+	signalsRequestedSetRebuildActivityName = "cadence-sys-signals-requested-set-rebuild-activity"
+)
+
+var errSignalInfoUndecodable = errors.New("signal_info_maps has records which can not be decoded, the requested set can not be derived")
+
+type (
+	// SignalsRequestedSetRebuildReport is the result of rebuilding the signals_requested_sets entries of an execution,
+	// Before and After are the sorted signal IDs of the set. Error is set if the set was left unchanged
+	SignalsRequestedSetRebuildReport struct {
+		Execution entity.Execution
+		Before    []string
+		After     []string
+		Error     string
+	}
+)
+
+func init() {
+	workflow.RegisterWithOptions(SignalsRequestedSetRebuildWorkflow, workflow.RegisterOptions{Name: signalsRequestedSetRebuildWFTypeName})
+	activity.RegisterWithOptions(SignalsRequestedSetRebuildActivity, activity.RegisterOptions{Name: signalsRequestedSetRebuildActivityName})
+}
+
+// SignalsRequestedSetRebuildWorkflow rewrites the signals_requested_sets entries of the flagged executions from their
+// signal_info_maps records, which are authoritative. It is not scheduled, operators start it with the executions
+// SignalsConsistencyWorkflow reported mismatches for, no other execution is touched.
+func SignalsRequestedSetRebuildWorkflow(
+	ctx workflow.Context,
+	executions []entity.Execution,
+) ([]SignalsRequestedSetRebuildReport, error) {
+
+	var reports []SignalsRequestedSetRebuildReport
+	future := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, activityOptions), signalsRequestedSetRebuildActivityName, executions)
+	if err := future.Get(ctx, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// SignalsRequestedSetRebuildActivity rebuilds the signals requested set of every execution, one after another.
+// The set before and after each rebuild is logged.
+func SignalsRequestedSetRebuildActivity(
+	activityCtx context.Context,
+	executions []entity.Execution,
+) ([]SignalsRequestedSetRebuildReport, error) {
+
+	ctx, err := getScannerContext(activityCtx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDefaultSQLDB(ctx.cfg.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []SignalsRequestedSetRebuildReport
+	if activity.HasHeartbeatDetails(activityCtx) {
+		if err := activity.GetHeartbeatDetails(activityCtx, &reports); err != nil {
+			return nil, err
+		}
+	}
+	for len(reports) < len(executions) {
+		report, err := rebuildSignalsRequestedSet(activityCtx, db, parser, ctx.resource.GetLogger(), executions[len(reports)])
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+		activity.RecordHeartbeat(activityCtx, reports)
+	}
+	return reports, nil
+}
+
+// rebuildSignalsRequestedSet derives the signals requested set of an execution from the request IDs of its
+// signal_info_maps records and replaces the signals_requested_sets entries with it, deleting all entries and
+// inserting the derived ones in a single transaction. The set is left unchanged if a record can not be decoded.
+func rebuildSignalsRequestedSet(
+	ctx context.Context,
+	db sqlplugin.DB,
+	parser serialization.Parser,
+	logger log.Logger,
+	execution entity.Execution,
+) (*SignalsRequestedSetRebuildReport, error) {
+
+	domainID, err := uuid.Parse(execution.DomainID)
+	if err != nil {
+		return nil, err
+	}
+	runID, err := uuid.Parse(execution.RunID)
+	if err != nil {
+		return nil, err
+	}
+	shardID := int64(execution.ShardID)
+	logger = logger.WithTags(
+		tag.ShardID(execution.ShardID),
+		tag.WorkflowDomainID(execution.DomainID),
+		tag.WorkflowID(execution.WorkflowID),
+		tag.WorkflowRunID(execution.RunID),
+	)
+	report := &SignalsRequestedSetRebuildReport{Execution: execution}
+
+	requestedRows, err := db.SelectFromSignalsRequestedSets(ctx, &sqlplugin.SignalsRequestedSetsFilter{
+		ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range requestedRows {
+		report.Before = append(report.Before, row.SignalID)
+	}
+	sort.Strings(report.Before)
+	infoRows, err := db.SelectFromSignalInfoMaps(ctx, &sqlplugin.SignalInfoMapsFilter{
+		ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+	})
+	if err != nil {
+		return nil, err
+	}
+	derived := make(map[string]bool, len(infoRows))
+	for _, row := range infoRows {
+		info, err := parser.SignalInfoFromBlob(row.Data, row.DataEncoding)
+		if err != nil {
+			report.After = report.Before
+			report.Error = errSignalInfoUndecodable.Error()
+			logger.Warn("Signals requested set not rebuilt", tag.Value(report.Before), tag.Error(errSignalInfoUndecodable))
+			return report, nil
+		}
+		derived[info.RequestID] = true
+	}
+	rows := make([]sqlplugin.SignalsRequestedSetsRow, 0, len(derived))
+	for signalID := range derived {
+		report.After = append(report.After, signalID)
+	}
+	sort.Strings(report.After)
+	for _, signalID := range report.After {
+		rows = append(rows, sqlplugin.SignalsRequestedSetsRow{
+			ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:], SignalID: signalID,
+		})
+	}
+
+	logger.Info("Rebuilding signals requested set", tag.Value(report.Before))
+	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(execution.ShardID, db.GetTotalNumDBShards())
+	tx, err := db.BeginTx(ctx, dbShardID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.DeleteFromSignalsRequestedSets(ctx, &sqlplugin.SignalsRequestedSetsFilter{
+		ShardID: shardID, DomainID: domainID[:], WorkflowID: execution.WorkflowID, RunID: runID[:],
+	}); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	if _, err := tx.InsertIntoSignalsRequestedSets(ctx, rows); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	logger.Info("Rebuilt signals requested set", tag.Value(report.After))
+	return report, nil
+}
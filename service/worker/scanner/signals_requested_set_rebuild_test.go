@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/reconciliation/entity"
+)
+
+// signalsRebuildDB is a signalsDB whose transactions rewrite the requested set in memory once committed
+type signalsRebuildDB struct {
+	signalsDB
+	committed bool
+}
+
+// signalsRebuildTx stages the rewrite of the requested set of a signalsRebuildDB, every other method panics
+type signalsRebuildTx struct {
+	sqlplugin.Tx
+	db        *signalsRebuildDB
+	requested []sqlplugin.SignalsRequestedSetsRow
+}
+
+func (db *signalsRebuildDB) GetTotalNumDBShards() int {
+	return 1
+}
+
+func (db *signalsRebuildDB) BeginTx(ctx context.Context, dbShardID int) (sqlplugin.Tx, error) {
+	return &signalsRebuildTx{db: db, requested: db.requested}, nil
+}
+
+func (tx *signalsRebuildTx) DeleteFromSignalsRequestedSets(ctx context.Context, filter *sqlplugin.SignalsRequestedSetsFilter) (sql.Result, error) {
+	tx.requested = nil
+	return nil, nil
+}
+
+func (tx *signalsRebuildTx) InsertIntoSignalsRequestedSets(ctx context.Context, rows []sqlplugin.SignalsRequestedSetsRow) (sql.Result, error) {
+	tx.requested = append(tx.requested, rows...)
+	return nil, nil
+}
+
+func (tx *signalsRebuildTx) Commit() error {
+	tx.db.requested = tx.requested
+	tx.db.committed = true
+	return nil
+}
+
+func TestRebuildSignalsRequestedSet(t *testing.T) {
+	parser, err := serialization.NewParser(common.EncodingTypeThriftRW, common.EncodingTypeThriftRW)
+	require.NoError(t, err)
+	infoRow := func(initiatedID int64, requestID string) sqlplugin.SignalInfoMapsRow {
+		blob, err := parser.SignalInfoToBlob(&serialization.SignalInfo{RequestID: requestID})
+		require.NoError(t, err)
+		return sqlplugin.SignalInfoMapsRow{InitiatedID: initiatedID, Data: blob.Data, DataEncoding: string(blob.Encoding)}
+	}
+	db := &signalsRebuildDB{signalsDB: signalsDB{
+		infos:     []sqlplugin.SignalInfoMapsRow{infoRow(5, "both"), infoRow(6, "delivered-only")},
+		requested: []sqlplugin.SignalsRequestedSetsRow{{SignalID: "requested-only"}, {SignalID: "both"}},
+	}}
+	execution := entity.Execution{
+		ShardID:    1,
+		DomainID:   "8a4e1c3a-59a7-4a8c-95d4-5d1b0f2a3f10",
+		WorkflowID: "wid",
+		RunID:      "2f1b7b1e-4d6e-4f8e-9c1a-3b5d7e9f1a2b",
+	}
+
+	report, err := rebuildSignalsRequestedSet(context.Background(), db, parser, log.NewNoop(), execution)
+	require.NoError(t, err)
+	assert.Equal(t, &SignalsRequestedSetRebuildReport{
+		Execution: execution,
+		Before:    []string{"both", "requested-only"},
+		After:     []string{"both", "delivered-only"},
+	}, report)
+	assert.True(t, db.committed)
+	var signalIDs []string
+	for _, row := range db.requested {
+		assert.Equal(t, int64(1), row.ShardID)
+		assert.Equal(t, "wid", row.WorkflowID)
+		signalIDs = append(signalIDs, row.SignalID)
+	}
+	assert.Equal(t, []string{"both", "delivered-only"}, signalIDs)
+
+	// the set is left unchanged when a signal info can not be decoded
+	db.committed = false
+	db.infos = append(db.infos, sqlplugin.SignalInfoMapsRow{InitiatedID: 7, Data: []byte("garbage"), DataEncoding: string(common.EncodingTypeThriftRW)})
+	report, err = rebuildSignalsRequestedSet(context.Background(), db, parser, log.NewNoop(), execution)
+	require.NoError(t, err)
+	assert.Equal(t, errSignalInfoUndecodable.Error(), report.Error)
+	assert.Equal(t, report.Before, report.After)
+	assert.False(t, db.committed)
+}
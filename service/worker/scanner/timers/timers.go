@@ -44,8 +44,9 @@ import (
 
 const (
 	// ScannerWFTypeName defines workflow type name for concrete executions scanner
-	ScannerWFTypeName   = "cadence-sys-timers-scanner-workflow"
-	wfid                = "cadence-sys-timers-scanner"
+	ScannerWFTypeName = "cadence-sys-timers-scanner-workflow"
+	// ScannerWFID is the workflow ID of the timers scanner
+	ScannerWFID         = "cadence-sys-timers-scanner"
 	scannerTaskListName = "cadence-sys-timers-scanner-tasklist-0"
 
 	// FixerWFTypeName defines workflow type name for timers fixer
@@ -181,7 +182,7 @@ func ScannerConfig(dc *dynamicconfig.Collection) *shardscanner.ScannerConfig {
 		FixerHooks:        FixerHooks,
 
 		StartWorkflowOptions: client.StartWorkflowOptions{
-			ID:                           wfid,
+			ID:                           ScannerWFID,
 			TaskList:                     scannerTaskListName,
 			ExecutionStartToCloseTimeout: 20 * 365 * 24 * time.Hour,
 			WorkflowIDReusePolicy:        client.WorkflowIDReusePolicyAllowDuplicate,
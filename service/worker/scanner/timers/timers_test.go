@@ -80,6 +80,7 @@ func (s *timersWorkflowsSuite) TestScannerWorkflow_Success() {
 		CustomScannerConfig: cconfig,
 	}, nil)
 	env.OnActivity(shardscanner.ActivityScannerEmitMetrics, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(shardscanner.ActivityScannerPurgeFindings, mock.Anything).Return(int64(0), nil)
 	shards := shardscanner.Shards{
 		Range: &shardscanner.ShardRange{
 			Min: 0,
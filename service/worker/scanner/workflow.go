@@ -22,6 +22,7 @@ package scanner
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"go.uber.org/cadence"
@@ -50,6 +51,10 @@ const (
 	historyScannerWFTypeName     = "cadence-sys-history-scanner-workflow"
 	historyScannerTaskListName   = "cadence-sys-history-scanner-tasklist-0"
 	historyScavengerActivityName = "cadence-sys-history-scanner-scvg-activity"
+
+	scannerEnabledActivityName = "cadence-sys-scanner-enabled-activity"
+	// scannerEnabledChangeID versions the task list and history scanner workflows which check the enabled flag of the scanner
+	scannerEnabledChangeID = "scanner-enabled-check"
 )
 
 var (
@@ -67,6 +72,15 @@ var (
 		HeartbeatTimeout:       5 * time.Minute,
 		RetryPolicy:            &activityRetryPolicy,
 	}
+	enabledActivityOptions = workflow.ActivityOptions{
+		ScheduleToStartTimeout: 5 * time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		RetryPolicy: &cadence.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 1.7,
+			ExpirationInterval: 10 * time.Minute,
+		},
+	}
 	tlScannerWFStartOptions = cclient.StartWorkflowOptions{
 		ID:                           tlScannerWFID,
 		TaskList:                     tlScannerTaskListName,
@@ -84,11 +98,14 @@ var (
 )
 
 func init() {
+	activity.RegisterWithOptions(ScannerEnabledActivity, activity.RegisterOptions{Name: scannerEnabledActivityName})
+
 	workflow.RegisterWithOptions(TaskListScannerWorkflow, workflow.RegisterOptions{Name: tlScannerWFTypeName})
 	activity.RegisterWithOptions(TaskListScavengerActivity, activity.RegisterOptions{Name: taskListScavengerActivityName})
 
 	workflow.RegisterWithOptions(HistoryScannerWorkflow, workflow.RegisterOptions{Name: historyScannerWFTypeName})
 	activity.RegisterWithOptions(HistoryScavengerActivity, activity.RegisterOptions{Name: historyScavengerActivityName})
+	activity.RegisterWithOptions(HistoryScavengerProgressActivity, activity.RegisterOptions{Name: historyScavengerProgressActivityName})
 
 	workflow.RegisterWithOptions(executions.ConcreteScannerWorkflow, workflow.RegisterOptions{Name: executions.ConcreteExecutionsScannerWFTypeName})
 	workflow.RegisterWithOptions(executions.CurrentScannerWorkflow, workflow.RegisterOptions{Name: executions.CurrentExecutionsScannerWFTypeName})
@@ -103,6 +120,9 @@ func TaskListScannerWorkflow(
 	ctx workflow.Context,
 ) error {
 
+	if enabled, err := isScannerEnabled(ctx, tlScannerWFTypeName); err != nil || !enabled {
+		return err
+	}
 	future := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, activityOptions), taskListScavengerActivityName)
 	return future.Get(ctx, nil)
 }
@@ -112,13 +132,56 @@ func HistoryScannerWorkflow(
 	ctx workflow.Context,
 ) error {
 
+	if enabled, err := isScannerEnabled(ctx, historyScannerWFTypeName); err != nil || !enabled {
+		return err
+	}
 	future := workflow.ExecuteActivity(
 		workflow.WithActivityOptions(ctx, activityOptions),
 		historyScavengerActivityName,
 	)
+	if workflow.GetVersion(ctx, historyScavengerProgressChangeID, workflow.DefaultVersion, 1) == 1 {
+		monitorHistoryScavengerProgress(ctx, future)
+	}
 	return future.Get(ctx, nil)
 }
 
+// isScannerEnabled checks the dynamic config enabled flag of the running scanner,
+// a disabled scanner completes the cron run without doing any work. The runs started before the check was
+// added are always enabled
+func isScannerEnabled(ctx workflow.Context, scannerWFTypeName string) (bool, error) {
+	if workflow.GetVersion(ctx, scannerEnabledChangeID, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return true, nil
+	}
+	var enabled bool
+	future := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, enabledActivityOptions), scannerEnabledActivityName, scannerWFTypeName)
+	if err := future.Get(ctx, &enabled); err != nil {
+		return false, err
+	}
+	if !enabled {
+		workflow.GetLogger(ctx).Info("scanner is disabled by dynamic config, skipping this run")
+	}
+	return enabled, nil
+}
+
+// ScannerEnabledActivity returns the dynamic config enabled flag of the given scanner workflow type
+func ScannerEnabledActivity(
+	activityCtx context.Context,
+	scannerWFTypeName string,
+) (bool, error) {
+	ctx, err := getScannerContext(activityCtx)
+	if err != nil {
+		return false, err
+	}
+	switch scannerWFTypeName {
+	case tlScannerWFTypeName:
+		return ctx.cfg.TaskListScannerEnabled(), nil
+	case historyScannerWFTypeName:
+		return ctx.cfg.HistoryScannerEnabled(), nil
+	default:
+		return true, nil
+	}
+}
+
 // HistoryScavengerActivity is the activity that runs history scavenger
 func HistoryScavengerActivity(
 	activityCtx context.Context,
@@ -134,14 +197,26 @@ func HistoryScavengerActivity(
 
 	hbd := history.ScavengerHeartbeatDetails{}
 	if activity.HasHeartbeatDetails(activityCtx) {
-		if err := activity.GetHeartbeatDetails(activityCtx, &hbd); err != nil {
+		// the details are recovered as raw JSON, so those recorded by another version of the scavenger can be decoded
+		var details json.RawMessage
+		err := activity.GetHeartbeatDetails(activityCtx, &details)
+		if err == nil {
+			hbd, err = history.DecodeScavengerHeartbeatDetails(details)
+		}
+		if err != nil {
 			res.GetLogger().Error("Failed to recover from last heartbeat, start over from beginning", tag.Error(err))
+		} else if hbd.Version != history.ScavengerHeartbeatDetailsVersion {
+			res.GetLogger().Info("Recovered heartbeat details recorded by another version of the scavenger",
+				tag.Dynamic("heartbeat-details-version", hbd.Version))
 		}
 	}
 	cache := res.GetDomainCache()
 	scavenger := history.NewScavenger(
 		res.GetHistoryManager(),
 		rps,
+		ctx.cfg.ScannerPersistenceMaxQPSPerDomain,
+		ctx.cfg.ScannerHistoryScavengerConcurrency(),
+		ctx.cfg.ScannerHistoryScavengerMaxHeartbeatDetailsSize,
 		res.GetHistoryClient(),
 		hbd,
 		res.GetMetricsClient(),
@@ -174,12 +249,14 @@ func TaskListScavengerActivity(
 	scavenger.Start()
 	for scavenger.Alive() {
 		activity.RecordHeartbeat(activityCtx)
-		if activityCtx.Err() != nil {
+		// the scavenger is stopped as soon as the activity is cancelled, not once the heartbeat interval is over
+		select {
+		case <-activityCtx.Done():
 			res.GetLogger().Info("activity context error, stopping scavenger", tag.Error(activityCtx.Err()))
 			scavenger.Stop()
 			return activityCtx.Err()
+		case <-ctx.clock.After(tlScavengerHBInterval):
 		}
-		time.Sleep(tlScavengerHBInterval)
 	}
 	return nil
 }
@@ -22,10 +22,12 @@ package scanner
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 
@@ -33,8 +35,11 @@ import (
 	"github.com/uber/cadence/common/metrics"
 	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/resource"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/service/worker/scanner/history"
 	"github.com/uber/cadence/service/worker/scanner/tasklist"
 
+	"go.uber.org/cadence"
 	"go.uber.org/cadence/testsuite"
 	"go.uber.org/cadence/worker"
 )
@@ -50,9 +55,136 @@ func TestScannerWorkflowTestSuite(t *testing.T) {
 
 func (s *scannerWorkflowTestSuite) TestWorkflow() {
 	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(scannerEnabledActivityName, mock.Anything, mock.Anything).Return(true, nil)
 	env.OnActivity(taskListScavengerActivityName, mock.Anything).Return(nil)
 	env.ExecuteWorkflow(tlScannerWFTypeName)
 	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+}
+
+func (s *scannerWorkflowTestSuite) TestWorkflow_Disabled() {
+	for _, wfTypeName := range []string{tlScannerWFTypeName, historyScannerWFTypeName} {
+		env := s.NewTestWorkflowEnvironment()
+		env.OnActivity(scannerEnabledActivityName, mock.Anything, mock.Anything).Return(false, nil)
+		env.ExecuteWorkflow(wfTypeName)
+		s.True(env.IsWorkflowCompleted(), wfTypeName)
+		s.NoError(env.GetWorkflowError(), wfTypeName)
+	}
+}
+
+func (s *scannerWorkflowTestSuite) TestScannerEnabledActivity() {
+	for _, enabled := range []bool{true, false} {
+		env := s.NewTestWorkflowEnvironment()
+		ctx := scannerContext{
+			cfg: Config{
+				HistoryScannerEnabled: dynamicconfig.GetBoolPropertyFn(enabled),
+			},
+		}
+		env.SetWorkerOptions(worker.Options{
+			BackgroundActivityContext: NewScannerContext(context.Background(), "default-test-workflow-type-name", ctx),
+		})
+		scavengerCalled := false
+		env.OnActivity(historyScavengerActivityName, mock.Anything).Return(
+			func(context.Context) (history.ScavengerHeartbeatDetails, error) {
+				scavengerCalled = true
+				return history.ScavengerHeartbeatDetails{}, nil
+			},
+		)
+		env.ExecuteWorkflow(historyScannerWFTypeName)
+		s.True(env.IsWorkflowCompleted())
+		s.NoError(env.GetWorkflowError())
+		s.Equal(enabled, scavengerCalled)
+	}
+}
+
+func (s *scannerWorkflowTestSuite) TestHistoryScannerWorkflow_ChecksScavengerProgress() {
+	env := s.NewTestWorkflowEnvironment()
+	env.OnActivity(scannerEnabledActivityName, mock.Anything, mock.Anything).Return(true, nil)
+	env.OnActivity(historyScavengerActivityName, mock.Anything).
+		After(2*historyScavengerProgressCheckInterval+time.Minute).
+		Return(history.ScavengerHeartbeatDetails{}, nil)
+	var checked []historyScavengerProgress
+	env.OnActivity(historyScavengerProgressActivityName, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, last historyScavengerProgress) (historyScavengerProgress, error) {
+			checked = append(checked, last)
+			return historyScavengerProgress{CurrentPage: len(checked)}, nil
+		},
+	)
+	env.ExecuteWorkflow(historyScannerWFTypeName)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	// every check is passed the progress returned by the one before
+	s.Equal([]historyScavengerProgress{{}, {CurrentPage: 1}}, checked)
+}
+
+func (s *scannerWorkflowTestSuite) TestHistoryScavengerProgressActivity() {
+	controller := gomock.NewController(s.T())
+	defer controller.Finish()
+	mockResource := resource.NewTest(controller, metrics.Worker)
+	defer mockResource.Finish(s.T())
+	clock := clockwork.NewFakeClock()
+	ctx := scannerContext{
+		resource: mockResource,
+		clock:    clock,
+		cfg: Config{
+			HistoryScannerStallAlertThreshold: dynamicconfig.GetDurationPropertyFn(time.Hour),
+		},
+	}
+	env := s.NewTestActivityEnvironment()
+	env.SetWorkerOptions(worker.Options{
+		BackgroundActivityContext: NewScannerContext(context.Background(), "default-test-workflow-type-name", ctx),
+	})
+	details, err := json.Marshal(history.ScavengerHeartbeatDetails{CurrentPage: 3, SuccCount: 10, SkipCount: 2})
+	s.NoError(err)
+	heartbeat := clock.Now().UnixNano()
+	mockResource.FrontendClient.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any()).Return(&types.DescribeWorkflowExecutionResponse{
+		PendingActivities: []*types.PendingActivityInfo{{
+			ActivityType:           &types.ActivityType{Name: historyScavengerActivityName},
+			HeartbeatDetails:       details,
+			LastHeartbeatTimestamp: &heartbeat,
+		}},
+	}, nil).AnyTimes()
+	stalledCount := func() int64 {
+		var count int64
+		for _, counter := range mockResource.MetricsScope.Snapshot().Counters() {
+			if counter.Name() == "test.scavenger_stalled" {
+				count += counter.Value()
+			}
+		}
+		return count
+	}
+	check := func(last historyScavengerProgress) historyScavengerProgress {
+		value, err := env.ExecuteActivity(historyScavengerProgressActivityName, last)
+		s.NoError(err)
+		var progress historyScavengerProgress
+		s.NoError(value.Get(&progress))
+		return progress
+	}
+
+	// the scavenger moved since the last check
+	progress := check(historyScavengerProgress{CurrentPage: 2, Processed: 5, Since: clock.Now().Add(-2 * time.Hour)})
+	s.Equal(3, progress.CurrentPage)
+	s.Equal(12, progress.Processed)
+	s.True(clock.Now().Equal(progress.Since))
+	s.Zero(stalledCount())
+
+	// no progress, but not for long enough to alert
+	last := historyScavengerProgress{CurrentPage: 3, Processed: 12, Since: clock.Now().Add(-time.Minute)}
+	progress = check(last)
+	s.True(last.Since.Equal(progress.Since))
+	s.Zero(stalledCount())
+
+	// no progress for longer than the threshold while heartbeating
+	last.Since = clock.Now().Add(-2 * time.Hour)
+	progress = check(last)
+	s.True(last.Since.Equal(progress.Since))
+	s.Equal(int64(1), stalledCount())
+
+	// a scavenger which stopped heartbeating is not reported as stalled
+	clock.Advance(activityOptions.HeartbeatTimeout + time.Minute)
+	check(last)
+	s.Equal(int64(1), stalledCount())
 }
 
 func (s *scannerWorkflowTestSuite) TestScavengerActivity() {
@@ -64,8 +196,10 @@ func (s *scannerWorkflowTestSuite) TestScavengerActivity() {
 
 	mockResource.TaskMgr.On("ListTaskList", mock.Anything, mock.Anything).Return(&p.ListTaskListResponse{}, nil)
 	mockResource.TaskMgr.On("GetOrphanTasks", mock.Anything, mock.Anything).Return(&p.GetOrphanTasksResponse{}, nil)
+	clock := clockwork.NewFakeClock()
 	ctx := scannerContext{
 		resource: mockResource,
+		clock:    clock,
 		cfg: Config{
 			TaskListScannerOptions: tasklist.Options{
 				GetOrphanTasksPageSizeFn: dynamicconfig.GetIntPropertyFn(dynamicconfig.ScannerGetOrphanTasksPageSize.DefaultInt()),
@@ -78,7 +212,63 @@ func (s *scannerWorkflowTestSuite) TestScavengerActivity() {
 	env.SetWorkerOptions(worker.Options{
 		BackgroundActivityContext: NewScannerContext(context.Background(), "default-test-workflow-type-name", ctx),
 	})
-	tlScavengerHBInterval = time.Millisecond * 10
-	_, err := env.ExecuteActivity(taskListScavengerActivityName)
-	s.NoError(err)
+	done := make(chan error)
+	go func() {
+		_, err := env.ExecuteActivity(taskListScavengerActivityName)
+		done <- err
+	}()
+	// every heartbeat waits on the fake clock, advance it until the scavenger finishes
+	for {
+		select {
+		case err := <-done:
+			s.NoError(err)
+			return
+		case <-time.After(time.Millisecond):
+			clock.Advance(tlScavengerHBInterval)
+		}
+	}
+}
+
+func (s *scannerWorkflowTestSuite) TestScavengerActivityCancellation() {
+	env := s.NewTestActivityEnvironment()
+	controller := gomock.NewController(s.T())
+	defer controller.Finish()
+	mockResource := resource.NewTest(controller, metrics.Worker)
+	defer mockResource.Finish(s.T())
+
+	// the scavenger stays alive until the listing is released
+	release := make(chan struct{})
+	mockResource.TaskMgr.On("ListTaskList", mock.Anything, mock.Anything).Return(&p.ListTaskListResponse{}, nil).Run(func(mock.Arguments) { <-release })
+	clock := clockwork.NewFakeClock()
+	ctx := scannerContext{
+		resource: mockResource,
+		clock:    clock,
+		cfg: Config{
+			TaskListScannerOptions: tasklist.Options{
+				GetOrphanTasksPageSizeFn: dynamicconfig.GetIntPropertyFn(dynamicconfig.ScannerGetOrphanTasksPageSize.DefaultInt()),
+				EnableCleaning:           dynamicconfig.GetBoolPropertyFn(false),
+				ExecutorPollInterval:     time.Millisecond * 50,
+			},
+		},
+	}
+	activityCtx, cancel := context.WithCancel(context.Background())
+	env.SetTestTimeout(time.Second * 5)
+	env.SetWorkerOptions(worker.Options{
+		BackgroundActivityContext: NewScannerContext(activityCtx, "default-test-workflow-type-name", ctx),
+	})
+	done := make(chan error)
+	go func() {
+		_, err := env.ExecuteActivity(taskListScavengerActivityName)
+		done <- err
+	}()
+	// the fake clock is never advanced, so the activity can only return because it was cancelled
+	clock.BlockUntil(1)
+	cancel()
+	close(release)
+	select {
+	case err := <-done:
+		s.IsType(&cadence.CanceledError{}, err)
+	case <-time.After(time.Second):
+		s.Fail("scavenger activity did not stop after cancellation")
+	}
 }
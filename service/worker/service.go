@@ -133,23 +133,33 @@ func NewConfig(params *resource.Params) *Config {
 			AllowArchivingIncompleteHistory: dc.GetBoolProperty(dynamicconfig.AllowArchivingIncompleteHistory),
 		},
 		ScannerCfg: &scanner.Config{
-			ScannerPersistenceMaxQPS: dc.GetIntProperty(dynamicconfig.ScannerPersistenceMaxQPS),
+			ScannerPersistenceMaxQPS:                       dc.GetIntProperty(dynamicconfig.ScannerPersistenceMaxQPS),
+			ScannerPersistenceMaxQPSPerDomain:              dc.GetIntPropertyFilteredByDomain(dynamicconfig.ScannerPersistenceMaxQPSPerDomain),
+			ScannerHistoryScavengerConcurrency:             dc.GetIntProperty(dynamicconfig.ScannerHistoryScavengerConcurrency),
+			ScannerHistoryScavengerMaxHeartbeatDetailsSize: dc.GetIntProperty(dynamicconfig.ScannerHistoryScavengerMaxHeartbeatDetailsSize),
 			TaskListScannerOptions: tasklist.Options{
 				GetOrphanTasksPageSizeFn: dc.GetIntProperty(dynamicconfig.ScannerGetOrphanTasksPageSize),
 				TaskBatchSizeFn:          dc.GetIntProperty(dynamicconfig.ScannerBatchSizeForTasklistHandler),
 				EnableCleaning:           dc.GetBoolProperty(dynamicconfig.EnableCleaningOrphanTaskInTasklistScavenger),
 				MaxTasksPerJobFn:         dc.GetIntProperty(dynamicconfig.ScannerMaxTasksProcessedPerTasklistJob),
 			},
-			Persistence:            &params.PersistenceConfig,
-			ClusterMetadata:        params.ClusterMetadata,
-			TaskListScannerEnabled: dc.GetBoolProperty(dynamicconfig.TaskListScannerEnabled),
-			HistoryScannerEnabled:  dc.GetBoolProperty(dynamicconfig.HistoryScannerEnabled),
+			Persistence:                       &params.PersistenceConfig,
+			ClusterMetadata:                   params.ClusterMetadata,
+			TaskListScannerEnabled:            dc.GetBoolProperty(dynamicconfig.TaskListScannerEnabled),
+			HistoryScannerEnabled:             dc.GetBoolProperty(dynamicconfig.HistoryScannerEnabled),
+			HistoryScannerStallAlertThreshold: dc.GetDurationProperty(dynamicconfig.HistoryScannerStallAlertThreshold),
 			ShardScanners: []*shardscanner.ScannerConfig{
-				executions.ConcreteExecutionScannerConfig(dc),
+				executions.ConcreteExecutionScannerConfig(dc, &params.PersistenceConfig),
 				executions.CurrentExecutionScannerConfig(dc),
 				timers.ScannerConfig(dc),
 			},
-			MaxWorkflowRetentionInDays: dc.GetIntProperty(dynamicconfig.MaxRetentionDays),
+			MaxWorkflowRetentionInDays:                            dc.GetIntProperty(dynamicconfig.MaxRetentionDays),
+			DedicatedWorkerEnabled:                                dc.GetBoolProperty(dynamicconfig.ScannerDedicatedWorkerEnabled),
+			FindingsTableEnabled:                                  dc.GetBoolProperty(dynamicconfig.ScannerFindingsTableEnabled),
+			ShardScanTimestampsEnabled:                            dc.GetBoolProperty(dynamicconfig.ScannerShardScanTimestampsEnabled),
+			FindingsRetention:                                     dc.GetDurationProperty(dynamicconfig.ScannerFindingsRetention),
+			DedicatedWorkerMaxConcurrentActivityExecutionSize:     dc.GetIntProperty(dynamicconfig.ScannerDedicatedWorkerMaxConcurrentActivityExecutionSize),
+			DedicatedWorkerMaxConcurrentDecisionTaskExecutionSize: dc.GetIntProperty(dynamicconfig.ScannerDedicatedWorkerMaxConcurrentDecisionTaskExecutionSize),
 		},
 		BatcherCfg: &batcher.Config{
 			AdminOperationToken: dc.GetStringProperty(dynamicconfig.AdminOperationToken),
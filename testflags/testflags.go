@@ -46,23 +46,23 @@ var (
 // 3) Want to be able to run individual tests (go testflags errors if the test doesn't import
 // something that defines the testflags.)
 
-func RequireMySQL(t *testing.T) {
+func RequireMySQL(t testing.TB) {
 	require(t, mysql)
 }
 
-func RequirePostgres(t *testing.T) {
+func RequirePostgres(t testing.TB) {
 	require(t, postgres)
 }
 
-func RequireMongoDB(t *testing.T) {
+func RequireMongoDB(t testing.TB) {
 	require(t, mongodb)
 }
 
-func RequireCassandra(t *testing.T) {
+func RequireCassandra(t testing.TB) {
 	require(t, cassandra)
 }
 
-func require(t *testing.T, name string) {
+func require(t testing.TB, name string) {
 	if !checkEnv(name) {
 		t.Skip(fmt.Sprintf("Skipping test that requires %s to run - start %s and set '%s=1' environment variable to run this test.",
 			name, name, name))
@@ -368,6 +368,14 @@ func newAdminHistoryHostCommands() []cli.Command {
 					Name:  FlagNumberOfShards,
 					Usage: "NumberOfShards for the cadence cluster(see config for numHistoryShards)",
 				},
+				cli.IntFlag{
+					Name:  FlagNumberOfDBShards,
+					Usage: "Number of DB shards of a sharded SQL database (see config for the connections of the SQL datastore), also prints the dbShardID the execution map rows of the workflow are in",
+				},
+				cli.IntFlag{
+					Name:  FlagPreviousNumberOfDBShards,
+					Usage: "Previous number of DB shards while the number of DB shards is migrated (see config for previousNShards of the SQL datastore), also prints the dbShardID the rows not migrated yet are in",
+				},
 			},
 			Action: func(c *cli.Context) {
 				AdminGetShardID(c)
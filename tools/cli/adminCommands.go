@@ -34,6 +34,7 @@ import (
 	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/codec"
 	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/common/types"
 	"github.com/uber/cadence/common/types/mapper/thrift"
 )
@@ -365,6 +366,18 @@ func AdminGetShardID(c *cli.Context) {
 	}
 	shardID := common.WorkflowIDToHistoryShard(wid, numberOfShards)
 	fmt.Printf("ShardID for workflowID: %v is %v \n", wid, shardID)
+	numberOfDBShards := c.Int(FlagNumberOfDBShards)
+	if numberOfDBShards <= 0 {
+		return
+	}
+	dbShardID := sqlplugin.GetDBShardIDFromHistoryShardID(shardID, numberOfDBShards)
+	fmt.Printf("DBShardID for shardID: %v is %v \n", shardID, dbShardID)
+	// while the number of DB shards is migrated, the rows which are not migrated yet are read from the previous DB shard
+	if previousNumberOfDBShards := c.Int(FlagPreviousNumberOfDBShards); previousNumberOfDBShards > 0 {
+		if previous := sqlplugin.GetDBShardIDFromHistoryShardID(shardID, previousNumberOfDBShards); previous != dbShardID {
+			fmt.Printf("Previous DBShardID for shardID: %v is %v \n", shardID, previous)
+		}
+	}
 }
 
 // AdminRemoveTask describes history host
@@ -56,6 +56,8 @@ const (
 	FlagTreeID                            = "tree_id"
 	FlagBranchID                          = "branch_id"
 	FlagNumberOfShards                    = "number_of_shards"
+	FlagNumberOfDBShards                  = "number_of_db_shards"
+	FlagPreviousNumberOfDBShards          = "previous_number_of_db_shards"
 	FlagRunIDWithAlias                    = FlagRunID + ", rid, r"
 	FlagTargetCluster                     = "target_cluster"
 	FlagTargetClusterWithAlias            = FlagTargetCluster + ", tc"